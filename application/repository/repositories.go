@@ -2,6 +2,8 @@ package repository
 
 import (
 	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	logout "github.com/fabric8-services/fabric8-auth/authentication/logout/repository"
+	mfa "github.com/fabric8-services/fabric8-auth/authentication/mfa/repository"
 	provider "github.com/fabric8-services/fabric8-auth/authentication/provider/repository"
 	invitation "github.com/fabric8-services/fabric8-auth/authorization/invitation/repository"
 	permission "github.com/fabric8-services/fabric8-auth/authorization/permission/repository"
@@ -17,8 +19,17 @@ type Repositories interface {
 	Identities() account.IdentityRepository
 	Users() account.UserRepository
 	OauthStates() provider.OauthStateReferenceRepository
+	AuthorizationCodes() provider.AuthorizationCodeRepository
 	ExternalTokens() token.ExternalTokenRepository
 	VerificationCodes() account.VerificationCodeRepository
+	PendingEmailChanges() account.PendingEmailChangeRepository
+	UsernameHistory() account.UsernameHistoryRepository
+	OutboxEvents() account.OutboxEventRepository
+	AuditEvents() account.AuditEventRepository
+	UserDataExports() account.UserDataExportRepository
+	UserBans() account.UserBanRepository
+	UserBulkOperations() account.UserBulkOperationRepository
+	UserProfileAttributes() account.UserProfileAttributeRepository
 	InvitationRepository() invitation.InvitationRepository
 	ResourceRepository() resource.ResourceRepository
 	ResourceTypeRepository() resourcetype.ResourceTypeRepository
@@ -28,6 +39,16 @@ type Repositories interface {
 	DefaultRoleMappingRepository() role.DefaultRoleMappingRepository
 	RoleMappingRepository() role.RoleMappingRepository
 	TokenRepository() token.TokenRepository
+	SessionRepository() token.SessionRepository
 	PrivilegeCacheRepository() permission.PrivilegeCacheRepository
 	WorkerLockRepository() worker.LockRepository
+	JobRepository() worker.JobRepository
+	JobRunRepository() worker.JobRunRepository
+	QueueTaskRepository() worker.QueueTaskRepository
+	BackChannelLogoutNotifications() logout.BackChannelLogoutNotificationRepository
+	TOTPCredentials() mfa.TOTPCredentialRepository
+	RecoveryCodes() mfa.RecoveryCodeRepository
+	MFAChallenges() mfa.MFAChallengeRepository
+	WebAuthnCredentials() mfa.WebAuthnCredentialRepository
+	WebAuthnRegistrations() mfa.WebAuthnRegistrationRepository
 }
@@ -11,8 +11,11 @@ import (
 	"github.com/fabric8-services/fabric8-auth/application/transaction"
 	userservice "github.com/fabric8-services/fabric8-auth/authentication/account/service"
 	logoutservice "github.com/fabric8-services/fabric8-auth/authentication/logout/service"
+	mfaservice "github.com/fabric8-services/fabric8-auth/authentication/mfa/service"
 	providerservice "github.com/fabric8-services/fabric8-auth/authentication/provider/service"
+	scimservice "github.com/fabric8-services/fabric8-auth/authentication/scim/service"
 	subscriptionservice "github.com/fabric8-services/fabric8-auth/authentication/subscription/service"
+	groupservice "github.com/fabric8-services/fabric8-auth/authorization/group/service"
 	invitationservice "github.com/fabric8-services/fabric8-auth/authorization/invitation/service"
 	organizationservice "github.com/fabric8-services/fabric8-auth/authorization/organization/service"
 	permissionservice "github.com/fabric8-services/fabric8-auth/authorization/permission/service"
@@ -242,6 +245,10 @@ func (f *ServiceFactory) AuthenticationProviderService() service.AuthenticationP
 	return f.authProviderServiceFunc()
 }
 
+func (f *ServiceFactory) GroupService() service.GroupService {
+	return groupservice.NewGroupService(f.getContext())
+}
+
 func (f *ServiceFactory) InvitationService() service.InvitationService {
 	return invitationservice.NewInvitationService(f.getContext(), f.config)
 }
@@ -254,16 +261,24 @@ func (f *ServiceFactory) LogoutService() service.LogoutService {
 	return logoutservice.NewLogoutService(f.getContext(), f.config)
 }
 
+func (f *ServiceFactory) MFAService() service.MFAService {
+	return mfaservice.NewMFAService(f.getContext(), f.config)
+}
+
 func (f *ServiceFactory) OrganizationService() service.OrganizationService {
 	return organizationservice.NewOrganizationService(f.getContext())
 }
 
+func (f *ServiceFactory) OutboxService() service.OutboxService {
+	return userservice.NewOutboxService(f.getContext(), f.config)
+}
+
 func (f *ServiceFactory) OSOSubscriptionService() service.OSOSubscriptionService {
 	return subscriptionservice.NewOSOSubscriptionService(f.getContext(), f.config)
 }
 
 func (f *ServiceFactory) PermissionService() service.PermissionService {
-	return permissionservice.NewPermissionService(f.getContext())
+	return permissionservice.NewPermissionService(f.getContext(), f.config)
 }
 
 func (f *ServiceFactory) PrivilegeCacheService() service.PrivilegeCacheService {
@@ -278,6 +293,10 @@ func (f *ServiceFactory) RoleManagementService() service.RoleManagementService {
 	return roleservice.NewRoleManagementService(f.getContext())
 }
 
+func (f *ServiceFactory) SCIMService() service.SCIMService {
+	return scimservice.NewSCIMService(f.getContext(), f.config)
+}
+
 func (f *ServiceFactory) TeamService() service.TeamService {
 	return teamservice.NewTeamService(f.getContext())
 }
@@ -294,6 +313,30 @@ func (f *ServiceFactory) UserService() service.UserService {
 	return f.userServiceFunc()
 }
 
+func (f *ServiceFactory) UserDataExportService() service.UserDataExportService {
+	return userservice.NewUserDataExportService(f.getContext(), f.config)
+}
+
+func (f *ServiceFactory) UserBulkOperationService() service.UserBulkOperationService {
+	return userservice.NewUserBulkOperationService(f.getContext(), f.config)
+}
+
+func (f *ServiceFactory) UserProfileAttributeService() service.UserProfileAttributeService {
+	return userservice.NewUserProfileAttributeService(f.getContext())
+}
+
+func (f *ServiceFactory) UserMergeService() service.UserMergeService {
+	return userservice.NewUserMergeService(f.getContext())
+}
+
+func (f *ServiceFactory) AvatarService() service.AvatarService {
+	return userservice.NewAvatarService(f.getContext(), f.config)
+}
+
+func (f *ServiceFactory) WebAuthnService() service.WebAuthnService {
+	return mfaservice.NewWebAuthnService(f.getContext(), f.config)
+}
+
 func (f *ServiceFactory) UserProfileService() service.UserProfileService {
 	return providerservice.NewUserProfileService(f.getContext())
 }
@@ -9,7 +9,10 @@ import (
 	"github.com/fabric8-services/fabric8-auth/app"
 	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
 	"github.com/fabric8-services/fabric8-auth/authentication/account/tenant"
+	mfarepo "github.com/fabric8-services/fabric8-auth/authentication/mfa/repository"
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa/webauthn"
 	"github.com/fabric8-services/fabric8-auth/authentication/provider"
+	"github.com/fabric8-services/fabric8-auth/authentication/scim"
 	"github.com/fabric8-services/fabric8-auth/authorization"
 	"github.com/fabric8-services/fabric8-auth/authorization/invitation"
 	permission "github.com/fabric8-services/fabric8-auth/authorization/permission/repository"
@@ -18,9 +21,11 @@ import (
 	rolerepo "github.com/fabric8-services/fabric8-auth/authorization/role/repository"
 	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
 	tokenrepo "github.com/fabric8-services/fabric8-auth/authorization/token/repository"
+	"github.com/fabric8-services/fabric8-auth/blob"
 	"github.com/fabric8-services/fabric8-auth/cluster"
 	"github.com/fabric8-services/fabric8-auth/notification"
 	"github.com/fabric8-services/fabric8-auth/rest"
+	worker "github.com/fabric8-services/fabric8-auth/worker/repository"
 	"github.com/goadesign/goa"
 	uuid "github.com/satori/go.uuid"
 	"golang.org/x/oauth2"
@@ -46,7 +51,7 @@ Steps for adding a new Service:
 */
 
 type AuthenticationProviderService interface {
-	AuthorizeCallback(ctx context.Context, state string, code string) (*string, error)
+	AuthorizeCallback(ctx context.Context, state string, code string, callbackURL string) (*string, error)
 	CreateOrUpdateIdentityAndUser(ctx context.Context, referrerURL *url.URL,
 		providerToken *oauth2.Token) (*string, *oauth2.Token, error)
 	UpdateIdentityUsingUserInfoEndPoint(ctx context.Context, accessToken string) (*account.Identity, error)
@@ -55,6 +60,8 @@ type AuthenticationProviderService interface {
 	GenerateAuthCodeURL(ctx context.Context, redirect *string, apiClient *string,
 		state *string, scopes []string, responseMode *string, referrer string, callbackURL string) (*string, error)
 	LoginCallback(ctx context.Context, state string, code string, redirectURL string) (*string, error)
+	CompleteMultiFactorLogin(ctx context.Context, challengeID uuid.UUID, code string) (*string, error)
+	CompleteWebAuthnLogin(ctx context.Context, challengeID uuid.UUID, response webauthn.AssertionResponse) (*string, error)
 	LoadReferrerAndResponseMode(ctx context.Context, state string) (string, *string, error)
 	SaveReferrer(ctx context.Context, state string, referrer string,
 		responseMode *string, validReferrerURL string) error
@@ -86,6 +93,29 @@ type LinkService interface {
 
 type LogoutService interface {
 	Logout(ctx context.Context, redirectURL string) (string, error)
+	ScheduleBackChannelLogoutNotifications(ctx context.Context, identityID uuid.UUID, sessionID *uuid.UUID) error
+	SendBackChannelLogoutNotifications(ctx context.Context, options ...rest.HTTPClientOption) (int, error)
+}
+
+// MFAService manages the multi-factor authentication of the users
+type MFAService interface {
+	EnrollTOTP(ctx context.Context, identityID uuid.UUID) (string, string, error)
+	ConfirmTOTP(ctx context.Context, identityID uuid.UUID, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, identityID uuid.UUID) error
+	IsEnrolled(ctx context.Context, identityID uuid.UUID) (bool, error)
+	StartChallenge(ctx context.Context, identityID uuid.UUID, referrer string, apiClient string) (*mfarepo.MFAChallenge, error)
+	VerifyChallenge(ctx context.Context, challengeID uuid.UUID, code string) (*mfarepo.MFAChallenge, error)
+	ReEncryptTOTPCredentials(ctx context.Context) (int, error)
+}
+
+// WebAuthnService manages the WebAuthn credentials of the users, used as a second authentication factor
+type WebAuthnService interface {
+	BeginRegistration(ctx context.Context, identityID uuid.UUID) (*webauthn.CreationOptions, error)
+	FinishRegistration(ctx context.Context, identityID uuid.UUID, name string, response webauthn.AttestationResponse) (*mfarepo.WebAuthnCredential, error)
+	ListCredentials(ctx context.Context, identityID uuid.UUID) ([]mfarepo.WebAuthnCredential, error)
+	DeleteCredential(ctx context.Context, identityID uuid.UUID, credentialID uuid.UUID) error
+	BeginAssertion(ctx context.Context, challengeID uuid.UUID) (*webauthn.RequestOptions, error)
+	FinishAssertion(ctx context.Context, challengeID uuid.UUID, response webauthn.AssertionResponse) (*mfarepo.MFAChallenge, error)
 }
 
 type NotificationService interface {
@@ -99,9 +129,21 @@ type AdminConsoleService interface {
 	CreateAuditLog(ctx context.Context, username string, eventType string) error
 }
 
+// OutboxService delivers the side effects which were recorded in the outbox along with the changes in the auth DB
+type OutboxService interface {
+	DeliverEvent(ctx context.Context, task worker.QueueTask) error
+	DeliverEvents(ctx context.Context) (int, error)
+}
+
 type OrganizationService interface {
 	CreateOrganization(ctx context.Context, creatorIdentityID uuid.UUID, organizationName string) (*uuid.UUID, error)
 	ListOrganizations(ctx context.Context, identityID uuid.UUID) ([]authorization.IdentityAssociation, error)
+	UpdateOrganization(ctx context.Context, identityID uuid.UUID, organizationID uuid.UUID, organizationName string) (*account.Identity, error)
+	DeleteOrganization(ctx context.Context, identityID uuid.UUID, organizationID uuid.UUID, detachSpaces bool) error
+	ListOrganizationMembers(ctx context.Context, identityID uuid.UUID, organizationID uuid.UUID) ([]account.Identity, error)
+	RemoveOrganizationMember(ctx context.Context, identityID uuid.UUID, organizationID uuid.UUID, memberID uuid.UUID) error
+	TransferOrganizationOwnership(ctx context.Context, identityID uuid.UUID, organizationID uuid.UUID, newOwnerID uuid.UUID) error
+	AttachSpace(ctx context.Context, identityID uuid.UUID, organizationID uuid.UUID, spaceID string) error
 }
 
 type OSOSubscriptionService interface {
@@ -147,10 +189,25 @@ type TenantService interface {
 	View(ctx context.Context) (*tenant.TenantSingle, error)
 }
 
+// GroupService manages the security groups, and their nesting in other groups, teams and organizations
+type GroupService interface {
+	CreateGroup(ctx context.Context, creatorIdentityID uuid.UUID, groupName string) (*uuid.UUID, error)
+	AddGroupMember(ctx context.Context, identityID uuid.UUID, groupID uuid.UUID, memberID uuid.UUID) error
+	RemoveGroupMember(ctx context.Context, identityID uuid.UUID, groupID uuid.UUID, memberID uuid.UUID) error
+	ListGroupMembers(ctx context.Context, identityID uuid.UUID, groupID uuid.UUID, transitive bool) ([]account.Identity, error)
+	NestGroup(ctx context.Context, identityID uuid.UUID, groupID uuid.UUID, parentID uuid.UUID) error
+	UnnestGroup(ctx context.Context, identityID uuid.UUID, groupID uuid.UUID, parentID uuid.UUID) error
+}
+
 type TeamService interface {
 	CreateTeam(ctx context.Context, identityID uuid.UUID, spaceID string, teamName string) (*uuid.UUID, error)
 	ListTeamsInSpace(ctx context.Context, identityID uuid.UUID, spaceID string) ([]account.Identity, error)
 	ListTeamsForIdentity(ctx context.Context, identityID uuid.UUID) ([]authorization.IdentityAssociation, error)
+	UpdateTeam(ctx context.Context, identityID uuid.UUID, teamID uuid.UUID, teamName string) (*account.Identity, error)
+	DeleteTeam(ctx context.Context, identityID uuid.UUID, teamID uuid.UUID) error
+	ListTeamMembers(ctx context.Context, identityID uuid.UUID, teamID uuid.UUID) ([]account.Identity, error)
+	RemoveTeamMember(ctx context.Context, identityID uuid.UUID, teamID uuid.UUID, memberID uuid.UUID) error
+	TransferTeam(ctx context.Context, identityID uuid.UUID, teamID uuid.UUID, spaceID string) (*account.Identity, error)
 }
 
 type TokenService interface {
@@ -158,9 +215,13 @@ type TokenService interface {
 	CleanupExpiredTokens(ctx context.Context) error
 	DeleteExternalToken(ctx context.Context, currentIdentity uuid.UUID, authURL string, forResource string) error
 	ExchangeRefreshToken(ctx context.Context, refreshToken string, rptToken string) (*manager.TokenSet, error)
+	ListSessions(ctx context.Context, identityID uuid.UUID) ([]tokenrepo.Session, error)
+	ReEncryptExternalTokens(ctx context.Context) (int, error)
 	RegisterToken(ctx context.Context, identityID uuid.UUID, tokenString string, tokenType string, privileges []tokenrepo.TokenPrivilege) (*tokenrepo.Token, error)
 	RetrieveExternalToken(ctx context.Context, forResource string, req *goa.RequestData, forcePull *bool) (*app.ExternalToken, *string, error)
 	SetStatusForAllIdentityTokens(ctx context.Context, identityID uuid.UUID, status int) error
+	StartSession(ctx context.Context, identityID uuid.UUID, sessionID uuid.UUID, clientID string) (*tokenrepo.Session, error)
+	TerminateSession(ctx context.Context, identityID uuid.UUID, sessionID uuid.UUID) error
 	ValidateToken(ctx context.Context, tkn *jwt.Token) error
 }
 
@@ -170,17 +231,84 @@ type UserProfileService interface {
 
 type UserService interface {
 	NotifyIdentitiesBeforeDeactivation(ctx context.Context, now func() time.Time) ([]account.Identity, error)
+	ListIdentitiesToNotifyForDeactivation(ctx context.Context, now func() time.Time) ([]account.Identity, error)
 	ListIdentitiesToDeactivate(ctx context.Context, now func() time.Time) ([]account.Identity, error)
+	ListDeactivationCandidates(ctx context.Context, now func() time.Time, offset, limit int) ([]account.DeactivationCandidate, int, error)
 	DeactivateUser(ctx context.Context, username string) (*account.Identity, error)
 	BanUser(ctx context.Context, username string) (*account.Identity, error)
+	BanUserWithReason(ctx context.Context, username string, ban account.UserBan) (*account.Identity, error)
+	ListUserBans(ctx context.Context, username string) ([]account.UserBan, error)
+	StartScheduledBans(ctx context.Context, now func() time.Time) (int, error)
+	LiftExpiredBans(ctx context.Context, now func() time.Time) (int, error)
+	BanError(ctx context.Context, user account.User, msg string) error
 	UserInfo(ctx context.Context, identityID uuid.UUID) (*account.User, *account.Identity, error)
 	LoadContextIdentityAndUser(ctx context.Context) (*account.Identity, error)
 	LoadContextIdentityIfNotBanned(ctx context.Context) (*account.Identity, error)
 	ContextIdentityIfExists(ctx context.Context) (uuid.UUID, error)
 	IdentityByUsernameAndEmail(ctx context.Context, username, email string) (*account.Identity, error)
 	ResetBan(ctx context.Context, user account.User) error
+	LiftBansWithReason(ctx context.Context, user account.User, reason string) error
 	HardDeleteUser(ctx context.Context, identity account.Identity) error
 	RescheduleDeactivation(ctx context.Context, identityID uuid.UUID) error
+	ChangeUsername(ctx context.Context, identity *account.Identity, username string, enforceCooldown bool) error
+	RequestDeletion(ctx context.Context, identityID uuid.UUID) (*account.Identity, error)
+	ListIdentitiesToDelete(ctx context.Context, now func() time.Time) ([]account.Identity, error)
+	ListIdentitiesToPurge(ctx context.Context, now func() time.Time) ([]account.Identity, error)
+}
+
+// UserDataExportService generates the copies of the personal data of the users
+type UserDataExportService interface {
+	ExportForIdentity(ctx context.Context, identityID uuid.UUID) (*account.UserDataExport, error)
+	ExportForUsername(ctx context.Context, username string) (*account.UserDataExport, error)
+	GeneratePendingExports(ctx context.Context) (int, error)
+}
+
+// UserBulkOperationService applies operations such as bans to lists of users asynchronously
+type UserBulkOperationService interface {
+	Submit(ctx context.Context, operation string, parameters map[string]interface{}, usernames []string, filter *account.UserBulkOperationFilter) (*account.UserBulkOperation, error)
+	Load(ctx context.Context, id uuid.UUID) (*account.UserBulkOperation, []account.UserBulkOperationItem, error)
+	ProcessTask(ctx context.Context, task worker.QueueTask) error
+}
+
+// UserMergeService merges the duplicate accounts of a person, created when signing in with a different identity provider
+// or email address
+type UserMergeService interface {
+	Merge(ctx context.Context, sourceUsername, targetUsername string) (*account.Identity, error)
+}
+
+// AvatarService manages the avatars uploaded by the users, whose images are kept in the blob storage
+type AvatarService interface {
+	Upload(ctx context.Context, req *goa.RequestData, identityID uuid.UUID, data []byte) (*account.Identity, error)
+	Delete(ctx context.Context, identityID uuid.UUID) (*account.Identity, error)
+	Load(ctx context.Context, avatarID uuid.UUID, size int) (*blob.Object, error)
+	DeleteImages(ctx context.Context, avatarID uuid.UUID) error
+}
+
+// UserProfileAttributeService manages the admin-defined schema of the custom attributes of the user profiles, and
+// validates the values of the users against it
+type UserProfileAttributeService interface {
+	ListAttributes(ctx context.Context) ([]account.UserProfileAttribute, error)
+	SaveAttribute(ctx context.Context, attribute account.UserProfileAttribute) (*account.UserProfileAttribute, error)
+	DeleteAttribute(ctx context.Context, name string) error
+	ApplyProfileAttributes(ctx context.Context, user *account.User, values map[string]interface{}, visibility string) error
+	UpdateProfileAttributes(ctx context.Context, username string, values map[string]interface{}) (*account.Identity, error)
+	SearchFilter(ctx context.Context, filters []string) (map[string]interface{}, error)
+}
+
+// SCIMService provisions the users and groups managed by a corporate identity management system with SCIM
+type SCIMService interface {
+	ListUsers(ctx context.Context, filter string, offset int, limit int) ([]account.Identity, int, error)
+	LoadUser(ctx context.Context, id uuid.UUID) (*account.Identity, error)
+	CreateUser(ctx context.Context, user scim.User) (*account.Identity, error)
+	ReplaceUser(ctx context.Context, id uuid.UUID, user scim.User) (*account.Identity, error)
+	PatchUser(ctx context.Context, id uuid.UUID, operations []scim.PatchOperation) (*account.Identity, error)
+	DeleteUser(ctx context.Context, id uuid.UUID) error
+	ListGroups(ctx context.Context, filter string, offset int, limit int) ([]scim.Group, int, error)
+	LoadGroup(ctx context.Context, id uuid.UUID) (*scim.Group, error)
+	CreateGroup(ctx context.Context, group scim.Group) (*scim.Group, error)
+	ReplaceGroup(ctx context.Context, id uuid.UUID, group scim.Group) (*scim.Group, error)
+	PatchGroup(ctx context.Context, id uuid.UUID, operations []scim.PatchOperation) (*scim.Group, error)
+	DeleteGroup(ctx context.Context, id uuid.UUID) error
 }
 
 // CheService service interface for Che
@@ -193,23 +321,33 @@ type Services interface {
 	AuthenticationProviderService() AuthenticationProviderService
 	CheService() CheService
 	ClusterService() ClusterService
+	GroupService() GroupService
 	InvitationService() InvitationService
 	LinkService() LinkService
 	LogoutService() LogoutService
+	MFAService() MFAService
 	NotificationService() NotificationService
 	AdminConsoleService() AdminConsoleService
 	OrganizationService() OrganizationService
+	OutboxService() OutboxService
 	OSOSubscriptionService() OSOSubscriptionService
 	PermissionService() PermissionService
 	PrivilegeCacheService() PrivilegeCacheService
 	ResourceService() ResourceService
 	RoleManagementService() RoleManagementService
+	SCIMService() SCIMService
 	SpaceService() SpaceService
 	TeamService() TeamService
 	TenantService() TenantService
 	TokenService() TokenService
 	UserProfileService() UserProfileService
 	UserService() UserService
+	UserDataExportService() UserDataExportService
+	UserBulkOperationService() UserBulkOperationService
+	UserProfileAttributeService() UserProfileAttributeService
+	UserMergeService() UserMergeService
+	AvatarService() AvatarService
+	WebAuthnService() WebAuthnService
 }
 
 //----------------------------------------------------------------------------------------------------------------------
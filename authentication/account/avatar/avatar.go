@@ -0,0 +1,145 @@
+// Package avatar contains the validation and the resizing of the images uploaded by the users as their avatar.
+package avatar
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"net/http"
+
+	// the decoders of the supported formats
+	_ "image/gif"
+	_ "image/jpeg"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+
+	errs "github.com/pkg/errors"
+)
+
+const (
+	// DefaultSize the size of the avatars when no size is requested
+	DefaultSize = 128
+	// ContentType the content type of the resized avatars
+	ContentType = "image/png"
+)
+
+// Sizes the standard sizes of the avatars, in pixels. The avatars are square.
+var Sizes = []int{32, 64, 128, 256}
+
+// IsValidSize returns true if the given size is one of the standard sizes
+func IsValidSize(size int) bool {
+	for _, s := range Sizes {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
+
+// formats the content types of the images which can be uploaded, sniffed from their data, and the corresponding
+// names of the decoders
+var formats = map[string]string{
+	"image/png":  "png",
+	"image/jpeg": "jpeg",
+	"image/gif":  "gif",
+}
+
+// Process validates the uploaded image and returns it resized in all the standard sizes, encoded in PNG. The content
+// type of the image is sniffed from its data rather than trusted from the client, and its dimensions are checked
+// before it is decoded, so that a small file cannot expand into a huge image in memory. Images which are not square
+// are cropped around their center.
+func Process(data []byte, maxSize int64, maxDimension int) (map[int][]byte, error) {
+	if len(data) == 0 {
+		return nil, errors.NewBadParameterErrorFromString("avatar", "", "the image is empty")
+	}
+	if int64(len(data)) > maxSize {
+		return nil, errors.NewBadParameterErrorFromString("avatar", len(data), fmt.Sprintf("the image must not be larger than %d bytes", maxSize))
+	}
+	contentType := http.DetectContentType(data)
+	format, supported := formats[contentType]
+	if !supported {
+		return nil, errors.NewBadParameterError("avatar", contentType).Expected("a PNG, JPEG or GIF image")
+	}
+	config, decodedFormat, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || decodedFormat != format {
+		return nil, errors.NewBadParameterErrorFromString("avatar", contentType, "the image cannot be decoded")
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width > maxDimension || config.Height > maxDimension {
+		return nil, errors.NewBadParameterErrorFromString("avatar", fmt.Sprintf("%dx%d", config.Width, config.Height),
+			fmt.Sprintf("the width and the height of the image must not exceed %d pixels", maxDimension))
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.NewBadParameterErrorFromString("avatar", contentType, "the image cannot be decoded")
+	}
+
+	square := cropToSquare(img)
+	result := make(map[int][]byte, len(Sizes))
+	for _, size := range Sizes {
+		buf := &bytes.Buffer{}
+		err := png.Encode(buf, resize(square, size))
+		if err != nil {
+			return nil, errs.Wrapf(err, "unable to encode the avatar in %dx%d", size, size)
+		}
+		result[size] = buf.Bytes()
+	}
+	return result, nil
+}
+
+// cropToSquare returns the largest square around the center of the image, converted to RGBA with premultiplied alpha
+func cropToSquare(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	origin := image.Pt(bounds.Min.X+(bounds.Dx()-side)/2, bounds.Min.Y+(bounds.Dy()-side)/2)
+	square := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(square, square.Bounds(), img, origin, draw.Src)
+	return square
+}
+
+// resize scales the square image to the given size. Each pixel of the result is the average of the pixels of the
+// source which it covers, which gives a smooth result when the image is scaled down, and repeats the pixels when it is
+// scaled up.
+func resize(src *image.RGBA, size int) *image.RGBA {
+	side := src.Bounds().Dx()
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0, y1 := span(y, size, side)
+		for x := 0; x < size; x++ {
+			x0, x1 := span(x, size, side)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				offset := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += uint64(src.Pix[offset])
+					g += uint64(src.Pix[offset+1])
+					b += uint64(src.Pix[offset+2])
+					a += uint64(src.Pix[offset+3])
+					offset += 4
+					n++
+				}
+			}
+			offset := dst.PixOffset(x, y)
+			dst.Pix[offset] = uint8(r / n)
+			dst.Pix[offset+1] = uint8(g / n)
+			dst.Pix[offset+2] = uint8(b / n)
+			dst.Pix[offset+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// span returns the range of the source pixels covered by the pixel `i` of the result, which always contains at least
+// one pixel
+func span(i, size, side int) (int, int) {
+	start := i * side / size
+	end := (i + 1) * side / size
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
@@ -0,0 +1,110 @@
+package avatar_test
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/account/avatar"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/resource"
+	testsupport "github.com/fabric8-services/fabric8-auth/test"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	maxSize      = 1024 * 1024
+	maxDimension = 1024
+)
+
+func TestProcess(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+
+	t.Run("png", func(t *testing.T) {
+		// when
+		result, err := avatar.Process(testsupport.EncodePNG(t, testsupport.NewImage(300, 300)), maxSize, maxDimension)
+		// then
+		require.NoError(t, err)
+		require.Len(t, result, len(avatar.Sizes))
+		for _, size := range avatar.Sizes {
+			img, format, err := image.Decode(bytes.NewReader(result[size]))
+			require.NoError(t, err)
+			assert.Equal(t, "png", format)
+			assert.Equal(t, size, img.Bounds().Dx())
+			assert.Equal(t, size, img.Bounds().Dy())
+			// the colors are kept
+			r, _, b, _ := img.At(0, size/2).RGBA()
+			assert.Equal(t, uint32(0xffff), r)
+			assert.Equal(t, uint32(0), b)
+			r, _, b, _ = img.At(size-1, size/2).RGBA()
+			assert.Equal(t, uint32(0), r)
+			assert.Equal(t, uint32(0xffff), b)
+		}
+	})
+
+	t.Run("jpeg cropped", func(t *testing.T) {
+		// given a landscape image, whose center is cropped
+		buf := &bytes.Buffer{}
+		require.NoError(t, jpeg.Encode(buf, testsupport.NewImage(400, 100), nil))
+		// when
+		result, err := avatar.Process(buf.Bytes(), maxSize, maxDimension)
+		// then
+		require.NoError(t, err)
+		img, _, err := image.Decode(bytes.NewReader(result[avatar.DefaultSize]))
+		require.NoError(t, err)
+		assert.Equal(t, avatar.DefaultSize, img.Bounds().Dx())
+		assert.Equal(t, avatar.DefaultSize, img.Bounds().Dy())
+	})
+
+	t.Run("gif scaled up", func(t *testing.T) {
+		// given
+		buf := &bytes.Buffer{}
+		require.NoError(t, gif.Encode(buf, testsupport.NewImage(16, 16), nil))
+		// when
+		result, err := avatar.Process(buf.Bytes(), maxSize, maxDimension)
+		// then
+		require.NoError(t, err)
+		img, _, err := image.Decode(bytes.NewReader(result[256]))
+		require.NoError(t, err)
+		assert.Equal(t, 256, img.Bounds().Dx())
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		_, err := avatar.Process([]byte{}, maxSize, maxDimension)
+		require.IsType(t, errors.BadParameterError{}, err)
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		data := testsupport.EncodePNG(t, testsupport.NewImage(300, 300))
+		_, err := avatar.Process(data, int64(len(data)-1), maxDimension)
+		require.IsType(t, errors.BadParameterError{}, err)
+	})
+
+	t.Run("too many pixels", func(t *testing.T) {
+		_, err := avatar.Process(testsupport.EncodePNG(t, testsupport.NewImage(maxDimension+1, 10)), maxSize, maxDimension)
+		require.IsType(t, errors.BadParameterError{}, err)
+	})
+
+	t.Run("not an image", func(t *testing.T) {
+		_, err := avatar.Process([]byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"), maxSize, maxDimension)
+		require.IsType(t, errors.BadParameterError{}, err)
+	})
+
+	t.Run("truncated image", func(t *testing.T) {
+		data := testsupport.EncodePNG(t, testsupport.NewImage(300, 300))
+		_, err := avatar.Process(data[:len(data)/2], maxSize, maxDimension)
+		require.IsType(t, errors.BadParameterError{}, err)
+	})
+}
+
+func TestIsValidSize(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+	assert.True(t, avatar.IsValidSize(avatar.DefaultSize))
+	assert.False(t, avatar.IsValidSize(100))
+}
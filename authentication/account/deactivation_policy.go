@@ -0,0 +1,140 @@
+package account
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DeactivationPolicy a rule which selects users by their attributes, and defines when the accounts of the selected
+// users are deactivated after a period of inactivity, and when the users are reminded before the deactivation.
+// A user is selected when all the non-empty selectors of the policy match.
+type DeactivationPolicy struct {
+	Name string `json:"name"`
+	// selects the users having one of the given feature levels
+	FeatureLevels []string `json:"feature_levels,omitempty"`
+	// selects the users whose email address belongs to one of the given domains
+	EmailDomains []string `json:"email_domains,omitempty"`
+	// selects the users provisioned on one of the given clusters
+	Clusters []string `json:"clusters,omitempty"`
+	// selects the users who are members of one of the given organizations (by name)
+	Organizations []string `json:"organizations,omitempty"`
+	// selects the users who have one of the given roles (by name) on any resource
+	Roles []string `json:"roles,omitempty"`
+	// the selected users are never deactivated
+	Exempt bool `json:"exempt,omitempty"`
+	// the number of days of inactivity after which the accounts of the selected users are deactivated
+	InactivityPeriodDays int `json:"inactivity_period_days,omitempty"`
+	// the number of days before the deactivation at which the selected users are reminded, eg: [30, 7, 1]
+	ReminderDays []int `json:"reminder_days,omitempty"`
+}
+
+// DeactivationPolicySubject the attributes of a user which are evaluated by the deactivation policies
+type DeactivationPolicySubject struct {
+	FeatureLevel  string
+	Email         string
+	Cluster       string
+	Organizations []string
+	Roles         []string
+}
+
+// ParseDeactivationPolicies parses the given JSON array of policies, and validates them. An empty string means
+// that no policy is defined.
+func ParseDeactivationPolicies(value string) ([]DeactivationPolicy, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	var policies []DeactivationPolicy
+	err := json.Unmarshal([]byte(value), &policies)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid user deactivation policies")
+	}
+	for i, p := range policies {
+		if p.Name == "" {
+			return nil, errors.Errorf("invalid user deactivation policy at index %d: missing name", i)
+		}
+		if p.Exempt {
+			continue
+		}
+		if p.InactivityPeriodDays <= 0 {
+			return nil, errors.Errorf("invalid user deactivation policy '%s': the inactivity period must be positive", p.Name)
+		}
+		if len(p.ReminderDays) == 0 {
+			return nil, errors.Errorf("invalid user deactivation policy '%s': at least one reminder is required", p.Name)
+		}
+		// reminders are sent in chronological order, ie, from the furthest to the closest to the deactivation
+		sort.Sort(sort.Reverse(sort.IntSlice(p.ReminderDays)))
+		for j, days := range p.ReminderDays {
+			if days <= 0 || days >= p.InactivityPeriodDays {
+				return nil, errors.Errorf("invalid user deactivation policy '%s': the reminders must be sent during the inactivity period", p.Name)
+			}
+			if j > 0 && days == p.ReminderDays[j-1] {
+				return nil, errors.Errorf("invalid user deactivation policy '%s': duplicate reminder at %d days", p.Name, days)
+			}
+		}
+	}
+	return policies, nil
+}
+
+// Matches returns true if all the non-empty selectors of the policy match the given subject
+func (p DeactivationPolicy) Matches(subject DeactivationPolicySubject) bool {
+	if len(p.FeatureLevels) > 0 && !containsAny(p.FeatureLevels, subject.FeatureLevel) {
+		return false
+	}
+	if len(p.EmailDomains) > 0 {
+		domain := ""
+		if i := strings.LastIndex(subject.Email, "@"); i >= 0 {
+			domain = strings.ToLower(subject.Email[i+1:])
+		}
+		if !containsAny(p.EmailDomains, domain) {
+			return false
+		}
+	}
+	if len(p.Clusters) > 0 && !containsAny(p.Clusters, subject.Cluster) {
+		return false
+	}
+	if len(p.Organizations) > 0 && !containsAny(p.Organizations, subject.Organizations...) {
+		return false
+	}
+	if len(p.Roles) > 0 && !containsAny(p.Roles, subject.Roles...) {
+		return false
+	}
+	return true
+}
+
+// InactivityPeriod returns the duration of inactivity after which the accounts of the selected users are deactivated
+func (p DeactivationPolicy) InactivityPeriod() time.Duration {
+	return time.Duration(p.InactivityPeriodDays) * 24 * time.Hour
+}
+
+// Reminders returns the durations before the deactivation at which the selected users are reminded, the furthest first
+func (p DeactivationPolicy) Reminders() []time.Duration {
+	reminders := make([]time.Duration, len(p.ReminderDays))
+	for i, days := range p.ReminderDays {
+		reminders[i] = time.Duration(days) * 24 * time.Hour
+	}
+	return reminders
+}
+
+// String returns a short description of the policy, used in the logs and reports
+func (p DeactivationPolicy) String() string {
+	if p.Exempt {
+		return fmt.Sprintf("%s (exempt)", p.Name)
+	}
+	return fmt.Sprintf("%s (%d days, reminders at %v days)", p.Name, p.InactivityPeriodDays, p.ReminderDays)
+}
+
+func containsAny(values []string, candidates ...string) bool {
+	for _, v := range values {
+		for _, c := range candidates {
+			if strings.EqualFold(v, c) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package account_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/account"
+	"github.com/fabric8-services/fabric8-auth/resource"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDeactivationPolicies(t *testing.T) {
+	resource.Require(t, resource.UnitTest)
+
+	t.Run("empty", func(t *testing.T) {
+		policies, err := account.ParseDeactivationPolicies("")
+		require.NoError(t, err)
+		assert.Empty(t, policies)
+	})
+
+	t.Run("ok", func(t *testing.T) {
+		policies, err := account.ParseDeactivationPolicies(`[
+			{"name": "internal", "email_domains": ["redhat.com"], "exempt": true},
+			{"name": "beta", "feature_levels": ["beta"], "inactivity_period_days": 90, "reminder_days": [1, 30, 7]}
+		]`)
+		require.NoError(t, err)
+		require.Len(t, policies, 2)
+		assert.True(t, policies[0].Exempt)
+		assert.Equal(t, []int{30, 7, 1}, policies[1].ReminderDays)
+		assert.Equal(t, 90*24*time.Hour, policies[1].InactivityPeriod())
+		assert.Equal(t, []time.Duration{30 * 24 * time.Hour, 7 * 24 * time.Hour, 24 * time.Hour}, policies[1].Reminders())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for name, value := range map[string]string{
+			"malformed":          `[{"name": `,
+			"missing name":       `[{"inactivity_period_days": 90, "reminder_days": [7]}]`,
+			"missing period":     `[{"name": "p", "reminder_days": [7]}]`,
+			"missing reminders":  `[{"name": "p", "inactivity_period_days": 90}]`,
+			"reminder too early": `[{"name": "p", "inactivity_period_days": 90, "reminder_days": [90]}]`,
+			"duplicate reminder": `[{"name": "p", "inactivity_period_days": 90, "reminder_days": [7, 7]}]`,
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := account.ParseDeactivationPolicies(value)
+				assert.Error(t, err)
+			})
+		}
+	})
+}
+
+func TestDeactivationPolicyMatches(t *testing.T) {
+	resource.Require(t, resource.UnitTest)
+	subject := account.DeactivationPolicySubject{
+		FeatureLevel:  "beta",
+		Email:         "jdoe@Example.com",
+		Cluster:       "starter-us-east-2",
+		Organizations: []string{"acme"},
+		Roles:         []string{"admin"},
+	}
+
+	t.Run("no selector", func(t *testing.T) {
+		assert.True(t, account.DeactivationPolicy{Name: "all"}.Matches(subject))
+	})
+
+	t.Run("all selectors match", func(t *testing.T) {
+		policy := account.DeactivationPolicy{
+			Name:          "p",
+			FeatureLevels: []string{"released", "beta"},
+			EmailDomains:  []string{"example.com"},
+			Clusters:      []string{"starter-us-east-2"},
+			Organizations: []string{"ACME"},
+			Roles:         []string{"admin"},
+		}
+		assert.True(t, policy.Matches(subject))
+	})
+
+	t.Run("one selector does not match", func(t *testing.T) {
+		policy := account.DeactivationPolicy{
+			Name:          "p",
+			FeatureLevels: []string{"beta"},
+			Roles:         []string{"contributor"},
+		}
+		assert.False(t, policy.Matches(subject))
+	})
+}
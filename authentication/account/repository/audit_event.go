@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// AuditEvent an audit event of an identity. Unlike the audit log outbox events, which are deleted once forwarded
+// to the admin console service, the audit events are kept until the account is purged.
+type AuditEvent struct {
+	gormsupport.LifecycleHardDelete
+	// AuditEventID the ID of the event. This is the primary key value.
+	AuditEventID uuid.UUID `sql:"type:uuid" gorm:"primary_key;column:audit_event_id"`
+	// the identity concerned by the event
+	IdentityID uuid.UUID `sql:"type:uuid"`
+	// the type of audit log
+	EventType string
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m AuditEvent) TableName() string {
+	return "audit_event"
+}
+
+// GormAuditEventRepository is the implementation of the storage interface for AuditEvent.
+type GormAuditEventRepository struct {
+	db *gorm.DB
+}
+
+// NewAuditEventRepository creates a new storage type.
+func NewAuditEventRepository(db *gorm.DB) AuditEventRepository {
+	return &GormAuditEventRepository{db: db}
+}
+
+// AuditEventRepository represents the storage interface.
+type AuditEventRepository interface {
+	Create(ctx context.Context, event *AuditEvent) error
+	ListForIdentity(ctx context.Context, identityID uuid.UUID) ([]AuditEvent, error)
+}
+
+// Create creates a new record.
+func (m *GormAuditEventRepository) Create(ctx context.Context, event *AuditEvent) error {
+	defer goa.MeasureSince([]string{"goa", "db", "audit_event", "create"}, time.Now())
+	if event.AuditEventID == uuid.Nil {
+		event.AuditEventID = uuid.NewV4()
+	}
+	err := m.db.Create(event).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": event.IdentityID,
+			"event_type":  event.EventType,
+			"err":         err,
+		}, "unable to record the audit event")
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"audit_event_id": event.AuditEventID,
+		"identity_id":    event.IdentityID,
+		"event_type":     event.EventType,
+	}, "audit event recorded!")
+	return nil
+}
+
+// ListForIdentity returns the audit events of the given identity, the oldest ones first
+func (m *GormAuditEventRepository) ListForIdentity(ctx context.Context, identityID uuid.UUID) ([]AuditEvent, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "audit_event", "list_for_identity"}, time.Now())
+	var events []AuditEvent
+	err := m.db.Where("identity_id = ?", identityID).Order("created_at").Find(&events).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": identityID,
+			"err":         err,
+		}, "unable to list the audit events")
+		return nil, errs.WithStack(err)
+	}
+	return events, nil
+}
@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"time"
+)
+
+const (
+	// DeactivationActionNotify the user will be notified that her account is about to be deactivated
+	DeactivationActionNotify = "notify"
+	// DeactivationActionDeactivate the account of the user will be deactivated
+	DeactivationActionDeactivate = "deactivate"
+)
+
+// DeactivationCandidate an identity which would be picked by the user deactivation notification worker or by the user
+// deactivation worker in their next cycle
+type DeactivationCandidate struct {
+	Identity Identity
+	// the action which would be taken: notify or deactivate
+	Action string
+	// a human-readable explanation of why the identity was picked
+	Reason string
+	// the time at which the account would be deactivated
+	ScheduledDeactivation time.Time
+}
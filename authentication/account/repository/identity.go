@@ -4,7 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
-	"strconv"
+	"encoding/json"
+	"fmt"
+	"regexp"
 	"strings"
 	"time"
 
@@ -88,6 +90,10 @@ type Identity struct {
 	DeactivationNotification *time.Time `gorm:"column:deactivation_notification"`
 	// Time of scheduled deactivation
 	DeactivationScheduled *time.Time `gorm:"column:deactivation_scheduled"`
+	// Number of reminders sent to the user before the deactivation of her account
+	DeactivationReminders int `gorm:"column:deactivation_reminders"`
+	// Time at which the user requested the deletion of her account, if any
+	DeletionRequested *time.Time `gorm:"column:deletion_requested"`
 }
 
 // TableName overrides the table name settings in Gorm to force a specific table name
@@ -133,15 +139,20 @@ type IdentityRepository interface {
 	Delete(ctx context.Context, id uuid.UUID, funcs ...func(*gorm.DB) *gorm.DB) error
 	DeleteForResource(ctx context.Context, resourceID string) error
 	Query(funcs ...func(*gorm.DB) *gorm.DB) ([]Identity, error)
+	Count(ctx context.Context, funcs ...func(*gorm.DB) *gorm.DB) (int, error)
 	List(ctx context.Context) ([]Identity, error)
-	ListIdentitiesToNotifyForDeactivation(ctx context.Context, lastActivity time.Time, whitelist []string, limit int) ([]Identity, error)
-	ListIdentitiesToDeactivate(ctx context.Context, lastActivity, notification time.Time, whitelist []string, limit int) ([]Identity, error)
+	ListIdentitiesToNotifyForDeactivation(ctx context.Context, lastActivity time.Time, whitelist []string, offset, limit int) ([]Identity, error)
+	ListIdentitiesToDeactivate(ctx context.Context, lastActivity, notification time.Time, whitelist []string, offset, limit int) ([]Identity, error)
+	ListInactiveIdentities(ctx context.Context, lastActivity time.Time, after *Identity, limit int, funcs ...func(*gorm.DB) *gorm.DB) ([]Identity, error)
+	ListIdentitiesToDelete(ctx context.Context, now time.Time, limit int) ([]Identity, error)
+	ListIdentitiesToPurge(ctx context.Context, deletedBefore time.Time, limit int) ([]Identity, error)
 	IsValid(context.Context, uuid.UUID) bool
-	Search(ctx context.Context, q string, start int, limit int) ([]Identity, int, error)
+	Search(ctx context.Context, q string, filter IdentitySearchFilter, start int, limit int) ([]Identity, int, error)
 	FindIdentityMemberships(ctx context.Context, identityID uuid.UUID, resourceType *string) ([]authorization.IdentityAssociation, error)
 	FindIdentitiesByResourceTypeWithParentResource(ctx context.Context, resourceTypeID uuid.UUID, parentResourceID string) ([]Identity, error)
 	AddMember(ctx context.Context, identityID uuid.UUID, memberID uuid.UUID) error
 	RemoveMember(ctx context.Context, memberOf uuid.UUID, memberID uuid.UUID) error
+	TransferMemberships(ctx context.Context, fromMemberID uuid.UUID, toMemberID uuid.UUID) error
 	FlagPrivilegeCacheStaleForMembershipChange(ctx context.Context, memberID uuid.UUID, memberOf uuid.UUID) error
 	TouchLastActive(ctx context.Context, identityID uuid.UUID) error
 	BumpDeactivationSchedule(ctx context.Context, identityID uuid.UUID, scheduledTime time.Time) error
@@ -314,6 +325,17 @@ func (m *GormIdentityRepository) Query(funcs ...func(*gorm.DB) *gorm.DB) ([]Iden
 	return identities, nil
 }
 
+// Count returns the number of identities which match the given criteria
+func (m *GormIdentityRepository) Count(ctx context.Context, funcs ...func(*gorm.DB) *gorm.DB) (int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "identity", "count"}, time.Now())
+	var count int
+	err := m.db.Model(&Identity{}).Scopes(funcs...).Count(&count).Error
+	if err != nil {
+		return 0, errs.WithStack(err)
+	}
+	return count, nil
+}
+
 // First returns the first Identity element that matches the given criteria
 func (m *GormIdentityRepository) First(funcs ...func(*gorm.DB) *gorm.DB) (*Identity, error) {
 	defer goa.MeasureSince([]string{"goa", "db", "identity", "first"}, time.Now())
@@ -343,10 +365,30 @@ func IdentityFilterByUserID(userID uuid.UUID) func(db *gorm.DB) *gorm.DB {
 	}
 }
 
-// IdentityFilterByUsername is a gorm filter by 'username'
+// IdentityFilterByUsername is a gorm filter by 'username'. A previous username of an identity also matches it, so that
+// the URLs based on the username keep working after it changed, unless another identity has taken the username since.
+// When several identities used the username, the last one matches. Since a previous username can eventually be taken by
+// another user, this filter must not be used to resolve the identity of a user who logs in.
 func IdentityFilterByUsername(username string) func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
-		return db.Where("username = ?", username).Limit(1)
+		return db.Where(`username = ? OR (
+			id = (SELECT identity_id FROM identity_username_history WHERE username = ? ORDER BY created_at DESC LIMIT 1)
+			AND NOT EXISTS (SELECT 1 FROM identities i WHERE i.username = ? AND i.deleted_at IS NULL))`,
+			username, username, username)
+	}
+}
+
+// IdentityFilterByCurrentUsername is a gorm filter by the current 'username' only, ignoring the previous usernames
+func IdentityFilterByCurrentUsername(username string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("username = ?", username)
+	}
+}
+
+// IdentityFilterByCurrentUsernames is a gorm filter by any of the given current 'usernames', ignoring the previous usernames
+func IdentityFilterByCurrentUsernames(usernames []string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("username IN (?)", usernames)
 	}
 }
 
@@ -364,6 +406,17 @@ func IdentityFilterByID(identityID uuid.UUID) func(db *gorm.DB) *gorm.DB {
 	}
 }
 
+// IdentityFilterByMemberOf is a gorm filter for the members of the given identity. If transitive is true, the members
+// of the nested groups, teams and organizations are included.
+func IdentityFilterByMemberOf(memberOf uuid.UUID, transitive bool) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if transitive {
+			return db.Where("id IN (SELECT member_id FROM membership_closure WHERE member_of = ?)", memberOf)
+		}
+		return db.Where("id IN (SELECT member_id FROM membership WHERE member_of = ?)", memberOf)
+	}
+}
+
 // IdentityWithUser is a gorm filter for preloading the User relationship.
 func IdentityWithUser() func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
@@ -378,6 +431,39 @@ func IdentityFilterByProviderType(providerType string) func(db *gorm.DB) *gorm.D
 	}
 }
 
+// IdentityFilterByReminderDue is a gorm filter for the identities which may be due for a deactivation reminder: either
+// they were not reminded yet, or they received fewer than the given number of reminders and their deactivation is
+// scheduled before the given time. The reminders sent before they were counted count as one.
+func IdentityFilterByReminderDue(maxReminders int, scheduledBefore time.Time) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(`identities.deactivation_notification IS NULL OR (GREATEST(identities.deactivation_reminders, 1) < ?
+			AND identities.deactivation_scheduled <= ?)`, maxReminders, scheduledBefore)
+	}
+}
+
+// IdentityFilterByDeactivationDue is a gorm filter for the identities which may be due for deactivation: they received
+// at least the given number of reminders, and their deactivation is scheduled before the given time
+func IdentityFilterByDeactivationDue(minReminders int, scheduledBefore time.Time) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(`identities.deactivation_notification IS NOT NULL AND GREATEST(identities.deactivation_reminders, 1) >= ?
+			AND identities.deactivation_scheduled < ?`, minReminders, scheduledBefore)
+	}
+}
+
+// IdentityFilterByUserCluster is a gorm filter by the cluster of the user
+func IdentityFilterByUserCluster(cluster string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("user_id IN (SELECT id FROM users WHERE cluster = ? AND deleted_at IS NULL)", cluster)
+	}
+}
+
+// IdentityFilterByUserFeatureLevel is a gorm filter by the feature level of the user
+func IdentityFilterByUserFeatureLevel(featureLevel string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("user_id IN (SELECT id FROM users WHERE feature_level = ? AND deleted_at IS NULL)", featureLevel)
+	}
+}
+
 // List return all user identities
 func (m *GormIdentityRepository) List(ctx context.Context) ([]Identity, error) {
 	defer goa.MeasureSince([]string{"goa", "db", "identity", "list"}, time.Now())
@@ -396,21 +482,15 @@ func (m *GormIdentityRepository) List(ctx context.Context) ([]Identity, error) {
 }
 
 // ListIdentitiesToNotifyForDeactivation return identities whose last activity is older than the given one. The result size is limited to the given
-// number of identities (ordered by last activity)
+// number of identities (ordered by last activity), from the given start position
 // if limit is a negative value (eg: '-1'), it is ignored
-func (m *GormIdentityRepository) ListIdentitiesToNotifyForDeactivation(ctx context.Context, lastActivity time.Time, whitelist []string, limit int) ([]Identity, error) {
+func (m *GormIdentityRepository) ListIdentitiesToNotifyForDeactivation(ctx context.Context, lastActivity time.Time, whitelist []string, offset, limit int) ([]Identity, error) {
 	defer goa.MeasureSince([]string{"goa", "db", "user", "listIdentitiesToNotifyForDeactivation"}, time.Now())
 	var identities []Identity
 	// sort identities by most inactive and then by date of creation to make sure we always get the same sublist of identities between
 	// queries to notify before deactivation and queries to deactivate for real.
-	query := m.db.Model(&Identity{}).Preload("User").
-		Where(`last_active < ? AND deactivation_notification IS NULL AND provider_type = ?`, lastActivity, DefaultIDP).
-		Joins("left join users on identities.user_id = users.id").Where("users.banned is false")
-	// check for whitelist if any
-	if len(whitelist) > 0 {
-		query = query.Not("username", whitelist)
-	}
-	err := query.Order("last_active, created_at").Limit(limit).Find(&identities).Error
+	err := m.db.Model(&Identity{}).Preload("User").Scopes(IdentityFilterToNotifyForDeactivation(lastActivity, whitelist)).
+		Order("last_active, created_at").Offset(offset).Limit(limit).Find(&identities).Error
 	if err != nil && err != gorm.ErrRecordNotFound {
 		return nil, errs.WithStack(err)
 	}
@@ -421,23 +501,32 @@ func (m *GormIdentityRepository) ListIdentitiesToNotifyForDeactivation(ctx conte
 	return identities, nil
 }
 
+// IdentityFilterToNotifyForDeactivation is a gorm filter for the identities whose last activity is older than the given
+// one, who were not notified before their deactivation yet, whose deletion was not requested, who were not banned and
+// whose username is not in the given whitelist
+func IdentityFilterToNotifyForDeactivation(lastActivity time.Time, whitelist []string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Where(`last_active < ? AND deactivation_notification IS NULL AND deletion_requested IS NULL AND provider_type = ?`, lastActivity, DefaultIDP).
+			Joins("left join users on identities.user_id = users.id").Where("users.banned is false")
+		// check for whitelist if any
+		if len(whitelist) > 0 {
+			db = db.Not("username", whitelist)
+		}
+		return db
+	}
+}
+
 // ListIdentitiesToDeactivate return identities whose last activity is older than the given one,
 // and for whom there is a `deactivation_notification` value and who were not previously banned.
-// The result size is limited to the given number of identities (ordered by last activity)
+// The result size is limited to the given number of identities (ordered by last activity), from the given start position
 // if limit is a negative value (eg: '-1'), it is ignored
-func (m *GormIdentityRepository) ListIdentitiesToDeactivate(ctx context.Context, lastActivity, notification time.Time, whitelist []string, limit int) ([]Identity, error) {
+func (m *GormIdentityRepository) ListIdentitiesToDeactivate(ctx context.Context, lastActivity, notification time.Time, whitelist []string, offset, limit int) ([]Identity, error) {
 	defer goa.MeasureSince([]string{"goa", "db", "user", "listIdentitiesToDeactivate"}, time.Now())
 	var identities []Identity
 	// sort identities by most inactive and then by date of creation to make sure we always get the same sublist of identities between
 	// queries to notify before deactivation and queries to deactivate for real.
-	query := m.db.Model(&Identity{}).
-		Where("last_active < ? and deactivation_notification < ? and deactivation_scheduled < ? and provider_type = ?", lastActivity, notification, time.Now(), DefaultIDP).
-		Joins("left join users on identities.user_id = users.id").Where("users.banned is false")
-	// check for whitelist if any
-	if len(whitelist) > 0 {
-		query = query.Not("username", whitelist)
-	}
-	err := query.Order("deactivation_scheduled, last_active, created_at").Limit(limit).Find(&identities).Error
+	err := m.db.Model(&Identity{}).Scopes(IdentityFilterToDeactivate(lastActivity, notification, whitelist)).
+		Order("deactivation_scheduled, last_active, created_at").Offset(offset).Limit(limit).Find(&identities).Error
 	if err != nil && err != gorm.ErrRecordNotFound {
 		return nil, errs.WithStack(err)
 	}
@@ -450,6 +539,76 @@ func (m *GormIdentityRepository) ListIdentitiesToDeactivate(ctx context.Context,
 	return identities, nil
 }
 
+// IdentityFilterToDeactivate is a gorm filter for the identities whose last activity is older than the given one, who
+// were notified before the given time, whose deactivation is due, who were not banned and whose username is not in
+// the given whitelist
+func IdentityFilterToDeactivate(lastActivity, notification time.Time, whitelist []string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Where("last_active < ? and deactivation_notification < ? and deactivation_scheduled < ? and provider_type = ?", lastActivity, notification, time.Now(), DefaultIDP).
+			Joins("left join users on identities.user_id = users.id").Where("users.banned is false")
+		// check for whitelist if any
+		if len(whitelist) > 0 {
+			db = db.Not("username", whitelist)
+		}
+		return db
+	}
+}
+
+// ListInactiveIdentities returns the identities (along with their user) whose last activity is older than the given one,
+// who were not banned, whose deletion was not requested and which match the given filters. The identities are ordered by
+// last activity, then by date of creation and by ID, so that the results can be paginated by passing the last identity
+// of the previous page.
+func (m *GormIdentityRepository) ListInactiveIdentities(ctx context.Context, lastActivity time.Time, after *Identity, limit int, funcs ...func(*gorm.DB) *gorm.DB) ([]Identity, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user", "listInactiveIdentities"}, time.Now())
+	var identities []Identity
+	query := m.db.Model(&Identity{}).Preload("User").Scopes(funcs...).
+		Where("identities.last_active < ? AND identities.deletion_requested IS NULL AND identities.provider_type = ?", lastActivity, DefaultIDP).
+		Joins("left join users on identities.user_id = users.id").Where("users.banned is false")
+	if after != nil {
+		query = query.Where("(identities.last_active, identities.created_at, identities.id) > (?, ?, ?)", after.LastActive, after.CreatedAt, after.ID)
+	}
+	err := query.Order("identities.last_active, identities.created_at, identities.id").Limit(limit).Find(&identities).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return identities, nil
+}
+
+// ListIdentitiesToDelete returns the identities whose user requested the deletion of her account, and whose
+// deletion is scheduled before the given time. The result size is limited to the given number of identities,
+// the earliest scheduled deletions first.
+func (m *GormIdentityRepository) ListIdentitiesToDelete(ctx context.Context, now time.Time, limit int) ([]Identity, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user", "listIdentitiesToDelete"}, time.Now())
+	var identities []Identity
+	err := m.db.Model(&Identity{}).
+		Where("deletion_requested IS NOT NULL AND deactivation_scheduled < ? AND provider_type = ?", now, DefaultIDP).
+		Order("deactivation_scheduled, created_at").Limit(limit).Find(&identities).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identities_to_delete": len(identities),
+	}, "listing identities to delete completed")
+	return identities, nil
+}
+
+// ListIdentitiesToPurge returns the identities whose user requested the deletion of her account, and which were
+// deactivated (ie, soft-deleted) before the given time. The result size is limited to the given number of identities.
+func (m *GormIdentityRepository) ListIdentitiesToPurge(ctx context.Context, deletedBefore time.Time, limit int) ([]Identity, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user", "listIdentitiesToPurge"}, time.Now())
+	var identities []Identity
+	err := m.db.Unscoped().Model(&Identity{}).
+		Where("deletion_requested IS NOT NULL AND deleted_at < ?", deletedBefore).
+		Order("deleted_at").Limit(limit).Find(&identities).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identities_to_purge": len(identities),
+	}, "listing identities to purge completed")
+	return identities, nil
+}
+
 // IsValid returns true if the identity exists
 func (m *GormIdentityRepository) IsValid(ctx context.Context, id uuid.UUID) bool {
 	_, err := m.Load(ctx, id)
@@ -459,89 +618,149 @@ func (m *GormIdentityRepository) IsValid(ctx context.Context, id uuid.UUID) bool
 	return true
 }
 
-// Search searches for Identities where FullName like %q% or users.email like %q% (but ignores private emails)
-// or users.username like %q%
-func (m *GormIdentityRepository) Search(ctx context.Context, q string, start int, limit int) ([]Identity, int, error) {
-	paramVal := strings.ToLower(q) + "%"
-	db := m.db
-
-	queryStr := `SELECT count(*) OVER () as cnt2, identity_id, username, users.* FROM (SELECT 
-  identities.id AS identity_id,
-  identities.username,  
-  users.*
-FROM 
-  identities, users
-WHERE 
-  identities.user_id = users.id 
-  AND identities.username LIKE ?
-  AND identities.deleted_at IS NULL
-  AND users.banned IS false
-  AND users.deleted_at IS NULL
-UNION SELECT
-  identities.id AS identity_id,
-  identities.username,
-  users.*
-FROM
-  identities, users
-WHERE  
-  identities.user_id = users.id 
-  AND identities.deleted_at IS NULL
-  AND users.deleted_at IS NULL
-  AND users.banned IS false 
-  AND (LOWER(users.full_name) LIKE ?
-  OR (LOWER(users.email) LIKE ? AND users.email_private is false))) users LIMIT ?`
-
-	rows, err := db.Raw(queryStr, paramVal, paramVal, paramVal, strconv.Itoa(limit)).Rows()
+// IdentitySearchFilter restricts the results of the search of users
+type IdentitySearchFilter struct {
+	// the company of the users, case insensitive
+	Company *string
+	// the ID of the organization in which the users are members or have a role
+	OrganizationID *uuid.UUID
+	// the URL of the cluster of the users
+	Cluster *string
+	// the values which the custom profile attributes of the users must have
+	ProfileAttributes map[string]interface{}
+}
 
-	if err != nil {
-		return nil, 0, err
+// searchTermPattern matches the words of a search query which are long enough to be matched approximately
+var searchTermPattern = regexp.MustCompile(`[[:alnum:]]{2,}`)
+
+// searchDocument the words of the full name and company of a user, for the full-text search
+const searchDocument = "to_tsvector('simple', coalesce(users.full_name, '') || ' ' || coalesce(users.company, ''))"
+
+// Search searches for the identities of the users whose username, full name, company or public email contain the
+// given query, or approximately match it (to tolerate typos), or whose full name and company contain all the words of
+// the query in any order. Banned users are never returned. The results are ordered by relevance, from the given start
+// position, and the total number of matching identities is returned along with them.
+func (m *GormIdentityRepository) Search(ctx context.Context, q string, filter IdentitySearchFilter, start int, limit int) ([]Identity, int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "identity", "search"}, time.Now())
+	q = strings.ToLower(strings.TrimSpace(q))
+	contains := "%" + gormsupport.EscapeLikePattern(q) + "%"
+	terms := strings.Join(searchTermPattern.FindAllString(q, -1), " ")
+
+	matches := []string{
+		"lower(identities.username) LIKE ?",
+		"lower(users.full_name) LIKE ?",
+		"lower(users.company) LIKE ?",
+		"(users.email_private IS false AND lower(users.email) LIKE ?)",
 	}
-	defer rows.Close()
-
-	result := []Identity{}
-	value := Identity{}
-	columns, err := rows.Columns()
+	args := []interface{}{contains, contains, contains, contains}
+	// an exact match on the username comes first, then a prefix match
+	ranks := []string{
+		"CASE WHEN lower(identities.username) = ? THEN 2 ELSE 0 END",
+		"CASE WHEN lower(identities.username) LIKE ? THEN 1 ELSE 0 END",
+	}
+	rankArgs := []interface{}{q, gormsupport.EscapeLikePattern(q) + "%"}
+	if terms != "" {
+		matches = append(matches,
+			"? <% lower(identities.username)",
+			"? <% lower(users.full_name)",
+			"? <% lower(users.company)",
+			searchDocument+" @@ plainto_tsquery('simple', ?)")
+		args = append(args, terms, terms, terms, terms)
+		ranks = append(ranks,
+			"word_similarity(?, lower(identities.username))",
+			"word_similarity(?, lower(users.full_name))",
+			"0.5 * word_similarity(?, lower(users.company))",
+			"ts_rank("+searchDocument+", plainto_tsquery('simple', ?))")
+		rankArgs = append(rankArgs, terms, terms, terms, terms)
+	}
+	conditions := []string{
+		"identities.deleted_at IS NULL",
+		"users.deleted_at IS NULL",
+		"users.banned IS false",
+		"(" + strings.Join(matches, " OR ") + ")",
+	}
+	if filter.Company != nil {
+		conditions = append(conditions, "lower(users.company) = ?")
+		args = append(args, strings.ToLower(*filter.Company))
+	}
+	if filter.Cluster != nil {
+		conditions = append(conditions, "users.cluster = ?")
+		args = append(args, *filter.Cluster)
+	}
+	if len(filter.ProfileAttributes) > 0 {
+		profile, err := json.Marshal(filter.ProfileAttributes)
+		if err != nil {
+			return nil, 0, errs.WithStack(err)
+		}
+		conditions = append(conditions, "users.profile_attributes @> ?::jsonb")
+		args = append(args, string(profile))
+	}
+	if filter.OrganizationID != nil {
+		conditions = append(conditions, `(identities.id IN (SELECT member_id FROM membership WHERE member_of = ?)
+			OR identities.id IN (SELECT identity_id FROM identity_role WHERE deleted_at IS NULL
+				AND resource_id = (SELECT identity_resource_id FROM identities WHERE id = ?)))`)
+		args = append(args, *filter.OrganizationID, *filter.OrganizationID)
+	}
+	args = append(args, rankArgs...)
+	args = append(args, limit, start)
+
+	queryStr := fmt.Sprintf(`SELECT identities.id, count(*) OVER () AS total
+		FROM identities JOIN users ON identities.user_id = users.id
+		WHERE %s
+		ORDER BY GREATEST(%s) DESC, identities.username
+		LIMIT ? OFFSET ?`, strings.Join(conditions, " AND "), strings.Join(ranks, ", "))
+	rows, err := m.db.Raw(queryStr, args...).Rows()
 	if err != nil {
-		return nil, 0, errors.NewInternalError(err)
+		return nil, 0, errs.WithStack(err)
 	}
-
-	// need to set up a result for Scan() in order to extract total count.
+	defer rows.Close()
 	var count int
-	var identityID string
-	var identityUsername string
-	var ignore interface{}
-	columnValues := make([]interface{}, len(columns))
-
-	for index := range columnValues {
-		columnValues[index] = &ignore
-	}
-	columnValues[0] = &count
-	// FIXME When our User Profile endpoints start giving "user" response
-	// instead of "identity" response, the identity.ID would be less relevant.
-
+	ids := []uuid.UUID{}
 	for rows.Next() {
-		columnValues[1] = &identityID
-		columnValues[2] = &identityUsername
-		db.ScanRows(rows, &value.User)
-
-		if err = rows.Scan(columnValues...); err != nil {
+		var id uuid.UUID
+		if err := rows.Scan(&id, &count); err != nil {
 			return nil, 0, errors.NewInternalError(err)
 		}
-
-		value.ID, err = uuid.FromString(identityID)
-		if err != nil {
-			return nil, 0, errors.NewInternalError(err)
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, errs.WithStack(err)
+	}
+	if len(ids) == 0 {
+		if start > 0 {
+			// the page is beyond the last result, but the total number of results is still needed for the paging links
+			countStr := fmt.Sprintf(`SELECT count(*) FROM identities JOIN users ON identities.user_id = users.id WHERE %s`,
+				strings.Join(conditions, " AND "))
+			err := m.db.Raw(countStr, args[:len(args)-len(rankArgs)-2]...).Row().Scan(&count)
+			if err != nil {
+				return nil, 0, errs.WithStack(err)
+			}
 		}
-
-		value.Username = identityUsername
-
-		result = append(result, value)
+		return []Identity{}, count, nil
 	}
 
+	// load the identities with their user, in the order of relevance
+	identities, err := m.Query(IdentityWithUser(), func(db *gorm.DB) *gorm.DB {
+		return db.Where("id IN (?)", ids)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	byID := make(map[uuid.UUID]Identity, len(identities))
+	for _, identity := range identities {
+		byID[identity.ID] = identity
+	}
+	result := make([]Identity, 0, len(ids))
+	for _, id := range ids {
+		if identity, found := byID[id]; found {
+			result = append(result, identity)
+		}
+	}
 	return result, count, nil
 }
 
-// FindIdentityMemberships returns an array of Identity objects with the (optionally) specified resource type in which the specified Identity is a member
+// FindIdentityMemberships returns an array of Identity objects with the (optionally) specified resource type in which the specified Identity is a member,
+// either directly or through nested memberships
 func (m *GormIdentityRepository) FindIdentityMemberships(ctx context.Context, identityID uuid.UUID, resourceType *string) ([]authorization.IdentityAssociation, error) {
 	defer goa.MeasureSince([]string{"goa", "db", "identity", "FindIdentityMemberships"}, time.Now())
 	associations := []authorization.IdentityAssociation{}
@@ -557,10 +776,7 @@ func (m *GormIdentityRepository) FindIdentityMemberships(ctx context.Context, id
 			Joins("JOIN resource_type rt ON r.resource_type_id = rt.resource_type_id AND rt.name = ?", resourceType)
 	}
 
-	err := q.Where(`identities.id IN (WITH RECURSIVE m AS (
-			SELECT member_of FROM	membership WHERE member_id = ? 
-      UNION SELECT p.member_of	FROM membership p INNER JOIN m ON m.member_of = p.member_id)
-		  SELECT member_of FROM m)`, identityID).
+	err := q.Where(`identities.id IN (SELECT member_of FROM membership_closure WHERE member_id = ?)`, identityID).
 		Find(&identities).Error
 
 	if err != nil {
@@ -603,11 +819,34 @@ func (m *GormIdentityRepository) FindIdentitiesByResourceTypeWithParentResource(
 	return identities, nil
 }
 
+// membershipLockID the key of the transaction-level advisory lock which serializes the changes of the memberships, so
+// that two concurrent changes can neither create a cycle which none of them would create alone, nor conflict when
+// refreshing the membership closure
+const membershipLockID = 7045
+
+// lockMemberships acquires the advisory lock on the memberships, which is held until the end of the current
+// transaction. The memberships must therefore be changed in a transaction.
+func (m *GormIdentityRepository) lockMemberships(ctx context.Context) error {
+	err := m.db.Exec("SELECT pg_advisory_xact_lock(?)", membershipLockID).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to acquire the lock on the memberships")
+		return errs.WithStack(err)
+	}
+	return nil
+}
+
 func (m *GormIdentityRepository) AddMember(ctx context.Context, identityID uuid.UUID, memberID uuid.UUID) error {
 	defer goa.MeasureSince([]string{"goa", "db", "identity", "AddMember"}, time.Now())
 
+	err := m.lockMemberships(ctx)
+	if err != nil {
+		return err
+	}
+
 	var identity Identity
-	err := m.db.Table(m.TableName()).Preload("IdentityResource").Preload("IdentityResource.ResourceType").Where("id = ?", identityID).Find(&identity).Error
+	err = m.db.Table(m.TableName()).Preload("IdentityResource").Preload("IdentityResource.ResourceType").Where("id = ?", identityID).Find(&identity).Error
 	if err == gorm.ErrRecordNotFound {
 		return errs.WithStack(errors.NewNotFoundError("identity", identityID.String()))
 	}
@@ -626,6 +865,16 @@ func (m *GormIdentityRepository) AddMember(ctx context.Context, identityID uuid.
 		return errs.WithStack(errors.NewNotFoundError("identity", memberID.String()))
 	}
 
+	// the new membership must not make the identity a member of itself, directly or through nested memberships
+	var cycles int
+	err = m.db.Table("membership_closure").Where("member_id = ? AND member_of = ?", identityID, memberID).Count(&cycles).Error
+	if err != nil {
+		return errs.WithStack(err)
+	}
+	if identityID == memberID || cycles > 0 {
+		return errs.WithStack(errors.NewBadParameterErrorFromString("memberID", memberID.String(), "Specified member would create a membership cycle"))
+	}
+
 	membership := &Membership{
 		MemberOf: identityID,
 		MemberID: memberID,
@@ -641,7 +890,7 @@ func (m *GormIdentityRepository) AddMember(ctx context.Context, identityID uuid.
 		return errs.WithStack(err)
 	}
 
-	err = m.FlagPrivilegeCacheStaleForMembershipChange(ctx, identityID, memberID)
+	err = m.FlagPrivilegeCacheStaleForMembershipChange(ctx, memberID, identityID)
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
 			"member_of": identityID,
@@ -663,12 +912,17 @@ func (m *GormIdentityRepository) AddMember(ctx context.Context, identityID uuid.
 func (m *GormIdentityRepository) RemoveMember(ctx context.Context, memberOf uuid.UUID, memberID uuid.UUID) error {
 	defer goa.MeasureSince([]string{"goa", "db", "identity", "RemoveMember"}, time.Now())
 
+	err := m.lockMemberships(ctx)
+	if err != nil {
+		return err
+	}
+
 	membership := &Membership{
 		MemberOf: memberOf,
 		MemberID: memberID,
 	}
 
-	err := m.db.Delete(membership).Error
+	err = m.db.Delete(membership).Error
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
 			"member_of": memberOf,
@@ -696,6 +950,46 @@ func (m *GormIdentityRepository) RemoveMember(ctx context.Context, memberOf uuid
 	return nil
 }
 
+// TransferMemberships makes an identity a member of the organizations, teams and groups of another identity, and removes
+// the memberships of the latter. The memberships are re-created rather than updated, so that the membership closure is
+// kept in sync by the triggers of the membership table. The privilege cache is not notified.
+func (m *GormIdentityRepository) TransferMemberships(ctx context.Context, fromMemberID uuid.UUID, toMemberID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "identity", "TransferMemberships"}, time.Now())
+
+	err := m.lockMemberships(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = m.db.Exec(`INSERT INTO membership (member_id, member_of)
+		SELECT ?, member_of FROM membership WHERE member_id = ? AND member_of <> ?
+		ON CONFLICT DO NOTHING`, toMemberID, fromMemberID, toMemberID).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"from_member_id": fromMemberID,
+			"to_member_id":   toMemberID,
+			"err":            err,
+		}, "unable to transfer the memberships")
+		return errs.WithStack(err)
+	}
+	err = m.db.Where("member_id = ?", fromMemberID).Delete(&Membership{}).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"from_member_id": fromMemberID,
+			"to_member_id":   toMemberID,
+			"err":            err,
+		}, "unable to remove the transferred memberships")
+		return errs.WithStack(err)
+	}
+
+	log.Info(ctx, map[string]interface{}{
+		"from_member_id": fromMemberID,
+		"to_member_id":   toMemberID,
+	}, "Memberships transferred!")
+
+	return nil
+}
+
 // FlagStaleForMembershipChange executes two update queries; the first sets the stale flag to true for all privilege
 // cache records where the identity ID is equal to, or a descendent of (via memberships) the specified member ID, and
 // the resourceID is contained in a set of resources for which there is an IDENTITY_ROLE record for the resource, or
@@ -709,22 +1003,12 @@ func (m *GormIdentityRepository) FlagPrivilegeCacheStaleForMembershipChange(ctx
 	defer goa.MeasureSince([]string{"goa", "db", "identity", "FlagPrivilegeCacheStaleForMembershipChange"}, time.Now())
 
 	result := m.db.Exec(`WITH member_identity_hierarchy AS (
-	WITH RECURSIVE m AS (
 	  SELECT
-	    member_id
+	    member_id AS identity_id
 	  FROM
-	    membership
+	    membership_closure
 	  WHERE
 	    member_of = ? /* MEMBER_ID */
-	  UNION SELECT
-	    p.member_id
-	  FROM
-	    membership p INNER JOIN m ON m.member_id = p.member_of
-	  )
-	  SELECT
-	    member_id AS identity_id
-	  FROM 
-	    m
 	  UNION SELECT
 	    id
 	  FROM
@@ -733,22 +1017,12 @@ func (m *GormIdentityRepository) FlagPrivilegeCacheStaleForMembershipChange(ctx
 	    id = ? /* MEMBER_ID */
 ),
 member_of_identity_hierarchy AS (
-WITH RECURSIVE m AS (
   SELECT
-    member_of
+    member_of AS identity_id
   FROM
-    membership
+    membership_closure
   WHERE
     member_id = ? /* MEMBER_OF */
-  UNION SELECT
-    p.member_of
-  FROM
-    membership p INNER JOIN m ON m.member_of = p.member_id
-  )
-  SELECT
-    member_of AS identity_id
-  FROM 
-    m
   UNION SELECT
     id
   FROM
@@ -798,22 +1072,12 @@ WHERE
 	}
 
 	result = m.db.Exec(`WITH member_identity_hierarchy AS (
-	WITH RECURSIVE m AS (
 	  SELECT
-	    member_id
+	    member_id AS identity_id
 	  FROM
-	    membership
+	    membership_closure
 	  WHERE
 	    member_of = ? /* MEMBER_ID */
-	  UNION SELECT
-	    p.member_id
-	  FROM
-	    membership p INNER JOIN m ON m.member_id = p.member_of
-	  )
-	  SELECT
-	    member_id AS identity_id
-	  FROM 
-	    m
 	  UNION SELECT
 	    id
 	  FROM
@@ -822,22 +1086,12 @@ WHERE
 	    id = ? /* MEMBER_ID */
 ),
 member_of_identity_hierarchy AS (
-WITH RECURSIVE m AS (
   SELECT
-    member_of
+    member_of AS identity_id
   FROM
-    membership
+    membership_closure
   WHERE
     member_id = ? /* MEMBER_OF */
-  UNION SELECT
-    p.member_of
-  FROM
-    membership p INNER JOIN m ON m.member_of = p.member_id
-  )
-  SELECT
-    member_of AS identity_id
-  FROM 
-    m
   UNION SELECT
     id
   FROM
@@ -900,11 +1154,12 @@ WHERE
 
 // TouchLastActive is intended to be a lightweight method that updates the last active column for a specified identity
 // to the current timestamp. Also, it resets the `deactivation_notification` timestamp so we can send another deactivation
-// notification to the user if she is once again inactive in the future.
+// notification to the user if she is once again inactive in the future, and it cancels the pending deletion of the
+// account, if any.
 func (m *GormIdentityRepository) TouchLastActive(ctx context.Context, identityID uuid.UUID) error {
 	defer goa.MeasureSince([]string{"goa", "db", "identity", "TouchLastActive"}, time.Now())
 
-	err := m.db.Exec("UPDATE identities SET last_active = ?, deactivation_notification = NULL, deactivation_scheduled = NULL WHERE id = ?", time.Now(), identityID).Error
+	err := m.db.Exec("UPDATE identities SET last_active = ?, deactivation_notification = NULL, deactivation_scheduled = NULL, deactivation_reminders = 0, deletion_requested = NULL WHERE id = ?", time.Now(), identityID).Error
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
 			"id":  identityID,
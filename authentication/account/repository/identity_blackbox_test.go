@@ -5,7 +5,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
 	"github.com/fabric8-services/fabric8-auth/authorization"
+	permission "github.com/fabric8-services/fabric8-auth/authorization/permission/repository"
 	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
 	testsupport "github.com/fabric8-services/fabric8-auth/test"
@@ -14,6 +16,7 @@ import (
 	"fmt"
 
 	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -173,7 +176,7 @@ func (s *IdentityRepositoryTestSuite) TestListIdentitiesToNotifyForDeactivation(
 		// given
 		lastActivity := now.Add(-90 * 24 * time.Hour) // 90 days of inactivity
 		// when
-		result, err := s.Application.Identities().ListIdentitiesToNotifyForDeactivation(ctx, lastActivity, []string{}, 100)
+		result, err := s.Application.Identities().ListIdentitiesToNotifyForDeactivation(ctx, lastActivity, []string{}, 0, 100)
 		// then
 		require.NoError(t, err)
 		assert.Empty(t, result)
@@ -183,7 +186,7 @@ func (s *IdentityRepositoryTestSuite) TestListIdentitiesToNotifyForDeactivation(
 		// given
 		lastActivity := now.Add(-60 * 24 * time.Hour) // 60 days of inactivity
 		// when
-		result, err := s.Application.Identities().ListIdentitiesToNotifyForDeactivation(ctx, lastActivity, []string{}, 100)
+		result, err := s.Application.Identities().ListIdentitiesToNotifyForDeactivation(ctx, lastActivity, []string{}, 0, 100)
 		// then
 		require.NoError(t, err)
 		require.Len(t, result, 1)
@@ -196,7 +199,7 @@ func (s *IdentityRepositoryTestSuite) TestListIdentitiesToNotifyForDeactivation(
 		// given
 		lastActivity := now.Add(-30 * 24 * time.Hour) // 30 days of inactivity
 		// when
-		result, err := s.Application.Identities().ListIdentitiesToNotifyForDeactivation(ctx, lastActivity, []string{}, 1)
+		result, err := s.Application.Identities().ListIdentitiesToNotifyForDeactivation(ctx, lastActivity, []string{}, 0, 1)
 		// then
 		require.NoError(t, err)
 		require.Len(t, result, 1)
@@ -207,7 +210,7 @@ func (s *IdentityRepositoryTestSuite) TestListIdentitiesToNotifyForDeactivation(
 		// given
 		lastActivity := now.Add(-30 * 24 * time.Hour) // 30 days of inactivity
 		// when
-		result, err := s.Application.Identities().ListIdentitiesToNotifyForDeactivation(ctx, lastActivity, []string{}, 100)
+		result, err := s.Application.Identities().ListIdentitiesToNotifyForDeactivation(ctx, lastActivity, []string{}, 0, 100)
 		// then
 		require.NoError(t, err)
 		require.Len(t, result, 2)
@@ -219,7 +222,7 @@ func (s *IdentityRepositoryTestSuite) TestListIdentitiesToNotifyForDeactivation(
 		// given
 		lastActivity := now.Add(-30 * 24 * time.Hour) // 30 days of inactivity
 		// when
-		result, err := s.Application.Identities().ListIdentitiesToNotifyForDeactivation(ctx, lastActivity, []string{}, -1)
+		result, err := s.Application.Identities().ListIdentitiesToNotifyForDeactivation(ctx, lastActivity, []string{}, 0, -1)
 		// then
 		require.NoError(t, err)
 		require.Len(t, result, 2)
@@ -231,7 +234,7 @@ func (s *IdentityRepositoryTestSuite) TestListIdentitiesToNotifyForDeactivation(
 		// given
 		lastActivity := now.Add(-30 * 24 * time.Hour) // 30 days of inactivity
 		// when
-		result, err := s.Application.Identities().ListIdentitiesToNotifyForDeactivation(ctx, lastActivity, []string{identity1.Username}, -1)
+		result, err := s.Application.Identities().ListIdentitiesToNotifyForDeactivation(ctx, lastActivity, []string{identity1.Username}, 0, -1)
 		// then
 		require.NoError(t, err)
 		require.Len(t, result, 1) // user1 is excluded
@@ -242,7 +245,7 @@ func (s *IdentityRepositoryTestSuite) TestListIdentitiesToNotifyForDeactivation(
 		// given
 		lastActivity := now.Add(-30 * 24 * time.Hour) // 30 days of inactivity
 		// when
-		result, err := s.Application.Identities().ListIdentitiesToNotifyForDeactivation(ctx, lastActivity, []string{identity1.Username, identity2.Username}, -1)
+		result, err := s.Application.Identities().ListIdentitiesToNotifyForDeactivation(ctx, lastActivity, []string{identity1.Username, identity2.Username}, 0, -1)
 		// then
 		require.NoError(t, err)
 		require.Empty(t, result) // user1 and user2 are excluded
@@ -307,7 +310,7 @@ func (s *IdentityRepositoryTestSuite) TestListIdentitiesToDeactivate() {
 		// given
 		lastActivity := now.Add(-90 * 24 * time.Hour) // 90 days of inactivity
 		// when
-		result, err := s.Application.Identities().ListIdentitiesToDeactivate(ctx, lastActivity, ago10days, []string{}, 100)
+		result, err := s.Application.Identities().ListIdentitiesToDeactivate(ctx, lastActivity, ago10days, []string{}, 0, 100)
 		// then
 		require.NoError(t, err)
 		assert.Empty(t, result)
@@ -317,7 +320,7 @@ func (s *IdentityRepositoryTestSuite) TestListIdentitiesToDeactivate() {
 		// given
 		lastActivity := now.Add(-30 * 24 * time.Hour) // 30 days of inactivity
 		// when
-		result, err := s.Application.Identities().ListIdentitiesToDeactivate(ctx, lastActivity, ago20days, []string{}, 100)
+		result, err := s.Application.Identities().ListIdentitiesToDeactivate(ctx, lastActivity, ago20days, []string{}, 0, 100)
 		// then
 		require.NoError(t, err)
 		assert.Empty(t, result)
@@ -327,7 +330,7 @@ func (s *IdentityRepositoryTestSuite) TestListIdentitiesToDeactivate() {
 		// given
 		lastActivity := now.Add(-60 * 24 * time.Hour) // 60 days of inactivity
 		// when
-		result, err := s.Application.Identities().ListIdentitiesToDeactivate(ctx, lastActivity, ago10days, []string{}, 100)
+		result, err := s.Application.Identities().ListIdentitiesToDeactivate(ctx, lastActivity, ago10days, []string{}, 0, 100)
 		// then
 		require.NoError(t, err)
 		require.Len(t, result, 1)
@@ -338,7 +341,7 @@ func (s *IdentityRepositoryTestSuite) TestListIdentitiesToDeactivate() {
 		// given
 		lastActivity := now.Add(-30 * 24 * time.Hour) // 30 days of inactivity
 		// when
-		result, err := s.Application.Identities().ListIdentitiesToDeactivate(ctx, lastActivity, ago10days, []string{}, 1)
+		result, err := s.Application.Identities().ListIdentitiesToDeactivate(ctx, lastActivity, ago10days, []string{}, 0, 1)
 		// then
 		require.NoError(t, err)
 		require.Len(t, result, 1)
@@ -349,7 +352,7 @@ func (s *IdentityRepositoryTestSuite) TestListIdentitiesToDeactivate() {
 		// given
 		lastActivity := now.Add(-30 * 24 * time.Hour) // 30 days of inactivity
 		// when
-		result, err := s.Application.Identities().ListIdentitiesToDeactivate(ctx, lastActivity, ago10days, []string{}, 100)
+		result, err := s.Application.Identities().ListIdentitiesToDeactivate(ctx, lastActivity, ago10days, []string{}, 0, 100)
 		// then
 		require.NoError(t, err)
 		require.Len(t, result, 2)
@@ -361,7 +364,7 @@ func (s *IdentityRepositoryTestSuite) TestListIdentitiesToDeactivate() {
 		// given
 		lastActivity := now.Add(-30 * 24 * time.Hour) // 30 days of inactivity
 		// when
-		result, err := s.Application.Identities().ListIdentitiesToDeactivate(ctx, lastActivity, ago10days, []string{}, -1)
+		result, err := s.Application.Identities().ListIdentitiesToDeactivate(ctx, lastActivity, ago10days, []string{}, 0, -1)
 		// then
 		require.NoError(t, err)
 		require.Len(t, result, 2)
@@ -373,7 +376,7 @@ func (s *IdentityRepositoryTestSuite) TestListIdentitiesToDeactivate() {
 		// given
 		lastActivity := now.Add(-30 * 24 * time.Hour) // 30 days of inactivity
 		// when
-		result, err := s.Application.Identities().ListIdentitiesToDeactivate(ctx, lastActivity, ago10days, []string{identity1.Username}, -1)
+		result, err := s.Application.Identities().ListIdentitiesToDeactivate(ctx, lastActivity, ago10days, []string{identity1.Username}, 0, -1)
 		// then
 		require.NoError(t, err)
 		require.Len(t, result, 1) // user1 is excluded
@@ -384,7 +387,7 @@ func (s *IdentityRepositoryTestSuite) TestListIdentitiesToDeactivate() {
 		// given
 		lastActivity := now.Add(-30 * 24 * time.Hour) // 30 days of inactivity
 		// when
-		result, err := s.Application.Identities().ListIdentitiesToDeactivate(ctx, lastActivity, ago10days, []string{identity1.Username, identity2.Username}, -1)
+		result, err := s.Application.Identities().ListIdentitiesToDeactivate(ctx, lastActivity, ago10days, []string{identity1.Username, identity2.Username}, 0, -1)
 		// then
 		require.NoError(t, err)
 		require.Empty(t, result) // user1 and user2 are excluded
@@ -434,19 +437,70 @@ func (s *IdentityRepositoryTestSuite) TestListIdentitiesToDeactivateSorting() {
 	require.NoError(s.T(), err)
 
 	lastActivity := now.Add(-30 * 24 * time.Hour) // 30 days of inactivity
-	result, err := s.Application.Identities().ListIdentitiesToDeactivate(s.Ctx, lastActivity, ago10days, []string{}, 1)
+	result, err := s.Application.Identities().ListIdentitiesToDeactivate(s.Ctx, lastActivity, ago10days, []string{}, 0, 1)
 	require.NoError(s.T(), err)
 
 	require.Len(s.T(), result, 1)
 	require.Equal(s.T(), identity4.ID, result[0].ID)
 
-	result, err = s.Application.Identities().ListIdentitiesToDeactivate(s.Ctx, lastActivity, ago10days, []string{}, 4)
+	result, err = s.Application.Identities().ListIdentitiesToDeactivate(s.Ctx, lastActivity, ago10days, []string{}, 0, 4)
 	require.NoError(s.T(), err)
 
 	require.Len(s.T(), result, 3)
 	require.Equal(s.T(), identity4.ID, result[0].ID)
 	require.Equal(s.T(), identity2.ID, result[1].ID)
 	require.Equal(s.T(), identity1.ID, result[2].ID)
+
+	result, err = s.Application.Identities().ListIdentitiesToDeactivate(s.Ctx, lastActivity, ago10days, []string{}, 1, 1)
+	require.NoError(s.T(), err)
+
+	require.Len(s.T(), result, 1)
+	require.Equal(s.T(), identity2.ID, result[0].ID)
+
+	count, err := s.Application.Identities().Count(s.Ctx, repository.IdentityFilterToDeactivate(lastActivity, ago10days, []string{}))
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), 3, count)
+}
+
+func (s *IdentityRepositoryTestSuite) TestListInactiveIdentitiesByKeyset() {
+	now := time.Now()
+	ago40days := now.Add(-40 * 24 * time.Hour) // 40 days ago
+	ago50days := now.Add(-50 * 24 * time.Hour) // 50 days ago
+
+	// Create a number of users, some of them sharing the same last activity
+	ids := []uuid.UUID{}
+	for _, lastActive := range []time.Time{ago40days, ago50days, ago40days, ago40days} {
+		identity := s.Graph.CreateUser().User().Identities[0]
+		la := lastActive
+		identity.LastActive = &la
+		err := s.Application.Identities().Save(s.Ctx, &identity)
+		require.NoError(s.T(), err)
+		ids = append(ids, identity.ID)
+	}
+	onlyCreated := func(db *gorm.DB) *gorm.DB {
+		return db.Where("identities.id IN (?)", ids)
+	}
+
+	// Walk through the identities one page at a time
+	lastActivity := now.Add(-30 * 24 * time.Hour) // 30 days of inactivity
+	seen := map[uuid.UUID]bool{}
+	var after *repository.Identity
+	for {
+		result, err := s.Application.Identities().ListInactiveIdentities(s.Ctx, lastActivity, after, 1, onlyCreated)
+		require.NoError(s.T(), err)
+		if len(result) == 0 {
+			break
+		}
+		require.Len(s.T(), result, 1)
+		require.False(s.T(), seen[result[0].ID], "identity listed twice")
+		if len(seen) == 0 {
+			// the least recently active identity comes first
+			require.Equal(s.T(), ids[1], result[0].ID)
+		}
+		seen[result[0].ID] = true
+		after = &result[0]
+	}
+	require.Len(s.T(), seen, len(ids))
 }
 
 func (s *IdentityRepositoryTestSuite) TestIdentityExists() {
@@ -631,6 +685,43 @@ func (s *IdentityRepositoryTestSuite) TestAddMember() {
 		assert.True(t, memberships[0].Member)
 	})
 
+	s.T().Run("privilege cache", func(t *testing.T) {
+		// given
+		g := s.NewTestGraph(t)
+		parent := g.CreateGroup()
+		child := g.CreateGroup()
+		parentSpace := g.CreateSpace().AddAdmin(parent)
+		childSpace := g.CreateSpace().AddAdmin(child)
+		parentMember := g.CreateUser()
+		parent.AddMember(parentMember)
+		childMember := g.CreateUser()
+		child.AddMember(childMember)
+		childMemberCache := &permission.PrivilegeCache{
+			IdentityID: childMember.IdentityID(),
+			ResourceID: parentSpace.SpaceID(),
+			Scopes:     "view",
+		}
+		require.NoError(t, s.Application.PrivilegeCacheRepository().Create(s.Ctx, childMemberCache))
+		parentMemberCache := &permission.PrivilegeCache{
+			IdentityID: parentMember.IdentityID(),
+			ResourceID: childSpace.SpaceID(),
+			Scopes:     "view",
+		}
+		require.NoError(t, s.Application.PrivilegeCacheRepository().Create(s.Ctx, parentMemberCache))
+		// when
+		err := s.Application.Identities().AddMember(s.Ctx, parent.GroupID(), child.GroupID())
+		// then
+		require.NoError(t, err)
+		// the members of the new member inherit the roles of the identity they are now a member of
+		cache, err := s.Application.PrivilegeCacheRepository().Load(s.Ctx, childMemberCache.PrivilegeCacheID)
+		require.NoError(t, err)
+		assert.True(t, cache.Stale)
+		// but the other members of that identity do not inherit the roles of the new member
+		cache, err = s.Application.PrivilegeCacheRepository().Load(s.Ctx, parentMemberCache.PrivilegeCacheID)
+		require.NoError(t, err)
+		assert.False(t, cache.Stale)
+	})
+
 	s.T().Run("failure", func(t *testing.T) {
 
 		t.Run("invalid team identity", func(t *testing.T) {
@@ -677,6 +768,36 @@ func (s *IdentityRepositoryTestSuite) TestAddMember() {
 			require.Error(t, err)
 		})
 
+		t.Run("concurrent memberships creating a cycle", func(t *testing.T) {
+			// given
+			g := s.NewTestGraph(t)
+			parent := g.CreateGroup()
+			child := g.CreateGroup()
+			tx := s.DB.Begin()
+			err := repository.NewIdentityRepository(tx).AddMember(s.Ctx, parent.GroupID(), child.GroupID())
+			require.NoError(t, err)
+			// when
+			result := make(chan error)
+			go func() {
+				other := s.DB.Begin()
+				err := repository.NewIdentityRepository(other).AddMember(s.Ctx, child.GroupID(), parent.GroupID())
+				if err != nil {
+					other.Rollback()
+				} else {
+					other.Commit()
+				}
+				result <- err
+			}()
+			// then the second membership waits for the first one to be committed
+			select {
+			case err := <-result:
+				t.Fatalf("membership created while another one was pending: %v", err)
+			case <-time.After(500 * time.Millisecond):
+			}
+			require.NoError(t, tx.Commit().Error)
+			err = <-result
+			require.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		})
 	})
 }
 
@@ -769,3 +890,98 @@ func (s *IdentityRepositoryTestSuite) TestBumpDeactivationSchedule() {
 	// Confirm the scheduled time has been updated (and is equal to the nearest second)
 	require.WithinDuration(s.T(), scheduled, *identity.Identity().DeactivationScheduled, time.Duration(1)*time.Second)
 }
+
+func (s *IdentityRepositoryTestSuite) TestSearch() {
+	// given
+	suffix := uuid.NewV4().String()[:8]
+	john := s.Graph.CreateUser("John Doe" + suffix)
+	jane := s.Graph.CreateUser("Jane Doe" + suffix)
+	jane.User().Company = "Acme" + suffix
+	err := s.Application.Users().Save(s.Ctx, jane.User())
+	require.NoError(s.T(), err)
+	banned := s.Graph.CreateUser("Jim Doe" + suffix)
+	banned.Ban()
+	org := s.Graph.CreateOrganization().AddMember(john)
+
+	identityIDs := func(identities []repository.Identity) []uuid.UUID {
+		ids := make([]uuid.UUID, len(identities))
+		for i, identity := range identities {
+			ids[i] = identity.ID
+		}
+		return ids
+	}
+
+	s.T().Run("inside the full name", func(t *testing.T) {
+		// when
+		result, count, err := s.Application.Identities().Search(s.Ctx, "doe"+suffix, repository.IdentitySearchFilter{}, 0, 10)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+		assert.ElementsMatch(t, []uuid.UUID{john.IdentityID(), jane.IdentityID()}, identityIDs(result))
+	})
+
+	s.T().Run("words in any order", func(t *testing.T) {
+		// when
+		result, count, err := s.Application.Identities().Search(s.Ctx, "doe"+suffix+" john", repository.IdentitySearchFilter{}, 0, 10)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, []uuid.UUID{john.IdentityID()}, identityIDs(result))
+	})
+
+	s.T().Run("with a typo", func(t *testing.T) {
+		// when
+		result, _, err := s.Application.Identities().Search(s.Ctx, "jhon doe"+suffix, repository.IdentitySearchFilter{}, 0, 10)
+		// then
+		require.NoError(t, err)
+		require.NotEmpty(t, result)
+		assert.Equal(t, john.IdentityID(), result[0].ID)
+	})
+
+	s.T().Run("paging", func(t *testing.T) {
+		// when
+		first, count, err := s.Application.Identities().Search(s.Ctx, "doe"+suffix, repository.IdentitySearchFilter{}, 0, 1)
+		require.NoError(t, err)
+		second, _, err := s.Application.Identities().Search(s.Ctx, "doe"+suffix, repository.IdentitySearchFilter{}, 1, 1)
+		require.NoError(t, err)
+		beyond, beyondCount, err := s.Application.Identities().Search(s.Ctx, "doe"+suffix, repository.IdentitySearchFilter{}, 5, 1)
+		require.NoError(t, err)
+		// then
+		assert.Equal(t, 2, count)
+		require.Len(t, first, 1)
+		require.Len(t, second, 1)
+		assert.NotEqual(t, first[0].ID, second[0].ID)
+		assert.Empty(t, beyond)
+		assert.Equal(t, 2, beyondCount)
+	})
+
+	s.T().Run("filter by company", func(t *testing.T) {
+		// when
+		company := "ACME" + suffix
+		result, count, err := s.Application.Identities().Search(s.Ctx, "doe"+suffix, repository.IdentitySearchFilter{Company: &company}, 0, 10)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, []uuid.UUID{jane.IdentityID()}, identityIDs(result))
+	})
+
+	s.T().Run("filter by organization", func(t *testing.T) {
+		// when
+		orgID := org.OrganizationID()
+		result, count, err := s.Application.Identities().Search(s.Ctx, "doe"+suffix, repository.IdentitySearchFilter{OrganizationID: &orgID}, 0, 10)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, []uuid.UUID{john.IdentityID()}, identityIDs(result))
+	})
+
+	s.T().Run("filter by cluster", func(t *testing.T) {
+		// when
+		cluster := jane.User().Cluster
+		result, count, err := s.Application.Identities().Search(s.Ctx, "doe"+suffix, repository.IdentitySearchFilter{Cluster: &cluster}, 0, 10)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, []uuid.UUID{jane.IdentityID()}, identityIDs(result))
+	})
+}
@@ -0,0 +1,324 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/account"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/notification"
+	workerrepo "github.com/fabric8-services/fabric8-auth/worker/repository"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// OutboxEventTypeDeprovisionUser the event to delete the user on the Che and Tenant services
+	OutboxEventTypeDeprovisionUser = "deprovision_user"
+	// OutboxEventTypeAuditLog the event to create an audit log on the admin console service
+	OutboxEventTypeAuditLog = "audit_log"
+	// OutboxEventTypeNotification the event to send a message to the user via the notification service
+	OutboxEventTypeNotification = "notification"
+
+	// OutboxQueue the work queue in which the outbox events are delivered, except the deprovisioning events
+	OutboxQueue = "outbox"
+	// OutboxDeprovisionQueue the work queue in which the deprovisioning events are delivered
+	OutboxDeprovisionQueue = "outbox-deprovision"
+	// DefaultOutboxEventMaxAttempts the maximum number of attempts to deliver an event, unless specified otherwise when
+	// the event is enqueued
+	DefaultOutboxEventMaxAttempts = 12
+)
+
+// OutboxQueues the work queues of the outbox events
+var OutboxQueues = []string{OutboxQueue, OutboxDeprovisionQueue}
+
+// OutboxEvent a side effect on another service, recorded in the same transaction as the change which caused it.
+// The events are stored as the tasks of the outbox work queues, which are consumed by all the pods. An event remains
+// until it was delivered, or until the maximum number of attempts has been reached, in which case it is dead-lettered:
+// it is kept for inspection but not delivered anymore.
+type OutboxEvent struct {
+	// OutboxEventID the ID of the event, which is also the ID of its queue task
+	OutboxEventID uuid.UUID
+	// the identity concerned by the event
+	IdentityID uuid.UUID
+	// the type of event, which determines how it is delivered
+	EventType string
+	// the key which identifies the side effect. An event is not recorded if another one with the same key is pending.
+	// The dead-lettered events are not considered as pending
+	DeduplicationKey string
+	// the data needed to deliver the event
+	Payload account.ContextInformation
+	// the number of attempts to deliver the event
+	Attempts int
+	// the maximum number of attempts before the event is dead-lettered. Defaults to `DefaultOutboxEventMaxAttempts`
+	MaxAttempts int
+	// the time after which the next attempt to deliver the event can happen
+	NextAttempt time.Time
+	// the error which occurred during the last attempt, if any
+	LastError *string
+	// the time at which the delivery was abandoned, if any
+	DeadLetteredAt *time.Time
+}
+
+// Queue returns the work queue in which the event is delivered. The deprovisioning events have their own queue, so
+// that they can be throttled without delaying the other events.
+func (m OutboxEvent) Queue() string {
+	if m.EventType == OutboxEventTypeDeprovisionUser {
+		return OutboxDeprovisionQueue
+	}
+	return OutboxQueue
+}
+
+// NewOutboxEventFromQueueTask returns the event stored in the given task of an outbox queue
+func NewOutboxEventFromQueueTask(task workerrepo.QueueTask) (*OutboxEvent, error) {
+	identityID, err := uuid.FromString(fmt.Sprintf("%v", task.Payload["identity_id"]))
+	if err != nil {
+		return nil, errs.Wrapf(err, "invalid identity ID in payload of outbox event '%s'", task.QueueTaskID)
+	}
+	eventType, ok := task.Payload["event_type"].(string)
+	if !ok {
+		return nil, errs.Errorf("missing event type in payload of outbox event '%s'", task.QueueTaskID)
+	}
+	event := &OutboxEvent{
+		OutboxEventID:  task.QueueTaskID,
+		IdentityID:     identityID,
+		EventType:      eventType,
+		Attempts:       task.Attempts,
+		MaxAttempts:    task.MaxAttempts,
+		NextAttempt:    task.VisibleAt,
+		LastError:      task.LastError,
+		DeadLetteredAt: task.DeadLetteredAt,
+	}
+	if task.DeduplicationKey != nil {
+		event.DeduplicationKey = *task.DeduplicationKey
+	}
+	if payload, ok := task.Payload["payload"].(map[string]interface{}); ok {
+		event.Payload = account.ContextInformation(payload)
+	}
+	return event, nil
+}
+
+// queueTask returns the queue task in which the event is stored
+func (m OutboxEvent) queueTask() workerrepo.QueueTask {
+	deduplicationKey := m.DeduplicationKey
+	return workerrepo.QueueTask{
+		QueueTaskID: m.OutboxEventID,
+		Queue:       m.Queue(),
+		Payload: account.ContextInformation{
+			"identity_id": m.IdentityID.String(),
+			"event_type":  m.EventType,
+			"payload":     map[string]interface{}(m.Payload),
+		},
+		DeduplicationKey: &deduplicationKey,
+		MaxAttempts:      m.MaxAttempts,
+		VisibleAt:        m.NextAttempt,
+	}
+}
+
+// NewDeprovisionUserEvent returns a new event to delete the given identity on the Che and Tenant services
+func NewDeprovisionUserEvent(identityID uuid.UUID) *OutboxEvent {
+	return &OutboxEvent{
+		IdentityID:       identityID,
+		EventType:        OutboxEventTypeDeprovisionUser,
+		DeduplicationKey: fmt.Sprintf("%s:%s", OutboxEventTypeDeprovisionUser, identityID),
+	}
+}
+
+// NewAuditLogEvent returns a new event to create an audit log of the given type for the given identity. The username
+// is recorded as-is, since the identity may be obfuscated by the time the event is delivered.
+func NewAuditLogEvent(identityID uuid.UUID, username string, auditLogType string) *OutboxEvent {
+	return &OutboxEvent{
+		IdentityID:       identityID,
+		EventType:        OutboxEventTypeAuditLog,
+		DeduplicationKey: fmt.Sprintf("%s:%s:%s", OutboxEventTypeAuditLog, auditLogType, identityID),
+		Payload: account.ContextInformation{
+			"username":   username,
+			"event_type": auditLogType,
+		},
+	}
+}
+
+// NewNotificationEvent returns a new event to send the given message to the given identity
+func NewNotificationEvent(identityID uuid.UUID, msg notification.Message) (*OutboxEvent, error) {
+	payload := account.ContextInformation{}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, errs.Wrap(err, "unable to encode the notification message")
+	}
+	err = json.Unmarshal(data, &payload)
+	if err != nil {
+		return nil, errs.Wrap(err, "unable to encode the notification message")
+	}
+	return &OutboxEvent{
+		IdentityID:       identityID,
+		EventType:        OutboxEventTypeNotification,
+		DeduplicationKey: fmt.Sprintf("%s:%s", OutboxEventTypeNotification, msg.MessageID),
+		Payload:          payload,
+	}, nil
+}
+
+// AuditLog returns the username and the audit log type of an audit log event
+func (m OutboxEvent) AuditLog() (string, string, error) {
+	username, ok := m.Payload["username"].(string)
+	if !ok {
+		return "", "", errs.Errorf("missing username in payload of outbox event '%s'", m.OutboxEventID)
+	}
+	auditLogType, ok := m.Payload["event_type"].(string)
+	if !ok {
+		return "", "", errs.Errorf("missing event type in payload of outbox event '%s'", m.OutboxEventID)
+	}
+	return username, auditLogType, nil
+}
+
+// NotificationMessage returns the message of a notification event
+func (m OutboxEvent) NotificationMessage() (notification.Message, error) {
+	var msg notification.Message
+	data, err := json.Marshal(m.Payload)
+	if err != nil {
+		return msg, errs.Wrapf(err, "unable to decode the payload of outbox event '%s'", m.OutboxEventID)
+	}
+	err = json.Unmarshal(data, &msg)
+	if err != nil {
+		return msg, errs.Wrapf(err, "unable to decode the payload of outbox event '%s'", m.OutboxEventID)
+	}
+	return msg, nil
+}
+
+// GormOutboxEventRepository is the implementation of the storage interface for OutboxEvent, on top of the queue tasks.
+type GormOutboxEventRepository struct {
+	db *gorm.DB
+}
+
+// NewOutboxEventRepository creates a new storage type.
+func NewOutboxEventRepository(db *gorm.DB) OutboxEventRepository {
+	return &GormOutboxEventRepository{db: db}
+}
+
+// OutboxEventRepository represents the storage interface.
+type OutboxEventRepository interface {
+	Load(ctx context.Context, id uuid.UUID) (*OutboxEvent, error)
+	Enqueue(ctx context.Context, event *OutboxEvent) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	ListForIdentity(ctx context.Context, identityID uuid.UUID) ([]OutboxEvent, error)
+	Redrive(ctx context.Context, id uuid.UUID) error
+	ListDeadLettered(ctx context.Context, limit int) ([]OutboxEvent, error)
+}
+
+// Load returns a single event
+func (m *GormOutboxEventRepository) Load(ctx context.Context, id uuid.UUID) (*OutboxEvent, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "outbox_event", "load"}, time.Now())
+	var native workerrepo.QueueTask
+	err := m.db.Where("queue_task_id = ? AND queue IN (?)", id, OutboxQueues).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("outbox_event", id.String())
+	}
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	return NewOutboxEventFromQueueTask(native)
+}
+
+// Enqueue records a new event in its outbox queue, to be delivered as soon as possible. Nothing is recorded if an
+// event with the same deduplication key is already pending, but a dead-lettered event does not prevent a new one from
+// being recorded.
+func (m *GormOutboxEventRepository) Enqueue(ctx context.Context, event *OutboxEvent) error {
+	defer goa.MeasureSince([]string{"goa", "db", "outbox_event", "enqueue"}, time.Now())
+	if event.OutboxEventID == uuid.Nil {
+		event.OutboxEventID = uuid.NewV4()
+	}
+	if event.MaxAttempts <= 0 {
+		event.MaxAttempts = DefaultOutboxEventMaxAttempts
+	}
+	task := event.queueTask()
+	err := workerrepo.NewQueueTaskRepository(m.db).Enqueue(ctx, &task)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"outbox_event_id": event.OutboxEventID,
+			"identity_id":     event.IdentityID,
+			"event_type":      event.EventType,
+			"err":             err,
+		}, "unable to create the outbox event")
+		return err
+	}
+	event.NextAttempt = task.VisibleAt
+	return nil
+}
+
+// Delete removes a single event, whether it is pending or dead-lettered. This is a hard delete!
+func (m *GormOutboxEventRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "outbox_event", "delete"}, time.Now())
+	result := m.db.Where("queue_task_id = ? AND queue IN (?)", id, OutboxQueues).Delete(&workerrepo.QueueTask{})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"outbox_event_id": id,
+			"err":             result.Error,
+		}, "unable to delete the outbox event")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("outbox_event", id.String())
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"outbox_event_id": id,
+	}, "outbox event deleted!")
+	return nil
+}
+
+// ListForIdentity returns the pending and dead-lettered events of the given identity, the oldest ones first
+func (m *GormOutboxEventRepository) ListForIdentity(ctx context.Context, identityID uuid.UUID) ([]OutboxEvent, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "outbox_event", "list_for_identity"}, time.Now())
+	var tasks []workerrepo.QueueTask
+	err := m.db.Where("queue IN (?) AND payload->>'identity_id' = ?", OutboxQueues, identityID.String()).Order("created_at").Find(&tasks).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": identityID,
+			"err":         err,
+		}, "unable to list the outbox events")
+		return nil, errs.WithStack(err)
+	}
+	return newOutboxEvents(tasks)
+}
+
+// Redrive puts the dead-lettered event with the given ID back in its outbox queue, with a new set of attempts. A
+// `DataConflictError` is returned if an event with the same deduplication key was recorded in the meantime.
+func (m *GormOutboxEventRepository) Redrive(ctx context.Context, id uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "outbox_event", "redrive"}, time.Now())
+	if _, err := m.Load(ctx, id); err != nil {
+		return err
+	}
+	return workerrepo.NewQueueTaskRepository(m.db).Redrive(ctx, id)
+}
+
+// ListDeadLettered returns the dead-lettered events, the most recent ones first. The number of results is capped by
+// the given limit.
+func (m *GormOutboxEventRepository) ListDeadLettered(ctx context.Context, limit int) ([]OutboxEvent, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "outbox_event", "list_dead_lettered"}, time.Now())
+	var tasks []workerrepo.QueueTask
+	err := m.db.Where("queue IN (?) AND dead_lettered_at IS NOT NULL", OutboxQueues).Order("dead_lettered_at DESC").Limit(limit).Find(&tasks).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to list the dead-lettered outbox events")
+		return nil, errs.WithStack(err)
+	}
+	return newOutboxEvents(tasks)
+}
+
+// newOutboxEvents returns the events stored in the given queue tasks
+func newOutboxEvents(tasks []workerrepo.QueueTask) ([]OutboxEvent, error) {
+	events := make([]OutboxEvent, len(tasks))
+	for i, task := range tasks {
+		event, err := NewOutboxEventFromQueueTask(task)
+		if err != nil {
+			return nil, err
+		}
+		events[i] = *event
+	}
+	return events, nil
+}
@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// PendingEmailChange a change of the email address of a user which is not confirmed yet. The email address of the user
+// is only changed once the new address is confirmed with the verification code, unless the change is cancelled from
+// the current address before. A confirmed change is kept along with the previous address until it expires, so that it
+// can still be reverted from the previous address.
+type PendingEmailChange struct {
+	gormsupport.LifecycleHardDelete
+	// PendingEmailChangeID the ID of the change. This is the primary key value.
+	PendingEmailChangeID uuid.UUID `sql:"type:uuid" gorm:"primary_key;column:pending_email_change_id"`
+	// the user whose email address changes
+	UserID uuid.UUID `sql:"type:uuid"`
+	// the new email address, which is not confirmed yet
+	NewEmail string
+	// the code sent to the new email address to confirm the change, or nil once the change is confirmed
+	VerificationCodeID *uuid.UUID `sql:"type:uuid"`
+	// the code sent to the current email address to cancel the change
+	CancelCode string
+	// the time after which the change cannot be confirmed nor cancelled anymore
+	ExpiresAt time.Time
+	// the email address of the user before the change was confirmed, which is restored if the change is reverted
+	PreviousEmail *string
+	// the time at which the change was confirmed, or nil if it is not confirmed yet
+	ConfirmedAt *time.Time
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m PendingEmailChange) TableName() string {
+	return "pending_email_change"
+}
+
+// Expired returns true if the change expired at the given time
+func (m PendingEmailChange) Expired(now time.Time) bool {
+	return !m.ExpiresAt.After(now)
+}
+
+// Confirmed returns true if the change was confirmed with the verification code sent to the new address
+func (m PendingEmailChange) Confirmed() bool {
+	return m.ConfirmedAt != nil
+}
+
+// GormPendingEmailChangeRepository is the implementation of the storage interface for PendingEmailChange.
+type GormPendingEmailChangeRepository struct {
+	db *gorm.DB
+}
+
+// NewPendingEmailChangeRepository creates a new storage type.
+func NewPendingEmailChangeRepository(db *gorm.DB) PendingEmailChangeRepository {
+	return &GormPendingEmailChangeRepository{db: db}
+}
+
+// PendingEmailChangeRepository represents the storage interface.
+type PendingEmailChangeRepository interface {
+	LoadForUser(ctx context.Context, userID uuid.UUID) (*PendingEmailChange, error)
+	LoadByVerificationCode(ctx context.Context, verificationCodeID uuid.UUID) (*PendingEmailChange, error)
+	LoadByCancelCode(ctx context.Context, cancelCode string) (*PendingEmailChange, error)
+	Create(ctx context.Context, change *PendingEmailChange) error
+	Save(ctx context.Context, change *PendingEmailChange) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// LoadForUser returns the pending change of the email address of the given user
+func (m *GormPendingEmailChangeRepository) LoadForUser(ctx context.Context, userID uuid.UUID) (*PendingEmailChange, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "pending_email_change", "load_for_user"}, time.Now())
+	var native PendingEmailChange
+	err := m.db.Where("user_id = ?", userID).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundErrorWithKey("pending_email_change", "user_id", userID.String())
+	}
+	return &native, errs.WithStack(err)
+}
+
+// LoadByVerificationCode returns the pending change of email address which is confirmed with the given verification code
+func (m *GormPendingEmailChangeRepository) LoadByVerificationCode(ctx context.Context, verificationCodeID uuid.UUID) (*PendingEmailChange, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "pending_email_change", "load_by_verification_code"}, time.Now())
+	var native PendingEmailChange
+	err := m.db.Where("verification_code_id = ?", verificationCodeID).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundErrorWithKey("pending_email_change", "verification_code_id", verificationCodeID.String())
+	}
+	return &native, errs.WithStack(err)
+}
+
+// LoadByCancelCode returns the pending change of email address which is cancelled with the given code
+func (m *GormPendingEmailChangeRepository) LoadByCancelCode(ctx context.Context, cancelCode string) (*PendingEmailChange, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "pending_email_change", "load_by_cancel_code"}, time.Now())
+	var native PendingEmailChange
+	err := m.db.Where("cancel_code = ?", cancelCode).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundErrorWithKey("pending_email_change", "cancel_code", cancelCode)
+	}
+	return &native, errs.WithStack(err)
+}
+
+// Create creates a new record.
+func (m *GormPendingEmailChangeRepository) Create(ctx context.Context, change *PendingEmailChange) error {
+	defer goa.MeasureSince([]string{"goa", "db", "pending_email_change", "create"}, time.Now())
+	if change.PendingEmailChangeID == uuid.Nil {
+		change.PendingEmailChangeID = uuid.NewV4()
+	}
+	err := m.db.Create(change).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"pending_email_change_id": change.PendingEmailChangeID,
+			"user_id":                 change.UserID,
+			"err":                     err,
+		}, "unable to create the pending email change")
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"pending_email_change_id": change.PendingEmailChangeID,
+		"user_id":                 change.UserID,
+	}, "pending email change created!")
+	return nil
+}
+
+// Save modifies a single record.
+func (m *GormPendingEmailChangeRepository) Save(ctx context.Context, change *PendingEmailChange) error {
+	defer goa.MeasureSince([]string{"goa", "db", "pending_email_change", "save"}, time.Now())
+	err := m.db.Save(change).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"pending_email_change_id": change.PendingEmailChangeID,
+			"err":                     err,
+		}, "unable to update the pending email change")
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"pending_email_change_id": change.PendingEmailChangeID,
+	}, "pending email change saved!")
+	return nil
+}
+
+// Delete removes a single record. This is a hard delete!
+func (m *GormPendingEmailChangeRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "pending_email_change", "delete"}, time.Now())
+	result := m.db.Delete(&PendingEmailChange{PendingEmailChangeID: id})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"pending_email_change_id": id,
+			"err":                     result.Error,
+		}, "unable to delete the pending email change")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("pending_email_change", id.String())
+	}
+	return nil
+}
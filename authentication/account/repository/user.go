@@ -42,6 +42,8 @@ type User struct {
 	Active             bool                       `gorm:"column:active"`
 	Identities         []Identity                 // has many Identities from different IDPs
 	ContextInformation account.ContextInformation `sql:"type:jsonb"` // context information of the user activity
+	ProfileAttributes  account.ContextInformation `sql:"type:jsonb"` // the values of the custom profile attributes of the user
+	AvatarID           *uuid.UUID                 `sql:"type:uuid"`  // the avatar uploaded by the user, if any. The ImageURL points at it.
 }
 
 const (
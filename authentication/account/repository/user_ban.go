@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// UserBanCategoryAbuse the user abused the platform resources (eg: crypto-mining)
+	UserBanCategoryAbuse = "abuse"
+	// UserBanCategorySpam the user published spam or malicious content
+	UserBanCategorySpam = "spam"
+	// UserBanCategoryTermsViolation the user violated the terms of service
+	UserBanCategoryTermsViolation = "terms_violation"
+	// UserBanCategorySecurity the account was compromised or is a threat to the platform
+	UserBanCategorySecurity = "security"
+	// UserBanCategoryOther any other reason
+	UserBanCategoryOther = "other"
+)
+
+// UserBanCategories the known categories of ban reasons
+var UserBanCategories = []string{
+	UserBanCategoryAbuse,
+	UserBanCategorySpam,
+	UserBanCategoryTermsViolation,
+	UserBanCategorySecurity,
+	UserBanCategoryOther,
+}
+
+// IsValidUserBanCategory returns true if the given category is one of the known categories of ban reasons
+func IsValidUserBanCategory(category string) bool {
+	for _, c := range UserBanCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// UserBan the record of a ban of a user, with its reason and its period
+type UserBan struct {
+	gormsupport.LifecycleHardDelete
+	// UserBanID the ID of the ban. This is the primary key value.
+	UserBanID uuid.UUID `sql:"type:uuid" gorm:"primary_key;column:user_ban_id"`
+	// the banned user
+	UserID uuid.UUID `sql:"type:uuid"`
+	// the category of the reason of the ban
+	ReasonCategory string
+	// the details about the reason of the ban
+	Reason *string
+	// the name of the user or service account who banned the user
+	Actor string
+	// the time at which the ban takes effect
+	StartsAt time.Time
+	// the time at which the ban ends, or nil if the ban is permanent
+	EndsAt *time.Time
+	// the time at which the ban was lifted, either at its end or before
+	LiftedAt *time.Time
+	// the name of the user or service account who lifted the ban, if it was lifted before its end
+	LiftedBy *string
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m UserBan) TableName() string {
+	return "user_ban"
+}
+
+// Active returns true if the ban has started at the given time, and has not ended nor been lifted yet
+func (m UserBan) Active(now time.Time) bool {
+	return m.LiftedAt == nil && !m.StartsAt.After(now) && (m.EndsAt == nil || m.EndsAt.After(now))
+}
+
+// GormUserBanRepository is the implementation of the storage interface for UserBan.
+type GormUserBanRepository struct {
+	db *gorm.DB
+}
+
+// NewUserBanRepository creates a new storage type.
+func NewUserBanRepository(db *gorm.DB) UserBanRepository {
+	return &GormUserBanRepository{db: db}
+}
+
+// UserBanRepository represents the storage interface.
+type UserBanRepository interface {
+	Load(ctx context.Context, id uuid.UUID) (*UserBan, error)
+	LoadActiveForUser(ctx context.Context, userID uuid.UUID, now time.Time) (*UserBan, error)
+	Create(ctx context.Context, ban *UserBan) error
+	Save(ctx context.Context, ban *UserBan) error
+	ListForUser(ctx context.Context, userID uuid.UUID) ([]UserBan, error)
+	ListToStart(ctx context.Context, now time.Time, limit int) ([]UserBan, error)
+	ListToLift(ctx context.Context, now time.Time, limit int) ([]UserBan, error)
+	LiftForUser(ctx context.Context, userID uuid.UUID, liftedBy string, now time.Time) error
+}
+
+// Load returns a single ban as a Database Model
+func (m *GormUserBanRepository) Load(ctx context.Context, id uuid.UUID) (*UserBan, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user_ban", "load"}, time.Now())
+	var native UserBan
+	err := m.db.Where("user_ban_id = ?", id).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("user_ban", id.String())
+	}
+	return &native, errs.WithStack(err)
+}
+
+// LoadActiveForUser returns the most recent ban of the given user which is in effect at the given time
+func (m *GormUserBanRepository) LoadActiveForUser(ctx context.Context, userID uuid.UUID, now time.Time) (*UserBan, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user_ban", "load_active_for_user"}, time.Now())
+	var native UserBan
+	err := m.db.Where("user_id = ? AND lifted_at IS NULL AND starts_at <= ? AND (ends_at IS NULL OR ends_at > ?)", userID, now, now).
+		Order("starts_at DESC").Limit(1).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundErrorWithKey("active user_ban", "user_id", userID.String())
+	}
+	return &native, errs.WithStack(err)
+}
+
+// Create creates a new record.
+func (m *GormUserBanRepository) Create(ctx context.Context, ban *UserBan) error {
+	defer goa.MeasureSince([]string{"goa", "db", "user_ban", "create"}, time.Now())
+	if ban.UserBanID == uuid.Nil {
+		ban.UserBanID = uuid.NewV4()
+	}
+	err := m.db.Create(ban).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"user_ban_id": ban.UserBanID,
+			"user_id":     ban.UserID,
+			"err":         err,
+		}, "unable to create the user ban")
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"user_ban_id": ban.UserBanID,
+		"user_id":     ban.UserID,
+	}, "user ban created!")
+	return nil
+}
+
+// Save modifies a single record.
+func (m *GormUserBanRepository) Save(ctx context.Context, ban *UserBan) error {
+	defer goa.MeasureSince([]string{"goa", "db", "user_ban", "save"}, time.Now())
+	obj, err := m.Load(ctx, ban.UserBanID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"user_ban_id": ban.UserBanID,
+			"err":         err,
+		}, "unable to update the user ban")
+		return errs.WithStack(err)
+	}
+	err = m.db.Model(obj).Updates(ban).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"user_ban_id": ban.UserBanID,
+			"err":         err,
+		}, "unable to update the user ban")
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"user_ban_id": ban.UserBanID,
+	}, "user ban saved!")
+	return nil
+}
+
+// ListForUser returns all the bans of the given user, the most recent ones first
+func (m *GormUserBanRepository) ListForUser(ctx context.Context, userID uuid.UUID) ([]UserBan, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user_ban", "list_for_user"}, time.Now())
+	var bans []UserBan
+	err := m.db.Where("user_id = ?", userID).Order("starts_at DESC, created_at DESC").Find(&bans).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Error(ctx, map[string]interface{}{
+			"user_id": userID,
+			"err":     err,
+		}, "unable to list the user bans")
+		return nil, errs.WithStack(err)
+	}
+	return bans, nil
+}
+
+// ListToStart returns the scheduled bans which started at the given time, but whose user is not banned yet. The number of
+// results is capped by the given limit.
+func (m *GormUserBanRepository) ListToStart(ctx context.Context, now time.Time, limit int) ([]UserBan, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user_ban", "list_to_start"}, time.Now())
+	var bans []UserBan
+	err := m.db.Joins("JOIN users u ON u.id = user_ban.user_id").
+		Where("u.banned = false AND u.deleted_at IS NULL").
+		Where("user_ban.lifted_at IS NULL AND user_ban.starts_at <= ? AND (user_ban.ends_at IS NULL OR user_ban.ends_at > ?)", now, now).
+		Order("user_ban.starts_at").Limit(limit).Find(&bans).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to list the user bans to start")
+		return nil, errs.WithStack(err)
+	}
+	return bans, nil
+}
+
+// ListToLift returns the bans which ended at the given time, but which have not been lifted yet. The number of
+// results is capped by the given limit.
+func (m *GormUserBanRepository) ListToLift(ctx context.Context, now time.Time, limit int) ([]UserBan, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user_ban", "list_to_lift"}, time.Now())
+	var bans []UserBan
+	err := m.db.Where("lifted_at IS NULL AND ends_at <= ?", now).Order("ends_at").Limit(limit).Find(&bans).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to list the user bans to lift")
+		return nil, errs.WithStack(err)
+	}
+	return bans, nil
+}
+
+// LiftForUser lifts all the current and scheduled bans of the given user
+func (m *GormUserBanRepository) LiftForUser(ctx context.Context, userID uuid.UUID, liftedBy string, now time.Time) error {
+	defer goa.MeasureSince([]string{"goa", "db", "user_ban", "lift_for_user"}, time.Now())
+	err := m.db.Model(&UserBan{}).Where("user_id = ? AND lifted_at IS NULL", userID).
+		Updates(map[string]interface{}{"lifted_at": now, "lifted_by": liftedBy}).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"user_id": userID,
+			"err":     err,
+		}, "unable to lift the user bans")
+		return errs.WithStack(err)
+	}
+	return nil
+}
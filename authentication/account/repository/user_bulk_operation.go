@@ -0,0 +1,252 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/account"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// UserBulkOperationBan bans the users
+	UserBulkOperationBan = "ban"
+	// UserBulkOperationDeactivate deactivates the accounts of the users
+	UserBulkOperationDeactivate = "deactivate"
+	// UserBulkOperationMove moves the users to another cluster
+	UserBulkOperationMove = "move"
+
+	// UserBulkOperationStatusRunning some users of the operation have not been processed yet
+	UserBulkOperationStatusRunning = "running"
+	// UserBulkOperationStatusCompleted all the users of the operation have been processed, successfully or not
+	UserBulkOperationStatusCompleted = "completed"
+
+	// UserBulkOperationItemStatusPending the user has not been processed yet
+	UserBulkOperationItemStatusPending = "pending"
+	// UserBulkOperationItemStatusSucceeded the operation was applied to the user
+	UserBulkOperationItemStatusSucceeded = "succeeded"
+	// UserBulkOperationItemStatusFailed the operation could not be applied to the user
+	UserBulkOperationItemStatusFailed = "failed"
+)
+
+// UserBulkOperations the known types of bulk operations
+var UserBulkOperations = []string{
+	UserBulkOperationBan,
+	UserBulkOperationDeactivate,
+	UserBulkOperationMove,
+}
+
+// UserBulkOperationQueue the name of the work queue in which a task is enqueued for each user of a bulk operation
+const UserBulkOperationQueue = "user-bulk-operation"
+
+// IsValidUserBulkOperation returns true if the given operation is one of the known types of bulk operations
+func IsValidUserBulkOperation(operation string) bool {
+	for _, o := range UserBulkOperations {
+		if o == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// UserBulkOperation an operation applied asynchronously to a list of users, on behalf of an admin
+type UserBulkOperation struct {
+	gormsupport.LifecycleHardDelete
+	// UserBulkOperationID the ID of the operation. This is the primary key value.
+	UserBulkOperationID uuid.UUID `sql:"type:uuid" gorm:"primary_key;column:user_bulk_operation_id"`
+	// the type of operation: ban, deactivate or move
+	Operation string
+	// the parameters of the operation, such as the reason of the ban or the target cluster
+	Parameters account.ContextInformation `sql:"type:jsonb"`
+	// the name of the service account which requested the operation
+	RequestedBy string
+	// the status of the operation: running or completed
+	Status string
+	// the time at which the last user of the operation was processed
+	CompletedAt *time.Time
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m UserBulkOperation) TableName() string {
+	return "user_bulk_operation"
+}
+
+// UserBulkOperationItem the result of a bulk operation for a single user
+type UserBulkOperationItem struct {
+	gormsupport.LifecycleHardDelete
+	// UserBulkOperationItemID the ID of the item. This is the primary key value.
+	UserBulkOperationItemID uuid.UUID `sql:"type:uuid" gorm:"primary_key;column:user_bulk_operation_item_id"`
+	// the operation which the item belongs to
+	UserBulkOperationID uuid.UUID `sql:"type:uuid"`
+	// the username of the user
+	Username string
+	// the status of the item: pending, succeeded or failed
+	Status string
+	// the reason why the operation could not be applied to the user, if it failed
+	Error *string
+	// the time at which the user was processed
+	CompletedAt *time.Time
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m UserBulkOperationItem) TableName() string {
+	return "user_bulk_operation_item"
+}
+
+// UserBulkOperationFilter selects the users of a bulk operation by their attributes, instead of listing their usernames
+type UserBulkOperationFilter struct {
+	// the URL of the cluster of the users
+	Cluster *string
+	// the feature level of the users
+	FeatureLevel *string
+}
+
+// IsEmpty returns true if the filter does not select the users by any attribute
+func (f UserBulkOperationFilter) IsEmpty() bool {
+	return f.Cluster == nil && f.FeatureLevel == nil
+}
+
+// GormUserBulkOperationRepository is the implementation of the storage interface for UserBulkOperation.
+type GormUserBulkOperationRepository struct {
+	db *gorm.DB
+}
+
+// NewUserBulkOperationRepository creates a new storage type.
+func NewUserBulkOperationRepository(db *gorm.DB) UserBulkOperationRepository {
+	return &GormUserBulkOperationRepository{db: db}
+}
+
+// UserBulkOperationRepository represents the storage interface.
+type UserBulkOperationRepository interface {
+	Load(ctx context.Context, id uuid.UUID) (*UserBulkOperation, error)
+	Create(ctx context.Context, operation *UserBulkOperation, usernames []string) error
+	ListItems(ctx context.Context, id uuid.UUID) ([]UserBulkOperationItem, error)
+	LoadItem(ctx context.Context, id uuid.UUID, username string) (*UserBulkOperationItem, error)
+	CompleteItem(ctx context.Context, id uuid.UUID, username string, cause error) error
+}
+
+// Load returns a single operation as a Database Model
+func (m *GormUserBulkOperationRepository) Load(ctx context.Context, id uuid.UUID) (*UserBulkOperation, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user_bulk_operation", "load"}, time.Now())
+	var native UserBulkOperation
+	err := m.db.Where("user_bulk_operation_id = ?", id).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("user_bulk_operation", id.String())
+	}
+	return &native, errs.WithStack(err)
+}
+
+// Create records a new running operation, along with a pending item for each of the given users
+func (m *GormUserBulkOperationRepository) Create(ctx context.Context, operation *UserBulkOperation, usernames []string) error {
+	defer goa.MeasureSince([]string{"goa", "db", "user_bulk_operation", "create"}, time.Now())
+	if operation.UserBulkOperationID == uuid.Nil {
+		operation.UserBulkOperationID = uuid.NewV4()
+	}
+	operation.Status = UserBulkOperationStatusRunning
+	err := m.db.Create(operation).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"user_bulk_operation_id": operation.UserBulkOperationID,
+			"operation":              operation.Operation,
+			"err":                    err,
+		}, "unable to create the user bulk operation")
+		return errs.WithStack(err)
+	}
+	for _, username := range usernames {
+		err := m.db.Create(&UserBulkOperationItem{
+			UserBulkOperationItemID: uuid.NewV4(),
+			UserBulkOperationID:     operation.UserBulkOperationID,
+			Username:                username,
+			Status:                  UserBulkOperationItemStatusPending,
+		}).Error
+		if err != nil {
+			if gormsupport.IsUniqueViolation(err, "user_bulk_operation_item_username_idx") {
+				return errors.NewDataConflictError("the same user cannot be listed more than once in a bulk operation: " + username)
+			}
+			log.Error(ctx, map[string]interface{}{
+				"user_bulk_operation_id": operation.UserBulkOperationID,
+				"username":               username,
+				"err":                    err,
+			}, "unable to create the user bulk operation item")
+			return errs.WithStack(err)
+		}
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"user_bulk_operation_id": operation.UserBulkOperationID,
+		"operation":              operation.Operation,
+		"users":                  len(usernames),
+	}, "user bulk operation created!")
+	return nil
+}
+
+// ListItems returns the items of the given operation, ordered by username
+func (m *GormUserBulkOperationRepository) ListItems(ctx context.Context, id uuid.UUID) ([]UserBulkOperationItem, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user_bulk_operation", "list_items"}, time.Now())
+	var items []UserBulkOperationItem
+	err := m.db.Where("user_bulk_operation_id = ?", id).Order("username").Find(&items).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return items, nil
+}
+
+// LoadItem returns the item of the given operation for the given user
+func (m *GormUserBulkOperationRepository) LoadItem(ctx context.Context, id uuid.UUID, username string) (*UserBulkOperationItem, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user_bulk_operation", "load_item"}, time.Now())
+	var native UserBulkOperationItem
+	err := m.db.Where("user_bulk_operation_id = ? AND username = ?", id, username).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundErrorWithKey("user_bulk_operation_item", "username", username)
+	}
+	return &native, errs.WithStack(err)
+}
+
+// CompleteItem records the result of the operation for the given user: the item failed if a cause is given, and
+// succeeded otherwise. Nothing is recorded if the item was already completed. The operation is completed once all
+// its items are completed.
+func (m *GormUserBulkOperationRepository) CompleteItem(ctx context.Context, id uuid.UUID, username string, cause error) error {
+	defer goa.MeasureSince([]string{"goa", "db", "user_bulk_operation", "complete_item"}, time.Now())
+	now := time.Now()
+	values := map[string]interface{}{
+		"status":       UserBulkOperationItemStatusSucceeded,
+		"completed_at": now,
+		"updated_at":   now,
+	}
+	if cause != nil {
+		values["status"] = UserBulkOperationItemStatusFailed
+		values["error"] = cause.Error()
+	}
+	result := m.db.Model(&UserBulkOperationItem{}).
+		Where("user_bulk_operation_id = ? AND username = ? AND status = ?", id, username, UserBulkOperationItemStatusPending).
+		Updates(values)
+	if result.Error != nil {
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		// the item was already completed, unless it does not exist
+		_, err := m.LoadItem(ctx, id, username)
+		return err
+	}
+	err := m.db.Exec(`UPDATE user_bulk_operation SET status = ?, completed_at = ?, updated_at = ?
+		WHERE user_bulk_operation_id = ? AND status = ?
+		AND NOT EXISTS (SELECT 1 FROM user_bulk_operation_item WHERE user_bulk_operation_id = ? AND status = ?)`,
+		UserBulkOperationStatusCompleted, now, now, id, UserBulkOperationStatusRunning, id, UserBulkOperationItemStatusPending).Error
+	if err != nil {
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"user_bulk_operation_id": id,
+		"username":               username,
+		"status":                 values["status"],
+	}, "user bulk operation item completed")
+	return nil
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/account"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// UserDataExportStatusPending the export was requested but its content has not been generated yet
+	UserDataExportStatusPending = "pending"
+	// UserDataExportStatusReady the content of the export was generated and can be downloaded until the export expires
+	UserDataExportStatusReady = "ready"
+	// UserDataExportStatusFailed the content of the export could not be generated
+	UserDataExportStatusFailed = "failed"
+)
+
+// UserDataExport a copy of the personal data of a user, generated on demand
+type UserDataExport struct {
+	gormsupport.LifecycleHardDelete
+	// UserDataExportID the ID of the export. This is the primary key value.
+	UserDataExportID uuid.UUID `sql:"type:uuid" gorm:"primary_key;column:user_data_export_id"`
+	// the identity whose data is exported
+	IdentityID uuid.UUID `sql:"type:uuid"`
+	// the status of the export: pending, ready or failed
+	Status string
+	// the exported data, once generated
+	Content account.ContextInformation `sql:"type:jsonb"`
+	// the time at which the content was generated, or at which the generation failed
+	CompletedAt *time.Time
+	// the time after which the export is not available anymore
+	ExpiresAt *time.Time
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m UserDataExport) TableName() string {
+	return "user_data_export"
+}
+
+// Expired returns true if the export has an expiry time which is before the given time
+func (m UserDataExport) Expired(now time.Time) bool {
+	return m.ExpiresAt != nil && m.ExpiresAt.Before(now)
+}
+
+// GormUserDataExportRepository is the implementation of the storage interface for UserDataExport.
+type GormUserDataExportRepository struct {
+	db *gorm.DB
+}
+
+// NewUserDataExportRepository creates a new storage type.
+func NewUserDataExportRepository(db *gorm.DB) UserDataExportRepository {
+	return &GormUserDataExportRepository{db: db}
+}
+
+// UserDataExportRepository represents the storage interface.
+type UserDataExportRepository interface {
+	Load(ctx context.Context, id uuid.UUID) (*UserDataExport, error)
+	LoadLatestForIdentity(ctx context.Context, identityID uuid.UUID) (*UserDataExport, error)
+	Create(ctx context.Context, export *UserDataExport) error
+	Save(ctx context.Context, export *UserDataExport) error
+	ListPending(ctx context.Context, limit int) ([]UserDataExport, error)
+	DeleteExpired(ctx context.Context, now time.Time) (int, error)
+}
+
+// Load returns a single export as a Database Model
+func (m *GormUserDataExportRepository) Load(ctx context.Context, id uuid.UUID) (*UserDataExport, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user_data_export", "load"}, time.Now())
+	var native UserDataExport
+	err := m.db.Where("user_data_export_id = ?", id).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("user_data_export", id.String())
+	}
+	return &native, errs.WithStack(err)
+}
+
+// LoadLatestForIdentity returns the most recent export of the given identity
+func (m *GormUserDataExportRepository) LoadLatestForIdentity(ctx context.Context, identityID uuid.UUID) (*UserDataExport, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user_data_export", "load_latest_for_identity"}, time.Now())
+	var native UserDataExport
+	err := m.db.Where("identity_id = ?", identityID).Order("created_at DESC").Limit(1).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundErrorWithKey("user_data_export", "identity_id", identityID.String())
+	}
+	return &native, errs.WithStack(err)
+}
+
+// Create creates a new record.
+func (m *GormUserDataExportRepository) Create(ctx context.Context, export *UserDataExport) error {
+	defer goa.MeasureSince([]string{"goa", "db", "user_data_export", "create"}, time.Now())
+	if export.UserDataExportID == uuid.Nil {
+		export.UserDataExportID = uuid.NewV4()
+	}
+	err := m.db.Create(export).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"user_data_export_id": export.UserDataExportID,
+			"identity_id":         export.IdentityID,
+			"err":                 err,
+		}, "unable to create the user data export")
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"user_data_export_id": export.UserDataExportID,
+		"identity_id":         export.IdentityID,
+	}, "user data export created!")
+	return nil
+}
+
+// Save modifies a single record.
+func (m *GormUserDataExportRepository) Save(ctx context.Context, export *UserDataExport) error {
+	defer goa.MeasureSince([]string{"goa", "db", "user_data_export", "save"}, time.Now())
+	obj, err := m.Load(ctx, export.UserDataExportID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"user_data_export_id": export.UserDataExportID,
+			"err":                 err,
+		}, "unable to update the user data export")
+		return errs.WithStack(err)
+	}
+	err = m.db.Model(obj).Updates(export).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"user_data_export_id": export.UserDataExportID,
+			"err":                 err,
+		}, "unable to update the user data export")
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"user_data_export_id": export.UserDataExportID,
+	}, "user data export saved!")
+	return nil
+}
+
+// ListPending returns the exports whose content has not been generated yet, the oldest ones first. The number of results
+// is capped by the given limit.
+func (m *GormUserDataExportRepository) ListPending(ctx context.Context, limit int) ([]UserDataExport, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user_data_export", "list_pending"}, time.Now())
+	var exports []UserDataExport
+	err := m.db.Where("status = ?", UserDataExportStatusPending).Order("created_at").Limit(limit).Find(&exports).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to list the pending user data exports")
+		return nil, errs.WithStack(err)
+	}
+	return exports, nil
+}
+
+// DeleteExpired removes the exports which expired before the given time, and returns the number of deleted records.
+// This is a hard delete!
+func (m *GormUserDataExportRepository) DeleteExpired(ctx context.Context, now time.Time) (int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user_data_export", "delete_expired"}, time.Now())
+	result := m.db.Where("expires_at < ?", now).Delete(&UserDataExport{})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": result.Error,
+		}, "unable to delete the expired user data exports")
+		return 0, errs.WithStack(result.Error)
+	}
+	return int(result.RowsAffected), nil
+}
@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	errs "github.com/pkg/errors"
+)
+
+const (
+	// UserProfileAttributeTypeString the values of the attribute are strings
+	UserProfileAttributeTypeString = "string"
+	// UserProfileAttributeTypeInteger the values of the attribute are integers
+	UserProfileAttributeTypeInteger = "integer"
+	// UserProfileAttributeTypeBoolean the values of the attribute are booleans
+	UserProfileAttributeTypeBoolean = "boolean"
+
+	// UserProfileAttributeVisibilityPublic the attribute is visible to everyone
+	UserProfileAttributeVisibilityPublic = "public"
+	// UserProfileAttributeVisibilityPrivate the attribute is only visible to the user and to the admins
+	UserProfileAttributeVisibilityPrivate = "private"
+	// UserProfileAttributeVisibilityAdmin the attribute is only visible to, and can only be set by the admins
+	UserProfileAttributeVisibilityAdmin = "admin"
+)
+
+// UserProfileAttributeTypes the known types of profile attributes
+var UserProfileAttributeTypes = []string{
+	UserProfileAttributeTypeString,
+	UserProfileAttributeTypeInteger,
+	UserProfileAttributeTypeBoolean,
+}
+
+// UserProfileAttributeVisibilities the known visibilities of profile attributes, from the least to the most restricted
+var UserProfileAttributeVisibilities = []string{
+	UserProfileAttributeVisibilityPublic,
+	UserProfileAttributeVisibilityPrivate,
+	UserProfileAttributeVisibilityAdmin,
+}
+
+// IsValidUserProfileAttributeType returns true if the given type is one of the known types of profile attributes
+func IsValidUserProfileAttributeType(attributeType string) bool {
+	for _, t := range UserProfileAttributeTypes {
+		if t == attributeType {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidUserProfileAttributeVisibility returns true if the given visibility is one of the known visibilities of
+// profile attributes
+func IsValidUserProfileAttributeVisibility(visibility string) bool {
+	return visibilityLevel(visibility) >= 0
+}
+
+// visibilityLevel returns the position of the given visibility in the list of known visibilities, or -1 if unknown
+func visibilityLevel(visibility string) int {
+	for i, v := range UserProfileAttributeVisibilities {
+		if v == visibility {
+			return i
+		}
+	}
+	return -1
+}
+
+// UserProfileAttribute the definition of a custom attribute of the user profiles, with the rules which its values
+// must follow. The values themselves are stored along with the users.
+type UserProfileAttribute struct {
+	gormsupport.LifecycleHardDelete
+	// Name the name of the attribute. This is the primary key value.
+	Name string `gorm:"primary_key;column:name"`
+	// the description of the attribute, displayed to the users
+	Description *string
+	// the type of the values
+	Type string
+	// who can see the values of the attribute
+	Visibility string
+	// whether the users can be searched by the values of the attribute
+	Indexable bool
+	// the regular expression which the string values must match
+	Pattern *string
+	// the minimum and maximum lengths of the string values
+	MinLength *int
+	MaxLength *int
+	// the minimum and maximum integer values
+	Minimum *int64
+	Maximum *int64
+	// the only values allowed for a string attribute, if not empty
+	AllowedValues pq.StringArray `sql:"type:text[]"`
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m UserProfileAttribute) TableName() string {
+	return "user_profile_attribute"
+}
+
+// IsVisibleTo returns true if the values of the attribute can be seen by someone with the given visibility, ie, the
+// `public` visibility for everyone, `private` for the user and `admin` for the admins
+func (m UserProfileAttribute) IsVisibleTo(visibility string) bool {
+	return visibilityLevel(m.Visibility) <= visibilityLevel(visibility)
+}
+
+// VisibleProfileAttributes returns the values of the given profile which can be seen by someone with the given
+// visibility. The values of the attributes which are not defined anymore are ignored.
+func VisibleProfileAttributes(attributes []UserProfileAttribute, values map[string]interface{}, visibility string) map[string]interface{} {
+	result := map[string]interface{}{}
+	for _, attribute := range attributes {
+		if value, found := values[attribute.Name]; found && value != nil && attribute.IsVisibleTo(visibility) {
+			result[attribute.Name] = value
+		}
+	}
+	return result
+}
+
+// GormUserProfileAttributeRepository is the implementation of the storage interface for UserProfileAttribute.
+type GormUserProfileAttributeRepository struct {
+	db *gorm.DB
+}
+
+// NewUserProfileAttributeRepository creates a new storage type.
+func NewUserProfileAttributeRepository(db *gorm.DB) UserProfileAttributeRepository {
+	return &GormUserProfileAttributeRepository{db: db}
+}
+
+// UserProfileAttributeRepository represents the storage interface.
+type UserProfileAttributeRepository interface {
+	Load(ctx context.Context, name string) (*UserProfileAttribute, error)
+	List(ctx context.Context) ([]UserProfileAttribute, error)
+	Create(ctx context.Context, attribute *UserProfileAttribute) error
+	Save(ctx context.Context, attribute *UserProfileAttribute) error
+	Delete(ctx context.Context, name string) error
+}
+
+// Load returns a single attribute definition as a Database Model
+func (m *GormUserProfileAttributeRepository) Load(ctx context.Context, name string) (*UserProfileAttribute, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user_profile_attribute", "load"}, time.Now())
+	var native UserProfileAttribute
+	err := m.db.Where("name = ?", name).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundErrorWithKey("user_profile_attribute", "name", name)
+	}
+	return &native, errs.WithStack(err)
+}
+
+// List returns all the attribute definitions, ordered by name
+func (m *GormUserProfileAttributeRepository) List(ctx context.Context) ([]UserProfileAttribute, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "user_profile_attribute", "list"}, time.Now())
+	var attributes []UserProfileAttribute
+	err := m.db.Order("name").Find(&attributes).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return attributes, nil
+}
+
+// Create creates a new record.
+func (m *GormUserProfileAttributeRepository) Create(ctx context.Context, attribute *UserProfileAttribute) error {
+	defer goa.MeasureSince([]string{"goa", "db", "user_profile_attribute", "create"}, time.Now())
+	err := m.db.Create(attribute).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"name": attribute.Name,
+			"err":  err,
+		}, "unable to create the user profile attribute")
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"name": attribute.Name,
+	}, "user profile attribute created!")
+	return nil
+}
+
+// Save modifies a single record, including the rules which are unset.
+func (m *GormUserProfileAttributeRepository) Save(ctx context.Context, attribute *UserProfileAttribute) error {
+	defer goa.MeasureSince([]string{"goa", "db", "user_profile_attribute", "save"}, time.Now())
+	_, err := m.Load(ctx, attribute.Name)
+	if err != nil {
+		return err
+	}
+	err = m.db.Save(attribute).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"name": attribute.Name,
+			"err":  err,
+		}, "unable to update the user profile attribute")
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"name": attribute.Name,
+	}, "user profile attribute saved!")
+	return nil
+}
+
+// Delete removes the definition of the attribute along with its values in the profiles of all the users
+func (m *GormUserProfileAttributeRepository) Delete(ctx context.Context, name string) error {
+	defer goa.MeasureSince([]string{"goa", "db", "user_profile_attribute", "delete"}, time.Now())
+	result := m.db.Where("name = ?", name).Delete(&UserProfileAttribute{})
+	if result.Error != nil {
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundErrorWithKey("user_profile_attribute", "name", name)
+	}
+	err := m.db.Exec("UPDATE users SET profile_attributes = profile_attributes - ? WHERE profile_attributes -> ? IS NOT NULL", name, name).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"name": name,
+			"err":  err,
+		}, "unable to remove the values of the user profile attribute")
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"name": name,
+	}, "user profile attribute deleted!")
+	return nil
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// UsernameHistory a previous username of an identity, recorded when the username was changed
+type UsernameHistory struct {
+	gormsupport.LifecycleHardDelete
+	// UsernameHistoryID the ID of the record. This is the primary key value.
+	UsernameHistoryID uuid.UUID `sql:"type:uuid" gorm:"primary_key;column:username_history_id"`
+	// the identity which used the username
+	IdentityID uuid.UUID `sql:"type:uuid"`
+	// the previous username
+	Username string
+	// the time until which the username cannot be taken by another identity
+	ReservedUntil time.Time
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m UsernameHistory) TableName() string {
+	return "identity_username_history"
+}
+
+// GormUsernameHistoryRepository is the implementation of the storage interface for UsernameHistory.
+type GormUsernameHistoryRepository struct {
+	db *gorm.DB
+}
+
+// NewUsernameHistoryRepository creates a new storage type.
+func NewUsernameHistoryRepository(db *gorm.DB) UsernameHistoryRepository {
+	return &GormUsernameHistoryRepository{db: db}
+}
+
+// UsernameHistoryRepository represents the storage interface.
+type UsernameHistoryRepository interface {
+	Create(ctx context.Context, history *UsernameHistory) error
+	ListForIdentity(ctx context.Context, identityID uuid.UUID) ([]UsernameHistory, error)
+	LoadReservation(ctx context.Context, username string, now time.Time) (*UsernameHistory, error)
+	DeleteForIdentity(ctx context.Context, identityID uuid.UUID, usernames ...string) error
+}
+
+// Create creates a new record.
+func (m *GormUsernameHistoryRepository) Create(ctx context.Context, history *UsernameHistory) error {
+	defer goa.MeasureSince([]string{"goa", "db", "identity_username_history", "create"}, time.Now())
+	if history.UsernameHistoryID == uuid.Nil {
+		history.UsernameHistoryID = uuid.NewV4()
+	}
+	err := m.db.Create(history).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": history.IdentityID,
+			"username":    history.Username,
+			"err":         err,
+		}, "unable to record the previous username")
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"identity_id": history.IdentityID,
+		"username":    history.Username,
+	}, "previous username recorded!")
+	return nil
+}
+
+// ListForIdentity returns the previous usernames of the given identity, the most recent first
+func (m *GormUsernameHistoryRepository) ListForIdentity(ctx context.Context, identityID uuid.UUID) ([]UsernameHistory, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "identity_username_history", "list_for_identity"}, time.Now())
+	var rows []UsernameHistory
+	err := m.db.Where("identity_id = ?", identityID).Order("created_at DESC").Find(&rows).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return rows, nil
+}
+
+// LoadReservation returns the most recent record which reserves the given username at the given time, or a
+// NotFoundError if the username is not reserved
+func (m *GormUsernameHistoryRepository) LoadReservation(ctx context.Context, username string, now time.Time) (*UsernameHistory, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "identity_username_history", "load_reservation"}, time.Now())
+	var native UsernameHistory
+	err := m.db.Where("username = ? AND reserved_until > ?", username, now).Order("created_at DESC").First(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundErrorWithKey("username reservation", "username", username)
+	}
+	return &native, errs.WithStack(err)
+}
+
+// DeleteForIdentity removes the given previous usernames of the given identity (eg, when the identity takes one of them
+// back), or all the previous usernames of the identity if none is given (eg, when the identity is deactivated)
+func (m *GormUsernameHistoryRepository) DeleteForIdentity(ctx context.Context, identityID uuid.UUID, usernames ...string) error {
+	defer goa.MeasureSince([]string{"goa", "db", "identity_username_history", "delete_for_identity"}, time.Now())
+	db := m.db.Where("identity_id = ?", identityID)
+	if len(usernames) > 0 {
+		db = db.Where("username IN (?)", usernames)
+	}
+	err := db.Delete(&UsernameHistory{}).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": identityID,
+			"usernames":   usernames,
+			"err":         err,
+		}, "unable to delete the previous usernames")
+		return errs.WithStack(err)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package repository_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type UsernameHistoryRepositoryTestSuite struct {
+	gormtestsupport.DBTestSuite
+}
+
+func TestUsernameHistoryRepository(t *testing.T) {
+	suite.Run(t, &UsernameHistoryRepositoryTestSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+// rename changes the username of the identity and records the previous one, reserved until the given time
+func (s *UsernameHistoryRepositoryTestSuite) rename(t *testing.T, identity *repository.Identity, username string, reservedUntil time.Time) {
+	err := s.Application.UsernameHistory().Create(s.Ctx, &repository.UsernameHistory{
+		IdentityID:    identity.ID,
+		Username:      identity.Username,
+		ReservedUntil: reservedUntil,
+	})
+	require.NoError(t, err)
+	identity.Username = username
+	err = s.Application.Identities().Save(s.Ctx, identity)
+	require.NoError(t, err)
+}
+
+func (s *UsernameHistoryRepositoryTestSuite) TestListForIdentity() {
+	// given
+	identity := s.Graph.CreateUser().Identity()
+	first := identity.Username
+	s.rename(s.T(), identity, "second-"+uuid.NewV4().String(), time.Now().Add(time.Hour))
+	second := identity.Username
+	s.rename(s.T(), identity, "third-"+uuid.NewV4().String(), time.Now().Add(time.Hour))
+	s.Graph.CreateUser() // another user, without previous usernames
+	// when
+	history, err := s.Application.UsernameHistory().ListForIdentity(s.Ctx, identity.ID)
+	// then
+	require.NoError(s.T(), err)
+	require.Len(s.T(), history, 2)
+	assert.Equal(s.T(), second, history[0].Username)
+	assert.Equal(s.T(), first, history[1].Username)
+}
+
+func (s *UsernameHistoryRepositoryTestSuite) TestLoadReservation() {
+
+	s.T().Run("reserved", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		previous := identity.Username
+		s.rename(t, identity, "renamed-"+uuid.NewV4().String(), time.Now().Add(time.Hour))
+		// when
+		reservation, err := s.Application.UsernameHistory().LoadReservation(s.Ctx, previous, time.Now())
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, identity.ID, reservation.IdentityID)
+	})
+
+	s.T().Run("reservation expired", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		previous := identity.Username
+		s.rename(t, identity, "renamed-"+uuid.NewV4().String(), time.Now().Add(-time.Hour))
+		// when
+		_, err := s.Application.UsernameHistory().LoadReservation(s.Ctx, previous, time.Now())
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, err)
+	})
+}
+
+func (s *UsernameHistoryRepositoryTestSuite) TestDeleteForIdentity() {
+
+	s.T().Run("given username", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		previous := identity.Username
+		s.rename(t, identity, "renamed-"+uuid.NewV4().String(), time.Now().Add(time.Hour))
+		kept := identity.Username
+		s.rename(t, identity, "renamed-"+uuid.NewV4().String(), time.Now().Add(time.Hour))
+		// when
+		err := s.Application.UsernameHistory().DeleteForIdentity(s.Ctx, identity.ID, previous)
+		// then
+		require.NoError(t, err)
+		history, err := s.Application.UsernameHistory().ListForIdentity(s.Ctx, identity.ID)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.Equal(t, kept, history[0].Username)
+	})
+
+	s.T().Run("all usernames", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		s.rename(t, identity, "renamed-"+uuid.NewV4().String(), time.Now().Add(time.Hour))
+		s.rename(t, identity, "renamed-"+uuid.NewV4().String(), time.Now().Add(time.Hour))
+		// when
+		err := s.Application.UsernameHistory().DeleteForIdentity(s.Ctx, identity.ID)
+		// then
+		require.NoError(t, err)
+		history, err := s.Application.UsernameHistory().ListForIdentity(s.Ctx, identity.ID)
+		require.NoError(t, err)
+		assert.Empty(t, history)
+	})
+}
+
+func (s *UsernameHistoryRepositoryTestSuite) TestIdentityFilterByUsername() {
+
+	s.T().Run("current username", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		// when
+		identities, err := s.Application.Identities().Query(repository.IdentityFilterByUsername(identity.Username))
+		// then
+		require.NoError(t, err)
+		require.Len(t, identities, 1)
+		assert.Equal(t, identity.ID, identities[0].ID)
+	})
+
+	s.T().Run("previous username", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		previous := identity.Username
+		s.rename(t, identity, "renamed-"+uuid.NewV4().String(), time.Now().Add(time.Hour))
+		// when
+		identities, err := s.Application.Identities().Query(repository.IdentityFilterByUsername(previous), repository.IdentityFilterByProviderType(repository.DefaultIDP))
+		// then
+		require.NoError(t, err)
+		require.Len(t, identities, 1)
+		assert.Equal(t, identity.ID, identities[0].ID)
+		// the current username only matches the current username
+		identities, err = s.Application.Identities().Query(repository.IdentityFilterByCurrentUsername(previous))
+		require.NoError(t, err)
+		assert.Empty(t, identities)
+	})
+
+	s.T().Run("previous username taken by another identity", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		previous := identity.Username
+		s.rename(t, identity, "renamed-"+uuid.NewV4().String(), time.Now().Add(-time.Hour))
+		other := s.Graph.CreateUser().Identity()
+		other.Username = previous
+		err := s.Application.Identities().Save(s.Ctx, other)
+		require.NoError(t, err)
+		// when
+		identities, err := s.Application.Identities().Query(repository.IdentityFilterByUsername(previous))
+		// then
+		require.NoError(t, err)
+		require.Len(t, identities, 1)
+		assert.Equal(t, other.ID, identities[0].ID)
+	})
+
+	s.T().Run("previous username of several identities", func(t *testing.T) {
+		// given
+		username := "shared-" + uuid.NewV4().String()
+		identity1 := s.Graph.CreateUser().Identity()
+		identity1.Username = username
+		require.NoError(t, s.Application.Identities().Save(s.Ctx, identity1))
+		s.rename(t, identity1, "renamed-"+uuid.NewV4().String(), time.Now().Add(-time.Hour))
+		identity2 := s.Graph.CreateUser().Identity()
+		identity2.Username = username
+		require.NoError(t, s.Application.Identities().Save(s.Ctx, identity2))
+		s.rename(t, identity2, "renamed-"+uuid.NewV4().String(), time.Now().Add(time.Hour))
+		// when
+		identities, err := s.Application.Identities().Query(repository.IdentityFilterByUsername(username))
+		// then the last identity which used the username matches
+		require.NoError(t, err)
+		require.Len(t, identities, 1)
+		assert.Equal(t, identity2.ID, identities[0].ID)
+	})
+}
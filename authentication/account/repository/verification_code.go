@@ -22,6 +22,13 @@ type VerificationCode struct {
 	UserID uuid.UUID `sql:"type:uuid"`
 
 	Code string
+	// the time after which the code cannot be used anymore, or nil if the code does not expire
+	ExpiresAt *time.Time
+}
+
+// Expired returns true if the code expired at the given time
+func (m VerificationCode) Expired(now time.Time) bool {
+	return m.ExpiresAt != nil && !m.ExpiresAt.After(now)
 }
 
 // TableName overrides the table name settings in Gorm to force a specific table name
@@ -50,6 +57,7 @@ type VerificationCodeRepository interface {
 	Save(ctx context.Context, VerificationCode *VerificationCode) error
 	Delete(ctx context.Context, id uuid.UUID) error
 	Query(funcs ...func(*gorm.DB) *gorm.DB) ([]VerificationCode, error)
+	CountCreatedSince(ctx context.Context, userID uuid.UUID, since time.Time) (int, error)
 }
 
 // TableName overrides the table name settings in Gorm to force a specific table name
@@ -166,6 +174,22 @@ func (m *GormVerificationCodeRepository) Query(funcs ...func(*gorm.DB) *gorm.DB)
 	return verificationCodes, nil
 }
 
+// CountCreatedSince returns the number of codes created for the given user since the given time, including the codes
+// which were already verified
+func (m *GormVerificationCodeRepository) CountCreatedSince(ctx context.Context, userID uuid.UUID, since time.Time) (int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "VerificationCode", "count_created_since"}, time.Now())
+	var count int
+	err := m.db.Unscoped().Table(m.TableName()).Where("user_id = ? AND created_at >= ?", userID, since).Count(&count).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"user_id": userID,
+			"err":     err,
+		}, "unable to count the verification codes")
+		return 0, errs.WithStack(err)
+	}
+	return count, nil
+}
+
 // VerificationCodeFilterByUserID is a gorm filter for a Belongs To relationship.
 func VerificationCodeFilterByUserID(userID uuid.UUID) func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
@@ -3,6 +3,7 @@ package repository_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
 	"github.com/fabric8-services/fabric8-auth/errors"
@@ -55,6 +56,33 @@ func (s *verificationCodeBlackboxTest) TestVerificationCodeOKToDelete() {
 	require.Error(s.T(), err, errors.NotFoundError{})
 }
 
+func (s *verificationCodeBlackboxTest) TestCountCreatedSince() {
+	// given
+	verificationCode := createAndLoadVerificationCode(s)
+	other := repository.VerificationCode{
+		Code:   uuid.NewV4().String(),
+		UserID: verificationCode.UserID,
+		User:   verificationCode.User,
+	}
+	err := s.repo.Create(s.Ctx, &other)
+	require.NoError(s.T(), err)
+	// the verified codes are still counted
+	err = s.repo.Delete(s.Ctx, other.ID)
+	require.NoError(s.T(), err)
+
+	s.T().Run("all codes", func(t *testing.T) {
+		count, err := s.repo.CountCreatedSince(s.Ctx, verificationCode.UserID, verificationCode.CreatedAt.Add(-time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+
+	s.T().Run("no recent code", func(t *testing.T) {
+		count, err := s.repo.CountCreatedSince(s.Ctx, verificationCode.UserID, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+}
+
 func createAndLoadVerificationCode(s *verificationCodeBlackboxTest) *repository.VerificationCode {
 
 	identity, err := test.CreateTestIdentityAndUser(s.DB, uuid.NewV4().String(), "kc")
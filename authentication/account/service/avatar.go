@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/fabric8-services/fabric8-auth/application/service"
+	"github.com/fabric8-services/fabric8-auth/application/service/base"
+	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/avatar"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/blob"
+	authclient "github.com/fabric8-services/fabric8-auth/client"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/rest"
+
+	"github.com/goadesign/goa"
+	uuid "github.com/satori/go.uuid"
+)
+
+// NewAvatarService creates a new service to manage the avatars uploaded by the users
+func NewAvatarService(ctx servicecontext.ServiceContext, config AvatarServiceConfiguration) service.AvatarService {
+	return &avatarServiceImpl{
+		BaseService: base.NewBaseService(ctx),
+		config:      config,
+	}
+}
+
+// AvatarServiceConfiguration the configuration for the Avatar service
+type AvatarServiceConfiguration interface {
+	blob.Configuration
+	GetAvatarMaxSize() int64
+	GetAvatarMaxDimension() int
+}
+
+// avatarServiceImpl implements the AvatarService to manage the avatars uploaded by the users
+type avatarServiceImpl struct {
+	base.BaseService
+	config AvatarServiceConfiguration
+}
+
+// avatarKey returns the key of the image of the given avatar in the given size in the blob storage
+func avatarKey(avatarID uuid.UUID, size int) string {
+	return "avatars/" + avatarID.String() + "/" + strconv.Itoa(size) + ".png"
+}
+
+// Upload validates the uploaded image, stores it resized in all the standard sizes and makes it the avatar of the
+// user of the given identity. The image URL of the user then points at the new avatar, and the previous avatar is
+// deleted. Each upload has a new ID, so that the images of an avatar never change and can be cached.
+func (s *avatarServiceImpl) Upload(ctx context.Context, req *goa.RequestData, identityID uuid.UUID, data []byte) (*repository.Identity, error) {
+	images, err := avatar.Process(data, s.config.GetAvatarMaxSize(), s.config.GetAvatarMaxDimension())
+	if err != nil {
+		return nil, err
+	}
+	store, err := blob.NewStore(s.config)
+	if err != nil {
+		return nil, err
+	}
+	avatarID := uuid.NewV4()
+	for size, image := range images {
+		err := store.Put(ctx, avatarKey(avatarID, size), blob.Object{
+			Data:        image,
+			ContentType: avatar.ContentType,
+		})
+		if err != nil {
+			s.deleteImages(ctx, store, avatarID)
+			return nil, err
+		}
+	}
+
+	var identity *repository.Identity
+	var previousAvatarID *uuid.UUID
+	err = s.ExecuteInTransaction(func() error {
+		identity, err = s.Repositories().Identities().LoadWithUser(ctx, identityID)
+		if err != nil {
+			return err
+		}
+		previousAvatarID = identity.User.AvatarID
+		identity.User.AvatarID = &avatarID
+		identity.User.ImageURL = rest.AbsoluteURL(req, authclient.ShowAvatarsPath(avatarID.String()), nil)
+		return s.Repositories().Users().Save(ctx, &identity.User)
+	})
+	if err != nil {
+		s.deleteImages(ctx, store, avatarID)
+		return nil, err
+	}
+	if previousAvatarID != nil {
+		s.deleteImages(ctx, store, *previousAvatarID)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identity_id": identityID,
+		"avatar_id":   avatarID,
+	}, "avatar uploaded")
+	return identity, nil
+}
+
+// Delete removes the avatar of the user of the given identity, along with her image URL. Returns a NotFoundError if
+// the user has no avatar.
+func (s *avatarServiceImpl) Delete(ctx context.Context, identityID uuid.UUID) (*repository.Identity, error) {
+	var identity *repository.Identity
+	var avatarID uuid.UUID
+	err := s.ExecuteInTransaction(func() error {
+		var err error
+		identity, err = s.Repositories().Identities().LoadWithUser(ctx, identityID)
+		if err != nil {
+			return err
+		}
+		if identity.User.AvatarID == nil {
+			return errors.NewNotFoundErrorWithKey("avatar", "identity_id", identityID.String())
+		}
+		avatarID = *identity.User.AvatarID
+		identity.User.AvatarID = nil
+		identity.User.ImageURL = ""
+		return s.Repositories().Users().Save(ctx, &identity.User)
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = s.DeleteImages(ctx, avatarID)
+	if err != nil {
+		return nil, err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identity_id": identityID,
+		"avatar_id":   avatarID,
+	}, "avatar deleted")
+	return identity, nil
+}
+
+// Load returns the image of the given avatar in the given size, which must be one of the standard sizes
+func (s *avatarServiceImpl) Load(ctx context.Context, avatarID uuid.UUID, size int) (*blob.Object, error) {
+	if !avatar.IsValidSize(size) {
+		return nil, errors.NewBadParameterError("size", size).Expected(fmt.Sprintf("one of %v", avatar.Sizes))
+	}
+	store, err := blob.NewStore(s.config)
+	if err != nil {
+		return nil, err
+	}
+	object, err := store.Get(ctx, avatarKey(avatarID, size))
+	if err != nil {
+		if notFound, _ := errors.IsNotFoundError(err); notFound {
+			return nil, errors.NewNotFoundError("avatar", avatarID.String())
+		}
+		return nil, err
+	}
+	return object, nil
+}
+
+// DeleteImages deletes the images of the given avatar from the blob storage, in all the standard sizes
+func (s *avatarServiceImpl) DeleteImages(ctx context.Context, avatarID uuid.UUID) error {
+	store, err := blob.NewStore(s.config)
+	if err != nil {
+		return err
+	}
+	for _, size := range avatar.Sizes {
+		err := store.Delete(ctx, avatarKey(avatarID, size))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteImages deletes the images of the given avatar which are not used anymore. The failures are only logged, so that
+// they do not hide the outcome of the upload.
+func (s *avatarServiceImpl) deleteImages(ctx context.Context, store blob.Store, avatarID uuid.UUID) {
+	for _, size := range avatar.Sizes {
+		err := store.Delete(ctx, avatarKey(avatarID, size))
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"avatar_id": avatarID,
+				"size":      size,
+				"err":       err,
+			}, "unable to delete the image of the avatar")
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/application/service"
+	"github.com/fabric8-services/fabric8-auth/application/service/factory"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/avatar"
+	userservice "github.com/fabric8-services/fabric8-auth/authentication/account/service"
+	"github.com/fabric8-services/fabric8-auth/configuration"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	testsupport "github.com/fabric8-services/fabric8-auth/test"
+
+	"github.com/goadesign/goa"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestAvatarService(t *testing.T) {
+	suite.Run(t, &avatarServiceBlackboxTestSuite{
+		DBTestSuite: gormtestsupport.NewDBTestSuite(),
+	})
+}
+
+type avatarServiceBlackboxTestSuite struct {
+	gormtestsupport.DBTestSuite
+	dir string
+	svc service.AvatarService
+	req *goa.RequestData
+}
+
+// avatarConfig stores the avatars in a temporary directory
+type avatarConfig struct {
+	*configuration.ConfigurationData
+	dir string
+}
+
+func (c avatarConfig) GetBlobStorage() string {
+	return "filesystem"
+}
+
+func (c avatarConfig) GetBlobStorageDirectory() string {
+	return c.dir
+}
+
+func (s *avatarServiceBlackboxTestSuite) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	dir, err := ioutil.TempDir("", "avatars")
+	require.NoError(s.T(), err)
+	s.dir = dir
+	config := avatarConfig{ConfigurationData: s.Configuration, dir: dir}
+	s.svc = userservice.NewAvatarService(factory.NewServiceContext(s.Application, s.Application, nil, nil), config)
+	s.req = &goa.RequestData{
+		Request: &http.Request{Host: "auth.example.com"},
+	}
+}
+
+func (s *avatarServiceBlackboxTestSuite) TearDownTest() {
+	os.RemoveAll(s.dir)
+	s.DBTestSuite.TearDownTest()
+}
+
+func (s *avatarServiceBlackboxTestSuite) TestUpload() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		// when
+		result, err := s.svc.Upload(context.Background(), s.req, identity.ID, testsupport.NewPNGImage(t, 300, 200))
+		// then
+		require.NoError(t, err)
+		require.NotNil(t, result.User.AvatarID)
+		assert.Equal(t, "http://auth.example.com/api/avatars/"+result.User.AvatarID.String(), result.User.ImageURL)
+		user, err := s.Application.Users().Load(context.Background(), identity.User.ID)
+		require.NoError(t, err)
+		require.NotNil(t, user.AvatarID)
+		assert.Equal(t, *result.User.AvatarID, *user.AvatarID)
+		assert.Equal(t, result.User.ImageURL, user.ImageURL)
+		// the image is available in all the standard sizes
+		for _, size := range avatar.Sizes {
+			object, err := s.svc.Load(context.Background(), *user.AvatarID, size)
+			require.NoError(t, err)
+			assert.Equal(t, avatar.ContentType, object.ContentType)
+			config, err := png.DecodeConfig(bytes.NewReader(object.Data))
+			require.NoError(t, err)
+			assert.Equal(t, size, config.Width)
+			assert.Equal(t, size, config.Height)
+		}
+	})
+
+	s.T().Run("replaces the previous avatar", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		previous, err := s.svc.Upload(context.Background(), s.req, identity.ID, testsupport.NewPNGImage(t, 64, 64))
+		require.NoError(t, err)
+		previousAvatarID := *previous.User.AvatarID
+		// when
+		result, err := s.svc.Upload(context.Background(), s.req, identity.ID, testsupport.NewPNGImage(t, 128, 128))
+		// then
+		require.NoError(t, err)
+		assert.NotEqual(t, previousAvatarID, *result.User.AvatarID)
+		_, err = s.svc.Load(context.Background(), *result.User.AvatarID, avatar.DefaultSize)
+		require.NoError(t, err)
+		_, err = s.svc.Load(context.Background(), previousAvatarID, avatar.DefaultSize)
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+
+	s.T().Run("invalid image", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		// when
+		_, err := s.svc.Upload(context.Background(), s.req, identity.ID, []byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"))
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		user, err := s.Application.Users().Load(context.Background(), identity.User.ID)
+		require.NoError(t, err)
+		assert.Nil(t, user.AvatarID)
+		assert.Equal(t, identity.User.ImageURL, user.ImageURL)
+	})
+
+	s.T().Run("unknown identity", func(t *testing.T) {
+		// when
+		_, err := s.svc.Upload(context.Background(), s.req, uuid.NewV4(), testsupport.NewPNGImage(t, 64, 64))
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}
+
+func (s *avatarServiceBlackboxTestSuite) TestDelete() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		uploaded, err := s.svc.Upload(context.Background(), s.req, identity.ID, testsupport.NewPNGImage(t, 64, 64))
+		require.NoError(t, err)
+		avatarID := *uploaded.User.AvatarID
+		// when
+		result, err := s.svc.Delete(context.Background(), identity.ID)
+		// then
+		require.NoError(t, err)
+		assert.Nil(t, result.User.AvatarID)
+		assert.Empty(t, result.User.ImageURL)
+		user, err := s.Application.Users().Load(context.Background(), identity.User.ID)
+		require.NoError(t, err)
+		assert.Nil(t, user.AvatarID)
+		assert.Empty(t, user.ImageURL)
+		for _, size := range avatar.Sizes {
+			_, err := s.svc.Load(context.Background(), avatarID, size)
+			require.Error(t, err)
+			assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+		}
+	})
+
+	s.T().Run("no avatar", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		// when
+		_, err := s.svc.Delete(context.Background(), identity.ID)
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}
+
+func (s *avatarServiceBlackboxTestSuite) TestLoad() {
+
+	s.T().Run("invalid size", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		uploaded, err := s.svc.Upload(context.Background(), s.req, identity.ID, testsupport.NewPNGImage(t, 64, 64))
+		require.NoError(t, err)
+		// when
+		_, err = s.svc.Load(context.Background(), *uploaded.User.AvatarID, 100)
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+	})
+
+	s.T().Run("unknown avatar", func(t *testing.T) {
+		// when
+		_, err := s.svc.Load(context.Background(), uuid.NewV4(), avatar.DefaultSize)
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}
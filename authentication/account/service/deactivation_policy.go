@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/account"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization"
+
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+)
+
+// defaultInactiveIdentitiesPageSize the number of inactive identities to load at once when the fetch limit is ignored
+const defaultInactiveIdentitiesPageSize = 100
+
+// deactivationSchedule the inactivity period and the reminders which apply to an identity
+type deactivationSchedule struct {
+	// the policy which selected the identity, or nil if the global settings apply
+	policy *account.DeactivationPolicy
+	// the duration of inactivity after which the account is deactivated
+	inactivityPeriod time.Duration
+	// the durations before the deactivation at which the user is reminded, the furthest first
+	reminders []time.Duration
+}
+
+// policyName returns the name of the policy which selected the identity, or an empty string if the global settings apply
+func (d deactivationSchedule) policyName() string {
+	if d.policy == nil {
+		return ""
+	}
+	return d.policy.Name
+}
+
+// scheduledIdentity an identity along with the deactivation schedule which applies to it
+type scheduledIdentity struct {
+	identity repository.Identity
+	schedule deactivationSchedule
+}
+
+// defaultDeactivationSchedule returns the schedule defined by the global settings: a single reminder sent after the
+// notification period of inactivity
+func (s *userServiceImpl) defaultDeactivationSchedule() deactivationSchedule {
+	inactivityPeriod := s.config.GetUserDeactivationInactivityPeriod()
+	return deactivationSchedule{
+		inactivityPeriod: inactivityPeriod,
+		reminders:        []time.Duration{inactivityPeriod - s.config.GetUserDeactivationInactivityNotificationPeriod()},
+	}
+}
+
+// deactivationScheduleFor returns the schedule defined by the first policy which selects the given identity, or the default
+// schedule if no policy selects it. Returns false if the identity is exempt from deactivation.
+func (s *userServiceImpl) deactivationScheduleFor(ctx context.Context, identity repository.Identity) (deactivationSchedule, bool, error) {
+	for _, username := range s.config.GetUserDeactivationWhiteList() {
+		if username == identity.Username {
+			return deactivationSchedule{}, false, nil
+		}
+	}
+	policies := s.config.GetUserDeactivationPolicies()
+	subject := account.DeactivationPolicySubject{
+		FeatureLevel: identity.User.FeatureLevel,
+		Email:        identity.User.Email,
+		Cluster:      identity.User.Cluster,
+	}
+	// only load the memberships and the roles of the identity if some policies need them
+	for _, p := range policies {
+		if len(p.Organizations) > 0 && subject.Organizations == nil {
+			resourceType := authorization.IdentityResourceTypeOrganization
+			memberships, err := s.Repositories().Identities().FindIdentityMemberships(ctx, identity.ID, &resourceType)
+			if err != nil {
+				return deactivationSchedule{}, false, errs.Wrapf(err, "unable to load the organizations of identity '%s'", identity.ID)
+			}
+			subject.Organizations = []string{}
+			for _, m := range memberships {
+				subject.Organizations = append(subject.Organizations, m.ResourceName)
+			}
+		}
+		if len(p.Roles) > 0 && subject.Roles == nil {
+			associations, err := s.Repositories().IdentityRoleRepository().FindIdentityRolesForIdentity(ctx, identity.ID, nil)
+			if err != nil {
+				return deactivationSchedule{}, false, errs.Wrapf(err, "unable to load the roles of identity '%s'", identity.ID)
+			}
+			subject.Roles = []string{}
+			for _, a := range associations {
+				subject.Roles = append(subject.Roles, a.Roles...)
+			}
+		}
+	}
+	for i := range policies {
+		p := policies[i]
+		if !p.Matches(subject) {
+			continue
+		}
+		if p.Exempt {
+			return deactivationSchedule{}, false, nil
+		}
+		return deactivationSchedule{
+			policy:           &p,
+			inactivityPeriod: p.InactivityPeriod(),
+			reminders:        p.Reminders(),
+		}, true, nil
+	}
+	return s.defaultDeactivationSchedule(), true, nil
+}
+
+// sentReminders returns the number of reminders which were sent to the user since her last activity
+func sentReminders(identity repository.Identity) int {
+	if identity.DeactivationNotification == nil {
+		return 0
+	}
+	if identity.DeactivationReminders == 0 {
+		// notified before the reminders were counted
+		return 1
+	}
+	return identity.DeactivationReminders
+}
+
+// reminderDue returns true if the next reminder of the given schedule should be sent to the user
+func (d deactivationSchedule) reminderDue(identity repository.Identity, now time.Time) bool {
+	sent := sentReminders(identity)
+	if sent >= len(d.reminders) {
+		return false
+	}
+	if sent == 0 {
+		// the first reminder is sent when the user has been inactive for long enough
+		return identity.LastActive != nil && identity.LastActive.Before(now.Add(d.reminders[0]-d.inactivityPeriod))
+	}
+	// the next reminders are sent relatively to the deactivation scheduled along with the first reminder
+	return identity.DeactivationScheduled != nil && !now.Before(identity.DeactivationScheduled.Add(-d.reminders[sent]))
+}
+
+// deactivationDue returns true if all the reminders of the given schedule were sent and the deactivation is due
+func (d deactivationSchedule) deactivationDue(identity repository.Identity, now time.Time) bool {
+	return sentReminders(identity) >= len(d.reminders) &&
+		identity.DeactivationScheduled != nil && identity.DeactivationScheduled.Before(now)
+}
+
+// schedules returns the schedules of the deactivation policies which do not exempt the users, along with the default
+// schedule which applies to the users selected by none of the policies
+func (s *userServiceImpl) schedules() []deactivationSchedule {
+	schedules := []deactivationSchedule{s.defaultDeactivationSchedule()}
+	for _, p := range s.config.GetUserDeactivationPolicies() {
+		if p.Exempt {
+			continue
+		}
+		schedules = append(schedules, deactivationSchedule{
+			inactivityPeriod: p.InactivityPeriod(),
+			reminders:        p.Reminders(),
+		})
+	}
+	return schedules
+}
+
+// listIdentitiesToRemind returns the identities for which the next reminder of their schedule is due. The identities
+// which cannot be due whatever the schedule which applies to them are excluded in the DB.
+func (s *userServiceImpl) listIdentitiesToRemind(ctx context.Context, now time.Time) ([]scheduledIdentity, error) {
+	// the reminders after the first one are sent relatively to the scheduled deactivation, the furthest one first
+	maxReminders := 0
+	var maxNextReminder time.Duration
+	for _, schedule := range s.schedules() {
+		if len(schedule.reminders) > maxReminders {
+			maxReminders = len(schedule.reminders)
+		}
+		if len(schedule.reminders) > 1 && schedule.reminders[1] > maxNextReminder {
+			maxNextReminder = schedule.reminders[1]
+		}
+	}
+	return s.listScheduledIdentities(ctx, now, repository.IdentityFilterByReminderDue(maxReminders, now.Add(maxNextReminder)), deactivationSchedule.reminderDue)
+}
+
+// listIdentitiesToDeactivateWithPolicies returns the identities which received all the reminders of their schedule and
+// whose deactivation is due. The identities which cannot be due whatever the schedule which applies to them are
+// excluded in the DB.
+func (s *userServiceImpl) listIdentitiesToDeactivateWithPolicies(ctx context.Context, now time.Time) ([]scheduledIdentity, error) {
+	minReminders := 0
+	for i, schedule := range s.schedules() {
+		if i == 0 || len(schedule.reminders) < minReminders {
+			minReminders = len(schedule.reminders)
+		}
+	}
+	return s.listScheduledIdentities(ctx, now, repository.IdentityFilterByDeactivationDue(minReminders, now), deactivationSchedule.deactivationDue)
+}
+
+// listScheduledIdentities evaluates the deactivation policies on the inactive identities matching the given filter,
+// and returns the ones for which the given predicate is true. The result size is limited by the fetch limit of the
+// configuration.
+func (s *userServiceImpl) listScheduledIdentities(ctx context.Context, now time.Time, filter func(*gorm.DB) *gorm.DB, due func(deactivationSchedule, repository.Identity) bool) ([]scheduledIdentity, error) {
+	// the users who have been inactive for a shorter time than the earliest first reminder of all policies are not considered
+	var threshold time.Duration
+	for i, schedule := range s.schedules() {
+		if t := schedule.inactivityPeriod - schedule.reminders[0]; i == 0 || t < threshold {
+			threshold = t
+		}
+	}
+	// a negative limit is ignored
+	limit := s.config.GetUserDeactivationFetchLimit()
+	pageSize := limit
+	if limit <= 0 {
+		pageSize = defaultInactiveIdentitiesPageSize
+	}
+	result := []scheduledIdentity{}
+	var after *repository.Identity
+	for limit <= 0 || len(result) < limit {
+		identities, err := s.Repositories().Identities().ListInactiveIdentities(ctx, now.Add(-threshold), after, pageSize, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, identity := range identities {
+			schedule, applies, err := s.deactivationScheduleFor(ctx, identity)
+			if err != nil {
+				return nil, err
+			}
+			if applies && due(schedule, identity) {
+				result = append(result, scheduledIdentity{identity: identity, schedule: schedule})
+				if len(result) == limit {
+					break
+				}
+			}
+		}
+		if len(identities) < pageSize {
+			break
+		}
+		after = &identities[len(identities)-1]
+	}
+	return result, nil
+}
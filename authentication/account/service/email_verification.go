@@ -2,9 +2,10 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/fabric8-services/fabric8-auth/application"
-	"github.com/fabric8-services/fabric8-auth/application/service"
 	"github.com/fabric8-services/fabric8-auth/application/transaction"
 	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
 	authclient "github.com/fabric8-services/fabric8-auth/client"
@@ -14,63 +15,231 @@ import (
 	"github.com/fabric8-services/fabric8-auth/rest"
 
 	"github.com/goadesign/goa"
+	errs "github.com/pkg/errors"
 	"github.com/satori/go.uuid"
 )
 
 type EmailVerificationService interface {
 	SendVerificationCode(ctx context.Context, req *goa.RequestData, identity repository.Identity) (*repository.VerificationCode, error)
 	VerifyCode(ctx context.Context, code string) (*repository.VerificationCode, error)
+	RequestEmailChange(ctx context.Context, req *goa.RequestData, identity repository.Identity, email string) (*repository.PendingEmailChange, error)
+	CancelEmailChange(ctx context.Context, code string) (*repository.PendingEmailChange, error)
+}
+
+// EmailVerificationConfiguration the configuration of the email verification
+type EmailVerificationConfiguration interface {
+	GetEmailVerificationCodeExpiry() time.Duration
+	GetEmailVerificationRateLimit() int
+	GetEmailVerificationRateLimitPeriod() time.Duration
 }
 
 type EmailVerificationClient struct {
-	app          application.Application
-	notification service.NotificationService
+	app    application.Application
+	config EmailVerificationConfiguration
 }
 
 // NewEmailVerificationClient creates a new client for managing email verification.
-func NewEmailVerificationClient(app application.Application) *EmailVerificationClient {
+func NewEmailVerificationClient(app application.Application, config EmailVerificationConfiguration) *EmailVerificationClient {
 	return &EmailVerificationClient{
-		app:          app,
-		notification: app.NotificationService(),
+		app:    app,
+		config: config,
 	}
 }
 
-// SendVerificationCode generates and sends out an email with verification code.
+// SendVerificationCode generates and sends out an email with verification code. If the user has a pending change of
+// her email address, the code is sent to the new address to confirm the change, and replaces the previous code.
+// The number of codes sent to a user is limited over the configured period.
 func (c *EmailVerificationClient) SendVerificationCode(ctx context.Context, req *goa.RequestData, identity repository.Identity) (*repository.VerificationCode, error) {
 
 	generatedCode := uuid.NewV4().String()
+	expiresAt := time.Now().Add(c.config.GetEmailVerificationCodeExpiry())
 	newVerificationCode := repository.VerificationCode{
-		User: identity.User,
-		Code: generatedCode,
+		User:      identity.User,
+		Code:      generatedCode,
+		ExpiresAt: &expiresAt,
+	}
+	var pendingChange *repository.PendingEmailChange
+
+	err := transaction.Transactional(c.app, func(tr transaction.TransactionalResources) error {
+		err := c.checkRateLimit(ctx, tr, identity.User.ID)
+		if err != nil {
+			return err
+		}
+		err = tr.VerificationCodes().Create(ctx, &newVerificationCode)
+		if err != nil {
+			return err
+		}
+		pendingChange, err = c.loadPendingChange(ctx, tr, identity.User.ID)
+		if err != nil {
+			return err
+		}
+		notificationCustomAttributes := map[string]interface{}{
+			"verifyURL": c.generateVerificationURL(ctx, req, generatedCode),
+		}
+		if pendingChange != nil {
+			// the previous code sent to the new address cannot be used anymore
+			err = tr.VerificationCodes().Delete(ctx, *pendingChange.VerificationCodeID)
+			if err != nil {
+				return err
+			}
+			pendingChange.VerificationCodeID = &newVerificationCode.ID
+			pendingChange.ExpiresAt = expiresAt
+			err = tr.PendingEmailChanges().Save(ctx, pendingChange)
+			if err != nil {
+				return err
+			}
+			notificationCustomAttributes["userEmail"] = pendingChange.NewEmail
+		}
+		return enqueueNotification(ctx, tr, identity.ID, notification.NewUserEmailUpdated(identity.ID.String(), notificationCustomAttributes))
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	email := identity.User.Email
+	if pendingChange != nil {
+		email = pendingChange.NewEmail
+	}
 	log.Info(ctx, map[string]interface{}{
-		"email": identity.User.Email,
+		"email": email,
 	}, "verification code to be sent")
 
+	return &newVerificationCode, err
+}
+
+// RequestEmailChange records a pending change of the email address of the user, which replaces any previous one.
+// A verification code is sent to the new address to confirm the change, and a code to cancel it is sent to the
+// current address. The email address of the user does not change until the change is confirmed. A new change cannot
+// be requested while a confirmed change can still be reverted.
+func (c *EmailVerificationClient) RequestEmailChange(ctx context.Context, req *goa.RequestData, identity repository.Identity, email string) (*repository.PendingEmailChange, error) {
+	expiresAt := time.Now().Add(c.config.GetEmailVerificationCodeExpiry())
+	verificationCode := repository.VerificationCode{
+		User:      identity.User,
+		Code:      uuid.NewV4().String(),
+		ExpiresAt: &expiresAt,
+	}
+	change := repository.PendingEmailChange{
+		UserID:     identity.User.ID,
+		NewEmail:   email,
+		CancelCode: uuid.NewV4().String(),
+		ExpiresAt:  expiresAt,
+	}
+
 	err := transaction.Transactional(c.app, func(tr transaction.TransactionalResources) error {
-		err := tr.VerificationCodes().Create(ctx, &newVerificationCode)
-		return err
+		err := c.checkRateLimit(ctx, tr, identity.User.ID)
+		if err != nil {
+			return err
+		}
+		err = c.deletePendingChange(ctx, tr, identity.User.ID)
+		if err != nil {
+			return err
+		}
+		err = tr.VerificationCodes().Create(ctx, &verificationCode)
+		if err != nil {
+			return err
+		}
+		change.VerificationCodeID = &verificationCode.ID
+		err = tr.PendingEmailChanges().Create(ctx, &change)
+		if err != nil {
+			return err
+		}
+		err = enqueueNotification(ctx, tr, identity.ID, notification.NewUserEmailUpdated(identity.ID.String(), map[string]interface{}{
+			"verifyURL": c.generateVerificationURL(ctx, req, verificationCode.Code),
+			"userEmail": email,
+		}))
+		if err != nil {
+			return err
+		}
+		return enqueueNotification(ctx, tr, identity.ID, notification.NewUserEmailChangeRequestedEmail(identity.ID.String(),
+			identity.User.Email, email, c.generateCancelURL(ctx, req, change.CancelCode)))
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	notificationCustomAttributes := map[string]interface{}{
-		"verifyURL": c.generateVerificationURL(ctx, req, generatedCode),
-	}
-
-	emailMessage := notification.NewUserEmailUpdated(identity.ID.String(), notificationCustomAttributes)
-	c.notification.SendMessageAsync(ctx, emailMessage)
+	log.Info(ctx, map[string]interface{}{
+		"identity_id": identity.ID.String(),
+		"email":       email,
+	}, "email change requested")
 
-	return &newVerificationCode, err
+	return &change, nil
 }
 
 func (c *EmailVerificationClient) generateVerificationURL(ctx context.Context, req *goa.RequestData, code string) string {
 	return rest.AbsoluteURL(req, authclient.VerifyEmailUsersPath(), nil) + "?code=" + code
 }
 
-// VerifyCode validates whether the code is present in our database and returns a non-nil if yes.
+func (c *EmailVerificationClient) generateCancelURL(ctx context.Context, req *goa.RequestData, code string) string {
+	return rest.AbsoluteURL(req, authclient.CancelEmailChangeUsersPath(), nil) + "?code=" + code
+}
+
+// checkRateLimit returns a TooManyRequestsError if the number of verification codes sent to the given user over the
+// configured period reached the limit
+func (c *EmailVerificationClient) checkRateLimit(ctx context.Context, tr transaction.TransactionalResources, userID uuid.UUID) error {
+	period := c.config.GetEmailVerificationRateLimitPeriod()
+	count, err := tr.VerificationCodes().CountCreatedSince(ctx, userID, time.Now().Add(-period))
+	if err != nil {
+		return err
+	}
+	if count >= c.config.GetEmailVerificationRateLimit() {
+		log.Warn(ctx, map[string]interface{}{
+			"user_id": userID,
+			"count":   count,
+		}, "too many verification codes requested")
+		return errors.NewTooManyRequestsError(fmt.Sprintf("too many verification codes requested, please try again in %s", period))
+	}
+	return nil
+}
+
+// loadPendingChange returns the pending change of the email address of the given user, or nil if there is none, if
+// it was already confirmed or if it expired
+func (c *EmailVerificationClient) loadPendingChange(ctx context.Context, tr transaction.TransactionalResources, userID uuid.UUID) (*repository.PendingEmailChange, error) {
+	change, err := tr.PendingEmailChanges().LoadForUser(ctx, userID)
+	if notFound, _ := errors.IsNotFoundError(err); notFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if change.Confirmed() || change.Expired(time.Now()) {
+		return nil, nil
+	}
+	return change, nil
+}
+
+// deletePendingChange deletes the pending change of the email address of the given user if any, along with its
+// verification code. A DataConflictError is returned if the change was confirmed and can still be reverted, so that
+// the record of the previous address is not lost.
+func (c *EmailVerificationClient) deletePendingChange(ctx context.Context, tr transaction.TransactionalResources, userID uuid.UUID) error {
+	change, err := tr.PendingEmailChanges().LoadForUser(ctx, userID)
+	if notFound, _ := errors.IsNotFoundError(err); notFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if change.Confirmed() && !change.Expired(time.Now()) {
+		return errors.NewDataConflictError(fmt.Sprintf("the previous change of the email address can still be reverted until %s", change.ExpiresAt.Format(time.RFC3339)))
+	}
+	return c.deleteChange(ctx, tr, *change)
+}
+
+func (c *EmailVerificationClient) deleteChange(ctx context.Context, tr transaction.TransactionalResources, change repository.PendingEmailChange) error {
+	err := tr.PendingEmailChanges().Delete(ctx, change.PendingEmailChangeID)
+	if err != nil || change.VerificationCodeID == nil {
+		return err
+	}
+	err = tr.VerificationCodes().Delete(ctx, *change.VerificationCodeID)
+	if notFound, _ := errors.IsNotFoundError(err); notFound {
+		// the code was already replaced or verified
+		return nil
+	}
+	return err
+}
+
+// VerifyCode validates whether the code is present in our database and did not expire, and returns a non-nil if yes.
+// If the code confirms a pending change of the email address of the user, her email address is changed. The change is
+// kept along with the previous address for the expiry of the codes, so that it can be reverted with the cancel code.
 func (c *EmailVerificationClient) VerifyCode(ctx context.Context, code string) (*repository.VerificationCode, error) {
 
 	var verificationCode *repository.VerificationCode
@@ -93,13 +262,44 @@ func (c *EmailVerificationClient) VerifyCode(ctx context.Context, code string) (
 		}
 
 		verificationCode = &verificationCodeList[0]
+		if verificationCode.Expired(time.Now()) {
+			return errors.NewBadParameterErrorFromString("code", code, "the verification code expired")
+		}
 
 		user := verificationCode.User
+		change, err := tr.PendingEmailChanges().LoadByVerificationCode(ctx, verificationCode.ID)
+		if notFound, _ := errors.IsNotFoundError(err); !notFound {
+			if err != nil {
+				return err
+			}
+			// the new address may have been taken by another user in the meantime
+			usersWithSameEmail, err := tr.Users().Query(repository.UserFilterByEmail(change.NewEmail))
+			if err != nil {
+				return err
+			}
+			for _, u := range usersWithSameEmail {
+				if u.ID != user.ID {
+					return errors.NewDataConflictError(fmt.Sprintf("email '%s' is already in use", change.NewEmail))
+				}
+			}
+			now := time.Now()
+			previousEmail := user.Email
+			change.PreviousEmail = &previousEmail
+			change.ConfirmedAt = &now
+			change.VerificationCodeID = nil
+			change.ExpiresAt = now.Add(c.config.GetEmailVerificationCodeExpiry())
+			err = tr.PendingEmailChanges().Save(ctx, change)
+			if err != nil {
+				return err
+			}
+			user.Email = change.NewEmail
+		}
 		user.EmailVerified = true
 		err = tr.Users().Save(ctx, &user)
 		if err != nil {
 			return err
 		}
+		verificationCode.User = user
 
 		err = tr.VerificationCodes().Delete(ctx, verificationCode.ID)
 		return err
@@ -110,6 +310,64 @@ func (c *EmailVerificationClient) VerifyCode(ctx context.Context, code string) (
 			"code": code,
 			"err":  err,
 		}, "verification failed")
+		return nil, err
+	}
+	return verificationCode, nil
+}
+
+// CancelEmailChange cancels the pending change of email address with the given cancel code, which was sent to the
+// current email address of the user. The verification code sent to the new address cannot be used anymore. If the
+// change was already confirmed, the previous email address of the user is restored.
+func (c *EmailVerificationClient) CancelEmailChange(ctx context.Context, code string) (*repository.PendingEmailChange, error) {
+	var change *repository.PendingEmailChange
+	err := transaction.Transactional(c.app, func(tr transaction.TransactionalResources) error {
+		var err error
+		change, err = tr.PendingEmailChanges().LoadByCancelCode(ctx, code)
+		if err != nil {
+			return err
+		}
+		if change.Expired(time.Now()) {
+			return errors.NewBadParameterErrorFromString("code", code, "the cancellation code expired")
+		}
+		if change.Confirmed() {
+			err = c.revertChange(ctx, tr, *change)
+			if err != nil {
+				return err
+			}
+		}
+		return c.deleteChange(ctx, tr, *change)
+	})
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"code": code,
+			"err":  err,
+		}, "unable to cancel the email change")
+		return nil, errs.WithStack(err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"user_id":   change.UserID,
+		"confirmed": change.Confirmed(),
+	}, "email change cancelled")
+	return change, nil
+}
+
+// revertChange restores the email address of the user as it was before the given change was confirmed, unless it was
+// taken by another user in the meantime
+func (c *EmailVerificationClient) revertChange(ctx context.Context, tr transaction.TransactionalResources, change repository.PendingEmailChange) error {
+	user, err := tr.Users().Load(ctx, change.UserID)
+	if err != nil {
+		return err
+	}
+	usersWithSameEmail, err := tr.Users().Query(repository.UserFilterByEmail(*change.PreviousEmail))
+	if err != nil {
+		return err
+	}
+	for _, u := range usersWithSameEmail {
+		if u.ID != user.ID {
+			return errors.NewDataConflictError(fmt.Sprintf("email '%s' is already in use", *change.PreviousEmail))
+		}
 	}
-	return verificationCode, err
+	user.Email = *change.PreviousEmail
+	user.EmailVerified = true
+	return tr.Users().Save(ctx, user)
 }
@@ -3,14 +3,19 @@ package service_test
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
 	"github.com/fabric8-services/fabric8-auth/authentication/account/service"
+	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	"github.com/fabric8-services/fabric8-auth/notification"
 	"github.com/fabric8-services/fabric8-auth/test"
 	"github.com/goadesign/goa"
+	errs "github.com/pkg/errors"
 	"github.com/satori/go.uuid"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"testing"
@@ -29,7 +34,7 @@ func TestRunVerificationServiceBlackboxTest(t *testing.T) {
 func (s *verificationServiceBlackboxTest) SetupTest() {
 	s.DBTestSuite.SetupTest()
 	s.repo = repository.NewVerificationCodeRepository(s.DB)
-	s.verificationService = service.NewEmailVerificationClient(s.Application)
+	s.verificationService = service.NewEmailVerificationClient(s.Application, s.Configuration)
 }
 
 func (s *verificationServiceBlackboxTest) TestSendVerificationCodeOK() {
@@ -49,6 +54,27 @@ func (s *verificationServiceBlackboxTest) TestSendVerificationCodeOK() {
 	verificationCodes, err := s.Application.VerificationCodes().LoadByCode(context.Background(), generatedCode.Code)
 	require.NoError(s.T(), err)
 	require.NotEmpty(s.T(), verificationCodes)
+	// and the code is sent via the outbox
+	messages := s.notifications(s.T(), identity.ID)
+	require.Len(s.T(), messages, 1)
+	assert.Equal(s.T(), "user.email.update", messages[0].MessageType)
+	assert.Contains(s.T(), messages[0].Custom["verifyURL"], generatedCode.Code)
+}
+
+// notifications returns the notifications enqueued for the given identity
+func (s *verificationServiceBlackboxTest) notifications(t *testing.T, identityID uuid.UUID) []notification.Message {
+	events, err := s.Application.OutboxEvents().ListForIdentity(context.Background(), identityID)
+	require.NoError(t, err)
+	var messages []notification.Message
+	for _, e := range events {
+		if e.EventType != repository.OutboxEventTypeNotification {
+			continue
+		}
+		msg, err := e.NotificationMessage()
+		require.NoError(t, err)
+		messages = append(messages, msg)
+	}
+	return messages
 }
 
 func (s *verificationServiceBlackboxTest) TestVerifyCodeOK() {
@@ -91,3 +117,272 @@ func (s *verificationServiceBlackboxTest) TestVerifyCodeFails() {
 	require.Error(s.T(), err)
 	require.Nil(s.T(), codeOK)
 }
+
+func (s *verificationServiceBlackboxTest) TestVerifyCodeExpired() {
+	identity, err := test.CreateTestIdentityAndUser(s.DB, uuid.NewV4().String(), "kc")
+	require.NoError(s.T(), err)
+
+	expiresAt := time.Now().Add(-time.Minute)
+	verificationCode := repository.VerificationCode{
+		User:      identity.User,
+		Code:      uuid.NewV4().String(),
+		ExpiresAt: &expiresAt,
+	}
+	err = s.Application.VerificationCodes().Create(context.Background(), &verificationCode)
+	require.NoError(s.T(), err)
+
+	codeOK, err := s.verificationService.VerifyCode(context.Background(), verificationCode.Code)
+	require.Error(s.T(), err)
+	require.IsType(s.T(), errors.BadParameterError{}, errs.Cause(err))
+	require.Nil(s.T(), codeOK)
+	user, err := s.Application.Users().Load(context.Background(), identity.User.ID)
+	require.NoError(s.T(), err)
+	require.False(s.T(), user.EmailVerified)
+}
+
+func (s *verificationServiceBlackboxTest) TestSendVerificationCodeTooManyRequests() {
+	identity, err := test.CreateTestIdentityAndUser(s.DB, uuid.NewV4().String(), "kc")
+	require.NoError(s.T(), err)
+	r := &goa.RequestData{
+		Request: &http.Request{Host: "example.com"},
+	}
+	for i := 0; i < s.Configuration.GetEmailVerificationRateLimit(); i++ {
+		_, err := s.verificationService.SendVerificationCode(context.Background(), r, identity)
+		require.NoError(s.T(), err)
+	}
+
+	_, err = s.verificationService.SendVerificationCode(context.Background(), r, identity)
+	require.Error(s.T(), err)
+	require.IsType(s.T(), errors.TooManyRequestsError{}, errs.Cause(err))
+	_, err = s.verificationService.RequestEmailChange(context.Background(), r, identity, uuid.NewV4().String()+"@example.com")
+	require.IsType(s.T(), errors.TooManyRequestsError{}, errs.Cause(err))
+}
+
+func (s *verificationServiceBlackboxTest) TestRequestEmailChange() {
+	r := &goa.RequestData{
+		Request: &http.Request{Host: "example.com"},
+	}
+
+	s.T().Run("confirmed", func(t *testing.T) {
+		// given
+		identity, err := test.CreateTestIdentityAndUser(s.DB, uuid.NewV4().String(), "kc")
+		require.NoError(t, err)
+		newEmail := uuid.NewV4().String() + "@example.com"
+		// when
+		change, err := s.verificationService.RequestEmailChange(context.Background(), r, identity, newEmail)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, newEmail, change.NewEmail)
+		user, err := s.Application.Users().Load(context.Background(), identity.User.ID)
+		require.NoError(t, err)
+		assert.Equal(t, identity.User.Email, user.Email)
+		// the verification code is sent to the new address and the cancel code to the current one
+		messages := s.notifications(t, identity.ID)
+		require.Len(t, messages, 2)
+		assert.Equal(t, newEmail, messages[0].Custom["userEmail"])
+		assert.Contains(t, messages[1].Custom["cancelURL"], change.CancelCode)
+
+		// when
+		s.confirmEmailChange(t, *change)
+		// then
+		user, err = s.Application.Users().Load(context.Background(), identity.User.ID)
+		require.NoError(t, err)
+		assert.Equal(t, newEmail, user.Email)
+		assert.True(t, user.EmailVerified)
+		// the change is kept so that it can be reverted
+		confirmed, err := s.Application.PendingEmailChanges().LoadForUser(context.Background(), identity.User.ID)
+		require.NoError(t, err)
+		assert.True(t, confirmed.Confirmed())
+		assert.Nil(t, confirmed.VerificationCodeID)
+		require.NotNil(t, confirmed.PreviousEmail)
+		assert.Equal(t, identity.User.Email, *confirmed.PreviousEmail)
+	})
+
+	s.T().Run("replaces the previous change", func(t *testing.T) {
+		// given
+		identity, err := test.CreateTestIdentityAndUser(s.DB, uuid.NewV4().String(), "kc")
+		require.NoError(t, err)
+		previous, err := s.verificationService.RequestEmailChange(context.Background(), r, identity, uuid.NewV4().String()+"@example.com")
+		require.NoError(t, err)
+		previousCode, err := s.Application.VerificationCodes().Load(context.Background(), *previous.VerificationCodeID)
+		require.NoError(t, err)
+		// when
+		change, err := s.verificationService.RequestEmailChange(context.Background(), r, identity, uuid.NewV4().String()+"@example.com")
+		// then
+		require.NoError(t, err)
+		_, err = s.verificationService.VerifyCode(context.Background(), previousCode.Code)
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+		loaded, err := s.Application.PendingEmailChanges().LoadForUser(context.Background(), identity.User.ID)
+		require.NoError(t, err)
+		assert.Equal(t, change.PendingEmailChangeID, loaded.PendingEmailChangeID)
+	})
+
+	s.T().Run("new code sent to the new address", func(t *testing.T) {
+		// given
+		identity, err := test.CreateTestIdentityAndUser(s.DB, uuid.NewV4().String(), "kc")
+		require.NoError(t, err)
+		change, err := s.verificationService.RequestEmailChange(context.Background(), r, identity, uuid.NewV4().String()+"@example.com")
+		require.NoError(t, err)
+		// when
+		verificationCode, err := s.verificationService.SendVerificationCode(context.Background(), r, identity)
+		// then
+		require.NoError(t, err)
+		loaded, err := s.Application.PendingEmailChanges().LoadForUser(context.Background(), identity.User.ID)
+		require.NoError(t, err)
+		require.NotNil(t, loaded.VerificationCodeID)
+		assert.Equal(t, verificationCode.ID, *loaded.VerificationCodeID)
+		_, err = s.verificationService.VerifyCode(context.Background(), verificationCode.Code)
+		require.NoError(t, err)
+		user, err := s.Application.Users().Load(context.Background(), identity.User.ID)
+		require.NoError(t, err)
+		assert.Equal(t, change.NewEmail, user.Email)
+	})
+
+	s.T().Run("email taken in the meantime", func(t *testing.T) {
+		// given
+		identity, err := test.CreateTestIdentityAndUser(s.DB, uuid.NewV4().String(), "kc")
+		require.NoError(t, err)
+		other, err := test.CreateTestIdentityAndUser(s.DB, uuid.NewV4().String(), "kc")
+		require.NoError(t, err)
+		change, err := s.verificationService.RequestEmailChange(context.Background(), r, identity, other.User.Email)
+		require.NoError(t, err)
+		verificationCode, err := s.Application.VerificationCodes().Load(context.Background(), *change.VerificationCodeID)
+		require.NoError(t, err)
+		// when
+		_, err = s.verificationService.VerifyCode(context.Background(), verificationCode.Code)
+		// then
+		require.IsType(t, errors.DataConflictError{}, errs.Cause(err))
+	})
+
+	s.T().Run("rejected while the confirmed change can be reverted", func(t *testing.T) {
+		// given
+		identity, err := test.CreateTestIdentityAndUser(s.DB, uuid.NewV4().String(), "kc")
+		require.NoError(t, err)
+		change, err := s.verificationService.RequestEmailChange(context.Background(), r, identity, uuid.NewV4().String()+"@example.com")
+		require.NoError(t, err)
+		s.confirmEmailChange(t, *change)
+		// when
+		_, err = s.verificationService.RequestEmailChange(context.Background(), r, identity, uuid.NewV4().String()+"@example.com")
+		// then
+		require.IsType(t, errors.DataConflictError{}, errs.Cause(err))
+		loaded, err := s.Application.PendingEmailChanges().LoadForUser(context.Background(), identity.User.ID)
+		require.NoError(t, err)
+		assert.Equal(t, change.PendingEmailChangeID, loaded.PendingEmailChangeID)
+	})
+
+	s.T().Run("accepted once the confirmed change expired", func(t *testing.T) {
+		// given
+		identity, err := test.CreateTestIdentityAndUser(s.DB, uuid.NewV4().String(), "kc")
+		require.NoError(t, err)
+		change, err := s.verificationService.RequestEmailChange(context.Background(), r, identity, uuid.NewV4().String()+"@example.com")
+		require.NoError(t, err)
+		s.confirmEmailChange(t, *change)
+		confirmed, err := s.Application.PendingEmailChanges().LoadForUser(context.Background(), identity.User.ID)
+		require.NoError(t, err)
+		confirmed.ExpiresAt = time.Now().Add(-time.Minute)
+		err = s.Application.PendingEmailChanges().Save(context.Background(), confirmed)
+		require.NoError(t, err)
+		// when
+		_, err = s.verificationService.RequestEmailChange(context.Background(), r, identity, uuid.NewV4().String()+"@example.com")
+		// then
+		require.NoError(t, err)
+	})
+}
+
+func (s *verificationServiceBlackboxTest) TestCancelEmailChange() {
+	r := &goa.RequestData{
+		Request: &http.Request{Host: "example.com"},
+	}
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		identity, err := test.CreateTestIdentityAndUser(s.DB, uuid.NewV4().String(), "kc")
+		require.NoError(t, err)
+		change, err := s.verificationService.RequestEmailChange(context.Background(), r, identity, uuid.NewV4().String()+"@example.com")
+		require.NoError(t, err)
+		verificationCode, err := s.Application.VerificationCodes().Load(context.Background(), *change.VerificationCodeID)
+		require.NoError(t, err)
+		// when
+		cancelled, err := s.verificationService.CancelEmailChange(context.Background(), change.CancelCode)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, change.PendingEmailChangeID, cancelled.PendingEmailChangeID)
+		_, err = s.verificationService.VerifyCode(context.Background(), verificationCode.Code)
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+		user, err := s.Application.Users().Load(context.Background(), identity.User.ID)
+		require.NoError(t, err)
+		assert.Equal(t, identity.User.Email, user.Email)
+	})
+
+	s.T().Run("reverts the confirmed change", func(t *testing.T) {
+		// given
+		identity, err := test.CreateTestIdentityAndUser(s.DB, uuid.NewV4().String(), "kc")
+		require.NoError(t, err)
+		change, err := s.verificationService.RequestEmailChange(context.Background(), r, identity, uuid.NewV4().String()+"@example.com")
+		require.NoError(t, err)
+		s.confirmEmailChange(t, *change)
+		// when
+		_, err = s.verificationService.CancelEmailChange(context.Background(), change.CancelCode)
+		// then
+		require.NoError(t, err)
+		user, err := s.Application.Users().Load(context.Background(), identity.User.ID)
+		require.NoError(t, err)
+		assert.Equal(t, identity.User.Email, user.Email)
+		assert.True(t, user.EmailVerified)
+		_, err = s.Application.PendingEmailChanges().LoadForUser(context.Background(), identity.User.ID)
+		require.IsType(t, errors.NotFoundError{}, err)
+		// the cancel code cannot be used twice
+		_, err = s.verificationService.CancelEmailChange(context.Background(), change.CancelCode)
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+
+	s.T().Run("previous email taken in the meantime", func(t *testing.T) {
+		// given
+		identity, err := test.CreateTestIdentityAndUser(s.DB, uuid.NewV4().String(), "kc")
+		require.NoError(t, err)
+		change, err := s.verificationService.RequestEmailChange(context.Background(), r, identity, uuid.NewV4().String()+"@example.com")
+		require.NoError(t, err)
+		s.confirmEmailChange(t, *change)
+		other, err := test.CreateTestIdentityAndUser(s.DB, uuid.NewV4().String(), "kc")
+		require.NoError(t, err)
+		other.User.Email = identity.User.Email
+		err = s.Application.Users().Save(context.Background(), &other.User)
+		require.NoError(t, err)
+		// when
+		_, err = s.verificationService.CancelEmailChange(context.Background(), change.CancelCode)
+		// then
+		require.IsType(t, errors.DataConflictError{}, errs.Cause(err))
+		user, err := s.Application.Users().Load(context.Background(), identity.User.ID)
+		require.NoError(t, err)
+		assert.Equal(t, change.NewEmail, user.Email)
+	})
+
+	s.T().Run("unknown code", func(t *testing.T) {
+		_, err := s.verificationService.CancelEmailChange(context.Background(), uuid.NewV4().String())
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+
+	s.T().Run("expired", func(t *testing.T) {
+		// given
+		identity, err := test.CreateTestIdentityAndUser(s.DB, uuid.NewV4().String(), "kc")
+		require.NoError(t, err)
+		change, err := s.verificationService.RequestEmailChange(context.Background(), r, identity, uuid.NewV4().String()+"@example.com")
+		require.NoError(t, err)
+		change.ExpiresAt = time.Now().Add(-time.Minute)
+		err = s.Application.PendingEmailChanges().Save(context.Background(), change)
+		require.NoError(t, err)
+		// when
+		_, err = s.verificationService.CancelEmailChange(context.Background(), change.CancelCode)
+		// then
+		require.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+	})
+}
+
+// confirmEmailChange confirms the given change with the verification code sent to the new address
+func (s *verificationServiceBlackboxTest) confirmEmailChange(t *testing.T, change repository.PendingEmailChange) {
+	require.NotNil(t, change.VerificationCodeID)
+	verificationCode, err := s.Application.VerificationCodes().Load(context.Background(), *change.VerificationCodeID)
+	require.NoError(t, err)
+	_, err = s.verificationService.VerifyCode(context.Background(), verificationCode.Code)
+	require.NoError(t, err)
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	apprepository "github.com/fabric8-services/fabric8-auth/application/repository"
+	"github.com/fabric8-services/fabric8-auth/application/service"
+	"github.com/fabric8-services/fabric8-auth/application/service/base"
+	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/metric"
+	"github.com/fabric8-services/fabric8-auth/notification"
+	"github.com/fabric8-services/fabric8-auth/worker"
+	workerrepo "github.com/fabric8-services/fabric8-auth/worker/repository"
+
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// NewOutboxService creates a new service to deliver the outbox events
+func NewOutboxService(ctx servicecontext.ServiceContext, config OutboxServiceConfiguration) service.OutboxService {
+	return &outboxServiceImpl{
+		BaseService: base.NewBaseService(ctx),
+		config:      config,
+	}
+}
+
+// OutboxServiceConfiguration the configuration for the Outbox service
+type OutboxServiceConfiguration interface {
+	GetOutboxBatchSize() int
+	GetOutboxRetryDelay() time.Duration
+	GetOutboxDeprovisionMinInterval() time.Duration
+}
+
+// OutboxQueueConsumerConfig returns the configuration of the consumers of the given outbox queue. The deletions of
+// users on the Che and Tenant services are processed one at a time and spaced by a minimum delay in each pod, to limit
+// the load on these services (for example, during a bulk operation).
+func OutboxQueueConsumerConfig(config OutboxServiceConfiguration, queue string) worker.QueueConsumerConfig {
+	consumerConfig := worker.DefaultQueueConsumerConfig
+	consumerConfig.BatchSize = config.GetOutboxBatchSize()
+	consumerConfig.RetryDelay = config.GetOutboxRetryDelay()
+	if queue == repository.OutboxDeprovisionQueue {
+		consumerConfig.Concurrency = 1
+		consumerConfig.MinInterval = config.GetOutboxDeprovisionMinInterval()
+	}
+	return consumerConfig
+}
+
+// outboxServiceImpl implements the OutboxService to deliver the outbox events
+type outboxServiceImpl struct {
+	base.BaseService
+	config OutboxServiceConfiguration
+}
+
+// DeliverEvent delivers the outbox event stored in the given queue task. This is the handler of the consumers of the
+// outbox queues, which retry the events that failed with an exponential backoff, until the maximum number of attempts
+// is reached and the event is dead-lettered.
+// An event is completed once delivered, so a crash between the delivery and the completion leads to a second
+// delivery: the side effects of the events must be idempotent.
+func (s *outboxServiceImpl) DeliverEvent(ctx context.Context, task workerrepo.QueueTask) error {
+	event, err := repository.NewOutboxEventFromQueueTask(task)
+	if err != nil {
+		return err
+	}
+	err = s.deliver(ctx, *event)
+	metric.RecordOutboxEventDelivery(event.EventType, err == nil)
+	return err
+}
+
+// DeliverEvents delivers the outbox events which are due in the current goroutine, without waiting for the consumers
+// of the outbox queues, and returns the number of events that were delivered.
+func (s *outboxServiceImpl) DeliverEvents(ctx context.Context) (int, error) {
+	delivered := 0
+	deliver := func(ctx context.Context, task workerrepo.QueueTask) error {
+		err := s.DeliverEvent(ctx, task)
+		if err == nil {
+			delivered++
+		}
+		return err
+	}
+	for _, queue := range repository.OutboxQueues {
+		_, err := worker.ProcessQueue(ctx, s.Repositories().QueueTaskRepository(), queue, deliver, OutboxQueueConsumerConfig(s.config, queue))
+		if err != nil {
+			return delivered, err
+		}
+	}
+	return delivered, nil
+}
+
+// deliver performs the side effect of the given event
+func (s *outboxServiceImpl) deliver(ctx context.Context, event repository.OutboxEvent) error {
+	log.Info(ctx, map[string]interface{}{
+		"outbox_event_id": event.OutboxEventID,
+		"identity_id":     event.IdentityID,
+		"event_type":      event.EventType,
+		"attempts":        event.Attempts,
+	}, "delivering outbox event")
+	switch event.EventType {
+	case repository.OutboxEventTypeDeprovisionUser:
+		return s.deprovisionUser(ctx, event.IdentityID)
+	case repository.OutboxEventTypeAuditLog:
+		username, auditLogType, err := event.AuditLog()
+		if err != nil {
+			return err
+		}
+		return s.Services().AdminConsoleService().CreateAuditLog(ctx, username, auditLogType)
+	case repository.OutboxEventTypeNotification:
+		msg, err := event.NotificationMessage()
+		if err != nil {
+			return err
+		}
+		return s.Services().NotificationService().SendMessage(ctx, msg)
+	default:
+		return errs.Errorf("unknown type of outbox event: '%s'", event.EventType)
+	}
+}
+
+// deprovisionUser deletes the user from Che and Tenant service. The user may already have been deactivated
+// or hard-deleted in the auth DB at this point.
+func (s *outboxServiceImpl) deprovisionUser(ctx context.Context, identityID uuid.UUID) error {
+	unscoped := func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}
+	identity, err := s.Repositories().Identities().Load(ctx, identityID, unscoped)
+	if err != nil {
+		if notFound, _ := errors.IsNotFoundError(err); !notFound {
+			return err
+		}
+		identity = &repository.Identity{ID: identityID}
+	} else if identity.UserID.Valid {
+		user, err := s.Repositories().Users().Load(ctx, identity.UserID.UUID, unscoped)
+		if err != nil {
+			return err
+		}
+		identity.User = *user
+	}
+	// call Che
+	err = s.Services().CheService().DeleteUser(ctx, *identity)
+	if err != nil {
+		// do not proceed with tenant removal if something wrong happened during Che cleanup
+		return errs.Wrapf(err, "error occurred during deleting the user '%s' on Che Service", identity.ID)
+	}
+
+	// call Tenant to delete the user there as well,
+	err = s.Services().TenantService().Delete(ctx, identity.ID)
+	if err != nil {
+		return errs.Wrapf(err, "error occurred during deleting the user '%s' on Tenant Service", identity.ID)
+	}
+
+	return nil
+}
+
+// enqueueAuditLog records the given audit log event, so that it remains part of the data of the user after it was
+// forwarded, and enqueues it for the admin console service
+func enqueueAuditLog(ctx context.Context, svcCtx servicecontext.ServiceContext, event *repository.OutboxEvent) error {
+	_, auditLogType, err := event.AuditLog()
+	if err != nil {
+		return err
+	}
+	err = svcCtx.Repositories().AuditEvents().Create(ctx, &repository.AuditEvent{
+		IdentityID: event.IdentityID,
+		EventType:  auditLogType,
+	})
+	if err != nil {
+		return err
+	}
+	return svcCtx.Repositories().OutboxEvents().Enqueue(ctx, event)
+}
+
+// enqueueNotification records the given message in the outbox, to be sent to the given identity by the notification
+// service once the current transaction is committed
+func enqueueNotification(ctx context.Context, repositories apprepository.Repositories, identityID uuid.UUID, msg notification.Message) error {
+	event, err := repository.NewNotificationEvent(identityID, msg)
+	if err != nil {
+		return err
+	}
+	return repositories.OutboxEvents().Enqueue(ctx, event)
+}
@@ -0,0 +1,234 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/admin-console/auditlog"
+	"github.com/fabric8-services/fabric8-auth/application/service/factory"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	userservice "github.com/fabric8-services/fabric8-auth/authentication/account/service"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	servicemock "github.com/fabric8-services/fabric8-auth/test/generated/application/service"
+	workerrepo "github.com/fabric8-services/fabric8-auth/worker/repository"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestOutboxService(t *testing.T) {
+	suite.Run(t, &outboxServiceBlackboxTestSuite{
+		DBTestSuite: gormtestsupport.NewDBTestSuite(),
+	})
+}
+
+type outboxServiceBlackboxTestSuite struct {
+	gormtestsupport.DBTestSuite
+}
+
+type outboxConfig struct {
+	deprovisionMinInterval time.Duration
+}
+
+func (c outboxConfig) GetOutboxBatchSize() int {
+	return 10
+}
+
+func (c outboxConfig) GetOutboxRetryDelay() time.Duration {
+	return time.Minute
+}
+
+func (c outboxConfig) GetOutboxDeprovisionMinInterval() time.Duration {
+	return c.deprovisionMinInterval
+}
+
+func (s *outboxServiceBlackboxTestSuite) TestDeliverEvents() {
+
+	ctx := context.Background()
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		adminConsoleServiceMock := servicemock.NewAdminConsoleServiceMock(t)
+		adminConsoleServiceMock.CreateAuditLogFunc = func(ctx context.Context, username string, eventType string) error {
+			return nil
+		}
+		svcCtx := factory.NewServiceContext(s.Application, s.Application, s.Configuration, nil, factory.WithAdminConsoleService(adminConsoleServiceMock))
+		identityID := uuid.NewV4()
+		err := s.Application.OutboxEvents().Enqueue(ctx, repository.NewAuditLogEvent(identityID, "jdoe", auditlog.UserDeactivationEvent))
+		require.NoError(t, err)
+		// when
+		delivered, err := userservice.NewOutboxService(svcCtx, outboxConfig{}).DeliverEvents(ctx)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, delivered)
+		assert.Equal(t, uint64(1), adminConsoleServiceMock.CreateAuditLogCounter)
+		events, err := s.Application.OutboxEvents().ListForIdentity(ctx, identityID)
+		require.NoError(t, err)
+		assert.Empty(t, events)
+	})
+
+	s.T().Run("deprovisions throttled", func(t *testing.T) {
+		// given
+		defer gock.Off()
+		deleted := []time.Time{}
+		tenantServiceMock := servicemock.NewTenantServiceMock(t)
+		tenantServiceMock.DeleteFunc = func(ctx context.Context, identityID uuid.UUID) error {
+			deleted = append(deleted, time.Now())
+			return nil
+		}
+		svcCtx := factory.NewServiceContext(s.Application, s.Application, s.Configuration, nil, factory.WithTenantService(tenantServiceMock))
+		for i := 0; i < 2; i++ {
+			user := s.Graph.CreateUser()
+			gock.New("http://localhost:8091").
+				Delete(fmt.Sprintf("/api/user/%s", user.IdentityID())).
+				Reply(204)
+			err := s.Application.OutboxEvents().Enqueue(ctx, repository.NewDeprovisionUserEvent(user.IdentityID()))
+			require.NoError(t, err)
+		}
+		// when
+		_, err := userservice.NewOutboxService(svcCtx, outboxConfig{deprovisionMinInterval: 200 * time.Millisecond}).DeliverEvents(ctx)
+		// then
+		require.NoError(t, err)
+		require.Len(t, deleted, 2)
+		assert.True(t, deleted[1].Sub(deleted[0]) >= 200*time.Millisecond)
+	})
+
+	s.T().Run("deprovisions in their own queue", func(t *testing.T) {
+		// given
+		identityID := uuid.NewV4()
+		err := s.Application.OutboxEvents().Enqueue(ctx, repository.NewDeprovisionUserEvent(identityID))
+		require.NoError(t, err)
+		err = s.Application.OutboxEvents().Enqueue(ctx, repository.NewAuditLogEvent(identityID, "jdoe", auditlog.UserDeactivationEvent))
+		require.NoError(t, err)
+		// when
+		events, err := s.Application.OutboxEvents().ListForIdentity(ctx, identityID)
+		// then
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		for _, e := range events {
+			task, err := s.Application.QueueTaskRepository().Load(ctx, e.OutboxEventID)
+			require.NoError(t, err)
+			assert.Equal(t, e.Queue(), task.Queue)
+			assert.Equal(t, repository.DefaultOutboxEventMaxAttempts, task.MaxAttempts)
+		}
+		assert.Equal(t, repository.OutboxDeprovisionQueue, events[0].Queue())
+		assert.Equal(t, repository.OutboxQueue, events[1].Queue())
+	})
+
+	s.T().Run("duplicate event", func(t *testing.T) {
+		// given
+		identityID := uuid.NewV4()
+		err := s.Application.OutboxEvents().Enqueue(ctx, repository.NewDeprovisionUserEvent(identityID))
+		require.NoError(t, err)
+		// when
+		err = s.Application.OutboxEvents().Enqueue(ctx, repository.NewDeprovisionUserEvent(identityID))
+		// then
+		require.NoError(t, err)
+		events, err := s.Application.OutboxEvents().ListForIdentity(ctx, identityID)
+		require.NoError(t, err)
+		assert.Len(t, events, 1)
+	})
+
+	s.T().Run("retried then dead-lettered", func(t *testing.T) {
+		// given
+		adminConsoleServiceMock := servicemock.NewAdminConsoleServiceMock(t)
+		adminConsoleServiceMock.CreateAuditLogFunc = func(ctx context.Context, username string, eventType string) error {
+			return errors.NewInternalErrorFromString("admin console unavailable")
+		}
+		svcCtx := factory.NewServiceContext(s.Application, s.Application, s.Configuration, nil, factory.WithAdminConsoleService(adminConsoleServiceMock))
+		outboxSvc := userservice.NewOutboxService(svcCtx, outboxConfig{})
+		identityID := uuid.NewV4()
+		event := repository.NewAuditLogEvent(identityID, "jdoe", auditlog.UserDeactivationEvent)
+		event.MaxAttempts = 2
+		err := s.Application.OutboxEvents().Enqueue(ctx, event)
+		require.NoError(t, err)
+
+		// when
+		delivered, err := outboxSvc.DeliverEvents(ctx)
+		// then the event is rescheduled
+		require.NoError(t, err)
+		assert.Equal(t, 0, delivered)
+		events, err := s.Application.OutboxEvents().ListForIdentity(ctx, identityID)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, 1, events[0].Attempts)
+		require.NotNil(t, events[0].LastError)
+		assert.Contains(t, *events[0].LastError, "admin console unavailable")
+		assert.True(t, events[0].NextAttempt.After(time.Now()))
+		assert.Nil(t, events[0].DeadLetteredAt)
+		// and not delivered again before it is due
+		_, err = outboxSvc.DeliverEvents(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), adminConsoleServiceMock.CreateAuditLogCounter)
+
+		// when the event is due again and fails for the last time
+		s.makeDue(t, events[0].OutboxEventID)
+		_, err = outboxSvc.DeliverEvents(ctx)
+		// then the event is dead-lettered
+		require.NoError(t, err)
+		assert.Equal(t, uint64(2), adminConsoleServiceMock.CreateAuditLogCounter)
+		events, err = s.Application.OutboxEvents().ListForIdentity(ctx, identityID)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, 2, events[0].Attempts)
+		assert.NotNil(t, events[0].DeadLetteredAt)
+		// and not delivered anymore
+		s.makeDue(t, events[0].OutboxEventID)
+		_, err = outboxSvc.DeliverEvents(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(2), adminConsoleServiceMock.CreateAuditLogCounter)
+		deadLettered, err := s.Application.OutboxEvents().ListDeadLettered(ctx, 10)
+		require.NoError(t, err)
+		found := false
+		for _, e := range deadLettered {
+			found = found || e.OutboxEventID == events[0].OutboxEventID
+		}
+		assert.True(t, found)
+
+		// when the event is redriven
+		adminConsoleServiceMock.CreateAuditLogFunc = func(ctx context.Context, username string, eventType string) error {
+			return nil
+		}
+		err = s.Application.OutboxEvents().Redrive(ctx, events[0].OutboxEventID)
+		require.NoError(t, err)
+		delivered, err = outboxSvc.DeliverEvents(ctx)
+		// then it is delivered
+		require.NoError(t, err)
+		assert.Equal(t, 1, delivered)
+		events, err = s.Application.OutboxEvents().ListForIdentity(ctx, identityID)
+		require.NoError(t, err)
+		assert.Empty(t, events)
+	})
+
+	s.T().Run("dead-lettered event does not prevent a new one", func(t *testing.T) {
+		// given
+		identityID := uuid.NewV4()
+		deadLettered := repository.NewDeprovisionUserEvent(identityID)
+		err := s.Application.OutboxEvents().Enqueue(ctx, deadLettered)
+		require.NoError(t, err)
+		err = s.DB.Model(&workerrepo.QueueTask{}).Where("queue_task_id = ?", deadLettered.OutboxEventID).Update("dead_lettered_at", time.Now()).Error
+		require.NoError(t, err)
+		// when
+		err = s.Application.OutboxEvents().Enqueue(ctx, repository.NewDeprovisionUserEvent(identityID))
+		// then
+		require.NoError(t, err)
+		events, err := s.Application.OutboxEvents().ListForIdentity(ctx, identityID)
+		require.NoError(t, err)
+		assert.Len(t, events, 2)
+		// and the dead-lettered event can not be redriven while the new one is pending
+		err = s.Application.OutboxEvents().Redrive(ctx, deadLettered.OutboxEventID)
+		assert.IsType(t, errors.DataConflictError{}, err)
+	})
+}
+
+// makeDue makes the outbox event with the given ID visible to the consumers again, as if its retry delay expired
+func (s *outboxServiceBlackboxTestSuite) makeDue(t *testing.T, id uuid.UUID) {
+	err := s.DB.Model(&workerrepo.QueueTask{}).Where("queue_task_id = ?", id).Update("visible_at", time.Now()).Error
+	require.NoError(t, err)
+}
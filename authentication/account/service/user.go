@@ -40,6 +40,11 @@ type UserServiceConfiguration interface {
 	GetUserDeactivationInactivityPeriod() time.Duration
 	GetUserDeactivationRescheduleDelay() time.Duration
 	GetUserDeactivationWhiteList() []string
+	GetUserDeactivationPolicies() []account.DeactivationPolicy
+	GetUserDeletionGracePeriod() time.Duration
+	GetUserDeletionRetentionPeriod() time.Duration
+	GetUserBanFetchLimit() int
+	GetUsernameChangeCooldown() time.Duration
 }
 
 // userServiceImpl implements the UserService to manage users
@@ -48,11 +53,16 @@ type userServiceImpl struct {
 	config UserServiceConfiguration
 }
 
-// ResetBanned sets User.Banned to false
+// ResetBanned sets User.Banned and User.Deprovisioned to false and lifts all the current and scheduled bans of the user
 func (s *userServiceImpl) ResetBan(ctx context.Context, user repository.User) error {
 	user.Banned = false
+	user.Deprovisioned = false
 	return s.ExecuteInTransaction(func() error {
-		return s.Repositories().Users().Save(ctx, &user)
+		err := s.Repositories().Users().Save(ctx, &user)
+		if err != nil {
+			return err
+		}
+		return s.Repositories().UserBans().LiftForUser(ctx, user.ID, banActor(ctx), time.Now())
 	})
 }
 
@@ -93,83 +103,73 @@ func (s *userServiceImpl) UserInfo(ctx context.Context, identityID uuid.UUID) (*
 	return &identity.User, identity, nil
 }
 
+// BanUser bans the user with the given username immediately and permanently, without any specific reason.
+// See `BanUserWithReason`
 func (s *userServiceImpl) BanUser(ctx context.Context, username string) (*repository.Identity, error) {
-
-	var identity *repository.Identity
-	err := s.ExecuteInTransaction(func() error {
-
-		identities, err := s.Repositories().Identities().Query(
-			repository.IdentityWithUser(),
-			repository.IdentityFilterByUsername(username),
-			repository.IdentityFilterByProviderType(repository.DefaultIDP))
-		if err != nil {
-			return err
-		}
-		if len(identities) == 0 {
-			return errors.NewNotFoundErrorWithKey("user identity", "username", username)
-		}
-
-		identity = &identities[0]
-		identity.User.Banned = true
-		identity.User.Deprovisioned = true // for backward compatibility
-
-		err = s.Repositories().Users().Save(ctx, &identity.User)
-		if err != nil {
-			return err
-		}
-
-		// revoke all user's tokens
-		return s.Services().TokenService().SetStatusForAllIdentityTokens(ctx, identity.ID, token.TOKEN_STATUS_REVOKED)
-	})
-
-	if err == nil {
-		err = s.deleteUserFromOtherServices(ctx, identity)
-	}
-
-	return identity, err
+	return s.BanUserWithReason(ctx, username, repository.UserBan{})
 }
 
 // NotifyIdentitiesBeforeDeactivation list identities (with a limit) who are soon eligible for account deactivation,
 // sends a notification to each one and record the timestamp of the notification as a marker before upcoming deactivation
 func (s *userServiceImpl) NotifyIdentitiesBeforeDeactivation(ctx context.Context, now func() time.Time) ([]repository.Identity, error) {
-	since := now().Add(-s.config.GetUserDeactivationInactivityNotificationPeriod()) // remove 'n' days from now (default: 24)
-	limit := s.config.GetUserDeactivationFetchLimit()
-	identities, err := s.Repositories().Identities().ListIdentitiesToNotifyForDeactivation(ctx, since, s.config.GetUserDeactivationWhiteList(), limit)
+	scheduled, err := s.listIdentitiesToNotify(ctx, now)
 	if err != nil {
 		return nil, errs.Wrap(err, "unable to send notification to users before account deactivation")
 	}
 
-	expirationDate := GetExpiryDate(s.config, now)
-
-	// for each identity, send a notification and record the timestamp in a separate transaction.
+	// for each identity, record the timestamp along with the notification to send in a separate transaction.
 	// perform the task for each identity in a separate Tx, and just log the error if something wrong happened,
 	// but don't stop processing on the rest of the accounts.
-	for _, identity := range identities {
-		err := s.notifyIdentityBeforeDeactivation(ctx, identity, expirationDate, now)
+	identities := make([]repository.Identity, len(scheduled))
+	for i, si := range scheduled {
+		identities[i] = si.identity
+		err := s.notifyIdentityBeforeDeactivation(ctx, si.identity, si.schedule, now)
 		if err != nil {
 			log.Error(ctx, map[string]interface{}{
 				"error":    err,
-				"username": identity.Username,
+				"username": si.identity.Username,
+				"policy":   si.schedule.policyName(),
 			}, "error while notifying user before account deactivation")
 		} else {
 			log.Info(ctx, map[string]interface{}{
-				"username": identity.Username,
+				"username": si.identity.Username,
+				"policy":   si.schedule.policyName(),
 			}, "notified user before account deactivation")
 		}
 		metric.RecordUserDeactivationNotification(err == nil) // record the notification
-		// create an audit log to keep track of the user deactivation notification
-		err = s.Services().AdminConsoleService().CreateAuditLog(ctx, identity.Username, auditlog.UserDeactivationNotificationEvent)
-		if err != nil {
-			log.Error(ctx, map[string]interface{}{
-				"error":    err,
-				"username": identity.Username,
-			}, "error while creating audit log for user deactivation notification")
-		}
 	}
 
 	return identities, nil
 }
 
+// ListIdentitiesToNotifyForDeactivation lists the identities to notify before their account is deactivated
+func (s *userServiceImpl) ListIdentitiesToNotifyForDeactivation(ctx context.Context, now func() time.Time) ([]repository.Identity, error) {
+	scheduled, err := s.listIdentitiesToNotify(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	identities := make([]repository.Identity, len(scheduled))
+	for i, si := range scheduled {
+		identities[i] = si.identity
+	}
+	return identities, nil
+}
+
+// listIdentitiesToNotify lists the identities to notify before their account is deactivated, along with the deactivation
+// schedule which applies to each of them. When no deactivation policy is defined, the global settings apply to all users.
+func (s *userServiceImpl) listIdentitiesToNotify(ctx context.Context, now func() time.Time) ([]scheduledIdentity, error) {
+	if len(s.config.GetUserDeactivationPolicies()) > 0 {
+		return s.listIdentitiesToRemind(ctx, now())
+	}
+	since := now().Add(-s.config.GetUserDeactivationInactivityNotificationPeriod()) // remove 'n' days from now (default: 24)
+	limit := s.config.GetUserDeactivationFetchLimit()
+	identities, err := s.Repositories().Identities().ListIdentitiesToNotifyForDeactivation(ctx, since, s.config.GetUserDeactivationWhiteList(), 0, limit)
+	if err != nil {
+		return nil, err
+	}
+	return withDefaultSchedule(identities, s.defaultDeactivationSchedule()), nil
+}
+
 // GetExpiryDate a utility function which returns the expiry date, ie, when the user deactivation will happen
 // The date is based on the given 'now', and takes into account the delay for which the user is given a chance
 // to come back (7 days by default)
@@ -181,27 +181,211 @@ func GetExpiryDate(config UserServiceConfiguration, now func() time.Time) string
 
 // ListIdentitiesToDeactivate lists the identities to deactivate
 func (s *userServiceImpl) ListIdentitiesToDeactivate(ctx context.Context, now func() time.Time) ([]repository.Identity, error) {
+	scheduled, err := s.listIdentitiesToDeactivate(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	identities := make([]repository.Identity, len(scheduled))
+	for i, si := range scheduled {
+		identities[i] = si.identity
+	}
+	return identities, nil
+}
+
+// listIdentitiesToDeactivate lists the identities to deactivate, along with the deactivation schedule which applies to
+// each of them. When no deactivation policy is defined, the global settings apply to all users.
+func (s *userServiceImpl) listIdentitiesToDeactivate(ctx context.Context, now func() time.Time) ([]scheduledIdentity, error) {
+	if len(s.config.GetUserDeactivationPolicies()) > 0 {
+		return s.listIdentitiesToDeactivateWithPolicies(ctx, now())
+	}
 	since := now().Add(-s.config.GetUserDeactivationInactivityPeriod())                                                                    // remove 'n' days from now (default: 31)
 	notification := now().Add(s.config.GetUserDeactivationInactivityNotificationPeriod() - s.config.GetUserDeactivationInactivityPeriod()) // make sure that the notification was sent at least `n` days earlier (default: 7)
 	limit := s.config.GetUserDeactivationFetchLimit()
 
-	return s.Repositories().Identities().ListIdentitiesToDeactivate(ctx, since, notification, s.config.GetUserDeactivationWhiteList(), limit)
+	identities, err := s.Repositories().Identities().ListIdentitiesToDeactivate(ctx, since, notification, s.config.GetUserDeactivationWhiteList(), 0, limit)
+	if err != nil {
+		return nil, err
+	}
+	return withDefaultSchedule(identities, s.defaultDeactivationSchedule()), nil
+}
+
+func withDefaultSchedule(identities []repository.Identity, schedule deactivationSchedule) []scheduledIdentity {
+	result := make([]scheduledIdentity, len(identities))
+	for i, identity := range identities {
+		result[i] = scheduledIdentity{identity: identity, schedule: schedule}
+	}
+	return result
 }
 
-func (s *userServiceImpl) notifyIdentityBeforeDeactivation(ctx context.Context, identity repository.Identity, expirationDate string, now func() time.Time) error {
-	msg := notification.NewUserDeactivationEmail(identity.ID.String(), identity.User.Email, expirationDate)
-	err := s.Services().NotificationService().SendMessage(ctx, msg)
+// ListDeactivationCandidates lists the identities which would be notified by the user deactivation notification worker
+// and deactivated by the user deactivation worker in their next cycle, without notifying nor deactivating them. The
+// identities to notify come first. Only the candidates within the given page are returned, along with the total number
+// of candidates. A negative limit is ignored.
+func (s *userServiceImpl) ListDeactivationCandidates(ctx context.Context, now func() time.Time, offset, limit int) ([]repository.DeactivationCandidate, int, error) {
+	if len(s.config.GetUserDeactivationPolicies()) > 0 {
+		// the policies which apply to each identity are evaluated in the application, so the candidates are paginated
+		// once all of them have been listed (no more than the fetch limit for each action)
+		return s.listDeactivationCandidatesWithPolicies(ctx, now, offset, limit)
+	}
+	fetchLimit := s.config.GetUserDeactivationFetchLimit()
+	whitelist := s.config.GetUserDeactivationWhiteList()
+	since := now().Add(-s.config.GetUserDeactivationInactivityNotificationPeriod())
+	toNotifyCount, err := s.Repositories().Identities().Count(ctx, repository.IdentityFilterToNotifyForDeactivation(since, whitelist))
+	if err != nil {
+		return nil, 0, errs.Wrap(err, "unable to count the users to notify before account deactivation")
+	}
+	toNotifyCount = withinFetchLimit(toNotifyCount, fetchLimit)
+	inactiveSince := now().Add(-s.config.GetUserDeactivationInactivityPeriod())
+	notification := now().Add(s.config.GetUserDeactivationInactivityNotificationPeriod() - s.config.GetUserDeactivationInactivityPeriod())
+	toDeactivateCount, err := s.Repositories().Identities().Count(ctx, repository.IdentityFilterToDeactivate(inactiveSince, notification, whitelist))
+	if err != nil {
+		return nil, 0, errs.Wrap(err, "unable to count the users to deactivate")
+	}
+	toDeactivateCount = withinFetchLimit(toDeactivateCount, fetchLimit)
+
+	schedule := s.defaultDeactivationSchedule()
+	candidates := []repository.DeactivationCandidate{}
+	if pageOffset, pageLimit, ok := pageWithin(toNotifyCount, offset, limit); ok {
+		identities, err := s.Repositories().Identities().ListIdentitiesToNotifyForDeactivation(ctx, since, whitelist, pageOffset, pageLimit)
+		if err != nil {
+			return nil, 0, errs.Wrap(err, "unable to list the users to notify before account deactivation")
+		}
+		candidates = append(candidates, notifyCandidates(withDefaultSchedule(identities, schedule), now())...)
+	}
+	if limit >= 0 {
+		limit -= len(candidates)
+	}
+	if pageOffset, pageLimit, ok := pageWithin(toDeactivateCount, offset-toNotifyCount, limit); ok {
+		identities, err := s.Repositories().Identities().ListIdentitiesToDeactivate(ctx, inactiveSince, notification, whitelist, pageOffset, pageLimit)
+		if err != nil {
+			return nil, 0, errs.Wrap(err, "unable to list the users to deactivate")
+		}
+		candidates = append(candidates, deactivateCandidates(withDefaultSchedule(identities, schedule), now())...)
+	}
+	return candidates, toNotifyCount + toDeactivateCount, nil
+}
+
+// listDeactivationCandidatesWithPolicies lists the identities which would be notified or deactivated according to the
+// deactivation policies in the next cycle of the workers, and returns the ones within the given page along with the
+// total number of candidates
+func (s *userServiceImpl) listDeactivationCandidatesWithPolicies(ctx context.Context, now func() time.Time, offset, limit int) ([]repository.DeactivationCandidate, int, error) {
+	toNotify, err := s.listIdentitiesToNotify(ctx, now)
+	if err != nil {
+		return nil, 0, errs.Wrap(err, "unable to list the users to notify before account deactivation")
+	}
+	toDeactivate, err := s.listIdentitiesToDeactivate(ctx, now)
+	if err != nil {
+		return nil, 0, errs.Wrap(err, "unable to list the users to deactivate")
+	}
+	candidates := append(notifyCandidates(toNotify, now()), deactivateCandidates(toDeactivate, now())...)
+	pageOffset, pageLimit, ok := pageWithin(len(candidates), offset, limit)
+	if !ok {
+		return []repository.DeactivationCandidate{}, len(candidates), nil
+	}
+	if pageLimit < 0 {
+		return candidates[pageOffset:], len(candidates), nil
+	}
+	return candidates[pageOffset : pageOffset+pageLimit], len(candidates), nil
+}
+
+// withinFetchLimit returns the given number of identities, or the fetch limit if it is lower. A negative fetch limit
+// is ignored.
+func withinFetchLimit(count, fetchLimit int) int {
+	if fetchLimit >= 0 && count > fetchLimit {
+		return fetchLimit
+	}
+	return count
+}
+
+// pageWithin returns the offset and the limit of the part of a list of the given size which is within the page at the
+// given offset and limit, or false if none of the elements of the list is within the page. A negative limit is ignored.
+func pageWithin(size, offset, limit int) (int, int, bool) {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= size || limit == 0 {
+		return 0, 0, false
+	}
+	if limit < 0 || offset+limit > size {
+		limit = size - offset
+	}
+	return offset, limit, true
+}
+
+// notifyCandidates returns the candidates to notify before their deactivation for the given identities
+func notifyCandidates(identities []scheduledIdentity, now time.Time) []repository.DeactivationCandidate {
+	candidates := make([]repository.DeactivationCandidate, len(identities))
+	for i, si := range identities {
+		sent := sentReminders(si.identity)
+		candidates[i] = repository.DeactivationCandidate{
+			Identity:              si.identity,
+			Action:                repository.DeactivationActionNotify,
+			Reason:                withPolicy(fmt.Sprintf("reminder %d of %d before deactivation", sent+1, len(si.schedule.reminders)), si.schedule),
+			ScheduledDeactivation: scheduledDeactivation(si, now),
+		}
+	}
+	return candidates
+}
+
+// deactivateCandidates returns the candidates to deactivate for the given identities
+func deactivateCandidates(identities []scheduledIdentity, now time.Time) []repository.DeactivationCandidate {
+	candidates := make([]repository.DeactivationCandidate, len(identities))
+	for i, si := range identities {
+		candidates[i] = repository.DeactivationCandidate{
+			Identity:              si.identity,
+			Action:                repository.DeactivationActionDeactivate,
+			Reason:                withPolicy("still inactive after the deactivation notification", si.schedule),
+			ScheduledDeactivation: scheduledDeactivation(si, now),
+		}
+		if si.identity.DeactivationNotification != nil {
+			candidates[i].Reason = withPolicy(fmt.Sprintf("still inactive after the deactivation notification of %s", si.identity.DeactivationNotification.Format("Mon Jan 2")), si.schedule)
+		}
+	}
+	return candidates
+}
+
+// withPolicy appends the name of the policy which applies to the identity to the given reason, if any
+func withPolicy(reason string, schedule deactivationSchedule) string {
+	if schedule.policy == nil {
+		return reason
+	}
+	return fmt.Sprintf("%s (policy: %s)", reason, schedule.policy.Name)
+}
+
+// scheduledDeactivation returns the time at which the account of the given identity will be deactivated. The deactivation
+// is scheduled when the first reminder is sent, at the time defined by the schedule of the identity.
+func scheduledDeactivation(si scheduledIdentity, now time.Time) time.Time {
+	if sentReminders(si.identity) > 0 && si.identity.DeactivationScheduled != nil {
+		return *si.identity.DeactivationScheduled
+	}
+	return now.Add(si.schedule.reminders[0])
+}
+
+// notifyIdentityBeforeDeactivation records the timestamp of the notification and the number of reminders sent, along with
+// the outbox events to send the notification to the user and to keep track of it in the audit logs
+func (s *userServiceImpl) notifyIdentityBeforeDeactivation(ctx context.Context, identity repository.Identity, schedule deactivationSchedule, now func() time.Time) error {
+	notificationDate := now()
+	deactivationDate := scheduledDeactivation(scheduledIdentity{identity: identity, schedule: schedule}, notificationDate)
+	msg := notification.NewUserDeactivationEmail(identity.ID.String(), identity.User.Email, deactivationDate.Format("Mon Jan 2"))
+	notificationEvent, err := repository.NewNotificationEvent(identity.ID, msg)
 	if err != nil {
 		return errs.Wrap(err, "failed to send notification to user before account deactivation")
 	}
 	if err := s.ExecuteInTransaction(func() error {
-		notificationDate := now()
+		identity.DeactivationReminders = sentReminders(identity) + 1
 		identity.DeactivationNotification = &notificationDate
-		scheduledDeactivation := notificationDate.Add(s.config.GetUserDeactivationInactivityPeriod()).Add(-s.config.GetUserDeactivationInactivityNotificationPeriod())
-		identity.DeactivationScheduled = &scheduledDeactivation
-		return s.Repositories().Identities().Save(ctx, &identity)
+		identity.DeactivationScheduled = &deactivationDate
+		err := s.Repositories().Identities().Save(ctx, &identity)
+		if err != nil {
+			return err
+		}
+		err = s.Repositories().OutboxEvents().Enqueue(ctx, notificationEvent)
+		if err != nil {
+			return err
+		}
+		return enqueueAuditLog(ctx, s, repository.NewAuditLogEvent(identity.ID, identity.Username, auditlog.UserDeactivationNotificationEvent))
 	}); err != nil {
-		return errs.Wrap(err, "failed to record timestamp of notification sent to user before account deactivation")
+		return errs.Wrap(err, "failed to record notification sent to user before account deactivation")
 	}
 	return nil
 }
@@ -225,13 +409,9 @@ func (s *userServiceImpl) deactivateUser(ctx context.Context, username string) (
 		return nil, errors.NewNotFoundErrorWithKey("user identity", "username", username)
 	}
 	identity := &identities[0]
+	avatarID := identity.User.AvatarID
 
-	err = s.deleteUserFromOtherServices(ctx, identity)
-	if err != nil {
-		return nil, err
-	}
-
-	// Now it's safe to clean up Auth DB
+	// clean up Auth DB, and record the deletion of the user from the other services in the same transaction
 	if err := s.ExecuteInTransaction(func() error {
 		// unlink external accounts (while we still have the user.Cluster info)
 		err = s.Services().TokenService().DeleteExternalToken(ctx, identity.ID, "", provider.GitHubProviderAlias)
@@ -259,6 +439,12 @@ func (s *userServiceImpl) deactivateUser(ctx context.Context, username string) (
 		identity.User.FeatureLevel = obfuscatated
 		// empty data
 		identity.User.ContextInformation = account.ContextInformation{}
+		identity.User.AvatarID = nil
+		// release the previous usernames reserved to the identity
+		err = s.Repositories().UsernameHistory().DeleteForIdentity(ctx, identity.ID)
+		if err != nil {
+			return err
+		}
 		err = s.Repositories().Identities().Save(ctx, identity)
 		if err != nil {
 			return err
@@ -276,41 +462,115 @@ func (s *userServiceImpl) deactivateUser(ctx context.Context, username string) (
 		if err := s.Repositories().Identities().Delete(ctx, identity.ID); err != nil {
 			return err
 		}
-		return s.Repositories().Users().Delete(ctx, identity.User.ID)
+		if err := s.Repositories().Users().Delete(ctx, identity.User.ID); err != nil {
+			return err
+		}
+		// delete the user from Che and Tenant service
+		if err := s.Repositories().OutboxEvents().Enqueue(ctx, repository.NewDeprovisionUserEvent(identity.ID)); err != nil {
+			return err
+		}
+		// create an audit log to keep track of the user deactivation, with the non-obfuscated username
+		return enqueueAuditLog(ctx, s, repository.NewAuditLogEvent(identity.ID, username, auditlog.UserDeactivationEvent))
 	}); err != nil {
 		return nil, err
 	}
-	// create an audit log to keep track of the user deactivation
-	err = s.Services().AdminConsoleService().CreateAuditLog(ctx, username, auditlog.UserDeactivationEvent)
-	// just log the error
-	if err != nil {
-		log.Error(ctx, map[string]interface{}{
-			"error":    err,
-			"username": username,
-		}, "error while creating audit log for user deactivation")
+	// the images of the avatar of the user are not in the database
+	if avatarID != nil {
+		if err := s.Services().AvatarService().DeleteImages(ctx, *avatarID); err != nil {
+			return nil, err
+		}
 	}
-	// do not return the error logged above.
 	return identity, nil
 }
 
-// deleteUserFromOtherServices deletes the user from Che and Tenant service
-func (s *userServiceImpl) deleteUserFromOtherServices(ctx context.Context, identity *repository.Identity) error {
-	// call Che
-	err := s.Services().CheService().DeleteUser(ctx, *identity)
-	if err != nil {
-		// do not proceed with tenant removal if something wrong happened during Che cleanup
-		return errs.Wrapf(err, "error occurred during deleting the user '%s' on Che Service", identity.ID)
+// ChangeUsername changes the username of the given identity, unless the username is already used by another user or
+// reserved to another identity, and saves the identity. Once the registration is completed, the previous username is
+// recorded in the history of the identity: during the cooldown period it keeps resolving to the identity and is reserved
+// to it. The dependent services are notified of the change once the transaction is committed. If enforceCooldown is
+// true, then the username can only be changed once per cooldown period.
+func (s *userServiceImpl) ChangeUsername(ctx context.Context, identity *repository.Identity, username string, enforceCooldown bool) error {
+	if username == identity.Username {
+		return nil
 	}
+	return s.ExecuteInTransaction(func() error {
+		err := s.checkUsernameAvailable(ctx, *identity, username)
+		if err != nil {
+			return err
+		}
+		previousUsername := identity.Username
+		if identity.RegistrationCompleted {
+			err = s.recordUsernameChange(ctx, *identity, username, enforceCooldown)
+			if err != nil {
+				return err
+			}
+		}
+		identity.Username = username
+		err = s.Repositories().Identities().Save(ctx, identity)
+		if err != nil {
+			return err
+		}
+		if !identity.RegistrationCompleted {
+			return nil
+		}
+		log.Info(ctx, map[string]interface{}{
+			"identity_id":       identity.ID.String(),
+			"previous_username": previousUsername,
+			"username":          username,
+		}, "username changed")
+		return enqueueNotification(ctx, s.Repositories(), identity.ID, notification.NewUserUsernameUpdated(identity.ID.String(), previousUsername, username))
+	})
+}
 
-	// call Tenant to delete the user there as well,
-	err = s.Services().TenantService().Delete(ctx, identity.ID)
+// checkUsernameAvailable verifies that the given username is neither the username of another user, nor a previous
+// username reserved to another identity
+func (s *userServiceImpl) checkUsernameAvailable(ctx context.Context, identity repository.Identity, username string) error {
+	identities, err := s.Repositories().Identities().Query(
+		repository.IdentityFilterByCurrentUsername(username),
+		repository.IdentityFilterByProviderType(repository.DefaultIDP))
 	if err != nil {
-		return errs.Wrapf(err, "error occurred during deleting the user '%s' on Tenant Service", identity.ID)
+		return err
+	}
+	for _, i := range identities {
+		if i.UserID.UUID != identity.UserID.UUID {
+			return errors.NewBadParameterError("username", username).Expected("unique username")
+		}
+	}
+	reservation, err := s.Repositories().UsernameHistory().LoadReservation(ctx, username, time.Now())
+	if err != nil {
+		if notFound, _ := errors.IsNotFoundError(err); notFound {
+			return nil
+		}
+		return err
+	}
+	if reservation.IdentityID != identity.ID {
+		return errors.NewBadParameterError("username", username).Expected("unique username")
 	}
-
 	return nil
 }
 
+// recordUsernameChange records the current username of the identity before it is changed to the given one
+func (s *userServiceImpl) recordUsernameChange(ctx context.Context, identity repository.Identity, username string, enforceCooldown bool) error {
+	cooldown := s.config.GetUsernameChangeCooldown()
+	now := time.Now()
+	history, err := s.Repositories().UsernameHistory().ListForIdentity(ctx, identity.ID)
+	if err != nil {
+		return err
+	}
+	if enforceCooldown && len(history) > 0 && history[0].CreatedAt.Add(cooldown).After(now) {
+		return errors.NewForbiddenError(fmt.Sprintf("username cannot be updated more than once every %d days for identity id %s", int(cooldown.Hours()/24), identity.ID))
+	}
+	// the identity may take back one of its previous usernames
+	err = s.Repositories().UsernameHistory().DeleteForIdentity(ctx, identity.ID, username)
+	if err != nil {
+		return err
+	}
+	return s.Repositories().UsernameHistory().Create(ctx, &repository.UsernameHistory{
+		IdentityID:    identity.ID,
+		Username:      identity.Username,
+		ReservedUntil: now.Add(cooldown),
+	})
+}
+
 // RescheduleDeactivation sets the deactivation schedule to a configurable point of time in the future
 func (s *userServiceImpl) RescheduleDeactivation(ctx context.Context, identityID uuid.UUID) error {
 	rescheduledDeactivation := time.Now().Add(s.config.GetUserDeactivationRescheduleDelay())
@@ -322,6 +582,63 @@ func (s *userServiceImpl) RescheduleDeactivation(ctx context.Context, identityID
 	return err
 }
 
+// RequestDeletion schedules the deletion of the account of the given identity at the end of the grace period, and
+// notifies the user. All tokens and sessions of the user are revoked, so that the deletion is cancelled only if the user
+// logs in again before the end of the grace period. Requesting the deletion of an account whose deletion was already
+// requested does not postpone the deletion.
+func (s *userServiceImpl) RequestDeletion(ctx context.Context, identityID uuid.UUID) (*repository.Identity, error) {
+	identity, err := s.Repositories().Identities().LoadWithUser(ctx, identityID)
+	if err != nil {
+		return nil, err
+	}
+	if identity.DeletionRequested != nil {
+		return identity, nil
+	}
+	now := time.Now()
+	deletionDate := now.Add(s.config.GetUserDeletionGracePeriod())
+	msg := notification.NewUserDeletionRequestedEmail(identity.ID.String(), identity.User.Email, deletionDate.Format("Mon Jan 2"))
+	notificationEvent, err := repository.NewNotificationEvent(identity.ID, msg)
+	if err != nil {
+		return nil, errs.Wrap(err, "failed to notify the user about the deletion of her account")
+	}
+	if err := s.ExecuteInTransaction(func() error {
+		identity.DeletionRequested = &now
+		identity.DeactivationScheduled = &deletionDate
+		err := s.Repositories().Identities().Save(ctx, identity)
+		if err != nil {
+			return err
+		}
+		err = s.Services().TokenService().SetStatusForAllIdentityTokens(ctx, identity.ID, token.TOKEN_STATUS_REVOKED)
+		if err != nil {
+			return err
+		}
+		err = s.Repositories().SessionRepository().DeleteForIdentity(ctx, identity.ID)
+		if err != nil {
+			return err
+		}
+		return s.Repositories().OutboxEvents().Enqueue(ctx, notificationEvent)
+	}); err != nil {
+		return nil, errs.Wrap(err, "failed to record the deletion request of the account")
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identity_id":   identity.ID,
+		"deletion_date": deletionDate,
+	}, "deletion of the account requested")
+	return identity, nil
+}
+
+// ListIdentitiesToDelete lists the identities whose deletion was requested and whose grace period is over
+func (s *userServiceImpl) ListIdentitiesToDelete(ctx context.Context, now func() time.Time) ([]repository.Identity, error) {
+	return s.Repositories().Identities().ListIdentitiesToDelete(ctx, now(), s.config.GetUserDeactivationFetchLimit())
+}
+
+// ListIdentitiesToPurge lists the identities whose deletion was requested, and which were deactivated for longer
+// than the retention period
+func (s *userServiceImpl) ListIdentitiesToPurge(ctx context.Context, now func() time.Time) ([]repository.Identity, error) {
+	deletedBefore := now().Add(-s.config.GetUserDeletionRetentionPeriod())
+	return s.Repositories().Identities().ListIdentitiesToPurge(ctx, deletedBefore, s.config.GetUserDeactivationFetchLimit())
+}
+
 // ContextIdentityIfExists returns the identity's ID found in given context if the identity exists in the Auth DB
 // If it doesn't exist then an Unauthorized error is returned
 func (s *userServiceImpl) ContextIdentityIfExists(ctx context.Context) (uuid.UUID, error) {
@@ -368,13 +685,13 @@ func (s *userServiceImpl) LoadContextIdentityIfNotBanned(ctx context.Context) (*
 		return nil, err
 	}
 	if identity.User.Banned {
-		return nil, errors.NewUnauthorizedError("user banned")
+		return nil, s.BanError(ctx, identity.User, "user banned")
 	}
 	return identity, err
 }
 
 func (s *userServiceImpl) HardDeleteUser(ctx context.Context, identity repository.Identity) error {
-	return s.ExecuteInTransaction(func() error {
+	err := s.ExecuteInTransaction(func() error {
 		unscoped := func(db *gorm.DB) *gorm.DB {
 			return db.Unscoped()
 		}
@@ -387,4 +704,12 @@ func (s *userServiceImpl) HardDeleteUser(ctx context.Context, identity repositor
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+	// the images of the avatar of the user are not in the database
+	if identity.User.AvatarID != nil {
+		return s.Services().AvatarService().DeleteImages(ctx, *identity.User.AvatarID)
+	}
+	return nil
 }
@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization/token"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// systemActor the actor recorded when a ban is started or lifted by the platform itself
+const systemActor = "system"
+
+// unauthorizedCodes the codes of the errors returned to the banned users, for each category of ban reasons
+var unauthorizedCodes = map[string]int{
+	repository.UserBanCategoryAbuse:          errors.UNAUTHORIZED_CODE_USER_BANNED_ABUSE,
+	repository.UserBanCategorySpam:           errors.UNAUTHORIZED_CODE_USER_BANNED_SPAM,
+	repository.UserBanCategoryTermsViolation: errors.UNAUTHORIZED_CODE_USER_BANNED_TERMS_VIOLATION,
+	repository.UserBanCategorySecurity:       errors.UNAUTHORIZED_CODE_USER_BANNED_SECURITY,
+}
+
+// banActor returns the name of the service account on behalf of which the request is performed, or `system` otherwise
+func banActor(ctx context.Context) string {
+	if name, ok := token.ServiceAccountName(ctx); ok {
+		return name
+	}
+	return systemActor
+}
+
+// BanUserWithReason records the given ban for the user with the given username. The ban takes effect immediately
+// unless it starts in the future, in which case it is applied by the user ban worker once it starts. A ban without end
+// time is permanent until it is lifted.
+// If the given ban has an ID, nothing happens if a ban with the same ID was already recorded, so that the callers
+// which may retry can ban the user only once.
+func (s *userServiceImpl) BanUserWithReason(ctx context.Context, username string, ban repository.UserBan) (*repository.Identity, error) {
+	now := time.Now()
+	if ban.ReasonCategory == "" {
+		ban.ReasonCategory = repository.UserBanCategoryOther
+	}
+	if !repository.IsValidUserBanCategory(ban.ReasonCategory) {
+		return nil, errors.NewBadParameterError("reason_category", ban.ReasonCategory).Expected(repository.UserBanCategories)
+	}
+	if ban.StartsAt.IsZero() {
+		ban.StartsAt = now
+	}
+	if ban.EndsAt != nil && !ban.EndsAt.After(ban.StartsAt) {
+		return nil, errors.NewBadParameterError("ends_at", *ban.EndsAt).Expected("a time after the start of the ban")
+	}
+	if ban.Actor == "" {
+		ban.Actor = banActor(ctx)
+	}
+
+	var identity *repository.Identity
+	err := s.ExecuteInTransaction(func() error {
+		identities, err := s.Repositories().Identities().Query(
+			repository.IdentityWithUser(),
+			repository.IdentityFilterByUsername(username),
+			repository.IdentityFilterByProviderType(repository.DefaultIDP))
+		if err != nil {
+			return err
+		}
+		if len(identities) == 0 {
+			return errors.NewNotFoundErrorWithKey("user identity", "username", username)
+		}
+		identity = &identities[0]
+
+		if ban.UserBanID != uuid.Nil {
+			_, err := s.Repositories().UserBans().Load(ctx, ban.UserBanID)
+			if err == nil {
+				log.Info(ctx, map[string]interface{}{
+					"username":    username,
+					"user_ban_id": ban.UserBanID,
+				}, "user ban already recorded")
+				return nil
+			}
+			if notFound, _ := errors.IsNotFoundError(err); !notFound {
+				return err
+			}
+		}
+		ban.UserID = identity.User.ID
+		ban.LiftedAt = nil
+		ban.LiftedBy = nil
+		err = s.Repositories().UserBans().Create(ctx, &ban)
+		if err != nil {
+			return err
+		}
+		if ban.StartsAt.After(now) {
+			// the ban will be applied by the user ban worker when it starts
+			return nil
+		}
+		return s.applyBan(ctx, identity)
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"username":        username,
+		"user_ban_id":     ban.UserBanID,
+		"reason_category": ban.ReasonCategory,
+		"actor":           ban.Actor,
+		"starts_at":       ban.StartsAt,
+		"ends_at":         ban.EndsAt,
+	}, "user ban recorded")
+	return identity, nil
+}
+
+// applyBan marks the user of the given identity as banned, revokes all her tokens and deprovisions her account on the
+// other services. Must be called within a transaction.
+func (s *userServiceImpl) applyBan(ctx context.Context, identity *repository.Identity) error {
+	identity.User.Banned = true
+	identity.User.Deprovisioned = true // for backward compatibility
+
+	err := s.Repositories().Users().Save(ctx, &identity.User)
+	if err != nil {
+		return err
+	}
+
+	// revoke all user's tokens
+	err = s.Services().TokenService().SetStatusForAllIdentityTokens(ctx, identity.ID, token.TOKEN_STATUS_REVOKED)
+	if err != nil {
+		return err
+	}
+	// delete the user from Che and Tenant service once the transaction is committed
+	return s.Repositories().OutboxEvents().Enqueue(ctx, repository.NewDeprovisionUserEvent(identity.ID))
+}
+
+// ListUserBans returns the history of the bans of the user with the given username, the most recent ones first
+func (s *userServiceImpl) ListUserBans(ctx context.Context, username string) ([]repository.UserBan, error) {
+	identities, err := s.Repositories().Identities().Query(
+		repository.IdentityWithUser(),
+		repository.IdentityFilterByUsername(username),
+		repository.IdentityFilterByProviderType(repository.DefaultIDP))
+	if err != nil {
+		return nil, err
+	}
+	if len(identities) == 0 {
+		return nil, errors.NewNotFoundErrorWithKey("user identity", "username", username)
+	}
+	return s.Repositories().UserBans().ListForUser(ctx, identities[0].User.ID)
+}
+
+// StartScheduledBans applies the scheduled bans which started, and returns the number of banned users
+func (s *userServiceImpl) StartScheduledBans(ctx context.Context, now func() time.Time) (int, error) {
+	bans, err := s.Repositories().UserBans().ListToStart(ctx, now(), s.config.GetUserBanFetchLimit())
+	if err != nil {
+		return 0, errs.Wrap(err, "unable to list the user bans to start")
+	}
+	// apply each ban in a separate Tx, and just log the error if something wrong happened,
+	// but don't stop processing the rest of the bans.
+	count := 0
+	for _, ban := range bans {
+		err := s.ExecuteInTransaction(func() error {
+			identities, err := s.Repositories().Identities().Query(
+				repository.IdentityWithUser(),
+				repository.IdentityFilterByUserID(ban.UserID),
+				repository.IdentityFilterByProviderType(repository.DefaultIDP))
+			if err != nil {
+				return err
+			}
+			if len(identities) == 0 {
+				return errors.NewNotFoundErrorWithKey("user identity", "user_id", ban.UserID.String())
+			}
+			return s.applyBan(ctx, &identities[0])
+		})
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"err":         err,
+				"user_ban_id": ban.UserBanID,
+				"user_id":     ban.UserID,
+			}, "error while starting scheduled user ban")
+			continue
+		}
+		log.Info(ctx, map[string]interface{}{
+			"user_ban_id":     ban.UserBanID,
+			"user_id":         ban.UserID,
+			"reason_category": ban.ReasonCategory,
+		}, "scheduled user ban started")
+		count++
+	}
+	return count, nil
+}
+
+// LiftExpiredBans lifts the bans which ended, and unbans their users unless they are still under another ban. Returns
+// the number of lifted bans.
+func (s *userServiceImpl) LiftExpiredBans(ctx context.Context, now func() time.Time) (int, error) {
+	bans, err := s.Repositories().UserBans().ListToLift(ctx, now(), s.config.GetUserBanFetchLimit())
+	if err != nil {
+		return 0, errs.Wrap(err, "unable to list the user bans to lift")
+	}
+	// lift each ban in a separate Tx, and just log the error if something wrong happened,
+	// but don't stop processing the rest of the bans.
+	count := 0
+	for _, ban := range bans {
+		err := s.ExecuteInTransaction(func() error {
+			liftedAt := now()
+			liftedBy := systemActor
+			ban.LiftedAt = &liftedAt
+			ban.LiftedBy = &liftedBy
+			err := s.Repositories().UserBans().Save(ctx, &ban)
+			if err != nil {
+				return err
+			}
+			_, err = s.Repositories().UserBans().LoadActiveForUser(ctx, ban.UserID, liftedAt)
+			if err == nil {
+				// the user is still under another ban
+				return nil
+			}
+			if notFound, _ := errors.IsNotFoundError(err); !notFound {
+				return err
+			}
+			user, err := s.Repositories().Users().Load(ctx, ban.UserID)
+			if err != nil {
+				return err
+			}
+			user.Banned = false
+			user.Deprovisioned = false
+			return s.Repositories().Users().Save(ctx, user)
+		})
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"err":         err,
+				"user_ban_id": ban.UserBanID,
+				"user_id":     ban.UserID,
+			}, "error while lifting expired user ban")
+			continue
+		}
+		log.Info(ctx, map[string]interface{}{
+			"user_ban_id": ban.UserBanID,
+			"user_id":     ban.UserID,
+		}, "expired user ban lifted")
+		count++
+	}
+	return count, nil
+}
+
+// LiftBansWithReason lifts the current and scheduled bans of the given user which were recorded with the given reason,
+// and unbans the user. The other bans are left untouched: returns a DataConflictError if any of them is in effect, in
+// which case nothing is lifted.
+func (s *userServiceImpl) LiftBansWithReason(ctx context.Context, user repository.User, reason string) error {
+	return s.ExecuteInTransaction(func() error {
+		now := time.Now()
+		bans, err := s.Repositories().UserBans().ListForUser(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		var toLift []repository.UserBan
+		for _, ban := range bans {
+			if ban.LiftedAt != nil {
+				continue
+			}
+			if ban.Reason != nil && *ban.Reason == reason {
+				toLift = append(toLift, ban)
+			} else if ban.Active(now) {
+				return errors.NewDataConflictError(fmt.Sprintf("the user is banned for another reason since %s", ban.StartsAt.Format(time.RFC3339)))
+			}
+		}
+		liftedBy := banActor(ctx)
+		for _, ban := range toLift {
+			ban.LiftedAt = &now
+			ban.LiftedBy = &liftedBy
+			err := s.Repositories().UserBans().Save(ctx, &ban)
+			if err != nil {
+				return err
+			}
+		}
+		user.Banned = false
+		user.Deprovisioned = false
+		err = s.Repositories().Users().Save(ctx, &user)
+		if err != nil {
+			return err
+		}
+		log.Info(ctx, map[string]interface{}{
+			"user_id":     user.ID,
+			"lifted_bans": len(toLift),
+			"lifted_by":   liftedBy,
+		}, "user bans lifted")
+		return nil
+	})
+}
+
+// BanError returns the Unauthorized error to respond to the given banned user, with the code matching the category of
+// the reason of her current ban
+func (s *userServiceImpl) BanError(ctx context.Context, user repository.User, msg string) error {
+	code := errors.UNAUTHORIZED_CODE_USER_BANNED
+	ban, err := s.Repositories().UserBans().LoadActiveForUser(ctx, user.ID, time.Now())
+	if err != nil {
+		if notFound, _ := errors.IsNotFoundError(err); !notFound {
+			log.Error(ctx, map[string]interface{}{
+				"err":     err,
+				"user_id": user.ID,
+			}, "unable to load the current ban of the user")
+		}
+	} else if c, found := unauthorizedCodes[ban.ReasonCategory]; found {
+		code = c
+	}
+	return errors.NewUnauthorizedErrorWithCode(msg, code)
+}
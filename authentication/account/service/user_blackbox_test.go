@@ -11,9 +11,12 @@ import (
 	"github.com/fabric8-services/admin-console/auditlog"
 	factorymanager "github.com/fabric8-services/fabric8-auth/application/factory/manager"
 	"github.com/fabric8-services/fabric8-auth/application/service/factory"
+	"github.com/fabric8-services/fabric8-auth/authentication/account"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/avatar"
 	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
 	userservice "github.com/fabric8-services/fabric8-auth/authentication/account/service"
 	"github.com/fabric8-services/fabric8-auth/authentication/provider"
+	"github.com/fabric8-services/fabric8-auth/authorization"
 	"github.com/fabric8-services/fabric8-auth/authorization/token"
 	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
 	"github.com/fabric8-services/fabric8-auth/errors"
@@ -29,6 +32,7 @@ import (
 	testsuite "github.com/fabric8-services/fabric8-common/test/suite"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/goadesign/goa"
 	"github.com/jinzhu/gorm"
 	errs "github.com/pkg/errors"
 	uuid "github.com/satori/go.uuid"
@@ -51,6 +55,9 @@ type userServiceBlackboxTestSuite struct {
 func (s *userServiceBlackboxTestSuite) TestNotifyIdentitiesBeforeDeactivation() {
 	ctx := context.Background()
 	config := userservicemock.NewUserServiceConfigurationMock(s.T())
+	config.GetUserDeactivationPoliciesFunc = func() (empty []account.DeactivationPolicy) {
+		return empty
+	}
 	config.GetUserDeactivationInactivityPeriodFunc = func() time.Duration {
 		return 31 * 24 * time.Hour // 31 days
 	}
@@ -120,9 +127,12 @@ func (s *userServiceBlackboxTestSuite) TestNotifyIdentitiesBeforeDeactivation()
 		adminConsoleServiceMock.CreateAuditLogFunc = func(ctx context.Context, username string, eventType string) error {
 			return nil
 		}
-		userSvc := userservice.NewUserService(factory.NewServiceContext(s.Application, s.Application, nil, nil, factory.WithNotificationService(notificationServiceMock), factory.WithAdminConsoleService(adminConsoleServiceMock)), config)
+		svcCtx := factory.NewServiceContext(s.Application, s.Application, nil, nil, factory.WithNotificationService(notificationServiceMock), factory.WithAdminConsoleService(adminConsoleServiceMock))
+		userSvc := userservice.NewUserService(svcCtx, config)
 		// when
 		result, err := userSvc.NotifyIdentitiesBeforeDeactivation(ctx, nowf)
+		require.NoError(s.T(), err)
+		_, err = userservice.NewOutboxService(svcCtx, s.Configuration).DeliverEvents(ctx)
 		// then
 		require.NoError(s.T(), err)
 		assert.Empty(s.T(), result)
@@ -155,9 +165,12 @@ func (s *userServiceBlackboxTestSuite) TestNotifyIdentitiesBeforeDeactivation()
 			// The deactivation notification should pass even if the audit log failed
 			return errors.NewInternalErrorFromString("oopsie woopsie")
 		}
-		userSvc := userservice.NewUserService(factory.NewServiceContext(s.Application, s.Application, nil, nil, factory.WithNotificationService(notificationServiceMock), factory.WithAdminConsoleService(adminConsoleServiceMock)), config)
+		svcCtx := factory.NewServiceContext(s.Application, s.Application, nil, nil, factory.WithNotificationService(notificationServiceMock), factory.WithAdminConsoleService(adminConsoleServiceMock))
+		userSvc := userservice.NewUserService(svcCtx, config)
 		// when
 		result, err := userSvc.NotifyIdentitiesBeforeDeactivation(ctx, nowf)
+		require.NoError(s.T(), err)
+		_, err = userservice.NewOutboxService(svcCtx, s.Configuration).DeliverEvents(ctx)
 		// then
 		require.NoError(s.T(), err)
 		require.Len(s.T(), result, 1)
@@ -198,9 +211,12 @@ func (s *userServiceBlackboxTestSuite) TestNotifyIdentitiesBeforeDeactivation()
 		adminConsoleServiceMock.CreateAuditLogFunc = func(ctx context.Context, username string, eventType string) error {
 			return nil
 		}
-		userSvc := userservice.NewUserService(factory.NewServiceContext(s.Application, s.Application, nil, nil, factory.WithNotificationService(notificationServiceMock), factory.WithAdminConsoleService(adminConsoleServiceMock)), config)
+		svcCtx := factory.NewServiceContext(s.Application, s.Application, nil, nil, factory.WithNotificationService(notificationServiceMock), factory.WithAdminConsoleService(adminConsoleServiceMock))
+		userSvc := userservice.NewUserService(svcCtx, config)
 		// when
 		result, err := userSvc.NotifyIdentitiesBeforeDeactivation(ctx, nowf)
+		require.NoError(s.T(), err)
+		_, err = userservice.NewOutboxService(svcCtx, s.Configuration).DeliverEvents(ctx)
 		// then
 		require.NoError(s.T(), err)
 		require.Len(s.T(), result, 1)
@@ -238,9 +254,12 @@ func (s *userServiceBlackboxTestSuite) TestNotifyIdentitiesBeforeDeactivation()
 			eventTypeToSend = append(eventTypeToSend, eventType)
 			return nil
 		}
-		userSvc := userservice.NewUserService(factory.NewServiceContext(s.Application, s.Application, nil, nil, factory.WithNotificationService(notificationServiceMock), factory.WithAdminConsoleService(adminConsoleServiceMock)), config)
+		svcCtx := factory.NewServiceContext(s.Application, s.Application, nil, nil, factory.WithNotificationService(notificationServiceMock), factory.WithAdminConsoleService(adminConsoleServiceMock))
+		userSvc := userservice.NewUserService(svcCtx, config)
 		// when
 		result, err := userSvc.NotifyIdentitiesBeforeDeactivation(ctx, nowf)
+		require.NoError(s.T(), err)
+		_, err = userservice.NewOutboxService(svcCtx, s.Configuration).DeliverEvents(ctx)
 		// then
 		require.NoError(s.T(), err)
 		require.Len(s.T(), result, 2)
@@ -305,9 +324,12 @@ func (s *userServiceBlackboxTestSuite) TestNotifyIdentitiesBeforeDeactivation()
 			eventTypeToSend = append(eventTypeToSend, eventType)
 			return nil
 		}
-		userSvc := userservice.NewUserService(factory.NewServiceContext(s.Application, s.Application, nil, nil, factory.WithNotificationService(notificationServiceMock), factory.WithAdminConsoleService(adminConsoleServiceMock)), config)
+		svcCtx := factory.NewServiceContext(s.Application, s.Application, nil, nil, factory.WithNotificationService(notificationServiceMock), factory.WithAdminConsoleService(adminConsoleServiceMock))
+		userSvc := userservice.NewUserService(svcCtx, config)
 		// when
 		result, err := userSvc.NotifyIdentitiesBeforeDeactivation(ctx, nowf)
+		require.NoError(s.T(), err)
+		_, err = userservice.NewOutboxService(svcCtx, s.Configuration).DeliverEvents(ctx)
 		// then
 		require.NoError(s.T(), err)
 		require.Len(s.T(), result, 1)
@@ -340,9 +362,12 @@ func (s *userServiceBlackboxTestSuite) TestNotifyIdentitiesBeforeDeactivation()
 			eventTypeToSend = append(eventTypeToSend, eventType)
 			return nil
 		}
-		userSvc := userservice.NewUserService(factory.NewServiceContext(s.Application, s.Application, nil, nil, factory.WithNotificationService(notificationServiceMock), factory.WithAdminConsoleService(adminConsoleServiceMock)), config)
+		svcCtx := factory.NewServiceContext(s.Application, s.Application, nil, nil, factory.WithNotificationService(notificationServiceMock), factory.WithAdminConsoleService(adminConsoleServiceMock))
+		userSvc := userservice.NewUserService(svcCtx, config)
 		// when
 		result, err := userSvc.NotifyIdentitiesBeforeDeactivation(ctx, nowf)
+		require.NoError(s.T(), err)
+		_, err = userservice.NewOutboxService(svcCtx, s.Configuration).DeliverEvents(ctx)
 		// then
 		require.NoError(s.T(), err)
 		require.Empty(s.T(), result)
@@ -372,9 +397,12 @@ func (s *userServiceBlackboxTestSuite) TestNotifyIdentitiesBeforeDeactivation()
 		adminConsoleServiceMock.CreateAuditLogFunc = func(ctx context.Context, username string, eventType string) error {
 			return nil
 		}
-		userSvc := userservice.NewUserService(factory.NewServiceContext(s.Application, s.Application, nil, nil, factory.WithNotificationService(notificationServiceMock), factory.WithAdminConsoleService(adminConsoleServiceMock)), config)
+		svcCtx := factory.NewServiceContext(s.Application, s.Application, nil, nil, factory.WithNotificationService(notificationServiceMock), factory.WithAdminConsoleService(adminConsoleServiceMock))
+		userSvc := userservice.NewUserService(svcCtx, config)
 		// when
 		result, err := userSvc.NotifyIdentitiesBeforeDeactivation(ctx, time.Now)
+		require.NoError(s.T(), err)
+		_, err = userservice.NewOutboxService(svcCtx, s.Configuration).DeliverEvents(ctx)
 		// then
 		require.NoError(s.T(), err)
 		require.Len(s.T(), result, 2)
@@ -382,20 +410,34 @@ func (s *userServiceBlackboxTestSuite) TestNotifyIdentitiesBeforeDeactivation()
 		assert.Equal(s.T(), identity1.ID, result[1].ID)
 		assert.Equal(s.T(), uint64(2), notificationServiceMock.SendMessageCounter)
 		assert.Equal(s.T(), uint64(2), adminConsoleServiceMock.CreateAuditLogCounter)
-		// also check that the `DeactivationNotification` fields were NOT set for identity #2 in the DB
-		identity, err := s.Application.Identities().Load(ctx, identity2.ID)
+		// also check that the `DeactivationNotification` fields were set for both identities in the DB
+		for _, id := range []uuid.UUID{identity1.ID, identity2.ID} {
+			identity, err := s.Application.Identities().Load(ctx, id)
+			require.NoError(s.T(), err)
+			require.NotNil(s.T(), identity.DeactivationNotification)
+			assert.True(s.T(), time.Now().Sub(*identity.DeactivationNotification) < time.Second*2)
+		}
+		// but the notification to identity #2 will be sent again later
+		events, err := s.Application.OutboxEvents().ListForIdentity(ctx, identity2.ID)
 		require.NoError(s.T(), err)
-		require.Nil(s.T(), identity.DeactivationNotification)
-		// but it worked for identity #1
-		identity, err = s.Application.Identities().Load(ctx, identity1.ID)
+		require.Len(s.T(), events, 1)
+		assert.Equal(s.T(), repository.OutboxEventTypeNotification, events[0].EventType)
+		assert.Equal(s.T(), 1, events[0].Attempts)
+		require.NotNil(s.T(), events[0].LastError)
+		assert.Equal(s.T(), "mock error!", *events[0].LastError)
+		assert.True(s.T(), events[0].NextAttempt.After(time.Now()))
+		// while the notification to identity #1 was delivered
+		events, err = s.Application.OutboxEvents().ListForIdentity(ctx, identity1.ID)
 		require.NoError(s.T(), err)
-		require.NotNil(s.T(), identity.DeactivationNotification)
-		assert.True(s.T(), time.Now().Sub(*identity.DeactivationNotification) < time.Second*2)
+		assert.Empty(s.T(), events)
 	})
 
 }
 func (s *userServiceBlackboxTestSuite) TestListUsersToDeactivate() {
 	config := userservicemock.NewUserServiceConfigurationMock(s.T())
+	config.GetUserDeactivationPoliciesFunc = func() (empty []account.DeactivationPolicy) {
+		return empty
+	}
 	config.GetUserDeactivationInactivityPeriodFunc = func() time.Duration {
 		return 31 * 24 * time.Hour // 31 days
 	}
@@ -577,9 +619,135 @@ func (s *userServiceBlackboxTestSuite) TestListUsersToDeactivate() {
 }
 
 // Testing workflow of user to notify and deactivate, or not, depending on their activity, etc.
+func (s *userServiceBlackboxTestSuite) TestDeactivationPolicies() {
+	// given
+	policies, err := account.ParseDeactivationPolicies(`[
+		{"name": "internal", "email_domains": ["exempt.example.com"], "exempt": true},
+		{"name": "beta", "feature_levels": ["beta"], "inactivity_period_days": 90, "reminder_days": [30, 7, 1]}
+	]`)
+	require.NoError(s.T(), err)
+	config := userservicemock.NewUserServiceConfigurationMock(s.T())
+	config.GetUserDeactivationPoliciesFunc = func() []account.DeactivationPolicy {
+		return policies
+	}
+	config.GetUserDeactivationFetchLimitFunc = func() int {
+		return 100
+	}
+	config.GetUserDeactivationInactivityPeriodFunc = func() time.Duration {
+		return 31 * 24 * time.Hour
+	}
+	config.GetUserDeactivationInactivityNotificationPeriodFunc = func() time.Duration {
+		return 24 * 24 * time.Hour
+	}
+	config.GetUserDeactivationWhiteListFunc = func() (empty []string) {
+		return empty
+	}
+	userSvc := userservice.NewUserService(factory.NewServiceContext(s.Application, s.Application, s.Configuration, nil), config)
+	contains := func(identities []repository.Identity, identityID uuid.UUID) bool {
+		for _, identity := range identities {
+			if identity.ID == identityID {
+				return true
+			}
+		}
+		return false
+	}
+	inactiveUser := func(t *testing.T, featureLevel, email string, inactiveDays int) *repository.Identity {
+		user := s.Graph.CreateUser()
+		user.User().FeatureLevel = featureLevel
+		if email != "" {
+			user.User().Email = email
+		}
+		err := s.Application.Users().Save(s.Ctx, user.User())
+		require.NoError(t, err)
+		lastActive := time.Now().Add(-time.Duration(inactiveDays) * 24 * time.Hour)
+		identity := user.Identity()
+		identity.User = *user.User()
+		identity.LastActive = &lastActive
+		err = s.Application.Identities().Save(s.Ctx, identity)
+		require.NoError(t, err)
+		return identity
+	}
+
+	s.T().Run("reminders", func(t *testing.T) {
+		// given
+		beta := inactiveUser(t, "beta", "", 70)
+		recentBeta := inactiveUser(t, "beta", "", 50)
+		exempt := inactiveUser(t, "released", uuid.NewV4().String()+"@exempt.example.com", 100)
+		released := inactiveUser(t, "released", "", 30)
+		// when
+		identities, err := userSvc.NotifyIdentitiesBeforeDeactivation(s.Ctx, time.Now)
+		// then
+		require.NoError(t, err)
+		assert.True(t, contains(identities, beta.ID))
+		assert.False(t, contains(identities, recentBeta.ID))
+		assert.False(t, contains(identities, exempt.ID))
+		assert.True(t, contains(identities, released.ID))
+		notified, err := s.Application.Identities().Load(s.Ctx, beta.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, notified.DeactivationReminders)
+		require.NotNil(t, notified.DeactivationScheduled)
+		assert.True(t, notified.DeactivationScheduled.After(time.Now().Add(29*24*time.Hour)))
+
+		// when the second reminder is not due yet
+		identities, err = userSvc.ListIdentitiesToNotifyForDeactivation(s.Ctx, time.Now)
+		// then
+		require.NoError(t, err)
+		assert.False(t, contains(identities, beta.ID))
+
+		// when the second reminder is due
+		inFiveDays := time.Now().Add(5 * 24 * time.Hour)
+		notified.DeactivationScheduled = &inFiveDays
+		err = s.Application.Identities().Save(s.Ctx, notified)
+		require.NoError(t, err)
+		identities, err = userSvc.NotifyIdentitiesBeforeDeactivation(s.Ctx, time.Now)
+		// then
+		require.NoError(t, err)
+		assert.True(t, contains(identities, beta.ID))
+		notified, err = s.Application.Identities().Load(s.Ctx, beta.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, notified.DeactivationReminders)
+		assert.Equal(t, inFiveDays.Unix(), notified.DeactivationScheduled.Unix())
+		// and the user is not deactivated before the last reminder
+		yesterday := time.Now().Add(-24 * time.Hour)
+		notified.DeactivationScheduled = &yesterday
+		err = s.Application.Identities().Save(s.Ctx, notified)
+		require.NoError(t, err)
+		identities, err = userSvc.ListIdentitiesToDeactivate(s.Ctx, time.Now)
+		require.NoError(t, err)
+		assert.False(t, contains(identities, beta.ID))
+	})
+
+	s.T().Run("deactivation", func(t *testing.T) {
+		// given
+		beta := inactiveUser(t, "beta", "", 100)
+		ago2days := time.Now().Add(-2 * 24 * time.Hour)
+		yesterday := time.Now().Add(-24 * time.Hour)
+		beta.DeactivationNotification = &ago2days
+		beta.DeactivationReminders = 3
+		beta.DeactivationScheduled = &yesterday
+		err := s.Application.Identities().Save(s.Ctx, beta)
+		require.NoError(t, err)
+		exempt := inactiveUser(t, "beta", uuid.NewV4().String()+"@exempt.example.com", 100)
+		exempt.DeactivationNotification = &ago2days
+		exempt.DeactivationReminders = 3
+		exempt.DeactivationScheduled = &yesterday
+		err = s.Application.Identities().Save(s.Ctx, exempt)
+		require.NoError(t, err)
+		// when
+		identities, err := userSvc.ListIdentitiesToDeactivate(s.Ctx, time.Now)
+		// then
+		require.NoError(t, err)
+		assert.True(t, contains(identities, beta.ID))
+		assert.False(t, contains(identities, exempt.ID))
+	})
+}
+
 func (s *userServiceBlackboxTestSuite) TestUserDeactivationFlow() {
 	// given
 	config := userservicemock.NewUserServiceConfigurationMock(s.T())
+	config.GetUserDeactivationPoliciesFunc = func() (empty []account.DeactivationPolicy) {
+		return empty
+	}
 	config.GetUserDeactivationFetchLimitFunc = func() int {
 		return 100
 	}
@@ -739,7 +907,11 @@ func (s *userServiceBlackboxTestSuite) TestBanUser() {
 			require.NotNil(t, tok)
 			assert.Equal(t, tok.Token().Status, token.TOKEN_STATUS_REVOKED)
 		}
-		// also, verify that che, and tenant services were called
+		// also, verify that che, and tenant services are called once the outbox events are delivered
+		assert.Equal(t, 0, tenantCallsCounter)
+		assert.Equal(t, 0, cheCallsCounter)
+		_, err = s.Application.OutboxService().DeliverEvents(ctx)
+		require.NoError(t, err)
 		assert.Equal(t, 1, tenantCallsCounter)
 		assert.Equal(t, 1, cheCallsCounter)
 
@@ -789,10 +961,14 @@ func (s *userServiceBlackboxTestSuite) TestBanUser() {
 				Reply(204)
 
 			identity, err := s.Application.UserService().BanUser(ctx, userToBan.Identity().Username)
-			assert.EqualError(t, err, fmt.Sprintf("error occurred during deleting the user '%s' on Tenant Service: unable to delete tenant", userToBan.Identity().ID.String()))
+			require.NoError(t, err)
+			_, err = s.Application.OutboxService().DeliverEvents(ctx)
+			require.NoError(t, err)
 
 			// User is marked as banned anyway
 			assert.True(s.T(), identity.User.Banned)
+			// and the deletion will be retried later
+			assertPendingOutboxEvent(t, s.Application.OutboxEvents(), userToBan.IdentityID(), fmt.Sprintf("error occurred during deleting the user '%s' on Tenant Service: unable to delete tenant", userToBan.Identity().ID.String()))
 		})
 
 		s.T().Run("che fails", func(t *testing.T) {
@@ -806,14 +982,30 @@ func (s *userServiceBlackboxTestSuite) TestBanUser() {
 				Reply(500)
 
 			identity, err := s.Application.UserService().BanUser(ctx, userToBan.Identity().Username)
-			assert.EqualError(t, err, fmt.Sprintf("error occurred during deleting the user '%s' on Che Service: unable to delete user '%s' in Che", userToBan.Identity().ID.String(), userToBan.Identity().ID.String()))
+			require.NoError(t, err)
+			_, err = s.Application.OutboxService().DeliverEvents(ctx)
+			require.NoError(t, err)
 
 			// User is marked as banned anyway
 			assert.True(s.T(), identity.User.Banned)
+			// and the deletion will be retried later
+			assertPendingOutboxEvent(t, s.Application.OutboxEvents(), userToBan.IdentityID(), fmt.Sprintf("error occurred during deleting the user '%s' on Che Service: unable to delete user '%s' in Che", userToBan.Identity().ID.String(), userToBan.Identity().ID.String()))
 		})
 	})
 }
 
+// assertPendingOutboxEvent verifies that the deprovisioning of the given identity failed once with the given error
+func assertPendingOutboxEvent(t *testing.T, events repository.OutboxEventRepository, identityID uuid.UUID, lastError string) {
+	pending, err := events.ListForIdentity(context.Background(), identityID)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, repository.OutboxEventTypeDeprovisionUser, pending[0].EventType)
+	assert.Equal(t, 1, pending[0].Attempts)
+	require.NotNil(t, pending[0].LastError)
+	assert.Equal(t, lastError, *pending[0].LastError)
+	assert.Nil(t, pending[0].DeadLetteredAt)
+}
+
 func (s *userServiceBlackboxTestSuite) TestDeactivate() {
 
 	// given
@@ -834,6 +1026,13 @@ func (s *userServiceBlackboxTestSuite) TestDeactivate() {
 		token4 := s.Graph.CreateToken(userToStayIntact)
 		githubTokenToKeep := s.Graph.CreateExternalToken(userToStayIntact, provider.GitHubProviderAlias)
 		openshiftTokenToKeep := s.Graph.CreateExternalToken(userToStayIntact, "02f2eee5-d01a-4119-9893-292a7d39b49e") // ID of the OpenShift cluster returned by gock on behalf of the cluster service
+		// a previous username reserved to the user to deactivate
+		err := s.Application.UsernameHistory().Create(s.Ctx, &repository.UsernameHistory{
+			IdentityID:    userToDeactivate.IdentityID(),
+			Username:      "previous-" + uuid.NewV4().String(),
+			ReservedUntil: time.Now().Add(time.Hour),
+		})
+		require.NoError(t, err)
 
 		defer gock.Off()
 		// call to Cluster Service
@@ -922,6 +1121,11 @@ func (s *userServiceBlackboxTestSuite) TestDeactivate() {
 		}
 		svcCtx := factory.NewServiceContext(s.Application, s.Application, s.Configuration, factorymanager.NewFactoryWrappers(), factory.WithAdminConsoleService(adminConsoleServiceMock))
 		userSvc := userservice.NewUserService(svcCtx, s.Configuration)
+		// an avatar uploaded by the user to deactivate
+		avatarSvc := userservice.NewAvatarService(svcCtx, s.Configuration)
+		uploaded, err := avatarSvc.Upload(ctx, &goa.RequestData{Request: &http.Request{Host: "auth.example.com"}}, userToDeactivate.IdentityID(), testsupport.NewPNGImage(t, 64, 64))
+		require.NoError(t, err)
+		avatarID := *uploaded.User.AvatarID
 
 		// when
 		identity, err := userSvc.DeactivateUser(ctx, userToDeactivate.Identity().Username)
@@ -936,13 +1140,29 @@ func (s *userServiceBlackboxTestSuite) TestDeactivate() {
 		require.NotNil(t, loadedUser)
 		testsupport.AssertIdentityObfuscated(t, userToDeactivate.Identity(), loadedUser.Identity())
 		testsupport.AssertIdentitySoftDeleted(t, loadedUser.Identity())
+		// verify that the previous usernames are not reserved to the user anymore
+		history, err := s.Application.UsernameHistory().ListForIdentity(s.Ctx, userToDeactivate.IdentityID())
+		require.NoError(t, err)
+		assert.Empty(t, history)
+		// verify that the avatar was deleted
+		assert.Nil(t, loadedUser.User().AvatarID)
+		for _, size := range avatar.Sizes {
+			_, err := avatarSvc.Load(ctx, avatarID, size)
+			require.Error(t, err)
+			assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+		}
 		// also, verify that user's tokens were revoked
 		for _, tID := range []uuid.UUID{token1.TokenID(), token2.TokenID()} {
 			tok := s.Graph.LoadToken(tID)
 			require.NotNil(t, tok)
 			assert.Equal(t, tok.Token().Status, token.TOKEN_STATUS_REVOKED)
 		}
-		// also, verify that che, and tenant services were called
+		// also, verify that che, and tenant services are called once the outbox events are delivered
+		assert.Equal(t, 0, tenantCallsCounter)
+		assert.Equal(t, 0, cheCallsCounter)
+		assert.Empty(t, auditlogUsername)
+		_, err = userservice.NewOutboxService(svcCtx, s.Configuration).DeliverEvents(ctx)
+		require.NoError(t, err)
 		assert.Equal(t, 1, tenantCallsCounter)
 		assert.Equal(t, 1, cheCallsCounter)
 		// also, verify that the external accounts where unlinked
@@ -1008,6 +1228,160 @@ func (s *userServiceBlackboxTestSuite) TestHardDeleteUser() {
 	})
 }
 
+func (s *userServiceBlackboxTestSuite) TestRequestDeletion() {
+	// given
+	config := userservicemock.NewUserServiceConfigurationMock(s.T())
+	config.GetUserDeletionGracePeriodFunc = func() time.Duration {
+		return 14 * 24 * time.Hour
+	}
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		tkn := s.Graph.CreateToken(user)
+		notificationServiceMock := servicemock.NewNotificationServiceMock(t)
+		notificationServiceMock.SendMessageFunc = func(ctx context.Context, msg notification.Message, options ...rest.HTTPClientOption) error {
+			assert.Equal(t, "user.deletion.requested", msg.MessageType)
+			assert.Equal(t, user.IdentityID().String(), msg.TargetID)
+			return nil
+		}
+		svcCtx := factory.NewServiceContext(s.Application, s.Application, s.Configuration, nil, factory.WithNotificationService(notificationServiceMock))
+		// when
+		identity, err := userservice.NewUserService(svcCtx, config).RequestDeletion(s.Ctx, user.IdentityID())
+		// then
+		require.NoError(t, err)
+		require.NotNil(t, identity.DeletionRequested)
+		require.NotNil(t, identity.DeactivationScheduled)
+		assert.True(t, identity.DeactivationScheduled.After(time.Now().Add(13*24*time.Hour)))
+		loadedToken, err := s.Application.TokenRepository().Load(s.Ctx, tkn.TokenID())
+		require.NoError(t, err)
+		assert.True(t, loadedToken.HasStatus(token.TOKEN_STATUS_REVOKED))
+		_, err = userservice.NewOutboxService(svcCtx, s.Configuration).DeliverEvents(s.Ctx)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), notificationServiceMock.SendMessageCounter)
+	})
+
+	s.T().Run("requested twice", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		userSvc := userservice.NewUserService(factory.NewServiceContext(s.Application, s.Application, s.Configuration, nil), config)
+		first, err := userSvc.RequestDeletion(s.Ctx, user.IdentityID())
+		require.NoError(t, err)
+		// when
+		second, err := userSvc.RequestDeletion(s.Ctx, user.IdentityID())
+		// then the deletion is not postponed
+		require.NoError(t, err)
+		assert.Equal(t, first.DeactivationScheduled.Unix(), second.DeactivationScheduled.Unix())
+		events, err := s.Application.OutboxEvents().ListForIdentity(s.Ctx, user.IdentityID())
+		require.NoError(t, err)
+		assert.Len(t, events, 1)
+	})
+
+	s.T().Run("cancelled by a new login", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		_, err := userservice.NewUserService(factory.NewServiceContext(s.Application, s.Application, s.Configuration, nil), config).RequestDeletion(s.Ctx, user.IdentityID())
+		require.NoError(t, err)
+		// when
+		err = s.Application.Identities().TouchLastActive(s.Ctx, user.IdentityID())
+		// then
+		require.NoError(t, err)
+		identity, err := s.Application.Identities().Load(s.Ctx, user.IdentityID())
+		require.NoError(t, err)
+		assert.Nil(t, identity.DeletionRequested)
+		assert.Nil(t, identity.DeactivationScheduled)
+	})
+
+	s.T().Run("unknown identity", func(t *testing.T) {
+		// when
+		_, err := userservice.NewUserService(factory.NewServiceContext(s.Application, s.Application, s.Configuration, nil), config).RequestDeletion(s.Ctx, uuid.NewV4())
+		// then
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}
+
+func (s *userServiceBlackboxTestSuite) TestListIdentitiesToDeleteAndPurge() {
+	// given
+	config := userservicemock.NewUserServiceConfigurationMock(s.T())
+	config.GetUserDeactivationFetchLimitFunc = func() int {
+		return 100
+	}
+	config.GetUserDeletionRetentionPeriodFunc = func() time.Duration {
+		return 30 * 24 * time.Hour
+	}
+	userSvc := userservice.NewUserService(factory.NewServiceContext(s.Application, s.Application, s.Configuration, nil), config)
+	yesterday := time.Now().Add(-24 * time.Hour)
+	tomorrow := time.Now().Add(24 * time.Hour)
+	contains := func(identities []repository.Identity, identityID uuid.UUID) bool {
+		for _, identity := range identities {
+			if identity.ID == identityID {
+				return true
+			}
+		}
+		return false
+	}
+	requestDeletion := func(t *testing.T, identity *repository.Identity, scheduled time.Time) {
+		identity.DeletionRequested = &yesterday
+		identity.DeactivationScheduled = &scheduled
+		err := s.Application.Identities().Save(s.Ctx, identity)
+		require.NoError(t, err)
+	}
+
+	s.T().Run("to delete", func(t *testing.T) {
+		// given
+		due := s.Graph.CreateUser()
+		requestDeletion(t, due.Identity(), yesterday)
+		notDue := s.Graph.CreateUser()
+		requestDeletion(t, notDue.Identity(), tomorrow)
+		inactive := s.Graph.CreateUser()
+		inactive.Identity().DeactivationScheduled = &yesterday
+		err := s.Application.Identities().Save(s.Ctx, inactive.Identity())
+		require.NoError(t, err)
+		// when
+		identities, err := userSvc.ListIdentitiesToDelete(s.Ctx, time.Now)
+		// then
+		require.NoError(t, err)
+		assert.True(t, contains(identities, due.IdentityID()))
+		assert.False(t, contains(identities, notDue.IdentityID()))
+		assert.False(t, contains(identities, inactive.IdentityID()))
+	})
+
+	s.T().Run("to purge", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		requestDeletion(t, user.Identity(), yesterday)
+		s.Graph.CreateToken(user)
+		s.Graph.CreateSpace().AddAdmin(user)
+		team := s.Graph.CreateTeam()
+		invitation := s.Graph.CreateInvitation(team, user, s.Graph.CreateRole(s.Graph.LoadResourceType(authorization.IdentityResourceTypeTeam)))
+		err := s.Application.Identities().Delete(s.Ctx, user.IdentityID())
+		require.NoError(t, err)
+		// when the retention period is not over yet
+		identities, err := userSvc.ListIdentitiesToPurge(s.Ctx, time.Now)
+		// then
+		require.NoError(t, err)
+		assert.False(t, contains(identities, user.IdentityID()))
+		// when the retention period is over
+		identities, err = userSvc.ListIdentitiesToPurge(s.Ctx, func() time.Time {
+			return time.Now().Add(31 * 24 * time.Hour)
+		})
+		// then
+		require.NoError(t, err)
+		require.True(t, contains(identities, user.IdentityID()))
+		// and the account can be purged along with its tokens, role assignments and invitations
+		identity := *user.Identity()
+		identity.User.ID = identity.UserID.UUID
+		err = userSvc.HardDeleteUser(s.Ctx, identity)
+		require.NoError(t, err)
+		_, err = s.Application.Identities().Load(s.Ctx, user.IdentityID(), func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped()
+		})
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+		_, err = s.Application.InvitationRepository().Load(s.Ctx, invitation.Invitation().InvitationID)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}
+
 func (s *userServiceBlackboxTestSuite) TestResetBan() {
 
 	userToBan := s.Graph.CreateUser()
@@ -1047,9 +1421,167 @@ func (s *userServiceBlackboxTestSuite) TestResetBan() {
 
 	loadedUser := s.Graph.LoadUser(userToBan.IdentityID())
 	assert.False(s.T(), loadedUser.User().Banned)
+	assert.False(s.T(), loadedUser.User().Deprovisioned)
 
 	loadedUser = s.Graph.LoadUser(userToStayIntact.IdentityID())
 	assert.True(s.T(), loadedUser.User().Banned)
+	assert.True(s.T(), loadedUser.User().Deprovisioned)
+}
+
+func (s *userServiceBlackboxTestSuite) TestBanUserWithReason() {
+
+	s.T().Run("temporary ban", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		reason := "crypto-mining"
+		endsAt := time.Now().Add(24 * time.Hour)
+		// when
+		identity, err := s.Application.UserService().BanUserWithReason(s.Ctx, user.Identity().Username, repository.UserBan{
+			ReasonCategory: repository.UserBanCategoryAbuse,
+			Reason:         &reason,
+			Actor:          "jdoe",
+			EndsAt:         &endsAt,
+		})
+		// then
+		require.NoError(t, err)
+		assert.True(t, identity.User.Banned)
+		bans, err := s.Application.UserService().ListUserBans(s.Ctx, user.Identity().Username)
+		require.NoError(t, err)
+		require.Len(t, bans, 1)
+		assert.Equal(t, repository.UserBanCategoryAbuse, bans[0].ReasonCategory)
+		assert.Equal(t, &reason, bans[0].Reason)
+		assert.Equal(t, "jdoe", bans[0].Actor)
+		assert.True(t, bans[0].Active(time.Now()))
+		// the banned user is told why
+		err = s.Application.UserService().BanError(s.Ctx, identity.User, "user banned")
+		require.IsType(t, errors.UnauthorizedError{}, err)
+		assert.Equal(t, errors.UNAUTHORIZED_CODE_USER_BANNED_ABUSE, err.(errors.UnauthorizedError).UnauthorizedCode)
+
+		// when the ban ends
+		lifted, err := s.Application.UserService().LiftExpiredBans(s.Ctx, func() time.Time {
+			return endsAt.Add(time.Minute)
+		})
+		// then
+		require.NoError(t, err)
+		assert.True(t, lifted >= 1)
+		loadedUser := s.Graph.LoadUser(user.IdentityID())
+		assert.False(t, loadedUser.User().Banned)
+		bans, err = s.Application.UserService().ListUserBans(s.Ctx, user.Identity().Username)
+		require.NoError(t, err)
+		require.Len(t, bans, 1)
+		assert.NotNil(t, bans[0].LiftedAt)
+		require.NotNil(t, bans[0].LiftedBy)
+		assert.Equal(t, "system", *bans[0].LiftedBy)
+	})
+
+	s.T().Run("still banned once a temporary ban ends", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		endsAt := time.Now().Add(time.Hour)
+		_, err := s.Application.UserService().BanUserWithReason(s.Ctx, user.Identity().Username, repository.UserBan{
+			EndsAt: &endsAt,
+		})
+		require.NoError(t, err)
+		_, err = s.Application.UserService().BanUserWithReason(s.Ctx, user.Identity().Username, repository.UserBan{
+			ReasonCategory: repository.UserBanCategorySecurity,
+		})
+		require.NoError(t, err)
+		// when
+		_, err = s.Application.UserService().LiftExpiredBans(s.Ctx, func() time.Time {
+			return endsAt.Add(time.Minute)
+		})
+		// then
+		require.NoError(t, err)
+		loadedUser := s.Graph.LoadUser(user.IdentityID())
+		assert.True(t, loadedUser.User().Banned)
+	})
+
+	s.T().Run("scheduled ban", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		startsAt := time.Now().Add(time.Hour)
+		// when
+		identity, err := s.Application.UserService().BanUserWithReason(s.Ctx, user.Identity().Username, repository.UserBan{
+			ReasonCategory: repository.UserBanCategoryTermsViolation,
+			StartsAt:       startsAt,
+		})
+		// then the user is not banned yet
+		require.NoError(t, err)
+		assert.False(t, identity.User.Banned)
+		_, err = s.Application.UserService().StartScheduledBans(s.Ctx, time.Now)
+		require.NoError(t, err)
+		loadedUser := s.Graph.LoadUser(user.IdentityID())
+		assert.False(t, loadedUser.User().Banned)
+
+		// when the ban starts
+		started, err := s.Application.UserService().StartScheduledBans(s.Ctx, func() time.Time {
+			return startsAt.Add(time.Minute)
+		})
+		// then
+		require.NoError(t, err)
+		assert.True(t, started >= 1)
+		loadedUser = s.Graph.LoadUser(user.IdentityID())
+		assert.True(t, loadedUser.User().Banned)
+	})
+
+	s.T().Run("lifted by reset", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		identity, err := s.Application.UserService().BanUserWithReason(s.Ctx, user.Identity().Username, repository.UserBan{
+			ReasonCategory: repository.UserBanCategorySpam,
+		})
+		require.NoError(t, err)
+		// when
+		err = s.Application.UserService().ResetBan(s.Ctx, identity.User)
+		// then
+		require.NoError(t, err)
+		bans, err := s.Application.UserService().ListUserBans(s.Ctx, user.Identity().Username)
+		require.NoError(t, err)
+		require.Len(t, bans, 1)
+		assert.False(t, bans[0].Active(time.Now()))
+		require.NotNil(t, bans[0].LiftedBy)
+		assert.Equal(t, "system", *bans[0].LiftedBy)
+	})
+
+	s.T().Run("ban without reason", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		// when
+		identity, err := s.Application.UserService().BanUser(s.Ctx, user.Identity().Username)
+		// then
+		require.NoError(t, err)
+		err = s.Application.UserService().BanError(s.Ctx, identity.User, "user banned")
+		require.IsType(t, errors.UnauthorizedError{}, err)
+		assert.Equal(t, errors.UNAUTHORIZED_CODE_USER_BANNED, err.(errors.UnauthorizedError).UnauthorizedCode)
+	})
+
+	s.T().Run("fail", func(t *testing.T) {
+
+		t.Run("unknown category", func(t *testing.T) {
+			// given
+			user := s.Graph.CreateUser()
+			// when
+			_, err := s.Application.UserService().BanUserWithReason(s.Ctx, user.Identity().Username, repository.UserBan{
+				ReasonCategory: "unknown",
+			})
+			// then
+			assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		})
+
+		t.Run("ends before it starts", func(t *testing.T) {
+			// given
+			user := s.Graph.CreateUser()
+			endsAt := time.Now().Add(-1 * time.Hour)
+			// when
+			_, err := s.Application.UserService().BanUserWithReason(s.Ctx, user.Identity().Username, repository.UserBan{
+				EndsAt: &endsAt,
+			})
+			// then
+			assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+			loadedUser := s.Graph.LoadUser(user.IdentityID())
+			assert.False(t, loadedUser.User().Banned)
+		})
+	})
 }
 
 func (s *userServiceBlackboxTestSuite) TestIdentityByUsernameAndEmail() {
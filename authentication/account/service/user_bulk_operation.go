@@ -0,0 +1,397 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application/service"
+	"github.com/fabric8-services/fabric8-auth/application/service/base"
+	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
+	"github.com/fabric8-services/fabric8-auth/authentication/account"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+	workerrepo "github.com/fabric8-services/fabric8-auth/worker/repository"
+
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// the parameters of the bulk operations
+	bulkParamReasonCategory = "reason_category"
+	bulkParamReason         = "reason"
+	bulkParamEndsAt         = "ends_at"
+	bulkParamCluster        = "cluster"
+
+	// the keys of the payload of the queue tasks
+	bulkTaskOperationID = "user_bulk_operation_id"
+	bulkTaskUsername    = "username"
+)
+
+// NewUserBulkOperationService creates a new service to apply operations to lists of users
+func NewUserBulkOperationService(ctx servicecontext.ServiceContext, config UserBulkOperationServiceConfiguration) service.UserBulkOperationService {
+	return &userBulkOperationServiceImpl{
+		BaseService: base.NewBaseService(ctx),
+		config:      config,
+	}
+}
+
+// UserBulkOperationServiceConfiguration the configuration for the UserBulkOperation service
+type UserBulkOperationServiceConfiguration interface {
+	GetUserBulkOperationMaxUsers() int
+}
+
+// userBulkOperationServiceImpl implements the UserBulkOperationService. The operation is recorded along with a pending
+// item for each of its users, and a task is enqueued for each user in the same transaction. The tasks are processed by
+// the queue consumers, which record the result for each user.
+type userBulkOperationServiceImpl struct {
+	base.BaseService
+	config UserBulkOperationServiceConfiguration
+}
+
+// Submit records a new bulk operation for the given users, or for the users matching the given filter, and enqueues
+// a task for each of them. Either a list of usernames or a filter must be given, but not both. The operation is
+// rejected if some of the given usernames are unknown.
+func (s *userBulkOperationServiceImpl) Submit(ctx context.Context, operation string, parameters map[string]interface{}, usernames []string, filter *repository.UserBulkOperationFilter) (*repository.UserBulkOperation, error) {
+	if !repository.IsValidUserBulkOperation(operation) {
+		return nil, errors.NewBadParameterError("operation", operation).Expected(repository.UserBulkOperations)
+	}
+	if parameters == nil {
+		parameters = map[string]interface{}{}
+	}
+	if err := s.validateParameters(ctx, operation, parameters); err != nil {
+		return nil, err
+	}
+	hasFilter := filter != nil && !filter.IsEmpty()
+	if len(usernames) > 0 && hasFilter {
+		return nil, errors.NewBadParameterErrorFromString("filter", filter, "either a list of usernames or a filter must be given, but not both")
+	}
+	maxUsers := s.config.GetUserBulkOperationMaxUsers()
+	if hasFilter {
+		var err error
+		usernames, err = s.listUsernames(ctx, *filter, maxUsers+1)
+		if err != nil {
+			return nil, err
+		}
+		if len(usernames) == 0 {
+			return nil, errors.NewBadParameterErrorFromString("filter", filter, "no user matches the filter")
+		}
+	} else {
+		usernames = distinct(usernames)
+		if len(usernames) == 0 {
+			return nil, errors.NewBadParameterErrorFromString("usernames", usernames, "either a list of usernames or a filter must be given")
+		}
+	}
+	if len(usernames) > maxUsers {
+		return nil, errors.NewBadParameterError("usernames", len(usernames)).Expected(fmt.Sprintf("at most %d users", maxUsers))
+	}
+	if !hasFilter {
+		unknown, err := s.unknownUsernames(ctx, usernames)
+		if err != nil {
+			return nil, err
+		}
+		if len(unknown) > 0 {
+			return nil, errors.NewBadParameterErrorFromString("usernames", unknown, "unknown users")
+		}
+	}
+
+	op := &repository.UserBulkOperation{
+		Operation:   operation,
+		Parameters:  account.ContextInformation(parameters),
+		RequestedBy: banActor(ctx),
+	}
+	err := s.ExecuteInTransaction(func() error {
+		err := s.Repositories().UserBulkOperations().Create(ctx, op, usernames)
+		if err != nil {
+			return err
+		}
+		for _, username := range usernames {
+			key := fmt.Sprintf("%s/%s", op.UserBulkOperationID, username)
+			err := s.Repositories().QueueTaskRepository().Enqueue(ctx, &workerrepo.QueueTask{
+				Queue: repository.UserBulkOperationQueue,
+				Payload: account.ContextInformation{
+					bulkTaskOperationID: op.UserBulkOperationID.String(),
+					bulkTaskUsername:    username,
+				},
+				DeduplicationKey: &key,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"user_bulk_operation_id": op.UserBulkOperationID,
+		"operation":              op.Operation,
+		"requested_by":           op.RequestedBy,
+		"users":                  len(usernames),
+	}, "user bulk operation submitted")
+	return op, nil
+}
+
+// validateParameters verifies the parameters of the operation before it is submitted, so that the operation does
+// not fail for all its users
+func (s *userBulkOperationServiceImpl) validateParameters(ctx context.Context, operation string, parameters map[string]interface{}) error {
+	switch operation {
+	case repository.UserBulkOperationBan:
+		ban, err := banFromParameters(parameters)
+		if err != nil {
+			return err
+		}
+		if ban.ReasonCategory != "" && !repository.IsValidUserBanCategory(ban.ReasonCategory) {
+			return errors.NewBadParameterError(bulkParamReasonCategory, ban.ReasonCategory).Expected(repository.UserBanCategories)
+		}
+		if ban.EndsAt != nil && !ban.EndsAt.After(time.Now()) {
+			return errors.NewBadParameterError(bulkParamEndsAt, *ban.EndsAt).Expected("a time in the future")
+		}
+	case repository.UserBulkOperationMove:
+		clusterURL, _ := parameters[bulkParamCluster].(string)
+		if clusterURL == "" {
+			return errors.NewBadParameterErrorFromString(bulkParamCluster, clusterURL, "the target cluster of the users is required")
+		}
+		cluster, err := s.Services().ClusterService().ClusterByURL(ctx, clusterURL)
+		if err != nil {
+			return err
+		}
+		if cluster == nil {
+			return errors.NewBadParameterErrorFromString(bulkParamCluster, clusterURL, "unknown cluster")
+		}
+		// record the URL of the cluster as known by the cluster service
+		parameters[bulkParamCluster] = cluster.APIURL
+	}
+	return nil
+}
+
+// listUsernames returns the usernames of the users matching the given filter, ordered by username. The number of
+// results is capped by the given limit.
+func (s *userBulkOperationServiceImpl) listUsernames(ctx context.Context, filter repository.UserBulkOperationFilter, limit int) ([]string, error) {
+	funcs := []func(*gorm.DB) *gorm.DB{
+		repository.IdentityFilterByProviderType(repository.DefaultIDP),
+		func(db *gorm.DB) *gorm.DB {
+			return db.Order("username").Limit(limit)
+		},
+	}
+	if filter.Cluster != nil {
+		funcs = append(funcs, repository.IdentityFilterByUserCluster(*filter.Cluster))
+	}
+	if filter.FeatureLevel != nil {
+		funcs = append(funcs, repository.IdentityFilterByUserFeatureLevel(*filter.FeatureLevel))
+	}
+	identities, err := s.Repositories().Identities().Query(funcs...)
+	if err != nil {
+		return nil, err
+	}
+	usernames := make([]string, len(identities))
+	for i, identity := range identities {
+		usernames[i] = identity.Username
+	}
+	return usernames, nil
+}
+
+// unknownUsernames returns the given usernames which are neither the current username nor a previous username of a user
+func (s *userBulkOperationServiceImpl) unknownUsernames(ctx context.Context, usernames []string) ([]string, error) {
+	identities, err := s.Repositories().Identities().Query(
+		repository.IdentityFilterByCurrentUsernames(usernames),
+		repository.IdentityFilterByProviderType(repository.DefaultIDP))
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(identities))
+	for _, identity := range identities {
+		known[identity.Username] = true
+	}
+	unknown := []string{}
+	for _, username := range usernames {
+		if known[username] {
+			continue
+		}
+		// the user may be referred to by a previous username
+		identities, err := s.Repositories().Identities().Query(
+			repository.IdentityFilterByUsername(username),
+			repository.IdentityFilterByProviderType(repository.DefaultIDP))
+		if err != nil {
+			return nil, err
+		}
+		if len(identities) == 0 {
+			unknown = append(unknown, username)
+		}
+	}
+	return unknown, nil
+}
+
+// Load returns the operation with the given ID, along with the result for each of its users
+func (s *userBulkOperationServiceImpl) Load(ctx context.Context, id uuid.UUID) (*repository.UserBulkOperation, []repository.UserBulkOperationItem, error) {
+	op, err := s.Repositories().UserBulkOperations().Load(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	items, err := s.Repositories().UserBulkOperations().ListItems(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return op, items, nil
+}
+
+// ProcessTask applies the operation to the user of the given queue task, and records the result. The task is retried
+// if the operation failed because of a transient error (for example, if a downstream service is not available), and
+// the failure is recorded once the last attempt failed.
+func (s *userBulkOperationServiceImpl) ProcessTask(ctx context.Context, task workerrepo.QueueTask) error {
+	id, err := uuid.FromString(fmt.Sprint(task.Payload[bulkTaskOperationID]))
+	if err != nil {
+		return errs.Wrapf(err, "invalid user bulk operation ID in queue task %s", task.QueueTaskID)
+	}
+	username := fmt.Sprint(task.Payload[bulkTaskUsername])
+	op, err := s.Repositories().UserBulkOperations().Load(ctx, id)
+	if err != nil {
+		return err
+	}
+	item, err := s.Repositories().UserBulkOperations().LoadItem(ctx, id, username)
+	if err != nil {
+		return err
+	}
+	if item.Status != repository.UserBulkOperationItemStatusPending {
+		// the task was already processed, but could not be completed
+		return nil
+	}
+
+	applyErr := s.apply(ctx, *op, username)
+	if applyErr != nil && !isPermanentError(applyErr) && task.Attempts < task.MaxAttempts {
+		return applyErr
+	}
+	err = s.ExecuteInTransaction(func() error {
+		return s.Repositories().UserBulkOperations().CompleteItem(ctx, id, username, applyErr)
+	})
+	if err != nil {
+		return err
+	}
+	if applyErr != nil {
+		log.Error(ctx, map[string]interface{}{
+			"user_bulk_operation_id": id,
+			"operation":              op.Operation,
+			"username":               username,
+			"err":                    applyErr,
+		}, "unable to apply the bulk operation to the user")
+	}
+	return nil
+}
+
+// apply applies the operation to a single user. The operation may be applied more than once to the same user, if its
+// result could not be recorded, so it must have no effect after the first time.
+func (s *userBulkOperationServiceImpl) apply(ctx context.Context, op repository.UserBulkOperation, username string) error {
+	switch op.Operation {
+	case repository.UserBulkOperationBan:
+		ban, err := banFromParameters(op.Parameters)
+		if err != nil {
+			return err
+		}
+		ban.Actor = op.RequestedBy
+		// the same ban ID for each attempt, so that the ban is recorded only once if the task is processed again
+		ban.UserBanID = uuid.NewV5(op.UserBulkOperationID, username)
+		_, err = s.Services().UserService().BanUserWithReason(ctx, username, ban)
+		return err
+	case repository.UserBulkOperationDeactivate:
+		_, err := s.Services().UserService().DeactivateUser(ctx, username)
+		if notFound, _ := errors.IsNotFoundError(err); notFound {
+			// the user existed when the operation was submitted, so the account was deactivated in the meantime (for
+			// example, by a previous attempt whose result could not be recorded)
+			return nil
+		}
+		return err
+	case repository.UserBulkOperationMove:
+		clusterURL, _ := op.Parameters[bulkParamCluster].(string)
+		return s.moveUser(ctx, username, clusterURL)
+	}
+	return errors.NewBadParameterError("operation", op.Operation).Expected(repository.UserBulkOperations)
+}
+
+// moveUser links the user to the given cluster in the cluster management service, and unlinks the user from the
+// previous cluster. Nothing happens if the user is already on the given cluster.
+func (s *userBulkOperationServiceImpl) moveUser(ctx context.Context, username, clusterURL string) error {
+	identities, err := s.Repositories().Identities().Query(
+		repository.IdentityWithUser(),
+		repository.IdentityFilterByUsername(username),
+		repository.IdentityFilterByProviderType(repository.DefaultIDP))
+	if err != nil {
+		return err
+	}
+	if len(identities) == 0 {
+		return errors.NewNotFoundErrorWithKey("user identity", "username", username)
+	}
+	identity := identities[0]
+	previous := identity.User.Cluster
+	if previous == clusterURL {
+		return nil
+	}
+	// link the new cluster first, so that the user is never left without cluster
+	err = s.Services().ClusterService().LinkIdentityToCluster(ctx, identity.ID, clusterURL)
+	if err != nil {
+		return err
+	}
+	if previous != "" {
+		err = s.Services().ClusterService().UnlinkIdentityFromCluster(ctx, identity.ID, previous)
+		if err != nil {
+			return err
+		}
+	}
+	identity.User.Cluster = clusterURL
+	err = s.ExecuteInTransaction(func() error {
+		return s.Repositories().Users().Save(ctx, &identity.User)
+	})
+	if err != nil {
+		return err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"username":         username,
+		"previous_cluster": previous,
+		"cluster":          clusterURL,
+	}, "user moved to another cluster")
+	return nil
+}
+
+// banFromParameters returns the ban described by the parameters of a bulk operation
+func banFromParameters(parameters map[string]interface{}) (repository.UserBan, error) {
+	ban := repository.UserBan{}
+	if category, ok := parameters[bulkParamReasonCategory].(string); ok {
+		ban.ReasonCategory = category
+	}
+	if reason, ok := parameters[bulkParamReason].(string); ok {
+		ban.Reason = &reason
+	}
+	if endsAt, ok := parameters[bulkParamEndsAt].(string); ok {
+		t, err := time.Parse(time.RFC3339, endsAt)
+		if err != nil {
+			return ban, errors.NewBadParameterError(bulkParamEndsAt, endsAt).Expected("a RFC3339 time")
+		}
+		ban.EndsAt = &t
+	}
+	return ban, nil
+}
+
+// isPermanentError returns true if the given error will occur again if the operation is retried
+func isPermanentError(err error) bool {
+	switch errs.Cause(err).(type) {
+	case errors.NotFoundError, errors.BadParameterError, errors.DataConflictError:
+		return true
+	}
+	return false
+}
+
+// distinct returns the given values without duplicates, in the same order
+func distinct(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
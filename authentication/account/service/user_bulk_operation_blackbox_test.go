@@ -0,0 +1,309 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application/service"
+	"github.com/fabric8-services/fabric8-auth/application/service/factory"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	userservice "github.com/fabric8-services/fabric8-auth/authentication/account/service"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	"github.com/fabric8-services/fabric8-auth/rest"
+	testsupport "github.com/fabric8-services/fabric8-auth/test"
+	testservice "github.com/fabric8-services/fabric8-auth/test/generated/application/service"
+	workerrepo "github.com/fabric8-services/fabric8-auth/worker/repository"
+
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestUserBulkOperationService(t *testing.T) {
+	suite.Run(t, &userBulkOperationServiceBlackboxTestSuite{
+		DBTestSuite: gormtestsupport.NewDBTestSuite(),
+	})
+}
+
+type userBulkOperationServiceBlackboxTestSuite struct {
+	gormtestsupport.DBTestSuite
+	clusterServiceMock *testservice.ClusterServiceMock
+}
+
+type userBulkOperationConfig struct {
+	maxUsers int
+}
+
+func (c userBulkOperationConfig) GetUserBulkOperationMaxUsers() int {
+	return c.maxUsers
+}
+
+func (s *userBulkOperationServiceBlackboxTestSuite) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.clusterServiceMock = testsupport.NewClusterServiceMock(s.T())
+}
+
+func (s *userBulkOperationServiceBlackboxTestSuite) newUserBulkOperationService(maxUsers int) service.UserBulkOperationService {
+	svcCtx := factory.NewServiceContext(s.Application, s.Application, s.Configuration, nil, factory.WithClusterService(s.clusterServiceMock))
+	return userservice.NewUserBulkOperationService(svcCtx, userBulkOperationConfig{maxUsers: maxUsers})
+}
+
+// dequeue returns the queue tasks of the given operation
+func (s *userBulkOperationServiceBlackboxTestSuite) dequeue(t *testing.T, id uuid.UUID) []workerrepo.QueueTask {
+	tasks, err := s.Application.QueueTaskRepository().Dequeue(s.Ctx, repository.UserBulkOperationQueue, "test", time.Minute, 1000)
+	require.NoError(t, err)
+	result := []workerrepo.QueueTask{}
+	for _, task := range tasks {
+		if task.Payload["user_bulk_operation_id"] == id.String() {
+			result = append(result, task)
+		}
+	}
+	return result
+}
+
+// process processes the queue tasks of the given operation, and returns the operation with its items
+func (s *userBulkOperationServiceBlackboxTestSuite) process(t *testing.T, svc service.UserBulkOperationService, id uuid.UUID) (*repository.UserBulkOperation, map[string]repository.UserBulkOperationItem) {
+	for _, task := range s.dequeue(t, id) {
+		err := svc.ProcessTask(s.Ctx, task)
+		require.NoError(t, err)
+	}
+	op, items, err := svc.Load(s.Ctx, id)
+	require.NoError(t, err)
+	result := map[string]repository.UserBulkOperationItem{}
+	for _, item := range items {
+		result[item.Username] = item
+	}
+	return op, result
+}
+
+func (s *userBulkOperationServiceBlackboxTestSuite) TestSubmit() {
+
+	s.T().Run("with usernames", func(t *testing.T) {
+		// given
+		user1 := s.Graph.CreateUser()
+		user2 := s.Graph.CreateUser()
+		svc := s.newUserBulkOperationService(10)
+		// when
+		op, err := svc.Submit(s.Ctx, repository.UserBulkOperationBan, map[string]interface{}{
+			"reason_category": repository.UserBanCategorySpam,
+		}, []string{user1.Identity().Username, user2.Identity().Username, user1.Identity().Username}, nil)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, repository.UserBulkOperationStatusRunning, op.Status)
+		_, items, err := svc.Load(s.Ctx, op.UserBulkOperationID)
+		require.NoError(t, err)
+		require.Len(t, items, 2)
+		for _, item := range items {
+			assert.Equal(t, repository.UserBulkOperationItemStatusPending, item.Status)
+		}
+		assert.Len(t, s.dequeue(t, op.UserBulkOperationID), 2)
+	})
+
+	s.T().Run("with filter", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		s.Graph.CreateUser()
+		svc := s.newUserBulkOperationService(10)
+		// when
+		op, err := svc.Submit(s.Ctx, repository.UserBulkOperationDeactivate, nil, nil, &repository.UserBulkOperationFilter{
+			Cluster: &user.User().Cluster,
+		})
+		// then
+		require.NoError(t, err)
+		_, items, err := svc.Load(s.Ctx, op.UserBulkOperationID)
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+		assert.Equal(t, user.Identity().Username, items[0].Username)
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+		user1 := s.Graph.CreateUser()
+		user2 := s.Graph.CreateUser()
+		usernames := []string{user1.Identity().Username, user2.Identity().Username}
+		unknown := "unknown"
+
+		for name, submit := range map[string]func(svc service.UserBulkOperationService) error{
+			"unknown operation": func(svc service.UserBulkOperationService) error {
+				_, err := svc.Submit(s.Ctx, "delete", nil, usernames, nil)
+				return err
+			},
+			"invalid reason category": func(svc service.UserBulkOperationService) error {
+				_, err := svc.Submit(s.Ctx, repository.UserBulkOperationBan, map[string]interface{}{"reason_category": "unknown"}, usernames, nil)
+				return err
+			},
+			"unknown cluster": func(svc service.UserBulkOperationService) error {
+				_, err := svc.Submit(s.Ctx, repository.UserBulkOperationMove, map[string]interface{}{"cluster": "https://unknown"}, usernames, nil)
+				return err
+			},
+			"no user": func(svc service.UserBulkOperationService) error {
+				_, err := svc.Submit(s.Ctx, repository.UserBulkOperationDeactivate, nil, nil, nil)
+				return err
+			},
+			"usernames and filter": func(svc service.UserBulkOperationService) error {
+				_, err := svc.Submit(s.Ctx, repository.UserBulkOperationDeactivate, nil, usernames, &repository.UserBulkOperationFilter{Cluster: &unknown})
+				return err
+			},
+			"no user matching the filter": func(svc service.UserBulkOperationService) error {
+				_, err := svc.Submit(s.Ctx, repository.UserBulkOperationDeactivate, nil, nil, &repository.UserBulkOperationFilter{Cluster: &unknown})
+				return err
+			},
+			"unknown user": func(svc service.UserBulkOperationService) error {
+				_, err := svc.Submit(s.Ctx, repository.UserBulkOperationDeactivate, nil, append(usernames, "unknown-user"), nil)
+				return err
+			},
+			"too many users": func(svc service.UserBulkOperationService) error {
+				_, err := s.newUserBulkOperationService(1).Submit(s.Ctx, repository.UserBulkOperationDeactivate, nil, usernames, nil)
+				return err
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				err := submit(s.newUserBulkOperationService(10))
+				assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+			})
+		}
+	})
+}
+
+func (s *userBulkOperationServiceBlackboxTestSuite) TestProcessTask() {
+
+	s.T().Run("ban", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		svc := s.newUserBulkOperationService(10)
+		op, err := svc.Submit(s.Ctx, repository.UserBulkOperationBan, map[string]interface{}{
+			"reason_category": repository.UserBanCategorySpam,
+			"reason":          "spam campaign",
+		}, []string{user.Identity().Username}, nil)
+		require.NoError(t, err)
+		// when
+		op, items := s.process(t, svc, op.UserBulkOperationID)
+		// then
+		assert.Equal(t, repository.UserBulkOperationStatusCompleted, op.Status)
+		assert.NotNil(t, op.CompletedAt)
+		assert.Equal(t, repository.UserBulkOperationItemStatusSucceeded, items[user.Identity().Username].Status)
+		assert.True(t, s.Graph.LoadUser(user.IdentityID()).User().Banned)
+		bans, err := s.Application.UserBans().ListForUser(s.Ctx, user.User().ID)
+		require.NoError(t, err)
+		require.Len(t, bans, 1)
+		assert.Equal(t, repository.UserBanCategorySpam, bans[0].ReasonCategory)
+		assert.Equal(t, op.RequestedBy, bans[0].Actor)
+	})
+
+	s.T().Run("ban applied again", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		svc := s.newUserBulkOperationService(10)
+		op, err := svc.Submit(s.Ctx, repository.UserBulkOperationBan, map[string]interface{}{
+			"reason_category": repository.UserBanCategorySpam,
+		}, []string{user.Identity().Username}, nil)
+		require.NoError(t, err)
+		tasks := s.dequeue(t, op.UserBulkOperationID)
+		require.Len(t, tasks, 1)
+		err = svc.ProcessTask(s.Ctx, tasks[0])
+		require.NoError(t, err)
+		// the result of the task was lost, for example because the pod crashed before it was recorded
+		err = s.DB.Model(&repository.UserBulkOperationItem{}).
+			Where("user_bulk_operation_id = ?", op.UserBulkOperationID).
+			Update("status", repository.UserBulkOperationItemStatusPending).Error
+		require.NoError(t, err)
+		// when
+		err = svc.ProcessTask(s.Ctx, tasks[0])
+		// then
+		require.NoError(t, err)
+		_, items, err := svc.Load(s.Ctx, op.UserBulkOperationID)
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+		assert.Equal(t, repository.UserBulkOperationItemStatusSucceeded, items[0].Status)
+		bans, err := s.Application.UserBans().ListForUser(s.Ctx, user.User().ID)
+		require.NoError(t, err)
+		assert.Len(t, bans, 1)
+	})
+
+	s.T().Run("deactivate", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		svc := s.newUserBulkOperationService(10)
+		op, err := svc.Submit(s.Ctx, repository.UserBulkOperationDeactivate, nil, []string{user.Identity().Username}, nil)
+		require.NoError(t, err)
+		// when
+		op, items := s.process(t, svc, op.UserBulkOperationID)
+		// then
+		assert.Equal(t, repository.UserBulkOperationStatusCompleted, op.Status)
+		assert.Equal(t, repository.UserBulkOperationItemStatusSucceeded, items[user.Identity().Username].Status)
+		_, err = s.Application.Identities().Load(s.Ctx, user.IdentityID())
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+
+	s.T().Run("deactivate user deleted in the meantime", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		svc := s.newUserBulkOperationService(10)
+		op, err := svc.Submit(s.Ctx, repository.UserBulkOperationDeactivate, nil, []string{user.Identity().Username}, nil)
+		require.NoError(t, err)
+		svcCtx := factory.NewServiceContext(s.Application, s.Application, s.Configuration, nil, factory.WithClusterService(s.clusterServiceMock))
+		_, err = svcCtx.Services().UserService().DeactivateUser(s.Ctx, user.Identity().Username)
+		require.NoError(t, err)
+		// when
+		op, items := s.process(t, svc, op.UserBulkOperationID)
+		// then
+		assert.Equal(t, repository.UserBulkOperationStatusCompleted, op.Status)
+		assert.Equal(t, repository.UserBulkOperationItemStatusSucceeded, items[user.Identity().Username].Status)
+	})
+
+	s.T().Run("move", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		target := "https://api.starter-us-east-2a.openshift.com/"
+		svc := s.newUserBulkOperationService(10)
+		op, err := svc.Submit(s.Ctx, repository.UserBulkOperationMove, map[string]interface{}{
+			"cluster": target,
+		}, []string{user.Identity().Username}, nil)
+		require.NoError(t, err)
+		// when
+		op, items := s.process(t, svc, op.UserBulkOperationID)
+		// then
+		assert.Equal(t, repository.UserBulkOperationStatusCompleted, op.Status)
+		assert.Equal(t, repository.UserBulkOperationItemStatusSucceeded, items[user.Identity().Username].Status)
+		assert.Equal(t, target, s.Graph.LoadUser(user.IdentityID()).User().Cluster)
+		assert.Equal(t, uint64(1), s.clusterServiceMock.LinkIdentityToClusterCounter)
+		assert.Equal(t, uint64(1), s.clusterServiceMock.UnlinkIdentityFromClusterCounter)
+	})
+
+	s.T().Run("retried until the last attempt", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		s.clusterServiceMock.LinkIdentityToClusterFunc = func(ctx context.Context, identityID uuid.UUID, clusterURL string, options ...rest.HTTPClientOption) error {
+			return errs.New("cluster service unavailable")
+		}
+		svc := s.newUserBulkOperationService(10)
+		op, err := svc.Submit(s.Ctx, repository.UserBulkOperationMove, map[string]interface{}{
+			"cluster": "https://api.starter-us-east-2a.openshift.com/",
+		}, []string{user.Identity().Username}, nil)
+		require.NoError(t, err)
+		tasks := s.dequeue(t, op.UserBulkOperationID)
+		require.Len(t, tasks, 1)
+		task := tasks[0]
+		// when
+		err = svc.ProcessTask(s.Ctx, task)
+		// then the task is retried
+		require.Error(t, err)
+		_, items, err := svc.Load(s.Ctx, op.UserBulkOperationID)
+		require.NoError(t, err)
+		assert.Equal(t, repository.UserBulkOperationItemStatusPending, items[0].Status)
+		// when
+		task.Attempts = task.MaxAttempts
+		err = svc.ProcessTask(s.Ctx, task)
+		// then the failure is recorded
+		require.NoError(t, err)
+		op, items, err = svc.Load(s.Ctx, op.UserBulkOperationID)
+		require.NoError(t, err)
+		assert.Equal(t, repository.UserBulkOperationStatusCompleted, op.Status)
+		assert.Equal(t, repository.UserBulkOperationItemStatusFailed, items[0].Status)
+		require.NotNil(t, items[0].Error)
+		assert.Contains(t, *items[0].Error, "cluster service unavailable")
+	})
+}
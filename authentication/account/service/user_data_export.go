@@ -0,0 +1,450 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application/service"
+	"github.com/fabric8-services/fabric8-auth/application/service/base"
+	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
+	"github.com/fabric8-services/fabric8-auth/authentication/account"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization"
+	tokenrepo "github.com/fabric8-services/fabric8-auth/authorization/token/repository"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// NewUserDataExportService creates a new service to export the personal data of the users
+func NewUserDataExportService(ctx servicecontext.ServiceContext, config UserDataExportServiceConfiguration) service.UserDataExportService {
+	return &userDataExportServiceImpl{
+		BaseService: base.NewBaseService(ctx),
+		config:      config,
+	}
+}
+
+// UserDataExportServiceConfiguration the configuration for the UserDataExport service
+type UserDataExportServiceConfiguration interface {
+	GetUserDataExportBatchSize() int
+	GetUserDataExportSyncTokenLimit() int
+	GetUserDataExportRetention() time.Duration
+}
+
+// userDataExportServiceImpl implements the UserDataExportService to export the personal data of the users
+type userDataExportServiceImpl struct {
+	base.BaseService
+	config UserDataExportServiceConfiguration
+}
+
+// userDataArchive the machine-readable copy of the personal data of a user. Secrets such as the external tokens
+// themselves are never part of the archive.
+type userDataArchive struct {
+	ExportedAt         time.Time                        `json:"exported_at"`
+	User               *userDataArchiveUser             `json:"user,omitempty"`
+	Identities         []userDataArchiveIdentity        `json:"identities"`
+	ContextInformation map[string]interface{}           `json:"context_information,omitempty"`
+	ProfileAttributes  map[string]interface{}           `json:"profile_attributes,omitempty"`
+	RoleAssignments    []userDataArchiveAssociation     `json:"role_assignments"`
+	Memberships        []userDataArchiveAssociation     `json:"memberships"`
+	Invitations        []userDataArchiveInvitation      `json:"invitations"`
+	ExternalAccounts   []userDataArchiveExternalAccount `json:"external_accounts"`
+	Tokens             []userDataArchiveToken           `json:"tokens"`
+	Sessions           []userDataArchiveSession         `json:"sessions"`
+	// the audit events recorded by this service, whether or not they were forwarded to the admin console service
+	AuditEvents []userDataArchiveAuditEvent `json:"audit_events"`
+}
+
+type userDataArchiveUser struct {
+	ID            uuid.UUID `json:"id"`
+	Email         string    `json:"email"`
+	EmailPrivate  bool      `json:"email_private"`
+	EmailVerified bool      `json:"email_verified"`
+	FullName      string    `json:"full_name"`
+	ImageURL      string    `json:"image_url"`
+	Bio           string    `json:"bio"`
+	URL           string    `json:"url"`
+	Company       string    `json:"company"`
+	FeatureLevel  string    `json:"feature_level"`
+	Cluster       string    `json:"cluster"`
+	Banned        bool      `json:"banned"`
+	Active        bool      `json:"active"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type userDataArchiveIdentity struct {
+	ID                       uuid.UUID  `json:"id"`
+	Username                 string     `json:"username"`
+	ProviderType             string     `json:"provider_type"`
+	ProfileURL               *string    `json:"profile_url,omitempty"`
+	RegistrationCompleted    bool       `json:"registration_completed"`
+	LastActive               *time.Time `json:"last_active,omitempty"`
+	DeactivationNotification *time.Time `json:"deactivation_notification,omitempty"`
+	DeactivationScheduled    *time.Time `json:"deactivation_scheduled,omitempty"`
+	DeletionRequested        *time.Time `json:"deletion_requested,omitempty"`
+	CreatedAt                time.Time  `json:"created_at"`
+	UpdatedAt                time.Time  `json:"updated_at"`
+}
+
+type userDataArchiveAssociation struct {
+	IdentityID       uuid.UUID `json:"identity_id"`
+	ResourceID       string    `json:"resource_id"`
+	ResourceName     string    `json:"resource_name"`
+	ParentResourceID *string   `json:"parent_resource_id,omitempty"`
+	Member           bool      `json:"member"`
+	Roles            []string  `json:"roles"`
+}
+
+type userDataArchiveInvitation struct {
+	InvitationID uuid.UUID  `json:"invitation_id"`
+	IdentityID   uuid.UUID  `json:"identity_id"`
+	InviteTo     *uuid.UUID `json:"invite_to,omitempty"`
+	ResourceID   *string    `json:"resource_id,omitempty"`
+	Member       bool       `json:"member"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+type userDataArchiveExternalAccount struct {
+	IdentityID uuid.UUID `json:"identity_id"`
+	ProviderID uuid.UUID `json:"provider_id"`
+	Username   string    `json:"username"`
+	Scope      string    `json:"scope"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type userDataArchiveToken struct {
+	TokenID    uuid.UUID  `json:"token_id"`
+	IdentityID uuid.UUID  `json:"identity_id"`
+	TokenType  string     `json:"token_type"`
+	Status     int        `json:"status"`
+	ExpiryTime time.Time  `json:"expiry_time"`
+	SessionID  *uuid.UUID `json:"session_id,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type userDataArchiveSession struct {
+	SessionID  uuid.UUID `json:"session_id"`
+	IdentityID uuid.UUID `json:"identity_id"`
+	ClientID   string    `json:"client_id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	LastUsed   time.Time `json:"last_used"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type userDataArchiveAuditEvent struct {
+	IdentityID uuid.UUID `json:"identity_id"`
+	EventType  string    `json:"event_type"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ExportForIdentity returns the export of the data of the given identity. A ready export is returned as-is until it
+// expires, otherwise a new export is requested. The content of the export is generated immediately unless the account
+// is too large, in which case the pending export is returned and the content is generated later by the worker.
+func (s *userDataExportServiceImpl) ExportForIdentity(ctx context.Context, identityID uuid.UUID) (*repository.UserDataExport, error) {
+	var export *repository.UserDataExport
+	err := s.ExecuteInTransaction(func() error {
+		_, err := s.Repositories().Identities().Load(ctx, identityID)
+		if err != nil {
+			return err
+		}
+		latest, err := s.Repositories().UserDataExports().LoadLatestForIdentity(ctx, identityID)
+		if err != nil {
+			if notFound, _ := errors.IsNotFoundError(err); !notFound {
+				return err
+			}
+		} else if latest.Status == repository.UserDataExportStatusPending ||
+			(latest.Status == repository.UserDataExportStatusReady && !latest.Expired(time.Now())) {
+			export = latest
+			return nil
+		}
+		export = &repository.UserDataExport{
+			IdentityID: identityID,
+			Status:     repository.UserDataExportStatusPending,
+		}
+		return s.Repositories().UserDataExports().Create(ctx, export)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if export.Status != repository.UserDataExportStatusPending {
+		return export, nil
+	}
+	count, err := s.Repositories().TokenRepository().CountForIdentity(ctx, identityID)
+	if err != nil {
+		return nil, err
+	}
+	if count > s.config.GetUserDataExportSyncTokenLimit() {
+		log.Info(ctx, map[string]interface{}{
+			"identity_id":         identityID,
+			"user_data_export_id": export.UserDataExportID,
+			"tokens":              count,
+		}, "the user data export will be generated asynchronously")
+		return export, nil
+	}
+	err = s.generate(ctx, export)
+	if err != nil {
+		return nil, err
+	}
+	if export.Status == repository.UserDataExportStatusFailed {
+		return nil, errors.NewInternalErrorFromString("unable to generate the user data export")
+	}
+	return export, nil
+}
+
+// ExportForUsername returns the export of the data of the user with the given username. See ExportForIdentity.
+func (s *userDataExportServiceImpl) ExportForUsername(ctx context.Context, username string) (*repository.UserDataExport, error) {
+	identities, err := s.Repositories().Identities().Query(
+		repository.IdentityFilterByUsername(username),
+		repository.IdentityFilterByProviderType(repository.DefaultIDP))
+	if err != nil {
+		return nil, err
+	}
+	if len(identities) == 0 {
+		return nil, errors.NewNotFoundErrorWithKey("user identity", "username", username)
+	}
+	return s.ExportForIdentity(ctx, identities[0].ID)
+}
+
+// GeneratePendingExports removes the expired exports and generates the content of the pending ones, the oldest first.
+// Returns the number of exports which were generated.
+func (s *userDataExportServiceImpl) GeneratePendingExports(ctx context.Context) (int, error) {
+	var exports []repository.UserDataExport
+	err := s.ExecuteInTransaction(func() error {
+		deleted, err := s.Repositories().UserDataExports().DeleteExpired(ctx, time.Now())
+		if err != nil {
+			return err
+		}
+		if deleted > 0 {
+			log.Info(ctx, map[string]interface{}{
+				"exports": deleted,
+			}, "removed expired user data exports")
+		}
+		exports, err = s.Repositories().UserDataExports().ListPending(ctx, s.config.GetUserDataExportBatchSize())
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	generated := 0
+	for _, e := range exports {
+		export := e
+		err := s.generate(ctx, &export)
+		if err != nil {
+			return generated, err
+		}
+		if export.Status == repository.UserDataExportStatusReady {
+			generated++
+		}
+	}
+	return generated, nil
+}
+
+// generate collects the data of the user and stores it in the given export. If the data could not be collected,
+// the export is marked as failed, so that the user can request a new one.
+func (s *userDataExportServiceImpl) generate(ctx context.Context, export *repository.UserDataExport) error {
+	content, err := s.collect(ctx, export.IdentityID)
+	now := time.Now()
+	expiresAt := now.Add(s.config.GetUserDataExportRetention())
+	export.CompletedAt = &now
+	export.ExpiresAt = &expiresAt
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id":         export.IdentityID,
+			"user_data_export_id": export.UserDataExportID,
+			"err":                 err,
+		}, "unable to generate the user data export")
+		export.Status = repository.UserDataExportStatusFailed
+	} else {
+		export.Status = repository.UserDataExportStatusReady
+		export.Content = content
+	}
+	return s.ExecuteInTransaction(func() error {
+		return s.Repositories().UserDataExports().Save(ctx, export)
+	})
+}
+
+// collect assembles the archive of the data of the user who owns the given identity
+func (s *userDataExportServiceImpl) collect(ctx context.Context, identityID uuid.UUID) (account.ContextInformation, error) {
+	identity, err := s.Repositories().Identities().LoadWithUser(ctx, identityID)
+	if err != nil {
+		return nil, err
+	}
+	archive := userDataArchive{
+		ExportedAt:       time.Now(),
+		Identities:       []userDataArchiveIdentity{},
+		RoleAssignments:  []userDataArchiveAssociation{},
+		Memberships:      []userDataArchiveAssociation{},
+		Invitations:      []userDataArchiveInvitation{},
+		ExternalAccounts: []userDataArchiveExternalAccount{},
+		Tokens:           []userDataArchiveToken{},
+		Sessions:         []userDataArchiveSession{},
+		AuditEvents:      []userDataArchiveAuditEvent{},
+	}
+	identities := []repository.Identity{*identity}
+	if identity.IsUser() {
+		user := identity.User
+		archive.User = &userDataArchiveUser{
+			ID:            user.ID,
+			Email:         user.Email,
+			EmailPrivate:  user.EmailPrivate,
+			EmailVerified: user.EmailVerified,
+			FullName:      user.FullName,
+			ImageURL:      user.ImageURL,
+			Bio:           user.Bio,
+			URL:           user.URL,
+			Company:       user.Company,
+			FeatureLevel:  user.FeatureLevel,
+			Cluster:       user.Cluster,
+			Banned:        user.Banned,
+			Active:        user.Active,
+			CreatedAt:     user.CreatedAt,
+			UpdatedAt:     user.UpdatedAt,
+		}
+		archive.ContextInformation = user.ContextInformation
+		archive.ProfileAttributes = user.ProfileAttributes
+		identities, err = s.Repositories().Identities().Query(repository.IdentityFilterByUserID(user.ID))
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, i := range identities {
+		archive.Identities = append(archive.Identities, userDataArchiveIdentity{
+			ID:                       i.ID,
+			Username:                 i.Username,
+			ProviderType:             i.ProviderType,
+			ProfileURL:               i.ProfileURL,
+			RegistrationCompleted:    i.RegistrationCompleted,
+			LastActive:               i.LastActive,
+			DeactivationNotification: i.DeactivationNotification,
+			DeactivationScheduled:    i.DeactivationScheduled,
+			DeletionRequested:        i.DeletionRequested,
+			CreatedAt:                i.CreatedAt,
+			UpdatedAt:                i.UpdatedAt,
+		})
+		err = s.collectForIdentity(ctx, i.ID, &archive)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// store the archive in a generic form, as it is kept in a JSON column
+	data, err := json.Marshal(archive)
+	if err != nil {
+		return nil, errs.Wrap(err, "unable to encode the user data export")
+	}
+	content := account.ContextInformation{}
+	err = json.Unmarshal(data, &content)
+	if err != nil {
+		return nil, errs.Wrap(err, "unable to encode the user data export")
+	}
+	return content, nil
+}
+
+// collectForIdentity appends the data related to the given identity to the archive
+func (s *userDataExportServiceImpl) collectForIdentity(ctx context.Context, identityID uuid.UUID, archive *userDataArchive) error {
+	roles, err := s.Repositories().IdentityRoleRepository().FindIdentityRolesForIdentity(ctx, identityID, nil)
+	if err != nil {
+		return err
+	}
+	archive.RoleAssignments = append(archive.RoleAssignments, convertToArchiveAssociations(identityID, roles)...)
+	memberships, err := s.Repositories().Identities().FindIdentityMemberships(ctx, identityID, nil)
+	if err != nil {
+		return err
+	}
+	archive.Memberships = append(archive.Memberships, convertToArchiveAssociations(identityID, memberships)...)
+
+	invitations, err := s.Repositories().InvitationRepository().ListForInvitee(ctx, identityID)
+	if err != nil {
+		return err
+	}
+	for _, i := range invitations {
+		archive.Invitations = append(archive.Invitations, userDataArchiveInvitation{
+			InvitationID: i.InvitationID,
+			IdentityID:   i.IdentityID,
+			InviteTo:     i.InviteTo,
+			ResourceID:   i.ResourceID,
+			Member:       i.Member,
+			CreatedAt:    i.CreatedAt,
+		})
+	}
+
+	externalTokens, err := s.Repositories().ExternalTokens().Query(tokenrepo.ExternalTokenFilterByIdentityID(identityID))
+	if err != nil {
+		return err
+	}
+	for _, t := range externalTokens {
+		archive.ExternalAccounts = append(archive.ExternalAccounts, userDataArchiveExternalAccount{
+			IdentityID: t.IdentityID,
+			ProviderID: t.ProviderID,
+			Username:   t.Username,
+			Scope:      t.Scope,
+			CreatedAt:  t.CreatedAt,
+			UpdatedAt:  t.UpdatedAt,
+		})
+	}
+
+	tokens, err := s.Repositories().TokenRepository().ListForIdentity(ctx, identityID)
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		archive.Tokens = append(archive.Tokens, userDataArchiveToken{
+			TokenID:    t.TokenID,
+			IdentityID: t.IdentityID,
+			TokenType:  t.TokenType,
+			Status:     t.Status,
+			ExpiryTime: t.ExpiryTime,
+			SessionID:  t.SessionID,
+			CreatedAt:  t.CreatedAt,
+		})
+	}
+
+	sessions, err := s.Repositories().SessionRepository().ListForIdentity(ctx, identityID)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		archive.Sessions = append(archive.Sessions, userDataArchiveSession{
+			SessionID:  session.SessionID,
+			IdentityID: session.IdentityID,
+			ClientID:   session.ClientID,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			LastUsed:   session.LastUsed,
+			CreatedAt:  session.CreatedAt,
+		})
+	}
+
+	events, err := s.Repositories().AuditEvents().ListForIdentity(ctx, identityID)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		archive.AuditEvents = append(archive.AuditEvents, userDataArchiveAuditEvent{
+			IdentityID: e.IdentityID,
+			EventType:  e.EventType,
+			CreatedAt:  e.CreatedAt,
+		})
+	}
+	return nil
+}
+
+func convertToArchiveAssociations(identityID uuid.UUID, associations []authorization.IdentityAssociation) []userDataArchiveAssociation {
+	result := make([]userDataArchiveAssociation, len(associations))
+	for i, a := range associations {
+		result[i] = userDataArchiveAssociation{
+			IdentityID:       identityID,
+			ResourceID:       a.ResourceID,
+			ResourceName:     a.ResourceName,
+			ParentResourceID: a.ParentResourceID,
+			Member:           a.Member,
+			Roles:            a.Roles,
+		}
+	}
+	return result
+}
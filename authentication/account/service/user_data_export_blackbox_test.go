@@ -0,0 +1,195 @@
+package service_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application/service"
+	"github.com/fabric8-services/fabric8-auth/application/service/factory"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	userservice "github.com/fabric8-services/fabric8-auth/authentication/account/service"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestUserDataExportService(t *testing.T) {
+	suite.Run(t, &userDataExportServiceBlackboxTestSuite{
+		DBTestSuite: gormtestsupport.NewDBTestSuite(),
+	})
+}
+
+type userDataExportServiceBlackboxTestSuite struct {
+	gormtestsupport.DBTestSuite
+}
+
+type userDataExportConfig struct {
+	syncTokenLimit int
+}
+
+func (c userDataExportConfig) GetUserDataExportBatchSize() int {
+	return 10
+}
+
+func (c userDataExportConfig) GetUserDataExportSyncTokenLimit() int {
+	return c.syncTokenLimit
+}
+
+func (c userDataExportConfig) GetUserDataExportRetention() time.Duration {
+	return time.Hour
+}
+
+func (s *userDataExportServiceBlackboxTestSuite) newUserDataExportService(syncTokenLimit int) service.UserDataExportService {
+	svcCtx := factory.NewServiceContext(s.Application, s.Application, s.Configuration, nil)
+	return userservice.NewUserDataExportService(svcCtx, userDataExportConfig{syncTokenLimit: syncTokenLimit})
+}
+
+func (s *userDataExportServiceBlackboxTestSuite) TestExportForIdentity() {
+
+	s.T().Run("generated immediately", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		s.Graph.CreateSpace().AddAdmin(user)
+		s.Graph.CreateOrganization().AddMember(user)
+		s.Graph.CreateToken(user)
+		externalToken := s.Graph.CreateExternalToken(user, uuid.NewV4().String())
+		// when
+		export, err := s.newUserDataExportService(10).ExportForIdentity(s.Ctx, user.IdentityID())
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, repository.UserDataExportStatusReady, export.Status)
+		require.NotNil(t, export.CompletedAt)
+		require.NotNil(t, export.ExpiresAt)
+		exportedUser, ok := export.Content["user"].(map[string]interface{})
+		require.True(t, ok)
+		assert.Equal(t, user.User().Email, exportedUser["email"])
+		assert.Len(t, export.Content["identities"], 1)
+		assert.Len(t, export.Content["role_assignments"], 1)
+		assert.Len(t, export.Content["memberships"], 1)
+		assert.Len(t, export.Content["tokens"], 1)
+		assert.Len(t, export.Content["external_accounts"], 1)
+		// secrets are never exported
+		data, err := json.Marshal(export.Content)
+		require.NoError(t, err)
+		loadedExternalToken, err := s.Application.ExternalTokens().Load(s.Ctx, externalToken.ID())
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), loadedExternalToken.Token)
+	})
+
+	s.T().Run("audit events forwarded to the admin console service are exported", func(t *testing.T) {
+		// given
+		source := s.Graph.CreateUser()
+		target := s.Graph.CreateUser()
+		_, err := s.Application.UserMergeService().Merge(s.Ctx, source.Identity().Username, target.Identity().Username)
+		require.NoError(t, err)
+		// the outbox events are deleted once delivered
+		events, err := s.Application.OutboxEvents().ListForIdentity(s.Ctx, target.IdentityID())
+		require.NoError(t, err)
+		require.NotEmpty(t, events)
+		for _, e := range events {
+			err = s.Application.OutboxEvents().Delete(s.Ctx, e.OutboxEventID)
+			require.NoError(t, err)
+		}
+		// when
+		export, err := s.newUserDataExportService(10).ExportForIdentity(s.Ctx, target.IdentityID())
+		// then
+		require.NoError(t, err)
+		require.Equal(t, repository.UserDataExportStatusReady, export.Status)
+		auditEvents, ok := export.Content["audit_events"].([]interface{})
+		require.True(t, ok)
+		require.Len(t, auditEvents, 1)
+		auditEvent, ok := auditEvents[0].(map[string]interface{})
+		require.True(t, ok)
+		assert.Equal(t, userservice.UserMergeAuditLogEvent, auditEvent["event_type"])
+	})
+
+	s.T().Run("ready export is reused", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		exportSvc := s.newUserDataExportService(10)
+		first, err := exportSvc.ExportForIdentity(s.Ctx, user.IdentityID())
+		require.NoError(t, err)
+		// when
+		second, err := exportSvc.ExportForIdentity(s.Ctx, user.IdentityID())
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, first.UserDataExportID, second.UserDataExportID)
+	})
+
+	s.T().Run("generated asynchronously", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		s.Graph.CreateToken(user)
+		exportSvc := s.newUserDataExportService(0)
+		// when
+		export, err := exportSvc.ExportForIdentity(s.Ctx, user.IdentityID())
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, repository.UserDataExportStatusPending, export.Status)
+		assert.Nil(t, export.Content)
+		// until the worker generates the export
+		generated, err := exportSvc.GeneratePendingExports(s.Ctx)
+		require.NoError(t, err)
+		assert.True(t, generated >= 1)
+		loaded, err := s.Application.UserDataExports().Load(s.Ctx, export.UserDataExportID)
+		require.NoError(t, err)
+		assert.Equal(t, repository.UserDataExportStatusReady, loaded.Status)
+		assert.Len(t, loaded.Content["tokens"], 1)
+	})
+
+	s.T().Run("expired export is removed", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		exportSvc := s.newUserDataExportService(10)
+		export, err := exportSvc.ExportForIdentity(s.Ctx, user.IdentityID())
+		require.NoError(t, err)
+		expiresAt := time.Now().Add(-1 * time.Minute)
+		export.ExpiresAt = &expiresAt
+		err = s.Application.UserDataExports().Save(s.Ctx, export)
+		require.NoError(t, err)
+		// when
+		_, err = exportSvc.GeneratePendingExports(s.Ctx)
+		// then
+		require.NoError(t, err)
+		_, err = s.Application.UserDataExports().Load(s.Ctx, export.UserDataExportID)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+		// and a new export can be requested
+		renewed, err := exportSvc.ExportForIdentity(s.Ctx, user.IdentityID())
+		require.NoError(t, err)
+		assert.NotEqual(t, export.UserDataExportID, renewed.UserDataExportID)
+	})
+
+	s.T().Run("unknown identity", func(t *testing.T) {
+		// when
+		_, err := s.newUserDataExportService(10).ExportForIdentity(s.Ctx, uuid.NewV4())
+		// then
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}
+
+func (s *userDataExportServiceBlackboxTestSuite) TestExportForUsername() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		// when
+		export, err := s.newUserDataExportService(10).ExportForUsername(s.Ctx, user.Identity().Username)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, user.IdentityID(), export.IdentityID)
+		assert.Equal(t, repository.UserDataExportStatusReady, export.Status)
+	})
+
+	s.T().Run("unknown username", func(t *testing.T) {
+		// when
+		_, err := s.newUserDataExportService(10).ExportForUsername(s.Ctx, uuid.NewV4().String())
+		// then
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}
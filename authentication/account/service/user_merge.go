@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application/service"
+	"github.com/fabric8-services/fabric8-auth/application/service/base"
+	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization/token"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// UserMergeAuditLogEvent the type of the audit log recorded for both users when they are merged
+const UserMergeAuditLogEvent = "user_merge"
+
+// NewUserMergeService creates a new service to merge the duplicate accounts of a person
+func NewUserMergeService(ctx servicecontext.ServiceContext) service.UserMergeService {
+	return &userMergeServiceImpl{
+		BaseService: base.NewBaseService(ctx),
+	}
+}
+
+// userMergeServiceImpl implements the UserMergeService. The identities of the source user are moved to the target
+// user, unless the target user already has an identity of the same provider type: in that case, the roles, memberships,
+// invitations, tokens and external tokens of the source identity are transferred to the identity of the target user,
+// and the source identity is deleted. The attributes of the target user are kept as-is.
+type userMergeServiceImpl struct {
+	base.BaseService
+}
+
+// Merge merges the user with the given source username into the user with the given target username, and returns the
+// identity of the target user. The source user is deleted.
+func (s *userMergeServiceImpl) Merge(ctx context.Context, sourceUsername, targetUsername string) (*repository.Identity, error) {
+	if sourceUsername == targetUsername {
+		return nil, errors.NewBadParameterErrorFromString("source", sourceUsername, "a user cannot be merged with itself")
+	}
+	var targetIdentityID, sourceUserID uuid.UUID
+	err := s.ExecuteInTransaction(func() error {
+		source, err := s.loadUserIdentity(ctx, sourceUsername)
+		if err != nil {
+			return err
+		}
+		target, err := s.loadUserIdentity(ctx, targetUsername)
+		if err != nil {
+			return err
+		}
+		if source.User.ID == target.User.ID {
+			return errors.NewBadParameterErrorFromString("source", sourceUsername, "the identities already belong to the same user")
+		}
+		// a merge must not be a way to escape a ban
+		if source.User.Banned || target.User.Banned {
+			return errors.NewBadParameterErrorFromString("source", sourceUsername, "a banned user cannot be merged")
+		}
+		kept, err := s.identitiesByProviderType(ctx, *target)
+		if err != nil {
+			return err
+		}
+		identities, err := s.Repositories().Identities().Query(repository.IdentityFilterByUserID(source.User.ID))
+		if err != nil {
+			return err
+		}
+		for _, identity := range identities {
+			if keptIdentity, found := kept[identity.ProviderType]; found {
+				err = s.mergeIdentity(ctx, identity, keptIdentity)
+			} else {
+				identity.UserID = repository.NullUUID{UUID: target.User.ID, Valid: true}
+				identity.User = target.User
+				err = s.Repositories().Identities().Save(ctx, &identity)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		err = s.Repositories().Users().Delete(ctx, source.User.ID)
+		if err != nil {
+			return err
+		}
+		err = enqueueAuditLog(ctx, s, repository.NewAuditLogEvent(source.ID, source.Username, UserMergeAuditLogEvent))
+		if err != nil {
+			return err
+		}
+		// the target user may be merged with several users before the events are delivered
+		event := repository.NewAuditLogEvent(target.ID, target.Username, UserMergeAuditLogEvent)
+		event.DeduplicationKey = fmt.Sprintf("%s:%s", event.DeduplicationKey, source.ID)
+		err = enqueueAuditLog(ctx, s, event)
+		if err != nil {
+			return err
+		}
+		targetIdentityID, sourceUserID = target.ID, source.User.ID
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"source_user_id":     sourceUserID,
+		"source_username":    sourceUsername,
+		"target_identity_id": targetIdentityID,
+		"target_username":    targetUsername,
+	}, "users merged")
+	return s.Repositories().Identities().LoadWithUser(ctx, targetIdentityID)
+}
+
+// loadUserIdentity returns the identity of the user with the given username, along with the user
+func (s *userMergeServiceImpl) loadUserIdentity(ctx context.Context, username string) (*repository.Identity, error) {
+	identities, err := s.Repositories().Identities().Query(
+		repository.IdentityWithUser(),
+		repository.IdentityFilterByUsername(username),
+		repository.IdentityFilterByProviderType(repository.DefaultIDP))
+	if err != nil {
+		return nil, err
+	}
+	if len(identities) == 0 || !identities[0].IsUser() {
+		return nil, errors.NewNotFoundErrorWithKey("user identity", "username", username)
+	}
+	return &identities[0], nil
+}
+
+// identitiesByProviderType returns the identities of the user of the given identity, indexed by provider type. The
+// given identity is the one of its provider type, and for the other provider types, the oldest identity is used.
+func (s *userMergeServiceImpl) identitiesByProviderType(ctx context.Context, identity repository.Identity) (map[string]repository.Identity, error) {
+	identities, err := s.Repositories().Identities().Query(repository.IdentityFilterByUserID(identity.User.ID))
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]repository.Identity, len(identities))
+	for _, i := range identities {
+		if existing, found := result[i.ProviderType]; !found || i.CreatedAt.Before(existing.CreatedAt) {
+			result[i.ProviderType] = i
+		}
+	}
+	result[identity.ProviderType] = identity
+	return result, nil
+}
+
+// mergeIdentity transfers the roles, memberships, invitations, tokens and external tokens of the source identity to
+// the target identity, then deletes the source identity. The tokens issued to the source identity are revoked, since
+// their subject does not exist anymore, and the privilege cache of the target identity is flagged as stale. The
+// username of the source identity is recorded as a previous username of the target identity.
+func (s *userMergeServiceImpl) mergeIdentity(ctx context.Context, source, target repository.Identity) error {
+	err := s.Repositories().IdentityRoleRepository().TransferToIdentity(ctx, source.ID, target.ID)
+	if err != nil {
+		return errs.Wrapf(err, "unable to transfer the roles of identity '%s'", source.ID)
+	}
+	err = s.Repositories().Identities().TransferMemberships(ctx, source.ID, target.ID)
+	if err != nil {
+		return errs.Wrapf(err, "unable to transfer the memberships of identity '%s'", source.ID)
+	}
+	err = s.Repositories().InvitationRepository().TransferToInvitee(ctx, source.ID, target.ID)
+	if err != nil {
+		return errs.Wrapf(err, "unable to transfer the invitations of identity '%s'", source.ID)
+	}
+	err = s.Repositories().TokenRepository().SetStatusFlagsForIdentity(ctx, source.ID, token.TOKEN_STATUS_REVOKED)
+	if err != nil {
+		return errs.Wrapf(err, "unable to revoke the tokens of identity '%s'", source.ID)
+	}
+	err = s.Repositories().TokenRepository().TransferToIdentity(ctx, source.ID, target.ID)
+	if err != nil {
+		return errs.Wrapf(err, "unable to transfer the tokens of identity '%s'", source.ID)
+	}
+	err = s.Repositories().ExternalTokens().TransferToIdentity(ctx, source.ID, target.ID)
+	if err != nil {
+		return errs.Wrapf(err, "unable to transfer the external tokens of identity '%s'", source.ID)
+	}
+	err = s.Repositories().PrivilegeCacheRepository().FlagStaleForIdentity(ctx, target.ID)
+	if err != nil {
+		return errs.Wrapf(err, "unable to invalidate the privilege cache of identity '%s'", target.ID)
+	}
+	// the previous username is not reserved, since the source identity is deleted
+	err = s.Repositories().UsernameHistory().Create(ctx, &repository.UsernameHistory{
+		IdentityID:    target.ID,
+		Username:      source.Username,
+		ReservedUntil: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return s.Repositories().Identities().Delete(ctx, source.ID)
+}
@@ -0,0 +1,160 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	userservice "github.com/fabric8-services/fabric8-auth/authentication/account/service"
+	"github.com/fabric8-services/fabric8-auth/authentication/provider"
+	permission "github.com/fabric8-services/fabric8-auth/authorization/permission/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization/token"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestUserMergeService(t *testing.T) {
+	suite.Run(t, &userMergeServiceBlackboxTestSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+type userMergeServiceBlackboxTestSuite struct {
+	gormtestsupport.DBTestSuite
+}
+
+func (s *userMergeServiceBlackboxTestSuite) TestMerge() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		source := s.Graph.CreateUser()
+		target := s.Graph.CreateUser()
+		// an identity of another provider type, which the target user does not have
+		linked := &repository.Identity{
+			Username:     "linked-" + uuid.NewV4().String(),
+			ProviderType: "testProvider",
+			UserID:       repository.NullUUID{UUID: source.User().ID, Valid: true},
+		}
+		require.NoError(t, s.Application.Identities().Create(s.Ctx, linked))
+		// memberships, one of which both users have
+		group := s.Graph.CreateGroup().AddMember(source)
+		org := s.Graph.CreateOrganization().AddMember(source).AddMember(target)
+		// roles, one of which both users have
+		space := s.Graph.CreateSpace().AddAdmin(source)
+		sharedSpace := s.Graph.CreateSpace().AddAdmin(source).AddAdmin(target)
+		s.Graph.CreateInvitation(s.Graph.CreateTeam(), source)
+		sourceToken := s.Graph.CreateToken(source)
+		duplicateExternalToken := s.Graph.CreateExternalToken(source, provider.GitHubProviderID)
+		s.Graph.CreateExternalToken(target, provider.GitHubProviderID)
+		externalToken := s.Graph.CreateExternalToken(source, "1234eee5-d01a-4119-9893-292a7d39b49e")
+		privilegeCache := &permission.PrivilegeCache{
+			IdentityID: target.IdentityID(),
+			ResourceID: sharedSpace.SpaceID(),
+			Scopes:     "view",
+		}
+		require.NoError(t, s.Application.PrivilegeCacheRepository().Create(s.Ctx, privilegeCache))
+
+		// when
+		result, err := s.Application.UserMergeService().Merge(s.Ctx, source.Identity().Username, target.Identity().Username)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, target.IdentityID(), result.ID)
+		assert.Equal(t, target.User().ID, result.User.ID)
+		// the source user and its identity are deleted
+		_, err = s.Application.Users().Load(s.Ctx, source.User().ID)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+		_, err = s.Application.Identities().Load(s.Ctx, source.IdentityID())
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+		// the identity of the other provider type is moved to the target user
+		moved, err := s.Application.Identities().Load(s.Ctx, linked.ID)
+		require.NoError(t, err)
+		assert.Equal(t, target.User().ID, moved.UserID.UUID)
+		// the memberships are transferred, including the transitive ones
+		members, err := s.Application.Identities().Query(repository.IdentityFilterByMemberOf(group.GroupID(), true))
+		require.NoError(t, err)
+		require.Len(t, members, 1)
+		assert.Equal(t, target.IdentityID(), members[0].ID)
+		members, err = s.Application.Identities().Query(repository.IdentityFilterByMemberOf(org.OrganizationID(), false))
+		require.NoError(t, err)
+		require.Len(t, members, 1)
+		assert.Equal(t, target.IdentityID(), members[0].ID)
+		// the roles are transferred, without duplicates
+		roles, err := s.Application.IdentityRoleRepository().FindIdentityRolesByIdentityAndResource(s.Ctx, space.SpaceID(), target.IdentityID())
+		require.NoError(t, err)
+		assert.Len(t, roles, 1)
+		roles, err = s.Application.IdentityRoleRepository().FindIdentityRolesByIdentityAndResource(s.Ctx, sharedSpace.SpaceID(), target.IdentityID())
+		require.NoError(t, err)
+		assert.Len(t, roles, 1)
+		// the invitations are transferred
+		invitations, err := s.Application.InvitationRepository().ListForInvitee(s.Ctx, target.IdentityID())
+		require.NoError(t, err)
+		assert.Len(t, invitations, 1)
+		// the tokens are transferred and revoked
+		tkn, err := s.Application.TokenRepository().Load(s.Ctx, sourceToken.TokenID())
+		require.NoError(t, err)
+		assert.Equal(t, target.IdentityID(), tkn.IdentityID)
+		assert.True(t, tkn.HasStatus(token.TOKEN_STATUS_REVOKED))
+		// the external tokens are transferred, except for the providers to which the target is already linked
+		_, err = s.Application.ExternalTokens().Load(s.Ctx, duplicateExternalToken.ID())
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+		externalTkn, err := s.Application.ExternalTokens().Load(s.Ctx, externalToken.ID())
+		require.NoError(t, err)
+		assert.Equal(t, target.IdentityID(), externalTkn.IdentityID)
+		// the privilege cache of the target is invalidated
+		cache, err := s.Application.PrivilegeCacheRepository().Load(s.Ctx, privilegeCache.PrivilegeCacheID)
+		require.NoError(t, err)
+		assert.True(t, cache.Stale)
+		// the previous username of the source identity now resolves to the target identity
+		identities, err := s.Application.Identities().Query(repository.IdentityFilterByUsername(source.Identity().Username))
+		require.NoError(t, err)
+		require.Len(t, identities, 1)
+		assert.Equal(t, target.IdentityID(), identities[0].ID)
+		// an audit log is recorded for both users
+		for _, identityID := range []uuid.UUID{source.IdentityID(), target.IdentityID()} {
+			events, err := s.Application.OutboxEvents().ListForIdentity(s.Ctx, identityID)
+			require.NoError(t, err)
+			require.Len(t, events, 1)
+			_, auditLogType, err := events[0].AuditLog()
+			require.NoError(t, err)
+			assert.Equal(t, userservice.UserMergeAuditLogEvent, auditLogType)
+		}
+	})
+
+	s.T().Run("same user", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		// when
+		_, err := s.Application.UserMergeService().Merge(s.Ctx, user.Identity().Username, user.Identity().Username)
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+	})
+
+	s.T().Run("banned user", func(t *testing.T) {
+		// given
+		source := s.Graph.CreateUser()
+		source.Ban()
+		target := s.Graph.CreateUser()
+		// when
+		_, err := s.Application.UserMergeService().Merge(s.Ctx, source.Identity().Username, target.Identity().Username)
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		_, err = s.Application.Users().Load(s.Ctx, source.User().ID)
+		require.NoError(t, err)
+	})
+
+	s.T().Run("unknown user", func(t *testing.T) {
+		// given
+		target := s.Graph.CreateUser()
+		// when
+		_, err := s.Application.UserMergeService().Merge(s.Ctx, "unknown-"+uuid.NewV4().String(), target.Identity().Username)
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}
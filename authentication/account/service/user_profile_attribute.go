@@ -0,0 +1,320 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fabric8-services/fabric8-auth/application/service"
+	"github.com/fabric8-services/fabric8-auth/application/service/base"
+	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
+	"github.com/fabric8-services/fabric8-auth/authentication/account"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	errs "github.com/pkg/errors"
+)
+
+// profileAttributeNamePattern the pattern which the names of the profile attributes must match
+var profileAttributeNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,62}$`)
+
+// NewUserProfileAttributeService creates a new service to manage the custom attributes of the user profiles
+func NewUserProfileAttributeService(ctx servicecontext.ServiceContext) service.UserProfileAttributeService {
+	return &userProfileAttributeServiceImpl{
+		BaseService: base.NewBaseService(ctx),
+	}
+}
+
+// userProfileAttributeServiceImpl implements the UserProfileAttributeService. The definitions of the attributes are
+// managed by the admins, and the values of each user are validated against them before they are stored in the
+// `profile_attributes` of the user.
+type userProfileAttributeServiceImpl struct {
+	base.BaseService
+}
+
+// ListAttributes returns the definitions of all the profile attributes, ordered by name
+func (s *userProfileAttributeServiceImpl) ListAttributes(ctx context.Context) ([]repository.UserProfileAttribute, error) {
+	return s.Repositories().UserProfileAttributes().List(ctx)
+}
+
+// SaveAttribute creates or updates the definition of a profile attribute. The values already set for an existing
+// attribute are not validated again against its new rules, and its type cannot be changed.
+func (s *userProfileAttributeServiceImpl) SaveAttribute(ctx context.Context, attribute repository.UserProfileAttribute) (*repository.UserProfileAttribute, error) {
+	if err := validateProfileAttribute(attribute); err != nil {
+		return nil, err
+	}
+	err := s.ExecuteInTransaction(func() error {
+		existing, err := s.Repositories().UserProfileAttributes().Load(ctx, attribute.Name)
+		if err != nil {
+			if notFound, _ := errors.IsNotFoundError(err); notFound {
+				return s.Repositories().UserProfileAttributes().Create(ctx, &attribute)
+			}
+			return err
+		}
+		if existing.Type != attribute.Type {
+			return errors.NewDataConflictError(fmt.Sprintf("the type of the profile attribute '%s' cannot be changed", attribute.Name))
+		}
+		attribute.CreatedAt = existing.CreatedAt
+		return s.Repositories().UserProfileAttributes().Save(ctx, &attribute)
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"name":       attribute.Name,
+		"type":       attribute.Type,
+		"visibility": attribute.Visibility,
+	}, "user profile attribute saved")
+	return &attribute, nil
+}
+
+// validateProfileAttribute checks that the definition of the attribute is consistent
+func validateProfileAttribute(attribute repository.UserProfileAttribute) error {
+	if !profileAttributeNamePattern.MatchString(attribute.Name) {
+		return errors.NewBadParameterError("name", attribute.Name).Expected(profileAttributeNamePattern.String())
+	}
+	if !repository.IsValidUserProfileAttributeType(attribute.Type) {
+		return errors.NewBadParameterError("type", attribute.Type).Expected(repository.UserProfileAttributeTypes)
+	}
+	if !repository.IsValidUserProfileAttributeVisibility(attribute.Visibility) {
+		return errors.NewBadParameterError("visibility", attribute.Visibility).Expected(repository.UserProfileAttributeVisibilities)
+	}
+	hasStringRules := attribute.Pattern != nil || attribute.MinLength != nil || attribute.MaxLength != nil || len(attribute.AllowedValues) > 0
+	if hasStringRules && attribute.Type != repository.UserProfileAttributeTypeString {
+		return errors.NewBadParameterErrorFromString("type", attribute.Type, "the pattern, lengths and allowed values only apply to string attributes")
+	}
+	hasIntegerRules := attribute.Minimum != nil || attribute.Maximum != nil
+	if hasIntegerRules && attribute.Type != repository.UserProfileAttributeTypeInteger {
+		return errors.NewBadParameterErrorFromString("type", attribute.Type, "the minimum and maximum only apply to integer attributes")
+	}
+	if attribute.Pattern != nil {
+		if _, err := regexp.Compile(*attribute.Pattern); err != nil {
+			return errors.NewBadParameterError("pattern", *attribute.Pattern).Expected("a valid regular expression")
+		}
+	}
+	if attribute.MinLength != nil && *attribute.MinLength < 0 {
+		return errors.NewBadParameterError("min_length", *attribute.MinLength).Expected("a positive length")
+	}
+	if attribute.MinLength != nil && attribute.MaxLength != nil && *attribute.MinLength > *attribute.MaxLength {
+		return errors.NewBadParameterError("max_length", *attribute.MaxLength).Expected("a length greater than the minimum length")
+	}
+	if attribute.Minimum != nil && attribute.Maximum != nil && *attribute.Minimum > *attribute.Maximum {
+		return errors.NewBadParameterError("maximum", *attribute.Maximum).Expected("a value greater than the minimum")
+	}
+	return nil
+}
+
+// DeleteAttribute removes the definition of the profile attribute with the given name, along with its values in the
+// profiles of all the users
+func (s *userProfileAttributeServiceImpl) DeleteAttribute(ctx context.Context, name string) error {
+	err := s.ExecuteInTransaction(func() error {
+		return s.Repositories().UserProfileAttributes().Delete(ctx, name)
+	})
+	if err != nil {
+		return err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"name": name,
+	}, "user profile attribute deleted")
+	return nil
+}
+
+// ApplyProfileAttributes validates the given values against the definitions of the profile attributes, and sets them
+// in the profile of the given user, which is not saved. A nil value removes the attribute from the profile. The
+// attributes which are not visible with the given visibility cannot be set, so only the admins can set the
+// `admin` attributes.
+func (s *userProfileAttributeServiceImpl) ApplyProfileAttributes(ctx context.Context, user *repository.User, values map[string]interface{}, visibility string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	attributes, err := s.attributesByName(ctx)
+	if err != nil {
+		return err
+	}
+	profile := account.ContextInformation{}
+	for name, value := range user.ProfileAttributes {
+		profile[name] = value
+	}
+	for name, value := range values {
+		attribute, found := attributes[name]
+		if !found {
+			return errors.NewBadParameterErrorFromString("profileAttributes", name, "unknown profile attribute")
+		}
+		if !attribute.IsVisibleTo(visibility) {
+			return errors.NewForbiddenError(fmt.Sprintf("the profile attribute '%s' can only be set by the admins", name))
+		}
+		if value == nil {
+			delete(profile, name)
+			continue
+		}
+		validValue, err := validateProfileAttributeValue(attribute, value)
+		if err != nil {
+			return err
+		}
+		profile[name] = validValue
+	}
+	user.ProfileAttributes = profile
+	return nil
+}
+
+// UpdateProfileAttributes sets the given values in the profile of the user with the given username, on behalf of an
+// admin. A nil value removes the attribute from the profile.
+func (s *userProfileAttributeServiceImpl) UpdateProfileAttributes(ctx context.Context, username string, values map[string]interface{}) (*repository.Identity, error) {
+	var identity *repository.Identity
+	err := s.ExecuteInTransaction(func() error {
+		identities, err := s.Repositories().Identities().Query(
+			repository.IdentityWithUser(),
+			repository.IdentityFilterByUsername(username),
+			repository.IdentityFilterByProviderType(repository.DefaultIDP))
+		if err != nil {
+			return err
+		}
+		if len(identities) == 0 {
+			return errors.NewNotFoundErrorWithKey("user identity", "username", username)
+		}
+		identity = &identities[0]
+		err = s.ApplyProfileAttributes(ctx, &identity.User, values, repository.UserProfileAttributeVisibilityAdmin)
+		if err != nil {
+			return err
+		}
+		return s.Repositories().Users().Save(ctx, &identity.User)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+// SearchFilter converts the given search filters, in the `name:value` form, into the values which the profiles of
+// the matching users must contain. Only the public attributes which are indexable can be searched.
+func (s *userProfileAttributeServiceImpl) SearchFilter(ctx context.Context, filters []string) (map[string]interface{}, error) {
+	if len(filters) == 0 {
+		return nil, nil
+	}
+	attributes, err := s.attributesByName(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{}
+	for _, filter := range filters {
+		parts := strings.SplitN(filter, ":", 2)
+		if len(parts) != 2 {
+			return nil, errors.NewBadParameterError("filter[profile]", filter).Expected("name:value")
+		}
+		attribute, found := attributes[parts[0]]
+		if !found || !attribute.Indexable || attribute.Visibility != repository.UserProfileAttributeVisibilityPublic {
+			return nil, errors.NewBadParameterErrorFromString("filter[profile]", parts[0], "not a searchable profile attribute")
+		}
+		var value interface{} = parts[1]
+		switch attribute.Type {
+		case repository.UserProfileAttributeTypeInteger:
+			if value, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+				return nil, errors.NewBadParameterError("filter[profile]", filter).Expected("an integer value")
+			}
+		case repository.UserProfileAttributeTypeBoolean:
+			if value, err = strconv.ParseBool(parts[1]); err != nil {
+				return nil, errors.NewBadParameterError("filter[profile]", filter).Expected("a boolean value")
+			}
+		}
+		result[attribute.Name] = value
+	}
+	return result, nil
+}
+
+// attributesByName returns the definitions of all the profile attributes, by name
+func (s *userProfileAttributeServiceImpl) attributesByName(ctx context.Context) (map[string]repository.UserProfileAttribute, error) {
+	attributes, err := s.Repositories().UserProfileAttributes().List(ctx)
+	if err != nil {
+		return nil, errs.Wrap(err, "unable to list the user profile attributes")
+	}
+	result := make(map[string]repository.UserProfileAttribute, len(attributes))
+	for _, attribute := range attributes {
+		result[attribute.Name] = attribute
+	}
+	return result, nil
+}
+
+// validateProfileAttributeValue checks that the given value follows the rules of the attribute, and returns it
+// converted to the type of the attribute
+func validateProfileAttributeValue(attribute repository.UserProfileAttribute, value interface{}) (interface{}, error) {
+	param := "profileAttributes." + attribute.Name
+	switch attribute.Type {
+	case repository.UserProfileAttributeTypeString:
+		s, ok := value.(string)
+		if !ok {
+			return nil, errors.NewBadParameterError(param, value).Expected("a string")
+		}
+		length := utf8.RuneCountInString(s)
+		if attribute.MinLength != nil && length < *attribute.MinLength {
+			return nil, errors.NewBadParameterError(param, s).Expected(fmt.Sprintf("at least %d characters", *attribute.MinLength))
+		}
+		if attribute.MaxLength != nil && length > *attribute.MaxLength {
+			return nil, errors.NewBadParameterError(param, s).Expected(fmt.Sprintf("at most %d characters", *attribute.MaxLength))
+		}
+		if attribute.Pattern != nil {
+			// the whole value must match the pattern
+			matched, err := regexp.MatchString("^(?:"+*attribute.Pattern+")$", s)
+			if err != nil || !matched {
+				return nil, errors.NewBadParameterError(param, s).Expected(*attribute.Pattern)
+			}
+		}
+		if len(attribute.AllowedValues) > 0 && !contains(attribute.AllowedValues, s) {
+			return nil, errors.NewBadParameterError(param, s).Expected([]string(attribute.AllowedValues))
+		}
+		return s, nil
+	case repository.UserProfileAttributeTypeInteger:
+		i, ok := toInteger(value)
+		if !ok {
+			return nil, errors.NewBadParameterError(param, value).Expected("an integer")
+		}
+		if attribute.Minimum != nil && i < *attribute.Minimum {
+			return nil, errors.NewBadParameterError(param, i).Expected(fmt.Sprintf("a value greater than or equal to %d", *attribute.Minimum))
+		}
+		if attribute.Maximum != nil && i > *attribute.Maximum {
+			return nil, errors.NewBadParameterError(param, i).Expected(fmt.Sprintf("a value less than or equal to %d", *attribute.Maximum))
+		}
+		return i, nil
+	case repository.UserProfileAttributeTypeBoolean:
+		b, ok := value.(bool)
+		if !ok {
+			return nil, errors.NewBadParameterError(param, value).Expected("a boolean")
+		}
+		return b, nil
+	}
+	return nil, errors.NewInternalErrorFromString(fmt.Sprintf("unknown type of profile attribute: '%s'", attribute.Type))
+}
+
+// toInteger converts the given JSON number to an integer, if it has no fractional part
+func toInteger(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		if v != math.Trunc(v) || math.IsInf(v, 0) {
+			return 0, false
+		}
+		return int64(v), true
+	case json.Number:
+		i, err := v.Int64()
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// contains returns true if the given value is one of the given values
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
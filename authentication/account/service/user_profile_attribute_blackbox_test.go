@@ -0,0 +1,307 @@
+package service_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestUserProfileAttributeService(t *testing.T) {
+	suite.Run(t, &userProfileAttributeServiceBlackboxTestSuite{
+		DBTestSuite: gormtestsupport.NewDBTestSuite(),
+	})
+}
+
+type userProfileAttributeServiceBlackboxTestSuite struct {
+	gormtestsupport.DBTestSuite
+}
+
+// newAttributeName returns a unique name of profile attribute
+func newAttributeName(prefix string) string {
+	return prefix + "_" + strings.Replace(uuid.NewV4().String(), "-", "", -1)
+}
+
+// saveAttribute saves the given attribute definition, which must be valid
+func (s *userProfileAttributeServiceBlackboxTestSuite) saveAttribute(t *testing.T, attribute repository.UserProfileAttribute) repository.UserProfileAttribute {
+	saved, err := s.Application.UserProfileAttributeService().SaveAttribute(s.Ctx, attribute)
+	require.NoError(t, err)
+	return *saved
+}
+
+func (s *userProfileAttributeServiceBlackboxTestSuite) TestSaveAttribute() {
+
+	s.T().Run("create and update", func(t *testing.T) {
+		// given
+		pattern := "[A-Z]{2}[0-9]{4}"
+		attribute := repository.UserProfileAttribute{
+			Name:       newAttributeName("cost_center"),
+			Type:       repository.UserProfileAttributeTypeString,
+			Visibility: repository.UserProfileAttributeVisibilityAdmin,
+			Pattern:    &pattern,
+		}
+		s.saveAttribute(t, attribute)
+		// when
+		attribute.Visibility = repository.UserProfileAttributeVisibilityPrivate
+		attribute.Pattern = nil
+		attribute.Indexable = true
+		s.saveAttribute(t, attribute)
+		// then
+		loaded, err := s.Application.UserProfileAttributes().Load(s.Ctx, attribute.Name)
+		require.NoError(t, err)
+		assert.Equal(t, repository.UserProfileAttributeVisibilityPrivate, loaded.Visibility)
+		assert.Nil(t, loaded.Pattern)
+		assert.True(t, loaded.Indexable)
+	})
+
+	s.T().Run("type cannot be changed", func(t *testing.T) {
+		// given
+		attribute := s.saveAttribute(t, repository.UserProfileAttribute{
+			Name:       newAttributeName("level"),
+			Type:       repository.UserProfileAttributeTypeInteger,
+			Visibility: repository.UserProfileAttributeVisibilityPublic,
+		})
+		// when
+		attribute.Type = repository.UserProfileAttributeTypeString
+		_, err := s.Application.UserProfileAttributeService().SaveAttribute(s.Ctx, attribute)
+		// then
+		require.IsType(t, errors.DataConflictError{}, errs.Cause(err))
+	})
+
+	s.T().Run("invalid definitions", func(t *testing.T) {
+		pattern := "[a-z"
+		minimum := int64(10)
+		maximum := int64(1)
+		minLength := 4
+		for name, attribute := range map[string]repository.UserProfileAttribute{
+			"invalid name":           {Name: "Time-Zone", Type: repository.UserProfileAttributeTypeString, Visibility: repository.UserProfileAttributeVisibilityPublic},
+			"unknown type":           {Name: newAttributeName("a"), Type: "date", Visibility: repository.UserProfileAttributeVisibilityPublic},
+			"unknown visibility":     {Name: newAttributeName("a"), Type: repository.UserProfileAttributeTypeString, Visibility: "internal"},
+			"invalid pattern":        {Name: newAttributeName("a"), Type: repository.UserProfileAttributeTypeString, Visibility: repository.UserProfileAttributeVisibilityPublic, Pattern: &pattern},
+			"minimum > maximum":      {Name: newAttributeName("a"), Type: repository.UserProfileAttributeTypeInteger, Visibility: repository.UserProfileAttributeVisibilityPublic, Minimum: &minimum, Maximum: &maximum},
+			"string rule on boolean": {Name: newAttributeName("a"), Type: repository.UserProfileAttributeTypeBoolean, Visibility: repository.UserProfileAttributeVisibilityPublic, MinLength: &minLength},
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := s.Application.UserProfileAttributeService().SaveAttribute(s.Ctx, attribute)
+				require.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+			})
+		}
+	})
+}
+
+func (s *userProfileAttributeServiceBlackboxTestSuite) TestApplyProfileAttributes() {
+	maxLength := 5
+	minimum := int64(1)
+	maximum := int64(10)
+	locale := s.saveAttribute(s.T(), repository.UserProfileAttribute{
+		Name:          newAttributeName("locale"),
+		Type:          repository.UserProfileAttributeTypeString,
+		Visibility:    repository.UserProfileAttributeVisibilityPublic,
+		MaxLength:     &maxLength,
+		AllowedValues: []string{"en_US", "fr_FR"},
+	})
+	level := s.saveAttribute(s.T(), repository.UserProfileAttribute{
+		Name:       newAttributeName("level"),
+		Type:       repository.UserProfileAttributeTypeInteger,
+		Visibility: repository.UserProfileAttributeVisibilityPrivate,
+		Minimum:    &minimum,
+		Maximum:    &maximum,
+	})
+	costCenter := s.saveAttribute(s.T(), repository.UserProfileAttribute{
+		Name:       newAttributeName("cost_center"),
+		Type:       repository.UserProfileAttributeTypeString,
+		Visibility: repository.UserProfileAttributeVisibilityAdmin,
+	})
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser().User()
+		// when
+		err := s.Application.UserProfileAttributeService().ApplyProfileAttributes(s.Ctx, user, map[string]interface{}{
+			locale.Name: "fr_FR",
+			level.Name:  float64(3), // as decoded from JSON
+		}, repository.UserProfileAttributeVisibilityPrivate)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "fr_FR", user.ProfileAttributes[locale.Name])
+		assert.Equal(t, int64(3), user.ProfileAttributes[level.Name])
+
+		// when the value is unset
+		err = s.Application.UserProfileAttributeService().ApplyProfileAttributes(s.Ctx, user, map[string]interface{}{
+			level.Name: nil,
+		}, repository.UserProfileAttributeVisibilityPrivate)
+		// then
+		require.NoError(t, err)
+		assert.NotContains(t, user.ProfileAttributes, level.Name)
+		assert.Contains(t, user.ProfileAttributes, locale.Name)
+	})
+
+	s.T().Run("invalid values", func(t *testing.T) {
+		for name, values := range map[string]map[string]interface{}{
+			"unknown attribute":    {newAttributeName("unknown"): "value"},
+			"not allowed value":    {locale.Name: "de_DE"},
+			"too long":             {locale.Name: "en_US.UTF-8"},
+			"not an integer":       {level.Name: 2.5},
+			"less than minimum":    {level.Name: float64(0)},
+			"greater than maximum": {level.Name: float64(11)},
+			"wrong type":           {locale.Name: true},
+		} {
+			t.Run(name, func(t *testing.T) {
+				user := s.Graph.CreateUser().User()
+				err := s.Application.UserProfileAttributeService().ApplyProfileAttributes(s.Ctx, user, values, repository.UserProfileAttributeVisibilityPrivate)
+				require.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+			})
+		}
+	})
+
+	s.T().Run("admin attribute", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser().User()
+		values := map[string]interface{}{costCenter.Name: "R&D"}
+		// when set by the user
+		err := s.Application.UserProfileAttributeService().ApplyProfileAttributes(s.Ctx, user, values, repository.UserProfileAttributeVisibilityPrivate)
+		// then
+		require.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+
+		// when set by an admin
+		err = s.Application.UserProfileAttributeService().ApplyProfileAttributes(s.Ctx, user, values, repository.UserProfileAttributeVisibilityAdmin)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "R&D", user.ProfileAttributes[costCenter.Name])
+	})
+
+	s.T().Run("update and visibility", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		// when
+		updated, err := s.Application.UserProfileAttributeService().UpdateProfileAttributes(s.Ctx, identity.Username, map[string]interface{}{
+			locale.Name:     "en_US",
+			level.Name:      float64(7),
+			costCenter.Name: "SALES",
+		})
+		// then
+		require.NoError(t, err)
+		user, err := s.Application.Users().Load(s.Ctx, updated.User.ID)
+		require.NoError(t, err)
+		attributes, err := s.Application.UserProfileAttributeService().ListAttributes(s.Ctx)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{locale.Name: "en_US"},
+			repository.VisibleProfileAttributes(attributes, user.ProfileAttributes, repository.UserProfileAttributeVisibilityPublic))
+		assert.Equal(t, map[string]interface{}{locale.Name: "en_US", level.Name: float64(7)},
+			repository.VisibleProfileAttributes(attributes, user.ProfileAttributes, repository.UserProfileAttributeVisibilityPrivate))
+		assert.Len(t, repository.VisibleProfileAttributes(attributes, user.ProfileAttributes, repository.UserProfileAttributeVisibilityAdmin), 3)
+	})
+
+	s.T().Run("unknown user", func(t *testing.T) {
+		_, err := s.Application.UserProfileAttributeService().UpdateProfileAttributes(s.Ctx, uuid.NewV4().String(), map[string]interface{}{
+			locale.Name: "en_US",
+		})
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}
+
+func (s *userProfileAttributeServiceBlackboxTestSuite) TestDeleteAttribute() {
+	// given
+	attribute := s.saveAttribute(s.T(), repository.UserProfileAttribute{
+		Name:       newAttributeName("timezone"),
+		Type:       repository.UserProfileAttributeTypeString,
+		Visibility: repository.UserProfileAttributeVisibilityPublic,
+	})
+	identity := s.Graph.CreateUser().Identity()
+	_, err := s.Application.UserProfileAttributeService().UpdateProfileAttributes(s.Ctx, identity.Username, map[string]interface{}{
+		attribute.Name: "Europe/Paris",
+	})
+	require.NoError(s.T(), err)
+
+	s.T().Run("ok", func(t *testing.T) {
+		// when
+		err := s.Application.UserProfileAttributeService().DeleteAttribute(s.Ctx, attribute.Name)
+		// then
+		require.NoError(t, err)
+		_, err = s.Application.UserProfileAttributes().Load(s.Ctx, attribute.Name)
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+		user, err := s.Application.Users().Load(s.Ctx, identity.User.ID)
+		require.NoError(t, err)
+		assert.NotContains(t, user.ProfileAttributes, attribute.Name)
+	})
+
+	s.T().Run("not found", func(t *testing.T) {
+		err := s.Application.UserProfileAttributeService().DeleteAttribute(s.Ctx, attribute.Name)
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}
+
+func (s *userProfileAttributeServiceBlackboxTestSuite) TestSearchFilter() {
+	// given
+	public := s.saveAttribute(s.T(), repository.UserProfileAttribute{
+		Name:       newAttributeName("github"),
+		Type:       repository.UserProfileAttributeTypeString,
+		Visibility: repository.UserProfileAttributeVisibilityPublic,
+		Indexable:  true,
+	})
+	level := s.saveAttribute(s.T(), repository.UserProfileAttribute{
+		Name:       newAttributeName("level"),
+		Type:       repository.UserProfileAttributeTypeInteger,
+		Visibility: repository.UserProfileAttributeVisibilityPublic,
+		Indexable:  true,
+	})
+	notIndexable := s.saveAttribute(s.T(), repository.UserProfileAttribute{
+		Name:       newAttributeName("bio"),
+		Type:       repository.UserProfileAttributeTypeString,
+		Visibility: repository.UserProfileAttributeVisibilityPublic,
+	})
+	private := s.saveAttribute(s.T(), repository.UserProfileAttribute{
+		Name:       newAttributeName("phone"),
+		Type:       repository.UserProfileAttributeTypeString,
+		Visibility: repository.UserProfileAttributeVisibilityPrivate,
+		Indexable:  true,
+	})
+
+	s.T().Run("ok", func(t *testing.T) {
+		filter, err := s.Application.UserProfileAttributeService().SearchFilter(s.Ctx, []string{public.Name + ":octo:cat", level.Name + ":3"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{public.Name: "octo:cat", level.Name: int64(3)}, filter)
+	})
+
+	s.T().Run("invalid", func(t *testing.T) {
+		for name, filter := range map[string]string{
+			"no value":      public.Name,
+			"not indexable": notIndexable.Name + ":value",
+			"private":       private.Name + ":value",
+			"not integer":   level.Name + ":three",
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := s.Application.UserProfileAttributeService().SearchFilter(s.Ctx, []string{filter})
+				require.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+			})
+		}
+	})
+
+	s.T().Run("search", func(t *testing.T) {
+		// given
+		handle := "octocat" + uuid.NewV4().String()
+		user := s.Graph.CreateUser()
+		s.Graph.CreateUser() // without the attribute
+		_, err := s.Application.UserProfileAttributeService().UpdateProfileAttributes(s.Ctx, user.Identity().Username, map[string]interface{}{
+			public.Name: handle,
+		})
+		require.NoError(t, err)
+		filter, err := s.Application.UserProfileAttributeService().SearchFilter(s.Ctx, []string{public.Name + ":" + handle})
+		require.NoError(t, err)
+		// when
+		result, count, err := s.Application.Identities().Search(s.Ctx, user.Identity().Username, repository.IdentitySearchFilter{ProfileAttributes: filter}, 0, 10)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		require.Len(t, result, 1)
+		assert.Equal(t, user.IdentityID(), result[0].ID)
+	})
+}
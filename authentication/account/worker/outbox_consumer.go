@@ -0,0 +1,23 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/service"
+	"github.com/fabric8-services/fabric8-auth/worker"
+	workerrepo "github.com/fabric8-services/fabric8-auth/worker/repository"
+)
+
+// NewOutboxConsumers returns the workers which deliver the outbox events to the other services, one per outbox queue.
+// All the pods consume the queues, and the events which failed are retried until they are dead-lettered.
+func NewOutboxConsumers(ctx context.Context, app application.Application, config service.OutboxServiceConfiguration) []worker.Worker {
+	var consumers []worker.Worker
+	for _, queue := range repository.OutboxQueues {
+		consumers = append(consumers, worker.NewQueueConsumer(ctx, app, queue, func(ctx context.Context, task workerrepo.QueueTask) error {
+			return app.OutboxService().DeliverEvent(ctx, task)
+		}, service.OutboxQueueConsumerConfig(config, queue)))
+	}
+	return consumers
+}
@@ -0,0 +1,32 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/worker"
+)
+
+const (
+	// UserBan the name of the job that starts the scheduled bans and lifts the expired ones.
+	// Also, the name of the lock used by its worker.
+	UserBan = "user-ban"
+)
+
+// NewUserBanJob returns a new job which bans the users whose scheduled ban started, and which unbans the users
+// whose ban ended
+func NewUserBanJob(app application.Application, schedule string) worker.Job {
+	return worker.Job{
+		Name:     UserBan,
+		Schedule: schedule,
+		Run: func(ctx context.Context) (int, error) {
+			started, err := app.UserService().StartScheduledBans(ctx, time.Now)
+			if err != nil {
+				return 0, err
+			}
+			lifted, err := app.UserService().LiftExpiredBans(ctx, time.Now)
+			return started + lifted, err
+		},
+	}
+}
@@ -0,0 +1,25 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/worker"
+	workerrepo "github.com/fabric8-services/fabric8-auth/worker/repository"
+)
+
+// NewUserBulkOperationConsumer returns a new worker which applies the bulk operations to their users, one queue task
+// per user. The number of users processed in parallel and the delay between them are limited in each pod, to protect
+// the Cluster service. The bans and deactivations delete the users on the Che and Tenant services via the outbox, which
+// has its own throttled queue.
+func NewUserBulkOperationConsumer(ctx context.Context, app application.Application, concurrency int, minInterval time.Duration) worker.Worker {
+	config := worker.DefaultQueueConsumerConfig
+	config.Concurrency = concurrency
+	config.BatchSize = 1
+	config.MinInterval = minInterval
+	return worker.NewQueueConsumer(ctx, app, repository.UserBulkOperationQueue, func(ctx context.Context, task workerrepo.QueueTask) error {
+		return app.UserBulkOperationService().ProcessTask(ctx, task)
+	}, config)
+}
@@ -0,0 +1,25 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/worker"
+)
+
+const (
+	// UserDataExport the name of the job that generates the pending user data exports.
+	// Also, the name of the lock used by its worker.
+	UserDataExport = "user-data-export"
+)
+
+// NewUserDataExportJob returns a new job which generates the pending user data exports, and removes the expired ones
+func NewUserDataExportJob(app application.Application, schedule string) worker.Job {
+	return worker.Job{
+		Name:     UserDataExport,
+		Schedule: schedule,
+		Run: func(ctx context.Context) (int, error) {
+			return app.UserDataExportService().GeneratePendingExports(ctx)
+		},
+	}
+}
@@ -12,45 +12,50 @@ import (
 )
 
 const (
-	// UserDeactivation the name of the worker that deactivates users.
-	// Also, the name of the lock used by this worker.
+	// UserDeactivation the name of the job that deactivates users.
+	// Also, the name of the lock used by its worker.
 	UserDeactivation = "user-deactivation"
 )
 
-// NewUserDeactivationWorker returns a new UserDeactivationWorker
-func NewUserDeactivationWorker(ctx context.Context, app application.Application) worker.Worker {
-	w := &userDeactivationWorker{
-		worker.BaseWorker{
-			Ctx:   ctx,
-			App:   app,
-			Owner: worker.GetLockOwner(ctx),
-			Name:  "user-deactivation",
+// NewUserDeactivationJob returns a new job which deactivates the inactive users. In dry-run mode, the job only logs
+// the users it would deactivate.
+func NewUserDeactivationJob(app application.Application, schedule string, dryRun bool) worker.Job {
+	return worker.Job{
+		Name:     UserDeactivation,
+		Schedule: schedule,
+		Run: func(ctx context.Context) (int, error) {
+			return deactivateUsers(ctx, app, dryRun)
 		},
 	}
-	w.Do = w.deactivateUsers
-	return w
 }
 
-type userDeactivationWorker struct {
-	worker.BaseWorker
-}
-
-func (w userDeactivationWorker) deactivateUsers() {
-	log.Info(w.Ctx, map[string]interface{}{
-		"owner": w.Owner,
+// deactivateUsers deactivates the inactive users and returns the number of users to deactivate
+func deactivateUsers(ctx context.Context, app application.Application, dryRun bool) (int, error) {
+	log.Info(ctx, map[string]interface{}{
+		"dry_run": dryRun,
 	}, "starting cycle of inactive users deactivation")
 	// user service has the config settings to limit the number of users to deactivate
-	identities, err := w.App.UserService().ListIdentitiesToDeactivate(w.Ctx, time.Now)
+	identities, err := app.UserService().ListIdentitiesToDeactivate(ctx, time.Now)
 	if err != nil {
-		// We will just log the error and continue
-		log.Error(nil, map[string]interface{}{
-			"err": err,
-		}, "error while notifying users to deactivate")
-		return
+		return 0, err
+	}
+
+	if dryRun {
+		for _, identity := range identities {
+			log.Info(ctx, map[string]interface{}{
+				"username":               identity.Username,
+				"last_active":            identity.LastActive,
+				"deactivation_scheduled": identity.DeactivationScheduled,
+			}, "dry-run: user would be deactivated")
+		}
+		log.Info(ctx, map[string]interface{}{
+			"identities": len(identities),
+		}, "ending cycle of inactive users deactivation (dry-run)")
+		return len(identities), nil
 	}
 
 	for _, identity := range identities {
-		err := w.App.UserService().RescheduleDeactivation(w.Ctx, identity.ID)
+		err := app.UserService().RescheduleDeactivation(ctx, identity.ID)
 		if err != nil {
 			log.Error(nil, map[string]interface{}{
 				"err":      err,
@@ -60,11 +65,11 @@ func (w userDeactivationWorker) deactivateUsers() {
 		// to deactivate a user, we need to call the OSO Registration App which will take care of
 		// deactivating the user on OSO and then call back `auth` service (on its `/namedusers/:username/deactivate` endpoint)
 		// which will handle the deactivation on the OSIO platform
-		err = w.App.OSOSubscriptionService().DeactivateUser(w.Ctx, identity.Username)
+		err = app.OSOSubscriptionService().DeactivateUser(ctx, identity.Username)
 		if err != nil {
 			if _, ok := err.(autherrors.NotFoundError); ok {
 				// deactivate user directly
-				_, err := w.App.UserService().DeactivateUser(w.Ctx, identity.Username)
+				_, err := app.UserService().DeactivateUser(ctx, identity.Username)
 				if err != nil {
 					log.Error(nil, map[string]interface{}{
 						"err":      err,
@@ -90,8 +95,8 @@ func (w userDeactivationWorker) deactivateUsers() {
 			}, "user account deactivation triggered")
 		}
 	}
-	log.Info(w.Ctx, map[string]interface{}{
+	log.Info(ctx, map[string]interface{}{
 		"identities": len(identities),
-		"owner":      w.Owner,
 	}, "ending cycle of inactive users deactivation")
+	return len(identities), nil
 }
@@ -14,6 +14,7 @@ import (
 	"github.com/fabric8-services/fabric8-auth/application/service/factory"
 
 	"github.com/fabric8-services/fabric8-auth/application"
+	authaccount "github.com/fabric8-services/fabric8-auth/authentication/account"
 	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
 	userservice "github.com/fabric8-services/fabric8-auth/authentication/account/service"
 	"github.com/fabric8-services/fabric8-auth/authentication/account/worker"
@@ -25,6 +26,7 @@ import (
 	accountservicemock "github.com/fabric8-services/fabric8-auth/test/generated/authentication/account/service"
 	testtoken "github.com/fabric8-services/fabric8-auth/test/token"
 	baseworker "github.com/fabric8-services/fabric8-auth/worker"
+	baserepository "github.com/fabric8-services/fabric8-auth/worker/repository"
 
 	uuid "github.com/satori/go.uuid"
 	"github.com/stretchr/testify/require"
@@ -43,6 +45,9 @@ func TestUserDeactivationWorker(t *testing.T) {
 func (s *UserDeactivationWorkerTest) TestDeactivateUsers() {
 	// given
 	config := accountservicemock.NewUserServiceConfigurationMock(s.T())
+	config.GetUserDeactivationPoliciesFunc = func() (empty []authaccount.DeactivationPolicy) {
+		return empty
+	}
 	config.GetUserDeactivationFetchLimitFunc = func() int {
 		return 100
 	}
@@ -84,19 +89,17 @@ func (s *UserDeactivationWorkerTest) TestDeactivateUsers() {
 		err = s.Application.Identities().Save(ctx, &identityToDeactivate)
 		require.NoError(s.T(), err)
 		mockRemoteCalls(userToDeactivate.User(), identityToDeactivate, s.Configuration, 200)
-		// start the worker with a 50ms ticker
-		w := s.newUserDeactivationWorker(ctx, "pod-a", app)
-		freq := time.Millisecond * 50
-		w.Start(freq)
-		// wait a few cycles before checking the results
-		time.Sleep(freq * 2)
-		// now stop all workers
-		stop(w)
-		// verify that the lock was released
-		l, err := s.Application.WorkerLockRepository().AcquireLock(context.Background(), "assert", worker.UserDeactivation)
+		// when
+		items, err := worker.NewUserDeactivationJob(app, "@yearly", false).Run(ctx)
+		// then
 		require.NoError(s.T(), err)
-		err = l.Close()
+		assert.True(s.T(), items >= 1)
+		assert.True(s.T(), gock.IsDone())
+		// deactivation was rescheduled, so the user is not deactivated again at the next run
+		identity, err := s.Application.Identities().Load(ctx, identityToDeactivate.ID)
 		require.NoError(s.T(), err)
+		require.NotNil(s.T(), identity.DeactivationScheduled)
+		assert.True(s.T(), identity.DeactivationScheduled.After(now))
 	})
 
 	s.Run("multiple workers but only one working", func() {
@@ -114,14 +117,14 @@ func (s *UserDeactivationWorkerTest) TestDeactivateUsers() {
 		err = s.Application.Identities().Save(ctx, &identityToDeactivate)
 		require.NoError(s.T(), err)
 		mockRemoteCalls(userToDeactivate.User(), identityToDeactivate, s.Configuration, 200)
-		// start the workers with a 50ms ticker
+		// start the job workers with a 50ms ticker
 		freq := time.Millisecond * 50
 		latch := sync.WaitGroup{}
 		latch.Add(1)
 		workers := []baseworker.Worker{}
 		for i := 1; i <= 2; i++ {
 			fmt.Printf("initializing worker %d...\n", i)
-			w := s.newUserDeactivationWorker(context.Background(), fmt.Sprintf("pod-%d", i), app)
+			w := s.newUserDeactivationWorker(ctx, fmt.Sprintf("pod-%d", i), app)
 			workers = append(workers, w)
 			go func(i int) {
 				// now, wait for latch to be released so that all workers start at the same time
@@ -131,10 +134,16 @@ func (s *UserDeactivationWorkerTest) TestDeactivateUsers() {
 			}(i)
 		}
 		latch.Done()
-		// wait a few cycles before checking the results
+		// when the job is triggered
+		triggerJob(s.T(), s.Application, worker.UserDeactivation)
+		// then it runs only once
+		waitForRuns(s.T(), s.Application, worker.UserDeactivation, 1)
 		time.Sleep(freq * 5)
-		// now stop all workers
 		stop(workers...)
+		runs := waitForRuns(s.T(), s.Application, worker.UserDeactivation, 1)
+		assert.Len(s.T(), runs, 1)
+		assert.Equal(s.T(), baserepository.JobRunOutcomeSuccess, runs[0].Outcome)
+		assert.True(s.T(), gock.IsDone())
 		// verify that the lock was released
 		l, err := s.Application.WorkerLockRepository().AcquireLock(context.Background(), "assert", worker.UserDeactivation)
 		require.NoError(s.T(), err)
@@ -161,21 +170,38 @@ func (s *UserDeactivationWorkerTest) TestDeactivateUsers() {
 		mockTenantCalls(s.Configuration)
 		mockClusterCalls(s.Configuration)
 		mockAdminConsoleCalls(s.Configuration)
-		// start the worker with a 50ms ticker
-		w := s.newUserDeactivationWorker(ctx, "pod-a", app)
-		freq := time.Millisecond * 50
-		w.Start(freq)
-		// wait a few cycles before checking the results
-		time.Sleep(freq * 2)
-		// now stop all workers
-		stop(w)
-		// verify that the lock was released
-		l, err := s.Application.WorkerLockRepository().AcquireLock(context.Background(), "assert", worker.UserDeactivation)
-		require.NoError(s.T(), err)
-		err = l.Close()
+		// when
+		_, err = worker.NewUserDeactivationJob(app, "@yearly", false).Run(ctx)
+		// then
 		require.NoError(s.T(), err)
 		s.verifyDeactivate(userToDeactivate.User().ID)
 	})
+
+	s.Run("dry-run", func() {
+		// given
+		ctx, _, _ := testtoken.ContextWithTokenAndRequestID(s.T())
+		userToDeactivate := s.Graph.CreateUser()
+		identityToDeactivate := *userToDeactivate.Identity()
+		identityToDeactivate.LastActive = &ago40days
+		identityToDeactivate.DeactivationNotification = &ago30days
+		now := time.Now()
+		identityToDeactivate.DeactivationScheduled = &now
+		err := s.Application.Identities().Save(ctx, &identityToDeactivate)
+		require.NoError(s.T(), err)
+		// when running the job without mocking the calls to the other services
+		items, err := worker.NewUserDeactivationJob(app, "@yearly", true).Run(ctx)
+		// then
+		require.NoError(s.T(), err)
+		assert.True(s.T(), items >= 1)
+		// verify that the user was not deactivated
+		user, err := s.Application.Users().Load(context.Background(), userToDeactivate.User().ID)
+		require.NoError(s.T(), err)
+		assert.True(s.T(), user.Active)
+		identity, err := s.Application.Identities().Load(context.Background(), identityToDeactivate.ID)
+		require.NoError(s.T(), err)
+		require.NotNil(s.T(), identity.DeactivationScheduled)
+		assert.Equal(s.T(), now.Unix(), identity.DeactivationScheduled.Unix())
+	})
 }
 
 func (s *UserDeactivationWorkerTest) newUserDeactivationWorker(ctx context.Context, podname string, app application.Application) baseworker.Worker {
@@ -185,7 +211,9 @@ func (s *UserDeactivationWorkerTest) newUserDeactivationWorker(ctx context.Conte
 	require.NoError(s.T(), err)
 	require.Equal(s.T(), podname, config.GetPodName())
 	ctx = context.WithValue(ctx, baseworker.LockOwner, podname)
-	return worker.NewUserDeactivationWorker(ctx, app)
+	w, err := baseworker.NewJobWorker(ctx, app, worker.NewUserDeactivationJob(app, "@yearly", false))
+	require.NoError(s.T(), err)
+	return w
 }
 
 func (s *UserDeactivationWorkerTest) verifyDeactivate(id uuid.UUID) {
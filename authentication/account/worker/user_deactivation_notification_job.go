@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/log"
+	worker "github.com/fabric8-services/fabric8-auth/worker"
+)
+
+const (
+	// UserDeactivationNotification the name of the job that notifies users to deactivate.
+	// Also, the name of the lock used by its worker.
+	UserDeactivationNotification = "user-deactivation-notification"
+)
+
+// NewUserDeactivationNotificationJob returns a new job which notifies the inactive users before their deactivation.
+// In dry-run mode, the job only logs the users it would notify.
+func NewUserDeactivationNotificationJob(app application.Application, schedule string, dryRun bool) worker.Job {
+	return worker.Job{
+		Name:     UserDeactivationNotification,
+		Schedule: schedule,
+		Run: func(ctx context.Context) (int, error) {
+			return notifyUsers(ctx, app, dryRun)
+		},
+	}
+}
+
+// notifyUsers notifies the inactive users before their deactivation and returns the number of notified users
+func notifyUsers(ctx context.Context, app application.Application, dryRun bool) (int, error) {
+	log.Info(ctx, map[string]interface{}{
+		"dry_run": dryRun,
+	}, "starting cycle of inactive users notifications")
+	var identities []repository.Identity
+	var err error
+	if dryRun {
+		identities, err = app.UserService().ListIdentitiesToNotifyForDeactivation(ctx, time.Now)
+		for _, identity := range identities {
+			log.Info(ctx, map[string]interface{}{
+				"username":    identity.Username,
+				"last_active": identity.LastActive,
+			}, "dry-run: user would be notified before account deactivation")
+		}
+	} else {
+		identities, err = app.UserService().NotifyIdentitiesBeforeDeactivation(ctx, time.Now) // user service has the config settings to limit the number of users to notify
+	}
+	if err != nil {
+		return len(identities), err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identities": len(identities),
+	}, "ending cycle of inactive users notifications")
+	return len(identities), nil
+}
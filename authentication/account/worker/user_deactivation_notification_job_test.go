@@ -10,6 +10,7 @@ import (
 
 	"github.com/fabric8-services/fabric8-auth/application"
 	"github.com/fabric8-services/fabric8-auth/application/service/factory"
+	"github.com/fabric8-services/fabric8-auth/authentication/account"
 	"github.com/fabric8-services/fabric8-auth/authentication/account/worker"
 	"github.com/fabric8-services/fabric8-auth/configuration"
 	"github.com/fabric8-services/fabric8-auth/gormapplication"
@@ -19,6 +20,7 @@ import (
 	appservicemock "github.com/fabric8-services/fabric8-auth/test/generated/application/service"
 	accountservicemock "github.com/fabric8-services/fabric8-auth/test/generated/authentication/account/service"
 	baseworker "github.com/fabric8-services/fabric8-auth/worker"
+	baserepository "github.com/fabric8-services/fabric8-auth/worker/repository"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -36,6 +38,9 @@ func TestUserDeactivationNotificationWorker(t *testing.T) {
 func (s *UserDeactivationNotificationWorkerTest) TestNotifyUsers() {
 	// given
 	config := accountservicemock.NewUserServiceConfigurationMock(s.T())
+	config.GetUserDeactivationPoliciesFunc = func() (empty []account.DeactivationPolicy) {
+		return empty
+	}
 	config.GetUserDeactivationFetchLimitFunc = func() int {
 		return 100
 	}
@@ -66,18 +71,20 @@ func (s *UserDeactivationNotificationWorkerTest) TestNotifyUsers() {
 		identity1.LastActive = &ago40days
 		err := s.Application.Identities().Save(ctx, &identity1)
 		require.NoError(s.T(), err)
-		// start the worker with a 50ms ticker
-		w := s.newUserDeactivationNotificationWorker(context.Background(), "pod-a", app)
-		freq := time.Millisecond * 50
-		w.Start(freq)
-		// wait a few cycles before checking the results
-		time.Sleep(freq * 5)
-		// now stop all workers
-		stop(w)
+		// when running the job twice
+		job := worker.NewUserDeactivationNotificationJob(app, "@yearly", false)
+		items, err := job.Run(ctx)
+		require.NoError(s.T(), err)
+		assert.True(s.T(), items >= 1)
+		_, err = job.Run(ctx)
+		require.NoError(s.T(), err)
 		// then load the user and check her deactivation notification status
 		result, err := s.Application.Identities().Load(context.Background(), identity1.ID)
 		require.NoError(s.T(), err)
 		assert.NotNil(s.T(), result.DeactivationNotification)
+		// deliver the outbox events
+		_, err = app.OutboxService().DeliverEvents(ctx)
+		require.NoError(s.T(), err)
 		// notification only sent once to the user
 		assert.Equal(s.T(), uint64(1), notificationServiceMock.SendMessageCounter)
 	})
@@ -89,7 +96,7 @@ func (s *UserDeactivationNotificationWorkerTest) TestNotifyUsers() {
 		identity1.LastActive = &ago40days
 		err := s.Application.Identities().Save(ctx, &identity1)
 		require.NoError(s.T(), err)
-		// start the workers with a 50ms ticker
+		// start the job workers with a 50ms ticker
 		freq := time.Millisecond * 50
 		latch := sync.WaitGroup{}
 		latch.Add(1)
@@ -106,14 +113,21 @@ func (s *UserDeactivationNotificationWorkerTest) TestNotifyUsers() {
 			}(i)
 		}
 		latch.Done()
-		// wait a few cycles before checking the results
+		// when the job is triggered
+		triggerJob(s.T(), s.Application, worker.UserDeactivationNotification)
+		// then it runs only once
+		waitForRuns(s.T(), s.Application, worker.UserDeactivationNotification, 1)
 		time.Sleep(freq * 5)
-		// now stop all workers
 		stop(workers...)
+		runs := waitForRuns(s.T(), s.Application, worker.UserDeactivationNotification, 1)
+		assert.Len(s.T(), runs, 1)
 		// then load the user and check her deactivation notification status
 		result, err := s.Application.Identities().Load(context.Background(), identity1.ID)
 		require.NoError(s.T(), err)
 		assert.NotNil(s.T(), result.DeactivationNotification)
+		// deliver the outbox events
+		_, err = app.OutboxService().DeliverEvents(ctx)
+		require.NoError(s.T(), err)
 		// notification only sent once to the user
 		assert.Equal(s.T(), uint64(1), notificationServiceMock.SendMessageCounter)
 		// verify that the lock was released
@@ -131,7 +145,36 @@ func (s *UserDeactivationNotificationWorkerTest) newUserDeactivationNotification
 	require.NoError(s.T(), err)
 	require.Equal(s.T(), podname, config.GetPodName())
 	ctx = context.WithValue(ctx, baseworker.LockOwner, podname)
-	return worker.NewUserDeactivationNotificationWorker(ctx, app)
+	w, err := baseworker.NewJobWorker(ctx, app, worker.NewUserDeactivationNotificationJob(app, "@yearly", false))
+	require.NoError(s.T(), err)
+	return w
+}
+
+// triggerJob waits until the job with the given name was registered by its worker, then requests a run
+func triggerJob(t *testing.T, app application.Application, name string) {
+	for i := 0; i < 100; i++ {
+		if _, err := app.JobRepository().Load(context.Background(), name); err == nil {
+			err := app.JobRepository().RequestTrigger(context.Background(), name, "tester")
+			require.NoError(t, err)
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	require.FailNow(t, "job was not registered in time", name)
+}
+
+// waitForRuns waits until the job with the given name completed at least the given number of runs, and returns them
+func waitForRuns(t *testing.T, app application.Application, name string, count int) []baserepository.JobRun {
+	for i := 0; i < 100; i++ {
+		runs, err := app.JobRunRepository().ListForJob(context.Background(), name, 10)
+		require.NoError(t, err)
+		if len(runs) >= count && runs[0].Outcome != baserepository.JobRunOutcomeRunning {
+			return runs
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	require.FailNow(t, "job runs not completed in time", name)
+	return nil
 }
 
 // stop stops the given workers and waits until they all actually stopped before returning.
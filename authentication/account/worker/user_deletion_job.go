@@ -0,0 +1,91 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/worker"
+)
+
+const (
+	// UserDeletion the name of the job that deletes the accounts whose users requested the deletion.
+	// Also, the name of the lock used by its worker.
+	UserDeletion = "user-deletion"
+)
+
+// NewUserDeletionJob returns a new job which deactivates the accounts whose deletion was requested once their grace
+// period is over, and which purges them once their retention period is over
+func NewUserDeletionJob(app application.Application, schedule string) worker.Job {
+	return worker.Job{
+		Name:     UserDeletion,
+		Schedule: schedule,
+		Run: func(ctx context.Context) (int, error) {
+			deleted, err := deleteUsers(ctx, app)
+			if err != nil {
+				return deleted, err
+			}
+			purged, err := purgeUsers(ctx, app)
+			return deleted + purged, err
+		},
+	}
+}
+
+// deleteUsers deactivates the accounts whose grace period is over, and returns the number of deactivated accounts
+func deleteUsers(ctx context.Context, app application.Application) (int, error) {
+	identities, err := app.UserService().ListIdentitiesToDelete(ctx, time.Now)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, identity := range identities {
+		// postpone the next attempt in case the deactivation fails, so that other accounts are not blocked behind this one
+		err := app.UserService().RescheduleDeactivation(ctx, identity.ID)
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"err":      err,
+				"username": identity.Username,
+			}, "error updating deactivation schedule while deleting user")
+		}
+		_, err = app.UserService().DeactivateUser(ctx, identity.Username)
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"err":      err,
+				"username": identity.Username,
+			}, "error while deleting user")
+			continue
+		}
+		log.Info(ctx, map[string]interface{}{
+			"identity_id": identity.ID,
+		}, "user deletion is successful")
+		count++
+	}
+	return count, nil
+}
+
+// purgeUsers removes the accounts whose retention period is over, and returns the number of purged accounts
+func purgeUsers(ctx context.Context, app application.Application) (int, error) {
+	identities, err := app.UserService().ListIdentitiesToPurge(ctx, time.Now)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, identity := range identities {
+		// the user record is soft-deleted, hence not loaded along with the identity
+		identity.User.ID = identity.UserID.UUID
+		err := app.UserService().HardDeleteUser(ctx, identity)
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"err":         err,
+				"identity_id": identity.ID,
+			}, "error while purging user")
+			continue
+		}
+		log.Info(ctx, map[string]interface{}{
+			"identity_id": identity.ID,
+		}, "user purge is successful")
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// BackChannelLogoutNotification a pending notification to a relying party that a user logged out.
+// The notification remains until the relying party acknowledged it or until the maximum number of attempts
+// has been reached.
+type BackChannelLogoutNotification struct {
+	gormsupport.Lifecycle
+
+	// This is the primary key value
+	NotificationID uuid.UUID `sql:"type:uuid default uuid_generate_v4()" gorm:"primary_key;column:notification_id"`
+	// the identity which logged out
+	IdentityID uuid.UUID `sql:"type:uuid"`
+	// the session which was terminated, or nil if all the sessions of the identity were terminated
+	SessionID *uuid.UUID `sql:"type:uuid"`
+	// the client ID of the relying party to notify
+	ClientID string
+	// the back-channel logout URI of the relying party
+	LogoutURI string
+	// the number of failed attempts to notify the relying party
+	Attempts int
+	// the time after which the next attempt to notify the relying party can happen
+	NextAttempt time.Time
+	// the error which occurred during the last attempt, if any
+	LastError *string
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m BackChannelLogoutNotification) TableName() string {
+	return "backchannel_logout_notification"
+}
+
+// GormBackChannelLogoutNotificationRepository is the implementation of the storage interface for BackChannelLogoutNotification.
+type GormBackChannelLogoutNotificationRepository struct {
+	db *gorm.DB
+}
+
+// NewBackChannelLogoutNotificationRepository creates a new storage type.
+func NewBackChannelLogoutNotificationRepository(db *gorm.DB) BackChannelLogoutNotificationRepository {
+	return &GormBackChannelLogoutNotificationRepository{db: db}
+}
+
+// TableName returns the name of the table used to store the notifications
+func (m *GormBackChannelLogoutNotificationRepository) TableName() string {
+	return "backchannel_logout_notification"
+}
+
+// BackChannelLogoutNotificationRepository represents the storage interface.
+type BackChannelLogoutNotificationRepository interface {
+	Load(ctx context.Context, id uuid.UUID) (*BackChannelLogoutNotification, error)
+	Create(ctx context.Context, notification *BackChannelLogoutNotification) error
+	Save(ctx context.Context, notification *BackChannelLogoutNotification) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	ListDue(ctx context.Context, now time.Time, limit int) ([]BackChannelLogoutNotification, error)
+}
+
+// Load returns a single notification as a Database Model
+func (m *GormBackChannelLogoutNotificationRepository) Load(ctx context.Context, id uuid.UUID) (*BackChannelLogoutNotification, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "backchannel_logout_notification", "load"}, time.Now())
+	var native BackChannelLogoutNotification
+	err := m.db.Table(m.TableName()).Where("notification_id = ?", id).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("backchannel_logout_notification", id.String())
+	}
+	return &native, errs.WithStack(err)
+}
+
+// Create creates a new record.
+func (m *GormBackChannelLogoutNotificationRepository) Create(ctx context.Context, notification *BackChannelLogoutNotification) error {
+	defer goa.MeasureSince([]string{"goa", "db", "backchannel_logout_notification", "create"}, time.Now())
+	if notification.NotificationID == uuid.Nil {
+		notification.NotificationID = uuid.NewV4()
+	}
+	err := m.db.Create(notification).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"notification_id": notification.NotificationID,
+			"client_id":       notification.ClientID,
+			"err":             err,
+		}, "unable to create the back-channel logout notification")
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"notification_id": notification.NotificationID,
+		"client_id":       notification.ClientID,
+	}, "back-channel logout notification created!")
+	return nil
+}
+
+// Save modifies a single record.
+func (m *GormBackChannelLogoutNotificationRepository) Save(ctx context.Context, notification *BackChannelLogoutNotification) error {
+	defer goa.MeasureSince([]string{"goa", "db", "backchannel_logout_notification", "save"}, time.Now())
+	obj, err := m.Load(ctx, notification.NotificationID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"notification_id": notification.NotificationID,
+			"err":             err,
+		}, "unable to update the back-channel logout notification")
+		return errs.WithStack(err)
+	}
+	err = m.db.Model(obj).Updates(notification).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"notification_id": notification.NotificationID,
+			"err":             err,
+		}, "unable to update the back-channel logout notification")
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"notification_id": notification.NotificationID,
+	}, "back-channel logout notification saved!")
+	return nil
+}
+
+// Delete removes a single record. This is a hard delete!
+func (m *GormBackChannelLogoutNotificationRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "backchannel_logout_notification", "delete"}, time.Now())
+	result := m.db.Unscoped().Delete(&BackChannelLogoutNotification{NotificationID: id})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"notification_id": id,
+			"err":             result.Error,
+		}, "unable to delete the back-channel logout notification")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("backchannel_logout_notification", id.String())
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"notification_id": id,
+	}, "back-channel logout notification deleted!")
+	return nil
+}
+
+// ListDue returns the notifications whose next attempt is due at the given time, the oldest ones first.
+// The number of results is capped by the given limit.
+func (m *GormBackChannelLogoutNotificationRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]BackChannelLogoutNotification, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "backchannel_logout_notification", "list_due"}, time.Now())
+	var notifications []BackChannelLogoutNotification
+	err := m.db.Where("next_attempt <= ?", now).Order("next_attempt, created_at").Limit(limit).Find(&notifications).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to list the back-channel logout notifications")
+		return nil, errs.WithStack(err)
+	}
+	return notifications, nil
+}
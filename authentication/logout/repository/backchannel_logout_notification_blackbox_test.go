@@ -0,0 +1,123 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/logout/repository"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type backChannelLogoutNotificationBlackboxTest struct {
+	gormtestsupport.DBTestSuite
+	repo repository.BackChannelLogoutNotificationRepository
+}
+
+func TestRunBackChannelLogoutNotificationBlackboxTest(t *testing.T) {
+	suite.Run(t, &backChannelLogoutNotificationBlackboxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *backChannelLogoutNotificationBlackboxTest) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.repo = repository.NewBackChannelLogoutNotificationRepository(s.DB)
+}
+
+func (s *backChannelLogoutNotificationBlackboxTest) createNotification(nextAttempt time.Time) repository.BackChannelLogoutNotification {
+	notification := repository.BackChannelLogoutNotification{
+		IdentityID:  s.Graph.CreateUser().IdentityID(),
+		ClientID:    uuid.NewV4().String(),
+		LogoutURI:   "http://localhost/logout",
+		NextAttempt: nextAttempt,
+	}
+	err := s.repo.Create(context.Background(), &notification)
+	require.NoError(s.T(), err)
+	return notification
+}
+
+func (s *backChannelLogoutNotificationBlackboxTest) TestCreateAndLoad() {
+	// given
+	notification := s.createNotification(time.Now())
+	// when
+	loaded, err := s.repo.Load(context.Background(), notification.NotificationID)
+	// then
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), notification.IdentityID, loaded.IdentityID)
+	assert.Equal(s.T(), notification.ClientID, loaded.ClientID)
+	assert.Equal(s.T(), notification.LogoutURI, loaded.LogoutURI)
+	assert.Equal(s.T(), 0, loaded.Attempts)
+	assert.Nil(s.T(), loaded.LastError)
+}
+
+func (s *backChannelLogoutNotificationBlackboxTest) TestSave() {
+	// given
+	notification := s.createNotification(time.Now())
+	lastError := "oopsie woopsie"
+	notification.Attempts = 2
+	notification.LastError = &lastError
+	// when
+	err := s.repo.Save(context.Background(), &notification)
+	// then
+	require.NoError(s.T(), err)
+	loaded, err := s.repo.Load(context.Background(), notification.NotificationID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 2, loaded.Attempts)
+	require.NotNil(s.T(), loaded.LastError)
+	assert.Equal(s.T(), lastError, *loaded.LastError)
+}
+
+func (s *backChannelLogoutNotificationBlackboxTest) TestDelete() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		notification := s.createNotification(time.Now())
+		// when
+		err := s.repo.Delete(context.Background(), notification.NotificationID)
+		// then
+		require.NoError(t, err)
+		_, err = s.repo.Load(context.Background(), notification.NotificationID)
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, err)
+	})
+
+	s.T().Run("not found", func(t *testing.T) {
+		// when
+		err := s.repo.Delete(context.Background(), uuid.NewV4())
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, err)
+	})
+}
+
+func (s *backChannelLogoutNotificationBlackboxTest) TestListDue() {
+	// given
+	now := time.Now()
+	due1 := s.createNotification(now.Add(-2 * time.Minute))
+	due2 := s.createNotification(now.Add(-1 * time.Minute))
+	s.createNotification(now.Add(time.Minute)) // not due yet
+
+	s.T().Run("all due", func(t *testing.T) {
+		// when
+		result, err := s.repo.ListDue(context.Background(), now, 10)
+		// then
+		require.NoError(t, err)
+		require.Len(t, result, 2)
+		assert.Equal(t, due1.NotificationID, result[0].NotificationID)
+		assert.Equal(t, due2.NotificationID, result[1].NotificationID)
+	})
+
+	s.T().Run("with limit", func(t *testing.T) {
+		// when
+		result, err := s.repo.ListDue(context.Background(), now, 1)
+		// then
+		require.NoError(t, err)
+		require.Len(t, result, 1)
+		assert.Equal(t, due1.NotificationID, result[0].NotificationID)
+	})
+}
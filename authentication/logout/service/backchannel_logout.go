@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	logout "github.com/fabric8-services/fabric8-auth/authentication/logout/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/metric"
+	"github.com/fabric8-services/fabric8-auth/rest"
+	"github.com/fabric8-services/fabric8-auth/worker"
+
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// ScheduleBackChannelLogoutNotifications creates a notification for each relying party which registered a
+// back-channel logout URI, for the given terminated session of the given identity, or for all its sessions if the
+// session ID is nil. The notifications are sent by the back-channel logout worker.
+func (s *logoutServiceImpl) ScheduleBackChannelLogoutNotifications(ctx context.Context, identityID uuid.UUID, sessionID *uuid.UUID) error {
+	now := time.Now()
+	for clientID, logoutURI := range s.config.GetBackChannelLogoutURIs() {
+		err := s.Repositories().BackChannelLogoutNotifications().Create(ctx, &logout.BackChannelLogoutNotification{
+			IdentityID:  identityID,
+			SessionID:   sessionID,
+			ClientID:    clientID,
+			LogoutURI:   logoutURI,
+			NextAttempt: now,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendBackChannelLogoutNotifications sends the logout notifications which are due, and returns the number of notifications
+// that were acknowledged by the relying parties. Notifications which failed are rescheduled with an exponential backoff,
+// until the maximum number of attempts is reached.
+func (s *logoutServiceImpl) SendBackChannelLogoutNotifications(ctx context.Context, options ...rest.HTTPClientOption) (int, error) {
+	notifications, err := s.Repositories().BackChannelLogoutNotifications().ListDue(ctx, time.Now(), s.config.GetBackChannelLogoutBatchSize())
+	if err != nil {
+		return 0, err
+	}
+	if len(notifications) == 0 {
+		return 0, nil
+	}
+	tm, err := manager.DefaultManager(s.config)
+	if err != nil {
+		return 0, err
+	}
+	client := &http.Client{
+		Timeout: s.config.GetBackChannelLogoutRequestTimeout(),
+	}
+	// apply options
+	for _, opt := range options {
+		opt(client)
+	}
+	sent := 0
+	for _, n := range notifications {
+		notification := n
+		sendErr := s.sendBackChannelLogoutNotification(ctx, client, tm, notification)
+		metric.RecordBackChannelLogoutNotification(sendErr == nil)
+		if sendErr == nil {
+			sent++
+		}
+		err := s.ExecuteInTransaction(func() error {
+			if sendErr == nil {
+				return s.Repositories().BackChannelLogoutNotifications().Delete(ctx, notification.NotificationID)
+			}
+			notification.Attempts++
+			if notification.Attempts >= s.config.GetBackChannelLogoutMaxAttempts() {
+				log.Error(ctx, map[string]interface{}{
+					"notification_id": notification.NotificationID,
+					"identity_id":     notification.IdentityID,
+					"client_id":       notification.ClientID,
+					"attempts":        notification.Attempts,
+					"err":             sendErr,
+				}, "giving up on notifying the relying party that the user logged out")
+				return s.Repositories().BackChannelLogoutNotifications().Delete(ctx, notification.NotificationID)
+			}
+			lastError := sendErr.Error()
+			notification.LastError = &lastError
+			notification.NextAttempt = time.Now().Add(worker.RetryDelay(s.config.GetBackChannelLogoutRetryDelay(), notification.Attempts))
+			return s.Repositories().BackChannelLogoutNotifications().Save(ctx, &notification)
+		})
+		if err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// sendBackChannelLogoutNotification POSTs a new logout token to the back-channel logout URI of the relying party.
+// See https://openid.net/specs/openid-connect-backchannel-1_0.html#BCRequest
+func (s *logoutServiceImpl) sendBackChannelLogoutNotification(ctx context.Context, client *http.Client, tm manager.TokenManager, notification logout.BackChannelLogoutNotification) error {
+	logoutToken, err := tm.GenerateLogoutToken(ctx, notification.IdentityID, notification.SessionID, notification.ClientID)
+	if err != nil {
+		return err
+	}
+	form := url.Values{}
+	form.Set("logout_token", logoutToken)
+	req, err := http.NewRequest("POST", notification.LogoutURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return errs.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Cache-Control", "no-cache, no-store")
+	res, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		log.Warn(ctx, map[string]interface{}{
+			"notification_id": notification.NotificationID,
+			"client_id":       notification.ClientID,
+			"logout_uri":      notification.LogoutURI,
+			"err":             err,
+		}, "unable to send the logout token to the relying party")
+		return errs.Wrapf(err, "unable to send the logout token to '%s'", notification.LogoutURI)
+	}
+	defer rest.CloseResponse(res)
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent {
+		body := rest.ReadBody(res.Body)
+		log.Warn(ctx, map[string]interface{}{
+			"notification_id": notification.NotificationID,
+			"client_id":       notification.ClientID,
+			"logout_uri":      notification.LogoutURI,
+			"status":          res.Status,
+			"response_body":   body,
+		}, "the relying party did not acknowledge the logout token")
+		return errs.Errorf("unexpected response from '%s': %s; response body: %s", notification.LogoutURI, res.Status, body)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"notification_id": notification.NotificationID,
+		"identity_id":     notification.IdentityID,
+		"client_id":       notification.ClientID,
+	}, "relying party notified that the user logged out")
+	return nil
+}
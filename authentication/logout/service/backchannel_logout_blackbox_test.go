@@ -0,0 +1,219 @@
+package service_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application/service/factory"
+	logout "github.com/fabric8-services/fabric8-auth/authentication/logout/repository"
+	logoutservice "github.com/fabric8-services/fabric8-auth/authentication/logout/service"
+	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
+	"github.com/fabric8-services/fabric8-auth/configuration"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	testtoken "github.com/fabric8-services/fabric8-auth/test/token"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const (
+	witClientID  = "5dec5fdb-09e3-4453-b73f-5c828832b28e"
+	witLogoutURI = "http://wit.localhost/api/logout/backchannel"
+	cheClientID  = "d6d5b568-8a3c-4e42-bcac-adeb14eb3b15"
+	cheLogoutURI = "http://che.localhost/api/logout/backchannel"
+)
+
+func TestBackChannelLogout(t *testing.T) {
+	suite.Run(t, &backChannelLogoutBlackboxTestSuite{
+		DBTestSuite: gormtestsupport.NewDBTestSuite(),
+	})
+}
+
+type backChannelLogoutBlackboxTestSuite struct {
+	gormtestsupport.DBTestSuite
+}
+
+// backChannelLogoutConfig overrides the back-channel logout URIs and max attempts of the test configuration
+type backChannelLogoutConfig struct {
+	*configuration.ConfigurationData
+	maxAttempts int
+}
+
+func (c backChannelLogoutConfig) GetBackChannelLogoutURIs() map[string]string {
+	return map[string]string{
+		witClientID: witLogoutURI,
+		cheClientID: cheLogoutURI,
+	}
+}
+
+func (c backChannelLogoutConfig) GetBackChannelLogoutMaxAttempts() int {
+	return c.maxAttempts
+}
+
+func (s *backChannelLogoutBlackboxTestSuite) newConfig() *backChannelLogoutConfig {
+	return &backChannelLogoutConfig{
+		ConfigurationData: s.Configuration,
+		maxAttempts:       3,
+	}
+}
+
+func (s *backChannelLogoutBlackboxTestSuite) TestLogoutSchedulesNotifications() {
+	// given
+	config := s.newConfig()
+	svc := logoutservice.NewLogoutService(factory.NewServiceContext(s.Application, s.Application, nil, nil), config)
+	identity := s.Graph.CreateUser().Identity()
+	ctx, err := testtoken.EmbedIdentityInContext(*identity)
+	require.NoError(s.T(), err)
+	// when
+	_, err = svc.Logout(ctx, "https://openshift.io/home")
+	// then
+	require.NoError(s.T(), err)
+	notifications, err := s.Application.BackChannelLogoutNotifications().ListDue(ctx, time.Now(), 100)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), notifications, 2)
+	uris := map[string]string{}
+	for _, n := range notifications {
+		assert.Equal(s.T(), identity.ID, n.IdentityID)
+		assert.Equal(s.T(), 0, n.Attempts)
+		uris[n.ClientID] = n.LogoutURI
+	}
+	assert.Equal(s.T(), config.GetBackChannelLogoutURIs(), uris)
+}
+
+func (s *backChannelLogoutBlackboxTestSuite) TestSendNotifications() {
+
+	ctx := context.Background()
+	config := s.newConfig()
+	svc := logoutservice.NewLogoutService(factory.NewServiceContext(s.Application, s.Application, nil, nil), config)
+	tm, err := manager.DefaultManager(config)
+	require.NoError(s.T(), err)
+
+	// logoutTokenMatcher verifies that the request contains a valid logout token for the given identity, session and client
+	logoutTokenMatcher := func(notification logout.BackChannelLogoutNotification) gock.Matcher {
+		m := gock.NewMatcher()
+		m.Add(func(req *http.Request, ereq *gock.Request) (bool, error) {
+			if err := req.ParseForm(); err != nil {
+				return false, err
+			}
+			claims, err := tm.ParseTokenWithMapClaims(context.Background(), req.PostForm.Get("logout_token"))
+			if err != nil {
+				return false, err
+			}
+			_, isLogoutEvent := claims["events"].(map[string]interface{})[manager.BackChannelLogoutEvent]
+			var sid interface{}
+			if notification.SessionID != nil {
+				sid = notification.SessionID.String()
+			}
+			return isLogoutEvent &&
+				claims["sub"] == notification.IdentityID.String() &&
+				claims["sid"] == sid &&
+				claims["aud"] == notification.ClientID, nil
+		})
+		return m
+	}
+
+	createNotification := func(attempts int, sessionID *uuid.UUID) logout.BackChannelLogoutNotification {
+		notification := logout.BackChannelLogoutNotification{
+			IdentityID:  s.Graph.CreateUser().IdentityID(),
+			SessionID:   sessionID,
+			ClientID:    witClientID,
+			LogoutURI:   witLogoutURI,
+			Attempts:    attempts,
+			NextAttempt: time.Now().Add(-1 * time.Minute),
+		}
+		err := s.Application.BackChannelLogoutNotifications().Create(ctx, &notification)
+		require.NoError(s.T(), err)
+		return notification
+	}
+
+	s.T().Run("notification acknowledged", func(t *testing.T) {
+		// given
+		notification := createNotification(0, nil)
+		defer gock.Off()
+		gock.New("http://wit.localhost").
+			Post("/api/logout/backchannel").
+			MatchType("url").
+			SetMatcher(logoutTokenMatcher(notification)).
+			Reply(200)
+		// when
+		count, err := svc.SendBackChannelLogoutNotifications(ctx)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		assert.True(t, gock.IsDone())
+		_, err = s.Application.BackChannelLogoutNotifications().Load(ctx, notification.NotificationID)
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, err)
+	})
+
+	s.T().Run("session notification acknowledged", func(t *testing.T) {
+		// given
+		sessionID := uuid.NewV4()
+		notification := createNotification(0, &sessionID)
+		defer gock.Off()
+		gock.New("http://wit.localhost").
+			Post("/api/logout/backchannel").
+			MatchType("url").
+			SetMatcher(logoutTokenMatcher(notification)).
+			Reply(200)
+		// when
+		count, err := svc.SendBackChannelLogoutNotifications(ctx)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		assert.True(t, gock.IsDone())
+	})
+
+	s.T().Run("notification failed and rescheduled", func(t *testing.T) {
+		// given
+		notification := createNotification(0, nil)
+		defer gock.Off()
+		gock.New("http://wit.localhost").
+			Post("/api/logout/backchannel").
+			Reply(500).
+			BodyString("oopsie woopsie")
+		// when
+		count, err := svc.SendBackChannelLogoutNotifications(ctx)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+		result, err := s.Application.BackChannelLogoutNotifications().Load(ctx, notification.NotificationID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Attempts)
+		require.NotNil(t, result.LastError)
+		assert.Contains(t, *result.LastError, "oopsie woopsie")
+		assert.True(t, result.NextAttempt.After(time.Now()))
+		// notification is not due anymore
+		due, err := s.Application.BackChannelLogoutNotifications().ListDue(ctx, time.Now(), 100)
+		require.NoError(t, err)
+		for _, n := range due {
+			assert.NotEqual(t, notification.NotificationID, n.NotificationID)
+		}
+		// cleanup
+		err = s.Application.BackChannelLogoutNotifications().Delete(ctx, notification.NotificationID)
+		require.NoError(t, err)
+	})
+
+	s.T().Run("notification failed after max attempts", func(t *testing.T) {
+		// given
+		notification := createNotification(config.GetBackChannelLogoutMaxAttempts()-1, nil)
+		defer gock.Off()
+		gock.New("http://wit.localhost").
+			Post("/api/logout/backchannel").
+			Reply(500)
+		// when
+		count, err := svc.SendBackChannelLogoutNotifications(ctx)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+		_, err = s.Application.BackChannelLogoutNotifications().Load(ctx, notification.NotificationID)
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, err)
+	})
+}
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/fabric8-services/fabric8-auth/authorization/token"
+	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
 	"net/url"
 	"regexp"
+	"time"
 
 	"github.com/fabric8-services/fabric8-auth/application/service"
 	"github.com/fabric8-services/fabric8-auth/application/service/base"
@@ -19,8 +21,14 @@ import (
 )
 
 type LogoutServiceConfiguration interface {
+	manager.TokenManagerConfiguration
 	GetValidRedirectURLs() string
 	GetOAuthProviderEndpointLogout() string
+	GetBackChannelLogoutURIs() map[string]string
+	GetBackChannelLogoutBatchSize() int
+	GetBackChannelLogoutMaxAttempts() int
+	GetBackChannelLogoutRetryDelay() time.Duration
+	GetBackChannelLogoutRequestTimeout() time.Duration
 }
 
 type logoutServiceImpl struct {
@@ -101,12 +109,24 @@ func (s *logoutServiceImpl) Logout(ctx context.Context, redirectURL string) (str
 				return errors.NewInternalError(err)
 			}
 
+			// Terminate all the sessions of the identity
+			err = s.Repositories().SessionRepository().DeleteForIdentity(ctx, identityID)
+			if err != nil {
+				return errors.NewInternalError(err)
+			}
+
 			// Update the identity's last active timestamp on logout
 			err = s.Repositories().Identities().TouchLastActive(ctx, identityID)
 			if err != nil {
 				return errors.NewInternalError(err)
 			}
 
+			// Notify the relying parties (asynchronously) that the user logged out
+			err = s.ScheduleBackChannelLogoutNotifications(ctx, identityID, nil)
+			if err != nil {
+				return errors.NewInternalError(err)
+			}
+
 			return nil
 		})
 
@@ -0,0 +1,27 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/rest"
+	"github.com/fabric8-services/fabric8-auth/worker"
+)
+
+const (
+	// BackChannelLogout the name of the job that notifies the relying parties when a user logged out.
+	// Also, the name of the lock used by its worker.
+	BackChannelLogout = "backchannel-logout"
+)
+
+// NewBackChannelLogoutJob returns a new job which sends the pending logout tokens to the back-channel
+// logout URIs of the relying parties, and retries the notifications which failed
+func NewBackChannelLogoutJob(app application.Application, schedule string, options ...rest.HTTPClientOption) worker.Job {
+	return worker.Job{
+		Name:     BackChannelLogout,
+		Schedule: schedule,
+		Run: func(ctx context.Context) (int, error) {
+			return app.LogoutService().SendBackChannelLogoutNotifications(ctx, options...)
+		},
+	}
+}
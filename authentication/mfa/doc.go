@@ -0,0 +1,3 @@
+// Package mfa contains the code to manage the multi-factor authentication of the users, based on time-based one-time
+// passwords (TOTP) and single-use recovery codes.
+package mfa
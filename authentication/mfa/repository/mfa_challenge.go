@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// MFAChallenge a login which is waiting for the user to verify their second authentication factor.
+// The tokens are only issued once the challenge has been successfully verified.
+type MFAChallenge struct {
+	gormsupport.LifecycleHardDelete
+	// ChallengeID the ID of the challenge. This is the primary key value.
+	ChallengeID uuid.UUID `sql:"type:uuid" gorm:"primary_key;column:challenge_id"`
+	IdentityID  uuid.UUID `sql:"type:uuid"`
+	// Referrer the URL to redirect the user to once the challenge is verified
+	Referrer string
+	// APIClient the name of the api client which initiated the login, if any
+	APIClient string
+	// Attempts the number of failed verifications
+	Attempts int
+	// ExpiresAt the time after which the challenge can not be verified anymore
+	ExpiresAt time.Time
+	// WebAuthnChallenge the base64url-encoded challenge of the WebAuthn assertion ceremony started for this login, if any
+	WebAuthnChallenge string `gorm:"column:webauthn_challenge"`
+	// AuthorizationCodeID the authorization code which is released once the challenge is verified, if the login was
+	// started with the authorization code flow
+	AuthorizationCodeID *uuid.UUID `sql:"type:uuid"`
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m MFAChallenge) TableName() string {
+	return "mfa_challenge"
+}
+
+// GormMFAChallengeRepository is the implementation of the storage interface for MFAChallenge.
+type GormMFAChallengeRepository struct {
+	db *gorm.DB
+}
+
+// NewMFAChallengeRepository creates a new storage type.
+func NewMFAChallengeRepository(db *gorm.DB) MFAChallengeRepository {
+	return &GormMFAChallengeRepository{db: db}
+}
+
+// MFAChallengeRepository represents the storage interface.
+type MFAChallengeRepository interface {
+	Load(ctx context.Context, id uuid.UUID) (*MFAChallenge, error)
+	LoadForUpdate(ctx context.Context, id uuid.UUID) (*MFAChallenge, error)
+	Create(ctx context.Context, challenge *MFAChallenge) error
+	Save(ctx context.Context, challenge *MFAChallenge) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	DeleteExpired(ctx context.Context, now time.Time) error
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m *GormMFAChallengeRepository) TableName() string {
+	return "mfa_challenge"
+}
+
+// Load returns a single MFAChallenge as a Database Model
+func (m *GormMFAChallengeRepository) Load(ctx context.Context, id uuid.UUID) (*MFAChallenge, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "mfa_challenge", "load"}, time.Now())
+
+	var native MFAChallenge
+	err := m.db.Table(m.TableName()).Where("challenge_id = ?", id).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("mfa_challenge", id.String())
+	}
+	return &native, errs.WithStack(err)
+}
+
+// LoadForUpdate returns a single MFAChallenge as a Database Model, and locks it until the end of the current
+// transaction, so that the concurrent verifications of the challenge are serialized
+func (m *GormMFAChallengeRepository) LoadForUpdate(ctx context.Context, id uuid.UUID) (*MFAChallenge, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "mfa_challenge", "load_for_update"}, time.Now())
+
+	var native MFAChallenge
+	err := m.db.Table(m.TableName()).Where("challenge_id = ?", id).Set("gorm:query_option", "FOR UPDATE").Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("mfa_challenge", id.String())
+	}
+	return &native, errs.WithStack(err)
+}
+
+// Create creates a new record.
+func (m *GormMFAChallengeRepository) Create(ctx context.Context, challenge *MFAChallenge) error {
+	defer goa.MeasureSince([]string{"goa", "db", "mfa_challenge", "create"}, time.Now())
+
+	if challenge.ChallengeID == uuid.Nil {
+		challenge.ChallengeID = uuid.NewV4()
+	}
+	err := m.db.Create(challenge).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": challenge.IdentityID,
+			"err":         err,
+		}, "unable to create the MFA challenge")
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"challenge_id": challenge.ChallengeID,
+		"identity_id":  challenge.IdentityID,
+	}, "MFA challenge created!")
+	return nil
+}
+
+// Save modifies a single record.
+func (m *GormMFAChallengeRepository) Save(ctx context.Context, challenge *MFAChallenge) error {
+	defer goa.MeasureSince([]string{"goa", "db", "mfa_challenge", "save"}, time.Now())
+
+	result := m.db.Save(challenge)
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"challenge_id": challenge.ChallengeID,
+			"err":          result.Error,
+		}, "unable to update the MFA challenge")
+		return errs.WithStack(result.Error)
+	}
+	return nil
+}
+
+// Delete removes a single record. This is a hard delete!
+func (m *GormMFAChallengeRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "mfa_challenge", "delete"}, time.Now())
+
+	result := m.db.Delete(&MFAChallenge{ChallengeID: id})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"challenge_id": id,
+			"err":          result.Error,
+		}, "unable to delete the MFA challenge")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("mfa_challenge", id.String())
+	}
+	return nil
+}
+
+// DeleteExpired removes all the challenges which expired before the given time. This is a hard delete!
+func (m *GormMFAChallengeRepository) DeleteExpired(ctx context.Context, now time.Time) error {
+	defer goa.MeasureSince([]string{"goa", "db", "mfa_challenge", "DeleteExpired"}, time.Now())
+
+	err := m.db.Where("expires_at < ?", now).Delete(&MFAChallenge{}).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to delete the expired MFA challenges")
+		return errs.WithStack(err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// RecoveryCode a single-use code which can be used instead of a TOTP code, e.g. when the user lost their device.
+// Only the bcrypt hash of the code is stored.
+type RecoveryCode struct {
+	gormsupport.LifecycleHardDelete
+	// RecoveryCodeID the ID of the recovery code. This is the primary key value.
+	RecoveryCodeID uuid.UUID `sql:"type:uuid" gorm:"primary_key;column:recovery_code_id"`
+	IdentityID     uuid.UUID `sql:"type:uuid"`
+	CodeHash       string
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m RecoveryCode) TableName() string {
+	return "mfa_recovery_code"
+}
+
+// GormRecoveryCodeRepository is the implementation of the storage interface for RecoveryCode.
+type GormRecoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+// NewRecoveryCodeRepository creates a new storage type.
+func NewRecoveryCodeRepository(db *gorm.DB) RecoveryCodeRepository {
+	return &GormRecoveryCodeRepository{db: db}
+}
+
+// RecoveryCodeRepository represents the storage interface.
+type RecoveryCodeRepository interface {
+	Create(ctx context.Context, code *RecoveryCode) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	DeleteForIdentity(ctx context.Context, identityID uuid.UUID) error
+	ListForIdentity(ctx context.Context, identityID uuid.UUID) ([]RecoveryCode, error)
+}
+
+// Create creates a new record.
+func (m *GormRecoveryCodeRepository) Create(ctx context.Context, code *RecoveryCode) error {
+	defer goa.MeasureSince([]string{"goa", "db", "mfa_recovery_code", "create"}, time.Now())
+
+	if code.RecoveryCodeID == uuid.Nil {
+		code.RecoveryCodeID = uuid.NewV4()
+	}
+	err := m.db.Create(code).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": code.IdentityID,
+			"err":         err,
+		}, "unable to create the recovery code")
+		return errs.WithStack(err)
+	}
+	return nil
+}
+
+// Delete removes a single record. This is a hard delete!
+func (m *GormRecoveryCodeRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "mfa_recovery_code", "delete"}, time.Now())
+
+	result := m.db.Delete(&RecoveryCode{RecoveryCodeID: id})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"recovery_code_id": id,
+			"err":              result.Error,
+		}, "unable to delete the recovery code")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("mfa_recovery_code", id.String())
+	}
+	return nil
+}
+
+// DeleteForIdentity removes all the recovery codes of the given identity. This is a hard delete!
+func (m *GormRecoveryCodeRepository) DeleteForIdentity(ctx context.Context, identityID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "mfa_recovery_code", "DeleteForIdentity"}, time.Now())
+
+	err := m.db.Where("identity_id = ?", identityID).Delete(&RecoveryCode{}).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": identityID,
+			"err":         err,
+		}, "unable to delete the recovery codes")
+		return errs.WithStack(err)
+	}
+	return nil
+}
+
+// ListForIdentity returns the remaining recovery codes of the given identity
+func (m *GormRecoveryCodeRepository) ListForIdentity(ctx context.Context, identityID uuid.UUID) ([]RecoveryCode, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "mfa_recovery_code", "ListForIdentity"}, time.Now())
+
+	var rows []RecoveryCode
+	err := m.db.Where("identity_id = ?", identityID).Find(&rows).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return rows, nil
+}
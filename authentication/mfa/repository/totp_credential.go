@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/authorization/token/encryption"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// TOTPCredential the TOTP secret shared between the server and the authenticator application of a user
+type TOTPCredential struct {
+	gormsupport.LifecycleHardDelete
+	// IdentityID the ID of the identity which enrolled. This is the primary key value.
+	IdentityID uuid.UUID `sql:"type:uuid" gorm:"primary_key"`
+	// Secret the TOTP secret. Encrypted in the database, and transparently decrypted when loaded
+	Secret string
+	// EncryptedDataKey the data key used to encrypt the secret, itself encrypted with the master key
+	EncryptedDataKey string
+	// MasterKeyID the ID of the master key used to encrypt the data key
+	MasterKeyID string
+	// Confirmed true once the user proved that their authenticator application was correctly configured
+	Confirmed bool
+	// LastUsedStep the time step of the last accepted code, to prevent replays
+	LastUsedStep int64
+	// FailedAttempts the number of consecutive invalid codes submitted by the user, across all the login challenges
+	FailedAttempts int
+	// LockedUntil the time until which the codes of the user are not verified anymore, after too many invalid codes
+	LockedUntil *time.Time
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m TOTPCredential) TableName() string {
+	return "totp_credential"
+}
+
+// GormTOTPCredentialRepository is the implementation of the storage interface for TOTPCredential.
+// Secrets are transparently encrypted before they are stored and decrypted after they are loaded.
+type GormTOTPCredentialRepository struct {
+	db        *gorm.DB
+	encryptor encryption.Encryptor
+}
+
+// NewTOTPCredentialRepository creates a new storage type.
+func NewTOTPCredentialRepository(db *gorm.DB, encryptor encryption.Encryptor) TOTPCredentialRepository {
+	return &GormTOTPCredentialRepository{db: db, encryptor: encryptor}
+}
+
+// TOTPCredentialRepository represents the storage interface.
+type TOTPCredentialRepository interface {
+	Load(ctx context.Context, identityID uuid.UUID) (*TOTPCredential, error)
+	LoadForUpdate(ctx context.Context, identityID uuid.UUID) (*TOTPCredential, error)
+	Create(ctx context.Context, credential *TOTPCredential) error
+	Save(ctx context.Context, credential *TOTPCredential) error
+	Delete(ctx context.Context, identityID uuid.UUID) error
+	ReEncrypt(ctx context.Context, limit int) (int, error)
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m *GormTOTPCredentialRepository) TableName() string {
+	return "totp_credential"
+}
+
+// Load returns the TOTP credential of the given identity, with its secret decrypted
+func (m *GormTOTPCredentialRepository) Load(ctx context.Context, identityID uuid.UUID) (*TOTPCredential, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "totp_credential", "load"}, time.Now())
+	return m.load(m.db, identityID)
+}
+
+// LoadForUpdate returns the TOTP credential of the given identity, with its secret decrypted. The row is locked until
+// the end of the current transaction, so that the concurrent verifications of the codes of the identity are serialized.
+func (m *GormTOTPCredentialRepository) LoadForUpdate(ctx context.Context, identityID uuid.UUID) (*TOTPCredential, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "totp_credential", "load_for_update"}, time.Now())
+	return m.load(m.db.Set("gorm:query_option", "FOR UPDATE"), identityID)
+}
+
+func (m *GormTOTPCredentialRepository) load(db *gorm.DB, identityID uuid.UUID) (*TOTPCredential, error) {
+	var native TOTPCredential
+	err := db.Table(m.TableName()).Where("identity_id = ?", identityID).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("totp_credential", identityID.String())
+	}
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	secret, err := m.encryptor.Decrypt(encryption.Envelope{
+		Ciphertext:       native.Secret,
+		EncryptedDataKey: native.EncryptedDataKey,
+		KeyID:            native.MasterKeyID,
+	})
+	if err != nil {
+		return nil, errs.Wrapf(err, "unable to decrypt the TOTP secret of identity '%s'", identityID)
+	}
+	native.Secret = secret
+	return &native, nil
+}
+
+// Create creates a new record, the secret being encrypted before it is stored
+func (m *GormTOTPCredentialRepository) Create(ctx context.Context, credential *TOTPCredential) error {
+	defer goa.MeasureSince([]string{"goa", "db", "totp_credential", "create"}, time.Now())
+
+	secret := credential.Secret
+	err := m.encrypt(credential)
+	if err != nil {
+		return err
+	}
+	err = m.db.Create(credential).Error
+	// callers keep working with the plain text secret
+	credential.Secret = secret
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": credential.IdentityID,
+			"err":         err,
+		}, "unable to create the TOTP credential")
+		return errs.WithStack(err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identity_id": credential.IdentityID,
+	}, "TOTP credential created!")
+	return nil
+}
+
+// Save modifies a single record, the secret being encrypted with a new data key before it is stored
+func (m *GormTOTPCredentialRepository) Save(ctx context.Context, credential *TOTPCredential) error {
+	defer goa.MeasureSince([]string{"goa", "db", "totp_credential", "save"}, time.Now())
+
+	secret := credential.Secret
+	err := m.encrypt(credential)
+	if err != nil {
+		return err
+	}
+	result := m.db.Model(&TOTPCredential{}).Where("identity_id = ?", credential.IdentityID).Updates(map[string]interface{}{
+		"secret":             credential.Secret,
+		"encrypted_data_key": credential.EncryptedDataKey,
+		"master_key_id":      credential.MasterKeyID,
+		"confirmed":          credential.Confirmed,
+		"last_used_step":     credential.LastUsedStep,
+		"failed_attempts":    credential.FailedAttempts,
+		"locked_until":       credential.LockedUntil,
+	})
+	// callers keep working with the plain text secret
+	credential.Secret = secret
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": credential.IdentityID,
+			"err":         result.Error,
+		}, "unable to update the TOTP credential")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("totp_credential", credential.IdentityID.String())
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"identity_id": credential.IdentityID,
+	}, "TOTP credential saved!")
+	return nil
+}
+
+// Delete removes the TOTP credential of the given identity. This is a hard delete!
+func (m *GormTOTPCredentialRepository) Delete(ctx context.Context, identityID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "totp_credential", "delete"}, time.Now())
+
+	result := m.db.Delete(&TOTPCredential{IdentityID: identityID})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": identityID,
+			"err":         result.Error,
+		}, "unable to delete the TOTP credential")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("totp_credential", identityID.String())
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"identity_id": identityID,
+	}, "TOTP credential deleted!")
+	return nil
+}
+
+// ReEncrypt encrypts with the primary master key (at most) `limit` TOTP secrets which were encrypted with another
+// master key (i.e., before a master key rotation), so that the previous master key can eventually be retired.
+// The selected rows are locked until the end of the current transaction, and rows which are already locked by
+// another transaction are skipped, so that several pods can run this method concurrently.
+// Returns the number of secrets that were re-encrypted.
+func (m *GormTOTPCredentialRepository) ReEncrypt(ctx context.Context, limit int) (int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "totp_credential", "reEncrypt"}, time.Now())
+
+	var credentials []TOTPCredential
+	err := m.db.Table(m.TableName()).
+		Where("master_key_id <> ?", m.encryptor.PrimaryKeyID()).
+		Limit(limit).
+		Set("gorm:query_option", "FOR UPDATE SKIP LOCKED").
+		Find(&credentials).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return 0, errs.WithStack(err)
+	}
+	for i := range credentials {
+		credential := &credentials[i]
+		previousKeyID := credential.MasterKeyID
+		secret, err := m.encryptor.Decrypt(encryption.Envelope{
+			Ciphertext:       credential.Secret,
+			EncryptedDataKey: credential.EncryptedDataKey,
+			KeyID:            credential.MasterKeyID,
+		})
+		if err != nil {
+			return i, errs.Wrapf(err, "unable to decrypt the TOTP secret of identity '%s'", credential.IdentityID)
+		}
+		credential.Secret = secret
+		if err := m.encrypt(credential); err != nil {
+			return i, err
+		}
+		// do not update the `updated_at` column, since the secret itself did not change
+		err = m.db.Model(credential).UpdateColumns(map[string]interface{}{
+			"secret":             credential.Secret,
+			"encrypted_data_key": credential.EncryptedDataKey,
+			"master_key_id":      credential.MasterKeyID,
+		}).Error
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"identity_id": credential.IdentityID,
+				"err":         err,
+			}, "unable to re-encrypt the TOTP credential")
+			return i, errs.WithStack(err)
+		}
+		log.Debug(ctx, map[string]interface{}{
+			"identity_id":     credential.IdentityID,
+			"previous_key_id": previousKeyID,
+			"key_id":          credential.MasterKeyID,
+		}, "TOTP credential re-encrypted")
+	}
+	return len(credentials), nil
+}
+
+// encrypt replaces the plain text secret of the given credential with its encrypted value,
+// and sets the wrapped data key and master key ID accordingly.
+func (m *GormTOTPCredentialRepository) encrypt(credential *TOTPCredential) error {
+	envelope, err := m.encryptor.Encrypt(credential.Secret)
+	if err != nil {
+		return errs.Wrapf(err, "unable to encrypt the TOTP secret of identity '%s'", credential.IdentityID)
+	}
+	credential.Secret = envelope.Ciphertext
+	credential.EncryptedDataKey = envelope.EncryptedDataKey
+	credential.MasterKeyID = envelope.KeyID
+	return nil
+}
@@ -0,0 +1,66 @@
+package repository_test
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization/token/encryption"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type totpCredentialBlackboxTest struct {
+	gormtestsupport.DBTestSuite
+	masterKeys map[string][]byte
+}
+
+func TestRunTOTPCredentialBlackboxTest(t *testing.T) {
+	suite.Run(t, &totpCredentialBlackboxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *totpCredentialBlackboxTest) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	// also include the configured keys, in case other credentials exist in the DB
+	s.masterKeys = s.Configuration.GetExternalTokenEncryptionMasterKeys()
+	s.masterKeys["test-key-1"] = []byte("0123456789abcdef0123456789abcdef")
+	s.masterKeys["test-key-2"] = []byte("fedcba9876543210fedcba9876543210")
+}
+
+func (s *totpCredentialBlackboxTest) newRepository(primaryKeyID string) repository.TOTPCredentialRepository {
+	encryptor, err := encryption.NewEnvelopeEncryptor(primaryKeyID, s.masterKeys)
+	require.NoError(s.T(), err)
+	return repository.NewTOTPCredentialRepository(s.DB, encryptor)
+}
+
+func (s *totpCredentialBlackboxTest) TestReEncrypt() {
+	// given
+	identity := s.Graph.CreateUser().Identity()
+	credential := &repository.TOTPCredential{
+		IdentityID: identity.ID,
+		Secret:     "JBSWY3DPEHPK3PXP",
+		Confirmed:  true,
+	}
+	require.NoError(s.T(), s.newRepository("test-key-1").Create(s.Ctx, credential))
+	rotatedRepo := s.newRepository("test-key-2")
+	// when
+	count, err := rotatedRepo.ReEncrypt(s.Ctx, 1000)
+	// then
+	require.NoError(s.T(), err)
+	assert.True(s.T(), count >= 1)
+	var native repository.TOTPCredential
+	require.NoError(s.T(), s.DB.Table(credential.TableName()).Where("identity_id = ?", identity.ID).Find(&native).Error)
+	assert.Equal(s.T(), "test-key-2", native.MasterKeyID)
+	// the secret can still be decrypted once the previous master key is retired
+	delete(s.masterKeys, "test-key-1")
+	loaded, err := s.newRepository("test-key-2").Load(s.Ctx, identity.ID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "JBSWY3DPEHPK3PXP", loaded.Secret)
+	assert.True(s.T(), loaded.Confirmed)
+	// and nothing left to re-encrypt
+	count, err = rotatedRepo.ReEncrypt(s.Ctx, 1000)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 0, count)
+}
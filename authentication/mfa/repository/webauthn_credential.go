@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// WebAuthnCredential a public key credential registered by a user with a WebAuthn authenticator (security key,
+// platform authenticator, etc.)
+type WebAuthnCredential struct {
+	gormsupport.LifecycleHardDelete
+	// WebAuthnCredentialID the ID of the credential record. This is the primary key value.
+	WebAuthnCredentialID uuid.UUID `sql:"type:uuid" gorm:"primary_key;column:webauthn_credential_id"`
+	UserID               uuid.UUID `sql:"type:uuid"`
+	// CredentialID the base64url-encoded credential ID, as chosen by the authenticator
+	CredentialID string
+	// Name the name given by the user to the credential
+	Name string
+	// PublicKey the credential public key, in its COSE_Key encoding
+	PublicKey []byte
+	// SignCount the last known signature counter of the authenticator
+	SignCount int64
+	// AAGUID the hex-encoded identifier of the authenticator model
+	AAGUID string `gorm:"column:aaguid"`
+	// LastUsedAt the time of the last successful assertion
+	LastUsedAt *time.Time
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m WebAuthnCredential) TableName() string {
+	return "webauthn_credential"
+}
+
+// GormWebAuthnCredentialRepository is the implementation of the storage interface for WebAuthnCredential.
+type GormWebAuthnCredentialRepository struct {
+	db *gorm.DB
+}
+
+// NewWebAuthnCredentialRepository creates a new storage type.
+func NewWebAuthnCredentialRepository(db *gorm.DB) WebAuthnCredentialRepository {
+	return &GormWebAuthnCredentialRepository{db: db}
+}
+
+// WebAuthnCredentialRepository represents the storage interface.
+type WebAuthnCredentialRepository interface {
+	Load(ctx context.Context, id uuid.UUID) (*WebAuthnCredential, error)
+	LoadByCredentialID(ctx context.Context, credentialID string) (*WebAuthnCredential, error)
+	Create(ctx context.Context, credential *WebAuthnCredential) error
+	Save(ctx context.Context, credential *WebAuthnCredential) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	ListForUser(ctx context.Context, userID uuid.UUID) ([]WebAuthnCredential, error)
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m *GormWebAuthnCredentialRepository) TableName() string {
+	return "webauthn_credential"
+}
+
+// Load returns a single WebAuthnCredential as a Database Model
+func (m *GormWebAuthnCredentialRepository) Load(ctx context.Context, id uuid.UUID) (*WebAuthnCredential, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "webauthn_credential", "load"}, time.Now())
+
+	var native WebAuthnCredential
+	err := m.db.Table(m.TableName()).Where("webauthn_credential_id = ?", id).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("webauthn_credential", id.String())
+	}
+	return &native, errs.WithStack(err)
+}
+
+// LoadByCredentialID returns the WebAuthnCredential with the given base64url-encoded credential ID
+func (m *GormWebAuthnCredentialRepository) LoadByCredentialID(ctx context.Context, credentialID string) (*WebAuthnCredential, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "webauthn_credential", "LoadByCredentialID"}, time.Now())
+
+	var native WebAuthnCredential
+	err := m.db.Table(m.TableName()).Where("credential_id = ?", credentialID).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("webauthn_credential", credentialID)
+	}
+	return &native, errs.WithStack(err)
+}
+
+// Create creates a new record.
+func (m *GormWebAuthnCredentialRepository) Create(ctx context.Context, credential *WebAuthnCredential) error {
+	defer goa.MeasureSince([]string{"goa", "db", "webauthn_credential", "create"}, time.Now())
+
+	if credential.WebAuthnCredentialID == uuid.Nil {
+		credential.WebAuthnCredentialID = uuid.NewV4()
+	}
+	err := m.db.Create(credential).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"user_id": credential.UserID,
+			"err":     err,
+		}, "unable to create the WebAuthn credential")
+		if gormsupport.IsUniqueViolation(err, "webauthn_credential_credential_id_idx") {
+			return errors.NewDataConflictError("the credential is already registered")
+		}
+		return errs.WithStack(err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"webauthn_credential_id": credential.WebAuthnCredentialID,
+		"user_id":                credential.UserID,
+	}, "WebAuthn credential created!")
+	return nil
+}
+
+// Save modifies a single record.
+func (m *GormWebAuthnCredentialRepository) Save(ctx context.Context, credential *WebAuthnCredential) error {
+	defer goa.MeasureSince([]string{"goa", "db", "webauthn_credential", "save"}, time.Now())
+
+	result := m.db.Save(credential)
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"webauthn_credential_id": credential.WebAuthnCredentialID,
+			"err":                    result.Error,
+		}, "unable to update the WebAuthn credential")
+		return errs.WithStack(result.Error)
+	}
+	return nil
+}
+
+// Delete removes a single record. This is a hard delete!
+func (m *GormWebAuthnCredentialRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "webauthn_credential", "delete"}, time.Now())
+
+	result := m.db.Delete(&WebAuthnCredential{WebAuthnCredentialID: id})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"webauthn_credential_id": id,
+			"err":                    result.Error,
+		}, "unable to delete the WebAuthn credential")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("webauthn_credential", id.String())
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"webauthn_credential_id": id,
+	}, "WebAuthn credential deleted!")
+	return nil
+}
+
+// ListForUser returns the WebAuthn credentials of the given user, oldest first
+func (m *GormWebAuthnCredentialRepository) ListForUser(ctx context.Context, userID uuid.UUID) ([]WebAuthnCredential, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "webauthn_credential", "ListForUser"}, time.Now())
+
+	var rows []WebAuthnCredential
+	err := m.db.Where("user_id = ?", userID).Order("created_at").Find(&rows).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// WebAuthnRegistration a pending WebAuthn registration ceremony. A user has at most one pending registration.
+type WebAuthnRegistration struct {
+	gormsupport.LifecycleHardDelete
+	// UserID the ID of the user who is registering a credential. This is the primary key value.
+	UserID uuid.UUID `sql:"type:uuid" gorm:"primary_key"`
+	// Challenge the base64url-encoded challenge of the ceremony
+	Challenge string
+	// ExpiresAt the time after which the registration can not be completed anymore
+	ExpiresAt time.Time
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m WebAuthnRegistration) TableName() string {
+	return "webauthn_registration"
+}
+
+// GormWebAuthnRegistrationRepository is the implementation of the storage interface for WebAuthnRegistration.
+type GormWebAuthnRegistrationRepository struct {
+	db *gorm.DB
+}
+
+// NewWebAuthnRegistrationRepository creates a new storage type.
+func NewWebAuthnRegistrationRepository(db *gorm.DB) WebAuthnRegistrationRepository {
+	return &GormWebAuthnRegistrationRepository{db: db}
+}
+
+// WebAuthnRegistrationRepository represents the storage interface.
+type WebAuthnRegistrationRepository interface {
+	Load(ctx context.Context, userID uuid.UUID) (*WebAuthnRegistration, error)
+	Create(ctx context.Context, registration *WebAuthnRegistration) error
+	Delete(ctx context.Context, userID uuid.UUID) error
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m *GormWebAuthnRegistrationRepository) TableName() string {
+	return "webauthn_registration"
+}
+
+// Load returns the pending registration of the given user
+func (m *GormWebAuthnRegistrationRepository) Load(ctx context.Context, userID uuid.UUID) (*WebAuthnRegistration, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "webauthn_registration", "load"}, time.Now())
+
+	var native WebAuthnRegistration
+	err := m.db.Table(m.TableName()).Where("user_id = ?", userID).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("webauthn_registration", userID.String())
+	}
+	return &native, errs.WithStack(err)
+}
+
+// Create creates a new record, replacing the pending registration of the user if any
+func (m *GormWebAuthnRegistrationRepository) Create(ctx context.Context, registration *WebAuthnRegistration) error {
+	defer goa.MeasureSince([]string{"goa", "db", "webauthn_registration", "create"}, time.Now())
+
+	err := m.db.Where("user_id = ?", registration.UserID).Delete(&WebAuthnRegistration{}).Error
+	if err != nil {
+		return errs.WithStack(err)
+	}
+	err = m.db.Create(registration).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"user_id": registration.UserID,
+			"err":     err,
+		}, "unable to create the WebAuthn registration")
+		return errs.WithStack(err)
+	}
+	return nil
+}
+
+// Delete removes the pending registration of the given user. This is a hard delete!
+func (m *GormWebAuthnRegistrationRepository) Delete(ctx context.Context, userID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "webauthn_registration", "delete"}, time.Now())
+
+	result := m.db.Delete(&WebAuthnRegistration{UserID: userID})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"user_id": userID,
+			"err":     result.Error,
+		}, "unable to delete the WebAuthn registration")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("webauthn_registration", userID.String())
+	}
+	return nil
+}
@@ -0,0 +1,377 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application/service"
+	"github.com/fabric8-services/fabric8-auth/application/service/base"
+	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa"
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa/repository"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// MFAServiceConfiguration the required configuration for the MFA service implementation
+type MFAServiceConfiguration interface {
+	GetMFATOTPIssuer() string
+	GetMFAChallengeExpiresIn() time.Duration
+	GetMFAChallengeMaxAttempts() int
+	GetMFALockoutMaxAttempts() int
+	GetMFALockoutDuration() time.Duration
+	GetMFARecoveryCodesCount() int
+	GetExternalTokenReencryptionBatchSize() int
+}
+
+type mfaServiceImpl struct {
+	base.BaseService
+	config MFAServiceConfiguration
+}
+
+// NewMFAService creates a new multi-factor authentication service
+func NewMFAService(context servicecontext.ServiceContext, config MFAServiceConfiguration) service.MFAService {
+	return &mfaServiceImpl{
+		BaseService: base.NewBaseService(context),
+		config:      config,
+	}
+}
+
+// EnrollTOTP generates a new TOTP secret for the given identity and returns it along with its `otpauth://` key URI.
+// The enrollment must then be confirmed with a valid code (see ConfirmTOTP) before the second factor is required during
+// login. Any previous unconfirmed enrollment is replaced. Returns a DataConflictError if the identity already enrolled.
+func (s *mfaServiceImpl) EnrollTOTP(ctx context.Context, identityID uuid.UUID) (string, string, error) {
+	var secret, keyURI string
+	err := s.ExecuteInTransaction(func() error {
+		identity, err := s.Repositories().Identities().Load(ctx, identityID)
+		if err != nil {
+			return err
+		}
+		credential, err := s.Repositories().TOTPCredentials().Load(ctx, identityID)
+		if err != nil {
+			if notFound, _ := errors.IsNotFoundError(err); !notFound {
+				return errors.NewInternalError(err)
+			}
+		} else if credential.Confirmed {
+			return errors.NewDataConflictError("multi-factor authentication is already enabled")
+		} else {
+			err = s.Repositories().TOTPCredentials().Delete(ctx, identityID)
+			if err != nil {
+				return errors.NewInternalError(err)
+			}
+		}
+
+		secret, err = mfa.GenerateTOTPSecret()
+		if err != nil {
+			return errors.NewInternalError(err)
+		}
+		err = s.Repositories().TOTPCredentials().Create(ctx, &repository.TOTPCredential{
+			IdentityID: identityID,
+			Secret:     secret,
+		})
+		if err != nil {
+			return errors.NewInternalError(err)
+		}
+		keyURI = mfa.TOTPKeyURI(s.config.GetMFATOTPIssuer(), identity.Username, secret)
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identity_id": identityID,
+	}, "TOTP enrollment started")
+	return secret, keyURI, nil
+}
+
+// ConfirmTOTP confirms the TOTP enrollment of the given identity with a code generated by the authenticator
+// application, and returns the recovery codes of the identity. The recovery codes are only returned once, as only their
+// hash is stored.
+func (s *mfaServiceImpl) ConfirmTOTP(ctx context.Context, identityID uuid.UUID, code string) ([]string, error) {
+	var recoveryCodes []string
+	err := s.ExecuteInTransaction(func() error {
+		credential, err := s.Repositories().TOTPCredentials().Load(ctx, identityID)
+		if err != nil {
+			return err
+		}
+		if credential.Confirmed {
+			return errors.NewDataConflictError("multi-factor authentication is already enabled")
+		}
+		step, valid, err := mfa.ValidateTOTPCode(credential.Secret, code, time.Now(), credential.LastUsedStep)
+		if err != nil {
+			return errors.NewInternalError(err)
+		}
+		if !valid {
+			return errors.NewBadParameterErrorFromString("code", "", "invalid TOTP code")
+		}
+		credential.Confirmed = true
+		credential.LastUsedStep = step
+		err = s.Repositories().TOTPCredentials().Save(ctx, credential)
+		if err != nil {
+			return errors.NewInternalError(err)
+		}
+		recoveryCodes, err = s.resetRecoveryCodes(ctx, identityID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identity_id": identityID,
+	}, "TOTP enrollment confirmed, multi-factor authentication enabled")
+	return recoveryCodes, nil
+}
+
+// DisableTOTP removes the TOTP credential and the recovery codes of the given identity
+func (s *mfaServiceImpl) DisableTOTP(ctx context.Context, identityID uuid.UUID) error {
+	err := s.ExecuteInTransaction(func() error {
+		err := s.Repositories().TOTPCredentials().Delete(ctx, identityID)
+		if err != nil {
+			return err
+		}
+		return s.Repositories().RecoveryCodes().DeleteForIdentity(ctx, identityID)
+	})
+	if err != nil {
+		return err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identity_id": identityID,
+	}, "multi-factor authentication disabled")
+	return nil
+}
+
+// IsEnrolled returns true if the given identity has a confirmed TOTP credential or a registered WebAuthn credential,
+// i.e. if a second factor must be verified during login
+func (s *mfaServiceImpl) IsEnrolled(ctx context.Context, identityID uuid.UUID) (bool, error) {
+	credential, err := s.Repositories().TOTPCredentials().Load(ctx, identityID)
+	if err != nil {
+		if notFound, _ := errors.IsNotFoundError(err); !notFound {
+			return false, errors.NewInternalError(err)
+		}
+	} else if credential.Confirmed {
+		return true, nil
+	}
+	identity, err := s.Repositories().Identities().Load(ctx, identityID)
+	if err != nil {
+		return false, err
+	}
+	webAuthnCredentials, err := s.Repositories().WebAuthnCredentials().ListForUser(ctx, identity.UserID.UUID)
+	if err != nil {
+		return false, errors.NewInternalError(err)
+	}
+	return len(webAuthnCredentials) > 0, nil
+}
+
+// StartChallenge records a login of the given identity which is waiting for the second factor to be verified.
+// The expired challenges are removed along the way.
+func (s *mfaServiceImpl) StartChallenge(ctx context.Context, identityID uuid.UUID, referrer string, apiClient string) (*repository.MFAChallenge, error) {
+	challenge := &repository.MFAChallenge{
+		IdentityID: identityID,
+		Referrer:   referrer,
+		APIClient:  apiClient,
+		ExpiresAt:  time.Now().Add(s.config.GetMFAChallengeExpiresIn()),
+	}
+	err := s.ExecuteInTransaction(func() error {
+		err := s.Repositories().MFAChallenges().DeleteExpired(ctx, time.Now())
+		if err != nil {
+			return err
+		}
+		return s.Repositories().MFAChallenges().Create(ctx, challenge)
+	})
+	if err != nil {
+		return nil, errors.NewInternalError(err)
+	}
+	return challenge, nil
+}
+
+// VerifyChallenge verifies the given TOTP or recovery code for the given login challenge. The challenge is removed once
+// it has been verified, or after too many invalid codes. The invalid codes are also counted per identity across all
+// challenges, and the verification is locked for a while once too many consecutive invalid codes were submitted, so that
+// the codes cannot be brute-forced by starting new challenges. Returns an UnauthorizedError if the challenge does not
+// exist, has expired or if the code is invalid, and a TooManyRequestsError if the verification is locked.
+// The challenge and the TOTP credential are locked during the verification, so that concurrent verifications can
+// neither replay a code nor lose a failed attempt.
+func (s *mfaServiceImpl) VerifyChallenge(ctx context.Context, challengeID uuid.UUID, code string) (*repository.MFAChallenge, error) {
+	var challenge *repository.MFAChallenge
+	verified := false
+	// failed attempts must be recorded, so the transaction is not rolled back when the code is invalid
+	err := s.ExecuteInTransaction(func() error {
+		var err error
+		challenge, err = s.Repositories().MFAChallenges().LoadForUpdate(ctx, challengeID)
+		if err != nil {
+			if notFound, _ := errors.IsNotFoundError(err); notFound {
+				return errors.NewUnauthorizedError("invalid or expired multi-factor authentication challenge")
+			}
+			return errors.NewInternalError(err)
+		}
+		if challenge.ExpiresAt.Before(time.Now()) {
+			return s.Repositories().MFAChallenges().Delete(ctx, challengeID)
+		}
+		credential, err := s.Repositories().TOTPCredentials().LoadForUpdate(ctx, challenge.IdentityID)
+		if err != nil {
+			if notFound, _ := errors.IsNotFoundError(err); notFound {
+				// multi-factor authentication was disabled in the meantime
+				return nil
+			}
+			return errors.NewInternalError(err)
+		}
+		if !credential.Confirmed {
+			// a pending enrollment is not a second factor, as in IsEnrolled
+			return nil
+		}
+		if credential.LockedUntil != nil && credential.LockedUntil.After(time.Now()) {
+			return errors.NewTooManyRequestsError("too many invalid multi-factor authentication codes, please try again later")
+		}
+
+		verified, err = s.verifyCode(ctx, credential, code)
+		if err != nil {
+			return err
+		}
+		if verified {
+			return s.Repositories().MFAChallenges().Delete(ctx, challengeID)
+		}
+		err = s.recordFailedAttempt(ctx, credential)
+		if err != nil {
+			return err
+		}
+		challenge.Attempts++
+		if challenge.Attempts >= s.config.GetMFAChallengeMaxAttempts() {
+			log.Warn(ctx, map[string]interface{}{
+				"identity_id":  challenge.IdentityID,
+				"challenge_id": challengeID,
+				"attempts":     challenge.Attempts,
+			}, "too many invalid multi-factor authentication codes, aborting login")
+			return s.Repositories().MFAChallenges().Delete(ctx, challengeID)
+		}
+		return s.Repositories().MFAChallenges().Save(ctx, challenge)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !verified {
+		return nil, errors.NewUnauthorizedError("invalid or expired multi-factor authentication challenge")
+	}
+	return challenge, nil
+}
+
+// ReEncryptTOTPCredentials encrypts with the primary master key all the TOTP secrets which were encrypted with another
+// master key, in batches of the configured size. Returns the number of secrets that were re-encrypted.
+func (s *mfaServiceImpl) ReEncryptTOTPCredentials(ctx context.Context) (int, error) {
+	batchSize := s.config.GetExternalTokenReencryptionBatchSize()
+	total := 0
+	for {
+		var count int
+		err := s.ExecuteInTransaction(func() error {
+			var err error
+			count, err = s.Repositories().TOTPCredentials().ReEncrypt(ctx, batchSize)
+			return err
+		})
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"err":   err,
+				"total": total,
+			}, "unable to re-encrypt TOTP credentials")
+			return total, err
+		}
+		total += count
+		if count < batchSize {
+			break
+		}
+	}
+	log.Info(ctx, map[string]interface{}{
+		"total": total,
+	}, "re-encrypted TOTP credentials")
+	return total, nil
+}
+
+// verifyCode checks the given code against the given TOTP credential, and then against the remaining recovery codes
+// of its identity. A matching recovery code is removed, as it can only be used once. The consecutive invalid codes of
+// the identity are reset when the code is valid.
+func (s *mfaServiceImpl) verifyCode(ctx context.Context, credential *repository.TOTPCredential, code string) (bool, error) {
+	identityID := credential.IdentityID
+	step, valid, err := mfa.ValidateTOTPCode(credential.Secret, code, time.Now(), credential.LastUsedStep)
+	if err != nil {
+		return false, errors.NewInternalError(err)
+	}
+	if valid {
+		credential.LastUsedStep = step
+		credential.FailedAttempts = 0
+		credential.LockedUntil = nil
+		err = s.Repositories().TOTPCredentials().Save(ctx, credential)
+		if err != nil {
+			return false, errors.NewInternalError(err)
+		}
+		return true, nil
+	}
+	recoveryCodes, err := s.Repositories().RecoveryCodes().ListForIdentity(ctx, identityID)
+	if err != nil {
+		return false, errors.NewInternalError(err)
+	}
+	for _, recoveryCode := range recoveryCodes {
+		if mfa.MatchRecoveryCode(recoveryCode.CodeHash, code) {
+			log.Info(ctx, map[string]interface{}{
+				"identity_id":              identityID,
+				"remaining_recovery_codes": len(recoveryCodes) - 1,
+			}, "recovery code used")
+			err = s.Repositories().RecoveryCodes().Delete(ctx, recoveryCode.RecoveryCodeID)
+			if err != nil {
+				return false, errors.NewInternalError(err)
+			}
+			if credential.FailedAttempts > 0 || credential.LockedUntil != nil {
+				credential.FailedAttempts = 0
+				credential.LockedUntil = nil
+				err = s.Repositories().TOTPCredentials().Save(ctx, credential)
+				if err != nil {
+					return false, errors.NewInternalError(err)
+				}
+			}
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// recordFailedAttempt increments the number of consecutive invalid codes of the identity of the given credential, and
+// locks the verification of its codes once the maximum number of invalid codes is reached
+func (s *mfaServiceImpl) recordFailedAttempt(ctx context.Context, credential *repository.TOTPCredential) error {
+	credential.FailedAttempts++
+	if credential.FailedAttempts >= s.config.GetMFALockoutMaxAttempts() {
+		lockedUntil := time.Now().Add(s.config.GetMFALockoutDuration())
+		log.Warn(ctx, map[string]interface{}{
+			"identity_id":  credential.IdentityID,
+			"attempts":     credential.FailedAttempts,
+			"locked_until": lockedUntil,
+		}, "too many invalid multi-factor authentication codes, locking the verification")
+		credential.FailedAttempts = 0
+		credential.LockedUntil = &lockedUntil
+	}
+	err := s.Repositories().TOTPCredentials().Save(ctx, credential)
+	if err != nil {
+		return errors.NewInternalError(err)
+	}
+	return nil
+}
+
+// resetRecoveryCodes replaces the recovery codes of the given identity with new ones
+func (s *mfaServiceImpl) resetRecoveryCodes(ctx context.Context, identityID uuid.UUID) ([]string, error) {
+	err := s.Repositories().RecoveryCodes().DeleteForIdentity(ctx, identityID)
+	if err != nil {
+		return nil, errors.NewInternalError(err)
+	}
+	codes, hashes, err := mfa.GenerateRecoveryCodes(s.config.GetMFARecoveryCodesCount())
+	if err != nil {
+		return nil, errors.NewInternalError(err)
+	}
+	for _, hash := range hashes {
+		err = s.Repositories().RecoveryCodes().Create(ctx, &repository.RecoveryCode{
+			IdentityID: identityID,
+			CodeHash:   hash,
+		})
+		if err != nil {
+			return nil, errors.NewInternalError(err)
+		}
+	}
+	return codes, nil
+}
@@ -0,0 +1,311 @@
+package service_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	testsupport "github.com/fabric8-services/fabric8-auth/test"
+
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestMFAService(t *testing.T) {
+	suite.Run(t, &mfaServiceBlackboxTestSuite{
+		DBTestSuite: gormtestsupport.NewDBTestSuite(),
+	})
+}
+
+type mfaServiceBlackboxTestSuite struct {
+	gormtestsupport.DBTestSuite
+}
+
+// enroll enrolls the given identity and returns its TOTP secret and recovery codes
+func (s *mfaServiceBlackboxTestSuite) enroll(identityID uuid.UUID) (string, []string) {
+	secret, _, err := s.Application.MFAService().EnrollTOTP(s.Ctx, identityID)
+	require.NoError(s.T(), err)
+	code, err := mfa.GenerateTOTPCode(secret, mfa.TOTPStep(time.Now()))
+	require.NoError(s.T(), err)
+	recoveryCodes, err := s.Application.MFAService().ConfirmTOTP(s.Ctx, identityID, code)
+	require.NoError(s.T(), err)
+	return secret, recoveryCodes
+}
+
+func (s *mfaServiceBlackboxTestSuite) TestEnrollTOTP() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		// when
+		secret, keyURI, err := s.Application.MFAService().EnrollTOTP(s.Ctx, identity.ID)
+		// then
+		require.NoError(t, err)
+		assert.NotEmpty(t, secret)
+		assert.Contains(t, keyURI, "otpauth://totp/")
+		assert.Contains(t, keyURI, "secret="+secret)
+		// not enrolled until confirmed
+		enrolled, err := s.Application.MFAService().IsEnrolled(s.Ctx, identity.ID)
+		require.NoError(t, err)
+		assert.False(t, enrolled)
+		// secret is not stored in plain text
+		var stored string
+		err = s.DB.Table("totp_credential").Where("identity_id = ?", identity.ID).Select("secret").Row().Scan(&stored)
+		require.NoError(t, err)
+		assert.NotEqual(t, secret, stored)
+	})
+
+	s.T().Run("unconfirmed enrollment is replaced", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		first, _, err := s.Application.MFAService().EnrollTOTP(s.Ctx, identity.ID)
+		require.NoError(t, err)
+		// when
+		second, _, err := s.Application.MFAService().EnrollTOTP(s.Ctx, identity.ID)
+		// then
+		require.NoError(t, err)
+		assert.NotEqual(t, first, second)
+	})
+
+	s.T().Run("already enrolled", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		s.enroll(identity.ID)
+		// when
+		_, _, err := s.Application.MFAService().EnrollTOTP(s.Ctx, identity.ID)
+		// then
+		testsupport.AssertError(t, err, errors.DataConflictError{}, "multi-factor authentication is already enabled")
+	})
+}
+
+func (s *mfaServiceBlackboxTestSuite) TestConfirmTOTP() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		// when
+		_, recoveryCodes := s.enroll(identity.ID)
+		// then
+		assert.Len(t, recoveryCodes, s.Configuration.GetMFARecoveryCodesCount())
+		enrolled, err := s.Application.MFAService().IsEnrolled(s.Ctx, identity.ID)
+		require.NoError(t, err)
+		assert.True(t, enrolled)
+	})
+
+	s.T().Run("invalid code", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		_, _, err := s.Application.MFAService().EnrollTOTP(s.Ctx, identity.ID)
+		require.NoError(t, err)
+		// when
+		_, err = s.Application.MFAService().ConfirmTOTP(s.Ctx, identity.ID, "000000x")
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+	})
+
+	s.T().Run("not enrolled", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		// when
+		_, err := s.Application.MFAService().ConfirmTOTP(s.Ctx, identity.ID, "123456")
+		// then
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}
+
+func (s *mfaServiceBlackboxTestSuite) TestDisableTOTP() {
+	// given
+	identity := s.Graph.CreateUser().Identity()
+	s.enroll(identity.ID)
+	// when
+	err := s.Application.MFAService().DisableTOTP(s.Ctx, identity.ID)
+	// then
+	require.NoError(s.T(), err)
+	enrolled, err := s.Application.MFAService().IsEnrolled(s.Ctx, identity.ID)
+	require.NoError(s.T(), err)
+	assert.False(s.T(), enrolled)
+	recoveryCodes, err := s.Application.RecoveryCodes().ListForIdentity(s.Ctx, identity.ID)
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), recoveryCodes)
+}
+
+func (s *mfaServiceBlackboxTestSuite) TestVerifyChallenge() {
+
+	s.T().Run("with TOTP code", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		secret, _ := s.enroll(identity.ID)
+		challenge, err := s.Application.MFAService().StartChallenge(s.Ctx, identity.ID, "https://openshift.io/home", "")
+		require.NoError(t, err)
+		// the code of the current step was already used to confirm the enrollment
+		code, err := mfa.GenerateTOTPCode(secret, mfa.TOTPStep(time.Now())+1)
+		require.NoError(t, err)
+		// when
+		verified, err := s.Application.MFAService().VerifyChallenge(s.Ctx, challenge.ChallengeID, code)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, identity.ID, verified.IdentityID)
+		assert.Equal(t, "https://openshift.io/home", verified.Referrer)
+		// the challenge can only be verified once
+		_, err = s.Application.MFAChallenges().Load(s.Ctx, challenge.ChallengeID)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+		// and the code can not be replayed
+		other, err := s.Application.MFAService().StartChallenge(s.Ctx, identity.ID, "https://openshift.io/home", "")
+		require.NoError(t, err)
+		_, err = s.Application.MFAService().VerifyChallenge(s.Ctx, other.ChallengeID, code)
+		assert.IsType(t, errors.UnauthorizedError{}, errs.Cause(err))
+	})
+
+	s.T().Run("with recovery code", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		_, recoveryCodes := s.enroll(identity.ID)
+		challenge, err := s.Application.MFAService().StartChallenge(s.Ctx, identity.ID, "https://openshift.io/home", "")
+		require.NoError(t, err)
+		// when
+		_, err = s.Application.MFAService().VerifyChallenge(s.Ctx, challenge.ChallengeID, recoveryCodes[0])
+		// then
+		require.NoError(t, err)
+		remaining, err := s.Application.RecoveryCodes().ListForIdentity(s.Ctx, identity.ID)
+		require.NoError(t, err)
+		assert.Len(t, remaining, len(recoveryCodes)-1)
+		// recovery codes can only be used once
+		other, err := s.Application.MFAService().StartChallenge(s.Ctx, identity.ID, "https://openshift.io/home", "")
+		require.NoError(t, err)
+		_, err = s.Application.MFAService().VerifyChallenge(s.Ctx, other.ChallengeID, recoveryCodes[0])
+		assert.IsType(t, errors.UnauthorizedError{}, errs.Cause(err))
+	})
+
+	s.T().Run("too many invalid codes", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		s.enroll(identity.ID)
+		challenge, err := s.Application.MFAService().StartChallenge(s.Ctx, identity.ID, "https://openshift.io/home", "")
+		require.NoError(t, err)
+		// when
+		for i := 0; i < s.Configuration.GetMFAChallengeMaxAttempts(); i++ {
+			_, err = s.Application.MFAService().VerifyChallenge(s.Ctx, challenge.ChallengeID, "invalid")
+			assert.IsType(t, errors.UnauthorizedError{}, errs.Cause(err))
+		}
+		// then
+		_, err = s.Application.MFAChallenges().Load(s.Ctx, challenge.ChallengeID)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+
+	s.T().Run("too many invalid codes across challenges", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		secret, _ := s.enroll(identity.ID)
+		// when
+		for i := 0; i < s.Configuration.GetMFALockoutMaxAttempts(); i++ {
+			// a new challenge for each code, so that the per-challenge limit is never reached
+			challenge, err := s.Application.MFAService().StartChallenge(s.Ctx, identity.ID, "https://openshift.io/home", "")
+			require.NoError(t, err)
+			_, err = s.Application.MFAService().VerifyChallenge(s.Ctx, challenge.ChallengeID, "invalid")
+			assert.IsType(t, errors.UnauthorizedError{}, errs.Cause(err))
+		}
+		// then the verification is locked, even with a valid code
+		challenge, err := s.Application.MFAService().StartChallenge(s.Ctx, identity.ID, "https://openshift.io/home", "")
+		require.NoError(t, err)
+		code, err := mfa.GenerateTOTPCode(secret, mfa.TOTPStep(time.Now())+1)
+		require.NoError(t, err)
+		_, err = s.Application.MFAService().VerifyChallenge(s.Ctx, challenge.ChallengeID, code)
+		assert.IsType(t, errors.TooManyRequestsError{}, errs.Cause(err))
+		// and unlocked once the lockout period is over
+		credential, err := s.Application.TOTPCredentials().Load(s.Ctx, identity.ID)
+		require.NoError(t, err)
+		require.NotNil(t, credential.LockedUntil)
+		lockedUntil := time.Now().Add(-time.Second)
+		credential.LockedUntil = &lockedUntil
+		require.NoError(t, s.Application.TOTPCredentials().Save(s.Ctx, credential))
+		_, err = s.Application.MFAService().VerifyChallenge(s.Ctx, challenge.ChallengeID, code)
+		require.NoError(t, err)
+		credential, err = s.Application.TOTPCredentials().Load(s.Ctx, identity.ID)
+		require.NoError(t, err)
+		assert.Nil(t, credential.LockedUntil)
+		assert.Equal(t, 0, credential.FailedAttempts)
+	})
+
+	s.T().Run("expired challenge", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		secret, _ := s.enroll(identity.ID)
+		challenge, err := s.Application.MFAService().StartChallenge(s.Ctx, identity.ID, "https://openshift.io/home", "")
+		require.NoError(t, err)
+		challenge.ExpiresAt = time.Now().Add(-time.Minute)
+		require.NoError(t, s.Application.MFAChallenges().Save(s.Ctx, challenge))
+		code, err := mfa.GenerateTOTPCode(secret, mfa.TOTPStep(time.Now())+1)
+		require.NoError(t, err)
+		// when
+		_, err = s.Application.MFAService().VerifyChallenge(s.Ctx, challenge.ChallengeID, code)
+		// then
+		assert.IsType(t, errors.UnauthorizedError{}, errs.Cause(err))
+	})
+
+	s.T().Run("unconfirmed enrollment", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		secret, _, err := s.Application.MFAService().EnrollTOTP(s.Ctx, identity.ID)
+		require.NoError(t, err)
+		challenge, err := s.Application.MFAService().StartChallenge(s.Ctx, identity.ID, "https://openshift.io/home", "")
+		require.NoError(t, err)
+		code, err := mfa.GenerateTOTPCode(secret, mfa.TOTPStep(time.Now()))
+		require.NoError(t, err)
+		// when
+		_, err = s.Application.MFAService().VerifyChallenge(s.Ctx, challenge.ChallengeID, code)
+		// then
+		assert.IsType(t, errors.UnauthorizedError{}, errs.Cause(err))
+	})
+
+	s.T().Run("concurrent replay", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		secret, _ := s.enroll(identity.ID)
+		code, err := mfa.GenerateTOTPCode(secret, mfa.TOTPStep(time.Now())+1)
+		require.NoError(t, err)
+		challenges := make([]uuid.UUID, 5)
+		for i := range challenges {
+			challenge, err := s.Application.MFAService().StartChallenge(s.Ctx, identity.ID, "https://openshift.io/home", "")
+			require.NoError(t, err)
+			challenges[i] = challenge.ChallengeID
+		}
+		// when
+		results := make(chan error, len(challenges))
+		var wg sync.WaitGroup
+		for _, challengeID := range challenges {
+			wg.Add(1)
+			go func(challengeID uuid.UUID) {
+				defer wg.Done()
+				_, err := s.Application.MFAService().VerifyChallenge(s.Ctx, challengeID, code)
+				results <- err
+			}(challengeID)
+		}
+		wg.Wait()
+		close(results)
+		// then the code is only accepted once
+		verified := 0
+		for err := range results {
+			if err == nil {
+				verified++
+			} else {
+				assert.IsType(t, errors.UnauthorizedError{}, errs.Cause(err))
+			}
+		}
+		assert.Equal(t, 1, verified)
+	})
+
+	s.T().Run("unknown challenge", func(t *testing.T) {
+		// when
+		_, err := s.Application.MFAService().VerifyChallenge(s.Ctx, uuid.NewV4(), "123456")
+		// then
+		assert.IsType(t, errors.UnauthorizedError{}, errs.Cause(err))
+	})
+}
@@ -0,0 +1,358 @@
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application/service"
+	"github.com/fabric8-services/fabric8-auth/application/service/base"
+	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa/repository"
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa/webauthn"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// WebAuthnServiceConfiguration the required configuration for the WebAuthn service implementation
+type WebAuthnServiceConfiguration interface {
+	GetWebAuthnRPID() string
+	GetWebAuthnRPName() string
+	GetWebAuthnOrigins() []string
+	GetMFAChallengeExpiresIn() time.Duration
+	GetMFAChallengeMaxAttempts() int
+}
+
+type webAuthnServiceImpl struct {
+	base.BaseService
+	config WebAuthnServiceConfiguration
+}
+
+// NewWebAuthnService creates a new WebAuthn service
+func NewWebAuthnService(context servicecontext.ServiceContext, config WebAuthnServiceConfiguration) service.WebAuthnService {
+	return &webAuthnServiceImpl{
+		BaseService: base.NewBaseService(context),
+		config:      config,
+	}
+}
+
+func (s *webAuthnServiceImpl) relyingParty() webauthn.RelyingParty {
+	return webauthn.RelyingParty{
+		ID:      s.config.GetWebAuthnRPID(),
+		Origins: s.config.GetWebAuthnOrigins(),
+	}
+}
+
+// BeginRegistration starts the registration of a new WebAuthn credential for the user of the given identity, and
+// returns the options of the ceremony. Any previous pending registration of the user is replaced.
+func (s *webAuthnServiceImpl) BeginRegistration(ctx context.Context, identityID uuid.UUID) (*webauthn.CreationOptions, error) {
+	var options *webauthn.CreationOptions
+	err := s.ExecuteInTransaction(func() error {
+		identity, err := s.Repositories().Identities().LoadWithUser(ctx, identityID)
+		if err != nil {
+			return err
+		}
+		challenge, err := webauthn.NewChallenge()
+		if err != nil {
+			return errors.NewInternalError(err)
+		}
+		credentials, err := s.Repositories().WebAuthnCredentials().ListForUser(ctx, identity.User.ID)
+		if err != nil {
+			return errors.NewInternalError(err)
+		}
+		excluded := make([][]byte, 0, len(credentials))
+		for _, credential := range credentials {
+			id, err := webauthn.DecodeBase64URL(credential.CredentialID)
+			if err != nil {
+				return errors.NewInternalError(err)
+			}
+			excluded = append(excluded, id)
+		}
+		err = s.Repositories().WebAuthnRegistrations().Create(ctx, &repository.WebAuthnRegistration{
+			UserID:    identity.User.ID,
+			Challenge: webauthn.EncodeBase64URL(challenge),
+			ExpiresAt: time.Now().Add(s.config.GetMFAChallengeExpiresIn()),
+		})
+		if err != nil {
+			return errors.NewInternalError(err)
+		}
+		options = &webauthn.CreationOptions{
+			Challenge:          challenge,
+			RPID:               s.config.GetWebAuthnRPID(),
+			RPName:             s.config.GetWebAuthnRPName(),
+			UserID:             identity.User.ID.Bytes(),
+			UserName:           identity.Username,
+			UserDisplayName:    identity.User.FullName,
+			Algorithms:         webauthn.SupportedAlgorithms,
+			ExcludeCredentials: excluded,
+			Timeout:            s.config.GetMFAChallengeExpiresIn(),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+// FinishRegistration verifies the response of the authenticator to the pending registration of the user of the given
+// identity, and stores the new credential under the given name. Returns a BadParameterError if there is no pending
+// registration or if the response is invalid.
+func (s *webAuthnServiceImpl) FinishRegistration(ctx context.Context, identityID uuid.UUID, name string, response webauthn.AttestationResponse) (*repository.WebAuthnCredential, error) {
+	var credential *repository.WebAuthnCredential
+	var verificationErr error
+	// the registration is removed even if the response is invalid, so the transaction is not rolled back in that case
+	err := s.ExecuteInTransaction(func() error {
+		identity, err := s.Repositories().Identities().Load(ctx, identityID)
+		if err != nil {
+			return err
+		}
+		userID := identity.UserID.UUID
+		registration, err := s.Repositories().WebAuthnRegistrations().Load(ctx, userID)
+		if err != nil {
+			if notFound, _ := errors.IsNotFoundError(err); notFound {
+				return errors.NewBadParameterErrorFromString("registration", "", "no pending WebAuthn registration")
+			}
+			return errors.NewInternalError(err)
+		}
+		// a registration challenge can only be used once
+		err = s.Repositories().WebAuthnRegistrations().Delete(ctx, userID)
+		if err != nil {
+			return errors.NewInternalError(err)
+		}
+		if registration.ExpiresAt.Before(time.Now()) {
+			verificationErr = errors.NewBadParameterErrorFromString("registration", "", "the WebAuthn registration has expired")
+			return nil
+		}
+		challenge, err := webauthn.DecodeBase64URL(registration.Challenge)
+		if err != nil {
+			return errors.NewInternalError(err)
+		}
+		created, err := s.relyingParty().VerifyRegistration(challenge, response.ClientDataJSON, response.AttestationObject)
+		if err != nil {
+			log.Warn(ctx, map[string]interface{}{
+				"identity_id": identityID,
+				"err":         err,
+			}, "invalid WebAuthn registration")
+			verificationErr = errors.NewBadParameterErrorFromString("attestation", "", err.Error())
+			return nil
+		}
+		credential = &repository.WebAuthnCredential{
+			UserID:       userID,
+			CredentialID: webauthn.EncodeBase64URL(created.ID),
+			Name:         name,
+			PublicKey:    created.PublicKey,
+			SignCount:    int64(created.SignCount),
+			AAGUID:       hex.EncodeToString(created.AAGUID),
+		}
+		return s.Repositories().WebAuthnCredentials().Create(ctx, credential)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if verificationErr != nil {
+		return nil, verificationErr
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identity_id":            identityID,
+		"webauthn_credential_id": credential.WebAuthnCredentialID,
+	}, "WebAuthn credential registered")
+	return credential, nil
+}
+
+// ListCredentials returns the WebAuthn credentials of the user of the given identity
+func (s *webAuthnServiceImpl) ListCredentials(ctx context.Context, identityID uuid.UUID) ([]repository.WebAuthnCredential, error) {
+	identity, err := s.Repositories().Identities().Load(ctx, identityID)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repositories().WebAuthnCredentials().ListForUser(ctx, identity.UserID.UUID)
+}
+
+// DeleteCredential removes the given WebAuthn credential of the user of the given identity. Returns a NotFoundError
+// if the credential does not exist or belongs to another user.
+func (s *webAuthnServiceImpl) DeleteCredential(ctx context.Context, identityID uuid.UUID, credentialID uuid.UUID) error {
+	err := s.ExecuteInTransaction(func() error {
+		identity, err := s.Repositories().Identities().Load(ctx, identityID)
+		if err != nil {
+			return err
+		}
+		credential, err := s.Repositories().WebAuthnCredentials().Load(ctx, credentialID)
+		if err != nil {
+			return err
+		}
+		if credential.UserID != identity.UserID.UUID {
+			return errors.NewNotFoundError("webauthn_credential", credentialID.String())
+		}
+		return s.Repositories().WebAuthnCredentials().Delete(ctx, credentialID)
+	})
+	if err != nil {
+		return err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identity_id":            identityID,
+		"webauthn_credential_id": credentialID,
+	}, "WebAuthn credential deleted")
+	return nil
+}
+
+// BeginAssertion starts a WebAuthn assertion ceremony for the given login challenge, and returns the options of the
+// ceremony. Returns an UnauthorizedError if the challenge does not exist or has expired, or if the user has no
+// WebAuthn credential.
+func (s *webAuthnServiceImpl) BeginAssertion(ctx context.Context, challengeID uuid.UUID) (*webauthn.RequestOptions, error) {
+	var options *webauthn.RequestOptions
+	err := s.ExecuteInTransaction(func() error {
+		challenge, err := s.loadChallenge(ctx, challengeID)
+		if err != nil {
+			return err
+		}
+		identity, err := s.Repositories().Identities().Load(ctx, challenge.IdentityID)
+		if err != nil {
+			return errors.NewInternalError(err)
+		}
+		credentials, err := s.Repositories().WebAuthnCredentials().ListForUser(ctx, identity.UserID.UUID)
+		if err != nil {
+			return errors.NewInternalError(err)
+		}
+		if len(credentials) == 0 {
+			return errors.NewUnauthorizedError("no WebAuthn credential registered")
+		}
+		allowed := make([][]byte, 0, len(credentials))
+		for _, credential := range credentials {
+			id, err := webauthn.DecodeBase64URL(credential.CredentialID)
+			if err != nil {
+				return errors.NewInternalError(err)
+			}
+			allowed = append(allowed, id)
+		}
+		assertionChallenge, err := webauthn.NewChallenge()
+		if err != nil {
+			return errors.NewInternalError(err)
+		}
+		challenge.WebAuthnChallenge = webauthn.EncodeBase64URL(assertionChallenge)
+		err = s.Repositories().MFAChallenges().Save(ctx, challenge)
+		if err != nil {
+			return errors.NewInternalError(err)
+		}
+		options = &webauthn.RequestOptions{
+			Challenge:        assertionChallenge,
+			RPID:             s.config.GetWebAuthnRPID(),
+			AllowCredentials: allowed,
+			Timeout:          challenge.ExpiresAt.Sub(time.Now()),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+// FinishAssertion verifies the response of the authenticator to the assertion ceremony of the given login challenge.
+// The login challenge is removed once it has been verified, or after too many invalid responses. Returns an
+// UnauthorizedError if the challenge does not exist, has expired or if the response is invalid.
+func (s *webAuthnServiceImpl) FinishAssertion(ctx context.Context, challengeID uuid.UUID, response webauthn.AssertionResponse) (*repository.MFAChallenge, error) {
+	var challenge *repository.MFAChallenge
+	verified := false
+	// failed attempts must be recorded, so the transaction is not rolled back when the response is invalid
+	err := s.ExecuteInTransaction(func() error {
+		var err error
+		challenge, err = s.loadChallenge(ctx, challengeID)
+		if err != nil {
+			return err
+		}
+		verified, err = s.verifyAssertion(ctx, challenge, response)
+		if err != nil {
+			return err
+		}
+		if verified {
+			return s.Repositories().MFAChallenges().Delete(ctx, challengeID)
+		}
+		challenge.Attempts++
+		if challenge.Attempts >= s.config.GetMFAChallengeMaxAttempts() {
+			log.Warn(ctx, map[string]interface{}{
+				"identity_id":  challenge.IdentityID,
+				"challenge_id": challengeID,
+				"attempts":     challenge.Attempts,
+			}, "too many invalid WebAuthn assertions, aborting login")
+			return s.Repositories().MFAChallenges().Delete(ctx, challengeID)
+		}
+		// the assertion challenge can only be used once
+		challenge.WebAuthnChallenge = ""
+		return s.Repositories().MFAChallenges().Save(ctx, challenge)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !verified {
+		return nil, errors.NewUnauthorizedError("invalid WebAuthn assertion")
+	}
+	return challenge, nil
+}
+
+// loadChallenge loads the given login challenge, which must not have expired
+func (s *webAuthnServiceImpl) loadChallenge(ctx context.Context, challengeID uuid.UUID) (*repository.MFAChallenge, error) {
+	challenge, err := s.Repositories().MFAChallenges().Load(ctx, challengeID)
+	if err != nil {
+		if notFound, _ := errors.IsNotFoundError(err); notFound {
+			return nil, errors.NewUnauthorizedError("invalid or expired multi-factor authentication challenge")
+		}
+		return nil, errors.NewInternalError(err)
+	}
+	if challenge.ExpiresAt.Before(time.Now()) {
+		return nil, errors.NewUnauthorizedError("invalid or expired multi-factor authentication challenge")
+	}
+	return challenge, nil
+}
+
+// verifyAssertion checks the given assertion against the assertion challenge of the given login challenge and the
+// credential it was made with, and updates the signature counter of the credential
+func (s *webAuthnServiceImpl) verifyAssertion(ctx context.Context, challenge *repository.MFAChallenge, response webauthn.AssertionResponse) (bool, error) {
+	if challenge.WebAuthnChallenge == "" {
+		return false, nil
+	}
+	assertionChallenge, err := webauthn.DecodeBase64URL(challenge.WebAuthnChallenge)
+	if err != nil {
+		return false, errors.NewInternalError(err)
+	}
+	identity, err := s.Repositories().Identities().Load(ctx, challenge.IdentityID)
+	if err != nil {
+		return false, errors.NewInternalError(err)
+	}
+	credential, err := s.Repositories().WebAuthnCredentials().LoadByCredentialID(ctx, webauthn.EncodeBase64URL(response.CredentialID))
+	if err != nil {
+		if notFound, _ := errors.IsNotFoundError(err); notFound {
+			return false, nil
+		}
+		return false, errors.NewInternalError(err)
+	}
+	if credential.UserID != identity.UserID.UUID {
+		log.Warn(ctx, map[string]interface{}{
+			"identity_id":            challenge.IdentityID,
+			"webauthn_credential_id": credential.WebAuthnCredentialID,
+		}, "WebAuthn assertion made with the credential of another user")
+		return false, nil
+	}
+	signCount, err := s.relyingParty().VerifyAssertion(assertionChallenge, webauthn.Credential{
+		PublicKey: credential.PublicKey,
+		SignCount: uint32(credential.SignCount),
+	}, response.ClientDataJSON, response.AuthenticatorData, response.Signature)
+	if err != nil {
+		log.Warn(ctx, map[string]interface{}{
+			"identity_id":            challenge.IdentityID,
+			"webauthn_credential_id": credential.WebAuthnCredentialID,
+			"err":                    err,
+		}, "invalid WebAuthn assertion")
+		return false, nil
+	}
+	now := time.Now()
+	credential.SignCount = int64(signCount)
+	credential.LastUsedAt = &now
+	err = s.Repositories().WebAuthnCredentials().Save(ctx, credential)
+	if err != nil {
+		return false, errors.NewInternalError(err)
+	}
+	return true, nil
+}
@@ -0,0 +1,227 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa/repository"
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa/webauthn"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	testwebauthn "github.com/fabric8-services/fabric8-auth/test/webauthn"
+
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestWebAuthnService(t *testing.T) {
+	suite.Run(t, &webAuthnServiceBlackboxTestSuite{
+		DBTestSuite: gormtestsupport.NewDBTestSuite(),
+	})
+}
+
+type webAuthnServiceBlackboxTestSuite struct {
+	gormtestsupport.DBTestSuite
+}
+
+func (s *webAuthnServiceBlackboxTestSuite) origin() string {
+	return s.Configuration.GetWebAuthnOrigins()[0]
+}
+
+// register registers a new software authenticator for the given identity
+func (s *webAuthnServiceBlackboxTestSuite) register(t *testing.T, identityID uuid.UUID) (*testwebauthn.Authenticator, *repository.WebAuthnCredential) {
+	authenticator, err := testwebauthn.NewAuthenticator()
+	require.NoError(t, err)
+	options, err := s.Application.WebAuthnService().BeginRegistration(s.Ctx, identityID)
+	require.NoError(t, err)
+	clientData, attestationObject, err := authenticator.Register(options.RPID, s.origin(), options.Challenge)
+	require.NoError(t, err)
+	credential, err := s.Application.WebAuthnService().FinishRegistration(s.Ctx, identityID, "my key", webauthn.AttestationResponse{
+		ClientDataJSON:    clientData,
+		AttestationObject: attestationObject,
+	})
+	require.NoError(t, err)
+	return authenticator, credential
+}
+
+// assert runs the assertion ceremony of the given login challenge with the given authenticator
+func (s *webAuthnServiceBlackboxTestSuite) assert(t *testing.T, authenticator *testwebauthn.Authenticator, challengeID uuid.UUID) webauthn.AssertionResponse {
+	options, err := s.Application.WebAuthnService().BeginAssertion(s.Ctx, challengeID)
+	require.NoError(t, err)
+	clientData, authData, signature, err := authenticator.Assert(options.RPID, s.origin(), options.Challenge)
+	require.NoError(t, err)
+	return webauthn.AssertionResponse{
+		CredentialID:      authenticator.CredentialID,
+		ClientDataJSON:    clientData,
+		AuthenticatorData: authData,
+		Signature:         signature,
+	}
+}
+
+func (s *webAuthnServiceBlackboxTestSuite) TestRegistration() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		// when
+		authenticator, credential := s.register(t, user.IdentityID())
+		// then
+		assert.Equal(t, user.User().ID, credential.UserID)
+		assert.Equal(t, webauthn.EncodeBase64URL(authenticator.CredentialID), credential.CredentialID)
+		assert.Equal(t, "my key", credential.Name)
+		credentials, err := s.Application.WebAuthnService().ListCredentials(s.Ctx, user.IdentityID())
+		require.NoError(t, err)
+		require.Len(t, credentials, 1)
+		assert.Equal(t, credential.WebAuthnCredentialID, credentials[0].WebAuthnCredentialID)
+		// a second factor is now required during login
+		enrolled, err := s.Application.MFAService().IsEnrolled(s.Ctx, user.IdentityID())
+		require.NoError(t, err)
+		assert.True(t, enrolled)
+		// and the credential is excluded from the next registrations
+		options, err := s.Application.WebAuthnService().BeginRegistration(s.Ctx, user.IdentityID())
+		require.NoError(t, err)
+		assert.Equal(t, [][]byte{authenticator.CredentialID}, options.ExcludeCredentials)
+	})
+
+	s.T().Run("no pending registration", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		// when
+		_, err := s.Application.WebAuthnService().FinishRegistration(s.Ctx, user.IdentityID(), "my key", webauthn.AttestationResponse{})
+		// then
+		assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+	})
+
+	s.T().Run("invalid response", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		authenticator, err := testwebauthn.NewAuthenticator()
+		require.NoError(t, err)
+		options, err := s.Application.WebAuthnService().BeginRegistration(s.Ctx, user.IdentityID())
+		require.NoError(t, err)
+		clientData, attestationObject, err := authenticator.Register(options.RPID, "https://evil.io", options.Challenge)
+		require.NoError(t, err)
+		response := webauthn.AttestationResponse{
+			ClientDataJSON:    clientData,
+			AttestationObject: attestationObject,
+		}
+		// when
+		_, err = s.Application.WebAuthnService().FinishRegistration(s.Ctx, user.IdentityID(), "my key", response)
+		// then
+		assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		// and the registration can not be retried
+		_, err = s.Application.WebAuthnRegistrations().Load(s.Ctx, user.User().ID)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}
+
+func (s *webAuthnServiceBlackboxTestSuite) TestDeleteCredential() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		_, credential := s.register(t, user.IdentityID())
+		// when
+		err := s.Application.WebAuthnService().DeleteCredential(s.Ctx, user.IdentityID(), credential.WebAuthnCredentialID)
+		// then
+		require.NoError(t, err)
+		credentials, err := s.Application.WebAuthnService().ListCredentials(s.Ctx, user.IdentityID())
+		require.NoError(t, err)
+		assert.Empty(t, credentials)
+	})
+
+	s.T().Run("credential of another user", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		_, credential := s.register(t, user.IdentityID())
+		other := s.Graph.CreateUser()
+		// when
+		err := s.Application.WebAuthnService().DeleteCredential(s.Ctx, other.IdentityID(), credential.WebAuthnCredentialID)
+		// then
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+		_, err = s.Application.WebAuthnCredentials().Load(s.Ctx, credential.WebAuthnCredentialID)
+		require.NoError(t, err)
+	})
+}
+
+func (s *webAuthnServiceBlackboxTestSuite) TestAssertion() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		authenticator, credential := s.register(t, user.IdentityID())
+		challenge, err := s.Application.MFAService().StartChallenge(s.Ctx, user.IdentityID(), "https://openshift.io/home", "")
+		require.NoError(t, err)
+		response := s.assert(t, authenticator, challenge.ChallengeID)
+		// when
+		verified, err := s.Application.WebAuthnService().FinishAssertion(s.Ctx, challenge.ChallengeID, response)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, user.IdentityID(), verified.IdentityID)
+		// the challenge can only be verified once
+		_, err = s.Application.MFAChallenges().Load(s.Ctx, challenge.ChallengeID)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+		// and the signature counter was updated
+		loaded, err := s.Application.WebAuthnCredentials().Load(s.Ctx, credential.WebAuthnCredentialID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(authenticator.SignCount), loaded.SignCount)
+		assert.NotNil(t, loaded.LastUsedAt)
+	})
+
+	s.T().Run("replayed assertion", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		authenticator, _ := s.register(t, user.IdentityID())
+		challenge, err := s.Application.MFAService().StartChallenge(s.Ctx, user.IdentityID(), "https://openshift.io/home", "")
+		require.NoError(t, err)
+		response := s.assert(t, authenticator, challenge.ChallengeID)
+		_, err = s.Application.WebAuthnService().FinishAssertion(s.Ctx, challenge.ChallengeID, response)
+		require.NoError(t, err)
+		other, err := s.Application.MFAService().StartChallenge(s.Ctx, user.IdentityID(), "https://openshift.io/home", "")
+		require.NoError(t, err)
+		_, err = s.Application.WebAuthnService().BeginAssertion(s.Ctx, other.ChallengeID)
+		require.NoError(t, err)
+		// when
+		_, err = s.Application.WebAuthnService().FinishAssertion(s.Ctx, other.ChallengeID, response)
+		// then
+		assert.IsType(t, errors.UnauthorizedError{}, errs.Cause(err))
+		loaded, err := s.Application.MFAChallenges().Load(s.Ctx, other.ChallengeID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, loaded.Attempts)
+	})
+
+	s.T().Run("credential of another user", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		s.register(t, user.IdentityID())
+		otherUser := s.Graph.CreateUser()
+		otherAuthenticator, _ := s.register(t, otherUser.IdentityID())
+		challenge, err := s.Application.MFAService().StartChallenge(s.Ctx, user.IdentityID(), "https://openshift.io/home", "")
+		require.NoError(t, err)
+		response := s.assert(t, otherAuthenticator, challenge.ChallengeID)
+		// when
+		_, err = s.Application.WebAuthnService().FinishAssertion(s.Ctx, challenge.ChallengeID, response)
+		// then
+		assert.IsType(t, errors.UnauthorizedError{}, errs.Cause(err))
+	})
+
+	s.T().Run("no credential", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		challenge, err := s.Application.MFAService().StartChallenge(s.Ctx, user.IdentityID(), "https://openshift.io/home", "")
+		require.NoError(t, err)
+		// when
+		_, err = s.Application.WebAuthnService().BeginAssertion(s.Ctx, challenge.ChallengeID)
+		// then
+		assert.IsType(t, errors.UnauthorizedError{}, errs.Cause(err))
+	})
+
+	s.T().Run("unknown challenge", func(t *testing.T) {
+		// when
+		_, err := s.Application.WebAuthnService().BeginAssertion(s.Ctx, uuid.NewV4())
+		// then
+		assert.IsType(t, errors.UnauthorizedError{}, errs.Cause(err))
+	})
+}
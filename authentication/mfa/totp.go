@@ -0,0 +1,132 @@
+package mfa
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	errs "github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// TOTPPeriod the number of seconds during which a TOTP code is valid, as recommended by RFC 6238
+	TOTPPeriod = 30
+	// TOTPDigits the number of digits of a TOTP code
+	TOTPDigits = 6
+	// totpSkew the number of periods before and after the current one during which a code is still accepted,
+	// to account for clock drifts between the server and the user's device
+	totpSkew = 1
+	// secretLength the length (in bytes) of the generated TOTP secrets, as recommended by RFC 4226
+	secretLength = 20
+	// recoveryCodeLength the length (in bytes) of the generated recovery codes
+	recoveryCodeLength = 10
+)
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret generates a new random TOTP secret, encoded in base32 (without padding) as expected by the
+// authenticator applications
+func GenerateTOTPSecret() (string, error) {
+	secret := make([]byte, secretLength)
+	if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+		return "", errs.Wrap(err, "unable to generate TOTP secret")
+	}
+	return base32NoPadding.EncodeToString(secret), nil
+}
+
+// TOTPKeyURI returns the `otpauth://` URI of the given secret, which can be rendered as a QR code
+// and scanned by the authenticator applications
+func TOTPKeyURI(issuer, accountName, secret string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprintf("%d", TOTPDigits))
+	params.Set("period", fmt.Sprintf("%d", TOTPPeriod))
+	label := url.PathEscape(issuer + ":" + accountName)
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, params.Encode())
+}
+
+// TOTPStep returns the TOTP time step of the given time
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / TOTPPeriod
+}
+
+// GenerateTOTPCode generates the TOTP code of the given secret for the given time step (RFC 6238)
+func GenerateTOTPCode(secret string, step int64) (string, error) {
+	key, err := base32NoPadding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", errs.Wrap(err, "invalid TOTP secret")
+	}
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+	// dynamic truncation, as defined in RFC 4226
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	modulo := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%modulo), nil
+}
+
+// ValidateTOTPCode checks the given code against the given secret at the given time. Codes of the adjacent time steps
+// are also accepted, but codes whose time step is not after the `lastUsedStep` are rejected to prevent replays.
+// Returns the time step of the matching code, or false if the code is invalid
+func ValidateTOTPCode(secret string, code string, t time.Time, lastUsedStep int64) (int64, bool, error) {
+	code = strings.TrimSpace(code)
+	if len(code) != TOTPDigits {
+		return 0, false, nil
+	}
+	current := TOTPStep(t)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastUsedStep {
+			continue
+		}
+		expected, err := GenerateTOTPCode(secret, step)
+		if err != nil {
+			return 0, false, err
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// GenerateRecoveryCodes generates the given number of random recovery codes, along with their bcrypt hash.
+func GenerateRecoveryCodes(count int) ([]string, []string, error) {
+	codes := make([]string, count)
+	hashes := make([]string, count)
+	for i := 0; i < count; i++ {
+		data := make([]byte, recoveryCodeLength)
+		if _, err := io.ReadFull(rand.Reader, data); err != nil {
+			return nil, nil, errs.Wrap(err, "unable to generate recovery code")
+		}
+		code := strings.ToLower(base32NoPadding.EncodeToString(data))
+		// format the code as `xxxx-xxxx-xxxx-xxxx` to make it easier to copy by hand
+		codes[i] = fmt.Sprintf("%s-%s-%s-%s", code[0:4], code[4:8], code[8:12], code[12:16])
+		hash, err := bcrypt.GenerateFromPassword([]byte(codes[i]), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, nil, errs.Wrap(err, "unable to hash recovery code")
+		}
+		hashes[i] = string(hash)
+	}
+	return codes, hashes, nil
+}
+
+// MatchRecoveryCode returns true if the given code matches the given bcrypt hash
+func MatchRecoveryCode(hash string, code string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(strings.ToLower(strings.TrimSpace(code)))) == nil
+}
@@ -0,0 +1,98 @@
+package mfa_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret the base32 encoding of the "12345678901234567890" secret used in the test vectors of RFC 6238
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGenerateTOTPCode(t *testing.T) {
+	// 6-digit truncations of the SHA1 test vectors of RFC 6238
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, expected := range vectors {
+		code, err := mfa.GenerateTOTPCode(rfcSecret, mfa.TOTPStep(time.Unix(unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, expected, code, "time %d", unix)
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	currentStep := mfa.TOTPStep(now)
+
+	t.Run("current code", func(t *testing.T) {
+		step, valid, err := mfa.ValidateTOTPCode(rfcSecret, "005924", now, 0)
+		require.NoError(t, err)
+		assert.True(t, valid)
+		assert.Equal(t, currentStep, step)
+	})
+
+	t.Run("previous code within skew", func(t *testing.T) {
+		code, err := mfa.GenerateTOTPCode(rfcSecret, currentStep-1)
+		require.NoError(t, err)
+		step, valid, err := mfa.ValidateTOTPCode(rfcSecret, code, now, 0)
+		require.NoError(t, err)
+		assert.True(t, valid)
+		assert.Equal(t, currentStep-1, step)
+	})
+
+	t.Run("code outside skew", func(t *testing.T) {
+		code, err := mfa.GenerateTOTPCode(rfcSecret, currentStep-5)
+		require.NoError(t, err)
+		_, valid, err := mfa.ValidateTOTPCode(rfcSecret, code, now, 0)
+		require.NoError(t, err)
+		assert.False(t, valid)
+	})
+
+	t.Run("replayed code", func(t *testing.T) {
+		_, valid, err := mfa.ValidateTOTPCode(rfcSecret, "005924", now, currentStep)
+		require.NoError(t, err)
+		assert.False(t, valid)
+	})
+
+	t.Run("invalid code", func(t *testing.T) {
+		_, valid, err := mfa.ValidateTOTPCode(rfcSecret, "12345", now, 0)
+		require.NoError(t, err)
+		assert.False(t, valid)
+	})
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := mfa.GenerateTOTPSecret()
+	require.NoError(t, err)
+	assert.Len(t, secret, 32)
+	other, err := mfa.GenerateTOTPSecret()
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, other)
+	uri := mfa.TOTPKeyURI("OpenShift.io", "jdoe", secret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/OpenShift.io:jdoe?"))
+	assert.Contains(t, uri, "secret="+secret)
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := mfa.GenerateRecoveryCodes(3)
+	require.NoError(t, err)
+	require.Len(t, codes, 3)
+	require.Len(t, hashes, 3)
+	for i, code := range codes {
+		assert.Len(t, code, 19)
+		assert.NotContains(t, hashes[i], code)
+		assert.True(t, mfa.MatchRecoveryCode(hashes[i], code))
+		assert.True(t, mfa.MatchRecoveryCode(hashes[i], " "+strings.ToUpper(code)+" "))
+	}
+	assert.False(t, mfa.MatchRecoveryCode(hashes[0], codes[1]))
+}
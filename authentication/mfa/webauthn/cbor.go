@@ -0,0 +1,125 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"math"
+
+	errs "github.com/pkg/errors"
+)
+
+// maxCBORDepth the maximum nesting of the CBOR items accepted by the decoder
+const maxCBORDepth = 16
+
+// decodeCBOR decodes the first CBOR item (RFC 7049) of the given data, and returns it along with the remaining bytes.
+// Only the subset of CBOR used by the WebAuthn attestation objects and COSE keys is supported: unsigned and negative
+// integers are returned as int64, byte strings as []byte, text strings as string, arrays as []interface{}, maps as
+// map[interface{}]interface{}, and the simple values as bool or nil. Indefinite lengths, tags and floats are rejected.
+func decodeCBOR(data []byte) (interface{}, []byte, error) {
+	return decodeCBORItem(data, 0)
+}
+
+func decodeCBORItem(data []byte, depth int) (interface{}, []byte, error) {
+	if depth > maxCBORDepth {
+		return nil, nil, errs.New("CBOR data is too deeply nested")
+	}
+	if len(data) == 0 {
+		return nil, nil, errs.New("unexpected end of CBOR data")
+	}
+	majorType := data[0] >> 5
+	info := data[0] & 0x1f
+	data = data[1:]
+
+	if majorType == 7 {
+		switch info {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		case 22, 23:
+			return nil, data, nil
+		default:
+			return nil, nil, errs.Errorf("unsupported CBOR simple value %d", info)
+		}
+	}
+
+	var arg uint64
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info == 24 && len(data) >= 1:
+		arg, data = uint64(data[0]), data[1:]
+	case info == 25 && len(data) >= 2:
+		arg, data = uint64(binary.BigEndian.Uint16(data)), data[2:]
+	case info == 26 && len(data) >= 4:
+		arg, data = uint64(binary.BigEndian.Uint32(data)), data[4:]
+	case info == 27 && len(data) >= 8:
+		arg, data = binary.BigEndian.Uint64(data), data[8:]
+	case info > 27:
+		return nil, nil, errs.Errorf("unsupported CBOR additional information %d", info)
+	default:
+		return nil, nil, errs.New("unexpected end of CBOR data")
+	}
+
+	switch majorType {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, nil, errs.New("CBOR integer overflow")
+		}
+		return int64(arg), data, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, nil, errs.New("CBOR integer overflow")
+		}
+		return -1 - int64(arg), data, nil
+	case 2, 3:
+		if arg > uint64(len(data)) {
+			return nil, nil, errs.New("unexpected end of CBOR data")
+		}
+		if majorType == 2 {
+			return data[:arg], data[arg:], nil
+		}
+		return string(data[:arg]), data[arg:], nil
+	case 4:
+		// each item takes at least one byte
+		if arg > uint64(len(data)) {
+			return nil, nil, errs.New("unexpected end of CBOR data")
+		}
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			var item interface{}
+			var err error
+			item, data, err = decodeCBORItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, data, nil
+	case 5:
+		if arg > uint64(len(data))/2 {
+			return nil, nil, errs.New("unexpected end of CBOR data")
+		}
+		entries := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			var key, value interface{}
+			var err error
+			key, data, err = decodeCBORItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errs.Errorf("unsupported CBOR map key type %T", key)
+			}
+			value, data, err = decodeCBORItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			entries[key] = value
+		}
+		return entries, data, nil
+	default:
+		return nil, nil, errs.Errorf("unsupported CBOR major type %d", majorType)
+	}
+}
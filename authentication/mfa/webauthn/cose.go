@@ -0,0 +1,134 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"math/big"
+
+	errs "github.com/pkg/errors"
+)
+
+// COSE algorithms (RFC 8152) supported for the credential public keys
+const (
+	// AlgES256 ECDSA w/ SHA-256 on the P-256 curve
+	AlgES256 int64 = -7
+	// AlgRS256 RSASSA-PKCS1-v1_5 w/ SHA-256
+	AlgRS256 int64 = -257
+)
+
+// SupportedAlgorithms the COSE algorithms accepted during registration, by order of preference
+var SupportedAlgorithms = []int64{AlgES256, AlgRS256}
+
+// COSE key parameters
+const (
+	coseKeyType   int64 = 1
+	coseAlgorithm int64 = 3
+	coseEC2Curve  int64 = -1
+	coseEC2X      int64 = -2
+	coseEC2Y      int64 = -3
+	coseRSAN      int64 = -1
+	coseRSAE      int64 = -2
+
+	coseKeyTypeEC2   int64 = 2
+	coseKeyTypeRSA   int64 = 3
+	coseCurveP256    int64 = 1
+	maxRSAKeyModulus       = 4096
+)
+
+// credentialPublicKey a credential public key decoded from its COSE_Key encoding
+type credentialPublicKey struct {
+	algorithm int64
+	key       crypto.PublicKey
+}
+
+// parseCredentialPublicKey decodes the given COSE_Key
+func parseCredentialPublicKey(coseKey []byte) (*credentialPublicKey, error) {
+	decoded, rest, err := decodeCBOR(coseKey)
+	if err != nil {
+		return nil, errs.Wrap(err, "invalid credential public key")
+	}
+	if len(rest) != 0 {
+		return nil, errs.New("invalid credential public key: trailing data")
+	}
+	params, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, errs.New("invalid credential public key: not a map")
+	}
+	keyType, _ := params[coseKeyType].(int64)
+	algorithm, _ := params[coseAlgorithm].(int64)
+
+	switch {
+	case keyType == coseKeyTypeEC2 && algorithm == AlgES256:
+		curve, _ := params[coseEC2Curve].(int64)
+		x, _ := params[coseEC2X].([]byte)
+		y, _ := params[coseEC2Y].([]byte)
+		if curve != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return nil, errs.New("invalid credential public key: unsupported EC2 key")
+		}
+		key := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, errs.New("invalid credential public key: point is not on the curve")
+		}
+		return &credentialPublicKey{algorithm: algorithm, key: key}, nil
+	case keyType == coseKeyTypeRSA && algorithm == AlgRS256:
+		n, _ := params[coseRSAN].([]byte)
+		e, _ := params[coseRSAE].([]byte)
+		if len(n) == 0 || len(n)*8 > maxRSAKeyModulus || len(e) == 0 || len(e) > 4 {
+			return nil, errs.New("invalid credential public key: unsupported RSA key")
+		}
+		key := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		return &credentialPublicKey{algorithm: algorithm, key: key}, nil
+	default:
+		return nil, errs.Errorf("unsupported credential public key: key type %d, algorithm %d", keyType, algorithm)
+	}
+}
+
+// verify checks the given signature of the given data
+func (k *credentialPublicKey) verify(data []byte, signature []byte) error {
+	return verifySignature(k.algorithm, k.key, data, signature)
+}
+
+// verifySignature checks the given signature of the given data, made with the given COSE algorithm
+func verifySignature(algorithm int64, key crypto.PublicKey, data []byte, signature []byte) error {
+	digest := sha256.Sum256(data)
+	switch algorithm {
+	case AlgES256:
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return errs.New("the public key does not match the ES256 algorithm")
+		}
+		var sig struct {
+			R, S *big.Int
+		}
+		rest, err := asn1.Unmarshal(signature, &sig)
+		if err != nil || len(rest) != 0 {
+			return errs.New("invalid ES256 signature encoding")
+		}
+		if !ecdsa.Verify(ecKey, digest[:], sig.R, sig.S) {
+			return errs.New("invalid signature")
+		}
+		return nil
+	case AlgRS256:
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errs.New("the public key does not match the RS256 algorithm")
+		}
+		if rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], signature) != nil {
+			return errs.New("invalid signature")
+		}
+		return nil
+	default:
+		return errs.Errorf("unsupported signature algorithm %d", algorithm)
+	}
+}
@@ -0,0 +1,3 @@
+// Package webauthn contains the verification of the WebAuthn (FIDO2) registration and assertion ceremonies, used to
+// authenticate the users with a security key or a platform authenticator as a second factor.
+package webauthn
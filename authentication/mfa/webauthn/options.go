@@ -0,0 +1,41 @@
+package webauthn
+
+import "time"
+
+// CreationOptions the options of a registration ceremony, to pass to `navigator.credentials.create()`
+type CreationOptions struct {
+	Challenge       []byte
+	RPID            string
+	RPName          string
+	UserID          []byte
+	UserName        string
+	UserDisplayName string
+	// Algorithms the COSE algorithms accepted for the credential public key, by order of preference
+	Algorithms []int64
+	// ExcludeCredentials the IDs of the credentials already registered by the user
+	ExcludeCredentials [][]byte
+	Timeout            time.Duration
+}
+
+// RequestOptions the options of an assertion ceremony, to pass to `navigator.credentials.get()`
+type RequestOptions struct {
+	Challenge []byte
+	RPID      string
+	// AllowCredentials the IDs of the credentials registered by the user
+	AllowCredentials [][]byte
+	Timeout          time.Duration
+}
+
+// AttestationResponse the response of an authenticator to a registration ceremony
+type AttestationResponse struct {
+	ClientDataJSON    []byte
+	AttestationObject []byte
+}
+
+// AssertionResponse the response of an authenticator to an assertion ceremony
+type AssertionResponse struct {
+	CredentialID      []byte
+	ClientDataJSON    []byte
+	AuthenticatorData []byte
+	Signature         []byte
+}
@@ -0,0 +1,293 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+
+	errs "github.com/pkg/errors"
+)
+
+const (
+	// challengeLength the number of random bytes of the ceremony challenges
+	challengeLength = 32
+
+	// ceremony types, as found in the client data
+	ceremonyCreate = "webauthn.create"
+	ceremonyGet    = "webauthn.get"
+
+	// authenticator data flags
+	flagUserPresent            = 0x01
+	flagUserVerified           = 0x04
+	flagAttestedCredentialData = 0x40
+	flagExtensionData          = 0x80
+
+	// attestation statement formats
+	attestationFormatNone   = "none"
+	attestationFormatPacked = "packed"
+)
+
+// RelyingParty this service, as seen by the WebAuthn authenticators
+type RelyingParty struct {
+	// ID the RP ID, i.e. the domain to which the credentials are scoped
+	ID string
+	// Origins the origins of the pages which are allowed to run the ceremonies
+	Origins []string
+}
+
+// Credential a public key credential created by an authenticator during a registration ceremony
+type Credential struct {
+	// ID the credential ID, chosen by the authenticator
+	ID []byte
+	// PublicKey the credential public key, in its COSE_Key encoding
+	PublicKey []byte
+	// SignCount the signature counter of the authenticator
+	SignCount uint32
+	// AAGUID the identifier of the authenticator model
+	AAGUID []byte
+}
+
+// NewChallenge returns a new random challenge for a registration or an assertion ceremony
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, challengeLength)
+	_, err := rand.Read(challenge)
+	if err != nil {
+		return nil, errs.Wrap(err, "unable to generate the WebAuthn challenge")
+	}
+	return challenge, nil
+}
+
+// EncodeBase64URL encodes the given bytes in the unpadded base64url encoding used by the WebAuthn API
+func EncodeBase64URL(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeBase64URL decodes the given base64url string, with or without padding
+func DecodeBase64URL(data string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(data, "="))
+}
+
+// VerifyRegistration verifies the response of an authenticator to a registration ceremony (`navigator.credentials.create()`)
+// initiated with the given challenge, and returns the created credential. Only the `none` and `packed` attestation
+// statement formats are supported. The attestation is verified but not used for trust decisions, i.e. any
+// authenticator model is accepted.
+func (rp RelyingParty) VerifyRegistration(challenge []byte, clientDataJSON []byte, attestationObject []byte) (*Credential, error) {
+	err := rp.verifyClientData(clientDataJSON, ceremonyCreate, challenge)
+	if err != nil {
+		return nil, err
+	}
+	decoded, rest, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, errs.Wrap(err, "invalid attestation object")
+	}
+	if len(rest) != 0 {
+		return nil, errs.New("invalid attestation object: trailing data")
+	}
+	attestation, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, errs.New("invalid attestation object: not a map")
+	}
+	format, _ := attestation["fmt"].(string)
+	statement, _ := attestation["attStmt"].(map[interface{}]interface{})
+	rawAuthData, _ := attestation["authData"].([]byte)
+	if statement == nil || rawAuthData == nil {
+		return nil, errs.New("invalid attestation object: missing attestation statement or authenticator data")
+	}
+
+	authData, err := rp.verifyAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if authData.flags&flagAttestedCredentialData == 0 || authData.credential == nil {
+		return nil, errs.New("invalid authenticator data: missing attested credential data")
+	}
+	publicKey, err := parseCredentialPublicKey(authData.credential.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, rawAuthData...), clientDataHash[:]...)
+	switch format {
+	case attestationFormatNone:
+		if len(statement) != 0 {
+			return nil, errs.New("invalid attestation statement: 'none' statement must be empty")
+		}
+	case attestationFormatPacked:
+		err = verifyPackedAttestation(statement, publicKey, signed)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errs.Errorf("unsupported attestation statement format '%s'", format)
+	}
+	return authData.credential, nil
+}
+
+// VerifyAssertion verifies the response of an authenticator to an assertion ceremony (`navigator.credentials.get()`)
+// initiated with the given challenge, for the given registered credential, and returns the new signature counter of the
+// credential. A signature counter which did not increase indicates that the authenticator may have been cloned, in
+// which case the assertion is rejected.
+func (rp RelyingParty) VerifyAssertion(challenge []byte, credential Credential, clientDataJSON []byte, authenticatorData []byte, signature []byte) (uint32, error) {
+	err := rp.verifyClientData(clientDataJSON, ceremonyGet, challenge)
+	if err != nil {
+		return 0, err
+	}
+	authData, err := rp.verifyAuthenticatorData(authenticatorData)
+	if err != nil {
+		return 0, err
+	}
+	publicKey, err := parseCredentialPublicKey(credential.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, authenticatorData...), clientDataHash[:]...)
+	err = publicKey.verify(signed, signature)
+	if err != nil {
+		return 0, errs.Wrap(err, "invalid assertion signature")
+	}
+	// authenticators which do not implement a signature counter always return 0
+	if (authData.signCount != 0 || credential.SignCount != 0) && authData.signCount <= credential.SignCount {
+		return 0, errs.Errorf("the signature counter did not increase (stored: %d, received: %d), the authenticator may have been cloned",
+			credential.SignCount, authData.signCount)
+	}
+	return authData.signCount, nil
+}
+
+// collectedClientData the client data collected by the browser during a ceremony
+type collectedClientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// verifyClientData checks that the given client data matches the given ceremony type and challenge, and one of the
+// origins of the relying party
+func (rp RelyingParty) verifyClientData(clientDataJSON []byte, ceremonyType string, challenge []byte) error {
+	var clientData collectedClientData
+	err := json.Unmarshal(clientDataJSON, &clientData)
+	if err != nil {
+		return errs.Wrap(err, "invalid client data")
+	}
+	if clientData.Type != ceremonyType {
+		return errs.Errorf("invalid client data: unexpected type '%s'", clientData.Type)
+	}
+	receivedChallenge, err := DecodeBase64URL(clientData.Challenge)
+	if err != nil || subtle.ConstantTimeCompare(receivedChallenge, challenge) != 1 {
+		return errs.New("invalid client data: challenge mismatch")
+	}
+	for _, origin := range rp.Origins {
+		if clientData.Origin == origin {
+			return nil
+		}
+	}
+	return errs.Errorf("invalid client data: unexpected origin '%s'", clientData.Origin)
+}
+
+// authenticatorData the data returned by an authenticator during a ceremony
+type authenticatorData struct {
+	rpIDHash   []byte
+	flags      byte
+	signCount  uint32
+	credential *Credential
+}
+
+// verifyAuthenticatorData parses the given authenticator data, and checks that it is scoped to the relying party and
+// that the user was present
+func (rp RelyingParty) verifyAuthenticatorData(data []byte) (*authenticatorData, error) {
+	authData, err := parseAuthenticatorData(data)
+	if err != nil {
+		return nil, err
+	}
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(authData.rpIDHash, rpIDHash[:]) {
+		return nil, errs.New("invalid authenticator data: RP ID mismatch")
+	}
+	if authData.flags&flagUserPresent == 0 {
+		return nil, errs.New("invalid authenticator data: user not present")
+	}
+	return authData, nil
+}
+
+// parseAuthenticatorData decodes the given authenticator data, including the attested credential data if any
+func parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, errs.New("invalid authenticator data: too short")
+	}
+	authData := &authenticatorData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+	if authData.flags&flagAttestedCredentialData != 0 {
+		if len(rest) < 18 {
+			return nil, errs.New("invalid authenticator data: truncated attested credential data")
+		}
+		aaguid := rest[:16]
+		credentialIDLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if len(rest) < credentialIDLength {
+			return nil, errs.New("invalid authenticator data: truncated credential ID")
+		}
+		credentialID := rest[:credentialIDLength]
+		rest = rest[credentialIDLength:]
+		// the COSE_Key is not length-prefixed, decoding it tells where it ends
+		_, afterKey, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, errs.Wrap(err, "invalid authenticator data: invalid credential public key")
+		}
+		authData.credential = &Credential{
+			ID:        append([]byte{}, credentialID...),
+			PublicKey: append([]byte{}, rest[:len(rest)-len(afterKey)]...),
+			SignCount: authData.signCount,
+			AAGUID:    append([]byte{}, aaguid...),
+		}
+		rest = afterKey
+	}
+	if authData.flags&flagExtensionData != 0 {
+		var err error
+		_, rest, err = decodeCBOR(rest)
+		if err != nil {
+			return nil, errs.Wrap(err, "invalid authenticator data: invalid extensions")
+		}
+	}
+	if len(rest) != 0 {
+		return nil, errs.New("invalid authenticator data: trailing data")
+	}
+	return authData, nil
+}
+
+// verifyPackedAttestation verifies a `packed` attestation statement, either self-signed with the credential key or
+// signed with the key of the attestation certificate
+func verifyPackedAttestation(statement map[interface{}]interface{}, publicKey *credentialPublicKey, signed []byte) error {
+	algorithm, _ := statement["alg"].(int64)
+	signature, _ := statement["sig"].([]byte)
+	if signature == nil {
+		return errs.New("invalid packed attestation statement: missing signature")
+	}
+	certificates, hasCertificates := statement["x5c"].([]interface{})
+	if !hasCertificates {
+		// self attestation
+		if algorithm != publicKey.algorithm {
+			return errs.New("invalid packed attestation statement: algorithm does not match the credential public key")
+		}
+		return errs.Wrap(publicKey.verify(signed, signature), "invalid packed attestation statement")
+	}
+	if len(certificates) == 0 {
+		return errs.New("invalid packed attestation statement: empty certificate chain")
+	}
+	rawCertificate, _ := certificates[0].([]byte)
+	certificate, err := x509.ParseCertificate(rawCertificate)
+	if err != nil {
+		return errs.Wrap(err, "invalid packed attestation statement: invalid attestation certificate")
+	}
+	return errs.Wrap(verifySignature(algorithm, certificate.PublicKey, signed, signature), "invalid packed attestation statement")
+}
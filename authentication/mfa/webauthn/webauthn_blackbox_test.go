@@ -0,0 +1,165 @@
+package webauthn_test
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa/webauthn"
+	testwebauthn "github.com/fabric8-services/fabric8-auth/test/webauthn"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	rpID   = "openshift.io"
+	origin = "https://openshift.io"
+)
+
+var rp = webauthn.RelyingParty{
+	ID:      rpID,
+	Origins: []string{origin},
+}
+
+func register(t *testing.T, authenticator *testwebauthn.Authenticator) *webauthn.Credential {
+	challenge, err := webauthn.NewChallenge()
+	require.NoError(t, err)
+	clientData, attestationObject, err := authenticator.Register(rpID, origin, challenge)
+	require.NoError(t, err)
+	credential, err := rp.VerifyRegistration(challenge, clientData, attestationObject)
+	require.NoError(t, err)
+	return credential
+}
+
+func TestVerifyRegistration(t *testing.T) {
+
+	t.Run("none attestation", func(t *testing.T) {
+		// given
+		authenticator, err := testwebauthn.NewAuthenticator()
+		require.NoError(t, err)
+		// when
+		credential := register(t, authenticator)
+		// then
+		assert.Equal(t, authenticator.CredentialID, credential.ID)
+		assert.Equal(t, authenticator.PublicKey(), credential.PublicKey)
+		assert.Equal(t, uint32(0), credential.SignCount)
+	})
+
+	t.Run("packed self attestation", func(t *testing.T) {
+		// given
+		authenticator, err := testwebauthn.NewAuthenticator()
+		require.NoError(t, err)
+		authenticator.Packed = true
+		// when
+		credential := register(t, authenticator)
+		// then
+		assert.Equal(t, authenticator.CredentialID, credential.ID)
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		authenticator, err := testwebauthn.NewAuthenticator()
+		require.NoError(t, err)
+		challenge, err := webauthn.NewChallenge()
+		require.NoError(t, err)
+
+		t.Run("other challenge", func(t *testing.T) {
+			clientData, attestationObject, err := authenticator.Register(rpID, origin, challenge)
+			require.NoError(t, err)
+			otherChallenge, err := webauthn.NewChallenge()
+			require.NoError(t, err)
+			_, err = rp.VerifyRegistration(otherChallenge, clientData, attestationObject)
+			assert.EqualError(t, err, "invalid client data: challenge mismatch")
+		})
+
+		t.Run("other origin", func(t *testing.T) {
+			clientData, attestationObject, err := authenticator.Register(rpID, "https://evil.io", challenge)
+			require.NoError(t, err)
+			_, err = rp.VerifyRegistration(challenge, clientData, attestationObject)
+			assert.EqualError(t, err, "invalid client data: unexpected origin 'https://evil.io'")
+		})
+
+		t.Run("other RP ID", func(t *testing.T) {
+			clientData, attestationObject, err := authenticator.Register("evil.io", origin, challenge)
+			require.NoError(t, err)
+			_, err = rp.VerifyRegistration(challenge, clientData, attestationObject)
+			assert.EqualError(t, err, "invalid authenticator data: RP ID mismatch")
+		})
+
+		t.Run("assertion client data", func(t *testing.T) {
+			_, attestationObject, err := authenticator.Register(rpID, origin, challenge)
+			require.NoError(t, err)
+			clientData := testwebauthn.ClientDataJSON("webauthn.get", origin, challenge)
+			_, err = rp.VerifyRegistration(challenge, clientData, attestationObject)
+			assert.EqualError(t, err, "invalid client data: unexpected type 'webauthn.get'")
+		})
+
+		t.Run("truncated attestation object", func(t *testing.T) {
+			clientData, attestationObject, err := authenticator.Register(rpID, origin, challenge)
+			require.NoError(t, err)
+			_, err = rp.VerifyRegistration(challenge, clientData, attestationObject[:len(attestationObject)-10])
+			assert.Error(t, err)
+		})
+	})
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	// given
+	authenticator, err := testwebauthn.NewAuthenticator()
+	require.NoError(t, err)
+	credential := register(t, authenticator)
+
+	t.Run("ok", func(t *testing.T) {
+		// given
+		challenge, err := webauthn.NewChallenge()
+		require.NoError(t, err)
+		clientData, authData, signature, err := authenticator.Assert(rpID, origin, challenge)
+		require.NoError(t, err)
+		// when
+		signCount, err := rp.VerifyAssertion(challenge, *credential, clientData, authData, signature)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, authenticator.SignCount, signCount)
+		credential.SignCount = signCount
+	})
+
+	t.Run("signature counter did not increase", func(t *testing.T) {
+		// given
+		challenge, err := webauthn.NewChallenge()
+		require.NoError(t, err)
+		authenticator.SignCount = credential.SignCount - 1
+		clientData, authData, signature, err := authenticator.Assert(rpID, origin, challenge)
+		require.NoError(t, err)
+		// when
+		_, err = rp.VerifyAssertion(challenge, *credential, clientData, authData, signature)
+		// then
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid signature", func(t *testing.T) {
+		// given
+		challenge, err := webauthn.NewChallenge()
+		require.NoError(t, err)
+		other, err := testwebauthn.NewAuthenticator()
+		require.NoError(t, err)
+		other.SignCount = credential.SignCount + 10
+		clientData, authData, signature, err := other.Assert(rpID, origin, challenge)
+		require.NoError(t, err)
+		// when
+		_, err = rp.VerifyAssertion(challenge, *credential, clientData, authData, signature)
+		// then
+		assert.EqualError(t, err, "invalid assertion signature: invalid signature")
+	})
+
+	t.Run("replayed with another challenge", func(t *testing.T) {
+		// given
+		challenge, err := webauthn.NewChallenge()
+		require.NoError(t, err)
+		clientData, authData, signature, err := authenticator.Assert(rpID, origin, challenge)
+		require.NoError(t, err)
+		otherChallenge, err := webauthn.NewChallenge()
+		require.NoError(t, err)
+		// when
+		_, err = rp.VerifyAssertion(otherChallenge, *credential, clientData, authData, signature)
+		// then
+		assert.EqualError(t, err, "invalid client data: challenge mismatch")
+	})
+}
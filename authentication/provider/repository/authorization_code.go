@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/authorization/token/encryption"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// AuthorizationCode an authorization code returned by the OAuth provider to the authorize callback, along with the
+// provider token for which it was exchanged. The code is returned to the client once the second factor of the user was
+// verified, if the user enrolled for multi-factor authentication, and the client then exchanges it for the user tokens.
+type AuthorizationCode struct {
+	gormsupport.LifecycleHardDelete
+	// AuthorizationCodeID the ID of the authorization code. This is the primary key value.
+	AuthorizationCodeID uuid.UUID `sql:"type:uuid" gorm:"primary_key;column:authorization_code_id"`
+	// CodeHash the SHA-256 hash of the code, which is not stored itself
+	CodeHash string
+	// ProviderToken the provider token. Encrypted in the database, and transparently decrypted when loaded
+	ProviderToken string
+	// EncryptedDataKey the data key used to encrypt the provider token, itself encrypted with the master key
+	EncryptedDataKey string
+	// MasterKeyID the ID of the master key used to encrypt the data key
+	MasterKeyID string
+	// SecondFactorRequired true until the second factor of the user is verified
+	SecondFactorRequired bool
+	// AuthMethod the second authentication factor verified by the user, if any
+	AuthMethod *string
+	// ExpiresAt the time after which the code can not be exchanged anymore
+	ExpiresAt time.Time
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m AuthorizationCode) TableName() string {
+	return "authorization_code"
+}
+
+// GormAuthorizationCodeRepository is the implementation of the storage interface for AuthorizationCode.
+// Provider tokens are transparently encrypted before they are stored and decrypted after they are loaded.
+type GormAuthorizationCodeRepository struct {
+	db        *gorm.DB
+	encryptor encryption.Encryptor
+}
+
+// NewAuthorizationCodeRepository creates a new storage type.
+func NewAuthorizationCodeRepository(db *gorm.DB, encryptor encryption.Encryptor) AuthorizationCodeRepository {
+	return &GormAuthorizationCodeRepository{db: db, encryptor: encryptor}
+}
+
+// AuthorizationCodeRepository represents the storage interface.
+type AuthorizationCodeRepository interface {
+	Create(ctx context.Context, code *AuthorizationCode) error
+	Consume(ctx context.Context, codeHash string) (*AuthorizationCode, error)
+	MarkVerified(ctx context.Context, id uuid.UUID, authMethod string) error
+	DeleteExpired(ctx context.Context, now time.Time) error
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m *GormAuthorizationCodeRepository) TableName() string {
+	return "authorization_code"
+}
+
+// Create creates a new record, the provider token being encrypted before it is stored
+func (m *GormAuthorizationCodeRepository) Create(ctx context.Context, code *AuthorizationCode) error {
+	defer goa.MeasureSince([]string{"goa", "db", "authorization_code", "create"}, time.Now())
+
+	if code.AuthorizationCodeID == uuid.Nil {
+		code.AuthorizationCodeID = uuid.NewV4()
+	}
+	providerToken := code.ProviderToken
+	envelope, err := m.encryptor.Encrypt(code.ProviderToken)
+	if err != nil {
+		return errs.Wrapf(err, "unable to encrypt the provider token of authorization code '%s'", code.AuthorizationCodeID)
+	}
+	code.ProviderToken = envelope.Ciphertext
+	code.EncryptedDataKey = envelope.EncryptedDataKey
+	code.MasterKeyID = envelope.KeyID
+	err = m.db.Create(code).Error
+	// callers keep working with the plain text provider token
+	code.ProviderToken = providerToken
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"authorization_code_id": code.AuthorizationCodeID,
+			"err":                   err,
+		}, "unable to create the authorization code")
+		return errs.WithStack(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"authorization_code_id": code.AuthorizationCodeID,
+	}, "authorization code created!")
+	return nil
+}
+
+// Consume deletes the authorization code with the given hash and returns it, with its provider token decrypted, so that
+// a code can only be exchanged once. Returns a NotFoundError if there is no such code, or if it was already consumed.
+func (m *GormAuthorizationCodeRepository) Consume(ctx context.Context, codeHash string) (*AuthorizationCode, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "authorization_code", "consume"}, time.Now())
+
+	var native AuthorizationCode
+	err := m.db.Table(m.TableName()).Where("code_hash = ?", codeHash).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundErrorWithKey("authorization_code", "code_hash", codeHash)
+	}
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	// the code is only returned to the request which actually deleted it
+	result := m.db.Delete(&AuthorizationCode{AuthorizationCodeID: native.AuthorizationCodeID})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"authorization_code_id": native.AuthorizationCodeID,
+			"err":                   result.Error,
+		}, "unable to delete the authorization code")
+		return nil, errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.NewNotFoundErrorWithKey("authorization_code", "code_hash", codeHash)
+	}
+	providerToken, err := m.encryptor.Decrypt(encryption.Envelope{
+		Ciphertext:       native.ProviderToken,
+		EncryptedDataKey: native.EncryptedDataKey,
+		KeyID:            native.MasterKeyID,
+	})
+	if err != nil {
+		return nil, errs.Wrapf(err, "unable to decrypt the provider token of authorization code '%s'", native.AuthorizationCodeID)
+	}
+	native.ProviderToken = providerToken
+	return &native, nil
+}
+
+// MarkVerified records that the second factor of the user was verified with the given method, so that the
+// authorization code can be exchanged
+func (m *GormAuthorizationCodeRepository) MarkVerified(ctx context.Context, id uuid.UUID, authMethod string) error {
+	defer goa.MeasureSince([]string{"goa", "db", "authorization_code", "mark_verified"}, time.Now())
+
+	result := m.db.Model(&AuthorizationCode{}).Where("authorization_code_id = ?", id).Updates(map[string]interface{}{
+		"second_factor_required": false,
+		"auth_method":            authMethod,
+	})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"authorization_code_id": id,
+			"err":                   result.Error,
+		}, "unable to update the authorization code")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("authorization_code", id.String())
+	}
+	return nil
+}
+
+// DeleteExpired removes all the authorization codes which expired at the given time
+func (m *GormAuthorizationCodeRepository) DeleteExpired(ctx context.Context, now time.Time) error {
+	defer goa.MeasureSince([]string{"goa", "db", "authorization_code", "delete_expired"}, time.Now())
+
+	err := m.db.Where("expires_at < ?", now).Delete(&AuthorizationCode{}).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to delete the expired authorization codes")
+		return errs.WithStack(err)
+	}
+	return nil
+}
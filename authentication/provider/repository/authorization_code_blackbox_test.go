@@ -0,0 +1,106 @@
+package repository_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/provider/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization/token/encryption"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type authorizationCodeBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+	repo repository.AuthorizationCodeRepository
+}
+
+func TestRunAuthorizationCodeBlackBoxTest(t *testing.T) {
+	suite.Run(t, &authorizationCodeBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *authorizationCodeBlackBoxTest) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	encryptor, err := encryption.NewEnvelopeEncryptor("test-key-1", map[string][]byte{
+		"test-key-1": []byte("0123456789abcdef0123456789abcdef"),
+	})
+	require.NoError(s.T(), err)
+	s.repo = repository.NewAuthorizationCodeRepository(s.DB, encryptor)
+}
+
+func (s *authorizationCodeBlackBoxTest) newAuthorizationCode(expiresAt time.Time) *repository.AuthorizationCode {
+	code := &repository.AuthorizationCode{
+		CodeHash:             uuid.NewV4().String(),
+		ProviderToken:        `{"access_token":"foo"}`,
+		SecondFactorRequired: true,
+		ExpiresAt:            expiresAt,
+	}
+	require.NoError(s.T(), s.repo.Create(s.Ctx, code))
+	return code
+}
+
+func (s *authorizationCodeBlackBoxTest) TestCreateAndConsume() {
+	// given
+	code := s.newAuthorizationCode(time.Now().Add(time.Minute))
+	// the provider token is not stored in clear
+	var native repository.AuthorizationCode
+	require.NoError(s.T(), s.DB.Table(code.TableName()).Where("authorization_code_id = ?", code.AuthorizationCodeID).Find(&native).Error)
+	assert.NotEqual(s.T(), `{"access_token":"foo"}`, native.ProviderToken)
+	assert.Equal(s.T(), "test-key-1", native.MasterKeyID)
+
+	// when
+	consumed, err := s.repo.Consume(s.Ctx, code.CodeHash)
+
+	// then
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), code.AuthorizationCodeID, consumed.AuthorizationCodeID)
+	assert.Equal(s.T(), `{"access_token":"foo"}`, consumed.ProviderToken)
+	assert.True(s.T(), consumed.SecondFactorRequired)
+	// and the code can only be consumed once
+	_, err = s.repo.Consume(s.Ctx, code.CodeHash)
+	require.Error(s.T(), err)
+	assert.IsType(s.T(), errors.NotFoundError{}, err)
+}
+
+func (s *authorizationCodeBlackBoxTest) TestMarkVerified() {
+	s.Run("ok", func() {
+		// given
+		code := s.newAuthorizationCode(time.Now().Add(time.Minute))
+		// when
+		err := s.repo.MarkVerified(s.Ctx, code.AuthorizationCodeID, "otp")
+		// then
+		require.NoError(s.T(), err)
+		consumed, err := s.repo.Consume(s.Ctx, code.CodeHash)
+		require.NoError(s.T(), err)
+		assert.False(s.T(), consumed.SecondFactorRequired)
+		require.NotNil(s.T(), consumed.AuthMethod)
+		assert.Equal(s.T(), "otp", *consumed.AuthMethod)
+	})
+
+	s.Run("not found", func() {
+		// when
+		err := s.repo.MarkVerified(s.Ctx, uuid.NewV4(), "otp")
+		// then
+		require.Error(s.T(), err)
+		assert.IsType(s.T(), errors.NotFoundError{}, err)
+	})
+}
+
+func (s *authorizationCodeBlackBoxTest) TestDeleteExpired() {
+	// given
+	expired := s.newAuthorizationCode(time.Now().Add(-time.Minute))
+	valid := s.newAuthorizationCode(time.Now().Add(time.Minute))
+	// when
+	err := s.repo.DeleteExpired(s.Ctx, time.Now())
+	// then
+	require.NoError(s.T(), err)
+	_, err = s.repo.Consume(s.Ctx, expired.CodeHash)
+	assert.IsType(s.T(), errors.NotFoundError{}, err)
+	_, err = s.repo.Consume(s.Ctx, valid.CodeHash)
+	assert.NoError(s.T(), err)
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -20,6 +21,8 @@ import (
 	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
 	name "github.com/fabric8-services/fabric8-auth/authentication/account"
 	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	mfarepo "github.com/fabric8-services/fabric8-auth/authentication/mfa/repository"
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa/webauthn"
 	"github.com/fabric8-services/fabric8-auth/authentication/provider"
 	providerrepo "github.com/fabric8-services/fabric8-auth/authentication/provider/repository"
 	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
@@ -36,6 +39,8 @@ type AuthenticationProviderServiceConfig interface {
 	manager.TokenManagerConfiguration
 	GetPublicOAuthClientID() string
 	GetWITURL() (string, error)
+	GetMFAVerificationURL() string
+	GetMFAChallengeExpiresIn() time.Duration
 }
 
 type authenticationProviderServiceImpl struct {
@@ -49,6 +54,19 @@ const (
 	tokenJSONParam = "token_json"
 )
 
+// secondFactorPolicy how the second factor of the users who enrolled for multi-factor authentication is handled when
+// they log in
+type secondFactorPolicy int
+
+const (
+	// secondFactorIgnored the second factor is not checked, because it was verified already or is not required
+	secondFactorIgnored secondFactorPolicy = iota
+	// secondFactorChallenged a login challenge is started and the tokens are issued once it is verified
+	secondFactorChallenged
+	// secondFactorRejected the login is rejected, because the second factor can not be verified at this step
+	secondFactorRejected
+)
+
 // NewAuthenticationProviderService returns a new AuthenticationProviderService implementation
 func NewAuthenticationProviderService(ctx servicecontext.ServiceContext, config AuthenticationProviderServiceConfig) service.AuthenticationProviderService {
 	return &authenticationProviderServiceImpl{
@@ -137,7 +155,7 @@ func (s *authenticationProviderServiceImpl) LoginCallback(ctx context.Context, s
 		return &redirect, err
 	}
 
-	redirectTo, _, err := s.CreateOrUpdateIdentityAndUser(ctx, referrerURL, providerToken)
+	redirectTo, _, err := s.createOrUpdateIdentityAndUser(ctx, referrerURL, providerToken, secondFactorChallenged)
 	if err != nil {
 		return nil, err
 	}
@@ -152,15 +170,117 @@ func (s *authenticationProviderServiceImpl) LoginCallback(ctx context.Context, s
 
 // AuthorizeCallback takes care of authorization callback.
 // When authorization_code is requested with /api/authorize, oauth provider returns authorization_code at /api/authorize/callback,
-// which would pass on the code along with the state to client using this method
-func (s *authenticationProviderServiceImpl) AuthorizeCallback(ctx context.Context, state string, code string) (*string, error) {
+// which would pass on the code along with the state to client using this method.
+// The code is exchanged with the oauth provider here already, with the given callback URL, and the provider token is
+// kept until the client exchanges the code for the user tokens. If the user enrolled for multi-factor authentication,
+// then the returned URL is the one of the MFA verification page, which passes on the code to the client once the
+// second factor is verified (see CompleteMultiFactorLogin).
+func (s *authenticationProviderServiceImpl) AuthorizeCallback(ctx context.Context, state string, code string, callbackURL string) (*string, error) {
 	referrerURL, responseMode, err := s.reclaimReferrerAndResponseMode(ctx, state, code)
 	if err != nil {
 		return nil, err
 	}
 
 	redirectTo := buildRedirectURL(code, state, referrerURL, responseMode)
-	return &redirectTo, nil
+
+	providerToken, err := s.ExchangeCodeWithProvider(ctx, code, callbackURL)
+	if err != nil {
+		return nil, err
+	}
+	serializedToken, err := json.Marshal(newStoredProviderToken(providerToken))
+	if err != nil {
+		return nil, autherrors.NewInternalError(err)
+	}
+	authorizationCode := &providerrepo.AuthorizationCode{
+		CodeHash:      hashAuthorizationCode(code),
+		ProviderToken: string(serializedToken),
+		ExpiresAt:     time.Now().Add(s.config.GetMFAChallengeExpiresIn()),
+	}
+
+	identity, err := s.UpdateIdentityUsingUserInfoEndPoint(ctx, providerToken.AccessToken)
+	if err != nil {
+		if unauthorized, _ := autherrors.IsUnauthorizedError(err); !unauthorized {
+			return nil, err
+		}
+		// the user is not approved, which is reported when the code is exchanged for the user tokens
+		identity = nil
+	}
+	enrolled := false
+	if identity != nil {
+		enrolled, err = s.Services().MFAService().IsEnrolled(ctx, identity.ID)
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{"err": err, "identity_id": identity.ID.String()}, "failed to check multi-factor authentication enrollment")
+			return nil, err
+		}
+	}
+	authorizationCode.SecondFactorRequired = enrolled
+
+	err = s.ExecuteInTransaction(func() error {
+		err := s.Repositories().AuthorizationCodes().DeleteExpired(ctx, time.Now())
+		if err != nil {
+			return err
+		}
+		return s.Repositories().AuthorizationCodes().Create(ctx, authorizationCode)
+	})
+	if err != nil {
+		return nil, autherrors.NewInternalError(err)
+	}
+	if !enrolled {
+		return &redirectTo, nil
+	}
+
+	challenge, err := s.Services().MFAService().StartChallenge(ctx, identity.ID, redirectTo, "")
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{"err": err, "identity_id": identity.ID.String()}, "failed to start multi-factor authentication challenge")
+		return nil, err
+	}
+	challenge.AuthorizationCodeID = &authorizationCode.AuthorizationCodeID
+	err = s.ExecuteInTransaction(func() error {
+		return s.Repositories().MFAChallenges().Save(ctx, challenge)
+	})
+	if err != nil {
+		return nil, autherrors.NewInternalError(err)
+	}
+	verificationURL, err := rest.AddParam(s.config.GetMFAVerificationURL(), "challenge", challenge.ChallengeID.String())
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{"err": err}, "failed to add the challenge param to the MFA verification URL")
+		return nil, autherrors.NewInternalError(err)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"identity_id":  identity.ID.String(),
+		"challenge_id": challenge.ChallengeID.String(),
+	}, "multi-factor authentication required; redirecting to verification page before returning the authorization code")
+	return &verificationURL, nil
+}
+
+// storedProviderToken the provider token for which an authorization code was exchanged, as it is stored until the
+// client exchanges the code. The expiries are not part of the JSON representation of the oauth2.Token, but they are
+// needed to issue the API client tokens.
+type storedProviderToken struct {
+	oauth2.Token
+	ExpiresIn        interface{} `json:"expires_in,omitempty"`
+	RefreshExpiresIn interface{} `json:"refresh_expires_in,omitempty"`
+}
+
+func newStoredProviderToken(token *oauth2.Token) storedProviderToken {
+	return storedProviderToken{
+		Token:            *token,
+		ExpiresIn:        token.Extra("expires_in"),
+		RefreshExpiresIn: token.Extra("refresh_expires_in"),
+	}
+}
+
+func (t storedProviderToken) oauth2Token() *oauth2.Token {
+	return t.Token.WithExtra(map[string]interface{}{
+		"expires_in":         t.ExpiresIn,
+		"refresh_expires_in": t.RefreshExpiresIn,
+	})
+}
+
+// hashAuthorizationCode returns the hash of the given authorization code, which is stored instead of the code itself
+func hashAuthorizationCode(code string) string {
+	hash := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(hash[:])
 }
 
 func buildRedirectURL(code string, state string, referrerURL *url.URL, responseMode *string) string {
@@ -194,13 +314,37 @@ func (s *authenticationProviderServiceImpl) ExchangeAuthorizationCodeForUserToke
 		return nil, nil, autherrors.NewUnauthorizedError("invalid oauth client id")
 	}
 
-	// Exchange the authorization code for an access token with the identity provider
-	providerToken, err := s.ExchangeCodeWithProvider(ctx, code, redirectURL.String())
+	var providerToken *oauth2.Token
+	policy := secondFactorRejected
+	var authMethods []string
+	authorizationCode, err := s.consumeAuthorizationCode(ctx, code)
 	if err != nil {
 		return nil, nil, err
 	}
+	if authorizationCode != nil {
+		// the code was already exchanged with the identity provider by the authorize callback, which also verified the
+		// second factor of the user, if required
+		var storedToken storedProviderToken
+		err = json.Unmarshal([]byte(authorizationCode.ProviderToken), &storedToken)
+		if err != nil {
+			return nil, nil, autherrors.NewInternalError(err)
+		}
+		providerToken = storedToken.oauth2Token()
+		policy = secondFactorIgnored
+		if authorizationCode.AuthMethod != nil {
+			authMethods = append(authMethods, *authorizationCode.AuthMethod)
+		}
+	} else {
+		// Exchange the authorization code for an access token with the identity provider. The second factor can not
+		// be verified at this step, so the users who enrolled for multi-factor authentication are rejected, otherwise
+		// this grant would bypass the multi-factor authentication.
+		providerToken, err = s.ExchangeCodeWithProvider(ctx, code, redirectURL.String())
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 
-	notApprovedRedirectURL, userToken, err := s.CreateOrUpdateIdentityAndUser(ctx, redirectURL, providerToken)
+	redirectTo, userToken, err := s.createOrUpdateIdentityAndUser(ctx, redirectURL, providerToken, policy, authMethods...)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -227,7 +371,35 @@ func (s *authenticationProviderServiceImpl) ExchangeAuthorizationCodeForUserToke
 		}
 	}
 
-	return notApprovedRedirectURL, token, nil
+	return redirectTo, token, nil
+}
+
+// consumeAuthorizationCode removes the given authorization code stored by the authorize callback and returns it, or
+// nil if the code is unknown. Returns an UnauthorizedError if the code expired or if the second factor of the user was
+// not verified yet.
+func (s *authenticationProviderServiceImpl) consumeAuthorizationCode(ctx context.Context, code string) (*providerrepo.AuthorizationCode, error) {
+	var authorizationCode *providerrepo.AuthorizationCode
+	err := s.ExecuteInTransaction(func() error {
+		var err error
+		authorizationCode, err = s.Repositories().AuthorizationCodes().Consume(ctx, hashAuthorizationCode(code))
+		return err
+	})
+	if err != nil {
+		if notFound, _ := autherrors.IsNotFoundError(err); notFound {
+			return nil, nil
+		}
+		return nil, autherrors.NewInternalError(err)
+	}
+	if authorizationCode.ExpiresAt.Before(time.Now()) {
+		return nil, autherrors.NewUnauthorizedError("authorization code expired")
+	}
+	if authorizationCode.SecondFactorRequired {
+		log.Warn(ctx, map[string]interface{}{
+			"authorization_code_id": authorizationCode.AuthorizationCodeID.String(),
+		}, "authorization code exchanged before the second factor was verified")
+		return nil, autherrors.NewUnauthorizedError("multi-factor authentication required")
+	}
+	return authorizationCode, nil
 }
 
 // Exchange exchanges the given code for OAuth2 token with the Authentication provider
@@ -257,6 +429,16 @@ func (s *authenticationProviderServiceImpl) ExchangeCodeWithProvider(ctx context
 // checks whether the user is approved, generates a new user token and returns a final URL to which the client should redirect
 func (s *authenticationProviderServiceImpl) CreateOrUpdateIdentityAndUser(ctx context.Context, referrerURL *url.URL,
 	providerToken *oauth2.Token) (*string, *oauth2.Token, error) {
+	return s.createOrUpdateIdentityAndUser(ctx, referrerURL, providerToken, secondFactorIgnored)
+}
+
+// createOrUpdateIdentityAndUser is the implementation of CreateOrUpdateIdentityAndUser. If the user enrolled for
+// multi-factor authentication, then the given policy applies: with `secondFactorChallenged`, no token is generated and
+// the returned URL is the one of the MFA verification page instead, the tokens being issued once the second factor is
+// verified (see CompleteMultiFactorLogin), and with `secondFactorRejected` an UnauthorizedError is returned.
+// The given auth methods are the additional authentication factors which were verified already, if any.
+func (s *authenticationProviderServiceImpl) createOrUpdateIdentityAndUser(ctx context.Context, referrerURL *url.URL,
+	providerToken *oauth2.Token, policy secondFactorPolicy, authMethods ...string) (*string, *oauth2.Token, error) {
 
 	tokenManager, err := manager.ReadTokenManagerFromContext(ctx)
 	if err != nil {
@@ -321,7 +503,7 @@ func (s *authenticationProviderServiceImpl) CreateOrUpdateIdentityAndUser(ctx co
 			"identity_id": identity.ID,
 			"user_name":   identity.Username,
 		}, "banned user tried to login")
-		return nil, nil, autherrors.NewUnauthorizedError("unauthorized access")
+		return nil, nil, s.Services().UserService().BanError(ctx, identity.User, "unauthorized access")
 	}
 
 	log.Debug(ctx, map[string]interface{}{
@@ -329,33 +511,179 @@ func (s *authenticationProviderServiceImpl) CreateOrUpdateIdentityAndUser(ctx co
 		"user_name":   identity.Username,
 	}, "local user created/updated")
 
-	// Update the identity's last active timestamp
-	err = s.Repositories().Identities().TouchLastActive(ctx, identity.ID)
+	if policy != secondFactorIgnored {
+		enrolled, err := s.Services().MFAService().IsEnrolled(ctx, identity.ID)
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{"err": err, "identity_id": identity.ID.String()}, "failed to check multi-factor authentication enrollment")
+			return nil, nil, err
+		}
+		if enrolled && policy == secondFactorRejected {
+			log.Warn(ctx, map[string]interface{}{
+				"identity_id": identity.ID.String(),
+			}, "multi-factor authentication required but the second factor can not be verified")
+			return nil, nil, autherrors.NewUnauthorizedError("multi-factor authentication required")
+		}
+		if enrolled {
+			challenge, err := s.Services().MFAService().StartChallenge(ctx, identity.ID, referrerURL.String(), apiClient)
+			if err != nil {
+				log.Error(ctx, map[string]interface{}{"err": err, "identity_id": identity.ID.String()}, "failed to start multi-factor authentication challenge")
+				return nil, nil, err
+			}
+			redirectTo, err := rest.AddParam(s.config.GetMFAVerificationURL(), "challenge", challenge.ChallengeID.String())
+			if err != nil {
+				log.Error(ctx, map[string]interface{}{"err": err}, "failed to add the challenge param to the MFA verification URL")
+				return nil, nil, autherrors.NewInternalError(err)
+			}
+			log.Debug(ctx, map[string]interface{}{
+				"identity_id":  identity.ID.String(),
+				"challenge_id": challenge.ChallengeID.String(),
+			}, "multi-factor authentication required; redirecting to verification page")
+			return &redirectTo, nil, nil
+		}
+	}
+
+	return s.issueUserToken(ctx, tokenManager, identity, referrerURL, apiClient, authMethods...)
+}
+
+// CompleteMultiFactorLogin verifies the TOTP or recovery code of the given login challenge and, if valid, generates
+// a new multi-factor user token and returns the referrer URL with the encoded token to which the client should redirect
+func (s *authenticationProviderServiceImpl) CompleteMultiFactorLogin(ctx context.Context, challengeID uuid.UUID, code string) (*string, error) {
+	challenge, err := s.Services().MFAService().VerifyChallenge(ctx, challengeID, code)
+	if err != nil {
+		log.Warn(ctx, map[string]interface{}{
+			"err":          err,
+			"challenge_id": challengeID.String(),
+		}, "failed to verify multi-factor authentication challenge")
+		return nil, err
+	}
+	return s.completeMultiFactorLogin(ctx, challenge, token2.AuthMethodOTP)
+}
+
+// CompleteWebAuthnLogin verifies the WebAuthn assertion of the given login challenge and, if valid, generates
+// a new multi-factor user token and returns the referrer URL with the encoded token to which the client should redirect
+func (s *authenticationProviderServiceImpl) CompleteWebAuthnLogin(ctx context.Context, challengeID uuid.UUID, response webauthn.AssertionResponse) (*string, error) {
+	challenge, err := s.Services().WebAuthnService().FinishAssertion(ctx, challengeID, response)
+	if err != nil {
+		log.Warn(ctx, map[string]interface{}{
+			"err":          err,
+			"challenge_id": challengeID.String(),
+		}, "failed to verify WebAuthn assertion")
+		return nil, err
+	}
+	return s.completeMultiFactorLogin(ctx, challenge, token2.AuthMethodHardwareKey)
+}
+
+// completeMultiFactorLogin issues the user token of the given verified login challenge, with the given second
+// authentication factor in its `amr` claim
+func (s *authenticationProviderServiceImpl) completeMultiFactorLogin(ctx context.Context, challenge *mfarepo.MFAChallenge, authMethod string) (*string, error) {
+	tokenManager, err := manager.ReadTokenManagerFromContext(ctx)
+	if err != nil {
+		log.Error(nil, map[string]interface{}{
+			"err": err,
+		}, "failed to retrieve token manager from context")
+		return nil, autherrors.NewInternalError(err)
+	}
+	identity, err := s.Repositories().Identities().LoadWithUser(ctx, challenge.IdentityID)
+	if err != nil {
+		return nil, autherrors.NewInternalError(err)
+	}
+	// the user may have been banned in the meantime
+	if identity.User.Banned {
+		log.Warn(ctx, map[string]interface{}{
+			"identity_id": identity.ID,
+			"user_name":   identity.Username,
+		}, "banned user tried to login")
+		return nil, s.Services().UserService().BanError(ctx, identity.User, "unauthorized access")
+	}
+	if challenge.AuthorizationCodeID != nil {
+		// the login was started with the authorization code flow: the code is released to the client, which then
+		// exchanges it for the user tokens
+		err = s.ExecuteInTransaction(func() error {
+			return s.Repositories().AuthorizationCodes().MarkVerified(ctx, *challenge.AuthorizationCodeID, authMethod)
+		})
+		if err != nil {
+			if notFound, _ := autherrors.IsNotFoundError(err); notFound {
+				return nil, autherrors.NewUnauthorizedError("authorization code expired")
+			}
+			return nil, autherrors.NewInternalError(err)
+		}
+		return &challenge.Referrer, nil
+	}
+	referrerURL, err := url.Parse(challenge.Referrer)
+	if err != nil {
+		return nil, autherrors.NewInternalError(err)
+	}
+
+	redirectTo, _, err := s.issueUserToken(ctx, tokenManager, identity, referrerURL, challenge.APIClient, authMethod)
+	return redirectTo, err
+}
+
+// issueUserToken generates a new user token for the given identity, starts a new session and encodes the token
+// in the referrer URL to which the client should redirect. This is the last step of a successful login, in which the
+// last active timestamp of the identity is updated.
+func (s *authenticationProviderServiceImpl) issueUserToken(ctx context.Context, tokenManager manager.TokenManager, identity *account.Identity,
+	referrerURL *url.URL, apiClient string, authMethods ...string) (*string, *oauth2.Token, error) {
+	if identity.DeletionRequested != nil {
+		log.Info(ctx, map[string]interface{}{
+			"identity_id": identity.ID.String(),
+		}, "user logged in again, cancelling the deletion of the account")
+	}
+	// Update the identity's last active timestamp (which also cancels the pending deletion of the account, if any)
+	err := s.Repositories().Identities().TouchLastActive(ctx, identity.ID)
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{"err": err, "identity_id": identity.ID.String()}, "failed to update last_active timestamp for identity")
 		return nil, nil, err
 	}
 
 	// Generate a new user token instead of using the original oauth provider token
-	userToken, err := tokenManager.GenerateUserTokenForIdentity(ctx, *identity, false)
+	userToken, err := tokenManager.GenerateUserTokenForIdentity(ctx, *identity, false, authMethods...)
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{"err": err, "identity_id": identity.ID.String()}, "failed to generate token for user")
 		return nil, nil, err
 	}
 
-	// Register the access token
-	_, err = s.Services().TokenService().RegisterToken(ctx, identity.ID, userToken.AccessToken, token2.TOKEN_TYPE_ACCESS, nil)
+	// Start a new session, the generated tokens are linked to it when they are registered
+	tokenClaims, err := tokenManager.ParseToken(ctx, userToken.AccessToken)
 	if err != nil {
-		log.Error(ctx, map[string]interface{}{"error": err}, "could not register access token")
+		log.Error(ctx, map[string]interface{}{"err": err, "identity_id": identity.ID.String()}, "failed to parse generated user token")
 		return nil, nil, autherrors.NewInternalError(err)
 	}
-
-	// Register the refresh token
-	_, err = s.Services().TokenService().RegisterToken(ctx, identity.ID, userToken.RefreshToken, token2.TOKEN_TYPE_REFRESH, nil)
+	sessionID, err := uuid.FromString(tokenClaims.SessionState)
 	if err != nil {
-		log.Error(ctx, map[string]interface{}{"error": err}, "could not register refresh token")
+		log.Error(ctx, map[string]interface{}{"err": err, "identity_id": identity.ID.String()}, "invalid session state in generated user token")
 		return nil, nil, autherrors.NewInternalError(err)
 	}
+	clientID := apiClient
+	if clientID == "" {
+		clientID = s.config.GetPublicOAuthClientID()
+	}
+	// The session and its tokens are created in the same transaction, otherwise the session could be cleaned up as an
+	// orphan before its tokens are registered
+	err = s.ExecuteInTransaction(func() error {
+		_, err := s.Services().TokenService().StartSession(ctx, identity.ID, sessionID, clientID)
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{"error": err, "identity_id": identity.ID.String()}, "could not start session")
+			return err
+		}
+
+		// Register the access token
+		_, err = s.Services().TokenService().RegisterToken(ctx, identity.ID, userToken.AccessToken, token2.TOKEN_TYPE_ACCESS, nil)
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{"error": err}, "could not register access token")
+			return autherrors.NewInternalError(err)
+		}
+
+		// Register the refresh token
+		_, err = s.Services().TokenService().RegisterToken(ctx, identity.ID, userToken.RefreshToken, token2.TOKEN_TYPE_REFRESH, nil)
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{"error": err}, "could not register refresh token")
+			return autherrors.NewInternalError(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
 
 	err = encodeToken(ctx, referrerURL, userToken, apiClient)
 	if err != nil {
@@ -394,7 +722,8 @@ func (s *authenticationProviderServiceImpl) UpdateIdentityUsingUserInfoEndPoint(
 
 	identity := &account.Identity{}
 
-	identities, err := s.Repositories().Identities().Query(account.IdentityFilterByUsername(userProfile.Username), account.IdentityWithUser())
+	// the previous usernames are ignored: once released, they can be taken by other users
+	identities, err := s.Repositories().Identities().Query(account.IdentityFilterByCurrentUsername(userProfile.Username), account.IdentityWithUser())
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
 			"err": err,
@@ -408,7 +737,7 @@ func (s *authenticationProviderServiceImpl) UpdateIdentityUsingUserInfoEndPoint(
 	identity = &identities[0]
 
 	// we had done a
-	// s.Repositories().Identities().Query(account.IdentityFilterByUsername(userProfile.Username), account.IdentityWithUser())
+	// s.Repositories().Identities().Query(account.IdentityFilterByCurrentUsername(userProfile.Username), account.IdentityWithUser())
 	// so, identity.user should have been populated.
 
 	if identity.User.ID == uuid.Nil {
@@ -12,6 +12,7 @@ import (
 
 	"github.com/fabric8-services/fabric8-auth/app"
 	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
+	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
 	"github.com/fabric8-services/fabric8-auth/authentication/provider"
 	token2 "github.com/fabric8-services/fabric8-auth/authorization/token"
 	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
@@ -116,6 +117,32 @@ func (s *authenticationProviderServiceTestSuite) TestUnapprovedUserUnauthorized(
 	require.IsType(s.T(), autherrors.NewUnauthorizedError(""), err)
 }
 
+func (s *authenticationProviderServiceTestSuite) TestPreviousUsernameLoginUnauthorized() {
+	// given a user who changed their username
+	identity := s.Graph.CreateUser().Identity()
+	previousUsername := identity.Username
+	err := s.Application.UsernameHistory().Create(s.Ctx, &account.UsernameHistory{
+		IdentityID:    identity.ID,
+		Username:      previousUsername,
+		ReservedUntil: time.Now().Add(-time.Hour),
+	})
+	require.NoError(s.T(), err)
+	identity.Username = "renamed-" + uuid.NewV4().String()
+	require.NoError(s.T(), s.Application.Identities().Save(s.Ctx, identity))
+	identityProvider := testoauth.NewIdentityProviderMock(s.T())
+	identityProvider.ProfileFunc = func(ctx context.Context, tk oauth2.Token) (*provider.UserProfile, error) {
+		return &provider.UserProfile{
+			Username: previousUsername,
+		}, nil
+	}
+	testsupport.ActivateDummyIdentityProviderFactory(s, identityProvider)
+	// when
+	_, err = s.Application.AuthenticationProviderService().UpdateIdentityUsingUserInfoEndPoint(context.Background(), "token")
+	// then the previous username, which another user can take, does not resolve to the user
+	require.Error(s.T(), err)
+	require.IsType(s.T(), autherrors.NewUnauthorizedError(""), err)
+}
+
 func (s *authenticationProviderServiceTestSuite) TestUnapprovedUserRedirected() {
 	env := os.Getenv("AUTH_NOTAPPROVED_REDIRECT")
 	defer func() {
@@ -1010,10 +1037,11 @@ func (s *authenticationProviderServiceTestSuite) TestOAuthAuthorizationRedirectF
 func (s *authenticationProviderServiceTestSuite) TestValidOAuthAuthorizationCodeForAuthorize() {
 
 	_, callbackCtx := s.authorizeCallback("valid_code")
-	_, err := s.Application.AuthenticationProviderService().AuthorizeCallback(callbackCtx, callbackCtx.State, callbackCtx.Code)
+	testsupport.ActivateDummyIdentityProviderFactory(s, s.getDummyOauthIDPService(uuid.NewV4().String(), true))
+	_, err := s.Application.AuthenticationProviderService().AuthorizeCallback(callbackCtx, callbackCtx.State, callbackCtx.Code,
+		rest.AbsoluteURL(callbackCtx.RequestData, client.CallbackAuthorizePath(), nil))
 	require.Nil(s.T(), err)
 
-	testsupport.ActivateDummyIdentityProviderFactory(s, s.getDummyOauthIDPService(uuid.NewV4().String(), true))
 	userToken, err := s.Application.AuthenticationProviderService().ExchangeCodeWithProvider(callbackCtx,
 		callbackCtx.Code, rest.AbsoluteURL(callbackCtx.RequestData, client.CallbackLoginPath(), nil))
 	require.Nil(s.T(), err)
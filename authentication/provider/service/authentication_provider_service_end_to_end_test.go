@@ -263,7 +263,8 @@ func (s *serviceLoginBlackBoxTest) runOauth2LoginEndToEnd(redirectURL string, re
 
 	prms := url.Values{"state": []string{returnedState}, "code": []string{returnedCode}}
 	authorizeCallbackCtx, _ := s.createNewAuthCallbackContext("/api/authorize/callback", prms)
-	redirectedTo, err = s.Application.AuthenticationProviderService().AuthorizeCallback(s.Ctx, authorizeCallbackCtx.State, authorizeCallbackCtx.Code)
+	redirectedTo, err = s.Application.AuthenticationProviderService().AuthorizeCallback(s.Ctx, authorizeCallbackCtx.State, authorizeCallbackCtx.Code,
+		rest.AbsoluteURL(authorizeCallbackCtx.RequestData, client.CallbackAuthorizePath(), nil))
 	require.NotNil(s.T(), redirectedTo)
 	require.NoError(s.T(), err)
 
@@ -0,0 +1,4 @@
+// Package scim contains the code to provision users and groups from a corporate identity management system with the
+// System for Cross-domain Identity Management (SCIM 2.0), as defined in RFC 7643 and RFC 7644: the resources
+// exchanged with the provisioning clients, their filters and their partial modifications.
+package scim
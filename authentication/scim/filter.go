@@ -0,0 +1,336 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+)
+
+// AttributeType the type of the values of an attribute
+type AttributeType int
+
+const (
+	// StringAttribute an attribute with string values
+	StringAttribute AttributeType = iota
+	// BooleanAttribute an attribute with boolean values
+	BooleanAttribute
+	// DateTimeAttribute an attribute with date-time values, in RFC 3339 format
+	DateTimeAttribute
+)
+
+// Attribute an attribute which can be used in filters, and the DB column in which its values are stored
+type Attribute struct {
+	// the SQL expression of the column
+	Column string
+	Type   AttributeType
+	// true if the string values must be compared case-sensitively
+	CaseExact bool
+}
+
+// Attributes the attributes which can be used in filters, by path in lower case (eg: "username", "name.formatted")
+type Attributes map[string]Attribute
+
+// Filter a filter of the resources returned by a list request, eg: `userName eq "john" and active eq true`
+type Filter interface {
+	// SQL returns the SQL condition of the filter along with its arguments, given the columns of the attributes
+	SQL(attributes Attributes) (string, []interface{}, error)
+}
+
+// the comparison operators
+var comparisonOperators = map[string]bool{
+	"eq": true, "ne": true, "co": true, "sw": true, "ew": true, "gt": true, "ge": true, "lt": true, "le": true,
+}
+
+// ParseFilter parses the given filter expression, as defined in RFC 7644 section 3.4.2.2. Value path filters
+// (eg: `emails[type eq "work"]`) are not supported.
+func ParseFilter(s string) (Filter, error) {
+	tokens, err := tokenizeFilter(s)
+	if err != nil {
+		return nil, errors.NewBadParameterErrorFromString("filter", s, err.Error())
+	}
+	p := &filterParser{tokens: tokens}
+	filter, err := p.parseOr()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected '%s'", p.tokens[p.pos].text)
+	}
+	if err != nil {
+		return nil, errors.NewBadParameterErrorFromString("filter", s, err.Error())
+	}
+	return filter, nil
+}
+
+// logicalExpression the conjunction or disjunction of two filters
+type logicalExpression struct {
+	op    string
+	left  Filter
+	right Filter
+}
+
+func (e *logicalExpression) SQL(attributes Attributes) (string, []interface{}, error) {
+	left, leftArgs, err := e.left.SQL(attributes)
+	if err != nil {
+		return "", nil, err
+	}
+	right, rightArgs, err := e.right.SQL(attributes)
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("(%s %s %s)", left, strings.ToUpper(e.op), right), append(leftArgs, rightArgs...), nil
+}
+
+// notExpression the negation of a filter
+type notExpression struct {
+	filter Filter
+}
+
+func (e *notExpression) SQL(attributes Attributes) (string, []interface{}, error) {
+	condition, args, err := e.filter.SQL(attributes)
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("(NOT %s)", condition), args, nil
+}
+
+// attributeExpression the comparison of an attribute with a value, or a test of the presence of the attribute
+type attributeExpression struct {
+	path  string
+	op    string
+	value interface{}
+}
+
+func (e *attributeExpression) SQL(attributes Attributes) (string, []interface{}, error) {
+	attribute, found := attributes[e.path]
+	if !found {
+		return "", nil, errors.NewBadParameterErrorFromString("filter", e.path, "unsupported attribute")
+	}
+	column := attribute.Column
+	switch {
+	case e.op == "pr":
+		if attribute.Type == StringAttribute {
+			return fmt.Sprintf("(%[1]s IS NOT NULL AND %[1]s <> '')", column), nil, nil
+		}
+		return fmt.Sprintf("(%s IS NOT NULL)", column), nil, nil
+	case e.value == nil:
+		switch e.op {
+		case "eq":
+			return fmt.Sprintf("(%s IS NULL)", column), nil, nil
+		case "ne":
+			return fmt.Sprintf("(%s IS NOT NULL)", column), nil, nil
+		}
+		return "", nil, errors.NewBadParameterErrorFromString("filter", e.path, fmt.Sprintf("'%s' cannot be used with null", e.op))
+	}
+
+	switch attribute.Type {
+	case BooleanAttribute:
+		value, ok := e.value.(bool)
+		if !ok {
+			return "", nil, errors.NewBadParameterErrorFromString("filter", e.value, fmt.Sprintf("the %s attribute is a boolean", e.path))
+		}
+		switch e.op {
+		case "eq":
+			return fmt.Sprintf("(%s = ?)", column), []interface{}{value}, nil
+		case "ne":
+			return fmt.Sprintf("(%s <> ?)", column), []interface{}{value}, nil
+		}
+		return "", nil, errors.NewBadParameterErrorFromString("filter", e.path, fmt.Sprintf("'%s' cannot be used with a boolean", e.op))
+	case DateTimeAttribute:
+		s, _ := e.value.(string)
+		value, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return "", nil, errors.NewBadParameterErrorFromString("filter", e.value, fmt.Sprintf("the %s attribute is a date-time", e.path))
+		}
+		if operator, found := orderingOperators[e.op]; found {
+			return fmt.Sprintf("(%s %s ?)", column, operator), []interface{}{value}, nil
+		}
+		return "", nil, errors.NewBadParameterErrorFromString("filter", e.path, fmt.Sprintf("'%s' cannot be used with a date-time", e.op))
+	}
+
+	value, ok := e.value.(string)
+	if !ok {
+		return "", nil, errors.NewBadParameterErrorFromString("filter", e.value, fmt.Sprintf("the %s attribute is a string", e.path))
+	}
+	if !attribute.CaseExact {
+		column = "lower(" + column + ")"
+		value = strings.ToLower(value)
+	}
+	switch e.op {
+	case "co":
+		return fmt.Sprintf("(%s LIKE ?)", column), []interface{}{"%" + gormsupport.EscapeLikePattern(value) + "%"}, nil
+	case "sw":
+		return fmt.Sprintf("(%s LIKE ?)", column), []interface{}{gormsupport.EscapeLikePattern(value) + "%"}, nil
+	case "ew":
+		return fmt.Sprintf("(%s LIKE ?)", column), []interface{}{"%" + gormsupport.EscapeLikePattern(value)}, nil
+	}
+	return fmt.Sprintf("(%s %s ?)", column, orderingOperators[e.op]), []interface{}{value}, nil
+}
+
+// the SQL operators of the comparison operators which apply to all types but booleans
+var orderingOperators = map[string]string{
+	"eq": "=", "ne": "<>", "gt": ">", "ge": ">=", "lt": "<", "le": "<=",
+}
+
+type filterToken struct {
+	text string
+	// true if the token is a quoted string
+	quoted bool
+}
+
+// tokenizeFilter splits the given filter into parentheses, quoted strings and words
+func tokenizeFilter(s string) ([]filterToken, error) {
+	tokens := []filterToken{}
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, filterToken{text: string(c)})
+			i++
+		case c == '[' || c == ']':
+			return nil, fmt.Errorf("value path filters are not supported")
+		case c == '"':
+			// find the closing quote, skipping the escaped characters
+			end := i + 1
+			for ; end < len(s) && s[end] != '"'; end++ {
+				if s[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			var value string
+			if err := json.Unmarshal([]byte(s[i:end+1]), &value); err != nil {
+				return nil, fmt.Errorf("invalid string %s", s[i:end+1])
+			}
+			tokens = append(tokens, filterToken{text: value, quoted: true})
+			i = end + 1
+		default:
+			end := i
+			for ; end < len(s) && !strings.ContainsRune(" \t()[]\"", rune(s[end])); end++ {
+			}
+			tokens = append(tokens, filterToken{text: s[i:end]})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+// filterParser a recursive descent parser of the filters
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peekKeyword(keyword string) bool {
+	return p.pos < len(p.tokens) && !p.tokens[p.pos].quoted && strings.EqualFold(p.tokens[p.pos].text, keyword)
+}
+
+func (p *filterParser) next() (filterToken, error) {
+	if p.pos >= len(p.tokens) {
+		return filterToken{}, fmt.Errorf("unexpected end of filter")
+	}
+	p.pos++
+	return p.tokens[p.pos-1], nil
+}
+
+// parseOr parses `filter ("or" filter)*`
+func (p *filterParser) parseOr() (Filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpression{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseAnd parses `filter ("and" filter)*`, as "and" has precedence over "or"
+func (p *filterParser) parseAnd() (Filter, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("and") {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpression{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseUnary parses `"not" "(" filter ")"`, `"(" filter ")"` or `attrPath op value`
+func (p *filterParser) parseUnary() (Filter, error) {
+	if p.peekKeyword("not") {
+		p.pos++
+		if !p.peekKeyword("(") {
+			return nil, fmt.Errorf("expected '(' after 'not'")
+		}
+		filter, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpression{filter: filter}, nil
+	}
+	if p.peekKeyword("(") {
+		p.pos++
+		filter, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peekKeyword(")") {
+			return nil, fmt.Errorf("expected ')'")
+		}
+		p.pos++
+		return filter, nil
+	}
+	path, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if path.quoted || path.text == ")" {
+		return nil, fmt.Errorf("expected an attribute instead of '%s'", path.text)
+	}
+	op, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	expr := &attributeExpression{path: normalizeAttributePath(path.text), op: strings.ToLower(op.text)}
+	if op.quoted || (expr.op != "pr" && !comparisonOperators[expr.op]) {
+		return nil, fmt.Errorf("unknown operator '%s'", op.text)
+	}
+	if expr.op == "pr" {
+		return expr, nil
+	}
+	value, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case value.quoted:
+		expr.value = value.text
+	case value.text == "true" || value.text == "false":
+		expr.value = value.text == "true"
+	case value.text == "null":
+		expr.value = nil
+	default:
+		var number float64
+		if err := json.Unmarshal([]byte(value.text), &number); err != nil {
+			return nil, fmt.Errorf("invalid value '%s'", value.text)
+		}
+		expr.value = number
+	}
+	return expr, nil
+}
@@ -0,0 +1,92 @@
+package scim_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/scim"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testAttributes = scim.Attributes{
+	"username":     {Column: "identities.username"},
+	"id":           {Column: "identities.id::text", CaseExact: true},
+	"active":       {Column: "(NOT users.banned)", Type: scim.BooleanAttribute},
+	"meta.created": {Column: "identities.created_at", Type: scim.DateTimeAttribute},
+}
+
+func TestParseFilter(t *testing.T) {
+
+	t.Run("ok", func(t *testing.T) {
+		created, err := time.Parse(time.RFC3339, "2011-05-13T04:42:34Z")
+		require.NoError(t, err)
+		tests := []struct {
+			filter    string
+			condition string
+			args      []interface{}
+		}{
+			{`userName eq "John"`, "(lower(identities.username) = ?)", []interface{}{"john"}},
+			{`id eq "ABC"`, "(identities.id::text = ?)", []interface{}{"ABC"}},
+			{`urn:ietf:params:scim:schemas:core:2.0:User:userName sw "j_"`, "(lower(identities.username) LIKE ?)", []interface{}{`j\_%`}},
+			{`userName co "oh" or userName ew "n" and active eq true`,
+				"((lower(identities.username) LIKE ?) OR ((lower(identities.username) LIKE ?) AND ((NOT users.banned) = ?)))",
+				[]interface{}{"%oh%", "%n", true}},
+			{`not (userName pr) AND meta.created gt "2011-05-13T04:42:34Z"`,
+				"((NOT (identities.username IS NOT NULL AND identities.username <> '')) AND (identities.created_at > ?))",
+				[]interface{}{created}},
+			{`(userName eq "a\"b")`, "(lower(identities.username) = ?)", []interface{}{`a"b`}},
+			{`meta.created eq null`, "(identities.created_at IS NULL)", nil},
+		}
+		for _, test := range tests {
+			t.Run(test.filter, func(t *testing.T) {
+				// when
+				filter, err := scim.ParseFilter(test.filter)
+				require.NoError(t, err)
+				condition, args, err := filter.SQL(testAttributes)
+				// then
+				require.NoError(t, err)
+				assert.Equal(t, test.condition, condition)
+				assert.Equal(t, test.args, args)
+			})
+		}
+	})
+
+	t.Run("invalid filter", func(t *testing.T) {
+		for _, filter := range []string{
+			`userName eq`,
+			`userName like "a"`,
+			`emails[type eq "work"]`,
+			`userName eq "a" userName`,
+			`(userName eq "a"`,
+			`userName eq "a`,
+			`not userName eq "a"`,
+		} {
+			t.Run(filter, func(t *testing.T) {
+				_, err := scim.ParseFilter(filter)
+				assert.Error(t, err)
+			})
+		}
+	})
+
+	t.Run("invalid attribute or value", func(t *testing.T) {
+		for _, filter := range []string{
+			`nickName eq "a"`,
+			`active eq "yes"`,
+			`active co true`,
+			`meta.created gt "yesterday"`,
+			`userName eq 42`,
+		} {
+			t.Run(filter, func(t *testing.T) {
+				// given
+				f, err := scim.ParseFilter(filter)
+				require.NoError(t, err)
+				// when
+				_, _, err = f.SQL(testAttributes)
+				// then
+				assert.Error(t, err)
+			})
+		}
+	})
+}
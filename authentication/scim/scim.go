@@ -0,0 +1,366 @@
+package scim
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// SchemaUser the URN of the core schema of the users
+	SchemaUser = "urn:ietf:params:scim:schemas:core:2.0:User"
+	// SchemaGroup the URN of the core schema of the groups
+	SchemaGroup = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	// SchemaServiceProviderConfig the URN of the schema of the configuration of the service provider
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	// SchemaListResponse the URN of the schema of the responses to the list requests
+	SchemaListResponse = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	// SchemaPatchOp the URN of the schema of the PATCH requests
+	SchemaPatchOp = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	// SchemaError the URN of the schema of the error responses
+	SchemaError = "urn:ietf:params:scim:api:messages:2.0:Error"
+
+	// PatchOpAdd adds a value to an attribute (or replaces the value of a single-valued attribute)
+	PatchOpAdd = "add"
+	// PatchOpRemove removes the value of an attribute, or some of the values of a multi-valued attribute
+	PatchOpRemove = "remove"
+	// PatchOpReplace replaces the value of an attribute
+	PatchOpReplace = "replace"
+)
+
+// User the attributes of a user which are managed by the provisioning clients
+type User struct {
+	// the username of the user, unique among all users
+	UserName string
+	// the full name of the user
+	DisplayName string
+	// the primary email address of the user, unique among all users
+	Email string
+	// false if the user is not allowed to log in
+	Active bool
+}
+
+// Group a group of users, managed by the provisioning clients
+type Group struct {
+	// the ID of the identity of the group
+	ID uuid.UUID
+	// the name of the group
+	DisplayName string
+	// the users who are members of the group
+	Members []Member
+	// the time at which the group was created
+	Created time.Time
+	// the time at which the group was last modified
+	LastModified time.Time
+}
+
+// Member a user who is member of a group
+type Member struct {
+	// the ID of the identity of the user
+	ID uuid.UUID
+	// the username of the user
+	Display string
+}
+
+// PatchOperation a partial modification of a user or a group
+type PatchOperation struct {
+	// the operation: "add", "remove" or "replace"
+	Op string
+	// the path of the modified attribute, or an empty string if the value contains the modified attributes
+	Path string
+	// the new value of the attribute, or the values to add or remove
+	Value interface{}
+}
+
+// ApplyPatch applies the given operations to the user. The user is left unchanged if any operation is not valid.
+func (u *User) ApplyPatch(operations []PatchOperation) error {
+	patched := *u
+	for _, operation := range operations {
+		err := applyPatchOperation(operation, patched.applyPatchToAttribute)
+		if err != nil {
+			return err
+		}
+	}
+	*u = patched
+	return nil
+}
+
+func (u *User) applyPatchToAttribute(op string, path string, value interface{}) error {
+	switch {
+	case path == "username":
+		if op == PatchOpRemove {
+			return errors.NewBadParameterErrorFromString("path", path, "the username is required")
+		}
+		return stringValue(path, value, &u.UserName)
+	case path == "displayname" || path == "name.formatted":
+		if op == PatchOpRemove {
+			u.DisplayName = ""
+			return nil
+		}
+		return stringValue(path, value, &u.DisplayName)
+	case path == "name":
+		if op == PatchOpRemove {
+			u.DisplayName = ""
+			return nil
+		}
+		name, ok := value.(map[string]interface{})
+		if !ok {
+			return errors.NewBadParameterErrorFromString("value", value, "the name must be an object")
+		}
+		u.DisplayName = FullName("", stringAttribute(name, "formatted"), stringAttribute(name, "givenName"), stringAttribute(name, "familyName"))
+		return nil
+	case path == "emails" || strings.HasPrefix(path, "emails[") || strings.HasPrefix(path, "emails."):
+		if op == PatchOpRemove {
+			return errors.NewBadParameterErrorFromString("path", path, "the email address is required")
+		}
+		if email, ok := value.(string); ok {
+			u.Email = email
+			return nil
+		}
+		emails, ok := value.([]interface{})
+		if !ok {
+			return errors.NewBadParameterErrorFromString("value", value, "the emails must be an array")
+		}
+		email := PrimaryEmail(emails)
+		if email == "" {
+			return errors.NewBadParameterErrorFromString("value", value, "no email address")
+		}
+		u.Email = email
+		return nil
+	case path == "active":
+		if op == PatchOpRemove {
+			return errors.NewBadParameterErrorFromString("path", path, "the active attribute is required")
+		}
+		// some clients send the boolean values as strings
+		switch v := value.(type) {
+		case bool:
+			u.Active = v
+		case string:
+			if !strings.EqualFold(v, "true") && !strings.EqualFold(v, "false") {
+				return errors.NewBadParameterErrorFromString("value", value, "the active attribute must be a boolean")
+			}
+			u.Active = strings.EqualFold(v, "true")
+		default:
+			return errors.NewBadParameterErrorFromString("value", value, "the active attribute must be a boolean")
+		}
+		return nil
+	}
+	return errors.NewBadParameterErrorFromString("path", path, "unsupported attribute")
+}
+
+// ApplyPatch applies the given operations to the group. The group is left unchanged if any operation is not valid.
+func (g *Group) ApplyPatch(operations []PatchOperation) error {
+	patched := *g
+	patched.Members = append([]Member{}, g.Members...)
+	for _, operation := range operations {
+		err := applyPatchOperation(operation, patched.applyPatchToAttribute)
+		if err != nil {
+			return err
+		}
+	}
+	*g = patched
+	return nil
+}
+
+func (g *Group) applyPatchToAttribute(op string, path string, value interface{}) error {
+	switch {
+	case path == "displayname":
+		if op == PatchOpRemove {
+			return errors.NewBadParameterErrorFromString("path", path, "the display name is required")
+		}
+		return stringValue(path, value, &g.DisplayName)
+	case path == "members":
+		var members []Member
+		if value != nil || op != PatchOpRemove {
+			var err error
+			members, err = memberValues(value)
+			if err != nil {
+				return err
+			}
+		}
+		switch op {
+		case PatchOpAdd:
+			for _, member := range members {
+				if !g.hasMember(member.ID) {
+					g.Members = append(g.Members, member)
+				}
+			}
+		case PatchOpReplace:
+			g.Members = members
+		case PatchOpRemove:
+			if value == nil {
+				// without a value, all the members are removed
+				g.Members = []Member{}
+			}
+			for _, member := range members {
+				g.removeMember(member.ID)
+			}
+		}
+		return nil
+	case strings.HasPrefix(path, "members["):
+		// eg: `members[value eq "2819c223-7f76-453a-919d-413861904646"]`
+		if op != PatchOpRemove {
+			return errors.NewBadParameterErrorFromString("path", path, "only the remove operation is supported with a value filter")
+		}
+		id, err := memberIDFromValuePath(path)
+		if err != nil {
+			return err
+		}
+		g.removeMember(id)
+		return nil
+	}
+	return errors.NewBadParameterErrorFromString("path", path, "unsupported attribute")
+}
+
+func (g *Group) hasMember(id uuid.UUID) bool {
+	for _, member := range g.Members {
+		if member.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Group) removeMember(id uuid.UUID) {
+	members := make([]Member, 0, len(g.Members))
+	for _, member := range g.Members {
+		if member.ID != id {
+			members = append(members, member)
+		}
+	}
+	g.Members = members
+}
+
+// applyPatchOperation applies a single operation with the given function, once per modified attribute: operations
+// without a path carry an object whose keys are the paths of the modified attributes
+func applyPatchOperation(operation PatchOperation, apply func(op string, path string, value interface{}) error) error {
+	op := strings.ToLower(operation.Op)
+	if op != PatchOpAdd && op != PatchOpRemove && op != PatchOpReplace {
+		return errors.NewBadParameterError("op", operation.Op).Expected([]string{PatchOpAdd, PatchOpRemove, PatchOpReplace})
+	}
+	if operation.Path != "" {
+		return apply(op, normalizeAttributePath(operation.Path), operation.Value)
+	}
+	if op == PatchOpRemove {
+		return errors.NewBadParameterErrorFromString("path", operation.Path, "the path is required to remove an attribute")
+	}
+	attributes, ok := operation.Value.(map[string]interface{})
+	if !ok {
+		return errors.NewBadParameterErrorFromString("value", operation.Value, "the value must be an object when no path is given")
+	}
+	for path, value := range attributes {
+		if err := apply(op, normalizeAttributePath(path), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeAttributePath returns the given attribute path in lower case, without the URN of the core schemas, as the
+// attribute names are case-insensitive
+func normalizeAttributePath(path string) string {
+	path = strings.ToLower(strings.TrimSpace(path))
+	for _, schema := range []string{SchemaUser, SchemaGroup} {
+		path = strings.TrimPrefix(path, strings.ToLower(schema)+":")
+	}
+	return path
+}
+
+// memberIDFromValuePath returns the ID of the member selected by the given value path, eg: `members[value eq "..."]`
+func memberIDFromValuePath(path string) (uuid.UUID, error) {
+	if !strings.HasSuffix(path, "]") {
+		return uuid.Nil, errors.NewBadParameterErrorFromString("path", path, "invalid value filter")
+	}
+	filter, err := ParseFilter(path[len("members[") : len(path)-1])
+	if err != nil {
+		return uuid.Nil, errors.NewBadParameterErrorFromString("path", path, err.Error())
+	}
+	expr, ok := filter.(*attributeExpression)
+	if !ok || expr.path != "value" || expr.op != "eq" {
+		return uuid.Nil, errors.NewBadParameterErrorFromString("path", path, "only the members with a given value can be selected")
+	}
+	value, _ := expr.value.(string)
+	id, err := uuid.FromString(value)
+	if err != nil {
+		return uuid.Nil, errors.NewBadParameterErrorFromString("path", path, "the value of the member is not a valid ID")
+	}
+	return id, nil
+}
+
+// memberValues returns the members in the given value, which must be an array of objects with a "value" attribute
+func memberValues(value interface{}) ([]Member, error) {
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil, errors.NewBadParameterErrorFromString("value", value, "the members must be an array")
+	}
+	members := make([]Member, 0, len(values))
+	for _, v := range values {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.NewBadParameterErrorFromString("value", v, "a member must be an object")
+		}
+		id, err := uuid.FromString(stringAttribute(m, "value"))
+		if err != nil {
+			return nil, errors.NewBadParameterErrorFromString("value", v, "the value of the member is not a valid ID")
+		}
+		members = append(members, Member{ID: id, Display: stringAttribute(m, "display")})
+	}
+	return members, nil
+}
+
+// PrimaryEmail returns the email address marked as primary in the given array of email objects,
+// or the first one if none is marked as primary
+func PrimaryEmail(emails []interface{}) string {
+	first := ""
+	for _, e := range emails {
+		email, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		value := stringAttribute(email, "value")
+		if primary, _ := email["primary"].(bool); primary && value != "" {
+			return value
+		}
+		if first == "" {
+			first = value
+		}
+	}
+	return first
+}
+
+// FullName returns the full name of a user given by the provisioning clients, from its display name,
+// or from the formatted name or else from the given and family names
+func FullName(displayName, formatted, givenName, familyName string) string {
+	if formatted != "" {
+		return formatted
+	}
+	if givenName != "" || familyName != "" {
+		return strings.TrimSpace(givenName + " " + familyName)
+	}
+	return displayName
+}
+
+// stringAttribute returns the string value of the given attribute of an object, or an empty string.
+// The attribute names are case-insensitive.
+func stringAttribute(object map[string]interface{}, name string) string {
+	for key, value := range object {
+		if strings.EqualFold(key, name) {
+			s, _ := value.(string)
+			return s
+		}
+	}
+	return ""
+}
+
+func stringValue(path string, value interface{}, target *string) error {
+	s, ok := value.(string)
+	if !ok {
+		return errors.NewBadParameterErrorFromString("value", value, fmt.Sprintf("the %s attribute must be a string", path))
+	}
+	*target = s
+	return nil
+}
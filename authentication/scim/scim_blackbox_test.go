@@ -0,0 +1,146 @@
+package scim_test
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/scim"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserApplyPatch(t *testing.T) {
+
+	t.Run("with paths", func(t *testing.T) {
+		// given
+		user := scim.User{UserName: "jdoe", DisplayName: "John Doe", Email: "jdoe@example.com", Active: true}
+		// when
+		err := user.ApplyPatch([]scim.PatchOperation{
+			{Op: "Replace", Path: "userName", Value: "john.doe"},
+			{Op: "replace", Path: `emails[type eq "work"].value`, Value: "john.doe@example.com"},
+			{Op: "replace", Path: "active", Value: false},
+		})
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, scim.User{UserName: "john.doe", DisplayName: "John Doe", Email: "john.doe@example.com", Active: false}, user)
+	})
+
+	t.Run("without path", func(t *testing.T) {
+		// given
+		user := scim.User{UserName: "jdoe", DisplayName: "John Doe", Email: "jdoe@example.com", Active: false}
+		// when
+		err := user.ApplyPatch([]scim.PatchOperation{
+			{Op: "replace", Value: map[string]interface{}{
+				"active": "True",
+				"name":   map[string]interface{}{"givenName": "Jane", "familyName": "Doe"},
+				"emails": []interface{}{
+					map[string]interface{}{"value": "jane@home.com"},
+					map[string]interface{}{"value": "jane@example.com", "primary": true},
+				},
+			}},
+		})
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, scim.User{UserName: "jdoe", DisplayName: "Jane Doe", Email: "jane@example.com", Active: true}, user)
+	})
+
+	t.Run("invalid operations", func(t *testing.T) {
+		for name, operation := range map[string]scim.PatchOperation{
+			"unknown op":          {Op: "move", Path: "userName", Value: "jdoe"},
+			"remove username":     {Op: "remove", Path: "userName"},
+			"remove emails":       {Op: "remove", Path: "emails"},
+			"unknown attribute":   {Op: "replace", Path: "nickName", Value: "jd"},
+			"invalid active":      {Op: "replace", Path: "active", Value: "yes"},
+			"remove without path": {Op: "remove"},
+		} {
+			t.Run(name, func(t *testing.T) {
+				// given
+				user := scim.User{UserName: "jdoe", DisplayName: "John Doe", Email: "jdoe@example.com", Active: true}
+				// when
+				err := user.ApplyPatch([]scim.PatchOperation{
+					{Op: "replace", Path: "displayName", Value: "changed"},
+					operation,
+				})
+				// then
+				assert.Error(t, err)
+				assert.Equal(t, "John Doe", user.DisplayName, "the user should not be changed")
+			})
+		}
+	})
+}
+
+func TestGroupApplyPatch(t *testing.T) {
+	john := uuid.NewV4()
+	jane := uuid.NewV4()
+	jim := uuid.NewV4()
+
+	memberValue := func(ids ...uuid.UUID) []interface{} {
+		values := make([]interface{}, len(ids))
+		for i, id := range ids {
+			values[i] = map[string]interface{}{"value": id.String()}
+		}
+		return values
+	}
+	memberIDs := func(group scim.Group) []uuid.UUID {
+		ids := []uuid.UUID{}
+		for _, member := range group.Members {
+			ids = append(ids, member.ID)
+		}
+		return ids
+	}
+
+	t.Run("add and remove members", func(t *testing.T) {
+		// given
+		group := scim.Group{DisplayName: "developers", Members: []scim.Member{{ID: john}}}
+		// when
+		err := group.ApplyPatch([]scim.PatchOperation{
+			{Op: "add", Path: "members", Value: memberValue(john, jane, jim)},
+			{Op: "remove", Path: `members[value eq "` + jane.String() + `"]`},
+			{Op: "remove", Path: "members", Value: memberValue(jim)},
+			{Op: "replace", Value: map[string]interface{}{"displayName": "engineers"}},
+		})
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "engineers", group.DisplayName)
+		assert.Equal(t, []uuid.UUID{john}, memberIDs(group))
+	})
+
+	t.Run("replace and remove all members", func(t *testing.T) {
+		// given
+		group := scim.Group{DisplayName: "developers", Members: []scim.Member{{ID: john}}}
+		// when
+		err := group.ApplyPatch([]scim.PatchOperation{{Op: "replace", Path: "members", Value: memberValue(jane, jim)}})
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{jane, jim}, memberIDs(group))
+		// when
+		err = group.ApplyPatch([]scim.PatchOperation{{Op: "remove", Path: "members"}})
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, group.Members)
+	})
+
+	t.Run("invalid operations", func(t *testing.T) {
+		for name, operation := range map[string]scim.PatchOperation{
+			"invalid member ID":   {Op: "add", Path: "members", Value: []interface{}{map[string]interface{}{"value": "foo"}}},
+			"invalid members":     {Op: "add", Path: "members", Value: "foo"},
+			"add with filter":     {Op: "add", Path: `members[value eq "` + jane.String() + `"]`, Value: "foo"},
+			"invalid filter":      {Op: "remove", Path: `members[display eq "jane"]`},
+			"remove display name": {Op: "remove", Path: "displayName"},
+		} {
+			t.Run(name, func(t *testing.T) {
+				// given
+				group := scim.Group{DisplayName: "developers", Members: []scim.Member{{ID: john}}}
+				// when
+				err := group.ApplyPatch([]scim.PatchOperation{
+					{Op: "remove", Path: "members"},
+					operation,
+				})
+				// then
+				assert.Error(t, err)
+				assert.Equal(t, []uuid.UUID{john}, memberIDs(group), "the group should not be changed")
+			})
+		}
+	})
+}
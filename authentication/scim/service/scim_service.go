@@ -0,0 +1,594 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application/service"
+	"github.com/fabric8-services/fabric8-auth/application/service/base"
+	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
+	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/authentication/scim"
+	"github.com/fabric8-services/fabric8-auth/authorization"
+	resource "github.com/fabric8-services/fabric8-auth/authorization/resource/repository"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/jinzhu/gorm"
+	uuid "github.com/satori/go.uuid"
+)
+
+// scimBanReason the reason recorded with the bans of the users deactivated by the provisioning clients
+const scimBanReason = "deactivated by the SCIM provisioning client"
+
+// userAttributes the attributes of the users which can be used in the filters, and their columns
+var userAttributes = scim.Attributes{
+	"id":                {Column: "identities.id::text", CaseExact: true},
+	"username":          {Column: "identities.username"},
+	"displayname":       {Column: "users.full_name"},
+	"name.formatted":    {Column: "users.full_name"},
+	"emails":            {Column: "users.email"},
+	"emails.value":      {Column: "users.email"},
+	"active":            {Column: "(NOT users.banned)", Type: scim.BooleanAttribute},
+	"meta.created":      {Column: "identities.created_at", Type: scim.DateTimeAttribute},
+	"meta.lastmodified": {Column: "users.updated_at", Type: scim.DateTimeAttribute},
+}
+
+// groupAttributes the attributes of the groups which can be used in the filters, and their columns
+var groupAttributes = scim.Attributes{
+	"id":                {Column: "identities.id::text", CaseExact: true},
+	"displayname":       {Column: "resource.name"},
+	"meta.created":      {Column: "identities.created_at", Type: scim.DateTimeAttribute},
+	"meta.lastmodified": {Column: "resource.updated_at", Type: scim.DateTimeAttribute},
+}
+
+// SCIMServiceConfiguration the configuration for the SCIM service
+type SCIMServiceConfiguration interface {
+	GetSCIMProvisioningCluster() string
+}
+
+// NewSCIMService creates a new service to provision the users and groups with SCIM
+func NewSCIMService(ctx servicecontext.ServiceContext, config SCIMServiceConfiguration) service.SCIMService {
+	return &scimServiceImpl{
+		BaseService: base.NewBaseService(ctx),
+		config:      config,
+	}
+}
+
+// scimServiceImpl implements the SCIMService. The SCIM users are the users with an identity of the default identity
+// provider, identified by the ID of that identity. The SCIM groups are the identities of the `identity/group`
+// resources, whose members are recorded as memberships.
+type scimServiceImpl struct {
+	base.BaseService
+	config SCIMServiceConfiguration
+}
+
+// ListUsers returns the users matching the given filter, from the given offset, along with the total number of
+// matching users
+func (s *scimServiceImpl) ListUsers(ctx context.Context, filter string, offset int, limit int) ([]account.Identity, int, error) {
+	scope, err := filterScope(filter, userAttributes, scimUsers)
+	if err != nil {
+		return nil, 0, err
+	}
+	count, err := s.Repositories().Identities().Count(ctx, scope)
+	if err != nil {
+		return nil, 0, err
+	}
+	identities, err := s.Repositories().Identities().Query(scope, page(offset, limit), account.IdentityWithUser())
+	if err != nil {
+		return nil, 0, err
+	}
+	return identities, count, nil
+}
+
+// LoadUser returns the user with the given identity ID
+func (s *scimServiceImpl) LoadUser(ctx context.Context, id uuid.UUID) (*account.Identity, error) {
+	identities, err := s.Repositories().Identities().Query(scimUsers, filterByID(id), account.IdentityWithUser())
+	if err != nil {
+		return nil, err
+	}
+	if len(identities) == 0 {
+		return nil, errors.NewNotFoundError("user", id.String())
+	}
+	return &identities[0], nil
+}
+
+// CreateUser creates a new user, and links it to the configured cluster, if any
+func (s *scimServiceImpl) CreateUser(ctx context.Context, user scim.User) (*account.Identity, error) {
+	if err := validateUser(user); err != nil {
+		return nil, err
+	}
+	cluster := s.config.GetSCIMProvisioningCluster()
+	u := &account.User{
+		ID:            uuid.NewV4(),
+		Email:         user.Email,
+		EmailVerified: true,
+		FullName:      user.DisplayName,
+		Cluster:       cluster,
+		FeatureLevel:  account.DefaultFeatureLevel,
+	}
+	identity := &account.Identity{
+		ID:           uuid.NewV4(),
+		Username:     user.UserName,
+		ProviderType: account.DefaultIDP,
+		UserID:       account.NullUUID{UUID: u.ID, Valid: true},
+	}
+	err := s.ExecuteInTransaction(func() error {
+		if err := s.checkUniqueness(ctx, nil, user); err != nil {
+			return err
+		}
+		if err := s.Repositories().Users().Create(ctx, u); err != nil {
+			return err
+		}
+		if err := s.Repositories().Identities().Create(ctx, identity); err != nil {
+			return err
+		}
+		identity.User = *u
+		if !user.Active {
+			return s.setActive(ctx, identity, false)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if cluster != "" {
+		err = s.Services().ClusterService().LinkIdentityToCluster(ctx, identity.ID, cluster)
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"err":         err,
+				"identity_id": identity.ID,
+				"cluster_url": cluster,
+			}, "failed to link the provisioned identity to the cluster")
+			// delete the user so that the provisioning client can repeat the creation
+			if err := s.Services().UserService().HardDeleteUser(ctx, *identity); err != nil {
+				return nil, err
+			}
+			return nil, err
+		}
+	}
+	log.Info(ctx, map[string]interface{}{
+		"identity_id": identity.ID,
+		"username":    identity.Username,
+	}, "user provisioned")
+	return s.LoadUser(ctx, identity.ID)
+}
+
+// ReplaceUser replaces all the attributes of the user with the given identity ID
+func (s *scimServiceImpl) ReplaceUser(ctx context.Context, id uuid.UUID, user scim.User) (*account.Identity, error) {
+	if err := validateUser(user); err != nil {
+		return nil, err
+	}
+	err := s.ExecuteInTransaction(func() error {
+		identity, err := s.LoadUser(ctx, id)
+		if err != nil {
+			return err
+		}
+		return s.updateUser(ctx, identity, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.LoadUser(ctx, id)
+}
+
+// PatchUser applies the given operations to the user with the given identity ID
+func (s *scimServiceImpl) PatchUser(ctx context.Context, id uuid.UUID, operations []scim.PatchOperation) (*account.Identity, error) {
+	err := s.ExecuteInTransaction(func() error {
+		identity, err := s.LoadUser(ctx, id)
+		if err != nil {
+			return err
+		}
+		user := scim.User{
+			UserName:    identity.Username,
+			DisplayName: identity.User.FullName,
+			Email:       identity.User.Email,
+			Active:      !identity.User.Banned,
+		}
+		if err := user.ApplyPatch(operations); err != nil {
+			return err
+		}
+		if err := validateUser(user); err != nil {
+			return err
+		}
+		return s.updateUser(ctx, identity, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.LoadUser(ctx, id)
+}
+
+// DeleteUser deactivates the account of the user with the given identity ID
+func (s *scimServiceImpl) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	identity, err := s.LoadUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	_, err = s.Services().UserService().DeactivateUser(ctx, identity.Username)
+	return err
+}
+
+// updateUser saves the given attributes of the user, and bans the user or lifts the ban if the active attribute changed
+func (s *scimServiceImpl) updateUser(ctx context.Context, identity *account.Identity, user scim.User) error {
+	if err := s.checkUniqueness(ctx, identity, user); err != nil {
+		return err
+	}
+	// the provisioning clients are not subject to the cooldown period between 2 changes of username
+	if err := s.Services().UserService().ChangeUsername(ctx, identity, user.UserName, false); err != nil {
+		return err
+	}
+	identity.User.FullName = user.DisplayName
+	if identity.User.Email != user.Email {
+		identity.User.Email = user.Email
+		identity.User.EmailVerified = true
+	}
+	if err := s.Repositories().Identities().Save(ctx, identity); err != nil {
+		return err
+	}
+	if err := s.Repositories().Users().Save(ctx, &identity.User); err != nil {
+		return err
+	}
+	if user.Active == identity.User.Banned {
+		return s.setActive(ctx, identity, user.Active)
+	}
+	return nil
+}
+
+// setActive lifts the ban of the user if active is true, or bans the user otherwise. Only the bans recorded by the
+// provisioning clients are lifted: the user can not be reactivated while banned for another reason.
+func (s *scimServiceImpl) setActive(ctx context.Context, identity *account.Identity, active bool) error {
+	if active {
+		return s.Services().UserService().LiftBansWithReason(ctx, identity.User, scimBanReason)
+	}
+	reason := scimBanReason
+	_, err := s.Services().UserService().BanUserWithReason(ctx, identity.Username, account.UserBan{
+		ReasonCategory: account.UserBanCategoryOther,
+		Reason:         &reason,
+	})
+	return err
+}
+
+// checkUniqueness verifies that no other user than the given one (if any) has the same username or email
+func (s *scimServiceImpl) checkUniqueness(ctx context.Context, identity *account.Identity, user scim.User) error {
+	var identityID, userID uuid.UUID
+	if identity != nil {
+		identityID, userID = identity.ID, identity.User.ID
+	}
+	identities, err := s.Repositories().Identities().Query(
+		account.IdentityFilterByCurrentUsername(user.UserName),
+		account.IdentityFilterByProviderType(account.DefaultIDP))
+	if err != nil {
+		return err
+	}
+	if len(identities) > 0 && identities[0].ID != identityID {
+		return errors.NewVersionConflictError(fmt.Sprintf("a user with the username '%s' already exists", user.UserName))
+	}
+	reservation, err := s.Repositories().UsernameHistory().LoadReservation(ctx, user.UserName, time.Now())
+	if err != nil {
+		if notFound, _ := errors.IsNotFoundError(err); !notFound {
+			return err
+		}
+	} else if reservation.IdentityID != identityID {
+		return errors.NewVersionConflictError(fmt.Sprintf("the username '%s' is reserved to a previous owner", user.UserName))
+	}
+	users, err := s.Repositories().Users().Query(account.UserFilterByEmail(user.Email))
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		if u.ID != userID {
+			return errors.NewVersionConflictError(fmt.Sprintf("a user with the email '%s' already exists", user.Email))
+		}
+	}
+	return nil
+}
+
+// ListGroups returns the groups matching the given filter, from the given offset, along with the total number of
+// matching groups
+func (s *scimServiceImpl) ListGroups(ctx context.Context, filter string, offset int, limit int) ([]scim.Group, int, error) {
+	groupType, err := s.Repositories().ResourceTypeRepository().Lookup(ctx, authorization.IdentityResourceTypeGroup)
+	if err != nil {
+		return nil, 0, err
+	}
+	scope, err := filterScope(filter, groupAttributes, scimGroups(groupType.ResourceTypeID))
+	if err != nil {
+		return nil, 0, err
+	}
+	count, err := s.Repositories().Identities().Count(ctx, scope)
+	if err != nil {
+		return nil, 0, err
+	}
+	identities, err := s.Repositories().Identities().Query(scope, page(offset, limit), withIdentityResource)
+	if err != nil {
+		return nil, 0, err
+	}
+	groups := make([]scim.Group, len(identities))
+	for i, identity := range identities {
+		group, err := s.toGroup(ctx, identity)
+		if err != nil {
+			return nil, 0, err
+		}
+		groups[i] = *group
+	}
+	return groups, count, nil
+}
+
+// LoadGroup returns the group with the given identity ID, along with its members
+func (s *scimServiceImpl) LoadGroup(ctx context.Context, id uuid.UUID) (*scim.Group, error) {
+	identity, err := s.loadGroupIdentity(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.toGroup(ctx, *identity)
+}
+
+// CreateGroup creates a new group with the given name and members
+func (s *scimServiceImpl) CreateGroup(ctx context.Context, group scim.Group) (*scim.Group, error) {
+	if group.DisplayName == "" {
+		return nil, errors.NewBadParameterErrorFromString("displayName", group.DisplayName, "the display name is required")
+	}
+	identity := &account.Identity{}
+	err := s.ExecuteInTransaction(func() error {
+		groupType, err := s.Repositories().ResourceTypeRepository().Lookup(ctx, authorization.IdentityResourceTypeGroup)
+		if err != nil {
+			return err
+		}
+		if err := s.checkGroupUniqueness(ctx, uuid.Nil, groupType.ResourceTypeID, group.DisplayName); err != nil {
+			return err
+		}
+		res := &resource.Resource{
+			Name:           group.DisplayName,
+			ResourceType:   *groupType,
+			ResourceTypeID: groupType.ResourceTypeID,
+		}
+		if err := s.Repositories().ResourceRepository().Create(ctx, res); err != nil {
+			return err
+		}
+		identity.IdentityResourceID = sql.NullString{String: res.ResourceID, Valid: true}
+		if err := s.Repositories().Identities().Create(ctx, identity); err != nil {
+			return err
+		}
+		return s.updateMembers(ctx, identity.ID, nil, group.Members)
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"group_id": identity.ID,
+		"name":     group.DisplayName,
+	}, "group provisioned")
+	return s.LoadGroup(ctx, identity.ID)
+}
+
+// ReplaceGroup replaces the name and the members of the group with the given identity ID
+func (s *scimServiceImpl) ReplaceGroup(ctx context.Context, id uuid.UUID, group scim.Group) (*scim.Group, error) {
+	err := s.ExecuteInTransaction(func() error {
+		current, err := s.LoadGroup(ctx, id)
+		if err != nil {
+			return err
+		}
+		return s.updateGroup(ctx, current, group)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.LoadGroup(ctx, id)
+}
+
+// PatchGroup applies the given operations to the group with the given identity ID
+func (s *scimServiceImpl) PatchGroup(ctx context.Context, id uuid.UUID, operations []scim.PatchOperation) (*scim.Group, error) {
+	err := s.ExecuteInTransaction(func() error {
+		current, err := s.LoadGroup(ctx, id)
+		if err != nil {
+			return err
+		}
+		group := *current
+		if err := group.ApplyPatch(operations); err != nil {
+			return err
+		}
+		return s.updateGroup(ctx, current, group)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.LoadGroup(ctx, id)
+}
+
+// DeleteGroup removes all the members of the group with the given identity ID, and deletes the group
+func (s *scimServiceImpl) DeleteGroup(ctx context.Context, id uuid.UUID) error {
+	return s.ExecuteInTransaction(func() error {
+		identity, err := s.loadGroupIdentity(ctx, id)
+		if err != nil {
+			return err
+		}
+		members, err := s.loadMembers(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := s.updateMembers(ctx, id, members, nil); err != nil {
+			return err
+		}
+		return s.Services().ResourceService().Delete(ctx, identity.IdentityResourceID.String)
+	})
+}
+
+// updateGroup renames the group and updates its members, from the current state to the given one
+func (s *scimServiceImpl) updateGroup(ctx context.Context, current *scim.Group, group scim.Group) error {
+	if group.DisplayName == "" {
+		return errors.NewBadParameterErrorFromString("displayName", group.DisplayName, "the display name is required")
+	}
+	if group.DisplayName != current.DisplayName {
+		identity, err := s.loadGroupIdentity(ctx, current.ID)
+		if err != nil {
+			return err
+		}
+		if err := s.checkGroupUniqueness(ctx, current.ID, identity.IdentityResource.ResourceTypeID, group.DisplayName); err != nil {
+			return err
+		}
+		identity.IdentityResource.Name = group.DisplayName
+		if err := s.Repositories().ResourceRepository().Save(ctx, &identity.IdentityResource); err != nil {
+			return err
+		}
+	}
+	return s.updateMembers(ctx, current.ID, current.Members, group.Members)
+}
+
+// updateMembers adds and removes the memberships of the group, from the current members to the given ones.
+// The new members must be users.
+func (s *scimServiceImpl) updateMembers(ctx context.Context, groupID uuid.UUID, current []scim.Member, members []scim.Member) error {
+	currentIDs := make(map[uuid.UUID]bool, len(current))
+	for _, member := range current {
+		currentIDs[member.ID] = true
+	}
+	memberIDs := make(map[uuid.UUID]bool, len(members))
+	for _, member := range members {
+		memberIDs[member.ID] = true
+		if currentIDs[member.ID] {
+			continue
+		}
+		if _, err := s.LoadUser(ctx, member.ID); err != nil {
+			if notFound, _ := errors.IsNotFoundError(err); notFound {
+				return errors.NewBadParameterErrorFromString("members", member.ID, "the member is not a user")
+			}
+			return err
+		}
+		if err := s.Repositories().Identities().AddMember(ctx, groupID, member.ID); err != nil {
+			return err
+		}
+		currentIDs[member.ID] = true
+	}
+	for _, member := range current {
+		if !memberIDs[member.ID] {
+			if err := s.Repositories().Identities().RemoveMember(ctx, groupID, member.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkGroupUniqueness verifies that no other group than the one with the given identity ID has the same name
+func (s *scimServiceImpl) checkGroupUniqueness(ctx context.Context, id uuid.UUID, groupTypeID uuid.UUID, name string) error {
+	identities, err := s.Repositories().Identities().Query(scimGroups(groupTypeID), func(db *gorm.DB) *gorm.DB {
+		return db.Where("resource.name = ? AND identities.id <> ?", name, id)
+	})
+	if err != nil {
+		return err
+	}
+	if len(identities) > 0 {
+		return errors.NewVersionConflictError(fmt.Sprintf("a group with the name '%s' already exists", name))
+	}
+	return nil
+}
+
+// loadGroupIdentity returns the identity of the group with the given ID, along with its resource
+func (s *scimServiceImpl) loadGroupIdentity(ctx context.Context, id uuid.UUID) (*account.Identity, error) {
+	groupType, err := s.Repositories().ResourceTypeRepository().Lookup(ctx, authorization.IdentityResourceTypeGroup)
+	if err != nil {
+		return nil, err
+	}
+	identities, err := s.Repositories().Identities().Query(scimGroups(groupType.ResourceTypeID), filterByID(id), withIdentityResource)
+	if err != nil {
+		return nil, err
+	}
+	if len(identities) == 0 {
+		return nil, errors.NewNotFoundError("group", id.String())
+	}
+	return &identities[0], nil
+}
+
+// loadMembers returns the members of the group with the given identity ID, ordered by username
+func (s *scimServiceImpl) loadMembers(ctx context.Context, groupID uuid.UUID) ([]scim.Member, error) {
+	identities, err := s.Repositories().Identities().Query(func(db *gorm.DB) *gorm.DB {
+		return db.Where("id IN (SELECT member_id FROM membership WHERE member_of = ?)", groupID).Order("username")
+	})
+	if err != nil {
+		return nil, err
+	}
+	members := make([]scim.Member, len(identities))
+	for i, identity := range identities {
+		members[i] = scim.Member{ID: identity.ID, Display: identity.Username}
+	}
+	return members, nil
+}
+
+func (s *scimServiceImpl) toGroup(ctx context.Context, identity account.Identity) (*scim.Group, error) {
+	members, err := s.loadMembers(ctx, identity.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &scim.Group{
+		ID:           identity.ID,
+		DisplayName:  identity.IdentityResource.Name,
+		Members:      members,
+		Created:      identity.CreatedAt,
+		LastModified: identity.IdentityResource.UpdatedAt,
+	}, nil
+}
+
+func validateUser(user scim.User) error {
+	if user.UserName == "" {
+		return errors.NewBadParameterErrorFromString("userName", user.UserName, "the username is required")
+	}
+	if user.Email == "" {
+		return errors.NewBadParameterErrorFromString("emails", user.Email, "an email address is required")
+	}
+	return nil
+}
+
+// scimUsers is a gorm filter for the users with an identity of the default identity provider. Only the
+// columns of the identities are selected, as the users are joined.
+func scimUsers(db *gorm.DB) *gorm.DB {
+	return db.Select("identities.*").Joins("JOIN users ON users.id = identities.user_id AND users.deleted_at IS NULL").
+		Where("identities.provider_type = ?", account.DefaultIDP)
+}
+
+// scimGroups returns a gorm filter for the identities of the resources of the group type. Only the columns of the
+// identities are selected, as the resources are joined.
+func scimGroups(groupTypeID uuid.UUID) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Select("identities.*").Joins("JOIN resource ON resource.resource_id = identities.identity_resource_id AND resource.deleted_at IS NULL").
+			Where("resource.resource_type_id = ?", groupTypeID)
+	}
+}
+
+// filterByID is a gorm filter for the identity with the given ID, which does not conflict with the joined tables
+func filterByID(id uuid.UUID) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("identities.id = ?", id)
+	}
+}
+
+// withIdentityResource is a gorm filter for preloading the resource of the identities
+func withIdentityResource(db *gorm.DB) *gorm.DB {
+	return db.Preload("IdentityResource")
+}
+
+// filterScope parses the given SCIM filter, and returns a gorm filter which combines it with the given base filter
+func filterScope(filter string, attributes scim.Attributes, base func(db *gorm.DB) *gorm.DB) (func(db *gorm.DB) *gorm.DB, error) {
+	if filter == "" {
+		return base, nil
+	}
+	f, err := scim.ParseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	condition, args, err := f.SQL(attributes)
+	if err != nil {
+		return nil, err
+	}
+	return func(db *gorm.DB) *gorm.DB {
+		return base(db).Where(condition, args...)
+	}, nil
+}
+
+// page returns a gorm filter for a page of identities, ordered by creation time
+func page(offset int, limit int) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Order("identities.created_at, identities.id").Offset(offset).Limit(limit)
+	}
+}
@@ -0,0 +1,480 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application/service"
+	"github.com/fabric8-services/fabric8-auth/application/service/factory"
+	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/authentication/scim"
+	scimservice "github.com/fabric8-services/fabric8-auth/authentication/scim/service"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	"github.com/fabric8-services/fabric8-auth/rest"
+	testsupport "github.com/fabric8-services/fabric8-auth/test"
+	testservice "github.com/fabric8-services/fabric8-auth/test/generated/application/service"
+
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestSCIMService(t *testing.T) {
+	suite.Run(t, &scimServiceBlackboxTestSuite{
+		DBTestSuite: gormtestsupport.NewDBTestSuite(),
+	})
+}
+
+type scimServiceBlackboxTestSuite struct {
+	gormtestsupport.DBTestSuite
+	clusterServiceMock *testservice.ClusterServiceMock
+}
+
+type scimConfig struct {
+	cluster string
+}
+
+func (c scimConfig) GetSCIMProvisioningCluster() string {
+	return c.cluster
+}
+
+func (s *scimServiceBlackboxTestSuite) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.clusterServiceMock = testsupport.NewClusterServiceMock(s.T())
+}
+
+func (s *scimServiceBlackboxTestSuite) newSCIMService(cluster string) service.SCIMService {
+	svcCtx := factory.NewServiceContext(s.Application, s.Application, s.Configuration, nil, factory.WithClusterService(s.clusterServiceMock))
+	return scimservice.NewSCIMService(svcCtx, scimConfig{cluster: cluster})
+}
+
+func newSCIMUser() scim.User {
+	username := fmt.Sprintf("scim-%s", uuid.NewV4())
+	return scim.User{
+		UserName:    username,
+		DisplayName: "John Doe",
+		Email:       username + "@example.com",
+		Active:      true,
+	}
+}
+
+func (s *scimServiceBlackboxTestSuite) TestUsers() {
+
+	s.T().Run("create", func(t *testing.T) {
+
+		t.Run("ok", func(t *testing.T) {
+			// given
+			cluster := "https://api.starter-us-east-2a.openshift.com/"
+			linked := []string{}
+			s.clusterServiceMock.LinkIdentityToClusterFunc = func(ctx context.Context, identityID uuid.UUID, clusterURL string, options ...rest.HTTPClientOption) error {
+				linked = append(linked, clusterURL)
+				return nil
+			}
+			svc := s.newSCIMService(cluster)
+			user := newSCIMUser()
+			// when
+			identity, err := svc.CreateUser(s.Ctx, user)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, user.UserName, identity.Username)
+			assert.Equal(t, user.Email, identity.User.Email)
+			assert.Equal(t, user.DisplayName, identity.User.FullName)
+			assert.Equal(t, cluster, identity.User.Cluster)
+			assert.False(t, identity.User.Banned)
+			assert.Equal(t, []string{cluster}, linked)
+		})
+
+		t.Run("inactive", func(t *testing.T) {
+			// given
+			svc := s.newSCIMService("")
+			user := newSCIMUser()
+			user.Active = false
+			// when
+			identity, err := svc.CreateUser(s.Ctx, user)
+			// then
+			require.NoError(t, err)
+			assert.True(t, identity.User.Banned)
+		})
+
+		t.Run("duplicate username", func(t *testing.T) {
+			// given
+			existing := s.Graph.CreateUser()
+			user := newSCIMUser()
+			user.UserName = existing.Identity().Username
+			// when
+			_, err := s.newSCIMService("").CreateUser(s.Ctx, user)
+			// then
+			assert.IsType(t, errors.VersionConflictError{}, errs.Cause(err))
+		})
+
+		t.Run("duplicate email", func(t *testing.T) {
+			// given
+			existing := s.Graph.CreateUser()
+			user := newSCIMUser()
+			user.Email = existing.User().Email
+			// when
+			_, err := s.newSCIMService("").CreateUser(s.Ctx, user)
+			// then
+			assert.IsType(t, errors.VersionConflictError{}, errs.Cause(err))
+		})
+
+		t.Run("cluster link failure", func(t *testing.T) {
+			// given
+			s.clusterServiceMock.LinkIdentityToClusterFunc = func(ctx context.Context, identityID uuid.UUID, clusterURL string, options ...rest.HTTPClientOption) error {
+				return errors.NewInternalErrorFromString("cluster unavailable")
+			}
+			svc := s.newSCIMService("https://api.starter-us-east-2a.openshift.com/")
+			user := newSCIMUser()
+			// when
+			_, err := svc.CreateUser(s.Ctx, user)
+			// then
+			require.Error(t, err)
+			identities, _, err := svc.ListUsers(s.Ctx, fmt.Sprintf(`userName eq "%s"`, user.UserName), 0, 10)
+			require.NoError(t, err)
+			assert.Empty(t, identities)
+		})
+	})
+
+	s.T().Run("list", func(t *testing.T) {
+		// given
+		svc := s.newSCIMService("")
+		user1, err := svc.CreateUser(s.Ctx, newSCIMUser())
+		require.NoError(t, err)
+		user2, err := svc.CreateUser(s.Ctx, newSCIMUser())
+		require.NoError(t, err)
+		filter := fmt.Sprintf(`userName eq "%s" or emails.value eq "%s"`, user1.Username, user2.User.Email)
+
+		t.Run("all matching", func(t *testing.T) {
+			// when
+			identities, total, err := svc.ListUsers(s.Ctx, filter, 0, 10)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, 2, total)
+			require.Len(t, identities, 2)
+			assert.Equal(t, user1.ID, identities[0].ID)
+			assert.Equal(t, user2.ID, identities[1].ID)
+			assert.Equal(t, user1.User.Email, identities[0].User.Email)
+		})
+
+		t.Run("paged", func(t *testing.T) {
+			// when
+			identities, total, err := svc.ListUsers(s.Ctx, filter, 1, 10)
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, 2, total)
+			require.Len(t, identities, 1)
+			assert.Equal(t, user2.ID, identities[0].ID)
+		})
+
+		t.Run("invalid filter", func(t *testing.T) {
+			// when
+			_, _, err := svc.ListUsers(s.Ctx, `password eq "secret"`, 0, 10)
+			// then
+			assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		})
+	})
+
+	s.T().Run("replace", func(t *testing.T) {
+		// given
+		svc := s.newSCIMService("")
+		identity, err := svc.CreateUser(s.Ctx, newSCIMUser())
+		require.NoError(t, err)
+		user := newSCIMUser()
+		user.DisplayName = "Jane Doe"
+		user.Active = false
+		// when
+		replaced, err := svc.ReplaceUser(s.Ctx, identity.ID, user)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, user.UserName, replaced.Username)
+		assert.Equal(t, user.Email, replaced.User.Email)
+		assert.Equal(t, "Jane Doe", replaced.User.FullName)
+		assert.True(t, replaced.User.Banned)
+	})
+
+	s.T().Run("rename after registration", func(t *testing.T) {
+		// given
+		svc := s.newSCIMService("")
+		identity, err := svc.CreateUser(s.Ctx, newSCIMUser())
+		require.NoError(t, err)
+		identity.RegistrationCompleted = true
+		require.NoError(t, s.Application.Identities().Save(s.Ctx, identity))
+		previousUsername := identity.Username
+		user := newSCIMUser()
+		user.Email = identity.User.Email
+		// when
+		_, err = svc.ReplaceUser(s.Ctx, identity.ID, user)
+		require.NoError(t, err)
+		// then the previous username is recorded, and the cooldown period does not apply to the provisioning clients
+		renamed := newSCIMUser()
+		renamed.Email = identity.User.Email
+		replaced, err := svc.ReplaceUser(s.Ctx, identity.ID, renamed)
+		require.NoError(t, err)
+		assert.Equal(t, renamed.UserName, replaced.Username)
+		history, err := s.Application.UsernameHistory().ListForIdentity(s.Ctx, identity.ID)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		assert.ElementsMatch(t, []string{previousUsername, user.UserName}, []string{history[0].Username, history[1].Username})
+		events, err := s.Application.OutboxEvents().ListForIdentity(s.Ctx, identity.ID)
+		require.NoError(t, err)
+		notifications := 0
+		for _, e := range events {
+			if e.EventType == account.OutboxEventTypeNotification {
+				notifications++
+			}
+		}
+		assert.Equal(t, 2, notifications)
+	})
+
+	s.T().Run("patch", func(t *testing.T) {
+
+		t.Run("deactivate and reactivate", func(t *testing.T) {
+			// given
+			svc := s.newSCIMService("")
+			identity, err := svc.CreateUser(s.Ctx, newSCIMUser())
+			require.NoError(t, err)
+			// when
+			patched, err := svc.PatchUser(s.Ctx, identity.ID, []scim.PatchOperation{
+				{Op: "replace", Path: "active", Value: false},
+			})
+			// then
+			require.NoError(t, err)
+			assert.True(t, patched.User.Banned)
+			bans, err := s.Application.UserBans().ListForUser(s.Ctx, identity.User.ID)
+			require.NoError(t, err)
+			require.Len(t, bans, 1)
+
+			// when
+			patched, err = svc.PatchUser(s.Ctx, identity.ID, []scim.PatchOperation{
+				{Op: "replace", Value: map[string]interface{}{"active": true, "displayName": "Jack Doe"}},
+			})
+			// then
+			require.NoError(t, err)
+			assert.False(t, patched.User.Banned)
+			assert.Equal(t, "Jack Doe", patched.User.FullName)
+		})
+
+		t.Run("reactivate while banned for another reason", func(t *testing.T) {
+			// given
+			svc := s.newSCIMService("")
+			identity, err := svc.CreateUser(s.Ctx, newSCIMUser())
+			require.NoError(t, err)
+			_, err = svc.PatchUser(s.Ctx, identity.ID, []scim.PatchOperation{
+				{Op: "replace", Path: "active", Value: false},
+			})
+			require.NoError(t, err)
+			reason := "crypto-mining"
+			_, err = s.Application.UserService().BanUserWithReason(s.Ctx, identity.Username, account.UserBan{
+				ReasonCategory: account.UserBanCategoryAbuse,
+				Reason:         &reason,
+			})
+			require.NoError(t, err)
+			// when
+			_, err = svc.PatchUser(s.Ctx, identity.ID, []scim.PatchOperation{
+				{Op: "replace", Path: "active", Value: true},
+			})
+			// then
+			assert.IsType(t, errors.DataConflictError{}, errs.Cause(err))
+			loaded, err := svc.LoadUser(s.Ctx, identity.ID)
+			require.NoError(t, err)
+			assert.True(t, loaded.User.Banned)
+			bans, err := s.Application.UserBans().ListForUser(s.Ctx, identity.User.ID)
+			require.NoError(t, err)
+			require.Len(t, bans, 2)
+			for _, ban := range bans {
+				assert.Nil(t, ban.LiftedAt)
+			}
+		})
+
+		t.Run("reactivate lifts the SCIM ban only", func(t *testing.T) {
+			// given
+			svc := s.newSCIMService("")
+			identity, err := svc.CreateUser(s.Ctx, newSCIMUser())
+			require.NoError(t, err)
+			_, err = svc.PatchUser(s.Ctx, identity.ID, []scim.PatchOperation{
+				{Op: "replace", Path: "active", Value: false},
+			})
+			require.NoError(t, err)
+			reason := "scheduled maintenance"
+			_, err = s.Application.UserService().BanUserWithReason(s.Ctx, identity.Username, account.UserBan{
+				Reason:   &reason,
+				StartsAt: time.Now().Add(24 * time.Hour),
+			})
+			require.NoError(t, err)
+			// when
+			patched, err := svc.PatchUser(s.Ctx, identity.ID, []scim.PatchOperation{
+				{Op: "replace", Path: "active", Value: true},
+			})
+			// then
+			require.NoError(t, err)
+			assert.False(t, patched.User.Banned)
+			bans, err := s.Application.UserBans().ListForUser(s.Ctx, identity.User.ID)
+			require.NoError(t, err)
+			require.Len(t, bans, 2)
+			for _, ban := range bans {
+				if *ban.Reason == reason {
+					assert.Nil(t, ban.LiftedAt, "the scheduled ban should not be lifted")
+				} else {
+					assert.NotNil(t, ban.LiftedAt, "the SCIM ban should be lifted")
+				}
+			}
+		})
+
+		t.Run("unknown user", func(t *testing.T) {
+			// when
+			_, err := s.newSCIMService("").PatchUser(s.Ctx, uuid.NewV4(), []scim.PatchOperation{
+				{Op: "replace", Path: "active", Value: false},
+			})
+			// then
+			assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+		})
+
+		t.Run("invalid operation", func(t *testing.T) {
+			// given
+			svc := s.newSCIMService("")
+			identity, err := svc.CreateUser(s.Ctx, newSCIMUser())
+			require.NoError(t, err)
+			// when
+			_, err = svc.PatchUser(s.Ctx, identity.ID, []scim.PatchOperation{
+				{Op: "remove", Path: "userName"},
+			})
+			// then
+			assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		})
+	})
+
+	s.T().Run("delete", func(t *testing.T) {
+		// given
+		svc := s.newSCIMService("")
+		identity, err := svc.CreateUser(s.Ctx, newSCIMUser())
+		require.NoError(t, err)
+		// when
+		err = svc.DeleteUser(s.Ctx, identity.ID)
+		// then
+		require.NoError(t, err)
+		_, err = svc.LoadUser(s.Ctx, identity.ID)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}
+
+func (s *scimServiceBlackboxTestSuite) TestGroups() {
+	svc := s.newSCIMService("")
+	user1, err := svc.CreateUser(s.Ctx, newSCIMUser())
+	require.NoError(s.T(), err)
+	user2, err := svc.CreateUser(s.Ctx, newSCIMUser())
+	require.NoError(s.T(), err)
+
+	s.T().Run("create", func(t *testing.T) {
+
+		t.Run("ok", func(t *testing.T) {
+			// when
+			group, err := svc.CreateGroup(s.Ctx, scim.Group{
+				DisplayName: "developers-" + uuid.NewV4().String(),
+				Members:     []scim.Member{{ID: user1.ID}},
+			})
+			// then
+			require.NoError(t, err)
+			require.Len(t, group.Members, 1)
+			assert.Equal(t, user1.ID, group.Members[0].ID)
+			assert.Equal(t, user1.Username, group.Members[0].Display)
+			loaded, err := svc.LoadGroup(s.Ctx, group.ID)
+			require.NoError(t, err)
+			assert.Equal(t, group.DisplayName, loaded.DisplayName)
+		})
+
+		t.Run("duplicate name", func(t *testing.T) {
+			// given
+			name := "testers-" + uuid.NewV4().String()
+			_, err := svc.CreateGroup(s.Ctx, scim.Group{DisplayName: name})
+			require.NoError(t, err)
+			// when
+			_, err = svc.CreateGroup(s.Ctx, scim.Group{DisplayName: name})
+			// then
+			assert.IsType(t, errors.VersionConflictError{}, errs.Cause(err))
+		})
+
+		t.Run("unknown member", func(t *testing.T) {
+			// when
+			_, err := svc.CreateGroup(s.Ctx, scim.Group{
+				DisplayName: "admins-" + uuid.NewV4().String(),
+				Members:     []scim.Member{{ID: uuid.NewV4()}},
+			})
+			// then
+			assert.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		})
+	})
+
+	s.T().Run("list", func(t *testing.T) {
+		// given
+		name := "operators-" + uuid.NewV4().String()
+		group, err := svc.CreateGroup(s.Ctx, scim.Group{DisplayName: name})
+		require.NoError(t, err)
+		// when
+		groups, total, err := svc.ListGroups(s.Ctx, fmt.Sprintf(`displayName eq "%s"`, name), 0, 10)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, total)
+		require.Len(t, groups, 1)
+		assert.Equal(t, group.ID, groups[0].ID)
+	})
+
+	s.T().Run("patch members", func(t *testing.T) {
+		// given
+		group, err := svc.CreateGroup(s.Ctx, scim.Group{
+			DisplayName: "writers-" + uuid.NewV4().String(),
+			Members:     []scim.Member{{ID: user1.ID}},
+		})
+		require.NoError(t, err)
+		// when
+		patched, err := svc.PatchGroup(s.Ctx, group.ID, []scim.PatchOperation{
+			{Op: "add", Path: "members", Value: []interface{}{map[string]interface{}{"value": user2.ID.String()}}},
+			{Op: "remove", Path: fmt.Sprintf(`members[value eq "%s"]`, user1.ID)},
+		})
+		// then
+		require.NoError(t, err)
+		require.Len(t, patched.Members, 1)
+		assert.Equal(t, user2.ID, patched.Members[0].ID)
+	})
+
+	s.T().Run("replace", func(t *testing.T) {
+		// given
+		group, err := svc.CreateGroup(s.Ctx, scim.Group{
+			DisplayName: "readers-" + uuid.NewV4().String(),
+			Members:     []scim.Member{{ID: user1.ID}},
+		})
+		require.NoError(t, err)
+		name := "reviewers-" + uuid.NewV4().String()
+		// when
+		replaced, err := svc.ReplaceGroup(s.Ctx, group.ID, scim.Group{
+			DisplayName: name,
+			Members:     []scim.Member{{ID: user1.ID}, {ID: user2.ID}},
+		})
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, name, replaced.DisplayName)
+		assert.Len(t, replaced.Members, 2)
+	})
+
+	s.T().Run("delete", func(t *testing.T) {
+		// given
+		group, err := svc.CreateGroup(s.Ctx, scim.Group{
+			DisplayName: "managers-" + uuid.NewV4().String(),
+			Members:     []scim.Member{{ID: user1.ID}},
+		})
+		require.NoError(t, err)
+		// when
+		err = svc.DeleteGroup(s.Ctx, group.ID)
+		// then
+		require.NoError(t, err)
+		_, err = svc.LoadGroup(s.Ctx, group.ID)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+		// the members are not deleted
+		_, err = svc.LoadUser(s.Ctx, user1.ID)
+		require.NoError(t, err)
+	})
+}
@@ -60,6 +60,9 @@ const (
 	// OrganizationContributorRole is the constant used to denote the name of the organization resource's contributor role
 	OrganizationContributorRole = contributorRole
 
+	// SecurityGroupAdminRole is the constant used to denote the name of the security group resource's administrator role
+	SecurityGroupAdminRole = adminRole
+
 	// SpaceAdminRole is the constant used to denote the name of a space resource's administrator role
 	SpaceAdminRole = adminRole
 
@@ -93,6 +96,9 @@ const (
 	// ManageOrganizationMembersScope is the scope required for users wishing to manage members of an organization
 	ManageOrganizationMembersScope = manageScope
 
+	// ManageOrganizationScope is the scope required for users wishing to rename or delete an organization, or to attach spaces to it
+	ManageOrganizationScope = manageScope
+
 	// ManageTeamMembersScope is the scope required for users wishing to manage members of a team
 	ManageTeamMembersScope = manageScope
 
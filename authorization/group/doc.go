@@ -1,2 +1,2 @@
-// Package group provides APIs for managing groups.
+// Package group provides APIs for managing security groups
 package group
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/fabric8-services/fabric8-auth/application/service"
+	"github.com/fabric8-services/fabric8-auth/application/service/base"
+	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
+	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization"
+	resource "github.com/fabric8-services/fabric8-auth/authorization/resource/repository"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/jinzhu/gorm"
+	"github.com/satori/go.uuid"
+)
+
+// groupServiceImpl is the default implementation of GroupService. It is a private struct and should only be instantiated
+// via the NewGroupService() function.
+type groupServiceImpl struct {
+	base.BaseService
+}
+
+// NewGroupService creates a new service.
+func NewGroupService(context servicecontext.ServiceContext) service.GroupService {
+	return &groupServiceImpl{base.NewBaseService(context)}
+}
+
+// CreateGroup creates a new security group. The specified identityID is the user creating the group, who is assigned
+// the admin role of the group. The group's identity ID is returned.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *groupServiceImpl) CreateGroup(ctx context.Context, creatorIdentityID uuid.UUID, groupName string) (*uuid.UUID, error) {
+	if strings.TrimSpace(groupName) == "" {
+		return nil, errors.NewBadParameterErrorFromString("name", groupName, "group name cannot be empty")
+	}
+	var groupID uuid.UUID
+	err := s.ExecuteInTransaction(func() error {
+		// Lookup the identity for the current user
+		identity, err := s.Repositories().Identities().Load(ctx, creatorIdentityID)
+		if err != nil {
+			return errors.NewUnauthorizedError(fmt.Sprintf("unknown Identity ID %s", creatorIdentityID))
+		}
+
+		resourceType, err := s.Repositories().ResourceTypeRepository().Lookup(ctx, authorization.IdentityResourceTypeGroup)
+		if err != nil {
+			return err
+		}
+		res := &resource.Resource{
+			Name:           groupName,
+			ResourceType:   *resourceType,
+			ResourceTypeID: resourceType.ResourceTypeID,
+		}
+		err = s.Repositories().ResourceRepository().Create(ctx, res)
+		if err != nil {
+			return err
+		}
+
+		group := &account.Identity{
+			IdentityResourceID: sql.NullString{
+				String: res.ResourceID,
+				Valid:  true,
+			},
+		}
+		err = s.Repositories().Identities().Create(ctx, group)
+		if err != nil {
+			return err
+		}
+		groupID = group.ID
+
+		return s.Services().RoleManagementService().ForceAssign(ctx, identity.ID, authorization.SecurityGroupAdminRole, *res)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info(ctx, map[string]interface{}{
+		"group_id":    groupID,
+		"identity_id": creatorIdentityID,
+	}, "group created")
+	return &groupID, nil
+}
+
+// AddGroupMember adds the specified user or group as a member of the group. The user must have the 'manage' scope
+// for the group, and for the member when it is a group itself. Memberships which would make a group a member of
+// itself, directly or through nested groups, are rejected.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *groupServiceImpl) AddGroupMember(ctx context.Context, identityID uuid.UUID, groupID uuid.UUID, memberID uuid.UUID) error {
+	return s.ExecuteInTransaction(func() error {
+		group, err := s.loadGroup(ctx, groupID)
+		if err != nil {
+			return err
+		}
+		err = s.Services().PermissionService().RequireScope(ctx, identityID, group.IdentityResourceID.String, authorization.ManageSecurityGroupMembersScope)
+		if err != nil {
+			return err
+		}
+		member, err := s.Repositories().Identities().Load(ctx, memberID, func(db *gorm.DB) *gorm.DB {
+			return db.Preload("IdentityResource").Preload("IdentityResource.ResourceType")
+		})
+		if err != nil {
+			return err
+		}
+		if member.IdentityResourceID.Valid {
+			if member.IdentityResource.ResourceType.Name != authorization.IdentityResourceTypeGroup {
+				return errors.NewBadParameterErrorFromString("memberID", memberID, "only users and groups can be members of a group")
+			}
+			err = s.Services().PermissionService().RequireScope(ctx, identityID, member.IdentityResourceID.String, authorization.ManageSecurityGroupMembersScope)
+			if err != nil {
+				return err
+			}
+		}
+		return s.Repositories().Identities().AddMember(ctx, groupID, memberID)
+	})
+}
+
+// RemoveGroupMember removes the specified member from the group. The user must have the 'manage' scope for the group.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *groupServiceImpl) RemoveGroupMember(ctx context.Context, identityID uuid.UUID, groupID uuid.UUID, memberID uuid.UUID) error {
+	return s.ExecuteInTransaction(func() error {
+		group, err := s.loadGroup(ctx, groupID)
+		if err != nil {
+			return err
+		}
+		err = s.Services().PermissionService().RequireScope(ctx, identityID, group.IdentityResourceID.String, authorization.ManageSecurityGroupMembersScope)
+		if err != nil {
+			return err
+		}
+		return s.removeMember(ctx, groupID, memberID)
+	})
+}
+
+// ListGroupMembers returns the identities of the members of the specified group, along with their user. If transitive
+// is true, the members of the nested groups are included. The user must have the 'view' scope for the group.
+func (s *groupServiceImpl) ListGroupMembers(ctx context.Context, identityID uuid.UUID, groupID uuid.UUID, transitive bool) ([]account.Identity, error) {
+	group, err := s.loadGroup(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.Services().PermissionService().RequireScope(ctx, identityID, group.IdentityResourceID.String, authorization.ViewSecurityGroupMembersScope)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repositories().Identities().Query(account.IdentityFilterByMemberOf(groupID, transitive), account.IdentityWithUser(), func(db *gorm.DB) *gorm.DB {
+		return db.Preload("IdentityResource")
+	})
+}
+
+// NestGroup makes the specified group a member of another group, team or organization, so that the members of the
+// group inherit the privileges of the parent. The user must have the 'manage' scope for the group and the scope
+// required to manage the members of the parent. Nestings which would create a cycle are rejected.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *groupServiceImpl) NestGroup(ctx context.Context, identityID uuid.UUID, groupID uuid.UUID, parentID uuid.UUID) error {
+	return s.ExecuteInTransaction(func() error {
+		err := s.requireNestingScopes(ctx, identityID, groupID, parentID)
+		if err != nil {
+			return err
+		}
+		return s.Repositories().Identities().AddMember(ctx, parentID, groupID)
+	})
+}
+
+// UnnestGroup removes the specified group from the members of its parent group, team or organization. The user must
+// have the 'manage' scope for the group and the scope required to manage the members of the parent.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *groupServiceImpl) UnnestGroup(ctx context.Context, identityID uuid.UUID, groupID uuid.UUID, parentID uuid.UUID) error {
+	return s.ExecuteInTransaction(func() error {
+		err := s.requireNestingScopes(ctx, identityID, groupID, parentID)
+		if err != nil {
+			return err
+		}
+		return s.removeMember(ctx, parentID, groupID)
+	})
+}
+
+// requireNestingScopes returns a ForbiddenError unless the user can manage the members of both the group and its parent
+func (s *groupServiceImpl) requireNestingScopes(ctx context.Context, identityID uuid.UUID, groupID uuid.UUID, parentID uuid.UUID) error {
+	group, err := s.loadGroup(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	err = s.Services().PermissionService().RequireScope(ctx, identityID, group.IdentityResourceID.String, authorization.ManageSecurityGroupMembersScope)
+	if err != nil {
+		return err
+	}
+	parent, err := s.Repositories().Identities().Load(ctx, parentID, func(db *gorm.DB) *gorm.DB {
+		return db.Preload("IdentityResource").Preload("IdentityResource.ResourceType")
+	})
+	if err != nil {
+		return err
+	}
+	if !parent.IdentityResourceID.Valid || !authorization.CanHaveMembers(parent.IdentityResource.ResourceType.Name) {
+		return errors.NewBadParameterErrorFromString("parentID", parentID, "a group can only be nested in a group, a team or an organization")
+	}
+	return s.Services().PermissionService().RequireScope(ctx, identityID, parent.IdentityResourceID.String,
+		authorization.ScopeForManagingRolesInResourceType(parent.IdentityResource.ResourceType.Name))
+}
+
+// removeMember removes the membership, returning a NotFoundError if the identity is not a direct member
+func (s *groupServiceImpl) removeMember(ctx context.Context, memberOf uuid.UUID, memberID uuid.UUID) error {
+	members, err := s.Repositories().Identities().Query(account.IdentityFilterByMemberOf(memberOf, false), account.IdentityFilterByID(memberID))
+	if err != nil {
+		return err
+	}
+	if len(members) == 0 {
+		return errors.NewNotFoundErrorFromString(fmt.Sprintf("identity %s is not a member of identity %s", memberID, memberOf))
+	}
+	return s.Repositories().Identities().RemoveMember(ctx, memberOf, memberID)
+}
+
+// loadGroup loads the identity of the specified group, along with its resource
+func (s *groupServiceImpl) loadGroup(ctx context.Context, groupID uuid.UUID) (*account.Identity, error) {
+	groups, err := s.Repositories().Identities().Query(account.IdentityFilterByID(groupID), func(db *gorm.DB) *gorm.DB {
+		return db.Preload("IdentityResource").Preload("IdentityResource.ResourceType")
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 || !groups[0].IdentityResourceID.Valid || groups[0].IdentityResource.ResourceType.Name != authorization.IdentityResourceTypeGroup {
+		return nil, errors.NewNotFoundError("group", groupID.String())
+	}
+	return &groups[0], nil
+}
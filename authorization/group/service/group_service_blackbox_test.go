@@ -0,0 +1,154 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/authorization"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type groupServiceBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+}
+
+func TestRunGroupServiceBlackBoxTest(t *testing.T) {
+	suite.Run(t, &groupServiceBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *groupServiceBlackBoxTest) TestCreateGroup() {
+	g := s.NewTestGraph(s.T())
+	creator := g.CreateUser()
+
+	s.T().Run("ok", func(t *testing.T) {
+		groupName := "Group-" + uuid.NewV4().String()
+		groupID, err := s.Application.GroupService().CreateGroup(s.Ctx, creator.IdentityID(), groupName)
+		require.NoError(t, err)
+
+		group := g.LoadIdentity(groupID).Identity()
+		res, err := s.Application.ResourceRepository().Load(s.Ctx, group.IdentityResourceID.String)
+		require.NoError(t, err)
+		assert.Equal(t, groupName, res.Name)
+		assert.Equal(t, authorization.IdentityResourceTypeGroup, res.ResourceType.Name)
+		admins, err := s.Application.IdentityRoleRepository().FindIdentityRolesByResourceAndRoleName(s.Ctx, res.ResourceID, authorization.SecurityGroupAdminRole, false)
+		require.NoError(t, err)
+		require.Len(t, admins, 1)
+		assert.Equal(t, creator.IdentityID(), admins[0].IdentityID)
+	})
+
+	s.T().Run("empty name", func(t *testing.T) {
+		_, err := s.Application.GroupService().CreateGroup(s.Ctx, creator.IdentityID(), " ")
+		require.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+	})
+}
+
+func (s *groupServiceBlackBoxTest) TestGroupMembers() {
+	g := s.NewTestGraph(s.T())
+	admin := g.CreateUser()
+	parent := g.CreateGroup(admin)
+	child := g.CreateGroup(admin)
+	u1 := g.CreateUser()
+	u2 := g.CreateUser()
+
+	s.T().Run("add members", func(t *testing.T) {
+		require.NoError(t, s.Application.GroupService().AddGroupMember(s.Ctx, admin.IdentityID(), parent.GroupID(), u1.IdentityID()))
+		require.NoError(t, s.Application.GroupService().AddGroupMember(s.Ctx, admin.IdentityID(), child.GroupID(), u2.IdentityID()))
+		require.NoError(t, s.Application.GroupService().AddGroupMember(s.Ctx, admin.IdentityID(), parent.GroupID(), child.GroupID()))
+	})
+
+	s.T().Run("list direct members", func(t *testing.T) {
+		members, err := s.Application.GroupService().ListGroupMembers(s.Ctx, admin.IdentityID(), parent.GroupID(), false)
+		require.NoError(t, err)
+		require.Len(t, members, 2)
+	})
+
+	s.T().Run("list transitive members", func(t *testing.T) {
+		members, err := s.Application.GroupService().ListGroupMembers(s.Ctx, admin.IdentityID(), parent.GroupID(), true)
+		require.NoError(t, err)
+		ids := []uuid.UUID{}
+		for _, member := range members {
+			ids = append(ids, member.ID)
+		}
+		assert.ElementsMatch(t, []uuid.UUID{u1.IdentityID(), u2.IdentityID(), child.GroupID()}, ids)
+	})
+
+	s.T().Run("transitive memberships", func(t *testing.T) {
+		groupType := authorization.IdentityResourceTypeGroup
+		memberships, err := s.Application.Identities().FindIdentityMemberships(s.Ctx, u2.IdentityID(), &groupType)
+		require.NoError(t, err)
+		require.Len(t, memberships, 2)
+	})
+
+	s.T().Run("cycle", func(t *testing.T) {
+		err := s.Application.GroupService().AddGroupMember(s.Ctx, admin.IdentityID(), child.GroupID(), parent.GroupID())
+		require.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+		err = s.Application.GroupService().AddGroupMember(s.Ctx, admin.IdentityID(), parent.GroupID(), parent.GroupID())
+		require.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+	})
+
+	s.T().Run("forbidden", func(t *testing.T) {
+		err := s.Application.GroupService().AddGroupMember(s.Ctx, u1.IdentityID(), parent.GroupID(), g.CreateUser().IdentityID())
+		require.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+		_, err = s.Application.GroupService().ListGroupMembers(s.Ctx, u1.IdentityID(), parent.GroupID(), false)
+		require.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+	})
+
+	s.T().Run("remove member", func(t *testing.T) {
+		require.NoError(t, s.Application.GroupService().RemoveGroupMember(s.Ctx, admin.IdentityID(), parent.GroupID(), child.GroupID()))
+		members, err := s.Application.GroupService().ListGroupMembers(s.Ctx, admin.IdentityID(), parent.GroupID(), true)
+		require.NoError(t, err)
+		require.Len(t, members, 1)
+		assert.Equal(t, u1.IdentityID(), members[0].ID)
+
+		err = s.Application.GroupService().RemoveGroupMember(s.Ctx, admin.IdentityID(), parent.GroupID(), child.GroupID())
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}
+
+func (s *groupServiceBlackBoxTest) TestNestGroup() {
+	g := s.NewTestGraph(s.T())
+	admin := g.CreateUser()
+	member := g.CreateUser()
+	group := g.CreateGroup(admin).AddMember(member)
+	org := g.CreateOrganization(admin)
+
+	s.T().Run("forbidden for group", func(t *testing.T) {
+		other := g.CreateUser()
+		err := s.Application.GroupService().NestGroup(s.Ctx, other.IdentityID(), group.GroupID(), g.CreateOrganization(other).OrganizationID())
+		require.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+	})
+
+	s.T().Run("forbidden for parent", func(t *testing.T) {
+		err := s.Application.GroupService().NestGroup(s.Ctx, admin.IdentityID(), group.GroupID(), g.CreateOrganization().OrganizationID())
+		require.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+	})
+
+	s.T().Run("parent cannot have members", func(t *testing.T) {
+		err := s.Application.GroupService().NestGroup(s.Ctx, admin.IdentityID(), group.GroupID(), member.IdentityID())
+		require.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+	})
+
+	s.T().Run("nest and unnest in organization", func(t *testing.T) {
+		err := s.Application.GroupService().NestGroup(s.Ctx, admin.IdentityID(), group.GroupID(), org.OrganizationID())
+		require.NoError(t, err)
+
+		// the members of the group are members of the organization through the group
+		orgs, err := s.Application.OrganizationService().ListOrganizations(s.Ctx, member.IdentityID())
+		require.NoError(t, err)
+		require.Len(t, orgs, 1)
+		assert.Equal(t, org.OrganizationID(), *orgs[0].IdentityID)
+		assert.True(t, orgs[0].Member)
+
+		err = s.Application.GroupService().UnnestGroup(s.Ctx, admin.IdentityID(), group.GroupID(), org.OrganizationID())
+		require.NoError(t, err)
+		orgs, err = s.Application.OrganizationService().ListOrganizations(s.Ctx, member.IdentityID())
+		require.NoError(t, err)
+		require.Empty(t, orgs)
+	})
+}
@@ -81,7 +81,9 @@ type InvitationRepository interface {
 	Save(ctx context.Context, i *Invitation) error
 	ListForIdentity(ctx context.Context, inviteToID uuid.UUID) ([]Invitation, error)
 	ListForResource(ctx context.Context, resourceID string) ([]Invitation, error)
+	ListForInvitee(ctx context.Context, identityID uuid.UUID) ([]Invitation, error)
 	Delete(ctx context.Context, id uuid.UUID) error
+	TransferToInvitee(ctx context.Context, fromIdentityID uuid.UUID, toIdentityID uuid.UUID) error
 
 	ListRoles(ctx context.Context, id uuid.UUID) ([]rolerepo.Role, error)
 	AddRole(ctx context.Context, invitationId uuid.UUID, roleId uuid.UUID) error
@@ -180,6 +182,17 @@ func (m *GormInvitationRepository) ListForIdentity(ctx context.Context, inviteTo
 	return rows, nil
 }
 
+func (m *GormInvitationRepository) ListForInvitee(ctx context.Context, identityID uuid.UUID) ([]Invitation, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "invitation", "listForInvitee"}, time.Now())
+	var rows []Invitation
+
+	err := m.db.Model(&Invitation{}).Where("identity_id = ?", identityID).Find(&rows).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return rows, nil
+}
+
 func (m *GormInvitationRepository) ListForResource(ctx context.Context, resourceID string) ([]Invitation, error) {
 	defer goa.MeasureSince([]string{"goa", "db", "invitation", "listForResource"}, time.Now())
 	var rows []Invitation
@@ -236,6 +249,60 @@ func (m *GormInvitationRepository) Delete(ctx context.Context, id uuid.UUID) err
 	return nil
 }
 
+// TransferToInvitee assigns the pending invitations of an identity to another identity. The invitations to the same
+// organization, team, group or resource as one of the pending invitations of the other identity are deleted instead.
+func (m *GormInvitationRepository) TransferToInvitee(ctx context.Context, fromIdentityID uuid.UUID, toIdentityID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "invitation", "transferToInvitee"}, time.Now())
+
+	existing, err := m.ListForInvitee(ctx, toIdentityID)
+	if err != nil {
+		return err
+	}
+	invitedTo := make(map[string]bool, len(existing))
+	for _, i := range existing {
+		invitedTo[invitationTarget(i)] = true
+	}
+	invitations, err := m.ListForInvitee(ctx, fromIdentityID)
+	if err != nil {
+		return err
+	}
+	for _, i := range invitations {
+		if invitedTo[invitationTarget(i)] {
+			err := m.Delete(ctx, i.InvitationID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = m.db.Model(&Invitation{}).Where("identity_id = ?", fromIdentityID).Update("identity_id", toIdentityID).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"from_identity_id": fromIdentityID,
+			"to_identity_id":   toIdentityID,
+			"err":              err,
+		}, "unable to transfer the invitations")
+		return errs.WithStack(err)
+	}
+
+	log.Debug(ctx, map[string]interface{}{
+		"from_identity_id": fromIdentityID,
+		"to_identity_id":   toIdentityID,
+	}, "Invitations transferred!")
+	return nil
+}
+
+// invitationTarget returns the ID of the identity or the resource to which the invitation applies
+func invitationTarget(i Invitation) string {
+	if i.InviteTo != nil {
+		return i.InviteTo.String()
+	}
+	if i.ResourceID != nil {
+		return *i.ResourceID
+	}
+	return ""
+}
+
 func (m *GormInvitationRepository) ListRoles(ctx context.Context, id uuid.UUID) ([]rolerepo.Role, error) {
 	defer goa.MeasureSince([]string{"goa", "db", "invitation", "list_roles"}, time.Now())
 
@@ -52,7 +52,7 @@ func (s *invitationServiceImpl) Issue(ctx context.Context, issuingUserId uuid.UU
 
 	var notifications []invitationNotification
 
-	err := s.ExecuteInTransaction(func() error {
+	return s.ExecuteInTransaction(func() error {
 
 		// First try to convert inviteTo to a uuid
 		inviteToUUID, err := uuid.FromString(inviteTo)
@@ -198,26 +198,25 @@ func (s *invitationServiceImpl) Issue(ctx context.Context, issuingUserId uuid.UU
 			})
 		}
 
-		return nil
+		// Enqueue the notifications in the same transaction as the invitations, so that they are sent once the
+		// invitations are committed
+		return s.enqueueNotifications(ctx, issuingUserId, inviteToIdentity, inviteToResource, notifications)
 	})
+}
 
-	if err != nil {
-		return err
-	}
-
+// enqueueNotifications enqueues the invitation e-mails to the invited users, to be sent by the notification service.
+// Currently we only support sending notifications for two types of invitations;
+//
+// 1) Invite user to team, membership only, no organization
+// 2) Invite user to space, roles only, no organization
+func (s *invitationServiceImpl) enqueueNotifications(ctx context.Context, issuingUserId uuid.UUID, inviteToIdentity *account.Identity,
+	inviteToResource *resource.Resource, notifications []invitationNotification) error {
 	// Lookup the identity record of the user doing the inviting
 	inviter, err := s.Repositories().Identities().LoadWithUser(ctx, issuingUserId)
-
 	if err != nil {
 		return err
 	}
 
-	// Use the notification service to send invitation e-mails to the invited users, in a separate thread
-	// Currently we only support sending notifications for two types of invitations;
-	//
-	// 1) Invite user to team, membership only, no organization
-	// 2) Invite user to space, roles only, no organization
-	//
 	if inviteToIdentity != nil {
 		identityResource, err := s.Repositories().ResourceRepository().Load(ctx, inviteToIdentity.IdentityResourceID.String)
 		if err != nil {
@@ -225,16 +224,11 @@ func (s *invitationServiceImpl) Issue(ctx context.Context, issuingUserId uuid.UU
 		}
 
 		if identityResource.ResourceType.Name == authorization.IdentityResourceTypeTeam {
-			err = s.processTeamInviteNotifications(ctx, inviteToIdentity, inviter.User.FullName, notifications)
+			return s.processTeamInviteNotifications(ctx, inviteToIdentity, inviter.User.FullName, notifications)
 		}
 	} else if inviteToResource != nil && inviteToResource.ResourceType.Name == authorization.ResourceTypeSpace {
-		err = s.processSpaceInviteNotifications(ctx, inviteToResource, inviter.User.FullName, notifications)
+		return s.processSpaceInviteNotifications(ctx, inviteToResource, inviter.User.FullName, notifications)
 	}
-
-	if err != nil {
-		return err
-	}
-
 	return nil
 }
 
@@ -243,48 +237,56 @@ type invitationNotification struct {
 	roles      []string
 }
 
-// processTeamInviteNotifications sends an e-mail notification to a user.
+// processTeamInviteNotifications enqueues an e-mail notification to each invited user.
 func (s *invitationServiceImpl) processTeamInviteNotifications(ctx context.Context, team *account.Identity, inviterName string,
 	notifications []invitationNotification) error {
 	teamName := team.IdentityResource.Name
 
 	var spaceName string
 
-	var messages []notification.Message
-
 	for _, n := range notifications {
 		acceptURL := fmt.Sprintf("%s%s", s.config.GetAuthServiceURL(), client.AcceptInviteInvitationPath(n.invitation.AcceptCode.String()))
 
-		messages = append(messages, notification.NewTeamInvitationEmail(n.invitation.Identity.ID.String(),
+		msg := notification.NewTeamInvitationEmail(n.invitation.Identity.ID.String(),
 			teamName,
 			inviterName,
 			spaceName,
-			acceptURL))
+			acceptURL)
+		if err := s.enqueueNotification(ctx, n.invitation.IdentityID, msg); err != nil {
+			return err
+		}
 	}
-
-	_, e := s.Services().NotificationService().SendMessagesAsync(ctx, messages)
-	return e
+	return nil
 }
 
-// processSpaceInviteNotifications sends an e-mail notification to a user.
+// processSpaceInviteNotifications enqueues an e-mail notification to each invited user.
 func (s *invitationServiceImpl) processSpaceInviteNotifications(ctx context.Context, space *resource.Resource,
 	inviterName string, notifications []invitationNotification) error {
 
 	spaceName := ""
 
-	var messages []notification.Message
-
 	for _, n := range notifications {
 		acceptURL := fmt.Sprintf("%s%s", s.config.GetAuthServiceURL(), client.AcceptInviteInvitationPath(n.invitation.AcceptCode.String()))
 
-		messages = append(messages, notification.NewSpaceInvitationEmail(n.invitation.Identity.ID.String(),
+		msg := notification.NewSpaceInvitationEmail(n.invitation.Identity.ID.String(),
 			spaceName,
 			inviterName,
 			strings.Join(n.roles, ","),
-			acceptURL))
+			acceptURL)
+		if err := s.enqueueNotification(ctx, n.invitation.IdentityID, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enqueueNotification records the given message in the outbox, to be sent to the given identity
+func (s *invitationServiceImpl) enqueueNotification(ctx context.Context, identityID uuid.UUID, msg notification.Message) error {
+	event, err := account.NewNotificationEvent(identityID, msg)
+	if err != nil {
+		return err
 	}
-	_, e := s.Services().NotificationService().SendMessagesAsync(ctx, messages)
-	return e
+	return s.Repositories().OutboxEvents().Enqueue(ctx, event)
 }
 
 // Rescind revokes an invitation request
@@ -1,23 +1,18 @@
 package service_test
 
 import (
-	"context"
 	"testing"
 
 	"github.com/fabric8-services/fabric8-auth/app"
 	"github.com/fabric8-services/fabric8-auth/application/service"
-	"github.com/fabric8-services/fabric8-auth/application/service/factory"
 	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
 	"github.com/fabric8-services/fabric8-auth/authorization"
 	"github.com/fabric8-services/fabric8-auth/authorization/invitation"
 	invitationrepo "github.com/fabric8-services/fabric8-auth/authorization/invitation/repository"
 	"github.com/fabric8-services/fabric8-auth/errors"
-	"github.com/fabric8-services/fabric8-auth/gormapplication"
 	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
 	"github.com/fabric8-services/fabric8-auth/notification"
-	"github.com/fabric8-services/fabric8-auth/rest"
 	"github.com/fabric8-services/fabric8-auth/test"
-	testservice "github.com/fabric8-services/fabric8-auth/test/generated/application/service"
 
 	"github.com/satori/go.uuid"
 	"github.com/stretchr/testify/require"
@@ -32,10 +27,9 @@ const (
 
 type invitationServiceBlackBoxTest struct {
 	gormtestsupport.DBTestSuite
-	invitationRepo          invitationrepo.InvitationRepository
-	identityRepo            account.IdentityRepository
-	orgService              service.OrganizationService
-	notificationServiceMock *testservice.NotificationServiceMock
+	invitationRepo invitationrepo.InvitationRepository
+	identityRepo   account.IdentityRepository
+	orgService     service.OrganizationService
 }
 
 func TestRunInvitationServiceBlackBoxTest(t *testing.T) {
@@ -47,8 +41,24 @@ func (s *invitationServiceBlackBoxTest) SetupTest() {
 	s.invitationRepo = invitationrepo.NewInvitationRepository(s.DB)
 	s.identityRepo = account.NewIdentityRepository(s.DB)
 	s.orgService = s.Application.OrganizationService()
-	s.notificationServiceMock = testservice.NewNotificationServiceMock(s.T())
-	s.Application = gormapplication.NewGormDB(s.DB, s.Configuration, s.Wrappers, factory.WithNotificationService(s.notificationServiceMock))
+}
+
+// invitationNotifications returns the notifications enqueued for the users of the given invitations
+func (s *invitationServiceBlackBoxTest) invitationNotifications(t *testing.T, invitations []invitation.Invitation) []notification.Message {
+	var messages []notification.Message
+	for _, inv := range invitations {
+		events, err := s.Application.OutboxEvents().ListForIdentity(s.Ctx, *inv.IdentityID)
+		require.NoError(t, err)
+		for _, e := range events {
+			if e.EventType != account.OutboxEventTypeNotification {
+				continue
+			}
+			msg, err := e.NotificationMessage()
+			require.NoError(t, err)
+			messages = append(messages, msg)
+		}
+	}
+	return messages
 }
 
 func (s *invitationServiceBlackBoxTest) TestIssueInvitation() {
@@ -83,19 +93,12 @@ func (s *invitationServiceBlackBoxTest) TestIssueInvitation() {
 			},
 		}
 
-		var messages []notification.Message
-		*s.notificationServiceMock = *testservice.NewNotificationServiceMock(t)
-		s.notificationServiceMock.SendMessagesAsyncFunc = func(p context.Context, msgs []notification.Message, p2 ...rest.HTTPClientOption) (r chan error, r1 error) {
-			messages = msgs
-			return nil, nil
-		}
-
 		// when
 		err := s.Application.InvitationService().Issue(s.Ctx, teamAdmin.IdentityID(), team.TeamID().String(), invitations)
 
 		// then
 		require.NoError(t, err, "Error creating invitations")
-		require.Equal(t, uint64(1), s.notificationServiceMock.SendMessagesAsyncCounter)
+		messages := s.invitationNotifications(t, invitations)
 		require.Len(t, messages, 1)
 		require.Equal(t, id.String(), messages[0].TargetID)
 		require.Contains(t, messages[0].Custom["acceptURL"], acceptInvitationEndpoint)
@@ -128,24 +131,19 @@ func (s *invitationServiceBlackBoxTest) TestIssueInvitation() {
 			},
 		}
 
-		*s.notificationServiceMock = *testservice.NewNotificationServiceMock(t)
-		s.notificationServiceMock.SendMessagesAsyncFunc = func(p context.Context, msgs []notification.Message, p2 ...rest.HTTPClientOption) (r chan error, r1 error) {
-			return nil, nil
-		}
-
 		// when
 		err = s.Application.InvitationService().Issue(s.Ctx, identity.ID, uuid.NewV4().String(), invitations)
 
 		// then
 		require.Error(t, err)
-		require.Equal(t, uint64(0), s.notificationServiceMock.SendMessagesAsyncCounter)
+		require.Empty(t, s.invitationNotifications(t, invitations))
 
 		// when
 		err = s.Application.InvitationService().Issue(s.Ctx, identity.ID, "foo", invitations)
 
 		// then
 		require.Error(t, err)
-		require.Equal(t, uint64(0), s.notificationServiceMock.SendMessagesAsyncCounter)
+		require.Empty(t, s.invitationNotifications(t, invitations))
 	})
 
 	s.T().Run("should issue invitation for resource", func(t *testing.T) {
@@ -172,19 +170,12 @@ func (s *invitationServiceBlackBoxTest) TestIssueInvitation() {
 			},
 		}
 
-		var messages []notification.Message
-		*s.notificationServiceMock = *testservice.NewNotificationServiceMock(t)
-		s.notificationServiceMock.SendMessagesAsyncFunc = func(p context.Context, msgs []notification.Message, p2 ...rest.HTTPClientOption) (r chan error, r1 error) {
-			messages = msgs
-			return nil, nil
-		}
-
 		// when - issue the invitation
 		err := s.Application.InvitationService().Issue(s.Ctx, inviter.IdentityID(), space.SpaceID(), invitations)
 
 		// then
 		require.NoError(t, err)
-		require.Equal(t, uint64(1), s.notificationServiceMock.SendMessagesAsyncCounter)
+		messages := s.invitationNotifications(t, invitations)
 		require.Len(t, messages, 1)
 		require.Equal(t, inviteeID.String(), messages[0].TargetID)
 		require.Contains(t, messages[0].Custom["acceptURL"], acceptInvitationEndpoint)
@@ -255,17 +246,12 @@ func (s *invitationServiceBlackBoxTest) TestIssueInvitation() {
 			},
 		}
 
-		*s.notificationServiceMock = *testservice.NewNotificationServiceMock(t)
-		s.notificationServiceMock.SendMessagesAsyncFunc = func(p context.Context, msgs []notification.Message, p2 ...rest.HTTPClientOption) (r chan error, r1 error) {
-			return nil, nil
-		}
-
 		// when - issue the invitation, which should fail because the new resource can't have members
 		err = s.Application.InvitationService().Issue(s.Ctx, identity.ID, resource.ResourceID, invitations)
 
 		// then
 		require.Error(t, err)
-		require.Equal(t, uint64(0), s.notificationServiceMock.SendMessagesAsyncCounter)
+		require.Empty(t, s.invitationNotifications(t, invitations))
 	})
 
 	s.T().Run("should fail to issue unprivileged invitation for resource", func(t *testing.T) {
@@ -300,17 +286,12 @@ func (s *invitationServiceBlackBoxTest) TestIssueInvitation() {
 			},
 		}
 
-		*s.notificationServiceMock = *testservice.NewNotificationServiceMock(t)
-		s.notificationServiceMock.SendMessagesAsyncFunc = func(p context.Context, msgs []notification.Message, p2 ...rest.HTTPClientOption) (r chan error, r1 error) {
-			return nil, nil
-		}
-
 		// when - issue the invitation, which should fail because the inviter has insufficient privileges to issue an invitation
 		err = s.Application.InvitationService().Issue(s.Ctx, identity.ID, resource.ResourceID, invitations)
 
 		// then
 		require.Error(t, err)
-		require.Equal(t, uint64(0), s.notificationServiceMock.SendMessagesAsyncCounter)
+		require.Empty(t, s.invitationNotifications(t, invitations))
 	})
 
 	s.T().Run("should fail to issue invitation for non owner", func(t *testing.T) {
@@ -336,17 +317,12 @@ func (s *invitationServiceBlackBoxTest) TestIssueInvitation() {
 			},
 		}
 
-		*s.notificationServiceMock = *testservice.NewNotificationServiceMock(t)
-		s.notificationServiceMock.SendMessagesAsyncFunc = func(p context.Context, msgs []notification.Message, p2 ...rest.HTTPClientOption) (r chan error, r1 error) {
-			return nil, nil
-		}
-
 		// when
 		err = s.Application.InvitationService().Issue(s.Ctx, otherIdentity.ID, orgId.String(), invitations)
 
 		// then
 		require.Error(t, err)
-		require.Equal(t, uint64(0), s.notificationServiceMock.SendMessagesAsyncCounter)
+		require.Empty(t, s.invitationNotifications(t, invitations))
 	})
 
 	s.T().Run("should fail to issue invitation for unknown user", func(t *testing.T) {
@@ -370,17 +346,12 @@ func (s *invitationServiceBlackBoxTest) TestIssueInvitation() {
 			},
 		}
 
-		*s.notificationServiceMock = *testservice.NewNotificationServiceMock(t)
-		s.notificationServiceMock.SendMessagesAsyncFunc = func(p context.Context, msgs []notification.Message, p2 ...rest.HTTPClientOption) (r chan error, r1 error) {
-			return nil, nil
-		}
-
 		// when - this should fail because we specified an unknown identity ID
 		err = s.Application.InvitationService().Issue(s.Ctx, identity.ID, orgId.String(), invitations)
 
 		// then
 		require.Error(t, err)
-		require.Equal(t, uint64(0), s.notificationServiceMock.SendMessagesAsyncCounter)
+		require.Empty(t, s.invitationNotifications(t, invitations))
 	})
 
 	s.T().Run("should fail to issue invitation for non user", func(t *testing.T) {
@@ -402,17 +373,12 @@ func (s *invitationServiceBlackBoxTest) TestIssueInvitation() {
 			},
 		}
 
-		*s.notificationServiceMock = *testservice.NewNotificationServiceMock(t)
-		s.notificationServiceMock.SendMessagesAsyncFunc = func(p context.Context, msgs []notification.Message, p2 ...rest.HTTPClientOption) (r chan error, r1 error) {
-			return nil, nil
-		}
-
 		// when - This should fail because we specified a non-user identity in the invitation
 		err = s.Application.InvitationService().Issue(s.Ctx, identity.ID, orgId.String(), invitations)
 
 		// then
 		require.Error(t, err)
-		require.Equal(t, uint64(0), s.notificationServiceMock.SendMessagesAsyncCounter)
+		require.Empty(t, s.invitationNotifications(t, invitations))
 	})
 
 	s.T().Run("should fail to issue invitation for non membership identity", func(t *testing.T) {
@@ -434,17 +400,12 @@ func (s *invitationServiceBlackBoxTest) TestIssueInvitation() {
 			},
 		}
 
-		*s.notificationServiceMock = *testservice.NewNotificationServiceMock(t)
-		s.notificationServiceMock.SendMessagesAsyncFunc = func(p context.Context, msgs []notification.Message, p2 ...rest.HTTPClientOption) (r chan error, r1 error) {
-			return nil, nil
-		}
-
 		// when - invite the user to "join" the other user as a member, this should fail
 		err = s.Application.InvitationService().Issue(s.Ctx, identity.ID, identity.ID.String(), invitations)
 
 		// then
 		require.Error(t, err)
-		require.Equal(t, uint64(0), s.notificationServiceMock.SendMessagesAsyncCounter)
+		require.Empty(t, s.invitationNotifications(t, invitations))
 	})
 
 	s.T().Run("should issue multiple invitations", func(t *testing.T) {
@@ -478,19 +439,12 @@ func (s *invitationServiceBlackBoxTest) TestIssueInvitation() {
 			},
 		}
 
-		var messages []notification.Message
-		*s.notificationServiceMock = *testservice.NewNotificationServiceMock(t)
-		s.notificationServiceMock.SendMessagesAsyncFunc = func(p context.Context, msgs []notification.Message, p2 ...rest.HTTPClientOption) (r chan error, r1 error) {
-			messages = msgs
-			return nil, nil
-		}
-
 		// when
 		err := s.Application.InvitationService().Issue(s.Ctx, teamAdmin.IdentityID(), team.TeamID().String(), invitations)
 
 		// then
 		require.NoError(t, err, "Error creating invitations")
-		require.Equal(t, uint64(1), s.notificationServiceMock.SendMessagesAsyncCounter)
+		messages := s.invitationNotifications(t, invitations)
 		require.Len(t, messages, 2)
 		require.Equal(t, invitee1ID.String(), messages[0].TargetID)
 		require.Contains(t, messages[0].Custom["acceptURL"], acceptInvitationEndpoint)
@@ -550,19 +504,12 @@ func (s *invitationServiceBlackBoxTest) TestIssueInvitation() {
 			},
 		}
 
-		var messages []notification.Message
-		*s.notificationServiceMock = *testservice.NewNotificationServiceMock(t)
-		s.notificationServiceMock.SendMessagesAsyncFunc = func(p context.Context, msgs []notification.Message, p2 ...rest.HTTPClientOption) (r chan error, r1 error) {
-			messages = msgs
-			return nil, nil
-		}
-
 		// when
 		err := s.Application.InvitationService().Issue(s.Ctx, teamAdmin.IdentityID(), team.TeamID().String(), invitations)
 
 		// then
 		require.NoError(t, err, "Error creating invitations")
-		require.Equal(t, uint64(1), s.notificationServiceMock.SendMessagesAsyncCounter)
+		messages := s.invitationNotifications(t, invitations)
 		require.Len(t, messages, 1)
 		require.Equal(t, id.String(), messages[0].TargetID)
 
@@ -602,19 +549,12 @@ func (s *invitationServiceBlackBoxTest) TestIssueInvitation() {
 			},
 		}
 
-		var messages []notification.Message
-		*s.notificationServiceMock = *testservice.NewNotificationServiceMock(t)
-		s.notificationServiceMock.SendMessagesAsyncFunc = func(p context.Context, msgs []notification.Message, p2 ...rest.HTTPClientOption) (r chan error, r1 error) {
-			messages = msgs
-			return nil, nil
-		}
-
 		// when
 		err := s.Application.InvitationService().Issue(s.Ctx, teamAdmin.IdentityID(), team.TeamID().String(), invitations)
 
 		//then
 		require.NoError(t, err)
-		require.Equal(t, uint64(1), s.notificationServiceMock.SendMessagesAsyncCounter)
+		messages := s.invitationNotifications(t, invitations)
 		require.Len(t, messages, 1)
 		require.Equal(t, id.String(), messages[0].TargetID)
 		require.Contains(t, messages[0].Custom["acceptURL"], acceptInvitationEndpoint)
@@ -649,19 +589,12 @@ func (s *invitationServiceBlackBoxTest) TestIssueInvitation() {
 			},
 		}
 
-		var messages []notification.Message
-		*s.notificationServiceMock = *testservice.NewNotificationServiceMock(t)
-		s.notificationServiceMock.SendMessagesAsyncFunc = func(p context.Context, msgs []notification.Message, p2 ...rest.HTTPClientOption) (r chan error, r1 error) {
-			messages = msgs
-			return nil, nil
-		}
-
 		// when
 		err := s.Application.InvitationService().Issue(s.Ctx, spaceAdmin.IdentityID(), space.SpaceID(), invitations)
 
 		// then
 		require.NoError(t, err)
-		require.Equal(t, uint64(1), s.notificationServiceMock.SendMessagesAsyncCounter)
+		messages := s.invitationNotifications(t, invitations)
 		require.Len(t, messages, 1)
 		require.Equal(t, id.String(), messages[0].TargetID)
 		require.Contains(t, messages[0].Custom["acceptURL"], acceptInvitationEndpoint)
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/fabric8-services/fabric8-auth/application/service/base"
 	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
@@ -11,11 +12,14 @@ import (
 	resource "github.com/fabric8-services/fabric8-auth/authorization/resource/repository"
 	role "github.com/fabric8-services/fabric8-auth/authorization/role/repository"
 	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
 	"github.com/fabric8-services/fabric8-auth/log"
 
 	"database/sql"
 
 	"github.com/fabric8-services/fabric8-auth/application/service"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
 	"github.com/satori/go.uuid"
 )
 
@@ -129,3 +133,380 @@ func (s *organizationServiceImpl) ListOrganizations(ctx context.Context, identit
 
 	return authorization.MergeAssociations(memberships, roles), nil
 }
+
+// UpdateOrganization renames the specified organization. The user must have the 'manage' scope for the organization.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *organizationServiceImpl) UpdateOrganization(ctx context.Context, identityID uuid.UUID, organizationID uuid.UUID, organizationName string) (*account.Identity, error) {
+	if strings.TrimSpace(organizationName) == "" {
+		return nil, errors.NewBadParameterErrorFromString("name", organizationName, "organization name cannot be empty")
+	}
+	var org *account.Identity
+	err := s.ExecuteInTransaction(func() error {
+		var err error
+		org, err = s.loadOrganization(ctx, organizationID)
+		if err != nil {
+			return err
+		}
+		err = s.Services().PermissionService().RequireScope(ctx, identityID, org.IdentityResourceID.String, authorization.ManageOrganizationScope)
+		if err != nil {
+			return err
+		}
+		org.IdentityResource.Name = organizationName
+		err = s.Repositories().ResourceRepository().Save(ctx, &org.IdentityResource)
+		if gormsupport.IsUniqueViolation(errs.Cause(err), "unique_organization_names") {
+			return errors.NewDataConflictError(fmt.Sprintf("an organization named '%s' already exists", organizationName))
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info(ctx, map[string]interface{}{
+		"organization_id": organizationID,
+		"identity_id":     identityID,
+	}, "organization renamed")
+	return org, nil
+}
+
+// DeleteOrganization deletes the specified organization, along with its memberships, the invitations to join it and
+// its role assignments. The user must have the 'manage' scope for the organization. An organization to which spaces
+// are attached cannot be deleted, unless detachSpaces is true in which case the spaces are detached from the
+// organization and kept.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *organizationServiceImpl) DeleteOrganization(ctx context.Context, identityID uuid.UUID, organizationID uuid.UUID, detachSpaces bool) error {
+	err := s.ExecuteInTransaction(func() error {
+		org, err := s.loadOrganization(ctx, organizationID)
+		if err != nil {
+			return err
+		}
+		resourceID := org.IdentityResourceID.String
+		err = s.Services().PermissionService().RequireScope(ctx, identityID, resourceID, authorization.ManageOrganizationScope)
+		if err != nil {
+			return err
+		}
+
+		// detach the spaces of the organization, which are not deleted along with it
+		children, err := s.Repositories().ResourceRepository().LoadChildren(ctx, resourceID)
+		if err != nil {
+			return err
+		}
+		if len(children) > 0 && !detachSpaces {
+			return errors.NewDataConflictError(fmt.Sprintf("organization %s still has %d attached space(s)", organizationID, len(children)))
+		}
+		for i := range children {
+			children[i].ParentResourceID = nil
+			err = s.Repositories().ResourceRepository().Save(ctx, &children[i])
+			if err != nil {
+				return err
+			}
+		}
+
+		// delete the invitations to join the organization or to accept a role in it
+		invitations, err := s.Repositories().InvitationRepository().ListForIdentity(ctx, organizationID)
+		if err != nil {
+			return err
+		}
+		resourceInvitations, err := s.Repositories().InvitationRepository().ListForResource(ctx, resourceID)
+		if err != nil {
+			return err
+		}
+		for _, invitation := range append(invitations, resourceInvitations...) {
+			err = s.Repositories().InvitationRepository().Delete(ctx, invitation.InvitationID)
+			if err != nil {
+				return err
+			}
+		}
+
+		// remove the members of the organization
+		members, err := s.Repositories().Identities().Query(organizationMembers(organizationID))
+		if err != nil {
+			return err
+		}
+		for _, member := range members {
+			err = s.Repositories().Identities().RemoveMember(ctx, organizationID, member.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		// delete the roles assigned in the organization one by one, so that the privilege caches are flagged as stale
+		identityRoles, err := s.Repositories().IdentityRoleRepository().FindIdentityRolesByResource(ctx, resourceID, false)
+		if err != nil {
+			return err
+		}
+		for _, identityRole := range identityRoles {
+			err = s.Repositories().IdentityRoleRepository().Delete(ctx, identityRole.IdentityRoleID)
+			if err != nil {
+				return err
+			}
+		}
+
+		return s.Services().ResourceService().Delete(ctx, resourceID)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Info(ctx, map[string]interface{}{
+		"organization_id": organizationID,
+		"identity_id":     identityID,
+	}, "organization deleted")
+	return nil
+}
+
+// ListOrganizationMembers returns the identities of the members of the specified organization, along with their user.
+// The user must have the 'view' scope for the organization.
+func (s *organizationServiceImpl) ListOrganizationMembers(ctx context.Context, identityID uuid.UUID, organizationID uuid.UUID) ([]account.Identity, error) {
+	org, err := s.loadOrganization(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.Services().PermissionService().RequireScope(ctx, identityID, org.IdentityResourceID.String, authorization.ViewOrganizationMembersScope)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repositories().Identities().Query(organizationMembers(organizationID), account.IdentityWithUser())
+}
+
+// RemoveOrganizationMember removes the specified member from the organization, along with the roles assigned to the
+// member in the organization. The user must have the 'manage' scope for the organization. The last administrator of
+// an organization cannot be removed, the ownership must be transferred first.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *organizationServiceImpl) RemoveOrganizationMember(ctx context.Context, identityID uuid.UUID, organizationID uuid.UUID, memberID uuid.UUID) error {
+	err := s.ExecuteInTransaction(func() error {
+		org, err := s.loadOrganization(ctx, organizationID)
+		if err != nil {
+			return err
+		}
+		resourceID := org.IdentityResourceID.String
+		err = s.Services().PermissionService().RequireScope(ctx, identityID, resourceID, authorization.ManageOrganizationMembersScope)
+		if err != nil {
+			return err
+		}
+
+		members, err := s.Repositories().Identities().Query(organizationMembers(organizationID), account.IdentityFilterByID(memberID))
+		if err != nil {
+			return err
+		}
+		identityRoles, err := s.Repositories().IdentityRoleRepository().FindIdentityRolesByIdentityAndResource(ctx, resourceID, memberID)
+		if err != nil {
+			return err
+		}
+		if len(members) == 0 && len(identityRoles) == 0 {
+			return errors.NewNotFoundErrorFromString(fmt.Sprintf("identity %s is not a member of organization %s", memberID, organizationID))
+		}
+
+		adminRole, err := s.Repositories().RoleRepository().Lookup(ctx, authorization.OrganizationAdminRole, authorization.IdentityResourceTypeOrganization)
+		if err != nil {
+			return err
+		}
+		for _, identityRole := range identityRoles {
+			if identityRole.RoleID == adminRole.RoleID {
+				admins, err := s.Repositories().IdentityRoleRepository().FindIdentityRolesByResourceAndRoleName(ctx, resourceID, authorization.OrganizationAdminRole, false)
+				if err != nil {
+					return err
+				}
+				if len(admins) <= 1 {
+					return errors.NewDataConflictError(fmt.Sprintf("identity %s is the owner of organization %s, the ownership must be transferred first", memberID, organizationID))
+				}
+			}
+			err = s.Repositories().IdentityRoleRepository().Delete(ctx, identityRole.IdentityRoleID)
+			if err != nil {
+				return err
+			}
+		}
+		if len(members) > 0 {
+			return s.Repositories().Identities().RemoveMember(ctx, organizationID, memberID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Info(ctx, map[string]interface{}{
+		"organization_id": organizationID,
+		"member_id":       memberID,
+		"identity_id":     identityID,
+	}, "organization member removed")
+	return nil
+}
+
+// TransferOrganizationOwnership assigns the owner (admin) role of the organization to the specified identity, which
+// must already be a member of the organization or have a role in it. The user must be an owner of the organization,
+// and is given the contributor role in place of the owner role.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *organizationServiceImpl) TransferOrganizationOwnership(ctx context.Context, identityID uuid.UUID, organizationID uuid.UUID, newOwnerID uuid.UUID) error {
+	if identityID == newOwnerID {
+		return errors.NewBadParameterErrorFromString("ownerID", newOwnerID, "the organization is already owned by this identity")
+	}
+	err := s.ExecuteInTransaction(func() error {
+		org, err := s.loadOrganization(ctx, organizationID)
+		if err != nil {
+			return err
+		}
+		resourceID := org.IdentityResourceID.String
+
+		adminRole, err := s.Repositories().RoleRepository().Lookup(ctx, authorization.OrganizationAdminRole, authorization.IdentityResourceTypeOrganization)
+		if err != nil {
+			return err
+		}
+
+		// only an owner can hand over the ownership
+		owner, err := s.hasOrganizationRole(ctx, identityID, resourceID, adminRole.RoleID)
+		if err != nil {
+			return err
+		}
+		if !owner {
+			return errors.NewForbiddenError(fmt.Sprintf("identity %s is not an owner of organization %s", identityID, organizationID))
+		}
+
+		members, err := s.Repositories().Identities().Query(organizationMembers(organizationID), account.IdentityFilterByID(newOwnerID))
+		if err != nil {
+			return err
+		}
+		identityRoles, err := s.Repositories().IdentityRoleRepository().FindIdentityRolesByIdentityAndResource(ctx, resourceID, newOwnerID)
+		if err != nil {
+			return err
+		}
+		if len(members) == 0 && len(identityRoles) == 0 {
+			return errors.NewBadParameterErrorFromString("ownerID", newOwnerID, "the new owner must be a member of the organization")
+		}
+
+		alreadyOwner, err := s.hasOrganizationRole(ctx, newOwnerID, resourceID, adminRole.RoleID)
+		if err != nil {
+			return err
+		}
+		if !alreadyOwner {
+			err = s.Services().RoleManagementService().ForceAssign(ctx, newOwnerID, authorization.OrganizationAdminRole, org.IdentityResource)
+			if err != nil {
+				return err
+			}
+		}
+		return s.Services().RoleManagementService().Assign(ctx, identityID, map[string][]uuid.UUID{
+			authorization.OrganizationContributorRole: {identityID},
+		}, resourceID, false)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Info(ctx, map[string]interface{}{
+		"organization_id": organizationID,
+		"owner_id":        newOwnerID,
+		"identity_id":     identityID,
+	}, "organization ownership transferred")
+	return nil
+}
+
+// AttachSpace attaches the specified space to the organization, as a child resource of the organization. The user
+// must have the 'manage' scope for both the organization and the space, and the space must not already be attached
+// to another resource.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *organizationServiceImpl) AttachSpace(ctx context.Context, identityID uuid.UUID, organizationID uuid.UUID, spaceID string) error {
+	err := s.ExecuteInTransaction(func() error {
+		org, err := s.loadOrganization(ctx, organizationID)
+		if err != nil {
+			return err
+		}
+		resourceID := org.IdentityResourceID.String
+		err = s.Services().PermissionService().RequireScope(ctx, identityID, resourceID, authorization.ManageOrganizationScope)
+		if err != nil {
+			return err
+		}
+
+		// Validate the space resource
+		space, err := s.Repositories().ResourceRepository().Load(ctx, spaceID)
+		if err != nil {
+			return errors.NewBadParameterErrorFromString("spaceID", spaceID, "invalid space ID specified")
+		}
+		if space.ResourceType.Name != authorization.ResourceTypeSpace {
+			return errors.NewBadParameterErrorFromString("spaceID", spaceID, "space ID specified is not a space resource")
+		}
+		err = s.Services().PermissionService().RequireScope(ctx, identityID, spaceID, authorization.ManageSpaceScope)
+		if err != nil {
+			return err
+		}
+		if space.ParentResourceID != nil {
+			if *space.ParentResourceID == resourceID {
+				return nil
+			}
+			return errors.NewDataConflictError(fmt.Sprintf("space %s is already attached to resource %s", spaceID, *space.ParentResourceID))
+		}
+
+		space.ParentResourceID = &resourceID
+		err = s.Repositories().ResourceRepository().Save(ctx, space)
+		if err != nil {
+			return err
+		}
+
+		// the members of the organization and the identities who have a role in it now inherit privileges in the space
+		members, err := s.Repositories().Identities().Query(organizationMembers(organizationID))
+		if err != nil {
+			return err
+		}
+		for _, member := range members {
+			err = s.Repositories().Identities().FlagPrivilegeCacheStaleForMembershipChange(ctx, member.ID, organizationID)
+			if err != nil {
+				return err
+			}
+		}
+		identityRoles, err := s.Repositories().IdentityRoleRepository().FindIdentityRolesByResource(ctx, resourceID, false)
+		if err != nil {
+			return err
+		}
+		for _, identityRole := range identityRoles {
+			err = s.Repositories().IdentityRoleRepository().FlagPrivilegeCacheStaleForIdentityRoleChange(ctx, identityRole.IdentityID, resourceID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Info(ctx, map[string]interface{}{
+		"organization_id": organizationID,
+		"space_id":        spaceID,
+		"identity_id":     identityID,
+	}, "space attached to organization")
+	return nil
+}
+
+// loadOrganization loads the identity of the specified organization, along with its resource
+func (s *organizationServiceImpl) loadOrganization(ctx context.Context, organizationID uuid.UUID) (*account.Identity, error) {
+	orgs, err := s.Repositories().Identities().Query(account.IdentityFilterByID(organizationID), func(db *gorm.DB) *gorm.DB {
+		return db.Preload("IdentityResource").Preload("IdentityResource.ResourceType")
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(orgs) == 0 || !orgs[0].IdentityResourceID.Valid || orgs[0].IdentityResource.ResourceType.Name != authorization.IdentityResourceTypeOrganization {
+		return nil, errors.NewNotFoundError("organization", organizationID.String())
+	}
+	return &orgs[0], nil
+}
+
+// hasOrganizationRole returns true if the identity has been directly assigned the role in the organization
+func (s *organizationServiceImpl) hasOrganizationRole(ctx context.Context, identityID uuid.UUID, resourceID string, roleID uuid.UUID) (bool, error) {
+	identityRoles, err := s.Repositories().IdentityRoleRepository().FindIdentityRolesByIdentityAndResource(ctx, resourceID, identityID)
+	if err != nil {
+		return false, err
+	}
+	for _, identityRole := range identityRoles {
+		if identityRole.RoleID == roleID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// organizationMembers is a gorm filter for the direct members of the organization
+func organizationMembers(organizationID uuid.UUID) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("id IN (SELECT member_id FROM membership WHERE member_of = ?)", organizationID)
+	}
+}
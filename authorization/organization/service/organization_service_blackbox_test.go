@@ -8,9 +8,11 @@ import (
 	"github.com/fabric8-services/fabric8-auth/authorization"
 	resource "github.com/fabric8-services/fabric8-auth/authorization/resource/repository"
 	role "github.com/fabric8-services/fabric8-auth/authorization/role/repository"
+	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
 	"github.com/fabric8-services/fabric8-auth/test"
 
+	errs "github.com/pkg/errors"
 	"github.com/satori/go.uuid"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -122,3 +124,202 @@ func (s *organizationServiceBlackBoxTest) equalOrganization(expectedOrgID uuid.U
 	require.Equal(s.T(), 1, len(actualOrg.Roles), "New organization should have assigned exactly 1 role")
 	require.Equal(s.T(), authorization.OrganizationAdminRole, actualOrg.Roles[0], "New organization should have assigned admin role")
 }
+
+func (s *organizationServiceBlackBoxTest) TestUpdateOrganization() {
+	g := s.NewTestGraph(s.T())
+	owner := g.CreateUser()
+	org := g.CreateOrganization(owner)
+
+	s.T().Run("ok", func(t *testing.T) {
+		orgName := "Organization-" + uuid.NewV4().String()
+		updated, err := s.orgService.UpdateOrganization(s.Ctx, owner.IdentityID(), org.OrganizationID(), orgName)
+		require.NoError(t, err)
+		require.Equal(t, orgName, updated.IdentityResource.Name)
+		res, err := s.resourceRepo.Load(s.Ctx, org.ResourceID())
+		require.NoError(t, err)
+		require.Equal(t, orgName, res.Name)
+	})
+
+	s.T().Run("duplicate name", func(t *testing.T) {
+		other := g.CreateOrganization()
+		_, err := s.orgService.UpdateOrganization(s.Ctx, owner.IdentityID(), org.OrganizationID(), other.OrganizationName())
+		require.IsType(t, errors.DataConflictError{}, errs.Cause(err))
+	})
+
+	s.T().Run("empty name", func(t *testing.T) {
+		_, err := s.orgService.UpdateOrganization(s.Ctx, owner.IdentityID(), org.OrganizationID(), "")
+		require.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+	})
+
+	s.T().Run("forbidden for member", func(t *testing.T) {
+		member := g.CreateUser()
+		org.AddMember(member)
+		_, err := s.orgService.UpdateOrganization(s.Ctx, member.IdentityID(), org.OrganizationID(), "Renamed-"+uuid.NewV4().String())
+		require.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+	})
+
+	s.T().Run("unknown organization", func(t *testing.T) {
+		_, err := s.orgService.UpdateOrganization(s.Ctx, owner.IdentityID(), uuid.NewV4(), "Renamed")
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}
+
+func (s *organizationServiceBlackBoxTest) TestDeleteOrganization() {
+	g := s.NewTestGraph(s.T())
+	owner := g.CreateUser()
+
+	s.T().Run("ok", func(t *testing.T) {
+		org := g.CreateOrganization(owner).AddMember(g.CreateUser(g.ID("member")))
+		invitation := g.CreateInvitation(org, g.CreateUser())
+
+		err := s.orgService.DeleteOrganization(s.Ctx, owner.IdentityID(), org.OrganizationID(), false)
+		require.NoError(t, err)
+
+		_, err = s.identityRepo.Load(s.Ctx, org.OrganizationID())
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+		_, err = s.resourceRepo.Load(s.Ctx, org.ResourceID())
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+		_, err = s.Application.InvitationRepository().Load(s.Ctx, invitation.Invitation().InvitationID)
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+		orgs, err := s.orgService.ListOrganizations(s.Ctx, g.UserByID("member").IdentityID())
+		require.NoError(t, err)
+		require.Empty(t, orgs)
+		orgs, err = s.orgService.ListOrganizations(s.Ctx, owner.IdentityID())
+		require.NoError(t, err)
+		require.Empty(t, orgs)
+	})
+
+	s.T().Run("attached spaces", func(t *testing.T) {
+		org := g.CreateOrganization(owner)
+		spc := g.CreateSpace().AddAdmin(owner)
+		err := s.orgService.AttachSpace(s.Ctx, owner.IdentityID(), org.OrganizationID(), spc.SpaceID())
+		require.NoError(t, err)
+
+		// deletion is refused while spaces are attached
+		err = s.orgService.DeleteOrganization(s.Ctx, owner.IdentityID(), org.OrganizationID(), false)
+		require.IsType(t, errors.DataConflictError{}, errs.Cause(err))
+
+		// the spaces are kept when they are detached
+		err = s.orgService.DeleteOrganization(s.Ctx, owner.IdentityID(), org.OrganizationID(), true)
+		require.NoError(t, err)
+		res, err := s.resourceRepo.Load(s.Ctx, spc.SpaceID())
+		require.NoError(t, err)
+		require.Nil(t, res.ParentResourceID)
+	})
+
+	s.T().Run("forbidden", func(t *testing.T) {
+		org := g.CreateOrganization(owner)
+		err := s.orgService.DeleteOrganization(s.Ctx, g.CreateUser().IdentityID(), org.OrganizationID(), false)
+		require.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+	})
+}
+
+func (s *organizationServiceBlackBoxTest) TestListAndRemoveOrganizationMembers() {
+	g := s.NewTestGraph(s.T())
+	owner := g.CreateUser()
+	org := g.CreateOrganization(owner).AddMember(g.CreateUser(g.ID("m1"))).AddMember(g.CreateUser(g.ID("m2")))
+
+	s.T().Run("list", func(t *testing.T) {
+		members, err := s.orgService.ListOrganizationMembers(s.Ctx, owner.IdentityID(), org.OrganizationID())
+		require.NoError(t, err)
+		require.Len(t, members, 2)
+	})
+
+	s.T().Run("list forbidden", func(t *testing.T) {
+		_, err := s.orgService.ListOrganizationMembers(s.Ctx, g.CreateUser().IdentityID(), org.OrganizationID())
+		require.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+	})
+
+	s.T().Run("remove forbidden for member", func(t *testing.T) {
+		err := s.orgService.RemoveOrganizationMember(s.Ctx, g.UserByID("m2").IdentityID(), org.OrganizationID(), g.UserByID("m1").IdentityID())
+		require.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+	})
+
+	s.T().Run("remove ok", func(t *testing.T) {
+		err := s.orgService.RemoveOrganizationMember(s.Ctx, owner.IdentityID(), org.OrganizationID(), g.UserByID("m1").IdentityID())
+		require.NoError(t, err)
+		members, err := s.orgService.ListOrganizationMembers(s.Ctx, owner.IdentityID(), org.OrganizationID())
+		require.NoError(t, err)
+		require.Len(t, members, 1)
+		require.Equal(t, g.UserByID("m2").IdentityID(), members[0].ID)
+	})
+
+	s.T().Run("remove non member", func(t *testing.T) {
+		err := s.orgService.RemoveOrganizationMember(s.Ctx, owner.IdentityID(), org.OrganizationID(), g.CreateUser().IdentityID())
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+
+	s.T().Run("remove last owner", func(t *testing.T) {
+		err := s.orgService.RemoveOrganizationMember(s.Ctx, owner.IdentityID(), org.OrganizationID(), owner.IdentityID())
+		require.IsType(t, errors.DataConflictError{}, errs.Cause(err))
+	})
+}
+
+func (s *organizationServiceBlackBoxTest) TestTransferOrganizationOwnership() {
+	g := s.NewTestGraph(s.T())
+	owner := g.CreateUser()
+	member := g.CreateUser()
+	org := g.CreateOrganization(owner).AddMember(member)
+
+	s.T().Run("forbidden for member", func(t *testing.T) {
+		err := s.orgService.TransferOrganizationOwnership(s.Ctx, member.IdentityID(), org.OrganizationID(), owner.IdentityID())
+		require.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+	})
+
+	s.T().Run("new owner must be a member", func(t *testing.T) {
+		err := s.orgService.TransferOrganizationOwnership(s.Ctx, owner.IdentityID(), org.OrganizationID(), g.CreateUser().IdentityID())
+		require.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+	})
+
+	s.T().Run("ok", func(t *testing.T) {
+		err := s.orgService.TransferOrganizationOwnership(s.Ctx, owner.IdentityID(), org.OrganizationID(), member.IdentityID())
+		require.NoError(t, err)
+
+		admins, err := s.identityRoleRepo.FindIdentityRolesByResourceAndRoleName(s.Ctx, org.ResourceID(), authorization.OrganizationAdminRole, false)
+		require.NoError(t, err)
+		require.Len(t, admins, 1)
+		require.Equal(t, member.IdentityID(), admins[0].IdentityID)
+		contributors, err := s.identityRoleRepo.FindIdentityRolesByResourceAndRoleName(s.Ctx, org.ResourceID(), authorization.OrganizationContributorRole, false)
+		require.NoError(t, err)
+		require.Len(t, contributors, 1)
+		require.Equal(t, owner.IdentityID(), contributors[0].IdentityID)
+
+		// the former owner can no longer manage the organization
+		_, err = s.orgService.UpdateOrganization(s.Ctx, owner.IdentityID(), org.OrganizationID(), "Renamed-"+uuid.NewV4().String())
+		require.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+	})
+}
+
+func (s *organizationServiceBlackBoxTest) TestAttachSpace() {
+	g := s.NewTestGraph(s.T())
+	owner := g.CreateUser()
+	org := g.CreateOrganization(owner)
+
+	s.T().Run("ok", func(t *testing.T) {
+		spc := g.CreateSpace().AddAdmin(owner)
+		err := s.orgService.AttachSpace(s.Ctx, owner.IdentityID(), org.OrganizationID(), spc.SpaceID())
+		require.NoError(t, err)
+		res, err := s.resourceRepo.Load(s.Ctx, spc.SpaceID())
+		require.NoError(t, err)
+		require.Equal(t, org.ResourceID(), *res.ParentResourceID)
+	})
+
+	s.T().Run("forbidden for space", func(t *testing.T) {
+		err := s.orgService.AttachSpace(s.Ctx, owner.IdentityID(), org.OrganizationID(), g.CreateSpace().SpaceID())
+		require.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+	})
+
+	s.T().Run("not a space", func(t *testing.T) {
+		err := s.orgService.AttachSpace(s.Ctx, owner.IdentityID(), org.OrganizationID(), g.CreateOrganization(owner).ResourceID())
+		require.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+	})
+
+	s.T().Run("already attached elsewhere", func(t *testing.T) {
+		spc := g.CreateSpace().AddAdmin(owner)
+		other := g.CreateOrganization(owner)
+		err := s.orgService.AttachSpace(s.Ctx, owner.IdentityID(), other.OrganizationID(), spc.SpaceID())
+		require.NoError(t, err)
+		err = s.orgService.AttachSpace(s.Ctx, owner.IdentityID(), org.OrganizationID(), spc.SpaceID())
+		require.IsType(t, errors.DataConflictError{}, errs.Cause(err))
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"fmt"
+	"github.com/fabric8-services/fabric8-auth/authorization/token"
 	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/gormsupport"
 	"github.com/fabric8-services/fabric8-auth/log"
@@ -70,6 +71,7 @@ type PrivilegeCacheRepository interface {
 	Save(ctx context.Context, cache *PrivilegeCache) error
 	Delete(ctx context.Context, privilegeCacheID uuid.UUID) error
 	FindForIdentityResource(ctx context.Context, identityID uuid.UUID, resourceID string) (*PrivilegeCache, error)
+	FlagStaleForIdentity(ctx context.Context, identityID uuid.UUID) error
 }
 
 // CheckExists returns true if the given ID exists otherwise returns an error
@@ -192,3 +194,35 @@ func (m *GormPrivilegeCacheRepository) FindForIdentityResource(ctx context.Conte
 
 	return &native, errs.WithStack(err)
 }
+
+// FlagStaleForIdentity sets the stale flag to true for all the privilege cache records of the given identity, whatever
+// the resource, along with the STALE flag of the tokens which are mapped to these records via the TOKEN_PRIVILEGE table
+func (m *GormPrivilegeCacheRepository) FlagStaleForIdentity(ctx context.Context, identityID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "privilege_cache", "FlagStaleForIdentity"}, time.Now())
+
+	result := m.db.Exec("UPDATE privilege_cache SET stale = true WHERE identity_id = ? AND deleted_at IS NULL", identityID)
+	if result.Error != nil {
+		return errors.NewInternalError(result.Error)
+	}
+
+	err := m.db.Exec(`UPDATE token t SET
+  STATUS = STATUS | ? /* TOKEN_STATUS_STALE */
+FROM
+  token_privilege tp,
+  privilege_cache pc
+WHERE
+  t.token_id = tp.token_id
+  AND tp.privilege_cache_id = pc.privilege_cache_id
+  AND pc.identity_id = ? /* IDENTITY_ID */
+  AND pc.deleted_at IS NULL`, token.TOKEN_STATUS_STALE, identityID).Error
+	if err != nil {
+		return errors.NewInternalError(err)
+	}
+
+	log.Debug(ctx, map[string]interface{}{
+		"identity_id":       identityID,
+		"rows_marked_stale": result.RowsAffected,
+	}, "Privilege cache rows marked stale")
+
+	return nil
+}
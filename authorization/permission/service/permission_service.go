@@ -7,19 +7,30 @@ import (
 	"github.com/fabric8-services/fabric8-auth/application/service"
 	"github.com/fabric8-services/fabric8-auth/application/service/base"
 	servicecontext "github.com/fabric8-services/fabric8-auth/application/service/context"
+	"github.com/fabric8-services/fabric8-auth/authorization/token"
 	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
 	"github.com/satori/go.uuid"
 )
 
+// PermissionServiceConfiguration the required configuration for the permission service implementation
+type PermissionServiceConfiguration interface {
+	GetMFARequiredScopes() []string
+}
+
 // permissionServiceImpl is the implementation of the interface for
 // PermissionModelService. IMPORTANT NOTE: Transaction control is not provided by this service
 type permissionServiceImpl struct {
 	base.BaseService
+	config PermissionServiceConfiguration
 }
 
 // NewPermissionModelService creates a new service.
-func NewPermissionService(context servicecontext.ServiceContext) service.PermissionService {
-	return &permissionServiceImpl{base.NewBaseService(context)}
+func NewPermissionService(context servicecontext.ServiceContext, config PermissionServiceConfiguration) service.PermissionService {
+	return &permissionServiceImpl{
+		BaseService: base.NewBaseService(context),
+		config:      config,
+	}
 }
 
 // HasScope does a permission check for a user, to determine whether they have a particular scope for the
@@ -40,7 +51,8 @@ func (s *permissionServiceImpl) HasScope(ctx context.Context, identityID uuid.UU
 }
 
 // RequireScope is the same as HasScope, except instead of returning a boolean value it will just return an error if the
-// identity does not have the specified scope for the resource
+// identity does not have the specified scope for the resource. If the scope is one of the scopes configured to require
+// multi-factor authentication, then the request must also have been made with a multi-factor authenticated token.
 func (s *permissionServiceImpl) RequireScope(ctx context.Context, identityID uuid.UUID, resourceID string, scopeName string) error {
 	result, err := s.HasScope(ctx, identityID, resourceID, scopeName)
 	if err != nil {
@@ -51,5 +63,24 @@ func (s *permissionServiceImpl) RequireScope(ctx context.Context, identityID uui
 		return errors.NewForbiddenError(fmt.Sprintf("identity with ID %s does not have required scope %s for resource %s", identityID.String(), scopeName, resourceID))
 	}
 
+	if s.requiresMultiFactorAuthentication(scopeName) && !token.IsMultiFactorAuthenticated(ctx) {
+		log.Warn(ctx, map[string]interface{}{
+			"identity_id": identityID,
+			"resource_id": resourceID,
+			"scope":       scopeName,
+		}, "scope requires a multi-factor authenticated token")
+		return errors.NewForbiddenError(fmt.Sprintf("scope %s for resource %s requires multi-factor authentication", scopeName, resourceID))
+	}
+
 	return nil
 }
+
+// requiresMultiFactorAuthentication returns true if the given scope is configured to require multi-factor authentication
+func (s *permissionServiceImpl) requiresMultiFactorAuthentication(scopeName string) bool {
+	for _, scope := range s.config.GetMFARequiredScopes() {
+		if scope == scopeName {
+			return true
+		}
+	}
+	return false
+}
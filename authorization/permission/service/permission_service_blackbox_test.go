@@ -3,8 +3,14 @@ package service_test
 import (
 	"testing"
 
+	"github.com/fabric8-services/fabric8-auth/application/service/factory"
+	permissionservice "github.com/fabric8-services/fabric8-auth/authorization/permission/service"
+	"github.com/fabric8-services/fabric8-auth/authorization/token"
+	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
 
+	jwt "github.com/dgrijalva/jwt-go"
+	goajwt "github.com/goadesign/goa/middleware/security/jwt"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
@@ -356,3 +362,46 @@ func (s *PermissionServiceTestSuite) TestHasScope() {
 	})
 
 }
+
+type mfaRequiredScopesConfig struct {
+	scopes []string
+}
+
+func (c mfaRequiredScopesConfig) GetMFARequiredScopes() []string {
+	return c.scopes
+}
+
+func (s *PermissionServiceTestSuite) TestRequireScopeWithMultiFactorAuthentication() {
+	// given
+	permissionService := permissionservice.NewPermissionService(factory.NewServiceContext(s.Application, s.Application, nil, nil),
+		mfaRequiredScopesConfig{scopes: []string{"test-mfa-scope"}})
+	g := s.NewTestGraph(s.T())
+	identity := g.CreateIdentity()
+	resourceType := g.CreateResourceType()
+	role := g.CreateRole(resourceType, "test-role").AddScope("test-scope").AddScope("test-mfa-scope")
+	resource := g.CreateResource(resourceType).AddRole(identity, role)
+	singleFactorCtx := goajwt.WithJWT(s.Ctx, jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"acr": token.ACRSingleFactor}))
+	multiFactorCtx := goajwt.WithJWT(s.Ctx, jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"acr": token.ACRMultiFactor}))
+
+	s.T().Run("single factor token is rejected", func(t *testing.T) {
+		err := permissionService.RequireScope(singleFactorCtx, identity.ID(), resource.ResourceID(), "test-mfa-scope")
+		require.Error(t, err)
+		require.IsType(t, errors.ForbiddenError{}, err)
+	})
+
+	s.T().Run("multi-factor token is accepted", func(t *testing.T) {
+		err := permissionService.RequireScope(multiFactorCtx, identity.ID(), resource.ResourceID(), "test-mfa-scope")
+		require.NoError(t, err)
+	})
+
+	s.T().Run("single factor token is accepted for other scopes", func(t *testing.T) {
+		err := permissionService.RequireScope(singleFactorCtx, identity.ID(), resource.ResourceID(), "test-scope")
+		require.NoError(t, err)
+	})
+
+	s.T().Run("multi-factor token does not grant missing scope", func(t *testing.T) {
+		err := permissionService.RequireScope(multiFactorCtx, g.CreateIdentity().ID(), resource.ResourceID(), "test-mfa-scope")
+		require.Error(t, err)
+		require.IsType(t, errors.ForbiddenError{}, err)
+	})
+}
@@ -215,13 +215,11 @@ func (m *GormResourceRepository) FindWithRoleByResourceTypeAndIdentity(ctx conte
 			WHERE a.ancestor_resource_id IS NOT NULL
 			  AND r.deleted_at IS NULL
 		),
-		/* list the identities of the teams to which the current user belongs, plus herself's identity */
+		/* list the identities of the teams to which the current user belongs, directly or transitively */
 		teams AS ( 
 			SELECT member_of as "id"
-			FROM membership
+			FROM membership_closure
 			WHERE member_id = $1 /* user's identity */
-			UNION SELECT m.member_of
-			FROM membership m INNER JOIN teams ON teams.id = m.member_id
 		)
 
 		/* list the roles on resources of the given type when the user has a direct role */
@@ -68,6 +68,7 @@ type IdentityRoleRepository interface {
 	Delete(ctx context.Context, ID uuid.UUID) error
 	DeleteForResource(ctx context.Context, resourceID string) error
 	DeleteForIdentityAndResource(ctx context.Context, resourceID string, identityID uuid.UUID) error
+	TransferToIdentity(ctx context.Context, fromIdentityID uuid.UUID, toIdentityID uuid.UUID) error
 	FindPermissions(ctx context.Context, identityID uuid.UUID, resourceID string, scopeName string) ([]IdentityRole, error)
 	FindIdentityRolesForIdentity(ctx context.Context, identityID uuid.UUID, resourceType *string) ([]authorization.IdentityAssociation, error)
 	FindIdentityRolesByResourceAndRoleName(ctx context.Context, resourceID string, roleName string, includeParenResources bool) ([]IdentityRole, error)
@@ -236,19 +237,12 @@ func (m *GormIdentityRoleRepository) FindPermissions(ctx context.Context, identi
   WHERE
     id = ? /* IDENTITY_ID */
     OR id IN (
-    WITH RECURSIVE m AS (
-      SELECT 
-        member_of 
-      FROM 
-        membership 
-      WHERE 
+      SELECT
+        member_of
+      FROM
+        membership_closure
+      WHERE
         member_id = ? /* IDENTITY_ID */
-      UNION SELECT 
-        p.member_of 
-      FROM 
-        membership p INNER JOIN m ON m.member_of = p.member_id
-    ) 
-    SELECT member_of FROM m
     )
   )
   AND resource_id IN (
@@ -388,10 +382,8 @@ func (m *GormIdentityRoleRepository) FindIdentityRolesForIdentity(ctx context.Co
 	}
 	q = q.Joins("JOIN role ON role.role_id = identity_role.role_id")
 
-	rows, err := q.Where(`(identity_role.identity_id = ? OR identity_role.identity_id IN (WITH RECURSIVE m AS (
-			SELECT member_of FROM	membership WHERE member_id = ? 
-      UNION SELECT p.member_of	FROM membership p INNER JOIN m ON m.member_of = p.member_id)
-		  SELECT member_of FROM m))`, identityID, identityID).Rows()
+	rows, err := q.Where(`(identity_role.identity_id = ? OR identity_role.identity_id IN (
+			SELECT member_of FROM membership_closure WHERE member_id = ?))`, identityID, identityID).Rows()
 
 	if err != nil {
 		return nil, err
@@ -512,6 +504,39 @@ func (m *GormIdentityRoleRepository) DeleteForIdentityAndResource(ctx context.Co
 	return nil
 }
 
+// TransferToIdentity assigns the roles of an identity to another identity. The roles which the other identity already
+// has on the same resources are deleted rather than transferred, so that each role is assigned only once.
+// The privilege cache is not notified, since all the privileges of both identities change at once.
+func (m *GormIdentityRoleRepository) TransferToIdentity(ctx context.Context, fromIdentityID uuid.UUID, toIdentityID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "identity_role", "transferToIdentity"}, time.Now())
+	err := m.db.Where(`identity_id = ? AND EXISTS (
+		SELECT 1 FROM identity_role t WHERE t.identity_id = ? AND t.resource_id = identity_role.resource_id
+		AND t.role_id = identity_role.role_id AND t.deleted_at IS NULL)`, fromIdentityID, toIdentityID).Delete(&IdentityRole{}).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"from_identity_id": fromIdentityID,
+			"to_identity_id":   toIdentityID,
+			"err":              err,
+		}, "unable to delete the duplicate identity roles")
+		return errs.WithStack(err)
+	}
+	result := m.db.Model(&IdentityRole{}).Where("identity_id = ?", fromIdentityID).Update("identity_id", toIdentityID)
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"from_identity_id": fromIdentityID,
+			"to_identity_id":   toIdentityID,
+			"err":              result.Error,
+		}, "unable to transfer the identity roles")
+		return errs.WithStack(result.Error)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"from_identity_id": fromIdentityID,
+		"to_identity_id":   toIdentityID,
+		"transferred":      result.RowsAffected,
+	}, "Identity roles transferred!")
+	return nil
+}
+
 // FindIdentityRolesByIdentityAndResource returns all identity roles by identity ID and resource ID
 func (m *GormIdentityRoleRepository) FindIdentityRolesByIdentityAndResource(ctx context.Context, resourceID string, identityID uuid.UUID) ([]IdentityRole, error) {
 	return m.query(identityRoleFilterByIdentityID(identityID), identityRoleFilterByResource(resourceID))
@@ -529,22 +554,12 @@ func (m *GormIdentityRoleRepository) FindScopesByIdentityAndResource(ctx context
 
 	err := m.db.Raw(`WITH identity_resource_roles AS (
 	WITH identity_hierarchy AS (
-		WITH RECURSIVE m AS (
-			SELECT
-		    member_of
-		  FROM
-		    membership
-		  WHERE
-		    member_id = ? /* IDENTITY_ID */
-		  UNION SELECT
-		    p.member_of
-		  FROM
-		    membership p INNER JOIN m ON m.member_of = p.member_id
-		)
 		SELECT
 		  member_of AS identity_id
-		FROM 
-		  m
+		FROM
+		  membership_closure
+		WHERE
+		  member_id = ? /* IDENTITY_ID */
 		UNION SELECT
 		  id
 		FROM
@@ -708,22 +723,12 @@ func (m *GormIdentityRoleRepository) FlagPrivilegeCacheStaleForIdentityRoleChang
 	defer goa.MeasureSince([]string{"goa", "db", "identity_role", "FlagPrivilegeCacheStaleForIdentityRoleChange"}, time.Now())
 
 	result := m.db.Exec(`WITH identity_hierarchy AS (
-	WITH RECURSIVE m AS (
 	  SELECT
-	    member_id
+	    member_id AS identity_id
 	  FROM
-	    membership
+	    membership_closure
 	  WHERE
 	    member_of = ? /* IDENTITY_ID */
-	  UNION SELECT
-	    p.member_id
-	  FROM
-	    membership p INNER JOIN m ON m.member_id = p.member_of
-	  )
-	  SELECT
-	    member_id AS identity_id
-	  FROM 
-	    m
 	  UNION SELECT
 	    id
 	  FROM
@@ -766,22 +771,12 @@ WHERE
 	}, "Privilege cache rows marked stale")
 
 	result = m.db.Exec(`WITH identity_hierarchy AS (
-	WITH RECURSIVE m AS (
 	  SELECT
-	    member_id
+	    member_id AS identity_id
 	  FROM
-	    membership
+	    membership_closure
 	  WHERE
 	    member_of = ? /* IDENTITY_ID */
-	  UNION SELECT
-	    p.member_id
-	  FROM
-	    membership p INNER JOIN m ON m.member_id = p.member_of
-	  )
-	  SELECT
-	    member_id AS identity_id
-	  FROM 
-	    m
 	  UNION SELECT
 	    id
 	  FROM
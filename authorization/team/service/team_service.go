@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	"github.com/fabric8-services/fabric8-auth/application/service"
 	"github.com/fabric8-services/fabric8-auth/application/service/base"
@@ -13,6 +14,8 @@ import (
 	resource "github.com/fabric8-services/fabric8-auth/authorization/resource/repository"
 	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/jinzhu/gorm"
 	"github.com/satori/go.uuid"
 )
 
@@ -173,3 +176,333 @@ func (s *teamServiceImpl) ListTeamsForIdentity(ctx context.Context, identityID u
 
 	return authorization.MergeAssociations(memberships, roles), nil
 }
+
+// UpdateTeam renames the specified team. The user must be an administrator of the team, or have the 'manage' scope
+// for the space of the team.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *teamServiceImpl) UpdateTeam(ctx context.Context, identityID uuid.UUID, teamID uuid.UUID, teamName string) (*account.Identity, error) {
+	if strings.TrimSpace(teamName) == "" {
+		return nil, errors.NewBadParameterErrorFromString("name", teamName, "team name cannot be empty")
+	}
+	var team *account.Identity
+	err := s.ExecuteInTransaction(func() error {
+		var err error
+		team, err = s.loadTeam(ctx, teamID)
+		if err != nil {
+			return err
+		}
+		err = s.requireTeamScope(ctx, identityID, team, authorization.ManageTeamMembersScope, authorization.ManageTeamsInSpaceScope)
+		if err != nil {
+			return err
+		}
+		team.IdentityResource.Name = teamName
+		return s.Repositories().ResourceRepository().Save(ctx, &team.IdentityResource)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info(ctx, map[string]interface{}{
+		"team_id":     teamID,
+		"identity_id": identityID,
+	}, "team renamed")
+	return team, nil
+}
+
+// DeleteTeam deletes the specified team, along with its memberships, the invitations to join it and its role
+// assignments. The user must be an administrator of the team, or have the 'manage' scope for the space of the team.
+// The privilege caches of the former members and of the identities who had a role in the team are flagged as stale.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *teamServiceImpl) DeleteTeam(ctx context.Context, identityID uuid.UUID, teamID uuid.UUID) error {
+	err := s.ExecuteInTransaction(func() error {
+		team, err := s.loadTeam(ctx, teamID)
+		if err != nil {
+			return err
+		}
+		err = s.requireTeamScope(ctx, identityID, team, authorization.ManageTeamMembersScope, authorization.ManageTeamsInSpaceScope)
+		if err != nil {
+			return err
+		}
+
+		// delete the invitations to join the team or to accept a role in it
+		invitations, err := s.Repositories().InvitationRepository().ListForIdentity(ctx, teamID)
+		if err != nil {
+			return err
+		}
+		resourceInvitations, err := s.Repositories().InvitationRepository().ListForResource(ctx, team.IdentityResourceID.String)
+		if err != nil {
+			return err
+		}
+		for _, invitation := range append(invitations, resourceInvitations...) {
+			err = s.Repositories().InvitationRepository().Delete(ctx, invitation.InvitationID)
+			if err != nil {
+				return err
+			}
+		}
+
+		// remove the members of the team, and the team from the identities it is a member of
+		members, err := s.Repositories().Identities().Query(teamMembers(teamID))
+		if err != nil {
+			return err
+		}
+		for _, member := range members {
+			err = s.Repositories().Identities().RemoveMember(ctx, teamID, member.ID)
+			if err != nil {
+				return err
+			}
+		}
+		parents, err := s.Repositories().Identities().Query(func(db *gorm.DB) *gorm.DB {
+			return db.Where("id IN (SELECT member_of FROM membership WHERE member_id = ?)", teamID)
+		})
+		if err != nil {
+			return err
+		}
+		for _, parent := range parents {
+			err = s.Repositories().Identities().RemoveMember(ctx, parent.ID, teamID)
+			if err != nil {
+				return err
+			}
+		}
+
+		// delete the roles assigned in the team, and the roles assigned to the team in other resources
+		err = s.deleteIdentityRoles(ctx, team.IdentityResourceID.String, false)
+		if err != nil {
+			return err
+		}
+		associations, err := s.Repositories().IdentityRoleRepository().FindIdentityRolesForIdentity(ctx, teamID, nil)
+		if err != nil {
+			return err
+		}
+		for _, association := range associations {
+			err = s.deleteTeamRoles(ctx, teamID, association.ResourceID)
+			if err != nil {
+				return err
+			}
+		}
+
+		return s.Services().ResourceService().Delete(ctx, team.IdentityResourceID.String)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Info(ctx, map[string]interface{}{
+		"team_id":     teamID,
+		"identity_id": identityID,
+	}, "team deleted")
+	return nil
+}
+
+// ListTeamMembers returns the identities of the members of the specified team, along with their user. The user must
+// have the 'view' scope for the team or for the space of the team.
+func (s *teamServiceImpl) ListTeamMembers(ctx context.Context, identityID uuid.UUID, teamID uuid.UUID) ([]account.Identity, error) {
+	team, err := s.loadTeam(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.requireTeamScope(ctx, identityID, team, authorization.ViewTeamMembersScope, authorization.ViewTeamsInSpaceScope)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repositories().Identities().Query(teamMembers(teamID), account.IdentityWithUser())
+}
+
+// RemoveTeamMember removes the specified member from the team, along with the roles assigned to the member in the team.
+// The user must be an administrator of the team, or have the 'manage' scope for the space of the team.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *teamServiceImpl) RemoveTeamMember(ctx context.Context, identityID uuid.UUID, teamID uuid.UUID, memberID uuid.UUID) error {
+	err := s.ExecuteInTransaction(func() error {
+		team, err := s.loadTeam(ctx, teamID)
+		if err != nil {
+			return err
+		}
+		err = s.requireTeamScope(ctx, identityID, team, authorization.ManageTeamMembersScope, authorization.ManageTeamsInSpaceScope)
+		if err != nil {
+			return err
+		}
+		members, err := s.Repositories().Identities().Query(teamMembers(teamID), account.IdentityFilterByID(memberID))
+		if err != nil {
+			return err
+		}
+		if len(members) == 0 {
+			return errors.NewNotFoundErrorFromString(fmt.Sprintf("identity %s is not a member of team %s", memberID, teamID))
+		}
+		err = s.Repositories().Identities().RemoveMember(ctx, teamID, memberID)
+		if err != nil {
+			return err
+		}
+		return s.deleteTeamRoles(ctx, memberID, team.IdentityResourceID.String)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Info(ctx, map[string]interface{}{
+		"team_id":     teamID,
+		"member_id":   memberID,
+		"identity_id": identityID,
+	}, "team member removed")
+	return nil
+}
+
+// TransferTeam moves the specified team to another space. The user must be an administrator of the team or have the
+// 'manage' scope for its current space, and must have the 'manage' scope for the target space. The roles assigned
+// to the team in its former space are removed, and the privilege caches of its members are flagged as stale.
+// IMPORTANT: This is a transactional method, which manages its own transaction/s internally
+func (s *teamServiceImpl) TransferTeam(ctx context.Context, identityID uuid.UUID, teamID uuid.UUID, spaceID string) (*account.Identity, error) {
+	var team *account.Identity
+	err := s.ExecuteInTransaction(func() error {
+		var err error
+		team, err = s.loadTeam(ctx, teamID)
+		if err != nil {
+			return err
+		}
+		err = s.requireTeamScope(ctx, identityID, team, authorization.ManageTeamMembersScope, authorization.ManageTeamsInSpaceScope)
+		if err != nil {
+			return err
+		}
+
+		// Validate the target space resource
+		space, err := s.Repositories().ResourceRepository().Load(ctx, spaceID)
+		if err != nil {
+			return errors.NewBadParameterErrorFromString("spaceID", spaceID, "invalid space ID specified")
+		}
+		if space.ResourceType.Name != authorization.ResourceTypeSpace {
+			return errors.NewBadParameterErrorFromString("spaceID", spaceID, "space ID specified is not a space resource")
+		}
+		err = s.Services().PermissionService().RequireScope(ctx, identityID, spaceID, authorization.ManageTeamsInSpaceScope)
+		if err != nil {
+			return err
+		}
+		formerSpaceID := team.IdentityResource.ParentResourceID
+		if formerSpaceID != nil && *formerSpaceID == spaceID {
+			return nil
+		}
+
+		// remove the roles assigned to the team in its former space and in the resources of that space
+		if formerSpaceID != nil {
+			associations, err := s.Repositories().IdentityRoleRepository().FindIdentityRolesForIdentity(ctx, teamID, nil)
+			if err != nil {
+				return err
+			}
+			for _, association := range associations {
+				if association.ResourceID == *formerSpaceID || (association.ParentResourceID != nil && *association.ParentResourceID == *formerSpaceID) {
+					err = s.deleteTeamRoles(ctx, teamID, association.ResourceID)
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		team.IdentityResource.ParentResourceID = &spaceID
+		team.IdentityResource.ParentResource = nil
+		err = s.Repositories().ResourceRepository().Save(ctx, &team.IdentityResource)
+		if err != nil {
+			return err
+		}
+
+		// the privileges inherited through the resource hierarchy have changed for the members of the team and for
+		// the identities who have a role in the team
+		members, err := s.Repositories().Identities().Query(teamMembers(teamID))
+		if err != nil {
+			return err
+		}
+		for _, member := range members {
+			err = s.Repositories().Identities().FlagPrivilegeCacheStaleForMembershipChange(ctx, member.ID, teamID)
+			if err != nil {
+				return err
+			}
+		}
+		return s.deleteIdentityRoles(ctx, team.IdentityResourceID.String, true)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info(ctx, map[string]interface{}{
+		"team_id":     teamID,
+		"space_id":    spaceID,
+		"identity_id": identityID,
+	}, "team transferred")
+	return team, nil
+}
+
+// loadTeam loads the identity of the specified team, along with its resource
+func (s *teamServiceImpl) loadTeam(ctx context.Context, teamID uuid.UUID) (*account.Identity, error) {
+	teams, err := s.Repositories().Identities().Query(account.IdentityFilterByID(teamID), func(db *gorm.DB) *gorm.DB {
+		return db.Preload("IdentityResource").Preload("IdentityResource.ResourceType")
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(teams) == 0 || !teams[0].IdentityResourceID.Valid || teams[0].IdentityResource.ResourceType.Name != authorization.IdentityResourceTypeTeam {
+		return nil, errors.NewNotFoundError("team", teamID.String())
+	}
+	return &teams[0], nil
+}
+
+// requireTeamScope returns a ForbiddenError unless the user has the given scope for the team, or the given scope
+// for the space of the team
+func (s *teamServiceImpl) requireTeamScope(ctx context.Context, identityID uuid.UUID, team *account.Identity, teamScope string, spaceScope string) error {
+	permService := s.Services().PermissionService()
+	scope, err := permService.HasScope(ctx, identityID, team.IdentityResourceID.String, teamScope)
+	if err != nil {
+		return errors.NewInternalError(err)
+	}
+	if scope {
+		return nil
+	}
+	if team.IdentityResource.ParentResourceID != nil {
+		scope, err = permService.HasScope(ctx, identityID, *team.IdentityResource.ParentResourceID, spaceScope)
+		if err != nil {
+			return errors.NewInternalError(err)
+		}
+	}
+	if !scope {
+		return errors.NewForbiddenError(fmt.Sprintf("user requires %s scope for the team or %s scope for its space", teamScope, spaceScope))
+	}
+	return nil
+}
+
+// deleteTeamRoles deletes the roles assigned to the identity in the resource, flagging the privilege caches as stale
+func (s *teamServiceImpl) deleteTeamRoles(ctx context.Context, identityID uuid.UUID, resourceID string) error {
+	identityRoles, err := s.Repositories().IdentityRoleRepository().FindIdentityRolesByIdentityAndResource(ctx, resourceID, identityID)
+	if err != nil {
+		return err
+	}
+	for _, identityRole := range identityRoles {
+		err = s.Repositories().IdentityRoleRepository().Delete(ctx, identityRole.IdentityRoleID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteIdentityRoles deletes the roles assigned in the resource, or only flags the privilege caches of the identities
+// who have a role in the resource as stale if flagOnly is true
+func (s *teamServiceImpl) deleteIdentityRoles(ctx context.Context, resourceID string, flagOnly bool) error {
+	identityRoles, err := s.Repositories().IdentityRoleRepository().FindIdentityRolesByResource(ctx, resourceID, false)
+	if err != nil {
+		return err
+	}
+	for _, identityRole := range identityRoles {
+		if flagOnly {
+			err = s.Repositories().IdentityRoleRepository().FlagPrivilegeCacheStaleForIdentityRoleChange(ctx, identityRole.IdentityID, resourceID)
+		} else {
+			err = s.Repositories().IdentityRoleRepository().Delete(ctx, identityRole.IdentityRoleID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// teamMembers is a gorm filter for the direct members of the team
+func teamMembers(teamID uuid.UUID) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("id IN (SELECT member_id FROM membership WHERE member_of = ?)", teamID)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
 
+	errs "github.com/pkg/errors"
 	"github.com/satori/go.uuid"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -289,3 +290,150 @@ func (s *teamServiceBlackBoxTest) TestListTeamsForIdentity() {
 	require.NoError(s.T(), err)
 	require.Len(s.T(), teams, 0)
 }
+
+func (s *teamServiceBlackBoxTest) TestUpdateTeam() {
+	g := s.DBTestSuite.NewTestGraph(s.T())
+	spc := g.CreateSpace().AddAdmin(g.CreateUser(g.ID("admin"))).AddViewer(g.CreateUser(g.ID("viewer")))
+	team := g.CreateTeam(spc)
+
+	s.T().Run("ok", func(t *testing.T) {
+		teamName := "TestTeam" + uuid.NewV4().String()
+		updated, err := s.Application.TeamService().UpdateTeam(s.Ctx, g.UserByID("admin").IdentityID(), team.TeamID(), teamName)
+		require.NoError(t, err)
+		require.Equal(t, teamName, updated.IdentityResource.Name)
+		require.Equal(t, teamName, g.LoadTeam(team.TeamID()).TeamName())
+	})
+
+	s.T().Run("empty name", func(t *testing.T) {
+		_, err := s.Application.TeamService().UpdateTeam(s.Ctx, g.UserByID("admin").IdentityID(), team.TeamID(), " ")
+		require.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+	})
+
+	s.T().Run("forbidden for space viewer", func(t *testing.T) {
+		_, err := s.Application.TeamService().UpdateTeam(s.Ctx, g.UserByID("viewer").IdentityID(), team.TeamID(), "Renamed")
+		require.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+	})
+
+	s.T().Run("unknown team", func(t *testing.T) {
+		_, err := s.Application.TeamService().UpdateTeam(s.Ctx, g.UserByID("admin").IdentityID(), uuid.NewV4(), "Renamed")
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+
+	s.T().Run("not a team", func(t *testing.T) {
+		_, err := s.Application.TeamService().UpdateTeam(s.Ctx, g.UserByID("admin").IdentityID(), g.UserByID("viewer").IdentityID(), "Renamed")
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}
+
+func (s *teamServiceBlackBoxTest) TestDeleteTeam() {
+	g := s.DBTestSuite.NewTestGraph(s.T())
+	spc := g.CreateSpace().AddAdmin(g.CreateUser(g.ID("admin"))).AddViewer(g.CreateUser(g.ID("viewer")))
+	team := g.CreateTeam(spc).AddMember(g.CreateUser(g.ID("member")))
+	spc.AddContributor(team)
+	invitation := g.CreateInvitation(team, g.CreateUser())
+
+	s.T().Run("forbidden for space viewer", func(t *testing.T) {
+		err := s.Application.TeamService().DeleteTeam(s.Ctx, g.UserByID("viewer").IdentityID(), team.TeamID())
+		require.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+	})
+
+	s.T().Run("ok", func(t *testing.T) {
+		err := s.Application.TeamService().DeleteTeam(s.Ctx, g.UserByID("admin").IdentityID(), team.TeamID())
+		require.NoError(t, err)
+
+		_, err = s.Application.Identities().Load(s.Ctx, team.TeamID())
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+		_, err = s.Application.ResourceRepository().Load(s.Ctx, team.ResourceID())
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+		_, err = s.Application.InvitationRepository().Load(s.Ctx, invitation.Invitation().InvitationID)
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+
+		teams, err := s.Application.TeamService().ListTeamsForIdentity(s.Ctx, g.UserByID("member").IdentityID())
+		require.NoError(t, err)
+		require.Empty(t, teams)
+
+		roles, err := s.Application.IdentityRoleRepository().FindIdentityRolesByIdentityAndResource(s.Ctx, spc.SpaceID(), team.TeamID())
+		require.NoError(t, err)
+		require.Empty(t, roles)
+	})
+
+	s.T().Run("unknown team", func(t *testing.T) {
+		err := s.Application.TeamService().DeleteTeam(s.Ctx, g.UserByID("admin").IdentityID(), team.TeamID())
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}
+
+func (s *teamServiceBlackBoxTest) TestListAndRemoveTeamMembers() {
+	g := s.DBTestSuite.NewTestGraph(s.T())
+	spc := g.CreateSpace().AddAdmin(g.CreateUser(g.ID("admin"))).AddViewer(g.CreateUser(g.ID("viewer")))
+	team := g.CreateTeam(spc).AddMember(g.CreateUser(g.ID("m1"))).AddMember(g.CreateUser(g.ID("m2")))
+	outsider := g.CreateUser()
+
+	s.T().Run("list ok for space viewer", func(t *testing.T) {
+		members, err := s.Application.TeamService().ListTeamMembers(s.Ctx, g.UserByID("viewer").IdentityID(), team.TeamID())
+		require.NoError(t, err)
+		require.Len(t, members, 2)
+		for _, member := range members {
+			require.NotEqual(t, uuid.Nil, member.User.ID)
+		}
+	})
+
+	s.T().Run("list forbidden for outsider", func(t *testing.T) {
+		_, err := s.Application.TeamService().ListTeamMembers(s.Ctx, outsider.IdentityID(), team.TeamID())
+		require.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+	})
+
+	s.T().Run("remove forbidden for space viewer", func(t *testing.T) {
+		err := s.Application.TeamService().RemoveTeamMember(s.Ctx, g.UserByID("viewer").IdentityID(), team.TeamID(), g.UserByID("m1").IdentityID())
+		require.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+	})
+
+	s.T().Run("remove ok", func(t *testing.T) {
+		err := s.Application.TeamService().RemoveTeamMember(s.Ctx, g.UserByID("admin").IdentityID(), team.TeamID(), g.UserByID("m1").IdentityID())
+		require.NoError(t, err)
+		members, err := s.Application.TeamService().ListTeamMembers(s.Ctx, g.UserByID("admin").IdentityID(), team.TeamID())
+		require.NoError(t, err)
+		require.Len(t, members, 1)
+		require.Equal(t, g.UserByID("m2").IdentityID(), members[0].ID)
+	})
+
+	s.T().Run("remove non member", func(t *testing.T) {
+		err := s.Application.TeamService().RemoveTeamMember(s.Ctx, g.UserByID("admin").IdentityID(), team.TeamID(), outsider.IdentityID())
+		require.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}
+
+func (s *teamServiceBlackBoxTest) TestTransferTeam() {
+	g := s.DBTestSuite.NewTestGraph(s.T())
+	g.CreateUser(g.ID("admin"))
+	spc := g.CreateSpace().AddAdmin(g.UserByID("admin"))
+	target := g.CreateSpace().AddAdmin(g.UserByID("admin"))
+	foreign := g.CreateSpace()
+	team := g.CreateTeam(spc).AddMember(g.CreateUser())
+	spc.AddContributor(team)
+
+	s.T().Run("forbidden for target space", func(t *testing.T) {
+		_, err := s.Application.TeamService().TransferTeam(s.Ctx, g.UserByID("admin").IdentityID(), team.TeamID(), foreign.SpaceID())
+		require.IsType(t, errors.ForbiddenError{}, errs.Cause(err))
+	})
+
+	s.T().Run("not a space", func(t *testing.T) {
+		_, err := s.Application.TeamService().TransferTeam(s.Ctx, g.UserByID("admin").IdentityID(), team.TeamID(), team.ResourceID())
+		require.IsType(t, errors.BadParameterError{}, errs.Cause(err))
+	})
+
+	s.T().Run("ok", func(t *testing.T) {
+		transferred, err := s.Application.TeamService().TransferTeam(s.Ctx, g.UserByID("admin").IdentityID(), team.TeamID(), target.SpaceID())
+		require.NoError(t, err)
+		require.Equal(t, target.SpaceID(), *transferred.IdentityResource.ParentResourceID)
+
+		teams, err := s.Application.TeamService().ListTeamsInSpace(s.Ctx, g.UserByID("admin").IdentityID(), target.SpaceID())
+		require.NoError(t, err)
+		require.Len(t, teams, 1)
+		require.Equal(t, team.TeamID(), teams[0].ID)
+
+		roles, err := s.Application.IdentityRoleRepository().FindIdentityRolesByIdentityAndResource(s.Ctx, spc.SpaceID(), team.TeamID())
+		require.NoError(t, err)
+		require.Empty(t, roles)
+	})
+}
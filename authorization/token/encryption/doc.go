@@ -0,0 +1,2 @@
+// Package encryption provides the envelope encryption used to protect external provider tokens at rest.
+package encryption
@@ -0,0 +1,183 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+
+	errs "github.com/pkg/errors"
+)
+
+// dataKeyLength the length (in bytes) of the data keys generated for each encrypted value (AES-256)
+const dataKeyLength = 32
+
+// Envelope holds an encrypted value along with the data key used to encrypt it, the data key being itself
+// encrypted ("wrapped") with the master key identified by KeyID.
+type Envelope struct {
+	Ciphertext       string // base64-encoded nonce and AES-GCM sealed value
+	EncryptedDataKey string // base64-encoded nonce and AES-GCM sealed data key
+	KeyID            string // the ID of the master key used to wrap the data key
+}
+
+// Encryptor encrypts and decrypts values using envelope encryption
+type Encryptor interface {
+	// Encrypt encrypts the given plaintext with a new data key, which is wrapped with the primary master key
+	Encrypt(plaintext string) (*Envelope, error)
+	// Decrypt unwraps the data key of the given envelope with the master key it refers to, and decrypts the value
+	Decrypt(envelope Envelope) (string, error)
+	// PrimaryKeyID returns the ID of the master key used to wrap the data keys of new envelopes
+	PrimaryKeyID() string
+}
+
+// EncryptionConfiguration the configuration for the envelope encryption
+type EncryptionConfiguration interface {
+	GetExternalTokenEncryptionMasterKeys() map[string][]byte
+	GetExternalTokenEncryptionPrimaryKeyID() string
+}
+
+// NewEnvelopeEncryptorFromConfig returns a new Encryptor using the master keys defined in the given configuration
+func NewEnvelopeEncryptorFromConfig(config EncryptionConfiguration) (Encryptor, error) {
+	return NewEnvelopeEncryptor(config.GetExternalTokenEncryptionPrimaryKeyID(), config.GetExternalTokenEncryptionMasterKeys())
+}
+
+// NewEnvelopeEncryptor returns a new Encryptor which wraps the data keys with the master key identified by
+// `primaryKeyID`. The other master keys are only used to decrypt values which were encrypted before a key rotation.
+// Returns an error if the primary key is missing or if any of the master keys is not a valid AES key.
+func NewEnvelopeEncryptor(primaryKeyID string, masterKeys map[string][]byte) (Encryptor, error) {
+	if _, found := masterKeys[primaryKeyID]; !found {
+		return nil, errs.Errorf("primary master key '%s' is not defined", primaryKeyID)
+	}
+	aeads := make(map[string]cipher.AEAD, len(masterKeys))
+	for id, key := range masterKeys {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, errs.Wrapf(err, "invalid master key '%s'", id)
+		}
+		aeads[id] = aead
+	}
+	return &envelopeEncryptor{
+		primaryKeyID: primaryKeyID,
+		masterKeys:   aeads,
+	}, nil
+}
+
+// NewUnavailableEncryptor returns an Encryptor which fails to encrypt and decrypt with the given cause. It is used
+// when the master keys are misconfigured, so that the service can still start and report the configuration error.
+func NewUnavailableEncryptor(cause error) Encryptor {
+	return &unavailableEncryptor{cause: cause}
+}
+
+type unavailableEncryptor struct {
+	cause error
+}
+
+// PrimaryKeyID returns an empty key ID
+func (e *unavailableEncryptor) PrimaryKeyID() string {
+	return ""
+}
+
+// Encrypt always fails
+func (e *unavailableEncryptor) Encrypt(plaintext string) (*Envelope, error) {
+	return nil, errs.Wrap(e.cause, "external token encryption is not available")
+}
+
+// Decrypt always fails
+func (e *unavailableEncryptor) Decrypt(envelope Envelope) (string, error) {
+	return "", errs.Wrap(e.cause, "external token encryption is not available")
+}
+
+type envelopeEncryptor struct {
+	primaryKeyID string
+	masterKeys   map[string]cipher.AEAD
+}
+
+// PrimaryKeyID returns the ID of the master key used to wrap the data keys of new envelopes
+func (e *envelopeEncryptor) PrimaryKeyID() string {
+	return e.primaryKeyID
+}
+
+// Encrypt encrypts the given plaintext with a new data key, which is wrapped with the primary master key
+func (e *envelopeEncryptor) Encrypt(plaintext string) (*Envelope, error) {
+	dataKey := make([]byte, dataKeyLength)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, errs.Wrap(err, "unable to generate data key")
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := seal(dataAEAD, []byte(plaintext))
+	if err != nil {
+		return nil, errs.Wrap(err, "unable to encrypt value")
+	}
+	encryptedDataKey, err := seal(e.masterKeys[e.primaryKeyID], dataKey)
+	if err != nil {
+		return nil, errs.Wrap(err, "unable to wrap data key")
+	}
+	return &Envelope{
+		Ciphertext:       ciphertext,
+		EncryptedDataKey: encryptedDataKey,
+		KeyID:            e.primaryKeyID,
+	}, nil
+}
+
+// Decrypt unwraps the data key of the given envelope with the master key it refers to, and decrypts the value
+func (e *envelopeEncryptor) Decrypt(envelope Envelope) (string, error) {
+	masterAEAD, found := e.masterKeys[envelope.KeyID]
+	if !found {
+		return "", errs.Errorf("unknown master key '%s'", envelope.KeyID)
+	}
+	dataKey, err := open(masterAEAD, envelope.EncryptedDataKey)
+	if err != nil {
+		return "", errs.Wrapf(err, "unable to unwrap data key with master key '%s'", envelope.KeyID)
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dataAEAD, envelope.Ciphertext)
+	if err != nil {
+		return "", errs.Wrap(err, "unable to decrypt value")
+	}
+	return string(plaintext), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	return aead, nil
+}
+
+// seal encrypts the given value and returns the base64-encoded concatenation of the random nonce and the sealed value
+func seal(aead cipher.AEAD, value []byte) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", errs.WithStack(err)
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, value, nil)), nil
+}
+
+// open decodes and decrypts a value produced by `seal`
+func open(aead cipher.AEAD, value string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, errs.New("encrypted value is too short")
+	}
+	nonce, sealed := data[:aead.NonceSize()], data[aead.NonceSize():]
+	result, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	return result, nil
+}
@@ -0,0 +1,111 @@
+package encryption_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/authorization/token/encryption"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	key1 = []byte("0123456789abcdef0123456789abcdef")
+	key2 = []byte("fedcba9876543210fedcba9876543210")
+)
+
+func TestEnvelopeEncryptor(t *testing.T) {
+
+	t.Run("encrypt and decrypt", func(t *testing.T) {
+		// given
+		e, err := encryption.NewEnvelopeEncryptor("key1", map[string][]byte{"key1": key1})
+		require.NoError(t, err)
+		// when
+		envelope, err := e.Encrypt("a secret token")
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "key1", envelope.KeyID)
+		assert.NotContains(t, envelope.Ciphertext, "a secret token")
+		assert.NotEmpty(t, envelope.EncryptedDataKey)
+		plaintext, err := e.Decrypt(*envelope)
+		require.NoError(t, err)
+		assert.Equal(t, "a secret token", plaintext)
+	})
+
+	t.Run("each envelope has its own data key", func(t *testing.T) {
+		// given
+		e, err := encryption.NewEnvelopeEncryptor("key1", map[string][]byte{"key1": key1})
+		require.NoError(t, err)
+		// when
+		envelope1, err := e.Encrypt("a secret token")
+		require.NoError(t, err)
+		envelope2, err := e.Encrypt("a secret token")
+		require.NoError(t, err)
+		// then
+		assert.NotEqual(t, envelope1.EncryptedDataKey, envelope2.EncryptedDataKey)
+		assert.NotEqual(t, envelope1.Ciphertext, envelope2.Ciphertext)
+	})
+
+	t.Run("decrypt after key rotation", func(t *testing.T) {
+		// given
+		e1, err := encryption.NewEnvelopeEncryptor("key1", map[string][]byte{"key1": key1})
+		require.NoError(t, err)
+		envelope, err := e1.Encrypt("a secret token")
+		require.NoError(t, err)
+		e2, err := encryption.NewEnvelopeEncryptor("key2", map[string][]byte{"key1": key1, "key2": key2})
+		require.NoError(t, err)
+		// when
+		plaintext, err := e2.Decrypt(*envelope)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "a secret token", plaintext)
+		assert.Equal(t, "key2", e2.PrimaryKeyID())
+	})
+
+	t.Run("fail to decrypt with unknown key", func(t *testing.T) {
+		// given
+		e1, err := encryption.NewEnvelopeEncryptor("key1", map[string][]byte{"key1": key1})
+		require.NoError(t, err)
+		envelope, err := e1.Encrypt("a secret token")
+		require.NoError(t, err)
+		e2, err := encryption.NewEnvelopeEncryptor("key2", map[string][]byte{"key2": key2})
+		require.NoError(t, err)
+		// when
+		_, err = e2.Decrypt(*envelope)
+		// then
+		require.Error(t, err)
+	})
+
+	t.Run("fail to decrypt with wrong key", func(t *testing.T) {
+		// given
+		e1, err := encryption.NewEnvelopeEncryptor("key1", map[string][]byte{"key1": key1})
+		require.NoError(t, err)
+		envelope, err := e1.Encrypt("a secret token")
+		require.NoError(t, err)
+		e2, err := encryption.NewEnvelopeEncryptor("key1", map[string][]byte{"key1": key2})
+		require.NoError(t, err)
+		// when
+		_, err = e2.Decrypt(*envelope)
+		// then
+		require.Error(t, err)
+	})
+
+	t.Run("fail with missing primary key", func(t *testing.T) {
+		_, err := encryption.NewEnvelopeEncryptor("key2", map[string][]byte{"key1": key1})
+		require.Error(t, err)
+	})
+
+	t.Run("fail with invalid key length", func(t *testing.T) {
+		_, err := encryption.NewEnvelopeEncryptor("key1", map[string][]byte{"key1": []byte("too short")})
+		require.Error(t, err)
+	})
+
+	t.Run("unavailable encryptor", func(t *testing.T) {
+		encryptor := encryption.NewUnavailableEncryptor(errors.New("no master key"))
+		_, err := encryptor.Encrypt("foo")
+		require.Error(t, err)
+		_, err = encryptor.Decrypt(encryption.Envelope{KeyID: "key1"})
+		require.Error(t, err)
+	})
+}
@@ -40,6 +40,14 @@ const (
 	contextTokenManagerKey = iota
 )
 
+const (
+	// BackChannelLogoutEvent the member of the `events` claim which identifies a Logout Token
+	BackChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+	// logoutTokenExpiresIn the lifespan of the logout tokens (in seconds). The tokens are generated when they are sent,
+	// so they do not need to remain valid for long
+	logoutTokenExpiresIn = 2 * 60
+)
+
 // DefaultManager creates the default manager if it has not created yet.
 // This function must be called in main to make sure the default manager is created during service startup.
 // It will try to create the default manager only once even if called multiple times.
@@ -76,6 +84,8 @@ type TokenClaims struct {
 	SessionState  string         `json:"session_state"`
 	Approved      bool           `json:"approved"`
 	Permissions   *[]Permissions `json:"permissions"`
+	ACR           string         `json:"acr"`
+	AuthMethods   []string       `json:"amr,omitempty"`
 	jwt.StandardClaims
 }
 
@@ -194,7 +204,7 @@ type TokenManager interface {
 	GenerateServiceAccountToken(saID string, saName string) (string, error)
 	GenerateUnsignedServiceAccountToken(saID string, saName string) *jwt.Token
 	GenerateUserTokenForAPIClient(ctx context.Context, providerToken oauth2.Token) (*oauth2.Token, error)
-	GenerateUserTokenForIdentity(ctx context.Context, identity repository.Identity, offlineToken bool) (*oauth2.Token, error)
+	GenerateUserTokenForIdentity(ctx context.Context, identity repository.Identity, offlineToken bool, authMethods ...string) (*oauth2.Token, error)
 	GenerateTransientUserAccessTokenForIdentity(ctx context.Context, identity repository.Identity) (*string, error)
 	GenerateUserTokenUsingRefreshToken(ctx context.Context, refreshTokenString string, identity *repository.Identity, permissions []Permissions) (*oauth2.Token, error)
 	GenerateUnsignedRPTTokenForIdentity(ctx context.Context, tokenClaims *TokenClaims, identity repository.Identity, permissions *[]Permissions) (*jwt.Token, error)
@@ -204,6 +214,7 @@ type TokenManager interface {
 	AddLoginRequiredHeaderToUnauthorizedError(err error, rw http.ResponseWriter)
 	AddLoginRequiredHeader(rw http.ResponseWriter)
 	AuthServiceAccountSigner() client.Signer
+	GenerateLogoutToken(ctx context.Context, identityID uuid.UUID, sessionID *uuid.UUID, audience string) (string, error)
 }
 
 type tokenManager struct {
@@ -318,14 +329,51 @@ func (m *tokenManager) AuthServiceAccountToken() string {
 	return m.serviceAccountToken
 }
 
+// #####################################################################################################################
+//
+// Logout token functions (Logout tokens are sent to the relying parties to notify them that a user logged out)
+//
+// #####################################################################################################################
+
+// GenerateLogoutToken generates and signs a new OpenID Connect Back-Channel Logout Token for the given identity and
+// the given audience (ie, the client ID of the relying party to notify). If a session ID is given, then the token
+// has a `sid` claim matching the `session_state` claim of the tokens of the terminated session. Otherwise, all the
+// sessions of the identity were terminated.
+// See https://openid.net/specs/openid-connect-backchannel-1_0.html#LogoutToken
+func (m *tokenManager) GenerateLogoutToken(ctx context.Context, identityID uuid.UUID, sessionID *uuid.UUID, audience string) (string, error) {
+	token := jwt.New(jwt.SigningMethodRS256)
+	token.Header["kid"] = m.userAccountPrivateKey.KeyID
+	claims := token.Claims.(jwt.MapClaims)
+	iat := time.Now().Unix()
+	claims["jti"] = uuid.NewV4().String()
+	claims["iat"] = iat
+	claims["exp"] = iat + logoutTokenExpiresIn
+	claims["iss"] = m.config.GetAuthServiceURL()
+	claims["aud"] = audience
+	claims["sub"] = identityID.String()
+	if sessionID != nil {
+		claims["sid"] = sessionID.String()
+	}
+	claims["events"] = map[string]interface{}{
+		BackChannelLogoutEvent: map[string]interface{}{},
+	}
+	tokenStr, err := token.SignedString(m.userAccountPrivateKey.Key)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return tokenStr, nil
+}
+
 // #####################################################################################################################
 //
 // User Token functions (User tokens are an oauth2 token consisting of an access token, refresh token and signature
 //
 // #####################################################################################################################
 
-// GenerateUserTokenForIdentity generates an OAuth2 user token for the given identity
-func (m *tokenManager) GenerateUserTokenForIdentity(ctx context.Context, identity repository.Identity, offlineToken bool) (*oauth2.Token, error) {
+// GenerateUserTokenForIdentity generates an OAuth2 user token for the given identity. The optional authMethods are the
+// second authentication factors verified by the user (e.g. `otp` or `hwk`). When any is given, the `acr` and `amr`
+// claims of the tokens reflect the multi-factor authentication.
+func (m *tokenManager) GenerateUserTokenForIdentity(ctx context.Context, identity repository.Identity, offlineToken bool, authMethods ...string) (*oauth2.Token, error) {
 	nowTime := time.Now().Unix()
 	unsignedAccessToken, err := m.GenerateUnsignedUserAccessTokenForIdentity(ctx, identity)
 	if err != nil {
@@ -339,6 +387,16 @@ func (m *tokenManager) GenerateUserTokenForIdentity(ctx context.Context, identit
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	// access and refresh tokens belong to the same session
+	unsignedRefreshToken.Claims.(jwt.MapClaims)["session_state"] = unsignedAccessToken.Claims.(jwt.MapClaims)["session_state"]
+	if len(authMethods) > 0 {
+		multiFactorClaims := &TokenClaims{
+			ACR:         token.ACRMultiFactor,
+			AuthMethods: append(append([]string{}, authMethods...), token.AuthMethodMFA),
+		}
+		setAuthenticationContextClaims(unsignedAccessToken.Claims.(jwt.MapClaims), multiFactorClaims)
+		setAuthenticationContextClaims(unsignedRefreshToken.Claims.(jwt.MapClaims), multiFactorClaims)
+	}
 	refreshToken, err := unsignedRefreshToken.SignedString(m.userAccountPrivateKey.Key)
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -446,7 +504,7 @@ func (m *tokenManager) GenerateUnsignedUserAccessTokenFromClaims(ctx context.Con
 
 	claims["azp"] = tokenClaims.Audience
 	claims["session_state"] = tokenClaims.SessionState
-	claims["acr"] = "0"
+	setAuthenticationContextClaims(claims, tokenClaims)
 
 	realmAccess := make(map[string]interface{})
 	realmAccess["roles"] = []string{"uma_authorization"}
@@ -467,6 +525,18 @@ func (m *tokenManager) GenerateUnsignedUserAccessTokenFromClaims(ctx context.Con
 	return token, nil
 }
 
+// setAuthenticationContextClaims sets the `acr` and `amr` claims from the given claims, so that the tokens generated
+// from another token keep track of how the user was authenticated
+func setAuthenticationContextClaims(claims jwt.MapClaims, tokenClaims *TokenClaims) {
+	claims["acr"] = token.ACRSingleFactor
+	if tokenClaims.ACR != "" {
+		claims["acr"] = tokenClaims.ACR
+	}
+	if len(tokenClaims.AuthMethods) > 0 {
+		claims["amr"] = tokenClaims.AuthMethods
+	}
+}
+
 // GenerateUnsignedUserAccessTokenForIdentity generates an unsigned OAuth2 user access token for the given identity
 func (m *tokenManager) GenerateUnsignedUserAccessTokenForIdentity(ctx context.Context, identity repository.Identity) (*jwt.Token, error) {
 	token := jwt.New(jwt.SigningMethodRS256)
@@ -614,6 +684,7 @@ func (m *tokenManager) GenerateUnsignedUserRefreshToken(ctx context.Context, ref
 
 	claims["azp"] = oldClaims.Audience
 	claims["session_state"] = oldClaims.SessionState
+	setAuthenticationContextClaims(claims, oldClaims)
 
 	return token, nil
 }
@@ -674,7 +745,7 @@ func (m *tokenManager) GenerateUnsignedUserAccessTokenFromRefreshToken(ctx conte
 
 	claims["azp"] = refreshTokenClaims.Audience
 	claims["session_state"] = refreshTokenClaims.SessionState
-	claims["acr"] = "0"
+	setAuthenticationContextClaims(claims, refreshTokenClaims)
 
 	realmAccess := make(map[string]interface{})
 	realmAccess["roles"] = []string{"uma_authorization"}
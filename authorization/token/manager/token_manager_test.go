@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	authtoken "github.com/fabric8-services/fabric8-auth/authorization/token"
 	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
 
 	"github.com/fabric8-services/fabric8-auth/authentication/account"
@@ -91,6 +92,110 @@ func (s *TestTokenSuite) TestGenerateTransientUserAccessTokenForIdentity() {
 	s.assertClaim(claims, "transient", "true")
 }
 
+func (s *TestTokenSuite) TestGenerateUserTokenForIdentityWithAuthMethods() {
+	// given
+	_, identity, ctx := s.generateToken(false)
+	// when
+	token, err := testtoken.TokenManager.GenerateUserTokenForIdentity(ctx, identity, false, authtoken.AuthMethodOTP)
+	// then
+	require.NoError(s.T(), err)
+	s.assertGeneratedToken(token, identity, false)
+	accessClaims, err := testtoken.TokenManager.ParseTokenWithMapClaims(ctx, token.AccessToken)
+	require.NoError(s.T(), err)
+	s.assertClaim(accessClaims, "acr", authtoken.ACRMultiFactor)
+	s.assertClaim(accessClaims, "amr", []interface{}{authtoken.AuthMethodOTP, authtoken.AuthMethodMFA})
+
+	s.T().Run("refreshed token is still multi-factor", func(t *testing.T) {
+		// when
+		refreshed, err := testtoken.TokenManager.GenerateUserTokenUsingRefreshToken(ctx, token.RefreshToken, &identity, nil)
+		// then
+		require.NoError(t, err)
+		claims, err := testtoken.TokenManager.ParseToken(ctx, refreshed.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, authtoken.ACRMultiFactor, claims.ACR)
+		assert.Equal(t, []string{authtoken.AuthMethodOTP, authtoken.AuthMethodMFA}, claims.AuthMethods)
+		claims, err = testtoken.TokenManager.ParseToken(ctx, refreshed.RefreshToken)
+		require.NoError(t, err)
+		assert.Equal(t, authtoken.ACRMultiFactor, claims.ACR)
+	})
+
+	s.T().Run("rpt token is still multi-factor", func(t *testing.T) {
+		// given
+		claims, err := testtoken.TokenManager.ParseToken(ctx, token.AccessToken)
+		require.NoError(t, err)
+		// when
+		rptToken, err := testtoken.TokenManager.GenerateUnsignedRPTTokenForIdentity(ctx, claims, identity, nil)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, authtoken.ACRMultiFactor, rptToken.Claims.(jwt.MapClaims)["acr"])
+	})
+
+	s.T().Run("single factor token", func(t *testing.T) {
+		// when
+		token, err := testtoken.TokenManager.GenerateUserTokenForIdentity(ctx, identity, false)
+		// then
+		require.NoError(t, err)
+		claims, err := testtoken.TokenManager.ParseToken(ctx, token.AccessToken)
+		require.NoError(t, err)
+		assert.NotEqual(t, authtoken.ACRMultiFactor, claims.ACR)
+		assert.Empty(t, claims.AuthMethods)
+	})
+
+	s.T().Run("hardware key", func(t *testing.T) {
+		// when
+		token, err := testtoken.TokenManager.GenerateUserTokenForIdentity(ctx, identity, false, authtoken.AuthMethodHardwareKey)
+		// then
+		require.NoError(t, err)
+		claims, err := testtoken.TokenManager.ParseToken(ctx, token.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, authtoken.ACRMultiFactor, claims.ACR)
+		assert.Equal(t, []string{authtoken.AuthMethodHardwareKey, authtoken.AuthMethodMFA}, claims.AuthMethods)
+	})
+}
+
+func (s *TestTokenSuite) TestGenerateLogoutToken() {
+
+	s.T().Run("all sessions", func(t *testing.T) {
+		// given
+		identityID := uuid.NewV4()
+		// when
+		logoutToken, err := testtoken.TokenManager.GenerateLogoutToken(context.Background(), identityID, nil, "fabric8-wit")
+		// then
+		require.NoError(t, err)
+		// Headers
+		s.assertHeaders(logoutToken)
+		// Claims
+		claims, err := testtoken.TokenManager.ParseTokenWithMapClaims(context.Background(), logoutToken)
+		require.NoError(t, err)
+		s.assertJti(claims)
+		s.assertIat(claims)
+		s.assertExpiresIn(claims["exp"], 2*time.Minute, 10*time.Second)
+		s.assertClaim(claims, "iss", "http://auth.localhost")
+		s.assertClaim(claims, "aud", "fabric8-wit")
+		s.assertClaim(claims, "sub", identityID.String())
+		s.assertClaim(claims, "events", map[string]interface{}{
+			manager.BackChannelLogoutEvent: map[string]interface{}{},
+		})
+		assert.Nil(t, claims["sid"])
+		// a logout token must not contain a nonce
+		assert.Nil(t, claims["nonce"])
+	})
+
+	s.T().Run("single session", func(t *testing.T) {
+		// given
+		identityID := uuid.NewV4()
+		sessionID := uuid.NewV4()
+		// when
+		logoutToken, err := testtoken.TokenManager.GenerateLogoutToken(context.Background(), identityID, &sessionID, "fabric8-wit")
+		// then
+		require.NoError(t, err)
+		claims, err := testtoken.TokenManager.ParseTokenWithMapClaims(context.Background(), logoutToken)
+		require.NoError(t, err)
+		s.assertClaim(claims, "sub", identityID.String())
+		s.assertClaim(claims, "sid", sessionID.String())
+	})
+}
+
 func (s *TestTokenSuite) TestRefreshedUserTokenForIdentity() {
 	s.checkRefreshedUserTokenForIdentity(false)
 	s.checkRefreshedUserTokenForIdentity(true)
@@ -313,6 +418,8 @@ func (s *TestTokenSuite) assertGeneratedToken(generatedToken *oauth2.Token, iden
 	}
 	s.assertIntClaim(refreshToken, "auth_time", 0)
 	s.assertClaim(refreshToken, "sub", identity.ID.String())
+	// access and refresh tokens belong to the same session
+	assert.Equal(s.T(), accessToken["session_state"], refreshToken["session_state"])
 }
 
 func (s *TestTokenSuite) assertRefreshTokenForAPIClient(generatedToken *oauth2.Token, identity repository.Identity) {
@@ -7,6 +7,7 @@ import (
 
 	repository "github.com/fabric8-services/fabric8-auth/application/repository/base"
 	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization/token/encryption"
 	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/gormsupport"
 	"github.com/fabric8-services/fabric8-auth/log"
@@ -27,6 +28,10 @@ type ExternalToken struct {
 	Username   string
 	IdentityID uuid.UUID `sql:"type:uuid"` // use NullUUID ?
 	Identity   account.Identity
+	// EncryptedDataKey the data key used to encrypt the token, itself encrypted with the master key
+	EncryptedDataKey string
+	// MasterKeyID the ID of the master key used to encrypt the data key. Empty if the token is stored in plain text
+	MasterKeyID string
 }
 
 // TableName overrides the table name settings in Gorm to force a specific table name
@@ -48,13 +53,15 @@ func (m ExternalToken) GetLastModified() time.Time {
 
 // GormExternalTokenRepository is the implementation of the storage interface for
 // ExternalToken.
+// Tokens are transparently encrypted before they are stored and decrypted after they are loaded.
 type GormExternalTokenRepository struct {
-	db *gorm.DB
+	db        *gorm.DB
+	encryptor encryption.Encryptor
 }
 
-// NewExternalTokenRepository creates a new storage type.
-func NewExternalTokenRepository(db *gorm.DB) *GormExternalTokenRepository {
-	return &GormExternalTokenRepository{db: db}
+// NewExternalTokenRepository creates a new storage type. If the given encryptor is nil, then the tokens are stored in plain text.
+func NewExternalTokenRepository(db *gorm.DB, encryptor encryption.Encryptor) *GormExternalTokenRepository {
+	return &GormExternalTokenRepository{db: db, encryptor: encryptor}
 }
 
 // ExternalTokenRepository represents the storage interface.
@@ -65,8 +72,10 @@ type ExternalTokenRepository interface {
 	Save(ctx context.Context, ExternalToken *ExternalToken) error
 	Delete(ctx context.Context, id uuid.UUID) error
 	DeleteByIdentityID(ctx context.Context, identityID uuid.UUID) error
+	TransferToIdentity(ctx context.Context, fromIdentityID uuid.UUID, toIdentityID uuid.UUID) error
 	LoadByProviderIDAndIdentityID(ctx context.Context, providerID uuid.UUID, identityID uuid.UUID) ([]ExternalToken, error)
 	Query(funcs ...func(*gorm.DB) *gorm.DB) ([]ExternalToken, error)
+	ReEncrypt(ctx context.Context, limit int, excluded []uuid.UUID) (int, []uuid.UUID, error)
 }
 
 // TableName overrides the table name settings in Gorm to force a specific table name
@@ -87,8 +96,13 @@ func (m *GormExternalTokenRepository) Load(ctx context.Context, id uuid.UUID) (*
 	if err == gorm.ErrRecordNotFound {
 		return nil, errors.NewNotFoundError("external_token", id.String())
 	}
-
-	return &native, errs.WithStack(err)
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	if err := m.decrypt(&native); err != nil {
+		return nil, err
+	}
+	return &native, nil
 }
 
 // CheckExists returns nil if the given ID exists otherwise returns an error
@@ -103,7 +117,14 @@ func (m *GormExternalTokenRepository) Create(ctx context.Context, model *Externa
 	if model.ID == uuid.Nil {
 		model.ID = uuid.NewV4()
 	}
-	err := m.db.Create(model).Error
+	token := model.Token
+	err := m.encrypt(model)
+	if err != nil {
+		return err
+	}
+	err = m.db.Create(model).Error
+	// callers keep working with the plain text token
+	model.Token = token
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
 			"external_token_id": model.ID,
@@ -130,7 +151,14 @@ func (m *GormExternalTokenRepository) Save(ctx context.Context, model *ExternalT
 		}, "unable to update the external_token")
 		return errs.WithStack(err)
 	}
+	token := model.Token
+	err = m.encrypt(model)
+	if err != nil {
+		return err
+	}
 	err = m.db.Model(obj).Updates(model).Error
+	// callers keep working with the plain text token
+	model.Token = token
 
 	log.Debug(ctx, map[string]interface{}{
 		"external_token_id": model.ID,
@@ -181,6 +209,38 @@ func (m *GormExternalTokenRepository) DeleteByIdentityID(ctx context.Context, id
 	return nil
 }
 
+// TransferToIdentity assigns the external tokens of an identity to another identity. The tokens for the providers
+// which the other identity is already linked to are deleted, since an identity is linked to each provider only once.
+// This is a hard delete!
+func (m *GormExternalTokenRepository) TransferToIdentity(ctx context.Context, fromIdentityID uuid.UUID, toIdentityID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "ExternalToken", "transferToIdentity"}, time.Now())
+	err := m.db.Where(`identity_id = ? AND provider_id IN (SELECT provider_id FROM external_tokens WHERE identity_id = ?)`,
+		fromIdentityID, toIdentityID).Delete(ExternalToken{}).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"from_identity_id": fromIdentityID,
+			"to_identity_id":   toIdentityID,
+			"err":              err,
+		}, "unable to delete the duplicate external tokens")
+		return errs.WithStack(err)
+	}
+	result := m.db.Model(&ExternalToken{}).Where("identity_id = ?", fromIdentityID).Update("identity_id", toIdentityID)
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"from_identity_id": fromIdentityID,
+			"to_identity_id":   toIdentityID,
+			"err":              result.Error,
+		}, "unable to transfer the external tokens")
+		return errs.WithStack(result.Error)
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"from_identity_id": fromIdentityID,
+		"to_identity_id":   toIdentityID,
+		"transferred":      result.RowsAffected,
+	}, "external tokens transferred!")
+	return nil
+}
+
 // Query expose an open ended Query model
 func (m *GormExternalTokenRepository) Query(funcs ...func(*gorm.DB) *gorm.DB) ([]ExternalToken, error) {
 	defer goa.MeasureSince([]string{"goa", "db", "ExternalToken", "query"}, time.Now())
@@ -190,8 +250,13 @@ func (m *GormExternalTokenRepository) Query(funcs ...func(*gorm.DB) *gorm.DB) ([
 	if err != nil && err != gorm.ErrRecordNotFound {
 		return nil, errs.WithStack(err)
 	}
+	for i := range externalProviderTokens {
+		if err := m.decrypt(&externalProviderTokens[i]); err != nil {
+			return nil, err
+		}
+	}
 	log.Debug(nil, map[string]interface{}{
-		"external_provider_token_query": externalProviderTokens,
+		"external_provider_tokens": len(externalProviderTokens),
 	}, "external_token query executed successfully!")
 
 	return externalProviderTokens, nil
@@ -208,6 +273,108 @@ func (m *GormExternalTokenRepository) LoadByProviderIDAndIdentityID(ctx context.
 	return externalProviderTokens, nil
 }
 
+// ReEncrypt encrypts with the primary master key (at most) `limit` external tokens which are stored in plain text
+// or which were encrypted with another master key (i.e., before a master key rotation).
+// The selected rows are locked until the end of the current transaction, and rows which are already locked by
+// another transaction are skipped, so that several pods can run this method concurrently.
+// The tokens with the given IDs are not selected, and the tokens which cannot be decrypted (e.g., their master key was
+// removed from the configuration) are left unchanged.
+// Returns the number of tokens that were re-encrypted, and the IDs of the tokens which could not be decrypted.
+func (m *GormExternalTokenRepository) ReEncrypt(ctx context.Context, limit int, excluded []uuid.UUID) (int, []uuid.UUID, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "ExternalToken", "reEncrypt"}, time.Now())
+	if m.encryptor == nil {
+		return 0, nil, nil
+	}
+	var tokens []ExternalToken
+	db := m.db.Table(m.TableName()).
+		Where("master_key_id IS NULL OR master_key_id <> ?", m.encryptor.PrimaryKeyID())
+	if len(excluded) > 0 {
+		db = db.Where("id NOT IN (?)", excluded)
+	}
+	err := db.Limit(limit).
+		Set("gorm:query_option", "FOR UPDATE SKIP LOCKED").
+		Find(&tokens).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return 0, nil, errs.WithStack(err)
+	}
+	count := 0
+	var failed []uuid.UUID
+	for i := range tokens {
+		t := &tokens[i]
+		previousKeyID := t.MasterKeyID
+		if err := m.decrypt(t); err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"external_token_id": t.ID,
+				"key_id":            previousKeyID,
+				"err":               err,
+			}, "unable to decrypt the external_token, skipping it")
+			failed = append(failed, t.ID)
+			continue
+		}
+		if err := m.encrypt(t); err != nil {
+			return count, failed, err
+		}
+		// do not update the `updated_at` column, since the token itself did not change
+		err := m.db.Model(t).UpdateColumns(map[string]interface{}{
+			"token":              t.Token,
+			"encrypted_data_key": t.EncryptedDataKey,
+			"master_key_id":      t.MasterKeyID,
+		}).Error
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"external_token_id": t.ID,
+				"err":               err,
+			}, "unable to re-encrypt the external_token")
+			return count, failed, errs.WithStack(err)
+		}
+		log.Debug(ctx, map[string]interface{}{
+			"external_token_id": t.ID,
+			"previous_key_id":   previousKeyID,
+			"key_id":            t.MasterKeyID,
+		}, "external_token re-encrypted")
+		count++
+	}
+	return count, failed, nil
+}
+
+// encrypt replaces the plain text token of the given model with its encrypted value,
+// and sets the wrapped data key and master key ID accordingly.
+// Does nothing if the token is empty (e.g., partial update) or if no encryptor was configured.
+func (m *GormExternalTokenRepository) encrypt(model *ExternalToken) error {
+	if m.encryptor == nil || model.Token == "" {
+		return nil
+	}
+	envelope, err := m.encryptor.Encrypt(model.Token)
+	if err != nil {
+		return errs.Wrapf(err, "unable to encrypt external token '%s'", model.ID)
+	}
+	model.Token = envelope.Ciphertext
+	model.EncryptedDataKey = envelope.EncryptedDataKey
+	model.MasterKeyID = envelope.KeyID
+	return nil
+}
+
+// decrypt replaces the encrypted token of the given model with its plain text value.
+// Does nothing if the token is stored in plain text (i.e., it was created before encryption was enabled)
+func (m *GormExternalTokenRepository) decrypt(model *ExternalToken) error {
+	if model.MasterKeyID == "" {
+		return nil
+	}
+	if m.encryptor == nil {
+		return errs.Errorf("unable to decrypt external token '%s': no encryptor configured", model.ID)
+	}
+	token, err := m.encryptor.Decrypt(encryption.Envelope{
+		Ciphertext:       model.Token,
+		EncryptedDataKey: model.EncryptedDataKey,
+		KeyID:            model.MasterKeyID,
+	})
+	if err != nil {
+		return errs.Wrapf(err, "unable to decrypt external token '%s'", model.ID)
+	}
+	model.Token = token
+	return nil
+}
+
 // ExternalTokenFilterByIdentityID is a gorm filter for a Belongs To relationship.
 func ExternalTokenFilterByIdentityID(identityID uuid.UUID) func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
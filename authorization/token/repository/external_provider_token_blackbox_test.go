@@ -3,6 +3,7 @@ package repository_test
 import (
 	"testing"
 
+	"github.com/fabric8-services/fabric8-auth/authorization/token/encryption"
 	"github.com/fabric8-services/fabric8-auth/authorization/token/repository"
 	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
@@ -18,7 +19,8 @@ import (
 
 type externalTokenBlackboxTest struct {
 	gormtestsupport.DBTestSuite
-	repo *repository.GormExternalTokenRepository
+	repo       *repository.GormExternalTokenRepository
+	masterKeys map[string][]byte
 }
 
 func TestRunExternalTokenBlackboxTest(t *testing.T) {
@@ -27,7 +29,146 @@ func TestRunExternalTokenBlackboxTest(t *testing.T) {
 
 func (s *externalTokenBlackboxTest) SetupTest() {
 	s.DBTestSuite.SetupTest()
-	s.repo = repository.NewExternalTokenRepository(s.DB)
+	// also include the configured keys, in case other tokens exist in the DB
+	s.masterKeys = s.Configuration.GetExternalTokenEncryptionMasterKeys()
+	s.masterKeys["test-key-1"] = []byte("0123456789abcdef0123456789abcdef")
+	s.masterKeys["test-key-2"] = []byte("fedcba9876543210fedcba9876543210")
+	s.repo = repository.NewExternalTokenRepository(s.DB, s.newEncryptor("test-key-1"))
+}
+
+func (s *externalTokenBlackboxTest) newEncryptor(primaryKeyID string) encryption.Encryptor {
+	encryptor, err := encryption.NewEnvelopeEncryptor(primaryKeyID, s.masterKeys)
+	require.NoError(s.T(), err)
+	return encryptor
+}
+
+func (s *externalTokenBlackboxTest) loadRawToken(id uuid.UUID) repository.ExternalToken {
+	var native repository.ExternalToken
+	err := s.DB.Table(s.repo.TableName()).Where("id = ?", id).Find(&native).Error
+	require.NoError(s.T(), err)
+	return native
+}
+
+func (s *externalTokenBlackboxTest) TestTokenIsEncryptedAtRest() {
+	// when
+	externalToken := createAndLoadExternalToken(s)
+	// then
+	native := s.loadRawToken(externalToken.ID)
+	assert.NotEqual(s.T(), externalToken.Token, native.Token)
+	assert.NotContains(s.T(), native.Token, externalToken.Token)
+	assert.NotEmpty(s.T(), native.EncryptedDataKey)
+	assert.Equal(s.T(), "test-key-1", native.MasterKeyID)
+}
+
+func (s *externalTokenBlackboxTest) TestLoadPlainTextToken() {
+	// given a token stored before encryption was enabled
+	identity, err := test.CreateTestIdentity(s.DB, uuid.NewV4().String(), "kc")
+	require.NoError(s.T(), err)
+	externalToken := repository.ExternalToken{
+		ID:         uuid.NewV4(),
+		ProviderID: uuid.NewV4(),
+		Token:      uuid.NewV4().String(),
+		Scope:      "user:full",
+		IdentityID: identity.ID,
+		Username:   uuid.NewV4().String(),
+	}
+	err = repository.NewExternalTokenRepository(s.DB, nil).Create(s.Ctx, &externalToken)
+	require.NoError(s.T(), err)
+	// when
+	loaded, err := s.repo.Load(s.Ctx, externalToken.ID)
+	// then
+	require.NoError(s.T(), err)
+	s.assertToken(externalToken, *loaded)
+	assert.Equal(s.T(), externalToken.Token, s.loadRawToken(externalToken.ID).Token)
+}
+
+func (s *externalTokenBlackboxTest) TestReEncrypt() {
+
+	s.T().Run("re-encrypt with new master key", func(t *testing.T) {
+		// given
+		externalToken := createAndLoadExternalToken(s)
+		rotatedRepo := repository.NewExternalTokenRepository(s.DB, s.newEncryptor("test-key-2"))
+		// when
+		count, failed, err := rotatedRepo.ReEncrypt(s.Ctx, 1000, nil)
+		// then
+		require.NoError(t, err)
+		assert.True(t, count >= 1)
+		assert.Empty(t, failed)
+		native := s.loadRawToken(externalToken.ID)
+		assert.Equal(t, "test-key-2", native.MasterKeyID)
+		loaded, err := rotatedRepo.Load(s.Ctx, externalToken.ID)
+		require.NoError(t, err)
+		s.assertToken(*externalToken, *loaded)
+		// and nothing left to re-encrypt
+		count, _, err = rotatedRepo.ReEncrypt(s.Ctx, 1000, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	s.T().Run("encrypt plain text token", func(t *testing.T) {
+		// given
+		identity, err := test.CreateTestIdentity(s.DB, uuid.NewV4().String(), "kc")
+		require.NoError(t, err)
+		externalToken := repository.ExternalToken{
+			ID:         uuid.NewV4(),
+			ProviderID: uuid.NewV4(),
+			Token:      uuid.NewV4().String(),
+			Scope:      "user:full",
+			IdentityID: identity.ID,
+			Username:   uuid.NewV4().String(),
+		}
+		err = repository.NewExternalTokenRepository(s.DB, nil).Create(s.Ctx, &externalToken)
+		require.NoError(t, err)
+		// when
+		_, _, err = s.repo.ReEncrypt(s.Ctx, 1000, nil)
+		// then
+		require.NoError(t, err)
+		native := s.loadRawToken(externalToken.ID)
+		assert.Equal(t, "test-key-1", native.MasterKeyID)
+		assert.NotEqual(t, externalToken.Token, native.Token)
+		loaded, err := s.repo.Load(s.Ctx, externalToken.ID)
+		require.NoError(t, err)
+		s.assertToken(externalToken, *loaded)
+	})
+
+	s.T().Run("skip undecryptable token", func(t *testing.T) {
+		// given a token encrypted with a master key which is not configured anymore
+		masterKeys := map[string][]byte{}
+		for id, key := range s.masterKeys {
+			masterKeys[id] = key
+		}
+		masterKeys["test-key-3"] = []byte("abcdef0123456789abcdef0123456789")
+		encryptor, err := encryption.NewEnvelopeEncryptor("test-key-3", masterKeys)
+		require.NoError(t, err)
+		identity, err := test.CreateTestIdentity(s.DB, uuid.NewV4().String(), "kc")
+		require.NoError(t, err)
+		undecryptable := repository.ExternalToken{
+			ID:         uuid.NewV4(),
+			ProviderID: uuid.NewV4(),
+			Token:      uuid.NewV4().String(),
+			Scope:      "user:full",
+			IdentityID: identity.ID,
+			Username:   uuid.NewV4().String(),
+		}
+		err = repository.NewExternalTokenRepository(s.DB, encryptor).Create(s.Ctx, &undecryptable)
+		require.NoError(t, err)
+		externalToken := createAndLoadExternalToken(s)
+		rotatedRepo := repository.NewExternalTokenRepository(s.DB, s.newEncryptor("test-key-2"))
+		// when
+		_, failed, err := rotatedRepo.ReEncrypt(s.Ctx, 1000, nil)
+		// then the other tokens are re-encrypted
+		require.NoError(t, err)
+		assert.Contains(t, failed, undecryptable.ID)
+		assert.Equal(t, "test-key-3", s.loadRawToken(undecryptable.ID).MasterKeyID)
+		assert.Equal(t, "test-key-2", s.loadRawToken(externalToken.ID).MasterKeyID)
+		// and the undecryptable token is not selected again once excluded
+		_, failed, err = rotatedRepo.ReEncrypt(s.Ctx, 1000, []uuid.UUID{undecryptable.ID})
+		require.NoError(t, err)
+		assert.NotContains(t, failed, undecryptable.ID)
+		// cleanup
+		err = s.repo.Delete(s.Ctx, undecryptable.ID)
+		require.NoError(t, err)
+	})
 }
 
 func (s *externalTokenBlackboxTest) TestOKToDelete() {
@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	"github.com/satori/go.uuid"
+)
+
+// Session groups the tokens (access, refresh and RPT tokens) issued from a single login
+type Session struct {
+	gormsupport.Lifecycle
+
+	// This is the primary key value. It matches the `session_state` claim of the tokens issued from this session
+	SessionID uuid.UUID `sql:"type:uuid" gorm:"primary_key;column:session_id"`
+
+	IdentityID uuid.UUID `sql:"type:uuid"`
+
+	// The ID of the client which initiated the login
+	ClientID string
+
+	// The user agent of the browser or tool used to login
+	UserAgent string
+
+	// The IP address from which the login was initiated
+	IPAddress string
+
+	// The last time a token of this session was used or refreshed
+	LastUsed time.Time
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m Session) TableName() string {
+	return "user_session"
+}
+
+// GormSessionRepository is the implementation of the storage interface for Session.
+type GormSessionRepository struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository creates a new storage type.
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &GormSessionRepository{db: db}
+}
+
+// TableName returns the name of the table used to store the sessions
+func (m *GormSessionRepository) TableName() string {
+	return "user_session"
+}
+
+// SessionRepository represents the storage interface.
+type SessionRepository interface {
+	Load(ctx context.Context, id uuid.UUID) (*Session, error)
+	Create(ctx context.Context, session *Session) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	DeleteForIdentity(ctx context.Context, identityID uuid.UUID) error
+	DeleteOrphans(ctx context.Context) error
+	ListForIdentity(ctx context.Context, identityID uuid.UUID) ([]Session, error)
+	Touch(ctx context.Context, id uuid.UUID, interval time.Duration) error
+}
+
+// Load returns a single Session as a Database Model
+func (m *GormSessionRepository) Load(ctx context.Context, id uuid.UUID) (*Session, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "session", "load"}, time.Now())
+
+	var native Session
+	err := m.db.Table(m.TableName()).Where("session_id = ?", id).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("session", id.String())
+	}
+	return &native, errs.WithStack(err)
+}
+
+// Create creates a new record.
+func (m *GormSessionRepository) Create(ctx context.Context, session *Session) error {
+	defer goa.MeasureSince([]string{"goa", "db", "session", "create"}, time.Now())
+
+	if session.SessionID == uuid.Nil {
+		session.SessionID = uuid.NewV4()
+	}
+	if session.LastUsed.IsZero() {
+		session.LastUsed = time.Now()
+	}
+	err := m.db.Create(session).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"session_id":  session.SessionID,
+			"identity_id": session.IdentityID,
+			"err":         err,
+		}, "unable to create the session")
+		return errs.WithStack(err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"session_id":  session.SessionID,
+		"identity_id": session.IdentityID,
+	}, "Session created!")
+	return nil
+}
+
+// Delete removes a single record. This is a hard delete!
+func (m *GormSessionRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "session", "delete"}, time.Now())
+
+	result := m.db.Unscoped().Delete(&Session{SessionID: id})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"session_id": id,
+			"err":        result.Error,
+		}, "unable to delete the session")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("session", id.String())
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"session_id": id,
+	}, "Session deleted!")
+	return nil
+}
+
+// DeleteForIdentity removes all the sessions of the given identity. This is a hard delete!
+func (m *GormSessionRepository) DeleteForIdentity(ctx context.Context, identityID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "session", "DeleteForIdentity"}, time.Now())
+
+	err := m.db.Unscoped().Where("identity_id = ?", identityID).Delete(&Session{}).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": identityID,
+			"err":         err,
+		}, "unable to delete the sessions")
+		return errs.WithStack(err)
+	}
+	return nil
+}
+
+// DeleteOrphans removes the sessions which have no token left (ie, all their tokens expired and were cleaned up)
+func (m *GormSessionRepository) DeleteOrphans(ctx context.Context) error {
+	defer goa.MeasureSince([]string{"goa", "db", "session", "DeleteOrphans"}, time.Now())
+
+	err := m.db.Exec("DELETE FROM user_session s WHERE NOT EXISTS (SELECT 1 FROM token t WHERE t.session_id = s.session_id)").Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to cleanup orphan sessions")
+		return errs.WithStack(err)
+	}
+	return nil
+}
+
+// ListForIdentity returns all the sessions of the given identity, the least recently used first
+func (m *GormSessionRepository) ListForIdentity(ctx context.Context, identityID uuid.UUID) ([]Session, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "session", "ListForIdentity"}, time.Now())
+
+	var rows []Session
+	err := m.db.Where("identity_id = ?", identityID).Order("last_used").Find(&rows).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, errs.WithStack(err)
+	}
+	return rows, nil
+}
+
+// Touch updates the last time the given session was used, unless it was already updated within the given interval
+func (m *GormSessionRepository) Touch(ctx context.Context, id uuid.UUID, interval time.Duration) error {
+	defer goa.MeasureSince([]string{"goa", "db", "session", "touch"}, time.Now())
+
+	now := time.Now()
+	err := m.db.Model(&Session{}).Where("session_id = ? AND last_used <= ?", id, now.Add(-interval)).UpdateColumn("last_used", now).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"session_id": id,
+			"err":        err,
+		}, "unable to update the session's last used timestamp")
+		return errs.WithStack(err)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package repository_test
+
+import (
+	"testing"
+	"time"
+
+	tokenPkg "github.com/fabric8-services/fabric8-auth/authorization/token"
+	tokenRepo "github.com/fabric8-services/fabric8-auth/authorization/token/repository"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type sessionBlackBoxTest struct {
+	gormtestsupport.DBTestSuite
+	repo      tokenRepo.SessionRepository
+	tokenRepo tokenRepo.TokenRepository
+}
+
+func TestRunSessionBlackBoxTest(t *testing.T) {
+	suite.Run(t, &sessionBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (s *sessionBlackBoxTest) SetupTest() {
+	s.DBTestSuite.SetupTest()
+	s.repo = tokenRepo.NewSessionRepository(s.DB)
+	s.tokenRepo = tokenRepo.NewTokenRepository(s.DB)
+}
+
+func (s *sessionBlackBoxTest) createSession(identityID uuid.UUID, lastUsed time.Time) *tokenRepo.Session {
+	session := &tokenRepo.Session{
+		IdentityID: identityID,
+		ClientID:   "740650a2-9c44-4db5-b067-a3d1b2cd2d01",
+		UserAgent:  "Mozilla/5.0",
+		IPAddress:  "10.0.0.1",
+		LastUsed:   lastUsed,
+	}
+	err := s.repo.Create(s.Ctx, session)
+	require.NoError(s.T(), err)
+	return session
+}
+
+func (s *sessionBlackBoxTest) createToken(identityID uuid.UUID, sessionID *uuid.UUID) *tokenRepo.Token {
+	tkn := &tokenRepo.Token{
+		TokenID:    uuid.NewV4(),
+		IdentityID: identityID,
+		TokenType:  tokenPkg.TOKEN_TYPE_ACCESS,
+		ExpiryTime: time.Now().Add(time.Hour),
+		SessionID:  sessionID,
+	}
+	err := s.tokenRepo.Create(s.Ctx, tkn)
+	require.NoError(s.T(), err)
+	return tkn
+}
+
+func (s *sessionBlackBoxTest) TestCreateAndLoad() {
+	// given
+	identityID := s.Graph.CreateUser().IdentityID()
+	session := s.createSession(identityID, time.Now())
+	// when
+	loaded, err := s.repo.Load(s.Ctx, session.SessionID)
+	// then
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), identityID, loaded.IdentityID)
+	assert.Equal(s.T(), session.ClientID, loaded.ClientID)
+	assert.Equal(s.T(), "Mozilla/5.0", loaded.UserAgent)
+	assert.Equal(s.T(), "10.0.0.1", loaded.IPAddress)
+}
+
+func (s *sessionBlackBoxTest) TestLoadUnknownFails() {
+	_, err := s.repo.Load(s.Ctx, uuid.NewV4())
+	require.Error(s.T(), err)
+	require.IsType(s.T(), errors.NotFoundError{}, err)
+}
+
+func (s *sessionBlackBoxTest) TestDelete() {
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		session := s.createSession(s.Graph.CreateUser().IdentityID(), time.Now())
+		// when
+		err := s.repo.Delete(s.Ctx, session.SessionID)
+		// then
+		require.NoError(t, err)
+		_, err = s.repo.Load(s.Ctx, session.SessionID)
+		require.IsType(t, errors.NotFoundError{}, err)
+	})
+
+	s.T().Run("unknown session", func(t *testing.T) {
+		err := s.repo.Delete(s.Ctx, uuid.NewV4())
+		require.Error(t, err)
+		require.IsType(t, errors.NotFoundError{}, err)
+	})
+}
+
+func (s *sessionBlackBoxTest) TestListForIdentity() {
+	// given
+	identityID := s.Graph.CreateUser().IdentityID()
+	recent := s.createSession(identityID, time.Now())
+	old := s.createSession(identityID, time.Now().Add(-2*time.Hour))
+	s.createSession(s.Graph.CreateUser().IdentityID(), time.Now())
+	// when
+	sessions, err := s.repo.ListForIdentity(s.Ctx, identityID)
+	// then
+	require.NoError(s.T(), err)
+	require.Len(s.T(), sessions, 2)
+	// the least recently used session comes first
+	assert.Equal(s.T(), old.SessionID, sessions[0].SessionID)
+	assert.Equal(s.T(), recent.SessionID, sessions[1].SessionID)
+}
+
+func (s *sessionBlackBoxTest) TestDeleteForIdentity() {
+	// given
+	identityID := s.Graph.CreateUser().IdentityID()
+	s.createSession(identityID, time.Now())
+	s.createSession(identityID, time.Now())
+	otherIdentityID := s.Graph.CreateUser().IdentityID()
+	s.createSession(otherIdentityID, time.Now())
+	// when
+	err := s.repo.DeleteForIdentity(s.Ctx, identityID)
+	// then
+	require.NoError(s.T(), err)
+	sessions, err := s.repo.ListForIdentity(s.Ctx, identityID)
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), sessions)
+	sessions, err = s.repo.ListForIdentity(s.Ctx, otherIdentityID)
+	require.NoError(s.T(), err)
+	assert.Len(s.T(), sessions, 1)
+}
+
+func (s *sessionBlackBoxTest) TestDeleteOrphans() {
+	// given
+	identityID := s.Graph.CreateUser().IdentityID()
+	withToken := s.createSession(identityID, time.Now())
+	s.createToken(identityID, &withToken.SessionID)
+	orphan := s.createSession(identityID, time.Now())
+	// when
+	err := s.repo.DeleteOrphans(s.Ctx)
+	// then
+	require.NoError(s.T(), err)
+	_, err = s.repo.Load(s.Ctx, withToken.SessionID)
+	require.NoError(s.T(), err)
+	_, err = s.repo.Load(s.Ctx, orphan.SessionID)
+	require.IsType(s.T(), errors.NotFoundError{}, err)
+}
+
+func (s *sessionBlackBoxTest) TestTouch() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		session := s.createSession(s.Graph.CreateUser().IdentityID(), time.Now().Add(-24*time.Hour))
+		// when
+		err := s.repo.Touch(s.Ctx, session.SessionID, time.Hour)
+		// then
+		require.NoError(t, err)
+		loaded, err := s.repo.Load(s.Ctx, session.SessionID)
+		require.NoError(t, err)
+		assert.True(t, loaded.LastUsed.After(time.Now().Add(-time.Minute)))
+	})
+
+	s.T().Run("within interval", func(t *testing.T) {
+		// given
+		lastUsed := time.Now().Add(-time.Minute)
+		session := s.createSession(s.Graph.CreateUser().IdentityID(), lastUsed)
+		// when
+		err := s.repo.Touch(s.Ctx, session.SessionID, time.Hour)
+		// then
+		require.NoError(t, err)
+		loaded, err := s.repo.Load(s.Ctx, session.SessionID)
+		require.NoError(t, err)
+		assert.WithinDuration(t, lastUsed, loaded.LastUsed, time.Second)
+	})
+}
+
+func (s *sessionBlackBoxTest) TestTerminatedSessionTokensAreLoggedOut() {
+	// given
+	identityID := s.Graph.CreateUser().IdentityID()
+	session := s.createSession(identityID, time.Now())
+	tkn := s.createToken(identityID, &session.SessionID)
+	otherTkn := s.createToken(identityID, nil)
+	// when
+	err := s.tokenRepo.SetStatusFlagsForSession(s.Ctx, session.SessionID, tokenPkg.TOKEN_STATUS_LOGGED_OUT)
+	// then
+	require.NoError(s.T(), err)
+	loaded, err := s.tokenRepo.Load(s.Ctx, tkn.TokenID)
+	require.NoError(s.T(), err)
+	assert.True(s.T(), loaded.HasStatus(tokenPkg.TOKEN_STATUS_LOGGED_OUT))
+	loaded, err = s.tokenRepo.Load(s.Ctx, otherTkn.TokenID)
+	require.NoError(s.T(), err)
+	assert.False(s.T(), loaded.HasStatus(tokenPkg.TOKEN_STATUS_LOGGED_OUT))
+}
@@ -31,6 +31,9 @@ type Token struct {
 
 	// The timestamp when the token will expire
 	ExpiryTime time.Time
+
+	// The session from which the token was issued, if any
+	SessionID *uuid.UUID `sql:"type:uuid"`
 }
 
 // TableName overrides the table name settings in Gorm to force a specific table name
@@ -91,9 +94,12 @@ type TokenRepository interface {
 	Save(ctx context.Context, token *Token) error
 	Delete(ctx context.Context, id uuid.UUID) error
 	ListForIdentity(ctx context.Context, id uuid.UUID) ([]Token, error)
+	CountForIdentity(ctx context.Context, id uuid.UUID) (int, error)
 	CreatePrivilege(ctx context.Context, privilege *TokenPrivilege) error
 	ListPrivileges(ctx context.Context, tokenID uuid.UUID) ([]permission.PrivilegeCache, error)
 	SetStatusFlagsForIdentity(ctx context.Context, identityID uuid.UUID, status int) error
+	SetStatusFlagsForSession(ctx context.Context, sessionID uuid.UUID, status int) error
+	TransferToIdentity(ctx context.Context, fromIdentityID uuid.UUID, toIdentityID uuid.UUID) error
 	CleanupExpiredTokens(ctx context.Context, retentionHours int) error
 }
 
@@ -228,6 +234,18 @@ func (m *GormTokenRepository) ListForIdentity(ctx context.Context, identityID uu
 	return rows, nil
 }
 
+// CountForIdentity returns the number of tokens of the given identity
+func (m *GormTokenRepository) CountForIdentity(ctx context.Context, identityID uuid.UUID) (int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "token", "CountForIdentity"}, time.Now())
+	var count int
+
+	err := m.db.Model(&Token{}).Where("identity_id = ?", identityID).Count(&count).Error
+	if err != nil {
+		return 0, errs.WithStack(err)
+	}
+	return count, nil
+}
+
 func (m *GormTokenRepository) CreatePrivilege(ctx context.Context, privilege *TokenPrivilege) error {
 	defer goa.MeasureSince([]string{"goa", "db", "token", "CreatePrivilege"}, time.Now())
 
@@ -283,6 +301,49 @@ func (m *GormTokenRepository) SetStatusFlagsForIdentity(ctx context.Context, ide
 	return nil
 }
 
+// TransferToIdentity assigns the tokens of an identity to another identity. Their status is unchanged.
+func (m *GormTokenRepository) TransferToIdentity(ctx context.Context, fromIdentityID uuid.UUID, toIdentityID uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "token", "TransferToIdentity"}, time.Now())
+
+	result := m.db.Exec("UPDATE token SET identity_id = ? WHERE identity_id = ?", toIdentityID, fromIdentityID)
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"from_identity_id": fromIdentityID.String(),
+			"to_identity_id":   toIdentityID.String(),
+			"err":              result.Error,
+		}, "unable to transfer the tokens")
+		return errs.WithStack(result.Error)
+	}
+
+	log.Info(ctx, map[string]interface{}{
+		"from_identity_id": fromIdentityID.String(),
+		"to_identity_id":   toIdentityID.String(),
+		"transferred":      result.RowsAffected,
+	}, "Tokens transferred")
+	return nil
+}
+
+// SetStatusFlagsForSession sets the given status flags on all the tokens issued from the given session
+func (m *GormTokenRepository) SetStatusFlagsForSession(ctx context.Context, sessionID uuid.UUID, status int) error {
+	defer goa.MeasureSince([]string{"goa", "db", "token", "SetStatusFlagsForSession"}, time.Now())
+
+	err := m.db.Exec("UPDATE token SET status = status | ? WHERE session_id = ? AND deleted_at IS NULL", status, sessionID).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"session_id": sessionID.String(),
+			"status":     status,
+			"err":        err,
+		}, "unable to update token status")
+		return errs.WithStack(err)
+	}
+
+	log.Info(ctx, map[string]interface{}{
+		"session_id": sessionID.String(),
+		"status":     status,
+	}, "Token status values updated")
+	return nil
+}
+
 func (m *GormTokenRepository) CleanupExpiredTokens(ctx context.Context, retentionHours int) error {
 	defer goa.MeasureSince([]string{"goa", "db", "token", "CleanupExpiredTokens"}, time.Now())
 
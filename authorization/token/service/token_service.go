@@ -31,6 +31,9 @@ type TokenServiceConfiguration interface {
 	manager.TokenManagerConfiguration
 	GetRPTTokenMaxPermissions() int
 	GetExpiredTokenRetentionHours() int
+	GetExternalTokenReencryptionBatchSize() int
+	GetMaxConcurrentSessions() int
+	GetSessionTouchInterval() time.Duration
 }
 
 type tokenServiceImpl struct {
@@ -363,7 +366,7 @@ func (s *tokenServiceImpl) ExchangeRefreshToken(ctx context.Context, refreshToke
 			"identity_id": identity.ID,
 			"user_name":   identity.Username,
 		}, "banned user tried to refresh token")
-		return nil, errors.NewUnauthorizedError("unauthorized access")
+		return nil, s.Services().UserService().BanError(ctx, identity.User, "unauthorized access")
 	}
 
 	// Initialize an array of permission objects that *may* be included in the token
@@ -540,6 +543,22 @@ func (s *tokenServiceImpl) RegisterToken(ctx context.Context, identityID uuid.UU
 
 	// Persist the token record to the database
 	err = s.ExecuteInTransaction(func() error {
+		// Link the token to the session it was issued from, if the session exists
+		if sessionID, err := uuid.FromString(tokenClaims.SessionState); err == nil {
+			session, err := s.Repositories().SessionRepository().Load(ctx, sessionID)
+			if err != nil {
+				if notFound, _ := errors.IsNotFoundError(err); !notFound {
+					return err
+				}
+			} else if session.IdentityID == identityID {
+				tkn.SessionID = &session.SessionID
+				err = s.Repositories().SessionRepository().Touch(ctx, session.SessionID, 0)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
 		err = s.Repositories().TokenRepository().Create(ctx, tkn)
 		if err != nil {
 			return err
@@ -741,7 +760,12 @@ func (s *tokenServiceImpl) SetStatusForAllIdentityTokens(ctx context.Context, id
 func (s *tokenServiceImpl) CleanupExpiredTokens(ctx context.Context) error {
 
 	err := s.ExecuteInTransaction(func() error {
-		return s.Repositories().TokenRepository().CleanupExpiredTokens(ctx, s.config.GetExpiredTokenRetentionHours())
+		err := s.Repositories().TokenRepository().CleanupExpiredTokens(ctx, s.config.GetExpiredTokenRetentionHours())
+		if err != nil {
+			return err
+		}
+		// Also remove the sessions whose tokens have all been cleaned up
+		return s.Repositories().SessionRepository().DeleteOrphans(ctx)
 	})
 
 	if err != nil {
@@ -756,6 +780,128 @@ func (s *tokenServiceImpl) CleanupExpiredTokens(ctx context.Context) error {
 	return nil
 }
 
+// ReEncryptExternalTokens encrypts with the primary master key all the external tokens which are stored in plain text
+// or which were encrypted with another master key. Tokens are processed in batches, each one in a separate transaction.
+// The tokens which cannot be decrypted are skipped, and reported in the returned error once all the other tokens were
+// processed. Returns the total number of tokens that were re-encrypted.
+func (s *tokenServiceImpl) ReEncryptExternalTokens(ctx context.Context) (int, error) {
+	batchSize := s.config.GetExternalTokenReencryptionBatchSize()
+	total := 0
+	// the tokens which could not be decrypted, excluded from the next batches
+	var failed []uuid.UUID
+	for {
+		var count int
+		var batchFailed []uuid.UUID
+		err := s.ExecuteInTransaction(func() error {
+			var err error
+			count, batchFailed, err = s.Repositories().ExternalTokens().ReEncrypt(ctx, batchSize, failed)
+			return err
+		})
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"err":    err,
+				"total":  total,
+				"failed": len(failed),
+			}, "unable to re-encrypt external tokens")
+			return total, err
+		}
+		total += count
+		failed = append(failed, batchFailed...)
+		if count+len(batchFailed) < batchSize {
+			break
+		}
+	}
+	log.Info(ctx, map[string]interface{}{
+		"total":  total,
+		"failed": len(failed),
+	}, "re-encrypted external tokens")
+	if len(failed) > 0 {
+		return total, errors.NewInternalErrorFromString(fmt.Sprintf("unable to decrypt %d external tokens", len(failed)))
+	}
+	return total, nil
+}
+
+// StartSession starts a new session for the given identity. The tokens whose `session_state` claim matches the given
+// session ID will be linked to this session when they are registered. If the identity already reached the maximum number
+// of concurrent sessions, then its least recently used sessions are terminated.
+func (s *tokenServiceImpl) StartSession(ctx context.Context, identityID uuid.UUID, sessionID uuid.UUID, clientID string) (*tokenrepo.Session, error) {
+	session := &tokenrepo.Session{
+		SessionID:  sessionID,
+		IdentityID: identityID,
+		ClientID:   clientID,
+		LastUsed:   time.Now(),
+	}
+	if req := goa.ContextRequest(ctx); req != nil && req.Request != nil {
+		session.UserAgent = req.UserAgent()
+		session.IPAddress = rest.ClientIP(req.Request)
+	}
+
+	err := s.ExecuteInTransaction(func() error {
+		if maxSessions := s.config.GetMaxConcurrentSessions(); maxSessions > 0 {
+			// Sessions are sorted by last use, the least recently used first
+			sessions, err := s.Repositories().SessionRepository().ListForIdentity(ctx, identityID)
+			if err != nil {
+				return err
+			}
+			for i := 0; i <= len(sessions)-maxSessions; i++ {
+				log.Info(ctx, map[string]interface{}{
+					"identity_id":  identityID,
+					"session_id":   sessions[i].SessionID,
+					"max_sessions": maxSessions,
+				}, "terminating least recently used session")
+				err = s.terminateSession(ctx, identityID, sessions[i].SessionID)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return s.Repositories().SessionRepository().Create(ctx, session)
+	})
+	if err != nil {
+		return nil, errors.NewInternalError(err)
+	}
+	return session, nil
+}
+
+// ListSessions returns the active sessions of the given identity
+func (s *tokenServiceImpl) ListSessions(ctx context.Context, identityID uuid.UUID) ([]tokenrepo.Session, error) {
+	return s.Repositories().SessionRepository().ListForIdentity(ctx, identityID)
+}
+
+// TerminateSession terminates the given session of the given identity: all the tokens issued from the session
+// are marked as logged out and the session is removed. Returns a NotFoundError if the session does not exist
+// or if it does not belong to the given identity
+func (s *tokenServiceImpl) TerminateSession(ctx context.Context, identityID uuid.UUID, sessionID uuid.UUID) error {
+	return s.ExecuteInTransaction(func() error {
+		session, err := s.Repositories().SessionRepository().Load(ctx, sessionID)
+		if err != nil {
+			return err
+		}
+		if session.IdentityID != identityID {
+			log.Warn(ctx, map[string]interface{}{
+				"identity_id": identityID,
+				"session_id":  sessionID,
+			}, "identity attempted to terminate a session of another identity")
+			return errors.NewNotFoundError("session", sessionID.String())
+		}
+		return s.terminateSession(ctx, identityID, sessionID)
+	})
+}
+
+// terminateSession logs out the tokens of the given session, deletes the session and notifies the relying parties
+// that the session was terminated
+func (s *tokenServiceImpl) terminateSession(ctx context.Context, identityID uuid.UUID, sessionID uuid.UUID) error {
+	err := s.Repositories().TokenRepository().SetStatusFlagsForSession(ctx, sessionID, authtoken.TOKEN_STATUS_LOGGED_OUT)
+	if err != nil {
+		return err
+	}
+	err = s.Repositories().SessionRepository().Delete(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	return s.Services().LogoutService().ScheduleBackChannelLogoutNotifications(ctx, identityID, &sessionID)
+}
+
 // ValidateToken extracts the token ID (the "jti" claim) from the token and uses it to perform a db lookup of the token's
 // status, and if the status is invalid will return an unauthorized error.  For valid tokens, it will also update the
 // identity's (determined from the token's "sub" claim) last active timestamp
@@ -800,6 +946,15 @@ func (s *tokenServiceImpl) ValidateToken(ctx context.Context, accessToken *jwt.T
 		}
 	}
 
+	// Update the session's last used timestamp, at most once per configured interval
+	if tkn.SessionID != nil {
+		err = s.Repositories().SessionRepository().Touch(ctx, *tkn.SessionID, s.config.GetSessionTouchInterval())
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{"error": err}, "could not update session last used timestamp")
+			return errors.NewInternalError(err)
+		}
+	}
+
 	if !transient {
 		identityID, err := uuid.FromString(claims["sub"].(string))
 		if err != nil {
@@ -24,6 +24,7 @@ const (
 	OnlineRegistration = "online-registration"
 	RhChe              = "rh-che"
 	GeminiServer       = "fabric8-gemini-server"
+	SCIMProvisioning   = "scim-provisioning"
 
 	_ = iota
 
@@ -37,6 +38,22 @@ const (
 	TOKEN_TYPE_RPT     = "RPT"
 	TOKEN_TYPE_ACCESS  = "ACC"
 	TOKEN_TYPE_REFRESH = "REF"
+
+	// Authentication Context Class References ("acr" claim)
+
+	// ACRSingleFactor the user was authenticated by the identity provider only
+	ACRSingleFactor = "0"
+	// ACRMultiFactor the user was authenticated by the identity provider and then verified a second factor
+	ACRMultiFactor = "2"
+
+	// Authentication Methods References ("amr" claim), as defined in RFC 8176
+
+	// AuthMethodOTP the user verified a one-time password (TOTP or recovery code)
+	AuthMethodOTP = "otp"
+	// AuthMethodHardwareKey the user verified a proof-of-possession of a hardware-secured key (WebAuthn authenticator)
+	AuthMethodHardwareKey = "hwk"
+	// AuthMethodMFA the user was authenticated with multiple factors
+	AuthMethodMFA = "mfa"
 )
 
 // PrivateKey represents an RSA private key with a Key ID
@@ -78,6 +95,17 @@ func IsSpecificServiceAccount(ctx context.Context, names ...string) bool {
 	return false
 }
 
+// IsMultiFactorAuthenticated checks if the request is done with a token
+// issued after the user verified a second authentication factor
+func IsMultiFactorAuthenticated(ctx context.Context) bool {
+	token := goajwt.ContextJWT(ctx)
+	if token == nil {
+		return false
+	}
+	acr, isString := token.Claims.(jwt.MapClaims)["acr"].(string)
+	return isString && acr == ACRMultiFactor
+}
+
 // IsServiceAccount checks if the request is done by a
 // Service account based on the JWT Token provided in context
 func IsServiceAccount(ctx context.Context) bool {
@@ -85,6 +113,12 @@ func IsServiceAccount(ctx context.Context) bool {
 	return ok
 }
 
+// ServiceAccountName returns the name of the service account on behalf of which the request is done,
+// based on the JWT Token provided in context
+func ServiceAccountName(ctx context.Context) (string, bool) {
+	return extractServiceAccountName(ctx)
+}
+
 func extractServiceAccountName(ctx context.Context) (string, bool) {
 	token := goajwt.ContextJWT(ctx)
 	if token == nil {
@@ -1,11 +1,16 @@
 package token_test
 
 import (
+	"context"
+	"testing"
+
 	"github.com/fabric8-services/fabric8-auth/authorization/token"
 	testsuite "github.com/fabric8-services/fabric8-auth/test/suite"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	goajwt "github.com/goadesign/goa/middleware/security/jwt"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
-	"testing"
 )
 
 type tokenBlackboxTest struct {
@@ -22,3 +27,13 @@ func (s *tokenBlackboxTest) TestIsValidTokenType() {
 	require.True(s.T(), token.IsValidTokenType("RPT"))
 	require.False(s.T(), token.IsValidTokenType("foo"))
 }
+
+func (s *tokenBlackboxTest) TestIsMultiFactorAuthenticated() {
+	require.False(s.T(), token.IsMultiFactorAuthenticated(context.Background()))
+	ctx := goajwt.WithJWT(context.Background(), jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"acr": token.ACRSingleFactor}))
+	require.False(s.T(), token.IsMultiFactorAuthenticated(ctx))
+	ctx = goajwt.WithJWT(context.Background(), jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{}))
+	require.False(s.T(), token.IsMultiFactorAuthenticated(ctx))
+	ctx = goajwt.WithJWT(context.Background(), jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"acr": token.ACRMultiFactor}))
+	require.True(s.T(), token.IsMultiFactorAuthenticated(ctx))
+}
@@ -0,0 +1,50 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/worker"
+)
+
+const (
+	// ExternalTokenReencryption the name of the job that re-encrypts the external tokens and the TOTP secrets with the
+	// primary master key. Also, the name of the lock used by its worker.
+	ExternalTokenReencryption = "external-token-reencryption"
+)
+
+// NewExternalTokenReencryptionJob returns a new job which encrypts the external tokens stored in plain text
+// and re-encrypts the tokens whose data key was wrapped with a master key other than the primary one (e.g., after a key rotation).
+// The TOTP secrets of the users, which are encrypted with the same master keys, are re-encrypted as well.
+func NewExternalTokenReencryptionJob(app application.Application, schedule string) worker.Job {
+	return worker.Job{
+		Name:     ExternalTokenReencryption,
+		Schedule: schedule,
+		Run: func(ctx context.Context) (int, error) {
+			return reencryptTokens(ctx, app)
+		},
+	}
+}
+
+// reencryptTokens re-encrypts the external tokens and the TOTP credentials, and returns the number of re-encrypted
+// records. The TOTP credentials are re-encrypted even if the re-encryption of the external tokens failed.
+func reencryptTokens(ctx context.Context, app application.Application) (int, error) {
+	tokens, tokensErr := app.TokenService().ReEncryptExternalTokens(ctx)
+	if tokensErr != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": tokensErr,
+		}, "error while re-encrypting external tokens")
+	}
+	log.Info(ctx, map[string]interface{}{
+		"tokens": tokens,
+	}, "ending cycle of external tokens re-encryption")
+	credentials, err := app.MFAService().ReEncryptTOTPCredentials(ctx)
+	if err != nil {
+		return tokens + credentials, err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"credentials": credentials,
+	}, "ending cycle of TOTP credentials re-encryption")
+	return tokens + credentials, tokensErr
+}
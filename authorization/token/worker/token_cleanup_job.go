@@ -0,0 +1,25 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/worker"
+)
+
+const (
+	// TokenCleanup the name of the job that deletes the expired tokens.
+	// Also, the name of the lock used by its worker.
+	TokenCleanup = "token-cleanup"
+)
+
+// NewTokenCleanupJob returns a new job which deletes the tokens which expired for longer than the retention period
+func NewTokenCleanupJob(app application.Application, schedule string) worker.Job {
+	return worker.Job{
+		Name:     TokenCleanup,
+		Schedule: schedule,
+		Run: func(ctx context.Context) (int, error) {
+			return 0, app.TokenService().CleanupExpiredTokens(ctx)
+		},
+	}
+}
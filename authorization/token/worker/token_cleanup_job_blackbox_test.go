@@ -12,15 +12,15 @@ import (
 	"github.com/stretchr/testify/suite"
 )
 
-type tokenCleanupWorkerBlackBoxTest struct {
+type tokenCleanupJobBlackBoxTest struct {
 	gormtestsupport.DBTestSuite
 }
 
-func TestRunTokenCleanupWorkerBlackBoxTest(t *testing.T) {
-	suite.Run(t, &tokenCleanupWorkerBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+func TestRunTokenCleanupJobBlackBoxTest(t *testing.T) {
+	suite.Run(t, &tokenCleanupJobBlackBoxTest{DBTestSuite: gormtestsupport.NewDBTestSuite()})
 }
 
-func (s *tokenCleanupWorkerBlackBoxTest) TestCleanupWorker() {
+func (s *tokenCleanupJobBlackBoxTest) TestCleanupJob() {
 	now := time.Now()
 	yesterday := now.AddDate(0, 0, -1)
 	tomorrow := now.AddDate(0, 0, 1)
@@ -34,29 +34,21 @@ func (s *tokenCleanupWorkerBlackBoxTest) TestCleanupWorker() {
 
 	require.Equal(s.T(), 3, s.countTokens())
 
-	// Start the worker with a 50ms ticker
-	worker := worker.NewTokenCleanupWorker(s.Ctx, s.Application)
-	worker.Start(time.Millisecond * 50)
-	defer worker.Stop()
-
-	for i := 0; i < 30; i++ {
-		time.Sleep(time.Millisecond * 100)
-
-		if s.countTokens() == 1 {
-			break
-		}
-	}
+	// Run the job
+	_, err := worker.NewTokenCleanupJob(s.Application, "@hourly").Run(s.Ctx)
+	require.NoError(s.T(), err)
 
+	require.Equal(s.T(), 1, s.countTokens())
 	require.False(s.T(), s.tokenExists(t1.TokenID()))
 	require.False(s.T(), s.tokenExists(t2.TokenID()))
 	require.True(s.T(), s.tokenExists(t3.TokenID()))
 }
 
-func (s *tokenCleanupWorkerBlackBoxTest) deleteAllTokens() error {
+func (s *tokenCleanupJobBlackBoxTest) deleteAllTokens() error {
 	return s.DB.Exec("DELETE FROM token").Error
 }
 
-func (s *tokenCleanupWorkerBlackBoxTest) countTokens() int {
+func (s *tokenCleanupJobBlackBoxTest) countTokens() int {
 	var result *int64
 
 	err := s.DB.Table("token").Count(&result).Error
@@ -65,7 +57,7 @@ func (s *tokenCleanupWorkerBlackBoxTest) countTokens() int {
 	return int(*result)
 }
 
-func (s *tokenCleanupWorkerBlackBoxTest) tokenExists(tokenID uuid.UUID) bool {
+func (s *tokenCleanupJobBlackBoxTest) tokenExists(tokenID uuid.UUID) bool {
 	exists, err := s.Application.TokenRepository().CheckExists(s.Ctx, tokenID)
 	if err != nil {
 		require.IsType(s.T(), err, errors.NotFoundError{})
@@ -0,0 +1,80 @@
+package blob
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+)
+
+const (
+	// StorageFileSystem the objects are stored in a directory of the local filesystem, which must be shared by all
+	// the pods
+	StorageFileSystem = "filesystem"
+	// StorageS3 the objects are stored in a bucket of an S3-compatible object storage
+	StorageS3 = "s3"
+)
+
+// Object a binary object along with its content type
+type Object struct {
+	Data         []byte
+	ContentType  string
+	LastModified time.Time
+}
+
+// Store stores the binary objects under keys made of segments separated by slashes
+type Store interface {
+	// Put creates or replaces the object with the given key
+	Put(ctx context.Context, key string, object Object) error
+	// Get returns the object with the given key, or a NotFoundError if there is none
+	Get(ctx context.Context, key string) (*Object, error)
+	// Delete deletes the object with the given key. Deleting an object which does not exist is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Configuration the configuration of the blob storage
+type Configuration interface {
+	GetBlobStorage() string
+	GetBlobStorageDirectory() string
+	GetBlobStorageS3Endpoint() string
+	GetBlobStorageS3Region() string
+	GetBlobStorageS3Bucket() string
+	GetBlobStorageS3AccessKeyID() string
+	GetBlobStorageS3SecretAccessKey() string
+}
+
+// NewStore returns the store of the configured kind
+func NewStore(config Configuration) (Store, error) {
+	switch config.GetBlobStorage() {
+	case StorageFileSystem:
+		return NewFileSystemStore(config.GetBlobStorageDirectory())
+	case StorageS3:
+		return NewS3Store(S3Options{
+			Endpoint:        config.GetBlobStorageS3Endpoint(),
+			Region:          config.GetBlobStorageS3Region(),
+			Bucket:          config.GetBlobStorageS3Bucket(),
+			AccessKeyID:     config.GetBlobStorageS3AccessKeyID(),
+			SecretAccessKey: config.GetBlobStorageS3SecretAccessKey(),
+		})
+	default:
+		return nil, errors.NewInternalErrorFromString(fmt.Sprintf("unknown blob storage: '%s'", config.GetBlobStorage()))
+	}
+}
+
+// keyPattern the pattern which the segments of the keys must match
+var keyPattern = regexp.MustCompile(`^[a-zA-Z0-9_-][a-zA-Z0-9._-]*$`)
+
+// validateKey checks that the key is made of non-empty segments which only contain safe characters, so that it can
+// be used as a relative path or in a URL without escaping, and cannot refer to a parent directory (segments cannot
+// start with a dot)
+func validateKey(key string) error {
+	for _, segment := range strings.Split(key, "/") {
+		if !keyPattern.MatchString(segment) {
+			return errors.NewBadParameterError("key", key).Expected(keyPattern.String() + " segments separated by slashes")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,3 @@
+// Package blob contains the storage of binary objects, such as the avatars of the users, behind a pluggable interface
+// with an implementation on the local filesystem and another one on an S3-compatible object storage.
+package blob
@@ -0,0 +1,104 @@
+package blob
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	errs "github.com/pkg/errors"
+)
+
+// FileSystemStore stores the objects as files in a directory. The content type of the objects is not stored, but
+// sniffed from their data when they are read.
+type FileSystemStore struct {
+	dir string
+}
+
+// NewFileSystemStore returns a store which keeps the objects in the given directory, which is created if needed
+func NewFileSystemStore(dir string) (*FileSystemStore, error) {
+	if dir == "" {
+		return nil, errors.NewInternalErrorFromString("the directory of the blob storage is not configured")
+	}
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, errs.Wrapf(err, "unable to create the directory of the blob storage: '%s'", dir)
+	}
+	return &FileSystemStore{dir: dir}, nil
+}
+
+func (s *FileSystemStore) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(key))
+}
+
+// Put creates or replaces the object with the given key. The data is first written to a temporary file which is then
+// renamed, so that the object is never read partially written.
+func (s *FileSystemStore) Put(ctx context.Context, key string, object Object) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	path := s.path(key)
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return errs.Wrapf(err, "unable to create the directory of the object '%s'", key)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return errs.Wrapf(err, "unable to create the object '%s'", key)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+	_, err = tmp.Write(object.Data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errs.Wrapf(err, "unable to write the object '%s'", key)
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return errs.Wrapf(err, "unable to write the object '%s'", key)
+	}
+	log.Debug(ctx, map[string]interface{}{"key": key}, "blob stored")
+	return nil
+}
+
+// Get returns the object with the given key, or a NotFoundError if there is none
+func (s *FileSystemStore) Get(ctx context.Context, key string) (*Object, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+	path := s.path(key)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, errors.NewNotFoundErrorWithKey("blob", "key", key)
+	}
+	if err != nil {
+		return nil, errs.Wrapf(err, "unable to read the object '%s'", key)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errs.Wrapf(err, "unable to read the object '%s'", key)
+	}
+	return &Object{
+		Data:         data,
+		ContentType:  http.DetectContentType(data),
+		LastModified: info.ModTime(),
+	}, nil
+}
+
+// Delete deletes the object with the given key, if it exists
+func (s *FileSystemStore) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	err := os.Remove(s.path(key))
+	if err != nil && !os.IsNotExist(err) {
+		return errs.Wrapf(err, "unable to delete the object '%s'", key)
+	}
+	log.Debug(ctx, map[string]interface{}{"key": key}, "blob deleted")
+	return nil
+}
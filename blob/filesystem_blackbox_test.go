@@ -0,0 +1,82 @@
+package blob_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/blob"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/resource"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSystemStore(t *testing.T) {
+	resource.Require(t, resource.UnitTest)
+	dir, err := ioutil.TempDir("", "blob-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store, err := blob.NewFileSystemStore(dir)
+	require.NoError(t, err)
+	ctx := context.Background()
+	data := []byte("\x89PNG\r\n\x1a\nnot really an image")
+
+	t.Run("put and get", func(t *testing.T) {
+		// when
+		err := store.Put(ctx, "avatars/1234/128.png", blob.Object{Data: data, ContentType: "image/png"})
+		// then
+		require.NoError(t, err)
+		object, err := store.Get(ctx, "avatars/1234/128.png")
+		require.NoError(t, err)
+		assert.Equal(t, data, object.Data)
+		assert.Equal(t, "image/png", object.ContentType)
+		assert.False(t, object.LastModified.IsZero())
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		// given
+		err := store.Put(ctx, "avatars/replaced", blob.Object{Data: []byte("first")})
+		require.NoError(t, err)
+		// when
+		err = store.Put(ctx, "avatars/replaced", blob.Object{Data: []byte("second")})
+		// then
+		require.NoError(t, err)
+		object, err := store.Get(ctx, "avatars/replaced")
+		require.NoError(t, err)
+		assert.Equal(t, "second", string(object.Data))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		// given
+		err := store.Put(ctx, "avatars/deleted", blob.Object{Data: data})
+		require.NoError(t, err)
+		// when
+		err = store.Delete(ctx, "avatars/deleted")
+		// then
+		require.NoError(t, err)
+		_, err = store.Get(ctx, "avatars/deleted")
+		require.IsType(t, errors.NotFoundError{}, err)
+		// deleting again is not an error
+		err = store.Delete(ctx, "avatars/deleted")
+		require.NoError(t, err)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := store.Get(ctx, "avatars/unknown")
+		require.IsType(t, errors.NotFoundError{}, err)
+	})
+
+	t.Run("invalid keys", func(t *testing.T) {
+		for _, key := range []string{"", "../outside", "avatars/../../outside", "/absolute", "avatars//empty", "avatars/.hidden", "spaces in key"} {
+			t.Run(key, func(t *testing.T) {
+				err := store.Put(ctx, key, blob.Object{Data: data})
+				require.IsType(t, errors.BadParameterError{}, err)
+				_, err = store.Get(ctx, key)
+				require.IsType(t, errors.BadParameterError{}, err)
+			})
+		}
+	})
+}
@@ -0,0 +1,230 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/rest"
+
+	errs "github.com/pkg/errors"
+)
+
+const (
+	s3SigningAlgorithm = "AWS4-HMAC-SHA256"
+	s3AmzDateFormat    = "20060102T150405Z"
+	s3DateFormat       = "20060102"
+)
+
+// S3Options the options of a store on an S3-compatible object storage
+type S3Options struct {
+	// Endpoint the URL of the object storage, eg: `https://s3.us-east-1.amazonaws.com` or the URL of a MinIO server
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// S3Store stores the objects in a bucket of an S3-compatible object storage. The requests use the path-style URLs
+// (`<endpoint>/<bucket>/<key>`), which all the S3-compatible implementations support, and are signed with the AWS
+// Signature Version 4.
+type S3Store struct {
+	options  S3Options
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// NewS3Store returns a store on the S3-compatible object storage with the given options
+func NewS3Store(options S3Options, httpOptions ...rest.HTTPClientOption) (*S3Store, error) {
+	if options.Endpoint == "" || options.Bucket == "" || options.Region == "" {
+		return nil, errors.NewInternalErrorFromString("the endpoint, the region and the bucket of the S3 blob storage must be configured")
+	}
+	endpoint, err := url.Parse(options.Endpoint)
+	if err != nil {
+		return nil, errs.Wrapf(err, "invalid endpoint of the S3 blob storage: '%s'", options.Endpoint)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	for _, opt := range httpOptions {
+		opt(client)
+	}
+	return &S3Store{
+		options:  options,
+		endpoint: endpoint,
+		client:   client,
+		now:      time.Now,
+	}, nil
+}
+
+// Put creates or replaces the object with the given key
+func (s *S3Store) Put(ctx context.Context, key string, object Object) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	headers := http.Header{}
+	headers.Set("Content-Type", object.ContentType)
+	resp, err := s.do(ctx, http.MethodPut, key, headers, object.Data)
+	if err != nil {
+		return err
+	}
+	defer rest.CloseResponse(resp)
+	if resp.StatusCode != http.StatusOK {
+		return s.responseError(ctx, resp, key)
+	}
+	log.Debug(ctx, map[string]interface{}{"key": key}, "blob stored")
+	return nil
+}
+
+// Get returns the object with the given key, or a NotFoundError if there is none
+func (s *S3Store) Get(ctx context.Context, key string) (*Object, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, http.MethodGet, key, http.Header{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rest.CloseResponse(resp)
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errors.NewNotFoundErrorWithKey("blob", "key", key)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s.responseError(ctx, resp, key)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errs.Wrapf(err, "unable to read the object '%s'", key)
+	}
+	object := &Object{
+		Data:        data,
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+	if lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		object.LastModified = lastModified
+	}
+	return object, nil
+}
+
+// Delete deletes the object with the given key. S3 does not report an error if the object does not exist.
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodDelete, key, http.Header{}, nil)
+	if err != nil {
+		return err
+	}
+	defer rest.CloseResponse(resp)
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s.responseError(ctx, resp, key)
+	}
+	log.Debug(ctx, map[string]interface{}{"key": key}, "blob deleted")
+	return nil
+}
+
+func (s *S3Store) responseError(ctx context.Context, resp *http.Response, key string) error {
+	body := rest.ReadBody(resp.Body)
+	log.Error(ctx, map[string]interface{}{
+		"key":           key,
+		"response_code": resp.StatusCode,
+		"response_body": body,
+	}, "unexpected response from the S3 blob storage")
+	return errors.NewInternalErrorFromString(fmt.Sprintf("unexpected response from the S3 blob storage for the object '%s': %d", key, resp.StatusCode))
+}
+
+// do sends the signed request on the object with the given key
+func (s *S3Store) do(ctx context.Context, method, key string, headers http.Header, body []byte) (*http.Response, error) {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.options.Bucket + "/" + key
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	s.sign(req, body)
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errs.Wrapf(err, "unable to send the request to the S3 blob storage for the object '%s'", key)
+	}
+	return resp, nil
+}
+
+// sign adds the `Authorization` header of the AWS Signature Version 4 to the request, along with the headers which are
+// signed with it
+func (s *S3Store) sign(req *http.Request, body []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format(s3AmzDateFormat)
+	date := now.Format(s3DateFormat)
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// the canonical headers, including the host which is not part of the headers of the request
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := &strings.Builder{}
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := strings.Join([]string{date, s.options.Region, "s3", "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		s3SigningAlgorithm,
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+	signingKey := deriveSigningKey(s.options.SecretAccessKey, date, s.options.Region, "s3")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3SigningAlgorithm, s.options.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// deriveSigningKey derives the key which signs the requests of the given day, for the given region and service
+func deriveSigningKey(secretAccessKey, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
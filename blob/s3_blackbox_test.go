@@ -0,0 +1,123 @@
+package blob_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/blob"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/resource"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 an in-memory S3-compatible server, which checks that the requests are signed and that the payload matches
+// its signed hash
+type fakeS3 struct {
+	t       *testing.T
+	lock    sync.Mutex
+	objects map[string]blob.Object
+}
+
+func (f *fakeS3) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	authorization := req.Header.Get("Authorization")
+	assert.True(f.t, strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=access-key/"), authorization)
+	assert.Contains(f.t, authorization, "/us-east-1/s3/aws4_request, SignedHeaders=")
+	assert.Contains(f.t, authorization, "host;")
+	assert.NotEmpty(f.t, req.Header.Get("X-Amz-Date"))
+	body, err := ioutil.ReadAll(req.Body)
+	assert.NoError(f.t, err)
+	sum := sha256.Sum256(body)
+	assert.Equal(f.t, hex.EncodeToString(sum[:]), req.Header.Get("X-Amz-Content-Sha256"))
+
+	key := req.URL.Path
+	switch req.Method {
+	case http.MethodPut:
+		f.objects[key] = blob.Object{Data: body, ContentType: req.Header.Get("Content-Type"), LastModified: time.Now()}
+		rw.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		object, found := f.objects[key]
+		if !found {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		rw.Header().Set("Content-Type", object.ContentType)
+		rw.Header().Set("Last-Modified", object.LastModified.UTC().Format(http.TimeFormat))
+		rw.WriteHeader(http.StatusOK)
+		rw.Write(object.Data)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		rw.WriteHeader(http.StatusNoContent)
+	default:
+		rw.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	resource.Require(t, resource.UnitTest)
+	fake := &fakeS3{t: t, objects: map[string]blob.Object{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	store, err := blob.NewS3Store(blob.S3Options{
+		Endpoint:        server.URL,
+		Region:          "us-east-1",
+		Bucket:          "avatars-bucket",
+		AccessKeyID:     "access-key",
+		SecretAccessKey: "secret-key",
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+	data := []byte("\x89PNG\r\n\x1a\nnot really an image")
+
+	t.Run("put and get", func(t *testing.T) {
+		// when
+		err := store.Put(ctx, "avatars/1234/128.png", blob.Object{Data: data, ContentType: "image/png"})
+		// then
+		require.NoError(t, err)
+		assert.Contains(t, fake.objects, "/avatars-bucket/avatars/1234/128.png")
+		object, err := store.Get(ctx, "avatars/1234/128.png")
+		require.NoError(t, err)
+		assert.Equal(t, data, object.Data)
+		assert.Equal(t, "image/png", object.ContentType)
+		assert.False(t, object.LastModified.IsZero())
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		// given
+		err := store.Put(ctx, "avatars/deleted", blob.Object{Data: data, ContentType: "image/png"})
+		require.NoError(t, err)
+		// when
+		err = store.Delete(ctx, "avatars/deleted")
+		// then
+		require.NoError(t, err)
+		_, err = store.Get(ctx, "avatars/deleted")
+		require.IsType(t, errors.NotFoundError{}, err)
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		failing := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.WriteHeader(http.StatusForbidden)
+		}))
+		defer failing.Close()
+		store, err := blob.NewS3Store(blob.S3Options{Endpoint: failing.URL, Region: "us-east-1", Bucket: "avatars-bucket"})
+		require.NoError(t, err)
+		err = store.Put(ctx, "avatars/failed", blob.Object{Data: data})
+		require.IsType(t, errors.InternalError{}, err)
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		_, err := blob.NewS3Store(blob.S3Options{Endpoint: server.URL})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,52 @@
+package blob
+
+import (
+	"encoding/hex"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/resource"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeriveSigningKey(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+	// the example of the AWS documentation
+	key := deriveSigningKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	assert.Equal(t, "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d", hex.EncodeToString(key))
+}
+
+func TestSign(t *testing.T) {
+	t.Parallel()
+	resource.Require(t, resource.UnitTest)
+	store, err := NewS3Store(S3Options{
+		Endpoint:        "https://s3.example.com",
+		Region:          "us-east-1",
+		Bucket:          "bucket",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "secret",
+	})
+	require.NoError(t, err)
+	store.now = func() time.Time {
+		return time.Date(2013, 5, 24, 0, 0, 0, 0, time.UTC)
+	}
+	req, err := http.NewRequest(http.MethodGet, "https://s3.example.com/bucket/avatars/1234/128.png", nil)
+	require.NoError(t, err)
+	// when
+	store.sign(req, nil)
+	// then
+	assert.Equal(t, "20130524T000000Z", req.Header.Get("X-Amz-Date"))
+	// the hash of an empty payload
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", req.Header.Get("X-Amz-Content-Sha256"))
+	assert.Regexp(t, `^AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20130524/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=[0-9a-f]{64}$`,
+		req.Header.Get("Authorization"))
+	// the signature is deterministic
+	signature := req.Header.Get("Authorization")
+	req.Header.Del("Authorization")
+	store.sign(req, nil)
+	assert.Equal(t, signature, req.Header.Get("Authorization"))
+}
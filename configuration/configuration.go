@@ -2,13 +2,16 @@ package configuration
 
 import (
 	"bytes"
+	"encoding/base64"
 	"fmt"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/fabric8-services/fabric8-auth/authentication/account"
 	"github.com/fabric8-services/fabric8-auth/rest"
 
 	"github.com/pkg/errors"
@@ -201,6 +204,12 @@ const (
 	varUserDeactivationWorkerRescheduleDelayHours = "user.deactivation.reschedule.delay.hours"
 	// varUserExcludeList the list of *space-separated* usernames to exclude from user deactivation
 	varUserDeactivationWhiteList = "user.deactivation.whitelist"
+	// varUserDeactivationDryRun true if the user deactivation and notification workers should only report the users they
+	// would notify or deactivate, without actually notifying nor deactivating them
+	varUserDeactivationDryRun = "user.deactivation.dryrun"
+	// varUserDeactivationPolicies the JSON array of the policies which define the inactivity period, the reminders and the
+	// exemptions of the users they select. Users who are not selected by any policy fall back to the global settings.
+	varUserDeactivationPolicies = "user.deactivation.policies"
 	// varAdminConsoleServiceURL the URL to the Admin Console service
 	varAdminConsoleServiceURL = "admin.console.serviceurl"
 
@@ -223,6 +232,210 @@ const (
 	// Token cleanup
 	varExpiredTokenRetentionHours = "expired.token.retention.hours"
 
+	//------------------------------------------------------------------------------------------------------------------
+	//
+	// External token encryption
+	//
+	//------------------------------------------------------------------------------------------------------------------
+
+	// varExternalTokenEncryptionMasterKeys the list of *space-separated* master keys used to wrap the data keys
+	// of the external tokens, each key being in the `<key id>:<base64-encoded AES key>` format
+	varExternalTokenEncryptionMasterKeys = "externaltoken.encryption.masterkeys"
+	// varExternalTokenEncryptionPrimaryKeyID the ID of the master key to use when encrypting new external tokens
+	varExternalTokenEncryptionPrimaryKeyID = "externaltoken.encryption.primarykeyid"
+	// varExternalTokenReencryptionEnabled true if the worker which re-encrypts the external tokens with the primary master key should be enabled
+	varExternalTokenReencryptionEnabled = "externaltoken.reencryption.enabled"
+	// varExternalTokenReencryptionWorkerIntervalSeconds the interval between 2 cycles of the external token re-encryption worker
+	varExternalTokenReencryptionWorkerIntervalSeconds = "externaltoken.reencryption.interval.seconds"
+	// varExternalTokenReencryptionBatchSize the maximum number of external tokens to re-encrypt in a single transaction
+	varExternalTokenReencryptionBatchSize = "externaltoken.reencryption.batch.size"
+
+	// varMaxConcurrentSessions the maximum number of concurrent sessions per user (0 means unlimited)
+	varMaxConcurrentSessions = "session.max.concurrent"
+	// varSessionTouchIntervalSeconds the minimum interval between 2 updates of the last used timestamp of a session
+	varSessionTouchIntervalSeconds = "session.touch.interval.seconds"
+
+	//------------------------------------------------------------------------------------------------------------------
+	//
+	// Back-channel logout
+	//
+	//------------------------------------------------------------------------------------------------------------------
+
+	// varBackChannelLogoutEnabled true if the worker which notifies the relying parties when a user logs out should be enabled
+	varBackChannelLogoutEnabled = "backchannel.logout.enabled"
+	// varBackChannelLogoutWorkerIntervalSeconds the interval between 2 cycles of the back-channel logout worker
+	varBackChannelLogoutWorkerIntervalSeconds = "backchannel.logout.interval.seconds"
+	// varBackChannelLogoutBatchSize the maximum number of notifications to send during a single cycle of the back-channel logout worker
+	varBackChannelLogoutBatchSize = "backchannel.logout.batch.size"
+	// varBackChannelLogoutMaxAttempts the maximum number of attempts to notify a relying party before giving up
+	varBackChannelLogoutMaxAttempts = "backchannel.logout.max.attempts"
+	// varBackChannelLogoutRetryDelaySeconds the delay before the first retry. The delay doubles after each failed attempt
+	varBackChannelLogoutRetryDelaySeconds = "backchannel.logout.retry.delay.seconds"
+	// varBackChannelLogoutRequestTimeoutSeconds the timeout of the requests sent to the back-channel logout URIs
+	varBackChannelLogoutRequestTimeoutSeconds = "backchannel.logout.request.timeout.seconds"
+
+	//------------------------------------------------------------------------------------------------------------------
+	//
+	// Multi-factor authentication
+	//
+	//------------------------------------------------------------------------------------------------------------------
+
+	// varMFATOTPIssuer the issuer displayed by the authenticator applications
+	varMFATOTPIssuer = "mfa.totp.issuer"
+	// varMFAVerificationURL the URL of the page where the users enter their TOTP or recovery code during login
+	varMFAVerificationURL = "mfa.verification.url"
+	// varMFAChallengeExpiresInSeconds the time given to the users to verify their second factor during login
+	varMFAChallengeExpiresInSeconds = "mfa.challenge.expires.seconds"
+	// varMFAChallengeMaxAttempts the maximum number of invalid codes before a login is aborted
+	varMFAChallengeMaxAttempts = "mfa.challenge.max.attempts"
+	// varMFALockoutMaxAttempts the maximum number of consecutive invalid codes of a user, across all logins, before the verification of their codes is locked
+	varMFALockoutMaxAttempts = "mfa.lockout.max.attempts"
+	// varMFALockoutDurationSeconds the time during which the codes of a user are not verified anymore after too many invalid codes
+	varMFALockoutDurationSeconds = "mfa.lockout.duration.seconds"
+	// varMFARecoveryCodesCount the number of recovery codes generated when a user enrolls
+	varMFARecoveryCodesCount = "mfa.recovery.codes.count"
+	// varMFARequiredScopes the comma-separated list of scopes which can only be granted with a multi-factor authenticated token
+	varMFARequiredScopes = "mfa.required.scopes"
+	// varWebAuthnRPID the WebAuthn relying party ID, i.e. the domain to which the WebAuthn credentials are scoped
+	varWebAuthnRPID = "webauthn.rp.id"
+	// varWebAuthnRPName the WebAuthn relying party name displayed by the authenticators
+	varWebAuthnRPName = "webauthn.rp.name"
+	// varWebAuthnOrigins the comma-separated list of origins of the pages which are allowed to run the WebAuthn ceremonies
+	varWebAuthnOrigins = "webauthn.origins"
+
+	//------------------------------------------------------------------------------------------------------------------
+	//
+	// Outbox
+	//
+	//------------------------------------------------------------------------------------------------------------------
+
+	// varOutboxEnabled true if the consumers which deliver the outbox events to the other services should be enabled
+	varOutboxEnabled = "outbox.enabled"
+	// varOutboxWorkerIntervalSeconds the interval at which the consumers check for new events when the outbox queues are empty
+	varOutboxWorkerIntervalSeconds = "outbox.interval.seconds"
+	// varOutboxBatchSize the maximum number of events dequeued at once by each of the outbox consumers
+	varOutboxBatchSize = "outbox.batch.size"
+	// varOutboxRetryDelaySeconds the delay before the first retry. The delay doubles after each failed attempt
+	varOutboxRetryDelaySeconds = "outbox.retry.delay.seconds"
+	// varOutboxDeprovisionMinIntervalMillis the minimum delay between the deletion of 2 users on the Che and Tenant
+	// services in each pod
+	varOutboxDeprovisionMinIntervalMillis = "outbox.deprovision.min.interval.millis"
+
+	//------------------------------------------------------------------------------------------------------------------
+	//
+	// User data export
+	//
+	//------------------------------------------------------------------------------------------------------------------
+
+	// varUserDataExportEnabled true if the worker which generates the pending user data exports should be enabled
+	varUserDataExportEnabled = "user.data.export.enabled"
+	// varUserDataExportWorkerIntervalSeconds the interval between 2 cycles of the user data export worker
+	varUserDataExportWorkerIntervalSeconds = "user.data.export.interval.seconds"
+	// varUserDataExportBatchSize the maximum number of exports to generate during a single cycle of the worker
+	varUserDataExportBatchSize = "user.data.export.batch.size"
+	// varUserDataExportSyncTokenLimit the maximum number of tokens of an account for its export to be generated
+	// during the request. The exports of larger accounts are generated asynchronously by the worker
+	varUserDataExportSyncTokenLimit = "user.data.export.sync.token.limit"
+	// varUserDataExportRetentionHours the number of hours during which an export is available once generated
+	varUserDataExportRetentionHours = "user.data.export.retention.hours"
+
+	//------------------------------------------------------------------------------------------------------------------
+	//
+	// User deletion
+	//
+	//------------------------------------------------------------------------------------------------------------------
+
+	// varUserDeletionEnabled true if the worker which deletes the accounts whose users requested the deletion should be enabled
+	varUserDeletionEnabled = "user.deletion.enabled"
+	// varUserDeletionWorkerIntervalSeconds the interval between 2 cycles of the user deletion worker
+	varUserDeletionWorkerIntervalSeconds = "user.deletion.interval.seconds"
+	// varUserDeletionGracePeriodDays the number of days during which the user can cancel the deletion of her account by logging in again
+	varUserDeletionGracePeriodDays = "user.deletion.grace.period.days"
+	// varUserDeletionRetentionDays the number of days during which a deactivated account is kept before it is purged
+	varUserDeletionRetentionDays = "user.deletion.retention.days"
+
+	//------------------------------------------------------------------------------------------------------------------
+	//
+	// User bans
+	//
+	//------------------------------------------------------------------------------------------------------------------
+
+	// varUserBanEnabled true if the worker which starts the scheduled bans and lifts the expired ones should be enabled
+	varUserBanEnabled = "user.ban.enabled"
+	// varUserBanSchedule the schedule of the user ban job, as a cron expression
+	varUserBanSchedule = "user.ban.schedule"
+	// varUserBanFetchLimit the maximum number of bans to start or to lift during a single cycle of the user ban worker
+	varUserBanFetchLimit = "user.ban.fetch.limit"
+
+	//------------------------------------------------------------------------------------------------------------------
+	//
+	// User bulk operations
+	//
+	//------------------------------------------------------------------------------------------------------------------
+
+	// varUserBulkOperationEnabled true if the consumer which applies the bulk operations to the users should be enabled
+	varUserBulkOperationEnabled = "user.bulk.operation.enabled"
+	// varUserBulkOperationPollIntervalSeconds the interval at which the consumer checks for new tasks when the queue is empty
+	varUserBulkOperationPollIntervalSeconds = "user.bulk.operation.poll.interval.seconds"
+	// varUserBulkOperationConcurrency the number of users processed in parallel in each pod
+	varUserBulkOperationConcurrency = "user.bulk.operation.concurrency"
+	// varUserBulkOperationMinIntervalMillis the minimum delay between the processing of 2 users in each pod, to limit
+	// the load on the Cluster service. The deletion of the users on the Che and Tenant services is throttled by the
+	// outbox consumers
+	varUserBulkOperationMinIntervalMillis = "user.bulk.operation.min.interval.millis"
+	// varUserBulkOperationMaxUsers the maximum number of users of a single bulk operation
+	varUserBulkOperationMaxUsers = "user.bulk.operation.max.users"
+
+	// varEmailVerificationCodeExpiryMinutes the number of minutes during which a code sent to verify an email address, or
+	// to confirm or cancel the change of an email address, can be used
+	varEmailVerificationCodeExpiryMinutes = "email.verification.code.expiry.minutes"
+	// varEmailVerificationRateLimit the maximum number of verification codes sent to a user during the rate limit period
+	varEmailVerificationRateLimit = "email.verification.rate.limit"
+	// varEmailVerificationRateLimitPeriodMinutes the period over which the verification codes sent to a user are counted
+	varEmailVerificationRateLimitPeriodMinutes = "email.verification.rate.limit.period.minutes"
+
+	// varUsernameChangeCooldownDays the period after a change of username during which the user cannot change it again,
+	// and the previous username is reserved to her
+	varUsernameChangeCooldownDays = "username.change.cooldown.days"
+
+	// varBlobStorage the kind of storage of the binary objects such as the avatars: `filesystem` or `s3`
+	varBlobStorage = "blob.storage"
+	// varBlobStorageDirectory the directory in which the binary objects are stored, with the `filesystem` storage. It
+	// must be shared by all the pods.
+	varBlobStorageDirectory = "blob.storage.directory"
+	// varBlobStorageS3Endpoint the URL of the S3-compatible object storage, with the `s3` storage
+	varBlobStorageS3Endpoint = "blob.storage.s3.endpoint"
+	// varBlobStorageS3Region the region of the S3-compatible object storage
+	varBlobStorageS3Region = "blob.storage.s3.region"
+	// varBlobStorageS3Bucket the bucket in which the binary objects are stored
+	varBlobStorageS3Bucket = "blob.storage.s3.bucket"
+	// varBlobStorageS3AccessKeyID the ID of the access key to the S3-compatible object storage
+	varBlobStorageS3AccessKeyID = "blob.storage.s3.access.key.id"
+	// varBlobStorageS3SecretAccessKey the secret of the access key to the S3-compatible object storage
+	varBlobStorageS3SecretAccessKey = "blob.storage.s3.secret.access.key"
+	// varAvatarMaxSizeBytes the maximum size of the images uploaded as avatars
+	varAvatarMaxSizeBytes = "avatar.max.size.bytes"
+	// varAvatarMaxDimension the maximum width and height of the images uploaded as avatars, in pixels
+	varAvatarMaxDimension = "avatar.max.dimension"
+	// varCacheControlAvatar the value of the "Cache-Control" HTTP response header when returning an avatar
+	varCacheControlAvatar = "cachecontrol.avatar"
+
+	// varSCIMProvisioningCluster the URL of the cluster to which the users provisioned with SCIM are linked, or an
+	// empty string if the provisioned users must not be linked to any cluster
+	varSCIMProvisioningCluster = "scim.provisioning.cluster"
+
+	//------------------------------------------------------------------------------------------------------------------
+	//
+	// Jobs
+	//
+	//------------------------------------------------------------------------------------------------------------------
+
+	// varJobPollIntervalSeconds the interval at which the job workers check if their job is due, i.e, the precision of the schedules
+	varJobPollIntervalSeconds = "job.poll.interval.seconds"
+	// varWorkerShutdownTimeoutSeconds the maximum time to wait for the workers to complete their current cycle and
+	// release their lock during the shutdown
+	varWorkerShutdownTimeoutSeconds = "worker.shutdown.timeout.seconds"
+
 	secondsInOneDay = 24 * 60 * 60
 )
 
@@ -235,6 +448,8 @@ type ServiceAccount struct {
 	Name    string   `mapstructure:"name"`
 	ID      string   `mapstructure:"id"`
 	Secrets []string `mapstructure:"secrets"`
+	// BackChannelLogoutURI the (optional) URI to which a logout token is sent when a user logs out
+	BackChannelLogoutURI string `mapstructure:"backchannel_logout_uri"`
 }
 
 // ConfigurationData encapsulates the Viper configuration object which stores the configuration data in-memory.
@@ -245,6 +460,9 @@ type ConfigurationData struct {
 	// Service Account Configuration is a map of service accounts where the key == the service account ID
 	sa map[string]ServiceAccount
 
+	// User deactivation policies, parsed from the main configuration
+	deactivationPolicies []account.DeactivationPolicy
+
 	defaultConfigurationError error
 
 	mux sync.RWMutex
@@ -348,6 +566,11 @@ func NewConfigurationData(mainConfigFile string, serviceAccountConfigFile string
 	if c.GetClusterCacheRefreshInterval() < 5*time.Second || c.GetClusterCacheRefreshInterval() > time.Hour {
 		c.appendDefaultConfigErrorMessage("Cluster cache refresh interval is less than five seconds or more than one hour")
 	}
+	c.checkExternalTokenEncryptionConfig()
+	c.deactivationPolicies, err = account.ParseDeactivationPolicies(c.v.GetString(varUserDeactivationPolicies))
+	if err != nil {
+		return nil, err
+	}
 	if c.defaultConfigurationError != nil {
 		log.WithFields(map[string]interface{}{
 			"default_configuration_error": c.defaultConfigurationError.Error(),
@@ -399,6 +622,44 @@ func (c *ConfigurationData) checkServiceAccountConfig() {
 	}
 }
 
+// checkExternalTokenEncryptionConfig verifies that all the external token encryption master keys can be parsed,
+// that the primary key is one of them and that it is not the default (dev mode) key
+func (c *ConfigurationData) checkExternalTokenEncryptionConfig() {
+	keys, err := parseMasterKeys(c.v.GetStringSlice(varExternalTokenEncryptionMasterKeys))
+	if err != nil {
+		c.appendDefaultConfigErrorMessage(err.Error())
+		return
+	}
+	primaryKeyID := c.GetExternalTokenEncryptionPrimaryKeyID()
+	if _, found := keys[primaryKeyID]; !found {
+		c.appendDefaultConfigErrorMessage(fmt.Sprintf("external token encryption primary key '%s' is not part of the master keys", primaryKeyID))
+	} else if primaryKeyID == defaultExternalTokenEncryptionPrimaryKeyID {
+		c.appendDefaultConfigErrorMessage("default external token encryption master key is used")
+	}
+}
+
+// parseMasterKeys parses the given `<key id>:<base64-encoded AES key>` entries
+func parseMasterKeys(entries []string) (map[string][]byte, error) {
+	keys := make(map[string][]byte, len(entries))
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf("invalid external token encryption master key entry (expected '<key id>:<base64-encoded key>')")
+		}
+		key, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid external token encryption master key '%s'", parts[0])
+		}
+		switch len(key) {
+		case 16, 24, 32:
+		default:
+			return nil, errors.Errorf("invalid length of external token encryption master key '%s': %d bytes", parts[0], len(key))
+		}
+		keys[parts[0]] = key
+	}
+	return keys, nil
+}
+
 func readFromJSONFile(configFilePath string, defaultConfigFilePath string, configFileName string) (*viper.Viper, *string, string, error) {
 	jsonViper := viper.New()
 	jsonViper.SetTypeByDefaultValue(true)
@@ -515,6 +776,7 @@ func (c *ConfigurationData) GetAuthServiceURL() string {
 // "rh-che : "secret"
 // "fabric8-gemini-server" : "secret"
 // "toolchain-operator" : "secret"
+// "scim-provisioning" : "secret"
 func (c *ConfigurationData) GetServiceAccounts() map[string]ServiceAccount {
 	return c.sa
 }
@@ -681,11 +943,99 @@ func (c *ConfigurationData) setConfigDefaults() {
 	c.v.SetDefault(varUserDeactivationNotificationWorkerIntervalSeconds, defaultUserDeactivationNotificationWorkerIntervalSeconds)
 	c.v.SetDefault(varPodName, defaultPodName)
 	c.v.SetDefault(varUserDeactivationWorkerRescheduleDelayHours, defaultUserDeactivationRescheduleDelayHours)
+	c.v.SetDefault(varUserDeactivationDryRun, defaultUserDeactivationDryRun)
+	c.v.SetDefault(varUserDeactivationPolicies, defaultUserDeactivationPolicies)
 	c.v.SetDefault(varAdminConsoleServiceURL, defaultAdminConsoleServiceURL)
 
 	// Che
 	c.v.SetDefault(varCheServiceURL, defaultCheServiceURL)
 
+	// External token encryption
+	c.v.SetDefault(varExternalTokenEncryptionMasterKeys, defaultExternalTokenEncryptionMasterKeys)
+	c.v.SetDefault(varExternalTokenEncryptionPrimaryKeyID, defaultExternalTokenEncryptionPrimaryKeyID)
+	c.v.SetDefault(varExternalTokenReencryptionEnabled, defaultExternalTokenReencryptionEnabled)
+	c.v.SetDefault(varExternalTokenReencryptionWorkerIntervalSeconds, defaultExternalTokenReencryptionWorkerIntervalSeconds)
+	c.v.SetDefault(varExternalTokenReencryptionBatchSize, defaultExternalTokenReencryptionBatchSize)
+
+	// Sessions
+	c.v.SetDefault(varMaxConcurrentSessions, defaultMaxConcurrentSessions)
+	c.v.SetDefault(varSessionTouchIntervalSeconds, defaultSessionTouchIntervalSeconds)
+
+	// Back-channel logout
+	c.v.SetDefault(varBackChannelLogoutEnabled, defaultBackChannelLogoutEnabled)
+	c.v.SetDefault(varBackChannelLogoutWorkerIntervalSeconds, defaultBackChannelLogoutWorkerIntervalSeconds)
+	c.v.SetDefault(varBackChannelLogoutBatchSize, defaultBackChannelLogoutBatchSize)
+	c.v.SetDefault(varBackChannelLogoutMaxAttempts, defaultBackChannelLogoutMaxAttempts)
+	c.v.SetDefault(varBackChannelLogoutRetryDelaySeconds, defaultBackChannelLogoutRetryDelaySeconds)
+	c.v.SetDefault(varBackChannelLogoutRequestTimeoutSeconds, defaultBackChannelLogoutRequestTimeoutSeconds)
+
+	// Multi-factor authentication
+	c.v.SetDefault(varMFATOTPIssuer, defaultMFATOTPIssuer)
+	c.v.SetDefault(varMFAVerificationURL, defaultMFAVerificationURL)
+	c.v.SetDefault(varMFAChallengeExpiresInSeconds, defaultMFAChallengeExpiresInSeconds)
+	c.v.SetDefault(varMFAChallengeMaxAttempts, defaultMFAChallengeMaxAttempts)
+	c.v.SetDefault(varMFALockoutMaxAttempts, defaultMFALockoutMaxAttempts)
+	c.v.SetDefault(varMFALockoutDurationSeconds, defaultMFALockoutDurationSeconds)
+	c.v.SetDefault(varMFARecoveryCodesCount, defaultMFARecoveryCodesCount)
+	c.v.SetDefault(varMFARequiredScopes, "")
+	c.v.SetDefault(varWebAuthnRPID, defaultWebAuthnRPID)
+	c.v.SetDefault(varWebAuthnRPName, defaultWebAuthnRPName)
+	c.v.SetDefault(varWebAuthnOrigins, defaultWebAuthnOrigins)
+
+	// Outbox
+	c.v.SetDefault(varOutboxEnabled, defaultOutboxEnabled)
+	c.v.SetDefault(varOutboxWorkerIntervalSeconds, defaultOutboxWorkerIntervalSeconds)
+	c.v.SetDefault(varOutboxBatchSize, defaultOutboxBatchSize)
+	c.v.SetDefault(varOutboxRetryDelaySeconds, defaultOutboxRetryDelaySeconds)
+	c.v.SetDefault(varOutboxDeprovisionMinIntervalMillis, defaultOutboxDeprovisionMinIntervalMillis)
+
+	// User data export
+	c.v.SetDefault(varUserDataExportEnabled, defaultUserDataExportEnabled)
+	c.v.SetDefault(varUserDataExportWorkerIntervalSeconds, defaultUserDataExportWorkerIntervalSeconds)
+	c.v.SetDefault(varUserDataExportBatchSize, defaultUserDataExportBatchSize)
+	c.v.SetDefault(varUserDataExportSyncTokenLimit, defaultUserDataExportSyncTokenLimit)
+	c.v.SetDefault(varUserDataExportRetentionHours, defaultUserDataExportRetentionHours)
+
+	// User deletion
+	c.v.SetDefault(varUserDeletionEnabled, defaultUserDeletionEnabled)
+	c.v.SetDefault(varUserDeletionWorkerIntervalSeconds, defaultUserDeletionWorkerIntervalSeconds)
+	c.v.SetDefault(varUserDeletionGracePeriodDays, defaultUserDeletionGracePeriodDays)
+	c.v.SetDefault(varUserDeletionRetentionDays, defaultUserDeletionRetentionDays)
+
+	// User bans
+	c.v.SetDefault(varUserBanEnabled, defaultUserBanEnabled)
+	c.v.SetDefault(varUserBanSchedule, defaultUserBanSchedule)
+	c.v.SetDefault(varUserBanFetchLimit, defaultUserBanFetchLimit)
+
+	// User bulk operations
+	c.v.SetDefault(varUserBulkOperationEnabled, defaultUserBulkOperationEnabled)
+	c.v.SetDefault(varUserBulkOperationPollIntervalSeconds, defaultUserBulkOperationPollIntervalSeconds)
+	c.v.SetDefault(varUserBulkOperationConcurrency, defaultUserBulkOperationConcurrency)
+	c.v.SetDefault(varUserBulkOperationMinIntervalMillis, defaultUserBulkOperationMinIntervalMillis)
+	c.v.SetDefault(varUserBulkOperationMaxUsers, defaultUserBulkOperationMaxUsers)
+
+	// Email verification
+	c.v.SetDefault(varEmailVerificationCodeExpiryMinutes, defaultEmailVerificationCodeExpiryMinutes)
+	c.v.SetDefault(varEmailVerificationRateLimit, defaultEmailVerificationRateLimit)
+	c.v.SetDefault(varEmailVerificationRateLimitPeriodMinutes, defaultEmailVerificationRateLimitPeriodMinutes)
+
+	// Username changes
+	c.v.SetDefault(varUsernameChangeCooldownDays, defaultUsernameChangeCooldownDays)
+
+	// Avatars
+	c.v.SetDefault(varBlobStorage, defaultBlobStorage)
+	c.v.SetDefault(varBlobStorageDirectory, filepath.Join(os.TempDir(), "fabric8-auth", "blobs"))
+	c.v.SetDefault(varAvatarMaxSizeBytes, defaultAvatarMaxSizeBytes)
+	c.v.SetDefault(varAvatarMaxDimension, defaultAvatarMaxDimension)
+	c.v.SetDefault(varCacheControlAvatar, defaultCacheControlAvatar)
+
+	// SCIM provisioning
+	c.v.SetDefault(varSCIMProvisioningCluster, defaultSCIMProvisioningCluster)
+
+	// Jobs
+	c.v.SetDefault(varJobPollIntervalSeconds, defaultJobPollIntervalSeconds)
+	c.v.SetDefault(varWorkerShutdownTimeoutSeconds, defaultWorkerShutdownTimeoutSeconds)
+
 }
 
 // GetEmailVerifiedRedirectURL returns the url where the user would be redirected to after clicking on email
@@ -1127,12 +1477,12 @@ func (c *ConfigurationData) GetUserDeactivationInactivityPeriod() time.Duration
 	return time.Duration(c.v.GetInt(varUserDeactivationInactivityPeriodDays)) * 24 * time.Hour
 }
 
-// GetUserDeactivationWorkerInterval returns the interval between 2 cycles of the user deactivation worker.
+// GetUserDeactivationWorkerInterval returns the interval between 2 runs of the user deactivation job.
 func (c *ConfigurationData) GetUserDeactivationWorkerInterval() time.Duration {
 	return time.Duration(c.v.GetInt(varUserDeactivationWorkerIntervalSeconds)) * time.Second
 }
 
-// GetUserDeactivationNotificationWorkerInterval returns the interval between 2 cycles of the user deactivation notification worker.
+// GetUserDeactivationNotificationWorkerInterval returns the interval between 2 runs of the user deactivation notification job.
 func (c *ConfigurationData) GetUserDeactivationNotificationWorkerInterval() time.Duration {
 	return time.Duration(c.v.GetInt(varUserDeactivationNotificationWorkerIntervalSeconds)) * time.Second
 }
@@ -1147,7 +1497,368 @@ func (c *ConfigurationData) GetUserDeactivationWhiteList() []string {
 	return c.v.GetStringSlice(varUserDeactivationWhiteList)
 }
 
+// GetUserDeactivationDryRun returns true if the user deactivation and notification workers should only report the users
+// they would notify or deactivate
+func (c *ConfigurationData) GetUserDeactivationDryRun() bool {
+	return c.v.GetBool(varUserDeactivationDryRun)
+}
+
+// GetUserDeactivationPolicies returns the user deactivation policies, in their order of evaluation
+func (c *ConfigurationData) GetUserDeactivationPolicies() []account.DeactivationPolicy {
+	return c.deactivationPolicies
+}
+
 // GetAdminConsoleServiceURL the URL to access to the Admin Console service
 func (c *ConfigurationData) GetAdminConsoleServiceURL() string {
 	return c.v.GetString(varAdminConsoleServiceURL)
 }
+
+// GetExternalTokenEncryptionMasterKeys returns the master keys used to wrap the data keys of the external tokens, indexed by their ID
+func (c *ConfigurationData) GetExternalTokenEncryptionMasterKeys() map[string][]byte {
+	// invalid entries are reported in the default configuration error when the configuration is loaded
+	keys, _ := parseMasterKeys(c.v.GetStringSlice(varExternalTokenEncryptionMasterKeys))
+	return keys
+}
+
+// GetExternalTokenEncryptionPrimaryKeyID returns the ID of the master key to use when encrypting new external tokens
+func (c *ConfigurationData) GetExternalTokenEncryptionPrimaryKeyID() string {
+	return c.v.GetString(varExternalTokenEncryptionPrimaryKeyID)
+}
+
+// GetExternalTokenReencryptionEnabled returns true if the external token re-encryption worker should be enabled
+func (c *ConfigurationData) GetExternalTokenReencryptionEnabled() bool {
+	return c.v.GetBool(varExternalTokenReencryptionEnabled)
+}
+
+// GetExternalTokenReencryptionWorkerInterval returns the interval between 2 runs of the external token re-encryption job
+func (c *ConfigurationData) GetExternalTokenReencryptionWorkerInterval() time.Duration {
+	return time.Duration(c.v.GetInt(varExternalTokenReencryptionWorkerIntervalSeconds)) * time.Second
+}
+
+// GetExternalTokenReencryptionBatchSize returns the maximum number of external tokens to re-encrypt in a single transaction
+func (c *ConfigurationData) GetExternalTokenReencryptionBatchSize() int {
+	return c.v.GetInt(varExternalTokenReencryptionBatchSize)
+}
+
+// GetMaxConcurrentSessions returns the maximum number of concurrent sessions per user. When a user reaches the limit,
+// their least recently used session is terminated at the next login. 0 means unlimited.
+func (c *ConfigurationData) GetMaxConcurrentSessions() int {
+	return c.v.GetInt(varMaxConcurrentSessions)
+}
+
+// GetSessionTouchInterval returns the minimum interval between 2 updates of the last used timestamp of a session
+// when its tokens are validated, so that a busy client does not write the session row on every request
+func (c *ConfigurationData) GetSessionTouchInterval() time.Duration {
+	return time.Duration(c.v.GetInt(varSessionTouchIntervalSeconds)) * time.Second
+}
+
+// GetBackChannelLogoutURIs returns the back-channel logout URIs registered by the service accounts, indexed by the service account ID
+func (c *ConfigurationData) GetBackChannelLogoutURIs() map[string]string {
+	uris := map[string]string{}
+	for id, sa := range c.sa {
+		if sa.BackChannelLogoutURI != "" {
+			uris[id] = sa.BackChannelLogoutURI
+		}
+	}
+	return uris
+}
+
+// GetBackChannelLogoutEnabled returns true if the back-channel logout worker should be enabled
+func (c *ConfigurationData) GetBackChannelLogoutEnabled() bool {
+	return c.v.GetBool(varBackChannelLogoutEnabled)
+}
+
+// GetBackChannelLogoutWorkerInterval returns the interval between 2 runs of the back-channel logout job
+func (c *ConfigurationData) GetBackChannelLogoutWorkerInterval() time.Duration {
+	return time.Duration(c.v.GetInt(varBackChannelLogoutWorkerIntervalSeconds)) * time.Second
+}
+
+// GetBackChannelLogoutBatchSize returns the maximum number of notifications to send during a single cycle of the back-channel logout worker
+func (c *ConfigurationData) GetBackChannelLogoutBatchSize() int {
+	return c.v.GetInt(varBackChannelLogoutBatchSize)
+}
+
+// GetBackChannelLogoutMaxAttempts returns the maximum number of attempts to notify a relying party before giving up
+func (c *ConfigurationData) GetBackChannelLogoutMaxAttempts() int {
+	return c.v.GetInt(varBackChannelLogoutMaxAttempts)
+}
+
+// GetBackChannelLogoutRetryDelay returns the delay before the first retry to notify a relying party
+func (c *ConfigurationData) GetBackChannelLogoutRetryDelay() time.Duration {
+	return time.Duration(c.v.GetInt(varBackChannelLogoutRetryDelaySeconds)) * time.Second
+}
+
+// GetBackChannelLogoutRequestTimeout returns the timeout of the requests sent to the back-channel logout URIs
+func (c *ConfigurationData) GetBackChannelLogoutRequestTimeout() time.Duration {
+	return time.Duration(c.v.GetInt(varBackChannelLogoutRequestTimeoutSeconds)) * time.Second
+}
+
+// GetMFATOTPIssuer returns the issuer displayed by the authenticator applications
+func (c *ConfigurationData) GetMFATOTPIssuer() string {
+	return c.v.GetString(varMFATOTPIssuer)
+}
+
+// GetMFAVerificationURL returns the URL of the page where the users enter their TOTP or recovery code during login.
+// The ID of the login challenge is passed in the `challenge` query parameter.
+func (c *ConfigurationData) GetMFAVerificationURL() string {
+	return c.v.GetString(varMFAVerificationURL)
+}
+
+// GetMFAChallengeExpiresIn returns the time given to the users to verify their second factor during login
+func (c *ConfigurationData) GetMFAChallengeExpiresIn() time.Duration {
+	return time.Duration(c.v.GetInt(varMFAChallengeExpiresInSeconds)) * time.Second
+}
+
+// GetMFAChallengeMaxAttempts returns the maximum number of invalid codes before a login is aborted
+func (c *ConfigurationData) GetMFAChallengeMaxAttempts() int {
+	return c.v.GetInt(varMFAChallengeMaxAttempts)
+}
+
+// GetMFALockoutMaxAttempts returns the maximum number of consecutive invalid codes of a user, across all logins,
+// before the verification of their codes is locked
+func (c *ConfigurationData) GetMFALockoutMaxAttempts() int {
+	return c.v.GetInt(varMFALockoutMaxAttempts)
+}
+
+// GetMFALockoutDuration returns the time during which the codes of a user are not verified anymore after too many invalid codes
+func (c *ConfigurationData) GetMFALockoutDuration() time.Duration {
+	return time.Duration(c.v.GetInt(varMFALockoutDurationSeconds)) * time.Second
+}
+
+// GetMFARecoveryCodesCount returns the number of recovery codes generated when a user enrolls
+func (c *ConfigurationData) GetMFARecoveryCodesCount() int {
+	return c.v.GetInt(varMFARecoveryCodesCount)
+}
+
+// GetMFARequiredScopes returns the scopes which can only be granted with a multi-factor authenticated token
+func (c *ConfigurationData) GetMFARequiredScopes() []string {
+	scopes := []string{}
+	for _, scope := range strings.Split(c.v.GetString(varMFARequiredScopes), ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// GetWebAuthnRPID returns the WebAuthn relying party ID, i.e. the domain to which the WebAuthn credentials are scoped.
+// It must be equal to, or a registrable suffix of, the domain of the WebAuthn origins.
+func (c *ConfigurationData) GetWebAuthnRPID() string {
+	return c.v.GetString(varWebAuthnRPID)
+}
+
+// GetWebAuthnRPName returns the WebAuthn relying party name displayed by the authenticators
+func (c *ConfigurationData) GetWebAuthnRPName() string {
+	return c.v.GetString(varWebAuthnRPName)
+}
+
+// GetWebAuthnOrigins returns the origins of the pages which are allowed to run the WebAuthn ceremonies
+func (c *ConfigurationData) GetWebAuthnOrigins() []string {
+	origins := []string{}
+	for _, origin := range strings.Split(c.v.GetString(varWebAuthnOrigins), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// GetOutboxEnabled returns true if the outbox consumers should be enabled
+func (c *ConfigurationData) GetOutboxEnabled() bool {
+	return c.v.GetBool(varOutboxEnabled)
+}
+
+// GetOutboxWorkerInterval returns the interval at which the outbox consumers check for new events when the queues are empty
+func (c *ConfigurationData) GetOutboxWorkerInterval() time.Duration {
+	return time.Duration(c.v.GetInt(varOutboxWorkerIntervalSeconds)) * time.Second
+}
+
+// GetOutboxBatchSize returns the maximum number of events dequeued at once by each of the outbox consumers
+func (c *ConfigurationData) GetOutboxBatchSize() int {
+	return c.v.GetInt(varOutboxBatchSize)
+}
+
+// GetOutboxRetryDelay returns the delay before the first retry to deliver an event
+func (c *ConfigurationData) GetOutboxRetryDelay() time.Duration {
+	return time.Duration(c.v.GetInt(varOutboxRetryDelaySeconds)) * time.Second
+}
+
+// GetOutboxDeprovisionMinInterval returns the minimum delay between the deletion of 2 users on the Che and Tenant services
+func (c *ConfigurationData) GetOutboxDeprovisionMinInterval() time.Duration {
+	return time.Duration(c.v.GetInt(varOutboxDeprovisionMinIntervalMillis)) * time.Millisecond
+}
+
+// GetUserDataExportEnabled returns true if the user data export worker should be enabled
+func (c *ConfigurationData) GetUserDataExportEnabled() bool {
+	return c.v.GetBool(varUserDataExportEnabled)
+}
+
+// GetUserDataExportWorkerInterval returns the interval between 2 runs of the user data export job
+func (c *ConfigurationData) GetUserDataExportWorkerInterval() time.Duration {
+	return time.Duration(c.v.GetInt(varUserDataExportWorkerIntervalSeconds)) * time.Second
+}
+
+// GetUserDataExportBatchSize returns the maximum number of exports to generate during a single cycle of the worker
+func (c *ConfigurationData) GetUserDataExportBatchSize() int {
+	return c.v.GetInt(varUserDataExportBatchSize)
+}
+
+// GetUserDataExportSyncTokenLimit returns the maximum number of tokens of an account for its export to be generated
+// during the request
+func (c *ConfigurationData) GetUserDataExportSyncTokenLimit() int {
+	return c.v.GetInt(varUserDataExportSyncTokenLimit)
+}
+
+// GetUserDataExportRetention returns the duration during which an export is available once generated
+func (c *ConfigurationData) GetUserDataExportRetention() time.Duration {
+	return time.Duration(c.v.GetInt(varUserDataExportRetentionHours)) * time.Hour
+}
+
+// GetUserDeletionEnabled returns true if the user deletion worker should be enabled
+func (c *ConfigurationData) GetUserDeletionEnabled() bool {
+	return c.v.GetBool(varUserDeletionEnabled)
+}
+
+// GetUserDeletionWorkerInterval returns the interval between 2 runs of the user deletion job
+func (c *ConfigurationData) GetUserDeletionWorkerInterval() time.Duration {
+	return time.Duration(c.v.GetInt(varUserDeletionWorkerIntervalSeconds)) * time.Second
+}
+
+// GetUserDeletionGracePeriod returns the duration during which the user can cancel the deletion of her account
+func (c *ConfigurationData) GetUserDeletionGracePeriod() time.Duration {
+	return time.Duration(c.v.GetInt(varUserDeletionGracePeriodDays)) * 24 * time.Hour
+}
+
+// GetUserDeletionRetentionPeriod returns the duration during which a deactivated account is kept before it is purged
+func (c *ConfigurationData) GetUserDeletionRetentionPeriod() time.Duration {
+	return time.Duration(c.v.GetInt(varUserDeletionRetentionDays)) * 24 * time.Hour
+}
+
+// GetUserBanEnabled returns true if the user ban worker should be enabled
+func (c *ConfigurationData) GetUserBanEnabled() bool {
+	return c.v.GetBool(varUserBanEnabled)
+}
+
+// GetUserBanSchedule returns the schedule of the user ban job
+func (c *ConfigurationData) GetUserBanSchedule() string {
+	return c.v.GetString(varUserBanSchedule)
+}
+
+// GetUserBanFetchLimit returns the maximum number of bans to start or to lift during a single cycle of the user ban worker
+func (c *ConfigurationData) GetUserBanFetchLimit() int {
+	return c.v.GetInt(varUserBanFetchLimit)
+}
+
+// GetUserBulkOperationEnabled returns true if the consumer which applies the bulk operations to the users should be enabled
+func (c *ConfigurationData) GetUserBulkOperationEnabled() bool {
+	return c.v.GetBool(varUserBulkOperationEnabled)
+}
+
+// GetUserBulkOperationPollInterval returns the interval at which the consumer checks for new tasks when the queue is empty
+func (c *ConfigurationData) GetUserBulkOperationPollInterval() time.Duration {
+	return time.Duration(c.v.GetInt(varUserBulkOperationPollIntervalSeconds)) * time.Second
+}
+
+// GetUserBulkOperationConcurrency returns the number of users processed in parallel in each pod
+func (c *ConfigurationData) GetUserBulkOperationConcurrency() int {
+	return c.v.GetInt(varUserBulkOperationConcurrency)
+}
+
+// GetUserBulkOperationMinInterval returns the minimum delay between the processing of 2 users in each pod
+func (c *ConfigurationData) GetUserBulkOperationMinInterval() time.Duration {
+	return time.Duration(c.v.GetInt(varUserBulkOperationMinIntervalMillis)) * time.Millisecond
+}
+
+// GetUserBulkOperationMaxUsers returns the maximum number of users of a single bulk operation
+func (c *ConfigurationData) GetUserBulkOperationMaxUsers() int {
+	return c.v.GetInt(varUserBulkOperationMaxUsers)
+}
+
+// GetEmailVerificationCodeExpiry returns the duration during which a code sent to verify an email address, or to
+// confirm or cancel the change of an email address, can be used
+func (c *ConfigurationData) GetEmailVerificationCodeExpiry() time.Duration {
+	return time.Duration(c.v.GetInt(varEmailVerificationCodeExpiryMinutes)) * time.Minute
+}
+
+// GetEmailVerificationRateLimit returns the maximum number of verification codes sent to a user during the rate limit period
+func (c *ConfigurationData) GetEmailVerificationRateLimit() int {
+	return c.v.GetInt(varEmailVerificationRateLimit)
+}
+
+// GetEmailVerificationRateLimitPeriod returns the period over which the verification codes sent to a user are counted
+func (c *ConfigurationData) GetEmailVerificationRateLimitPeriod() time.Duration {
+	return time.Duration(c.v.GetInt(varEmailVerificationRateLimitPeriodMinutes)) * time.Minute
+}
+
+// GetUsernameChangeCooldown returns the period after a change of username during which the user cannot change it
+// again, and the previous username cannot be taken by another user
+func (c *ConfigurationData) GetUsernameChangeCooldown() time.Duration {
+	return time.Duration(c.v.GetInt(varUsernameChangeCooldownDays)) * 24 * time.Hour
+}
+
+// GetBlobStorage returns the kind of storage of the binary objects such as the avatars: `filesystem` or `s3`
+func (c *ConfigurationData) GetBlobStorage() string {
+	return c.v.GetString(varBlobStorage)
+}
+
+// GetBlobStorageDirectory returns the directory in which the binary objects are stored, with the `filesystem` storage
+func (c *ConfigurationData) GetBlobStorageDirectory() string {
+	return c.v.GetString(varBlobStorageDirectory)
+}
+
+// GetBlobStorageS3Endpoint returns the URL of the S3-compatible object storage, with the `s3` storage
+func (c *ConfigurationData) GetBlobStorageS3Endpoint() string {
+	return c.v.GetString(varBlobStorageS3Endpoint)
+}
+
+// GetBlobStorageS3Region returns the region of the S3-compatible object storage
+func (c *ConfigurationData) GetBlobStorageS3Region() string {
+	return c.v.GetString(varBlobStorageS3Region)
+}
+
+// GetBlobStorageS3Bucket returns the bucket in which the binary objects are stored
+func (c *ConfigurationData) GetBlobStorageS3Bucket() string {
+	return c.v.GetString(varBlobStorageS3Bucket)
+}
+
+// GetBlobStorageS3AccessKeyID returns the ID of the access key to the S3-compatible object storage
+func (c *ConfigurationData) GetBlobStorageS3AccessKeyID() string {
+	return c.v.GetString(varBlobStorageS3AccessKeyID)
+}
+
+// GetBlobStorageS3SecretAccessKey returns the secret of the access key to the S3-compatible object storage
+func (c *ConfigurationData) GetBlobStorageS3SecretAccessKey() string {
+	return c.v.GetString(varBlobStorageS3SecretAccessKey)
+}
+
+// GetAvatarMaxSize returns the maximum size of the images uploaded as avatars, in bytes
+func (c *ConfigurationData) GetAvatarMaxSize() int64 {
+	return c.v.GetInt64(varAvatarMaxSizeBytes)
+}
+
+// GetAvatarMaxDimension returns the maximum width and height of the images uploaded as avatars, in pixels
+func (c *ConfigurationData) GetAvatarMaxDimension() int {
+	return c.v.GetInt(varAvatarMaxDimension)
+}
+
+// GetCacheControlAvatar returns the value to set in the "Cache-Control" HTTP response header when returning an avatar.
+// The avatars never change, since a new upload has a new URL.
+func (c *ConfigurationData) GetCacheControlAvatar() string {
+	return c.v.GetString(varCacheControlAvatar)
+}
+
+// GetSCIMProvisioningCluster returns the URL of the cluster to which the users provisioned with SCIM are linked,
+// or an empty string if they must not be linked to any cluster
+func (c *ConfigurationData) GetSCIMProvisioningCluster() string {
+	return c.v.GetString(varSCIMProvisioningCluster)
+}
+
+// GetJobPollInterval returns the interval at which the job workers check if their job is due
+func (c *ConfigurationData) GetJobPollInterval() time.Duration {
+	return time.Duration(c.v.GetInt(varJobPollIntervalSeconds)) * time.Second
+}
+
+// GetWorkerShutdownTimeout returns the maximum time to wait for the workers to stop during the shutdown
+func (c *ConfigurationData) GetWorkerShutdownTimeout() time.Duration {
+	return time.Duration(c.v.GetInt(varWorkerShutdownTimeoutSeconds)) * time.Second
+}
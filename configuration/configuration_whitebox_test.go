@@ -130,6 +130,28 @@ func TestDefaultRedirectURLs(t *testing.T) {
 	assert.False(t, validateRedirectURL(t, "https://localhost.domain/api"))
 }
 
+func TestInvalidExternalTokenEncryptionKeysAreReported(t *testing.T) {
+	resource.Require(t, resource.UnitTest)
+
+	key := "AUTH_EXTERNALTOKEN_ENCRYPTION_MASTERKEYS"
+	realEnvValue := os.Getenv(key)
+
+	defer func() {
+		if realEnvValue != "" {
+			os.Setenv(key, realEnvValue)
+		} else {
+			os.Unsetenv(key)
+		}
+		resetConfiguration()
+	}()
+	os.Setenv(key, "invalid")
+	c, err := GetConfigurationData()
+	require.NoError(t, err)
+	require.Error(t, c.DefaultConfigurationError())
+	assert.Contains(t, c.DefaultConfigurationError().Error(), "invalid external token encryption master key entry")
+	assert.Empty(t, c.GetExternalTokenEncryptionMasterKeys())
+}
+
 func validateRedirectURL(t *testing.T, redirect string) bool {
 	matched, err := regexp.MatchString(DefaultValidRedirectURLs, redirect)
 	require.Nil(t, err)
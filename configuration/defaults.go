@@ -133,10 +133,127 @@ vwIDAQAB
 	defaultPodName = "unknown"
 	// defaultUserDeactivationRescheduleDelayDays default of 1 day to re-attempt a failed deactivation attempt
 	defaultUserDeactivationRescheduleDelayHours = 24 // 24 hours
+	// defaultUserDeactivationDryRun the user deactivation and notification workers notify and deactivate users by default
+	defaultUserDeactivationDryRun = false
+	// defaultUserDeactivationPolicies no user deactivation policy is defined by default
+	defaultUserDeactivationPolicies = ""
 
 	// defaultCheServiceURL the default URL to the Che service
 	defaultCheServiceURL = "http://rhche-host:8080"
 
 	// defaultAdminConsoleServiceURL the default URL to the Admin Console service
 	defaultAdminConsoleServiceURL = "http://admin-console"
+	// defaultExternalTokenEncryptionPrimaryKeyID the ID of the default (dev mode) master key for external tokens encryption
+	defaultExternalTokenEncryptionPrimaryKeyID = "dev-key"
+	// defaultExternalTokenEncryptionMasterKeys the default (dev mode) master key for external tokens encryption
+	defaultExternalTokenEncryptionMasterKeys = defaultExternalTokenEncryptionPrimaryKeyID + ":ZGV2LW1vZGUtbWFzdGVyLWtleS1ub3QtZm9yLXByb2Q="
+	defaultExternalTokenReencryptionEnabled  = true
+	// defaultExternalTokenReencryptionWorkerIntervalSeconds the default interval between 2 cycles of the external token re-encryption worker
+	defaultExternalTokenReencryptionWorkerIntervalSeconds = 60 * 60 // 1 hour
+	// defaultExternalTokenReencryptionBatchSize the default number of external tokens to re-encrypt in a single transaction
+	defaultExternalTokenReencryptionBatchSize = 100
+	// defaultMaxConcurrentSessions the number of concurrent sessions per user is unlimited by default
+	defaultMaxConcurrentSessions = 0
+	// defaultSessionTouchIntervalSeconds the default minimum interval between 2 updates of the last used timestamp of a session
+	defaultSessionTouchIntervalSeconds = 60
+	// defaultBackChannelLogoutEnabled the back-channel logout worker is enabled by default
+	defaultBackChannelLogoutEnabled = true
+	// defaultBackChannelLogoutWorkerIntervalSeconds the default interval between 2 cycles of the back-channel logout worker
+	defaultBackChannelLogoutWorkerIntervalSeconds = 30
+	// defaultBackChannelLogoutBatchSize the default maximum number of notifications to send during a single cycle of the back-channel logout worker
+	defaultBackChannelLogoutBatchSize = 100
+	// defaultBackChannelLogoutMaxAttempts the default maximum number of attempts to notify a relying party
+	defaultBackChannelLogoutMaxAttempts = 10
+	// defaultBackChannelLogoutRetryDelaySeconds the default delay before the first retry to notify a relying party
+	defaultBackChannelLogoutRetryDelaySeconds = 30
+	// defaultBackChannelLogoutRequestTimeoutSeconds the default timeout of the requests sent to the back-channel logout URIs
+	defaultBackChannelLogoutRequestTimeoutSeconds = 10
+	// defaultMFATOTPIssuer the default issuer displayed by the authenticator applications
+	defaultMFATOTPIssuer = "OpenShift.io"
+	// defaultMFAVerificationURL the default URL of the page where the users enter their TOTP or recovery code
+	defaultMFAVerificationURL = "https://prod-preview.openshift.io/_mfa"
+	// defaultMFAChallengeExpiresInSeconds the default time given to the users to verify their second factor
+	defaultMFAChallengeExpiresInSeconds = 5 * 60
+	// defaultMFAChallengeMaxAttempts the default maximum number of invalid codes before a login is aborted
+	defaultMFAChallengeMaxAttempts = 5
+	// defaultMFALockoutMaxAttempts the default maximum number of consecutive invalid codes of a user before their codes are locked
+	defaultMFALockoutMaxAttempts = 10
+	// defaultMFALockoutDurationSeconds the default time during which the codes of a user are locked after too many invalid codes
+	defaultMFALockoutDurationSeconds = 15 * 60
+	// defaultMFARecoveryCodesCount the default number of recovery codes generated when a user enrolls
+	defaultMFARecoveryCodesCount = 10
+	// defaultWebAuthnRPID the default WebAuthn relying party ID
+	defaultWebAuthnRPID = "openshift.io"
+	// defaultWebAuthnRPName the default WebAuthn relying party name displayed by the authenticators
+	defaultWebAuthnRPName = "OpenShift.io"
+	// defaultWebAuthnOrigins the default origins of the pages which are allowed to run the WebAuthn ceremonies
+	defaultWebAuthnOrigins = "https://prod-preview.openshift.io"
+	// defaultOutboxEnabled the outbox consumers are enabled by default
+	defaultOutboxEnabled = true
+	// defaultOutboxWorkerIntervalSeconds the default interval at which the outbox consumers check for new events
+	defaultOutboxWorkerIntervalSeconds = 30
+	// defaultOutboxBatchSize the default maximum number of events dequeued at once by each of the outbox consumers
+	defaultOutboxBatchSize = 100
+	// defaultOutboxRetryDelaySeconds the default delay before the first retry to deliver an event
+	defaultOutboxRetryDelaySeconds = 60
+	// defaultOutboxDeprovisionMinIntervalMillis the default minimum delay between the deletion of 2 users on the Che and
+	// Tenant services
+	defaultOutboxDeprovisionMinIntervalMillis = 500
+	// defaultUserDataExportEnabled the user data export worker is enabled by default
+	defaultUserDataExportEnabled = true
+	// defaultUserDataExportWorkerIntervalSeconds the default interval between 2 cycles of the user data export worker
+	defaultUserDataExportWorkerIntervalSeconds = 60
+	// defaultUserDataExportBatchSize the default maximum number of exports to generate during a single cycle of the worker
+	defaultUserDataExportBatchSize = 10
+	// defaultUserDataExportSyncTokenLimit the default maximum number of tokens of an account for its export to be generated during the request
+	defaultUserDataExportSyncTokenLimit = 500
+	// defaultUserDataExportRetentionHours the default number of hours during which an export is available
+	defaultUserDataExportRetentionHours = 24
+	// defaultUserDeletionEnabled the user deletion worker is enabled by default
+	defaultUserDeletionEnabled = true
+	// defaultUserDeletionWorkerIntervalSeconds the default interval between 2 cycles of the user deletion worker
+	defaultUserDeletionWorkerIntervalSeconds = 60 * 60 // 1 hour
+	// defaultUserDeletionGracePeriodDays the default number of days during which the user can cancel the deletion of her account
+	defaultUserDeletionGracePeriodDays = 14
+	// defaultUserDeletionRetentionDays the default number of days during which a deactivated account is kept before it is purged
+	defaultUserDeletionRetentionDays = 30
+	// defaultUserBanEnabled the user ban worker is enabled by default
+	defaultUserBanEnabled = true
+	// defaultUserBanSchedule the default schedule of the user ban job
+	defaultUserBanSchedule = "*/5 * * * *" // every 5 minutes
+	// defaultUserBanFetchLimit the default maximum number of bans to start or to lift during a single cycle
+	defaultUserBanFetchLimit = 100
+	// defaultUserBulkOperationEnabled the user bulk operation consumer is enabled by default
+	defaultUserBulkOperationEnabled = true
+	// defaultUserBulkOperationPollIntervalSeconds the default interval at which the consumer checks for new tasks
+	defaultUserBulkOperationPollIntervalSeconds = 10
+	// defaultUserBulkOperationConcurrency the default number of users processed in parallel in each pod
+	defaultUserBulkOperationConcurrency = 2
+	// defaultUserBulkOperationMinIntervalMillis the default minimum delay between the processing of 2 users in each pod
+	defaultUserBulkOperationMinIntervalMillis = 500
+	// defaultUserBulkOperationMaxUsers the default maximum number of users of a single bulk operation
+	defaultUserBulkOperationMaxUsers = 1000
+	// defaultEmailVerificationCodeExpiryMinutes the verification codes can be used during 24 hours by default
+	defaultEmailVerificationCodeExpiryMinutes = 24 * 60
+	// defaultEmailVerificationRateLimit the default maximum number of verification codes sent to a user during the period
+	defaultEmailVerificationRateLimit = 5
+	// defaultEmailVerificationRateLimitPeriodMinutes the verification codes sent to a user are counted over an hour by default
+	defaultEmailVerificationRateLimitPeriodMinutes = 60
+	// defaultUsernameChangeCooldownDays a user can change her username once a month by default
+	defaultUsernameChangeCooldownDays = 30
+	// defaultBlobStorage the binary objects are stored on the filesystem by default
+	defaultBlobStorage = "filesystem"
+	// defaultAvatarMaxSizeBytes the default maximum size of the images uploaded as avatars
+	defaultAvatarMaxSizeBytes = 2 * 1024 * 1024
+	// defaultAvatarMaxDimension the default maximum width and height of the images uploaded as avatars
+	defaultAvatarMaxDimension = 4096
+	// defaultCacheControlAvatar the avatars can be cached by the intermediate proxies for a year by default
+	defaultCacheControlAvatar = "public,max-age=31536000,immutable"
+	// defaultSCIMProvisioningCluster the provisioned users are not linked to any cluster by default
+	defaultSCIMProvisioningCluster = ""
+	// defaultJobPollIntervalSeconds the default interval at which the job workers check if their job is due
+	defaultJobPollIntervalSeconds = 30
+	// defaultWorkerShutdownTimeoutSeconds the default maximum time to wait for the workers to stop during the shutdown.
+	// It must be shorter than the grace period of the pod termination
+	defaultWorkerShutdownTimeoutSeconds = 20
 )
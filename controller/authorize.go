@@ -62,7 +62,9 @@ func (c *AuthorizeController) Authorize(ctx *app.AuthorizeAuthorizeContext) erro
 // Callback takes care of Authorize callback
 func (c *AuthorizeController) Callback(ctx *app.CallbackAuthorizeContext) error {
 
-	redirectTo, err := c.app.AuthenticationProviderService().AuthorizeCallback(ctx, ctx.State, ctx.Code)
+	// the code is exchanged with the same callback URL as the one given to the oauth provider by the authorize endpoint
+	callbackURL := rest.AbsoluteURL(ctx.RequestData, client.CallbackAuthorizePath(), nil)
+	redirectTo, err := c.app.AuthenticationProviderService().AuthorizeCallback(ctx, ctx.State, ctx.Code, callbackURL)
 
 	//redirectTo, err := c.Auth.AuthCodeCallback(ctx)
 	if err != nil {
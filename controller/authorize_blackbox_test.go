@@ -13,11 +13,13 @@ import (
 
 	"github.com/fabric8-services/fabric8-auth/app"
 	"github.com/fabric8-services/fabric8-auth/app/test"
+	"github.com/fabric8-services/fabric8-auth/authentication/provider"
 	"github.com/fabric8-services/fabric8-auth/client"
 	. "github.com/fabric8-services/fabric8-auth/controller"
 	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
 	"github.com/fabric8-services/fabric8-auth/jsonapi"
 	testsupport "github.com/fabric8-services/fabric8-auth/test"
+	testtoken "github.com/fabric8-services/fabric8-auth/test/token"
 	"github.com/satori/go.uuid"
 
 	"github.com/goadesign/goa"
@@ -167,6 +169,7 @@ func (rest *TestAuthorizeREST) checkAuthorizeCallbackOK(responseMode *string) {
 	callbackCtx, err := app.NewCallbackAuthorizeContext(goaCtx, req, goa.New("LoginService"))
 	require.Nil(t, err)
 
+	rest.activateDummyIdentityProvider()
 	err = ctrl.Callback(callbackCtx)
 	require.Nil(t, err)
 
@@ -260,6 +263,7 @@ func (rest *TestAuthorizeREST) TestAuthorizeCallbackUnauthorizedError() {
 		"code":  {"SOME_OAUTH2.0_CODE"},
 	}
 
+	rest.activateDummyIdentityProvider()
 	// Request with wrong state results in unauthorized error
 	statusCode, err := rest.makeNewRequest("authorizeCallback", u, t, prms, ctrl)
 	require.NotNil(t, err)
@@ -278,6 +282,23 @@ func (rest *TestAuthorizeREST) TestAuthorizeCallbackUnauthorizedError() {
 	require.Equal(t, 401, statusCode)
 }
 
+// activateDummyIdentityProvider activates an identity provider which exchanges any code for the token of an unknown
+// user, since the authorize callback exchanges the code to verify the second factor of the users who enrolled for it
+func (rest *TestAuthorizeREST) activateDummyIdentityProvider() {
+	claims := map[string]interface{}{
+		"sub": uuid.NewV4().String(),
+	}
+	accessToken, err := testtoken.GenerateTokenWithClaims(claims)
+	require.NoError(rest.T(), err)
+	refreshToken, err := testtoken.GenerateRefreshTokenWithClaims(claims)
+	require.NoError(rest.T(), err)
+	testsupport.ActivateDummyIdentityProviderFactory(rest, &dummyIDPOAuthProvider{
+		IdentityProvider: provider.NewIdentityProvider(rest.Configuration),
+		accessToken:      accessToken,
+		refreshToken:     refreshToken,
+	})
+}
+
 func (rest *TestAuthorizeREST) checkInvalidRequest(testFor string, toBeRemoved string, prms url.Values, u *url.URL, t *testing.T) {
 	ctx := context.Background()
 	rw := httptest.NewRecorder()
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/blob"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	uuid "github.com/satori/go.uuid"
+)
+
+type avatarsConfiguration interface {
+	GetAvatarMaxSize() int64
+	GetCacheControlAvatar() string
+}
+
+// AvatarsController implements the avatars resource.
+type AvatarsController struct {
+	*goa.Controller
+	app    application.Application
+	config avatarsConfiguration
+}
+
+// NewAvatarsController creates an avatars controller.
+func NewAvatarsController(service *goa.Service, app application.Application, config avatarsConfiguration) *AvatarsController {
+	return &AvatarsController{
+		Controller: service.NewController("AvatarsController"),
+		app:        app,
+		config:     config,
+	}
+}
+
+// Upload runs the upload action.
+func (c *AvatarsController) Upload(ctx *app.UploadAvatarsContext) error {
+	identity, err := c.app.UserService().LoadContextIdentityIfNotBanned(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	if ctx.Request.Body == nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterErrorFromString("avatar", "", "the image is empty"))
+	}
+	// one more byte than the limit is read, so that the images which are too large are rejected rather than truncated
+	maxSize := c.config.GetAvatarMaxSize()
+	data, err := ioutil.ReadAll(io.LimitReader(ctx.Request.Body, maxSize+1))
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterErrorFromString("avatar", "", fmt.Sprintf("unable to read the image: %v", err)))
+	}
+	updated, err := c.app.AvatarService().Upload(ctx, ctx.RequestData, identity.ID, data)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"identity_id": identity.ID,
+			"err":         err,
+		}, "unable to upload the avatar")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(ConvertToAppUser(ctx.RequestData, &updated.User, updated, true))
+}
+
+// Delete runs the delete action.
+func (c *AvatarsController) Delete(ctx *app.DeleteAvatarsContext) error {
+	identity, err := c.app.UserService().LoadContextIdentityIfNotBanned(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	updated, err := c.app.AvatarService().Delete(ctx, identity.ID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(ConvertToAppUser(ctx.RequestData, &updated.User, updated, true))
+}
+
+// Show runs the show action. A "304 Not Modified" response is returned if the image did not change since the client's
+// last call, as told by the "If-None-Match" header or else by the "If-Modified-Since" header.
+func (c *AvatarsController) Show(ctx *app.ShowAvatarsContext) error {
+	object, err := c.app.AvatarService().Load(ctx, ctx.ID, ctx.Size)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	eTag := app.GenerateEntityTag(avatarImage{id: ctx.ID, size: ctx.Size, object: object})
+	ctx.ResponseData.Header().Set(app.ETag, eTag)
+	ctx.ResponseData.Header().Set(app.CacheControl, c.config.GetCacheControlAvatar())
+	if !object.LastModified.IsZero() {
+		ctx.ResponseData.Header().Set(app.LastModified, app.ToHTTPTime(object.LastModified))
+	}
+	if notModified(ctx.IfNoneMatch, ctx.IfModifiedSince, eTag, object.LastModified) {
+		return ctx.NotModified()
+	}
+	ctx.ResponseData.Header().Set("Content-Type", object.ContentType)
+	return ctx.OK(object.Data)
+}
+
+// notModified returns true if the given 'If-None-Match' header matches the ETag of the entity, or if there is no such
+// header and the given 'If-Modified-Since' header is not before the last modification of the entity
+func notModified(ifNoneMatch, ifModifiedSince *string, eTag string, lastModified time.Time) bool {
+	if ifNoneMatch != nil {
+		return *ifNoneMatch == eTag
+	}
+	if ifModifiedSince == nil || lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(*ifModifiedSince)
+	if err != nil {
+		return false
+	}
+	// the HTTP dates have a precision of one second
+	return !since.Before(lastModified.Truncate(time.Second))
+}
+
+// avatarImage the image of an avatar in a given size, which is a conditional request entity
+type avatarImage struct {
+	id     uuid.UUID
+	size   int
+	object *blob.Object
+}
+
+// GetETagData returns the field values to use to generate the ETag
+func (i avatarImage) GetETagData() []interface{} {
+	return []interface{}{i.id, i.size, i.object.LastModified}
+}
+
+// GetLastModified returns the last modification time
+func (i avatarImage) GetLastModified() time.Time {
+	return i.object.LastModified
+}
@@ -0,0 +1,203 @@
+package controller_test
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/app/test"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/controller"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	testsupport "github.com/fabric8-services/fabric8-auth/test"
+
+	"github.com/goadesign/goa"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestAvatarsController(t *testing.T) {
+	suite.Run(t, &AvatarsControllerTestSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+type AvatarsControllerTestSuite struct {
+	gormtestsupport.DBTestSuite
+}
+
+func (s *AvatarsControllerTestSuite) SecuredController(identity repository.Identity) (*goa.Service, *controller.AvatarsController) {
+	svc := testsupport.ServiceAsUser("Avatars-Service", identity)
+	return svc, controller.NewAvatarsController(svc, s.Application, s.Configuration)
+}
+
+func (s *AvatarsControllerTestSuite) UnsecuredController() (*goa.Service, *controller.AvatarsController) {
+	svc := goa.New("Avatars-Service")
+	return svc, controller.NewAvatarsController(svc, s.Application, s.Configuration)
+}
+
+// upload sends the given image to the upload action of the controller, since the generated test helpers do not
+// support a raw request body
+func (s *AvatarsControllerTestSuite) upload(t *testing.T, identity repository.Identity, data []byte) *httptest.ResponseRecorder {
+	svc, ctrl := s.SecuredController(identity)
+	rw := httptest.NewRecorder()
+	req, err := http.NewRequest("PUT", "/api/avatars", bytes.NewReader(data))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "image/png")
+	goaCtx := goa.NewContext(goa.WithAction(svc.Context, "UploadAvatarsTest"), rw, req, url.Values{})
+	uploadCtx, err := app.NewUploadAvatarsContext(goaCtx, req, svc)
+	require.NoError(t, err)
+	err = ctrl.Upload(uploadCtx)
+	require.NoError(t, err)
+	return rw
+}
+
+func (s *AvatarsControllerTestSuite) TestUpload() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		// when
+		rw := s.upload(t, *identity, testsupport.NewPNGImage(t, 100, 100))
+		// then
+		require.Equal(t, http.StatusOK, rw.Code)
+		user, err := s.Application.Users().Load(s.Ctx, identity.User.ID)
+		require.NoError(t, err)
+		require.NotNil(t, user.AvatarID)
+		assert.Contains(t, rw.Body.String(), user.ImageURL)
+		assert.Contains(t, user.ImageURL, "/api/avatars/"+user.AvatarID.String())
+	})
+
+	s.T().Run("not an image", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		// when
+		rw := s.upload(t, *identity, []byte("this is not an image"))
+		// then
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+	})
+
+	s.T().Run("too large", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		data := append(testsupport.NewPNGImage(t, 64, 64), make([]byte, s.Configuration.GetAvatarMaxSize())...)
+		// when
+		rw := s.upload(t, *identity, data)
+		// then
+		assert.Equal(t, http.StatusBadRequest, rw.Code)
+	})
+}
+
+func (s *AvatarsControllerTestSuite) TestDelete() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		rw := s.upload(t, *identity, testsupport.NewPNGImage(t, 64, 64))
+		require.Equal(t, http.StatusOK, rw.Code)
+		svc, ctrl := s.SecuredController(*identity)
+		// when
+		_, result := test.DeleteAvatarsOK(t, svc.Context, svc, ctrl)
+		// then
+		require.NotNil(t, result.Data.Attributes.ImageURL)
+		assert.Empty(t, *result.Data.Attributes.ImageURL)
+	})
+
+	s.T().Run("no avatar", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		svc, ctrl := s.SecuredController(*identity)
+		// when/then
+		test.DeleteAvatarsNotFound(t, svc.Context, svc, ctrl)
+	})
+
+	s.T().Run("unauthorized", func(t *testing.T) {
+		// given
+		svc, ctrl := s.UnsecuredController()
+		// when/then
+		test.DeleteAvatarsUnauthorized(t, svc.Context, svc, ctrl)
+	})
+}
+
+func (s *AvatarsControllerTestSuite) TestShow() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		rw := s.upload(t, *identity, testsupport.NewPNGImage(t, 64, 64))
+		require.Equal(t, http.StatusOK, rw.Code)
+		user, err := s.Application.Users().Load(s.Ctx, identity.User.ID)
+		require.NoError(t, err)
+		svc, ctrl := s.UnsecuredController()
+		// when
+		res := test.ShowAvatarsOK(t, svc.Context, svc, ctrl, *user.AvatarID, 32, nil, nil)
+		// then
+		assert.Equal(t, "image/png", res.Header().Get("Content-Type"))
+		assert.Equal(t, s.Configuration.GetCacheControlAvatar(), res.Header().Get("Cache-Control"))
+		assert.NotEmpty(t, res.Header().Get(app.ETag))
+		assert.NotEmpty(t, res.Header().Get(app.LastModified))
+		config, err := png.DecodeConfig(res.(*httptest.ResponseRecorder).Body)
+		require.NoError(t, err)
+		assert.Equal(t, 32, config.Width)
+		assert.Equal(t, 32, config.Height)
+	})
+
+	s.T().Run("not modified", func(t *testing.T) {
+		// given
+		identity := s.Graph.CreateUser().Identity()
+		rw := s.upload(t, *identity, testsupport.NewPNGImage(t, 64, 64))
+		require.Equal(t, http.StatusOK, rw.Code)
+		user, err := s.Application.Users().Load(s.Ctx, identity.User.ID)
+		require.NoError(t, err)
+		svc, ctrl := s.UnsecuredController()
+		res := test.ShowAvatarsOK(t, svc.Context, svc, ctrl, *user.AvatarID, 64, nil, nil)
+		eTag := res.Header().Get(app.ETag)
+		lastModified := res.Header().Get(app.LastModified)
+
+		t.Run("using if-none-match header", func(t *testing.T) {
+			// when
+			res := test.ShowAvatarsNotModified(t, svc.Context, svc, ctrl, *user.AvatarID, 64, nil, &eTag)
+			// then
+			assert.Equal(t, eTag, res.Header().Get(app.ETag))
+			assert.Equal(t, s.Configuration.GetCacheControlAvatar(), res.Header().Get(app.CacheControl))
+		})
+
+		t.Run("using if-modified-since header", func(t *testing.T) {
+			// when
+			res := test.ShowAvatarsNotModified(t, svc.Context, svc, ctrl, *user.AvatarID, 64, &lastModified, nil)
+			// then
+			assert.Equal(t, eTag, res.Header().Get(app.ETag))
+		})
+
+		t.Run("other size", func(t *testing.T) {
+			// the images of an avatar in the other sizes have their own ETag
+			test.ShowAvatarsOK(t, svc.Context, svc, ctrl, *user.AvatarID, 32, nil, &eTag)
+		})
+
+		t.Run("modified since", func(t *testing.T) {
+			// given
+			ifModifiedSince := app.ToHTTPTime(time.Now().Add(-1 * time.Hour))
+			// when/then
+			test.ShowAvatarsOK(t, svc.Context, svc, ctrl, *user.AvatarID, 64, &ifModifiedSince, nil)
+		})
+
+		t.Run("if-none-match header takes precedence", func(t *testing.T) {
+			// given
+			ifNoneMatch := "foo"
+			// when/then
+			test.ShowAvatarsOK(t, svc.Context, svc, ctrl, *user.AvatarID, 64, &lastModified, &ifNoneMatch)
+		})
+	})
+
+	s.T().Run("unknown avatar", func(t *testing.T) {
+		// given
+		svc, ctrl := s.UnsecuredController()
+		// when/then
+		test.ShowAvatarsNotFound(t, svc.Context, svc, ctrl, uuid.NewV4(), 128, nil, nil)
+	})
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization/token"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	errs "github.com/pkg/errors"
+)
+
+// DeactivationsController implements the deactivations resource.
+type DeactivationsController struct {
+	*goa.Controller
+	app application.Application
+}
+
+// NewDeactivationsController creates a deactivations controller.
+func NewDeactivationsController(service *goa.Service, app application.Application) *DeactivationsController {
+	return &DeactivationsController{
+		Controller: service.NewController("DeactivationsController"),
+		app:        app,
+	}
+}
+
+// List runs the "list" action: it returns the users who would be notified or deactivated during the next cycle of the
+// user deactivation workers, as a paginated JSON-API list or as a CSV document containing all users.
+func (c *DeactivationsController) List(ctx *app.ListDeactivationsContext) error {
+	isSvcAccount := token.IsSpecificServiceAccount(ctx, token.Admin)
+	if !isSvcAccount {
+		log.Error(ctx, nil, "the account is not an authorized service account allowed to list the deactivation candidates")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewForbiddenError("account not authorized to list the deactivation candidates"))
+	}
+
+	offset, limit := computePagingLimits(ctx.PageOffset, ctx.PageLimit)
+	if ctx.Format == "csv" {
+		// all the candidates are exported
+		offset, limit = 0, -1
+	}
+	page, count, err := c.app.UserService().ListDeactivationCandidates(ctx, time.Now, offset, limit)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to list the deactivation candidates")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	if ctx.Format == "csv" {
+		return writeDeactivationCandidatesCSV(ctx, page)
+	}
+
+	data := make([]*app.DeactivationCandidateData, len(page))
+	for i, candidate := range page {
+		data[i] = &app.DeactivationCandidateData{
+			ID:   candidate.Identity.ID.String(),
+			Type: "deactivation-candidates",
+			Attributes: &app.DeactivationCandidateDataAttributes{
+				Username:              candidate.Identity.Username,
+				Action:                candidate.Action,
+				Reason:                candidate.Reason,
+				LastActive:            candidate.Identity.LastActive,
+				ScheduledDeactivation: candidate.ScheduledDeactivation,
+			},
+		}
+	}
+	response := app.DeactivationCandidateList{
+		Data:  data,
+		Links: &app.PagingLinks{},
+		Meta:  &app.DeactivationCandidateListMeta{TotalCount: count},
+	}
+	setPagingLinks(response.Links, buildAbsoluteURL(ctx.RequestData), len(page), offset, limit, count)
+	return ctx.OK(&response)
+}
+
+// writeDeactivationCandidatesCSV writes all the given candidates in the response, in CSV format
+func writeDeactivationCandidatesCSV(ctx *app.ListDeactivationsContext, candidates []repository.DeactivationCandidate) error {
+	ctx.ResponseData.Header().Set("Content-Type", "text/csv")
+	ctx.ResponseData.Header().Set("Content-Disposition", `attachment; filename="deactivations.csv"`)
+	ctx.ResponseData.WriteHeader(http.StatusOK)
+	w := csv.NewWriter(ctx.ResponseData)
+	err := w.Write([]string{"identity_id", "username", "action", "reason", "last_active", "scheduled_deactivation"})
+	if err != nil {
+		return errs.Wrap(err, "unable to write the deactivation candidates")
+	}
+	for _, candidate := range candidates {
+		lastActive := ""
+		if candidate.Identity.LastActive != nil {
+			lastActive = candidate.Identity.LastActive.Format(time.RFC3339)
+		}
+		err := w.Write([]string{
+			candidate.Identity.ID.String(),
+			csvCell(candidate.Identity.Username),
+			csvCell(candidate.Action),
+			csvCell(candidate.Reason),
+			lastActive,
+			candidate.ScheduledDeactivation.Format(time.RFC3339),
+		})
+		if err != nil {
+			return errs.Wrap(err, "unable to write the deactivation candidates")
+		}
+	}
+	w.Flush()
+	return errs.Wrap(w.Error(), "unable to write the deactivation candidates")
+}
+
+// csvCell returns the given value, prefixed with a single quote if it starts with a character which would make a
+// spreadsheet application evaluate it as a formula
+func csvCell(value string) string {
+	if value != "" && strings.ContainsAny(value[:1], "=+-@\t\r") {
+		return "'" + value
+	}
+	return value
+}
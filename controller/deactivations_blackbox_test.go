@@ -0,0 +1,126 @@
+package controller_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/app/test"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/controller"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	testsupport "github.com/fabric8-services/fabric8-auth/test"
+
+	"github.com/goadesign/goa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestDeactivationsController(t *testing.T) {
+	suite.Run(t, &DeactivationsControllerTestSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+type DeactivationsControllerTestSuite struct {
+	gormtestsupport.DBTestSuite
+}
+
+func (s *DeactivationsControllerTestSuite) SecuredServiceAccountController(identity repository.Identity) (*goa.Service, *controller.DeactivationsController) {
+	svc := testsupport.ServiceAsServiceAccountUser("Deactivations-ServiceAccount-Service", identity)
+	return svc, controller.NewDeactivationsController(svc, s.Application)
+}
+
+func (s *DeactivationsControllerTestSuite) TestList() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		ago60days := time.Now().Add(-60 * 24 * time.Hour)
+		ago10days := time.Now().Add(-10 * 24 * time.Hour)
+		yesterday := time.Now().Add(-24 * time.Hour)
+		toNotify := s.Graph.CreateUser().Identity()
+		toNotify.LastActive = &ago60days
+		err := s.Application.Identities().Save(s.Ctx, toNotify)
+		require.NoError(t, err)
+		toDeactivate := s.Graph.CreateUser().Identity()
+		toDeactivate.LastActive = &ago60days
+		toDeactivate.DeactivationNotification = &ago10days
+		toDeactivate.DeactivationScheduled = &yesterday
+		err = s.Application.Identities().Save(s.Ctx, toDeactivate)
+		require.NoError(t, err)
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+		// when
+		_, result := test.ListDeactivationsOK(t, svc.Context, svc, ctrl, "json", nil, nil)
+		// then
+		actions := map[string]string{}
+		for _, candidate := range result.Data {
+			actions[candidate.ID] = candidate.Attributes.Action
+		}
+		assert.Equal(t, repository.DeactivationActionNotify, actions[toNotify.ID.String()])
+		assert.Equal(t, repository.DeactivationActionDeactivate, actions[toDeactivate.ID.String()])
+		assert.Equal(t, len(result.Data), result.Meta.TotalCount)
+		// and the users were neither notified nor deactivated
+		identity, err := s.Application.Identities().Load(s.Ctx, toNotify.ID)
+		require.NoError(t, err)
+		assert.Nil(t, identity.DeactivationNotification)
+	})
+
+	s.T().Run("paginated", func(t *testing.T) {
+		// given
+		ago60days := time.Now().Add(-60 * 24 * time.Hour)
+		for i := 0; i < 2; i++ {
+			identity := s.Graph.CreateUser().Identity()
+			identity.LastActive = &ago60days
+			err := s.Application.Identities().Save(s.Ctx, identity)
+			require.NoError(t, err)
+		}
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+		limit := 1
+		offset := "0"
+		// when
+		_, result := test.ListDeactivationsOK(t, svc.Context, svc, ctrl, "json", &limit, &offset)
+		// then
+		assert.Len(t, result.Data, 1)
+		assert.True(t, result.Meta.TotalCount >= 2)
+		assert.NotNil(t, result.Links.Next)
+	})
+
+	s.T().Run("paginated across the actions", func(t *testing.T) {
+		// given
+		ago60days := time.Now().Add(-60 * 24 * time.Hour)
+		ago10days := time.Now().Add(-10 * 24 * time.Hour)
+		yesterday := time.Now().Add(-24 * time.Hour)
+		toNotify := s.Graph.CreateUser().Identity()
+		toNotify.LastActive = &ago60days
+		err := s.Application.Identities().Save(s.Ctx, toNotify)
+		require.NoError(t, err)
+		toDeactivate := s.Graph.CreateUser().Identity()
+		toDeactivate.LastActive = &ago60days
+		toDeactivate.DeactivationNotification = &ago10days
+		toDeactivate.DeactivationScheduled = &yesterday
+		err = s.Application.Identities().Save(s.Ctx, toDeactivate)
+		require.NoError(t, err)
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+		all := 100
+		_, expected := test.ListDeactivationsOK(t, svc.Context, svc, ctrl, "json", &all, nil)
+		require.True(t, len(expected.Data) >= 2)
+		// when listing the candidates one at a time
+		result := []string{}
+		for i := 0; i < len(expected.Data); i++ {
+			limit := 1
+			offset := strconv.Itoa(i)
+			_, page := test.ListDeactivationsOK(t, svc.Context, svc, ctrl, "json", &limit, &offset)
+			require.Len(t, page.Data, 1)
+			assert.Equal(t, expected.Meta.TotalCount, page.Meta.TotalCount)
+			result = append(result, page.Data[0].ID)
+		}
+		// then
+		for i, candidate := range expected.Data {
+			assert.Equal(t, candidate.ID, result[i])
+		}
+	})
+
+	s.T().Run("forbidden", func(t *testing.T) {
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestOnlineRegistrationAppIdentity)
+		test.ListDeactivationsForbidden(t, svc.Context, svc, ctrl, "json", nil, nil)
+	})
+}
@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/resource"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSVCell(t *testing.T) {
+	resource.Require(t, resource.UnitTest)
+
+	testcases := map[string]string{
+		"jdoe":                 "jdoe",
+		"":                     "",
+		"=HYPERLINK(\"x\")":    "'=HYPERLINK(\"x\")",
+		"+1":                   "'+1",
+		"-cmd":                 "'-cmd",
+		"@SUM(A1)":             "'@SUM(A1)",
+		"\t=1":                 "'\t=1",
+		"still inactive (a=b)": "still inactive (a=b)",
+	}
+	for value, expected := range testcases {
+		t.Run(value, func(t *testing.T) {
+			assert.Equal(t, expected, csvCell(value))
+		})
+	}
+}
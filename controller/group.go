@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+)
+
+// GroupController implements the group resource.
+type GroupController struct {
+	*goa.Controller
+	app application.Application
+}
+
+// NewGroupController creates a group controller.
+func NewGroupController(service *goa.Service, app application.Application) *GroupController {
+	return &GroupController{Controller: service.NewController("GroupController"), app: app}
+}
+
+// Create runs the create action.
+func (c *GroupController) Create(ctx *app.CreateGroupContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	groupID, err := c.app.GroupService().CreateGroup(ctx, *currentUser, ctx.Payload.Name)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":        err,
+			"group_name": ctx.Payload.Name,
+		}, "failed to create group")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.Created(&app.CreateGroupResponse{
+		GroupID: groupID.String(),
+	})
+}
+
+// ListMembers runs the listMembers action.
+func (c *GroupController) ListMembers(ctx *app.ListMembersGroupContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	members, err := c.app.GroupService().ListGroupMembers(ctx, *currentUser, ctx.ID, ctx.Transitive)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":      err,
+			"group_id": ctx.ID,
+		}, "failed to list group members")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	data := make([]*app.UserData, 0, len(members))
+	for i := range members {
+		// nested groups are members too, but are not users
+		if members[i].IdentityResourceID.Valid {
+			continue
+		}
+		data = append(data, ConvertToAppUser(ctx.RequestData, &members[i].User, &members[i], false).Data)
+	}
+	return ctx.OK(&app.UserList{
+		Links: &app.PagingLinks{},
+		Meta:  &app.UserListMeta{TotalCount: len(data)},
+		Data:  data,
+	})
+}
+
+// AddMember runs the addMember action.
+func (c *GroupController) AddMember(ctx *app.AddMemberGroupContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	err = c.app.GroupService().AddGroupMember(ctx, *currentUser, ctx.ID, ctx.MemberID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":       err,
+			"group_id":  ctx.ID,
+			"member_id": ctx.MemberID,
+		}, "failed to add group member")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent()
+}
+
+// RemoveMember runs the removeMember action.
+func (c *GroupController) RemoveMember(ctx *app.RemoveMemberGroupContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	err = c.app.GroupService().RemoveGroupMember(ctx, *currentUser, ctx.ID, ctx.MemberID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":       err,
+			"group_id":  ctx.ID,
+			"member_id": ctx.MemberID,
+		}, "failed to remove group member")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent()
+}
+
+// Nest runs the nest action.
+func (c *GroupController) Nest(ctx *app.NestGroupContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	err = c.app.GroupService().NestGroup(ctx, *currentUser, ctx.ID, ctx.ParentID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":       err,
+			"group_id":  ctx.ID,
+			"parent_id": ctx.ParentID,
+		}, "failed to nest group")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent()
+}
+
+// Unnest runs the unnest action.
+func (c *GroupController) Unnest(ctx *app.UnnestGroupContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	err = c.app.GroupService().UnnestGroup(ctx, *currentUser, ctx.ID, ctx.ParentID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":       err,
+			"group_id":  ctx.ID,
+			"parent_id": ctx.ParentID,
+		}, "failed to unnest group")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent()
+}
@@ -0,0 +1,98 @@
+package controller_test
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/app/test"
+	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	. "github.com/fabric8-services/fabric8-auth/controller"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	testsupport "github.com/fabric8-services/fabric8-auth/test"
+
+	"github.com/goadesign/goa"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TestGroupREST struct {
+	gormtestsupport.DBTestSuite
+}
+
+func TestRunGroupREST(t *testing.T) {
+	suite.Run(t, &TestGroupREST{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+func (rest *TestGroupREST) SecuredController(identity account.Identity) (*goa.Service, *GroupController) {
+	svc := testsupport.ServiceAsUser("Group-Service", identity)
+	return svc, NewGroupController(svc, rest.Application)
+}
+
+func (rest *TestGroupREST) UnsecuredController() (*goa.Service, *GroupController) {
+	svc := goa.New("Group-Service")
+	return svc, NewGroupController(svc, rest.Application)
+}
+
+func (rest *TestGroupREST) TestCreateGroup() {
+	g := rest.NewTestGraph(rest.T())
+	user := g.CreateUser()
+
+	rest.T().Run("ok", func(t *testing.T) {
+		service, controller := rest.SecuredController(*user.Identity())
+		payload := &app.CreateGroupPayload{Name: "Group-" + uuid.NewV4().String()}
+		_, created := test.CreateGroupCreated(t, service.Context, service, controller, payload)
+		require.NotEmpty(t, created.GroupID)
+	})
+
+	rest.T().Run("unauthorized", func(t *testing.T) {
+		service, controller := rest.UnsecuredController()
+		payload := &app.CreateGroupPayload{Name: "Group-" + uuid.NewV4().String()}
+		test.CreateGroupUnauthorized(t, service.Context, service, controller, payload)
+	})
+}
+
+func (rest *TestGroupREST) TestGroupMembers() {
+	g := rest.NewTestGraph(rest.T())
+	admin := g.CreateUser()
+	parent := g.CreateGroup(admin)
+	child := g.CreateGroup(admin)
+	member := g.CreateUser()
+	nestedMember := g.CreateUser()
+	service, controller := rest.SecuredController(*admin.Identity())
+
+	rest.T().Run("add members", func(t *testing.T) {
+		test.AddMemberGroupNoContent(t, service.Context, service, controller, parent.GroupID(), member.IdentityID())
+		test.AddMemberGroupNoContent(t, service.Context, service, controller, child.GroupID(), nestedMember.IdentityID())
+		test.NestGroupNoContent(t, service.Context, service, controller, child.GroupID(), parent.GroupID())
+	})
+
+	rest.T().Run("list direct members", func(t *testing.T) {
+		_, members := test.ListMembersGroupOK(t, service.Context, service, controller, parent.GroupID(), false)
+		require.Len(t, members.Data, 1)
+		assert.Equal(t, member.IdentityID().String(), *members.Data[0].ID)
+	})
+
+	rest.T().Run("list transitive members", func(t *testing.T) {
+		_, members := test.ListMembersGroupOK(t, service.Context, service, controller, parent.GroupID(), true)
+		require.Len(t, members.Data, 2)
+	})
+
+	rest.T().Run("cycle", func(t *testing.T) {
+		test.NestGroupBadRequest(t, service.Context, service, controller, parent.GroupID(), child.GroupID())
+	})
+
+	rest.T().Run("forbidden", func(t *testing.T) {
+		svc, ctrl := rest.SecuredController(*member.Identity())
+		test.AddMemberGroupForbidden(t, svc.Context, svc, ctrl, parent.GroupID(), g.CreateUser().IdentityID())
+	})
+
+	rest.T().Run("unnest and remove member", func(t *testing.T) {
+		test.UnnestGroupNoContent(t, service.Context, service, controller, child.GroupID(), parent.GroupID())
+		test.RemoveMemberGroupNoContent(t, service.Context, service, controller, parent.GroupID(), member.IdentityID())
+		_, members := test.ListMembersGroupOK(t, service.Context, service, controller, parent.GroupID(), true)
+		require.Empty(t, members.Data)
+		test.RemoveMemberGroupNotFound(t, service.Context, service, controller, parent.GroupID(), member.IdentityID())
+	})
+}
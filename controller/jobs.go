@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/authorization/token"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/worker/repository"
+
+	"github.com/goadesign/goa"
+)
+
+// JobsController implements the jobs resource.
+type JobsController struct {
+	*goa.Controller
+	app application.Application
+}
+
+// NewJobsController creates a jobs controller.
+func NewJobsController(service *goa.Service, app application.Application) *JobsController {
+	return &JobsController{
+		Controller: service.NewController("JobsController"),
+		app:        app,
+	}
+}
+
+// List runs the list action.
+func (c *JobsController) List(ctx *app.ListJobsContext) error {
+	if !token.IsSpecificServiceAccount(ctx, token.Admin) {
+		log.Error(ctx, nil, "the account is not an authorized service account allowed to manage the jobs")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewForbiddenError("account not authorized to manage the jobs"))
+	}
+	jobs, err := c.app.JobRepository().List(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	result := &app.JobList{
+		Data: make([]*app.JobData, len(jobs)),
+	}
+	for i, job := range jobs {
+		data, err := c.convertJob(ctx, job)
+		if err != nil {
+			return jsonapi.JSONErrorResponse(ctx, err)
+		}
+		result.Data[i] = data
+	}
+	return ctx.OK(result)
+}
+
+// Runs runs the runs action.
+func (c *JobsController) Runs(ctx *app.RunsJobsContext) error {
+	if !token.IsSpecificServiceAccount(ctx, token.Admin) {
+		log.Error(ctx, nil, "the account is not an authorized service account allowed to manage the jobs")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewForbiddenError("account not authorized to manage the jobs"))
+	}
+	_, err := c.app.JobRepository().Load(ctx, ctx.Name)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	runs, err := c.app.JobRunRepository().ListForJob(ctx, ctx.Name, ctx.Limit)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	result := &app.JobRunList{
+		Data: make([]*app.JobRunData, len(runs)),
+	}
+	for i, run := range runs {
+		result.Data[i] = &app.JobRunData{
+			ID:         run.JobRunID.String(),
+			Type:       "job-runs",
+			Attributes: convertJobRun(run),
+		}
+	}
+	return ctx.OK(result)
+}
+
+// Trigger runs the trigger action.
+func (c *JobsController) Trigger(ctx *app.TriggerJobsContext) error {
+	if !token.IsSpecificServiceAccount(ctx, token.Admin) {
+		log.Error(ctx, nil, "the account is not an authorized service account allowed to manage the jobs")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewForbiddenError("account not authorized to manage the jobs"))
+	}
+	err := c.app.JobRepository().RequestTrigger(ctx, ctx.Name, token.Admin)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	log.Info(ctx, map[string]interface{}{
+		"job": ctx.Name,
+	}, "job run requested")
+	return ctx.Accepted()
+}
+
+// Pause runs the pause action.
+func (c *JobsController) Pause(ctx *app.PauseJobsContext) error {
+	if !token.IsSpecificServiceAccount(ctx, token.Admin) {
+		log.Error(ctx, nil, "the account is not an authorized service account allowed to manage the jobs")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewForbiddenError("account not authorized to manage the jobs"))
+	}
+	job, err := c.setPaused(ctx, ctx.Name, true)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.JobSingle{Data: job})
+}
+
+// Resume runs the resume action.
+func (c *JobsController) Resume(ctx *app.ResumeJobsContext) error {
+	if !token.IsSpecificServiceAccount(ctx, token.Admin) {
+		log.Error(ctx, nil, "the account is not an authorized service account allowed to manage the jobs")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewForbiddenError("account not authorized to manage the jobs"))
+	}
+	job, err := c.setPaused(ctx, ctx.Name, false)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.JobSingle{Data: job})
+}
+
+func (c *JobsController) setPaused(ctx context.Context, name string, paused bool) (*app.JobData, error) {
+	err := c.app.JobRepository().SetPaused(ctx, name, paused)
+	if err != nil {
+		return nil, err
+	}
+	log.Info(ctx, map[string]interface{}{
+		"job":    name,
+		"paused": paused,
+	}, "job paused or resumed")
+	job, err := c.app.JobRepository().Load(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return c.convertJob(ctx, *job)
+}
+
+// convertJob converts a job to its API representation, along with its latest run
+func (c *JobsController) convertJob(ctx context.Context, job repository.Job) (*app.JobData, error) {
+	result := &app.JobData{
+		ID:   job.Name,
+		Type: "jobs",
+		Attributes: &app.JobDataAttributes{
+			Schedule:           job.Schedule,
+			Paused:             job.Paused,
+			TriggerRequestedAt: job.TriggerRequestedAt,
+			TriggerRequestedBy: job.TriggerRequestedBy,
+		},
+	}
+	latest, err := c.app.JobRunRepository().LoadLatest(ctx, job.Name)
+	if err != nil {
+		if notFound, _ := errors.IsNotFoundError(err); !notFound {
+			return nil, err
+		}
+	} else {
+		result.Attributes.LastRun = convertJobRun(*latest)
+	}
+	return result, nil
+}
+
+func convertJobRun(run repository.JobRun) *app.JobRunDataAttributes {
+	return &app.JobRunDataAttributes{
+		Owner:          run.Owner,
+		Trigger:        run.Trigger,
+		StartedAt:      run.StartedAt,
+		EndedAt:        run.EndedAt,
+		Outcome:        run.Outcome,
+		ItemsProcessed: run.ItemsProcessed,
+		Error:          run.Error,
+	}
+}
@@ -0,0 +1,150 @@
+package controller_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/app/test"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/controller"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	testsupport "github.com/fabric8-services/fabric8-auth/test"
+	workerrepo "github.com/fabric8-services/fabric8-auth/worker/repository"
+
+	"github.com/goadesign/goa"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestJobsController(t *testing.T) {
+	suite.Run(t, &JobsControllerTestSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+type JobsControllerTestSuite struct {
+	gormtestsupport.DBTestSuite
+}
+
+func (s *JobsControllerTestSuite) SecuredServiceAccountController(identity repository.Identity) (*goa.Service, *controller.JobsController) {
+	svc := testsupport.ServiceAsServiceAccountUser("Jobs-ServiceAccount-Service", identity)
+	return svc, controller.NewJobsController(svc, s.Application)
+}
+
+// createJob registers a job along with a successful run
+func (s *JobsControllerTestSuite) createJob(t *testing.T) string {
+	name := fmt.Sprintf("test-job-%s", uuid.NewV4())
+	_, err := s.Application.JobRepository().Register(s.Ctx, name, "@hourly")
+	require.NoError(t, err)
+	end := time.Now()
+	err = s.Application.JobRunRepository().Create(s.Ctx, &workerrepo.JobRun{
+		JobName:        name,
+		Owner:          "pod-1",
+		Trigger:        workerrepo.JobRunTriggerSchedule,
+		StartedAt:      end.Add(-1 * time.Second),
+		EndedAt:        &end,
+		Outcome:        workerrepo.JobRunOutcomeSuccess,
+		ItemsProcessed: 5,
+	})
+	require.NoError(t, err)
+	return name
+}
+
+func (s *JobsControllerTestSuite) TestList() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		name := s.createJob(t)
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+		// when
+		_, result := test.ListJobsOK(t, svc.Context, svc, ctrl)
+		// then
+		var found bool
+		for _, job := range result.Data {
+			if job.ID == name {
+				found = true
+				assert.Equal(t, "@hourly", job.Attributes.Schedule)
+				assert.False(t, job.Attributes.Paused)
+				require.NotNil(t, job.Attributes.LastRun)
+				assert.Equal(t, workerrepo.JobRunOutcomeSuccess, job.Attributes.LastRun.Outcome)
+				assert.Equal(t, 5, job.Attributes.LastRun.ItemsProcessed)
+			}
+		}
+		assert.True(t, found)
+	})
+
+	s.T().Run("forbidden", func(t *testing.T) {
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestOnlineRegistrationAppIdentity)
+		test.ListJobsForbidden(t, svc.Context, svc, ctrl)
+	})
+}
+
+func (s *JobsControllerTestSuite) TestRuns() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		name := s.createJob(t)
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+		// when
+		_, result := test.RunsJobsOK(t, svc.Context, svc, ctrl, name, 20)
+		// then
+		require.Len(t, result.Data, 1)
+		assert.Equal(t, "pod-1", result.Data[0].Attributes.Owner)
+	})
+
+	s.T().Run("unknown job", func(t *testing.T) {
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+		test.RunsJobsNotFound(t, svc.Context, svc, ctrl, uuid.NewV4().String(), 20)
+	})
+}
+
+func (s *JobsControllerTestSuite) TestTrigger() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		name := s.createJob(t)
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+		// when
+		test.TriggerJobsAccepted(t, svc.Context, svc, ctrl, name)
+		// then
+		job, err := s.Application.JobRepository().Load(s.Ctx, name)
+		require.NoError(t, err)
+		assert.NotNil(t, job.TriggerRequestedAt)
+	})
+
+	s.T().Run("unknown job", func(t *testing.T) {
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+		test.TriggerJobsNotFound(t, svc.Context, svc, ctrl, uuid.NewV4().String())
+	})
+
+	s.T().Run("forbidden", func(t *testing.T) {
+		name := s.createJob(t)
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestOnlineRegistrationAppIdentity)
+		test.TriggerJobsForbidden(t, svc.Context, svc, ctrl, name)
+	})
+}
+
+func (s *JobsControllerTestSuite) TestPauseAndResume() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		name := s.createJob(t)
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+		// when
+		_, paused := test.PauseJobsOK(t, svc.Context, svc, ctrl, name)
+		// then
+		assert.True(t, paused.Data.Attributes.Paused)
+		// when
+		_, resumed := test.ResumeJobsOK(t, svc.Context, svc, ctrl, name)
+		// then
+		assert.False(t, resumed.Data.Attributes.Paused)
+	})
+
+	s.T().Run("forbidden", func(t *testing.T) {
+		name := s.createJob(t)
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestOnlineRegistrationAppIdentity)
+		test.PauseJobsForbidden(t, svc.Context, svc, ctrl, name)
+		test.ResumeJobsForbidden(t, svc.Context, svc, ctrl, name)
+	})
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/authorization/token"
+	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	uuid "github.com/satori/go.uuid"
+)
+
+// MfaController implements the mfa resource.
+type MfaController struct {
+	*goa.Controller
+	app          application.Application
+	tokenManager manager.TokenManager
+}
+
+// NewMfaController creates a mfa controller.
+func NewMfaController(service *goa.Service, app application.Application, tokenManager manager.TokenManager) *MfaController {
+	return &MfaController{
+		Controller:   service.NewController("MfaController"),
+		app:          app,
+		tokenManager: tokenManager,
+	}
+}
+
+// EnrollTOTP starts the TOTP enrollment of the current user
+func (c *MfaController) EnrollTOTP(ctx *app.EnrollTOTPMfaContext) error {
+	identityID, err := c.tokenManager.Locate(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "Bad Token")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError("bad or missing token"))
+	}
+	// users who already have a WebAuthn credential must use it to enroll another second factor
+	enrolled, err := c.app.MFAService().IsEnrolled(ctx, identityID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	if enrolled && !token.IsMultiFactorAuthenticated(ctx) {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewForbiddenError("multi-factor authentication is required to enroll another second factor"))
+	}
+	secret, keyURI, err := c.app.MFAService().EnrollTOTP(ctx, identityID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	ctx.ResponseData.Header().Set("Cache-Control", "no-store")
+	return ctx.OK(&app.TOTPEnrollment{
+		Secret: secret,
+		KeyURI: keyURI,
+	})
+}
+
+// ConfirmTOTP confirms the TOTP enrollment of the current user and returns the recovery codes
+func (c *MfaController) ConfirmTOTP(ctx *app.ConfirmTOTPMfaContext) error {
+	identityID, err := c.tokenManager.Locate(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "Bad Token")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError("bad or missing token"))
+	}
+	recoveryCodes, err := c.app.MFAService().ConfirmTOTP(ctx, identityID, ctx.Payload.Code)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	ctx.ResponseData.Header().Set("Cache-Control", "no-store")
+	return ctx.OK(&app.MFARecoveryCodes{
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+// DisableTOTP disables the multi-factor authentication of the current user. Only a token obtained with
+// multi-factor authentication is allowed to do so.
+func (c *MfaController) DisableTOTP(ctx *app.DisableTOTPMfaContext) error {
+	identityID, err := c.tokenManager.Locate(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "Bad Token")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError("bad or missing token"))
+	}
+	if !token.IsMultiFactorAuthenticated(ctx) {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewForbiddenError("multi-factor authentication is required to disable the second factor"))
+	}
+	err = c.app.MFAService().DisableTOTP(ctx, identityID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.NoContent()
+}
+
+// Verify verifies the second factor of a pending login and returns the URL to redirect to in order to complete it
+func (c *MfaController) Verify(ctx *app.VerifyMfaContext) error {
+	challengeID, err := uuid.FromString(ctx.Payload.Challenge)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterErrorFromString("challenge", ctx.Payload.Challenge, "invalid challenge - not a UUID"))
+	}
+	redirectURL, err := c.app.AuthenticationProviderService().CompleteMultiFactorLogin(ctx, challengeID, ctx.Payload.Code)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	ctx.ResponseData.Header().Set("Cache-Control", "no-cache")
+	return ctx.OK(&app.MFAVerificationResponse{
+		RedirectURL: *redirectURL,
+	})
+}
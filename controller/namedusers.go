@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"time"
+
 	"github.com/fabric8-services/fabric8-auth/app"
 	"github.com/fabric8-services/fabric8-auth/application"
 	appservice "github.com/fabric8-services/fabric8-auth/application/service"
@@ -32,13 +34,25 @@ func NewNamedusersController(service *goa.Service, app application.Application,
 
 // Ban runs the "ban" action.
 func (c *NamedusersController) Ban(ctx *app.BanNamedusersContext) error {
-	isSvcAccount := token.IsSpecificServiceAccount(ctx, token.OnlineRegistration)
+	isSvcAccount := token.IsSpecificServiceAccount(ctx, token.OnlineRegistration, token.Admin)
 	if !isSvcAccount {
 		log.Error(ctx, nil, "the account is not an authorized service account allowed to deprovision users")
 		return jsonapi.JSONErrorResponse(ctx, errors.NewForbiddenError("account not authorized to deprovision users"))
 	}
 
-	identity, err := c.app.UserService().BanUser(ctx, ctx.Username)
+	ban := repository.UserBan{}
+	if ctx.Payload != nil && ctx.Payload.Data != nil && ctx.Payload.Data.Attributes != nil {
+		attributes := ctx.Payload.Data.Attributes
+		if attributes.ReasonCategory != nil {
+			ban.ReasonCategory = *attributes.ReasonCategory
+		}
+		ban.Reason = attributes.Reason
+		if attributes.StartsAt != nil {
+			ban.StartsAt = *attributes.StartsAt
+		}
+		ban.EndsAt = attributes.EndsAt
+	}
+	identity, err := c.app.UserService().BanUserWithReason(ctx, ctx.Username, ban)
 	if err != nil {
 		log.Error(ctx, map[string]interface{}{
 			"err":      err,
@@ -54,6 +68,51 @@ func (c *NamedusersController) Ban(ctx *app.BanNamedusersContext) error {
 	return ctx.OK(ConvertToAppUser(ctx.RequestData, &identity.User, identity, true))
 }
 
+// Bans runs the bans action.
+func (c *NamedusersController) Bans(ctx *app.BansNamedusersContext) error {
+	isSvcAccount := token.IsSpecificServiceAccount(ctx, token.Admin)
+	if !isSvcAccount {
+		log.Error(ctx, nil, "the account is not an authorized service account allowed to list the bans of users")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewForbiddenError("account not authorized to list the bans of users"))
+	}
+
+	bans, err := c.app.UserService().ListUserBans(ctx, ctx.Username)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":      err,
+			"username": ctx.Username,
+		}, "unable to list the bans of the user")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	now := time.Now()
+	result := &app.UserBanList{
+		Data: make([]*app.UserBanData, len(bans)),
+	}
+	for i, ban := range bans {
+		result.Data[i] = ConvertToAppUserBan(ban, now)
+	}
+	return ctx.OK(result)
+}
+
+// ConvertToAppUserBan converts a ban record to its API representation
+func ConvertToAppUserBan(ban repository.UserBan, now time.Time) *app.UserBanData {
+	return &app.UserBanData{
+		ID:   ban.UserBanID.String(),
+		Type: "user-bans",
+		Attributes: &app.UserBanDataAttributes{
+			ReasonCategory: ban.ReasonCategory,
+			Reason:         ban.Reason,
+			Actor:          ban.Actor,
+			StartsAt:       ban.StartsAt,
+			EndsAt:         ban.EndsAt,
+			LiftedAt:       ban.LiftedAt,
+			LiftedBy:       ban.LiftedBy,
+			Active:         ban.Active(now),
+		},
+	}
+}
+
 // Deprovision runs the deprovision action.
 // DEPRECATED: see `Ban`
 func (c *NamedusersController) Deprovision(ctx *app.DeprovisionNamedusersContext) error {
@@ -66,6 +125,35 @@ func (c *NamedusersController) Deprovision(ctx *app.DeprovisionNamedusersContext
 	})
 }
 
+// Export runs the export action.
+func (c *NamedusersController) Export(ctx *app.ExportNamedusersContext) error {
+	isSvcAccount := token.IsSpecificServiceAccount(ctx, token.Admin)
+	if !isSvcAccount {
+		log.Error(ctx, nil, "the account is not an authorized service account allowed to export user data")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewForbiddenError("account not authorized to export user data"))
+	}
+
+	export, err := c.app.UserDataExportService().ExportForUsername(ctx, ctx.Username)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":      err,
+			"username": ctx.Username,
+		}, "unable to export user data")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	log.Info(ctx, map[string]interface{}{
+		"username":            ctx.Username,
+		"user_data_export_id": export.UserDataExportID,
+		"status":              export.Status,
+	}, "user data exported")
+
+	if export.Status == repository.UserDataExportStatusPending {
+		return ctx.Accepted(ConvertToAppUserDataExport(export))
+	}
+	return ctx.OK(ConvertToAppUserDataExport(export))
+}
+
 // Deactivate runs the deactivate action.
 func (c *NamedusersController) Deactivate(ctx *app.DeactivateNamedusersContext) error {
 	isSvcAccount := token.IsSpecificServiceAccount(ctx, token.OnlineRegistration)
@@ -94,3 +182,146 @@ func (c *NamedusersController) Deactivate(ctx *app.DeactivateNamedusersContext)
 
 	return ctx.OK(ConvertToAppUser(ctx.RequestData, &identity.User, identity, true))
 }
+
+// UpdateProfile runs the updateProfile action.
+func (c *NamedusersController) UpdateProfile(ctx *app.UpdateProfileNamedusersContext) error {
+	isSvcAccount := token.IsSpecificServiceAccount(ctx, token.Admin)
+	if !isSvcAccount {
+		log.Error(ctx, nil, "the account is not an authorized service account allowed to update the profile of users")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewForbiddenError("account not authorized to update the profile of users"))
+	}
+
+	identity, err := c.app.UserProfileAttributeService().UpdateProfileAttributes(ctx, ctx.Username, ctx.Payload.Data.Attributes.ProfileAttributes)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":      err,
+			"username": ctx.Username,
+		}, "unable to update the profile of the user")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	attributes, err := c.app.UserProfileAttributeService().ListAttributes(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(ConvertToAppUserWithProfile(ctx.RequestData, &identity.User, identity, true, attributes, repository.UserProfileAttributeVisibilityAdmin))
+}
+
+// Merge runs the merge action.
+func (c *NamedusersController) Merge(ctx *app.MergeNamedusersContext) error {
+	isSvcAccount := token.IsSpecificServiceAccount(ctx, token.Admin)
+	if !isSvcAccount {
+		log.Error(ctx, nil, "the account is not an authorized service account allowed to merge users")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewForbiddenError("account not authorized to merge users"))
+	}
+
+	source := ctx.Payload.Data.Attributes.Source
+	identity, err := c.app.UserMergeService().Merge(ctx, source, ctx.Username)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":             err,
+			"source_username": source,
+			"username":        ctx.Username,
+		}, "unable to merge the users")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(ConvertToAppUser(ctx.RequestData, &identity.User, identity, true))
+}
+
+// Bulk runs the bulk action.
+func (c *NamedusersController) Bulk(ctx *app.BulkNamedusersContext) error {
+	isSvcAccount := token.IsSpecificServiceAccount(ctx, token.OnlineRegistration, token.Admin)
+	if !isSvcAccount {
+		log.Error(ctx, nil, "the account is not an authorized service account allowed to apply bulk operations to users")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewForbiddenError("account not authorized to apply bulk operations to users"))
+	}
+
+	attributes := ctx.Payload.Data.Attributes
+	parameters := map[string]interface{}{}
+	if attributes.ReasonCategory != nil {
+		parameters["reason_category"] = *attributes.ReasonCategory
+	}
+	if attributes.Reason != nil {
+		parameters["reason"] = *attributes.Reason
+	}
+	if attributes.EndsAt != nil {
+		parameters["ends_at"] = attributes.EndsAt.Format(time.RFC3339)
+	}
+	if attributes.Cluster != nil {
+		parameters["cluster"] = *attributes.Cluster
+	}
+	var filter *repository.UserBulkOperationFilter
+	if attributes.Filter != nil {
+		filter = &repository.UserBulkOperationFilter{
+			Cluster:      attributes.Filter.Cluster,
+			FeatureLevel: attributes.Filter.FeatureLevel,
+		}
+	}
+	op, err := c.app.UserBulkOperationService().Submit(ctx, attributes.Operation, parameters, attributes.Usernames, filter)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":       err,
+			"operation": attributes.Operation,
+		}, "unable to submit the bulk operation")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	_, items, err := c.app.UserBulkOperationService().Load(ctx, op.UserBulkOperationID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	// the items are all pending at this point, so they are not part of the response
+	result := ConvertToAppUserBulkOperation(*op, items)
+	result.Data.Attributes.Items = nil
+	return ctx.Accepted(result)
+}
+
+// ShowBulk runs the showBulk action.
+func (c *NamedusersController) ShowBulk(ctx *app.ShowBulkNamedusersContext) error {
+	isSvcAccount := token.IsSpecificServiceAccount(ctx, token.OnlineRegistration, token.Admin)
+	if !isSvcAccount {
+		log.Error(ctx, nil, "the account is not an authorized service account allowed to apply bulk operations to users")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewForbiddenError("account not authorized to apply bulk operations to users"))
+	}
+
+	op, items, err := c.app.UserBulkOperationService().Load(ctx, ctx.ID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(ConvertToAppUserBulkOperation(*op, items))
+}
+
+// ConvertToAppUserBulkOperation converts a bulk operation and its items to its API representation
+func ConvertToAppUserBulkOperation(op repository.UserBulkOperation, items []repository.UserBulkOperationItem) *app.UserBulkOperationSingle {
+	attributes := &app.UserBulkOperationDataAttributes{
+		Operation:   op.Operation,
+		Parameters:  op.Parameters,
+		RequestedBy: op.RequestedBy,
+		Status:      op.Status,
+		CreatedAt:   op.CreatedAt,
+		CompletedAt: op.CompletedAt,
+		Total:       len(items),
+		Items:       make([]*app.UserBulkOperationItem, len(items)),
+	}
+	for i, item := range items {
+		switch item.Status {
+		case repository.UserBulkOperationItemStatusPending:
+			attributes.Pending++
+		case repository.UserBulkOperationItemStatusSucceeded:
+			attributes.Succeeded++
+		case repository.UserBulkOperationItemStatusFailed:
+			attributes.Failed++
+		}
+		attributes.Items[i] = &app.UserBulkOperationItem{
+			Username:    item.Username,
+			Status:      item.Status,
+			Error:       item.Error,
+			CompletedAt: item.CompletedAt,
+		}
+	}
+	return &app.UserBulkOperationSingle{
+		Data: &app.UserBulkOperationData{
+			ID:         op.UserBulkOperationID,
+			Type:       "user-bulk-operations",
+			Attributes: attributes,
+		},
+	}
+}
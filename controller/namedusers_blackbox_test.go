@@ -4,21 +4,26 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/fabric8-services/fabric8-auth/app"
 	"github.com/fabric8-services/fabric8-auth/app/test"
 	"github.com/fabric8-services/fabric8-auth/application/service/factory"
 	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization/token"
 	"github.com/fabric8-services/fabric8-auth/controller"
 	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/gormapplication"
 	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
 	testsupport "github.com/fabric8-services/fabric8-auth/test"
 	testservice "github.com/fabric8-services/fabric8-auth/test/generated/application/service"
+	workerrepo "github.com/fabric8-services/fabric8-auth/worker/repository"
 	uuid "github.com/satori/go.uuid"
 	"gopkg.in/h2non/gock.v1"
 
 	"github.com/goadesign/goa"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -58,7 +63,7 @@ func (s *NamedUsersControllerTestSuite) TestDeprovision() { // for backward comp
 		})
 
 		t.Run("with che failure", func(t *testing.T) {
-			// Fail if che service failed
+			// OK even if che service failed: the deletion will be retried later
 			userToBan := s.Graph.CreateUser()
 			defer gock.Off()
 			gock.New("http://localhost:8091").
@@ -66,7 +71,8 @@ func (s *NamedUsersControllerTestSuite) TestDeprovision() { // for backward comp
 				Reply(500)
 			svc, ctrl := s.SecuredServiceAccountController(testsupport.TestOnlineRegistrationAppIdentity)
 
-			test.DeprovisionNamedusersInternalServerError(t, svc.Context, svc, ctrl, userToBan.Identity().Username)
+			test.DeprovisionNamedusersOK(t, svc.Context, svc, ctrl, userToBan.Identity().Username)
+			s.checkDeprovisioningPending(t, svc.Context, userToBan.IdentityID())
 		})
 	})
 
@@ -133,6 +139,23 @@ func (s *NamedUsersControllerTestSuite) checkDeprovisionOK(t *testing.T) {
 	loadedUser = s.Graph.LoadUser(userToStayIntact.IdentityID())
 	assert.Equal(t, false, loadedUser.User().Banned)
 	testsupport.AssertIdentityEqual(t, userToStayIntact.Identity(), loadedUser.Identity())
+
+	// Check that the user is deleted on Che and Tenant services once the outbox events are delivered
+	_, err := s.Application.OutboxService().DeliverEvents(svc.Context)
+	require.NoError(t, err)
+	assert.True(t, gock.IsDone())
+}
+
+// checkDeprovisioningPending verifies that the deletion of the given identity on Che and Tenant services failed and will be retried
+func (s *NamedUsersControllerTestSuite) checkDeprovisioningPending(t *testing.T, ctx context.Context, identityID uuid.UUID) {
+	_, err := s.Application.OutboxService().DeliverEvents(ctx)
+	require.NoError(t, err)
+	events, err := s.Application.OutboxEvents().ListForIdentity(ctx, identityID)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, repository.OutboxEventTypeDeprovisionUser, events[0].EventType)
+	assert.Equal(t, 1, events[0].Attempts)
+	assert.True(t, events[0].NextAttempt.After(time.Now()))
 }
 
 func (s *NamedUsersControllerTestSuite) TestBan() {
@@ -145,7 +168,7 @@ func (s *NamedUsersControllerTestSuite) TestBan() {
 		})
 
 		t.Run("with che failure", func(t *testing.T) {
-			// Fail if che service failed
+			// OK even if che service failed: the deletion will be retried later
 			userToBan := s.Graph.CreateUser()
 			defer gock.Off()
 			gock.New("http://localhost:8091").
@@ -153,16 +176,27 @@ func (s *NamedUsersControllerTestSuite) TestBan() {
 				Reply(500)
 			svc, ctrl := s.SecuredServiceAccountController(testsupport.TestOnlineRegistrationAppIdentity)
 
-			test.BanNamedusersInternalServerError(t, svc.Context, svc, ctrl, userToBan.Identity().Username)
+			test.BanNamedusersOK(t, svc.Context, svc, ctrl, userToBan.Identity().Username, nil)
+			s.checkDeprovisioningPending(t, svc.Context, userToBan.IdentityID())
 
-			// If now Che is "fixed" and returns 204 then re-banning should work
+			// If now Che is "fixed" and returns 204 then the next delivery should work
 			gock.New("http://localhost:8091").
 				Delete(fmt.Sprintf("/api/user/%s", userToBan.Identity().ID.String())).
 				Reply(204)
 			gock.New("http://localhost:8090").
 				Delete(fmt.Sprintf("/api/tenants/%s", userToBan.Identity().ID.String())).
 				Reply(204)
-			test.BanNamedusersOK(t, svc.Context, svc, ctrl, userToBan.Identity().Username)
+			events, err := s.Application.OutboxEvents().ListForIdentity(svc.Context, userToBan.IdentityID())
+			require.NoError(t, err)
+			require.Len(t, events, 1)
+			err = s.DB.Model(&workerrepo.QueueTask{}).Where("queue_task_id = ?", events[0].OutboxEventID).Update("visible_at", time.Now()).Error
+			require.NoError(t, err)
+			_, err = s.Application.OutboxService().DeliverEvents(svc.Context)
+			require.NoError(t, err)
+			assert.True(t, gock.IsDone())
+			events, err = s.Application.OutboxEvents().ListForIdentity(svc.Context, userToBan.IdentityID())
+			require.NoError(t, err)
+			assert.Empty(t, events)
 		})
 	})
 
@@ -170,7 +204,7 @@ func (s *NamedUsersControllerTestSuite) TestBan() {
 
 		t.Run("not found", func(t *testing.T) {
 			svc, ctrl := s.SecuredServiceAccountController(testsupport.TestOnlineRegistrationAppIdentity)
-			test.BanNamedusersNotFound(t, svc.Context, svc, ctrl, uuid.NewV4().String())
+			test.BanNamedusersNotFound(t, svc.Context, svc, ctrl, uuid.NewV4().String(), nil)
 		})
 
 		t.Run("forbidden", func(t *testing.T) {
@@ -180,20 +214,20 @@ func (s *NamedUsersControllerTestSuite) TestBan() {
 			t.Run("other service", func(t *testing.T) {
 				// Another service account can't deprovision
 				svc, ctrl := s.SecuredServiceAccountController(testsupport.TestTenantIdentity)
-				test.BanNamedusersForbidden(t, svc.Context, svc, ctrl, userToBan.Identity().Username)
+				test.BanNamedusersForbidden(t, svc.Context, svc, ctrl, userToBan.Identity().Username, nil)
 
 			})
 
 			t.Run("missing token", func(t *testing.T) {
 				// If no token present in the context then fails too
 				_, ctrl := s.SecuredServiceAccountController(testsupport.TestOnlineRegistrationAppIdentity)
-				test.BanNamedusersForbidden(t, nil, nil, ctrl, userToBan.Identity().Username)
+				test.BanNamedusersForbidden(t, nil, nil, ctrl, userToBan.Identity().Username, nil)
 			})
 
 			t.Run("regular user", func(t *testing.T) {
 				// Regular user can't deprovision either
 				svc, ctrl := s.SecuredController(*s.Graph.CreateUser().Identity())
-				test.BanNamedusersForbidden(t, svc.Context, svc, ctrl, userToBan.Identity().Username)
+				test.BanNamedusersForbidden(t, svc.Context, svc, ctrl, userToBan.Identity().Username, nil)
 			})
 
 		})
@@ -214,7 +248,7 @@ func (s *NamedUsersControllerTestSuite) checkBanOK(t *testing.T) {
 		Reply(204)
 
 	svc, ctrl := s.SecuredServiceAccountController(testsupport.TestOnlineRegistrationAppIdentity)
-	_, result := test.BanNamedusersOK(t, svc.Context, svc, ctrl, userToBan.Identity().Username)
+	_, result := test.BanNamedusersOK(t, svc.Context, svc, ctrl, userToBan.Identity().Username, nil)
 
 	assert.Equal(t, userToBan.User().ID.String(), *result.Data.Attributes.UserID)
 	assert.Equal(t, userToBan.IdentityID().String(), *result.Data.Attributes.IdentityID)
@@ -229,6 +263,234 @@ func (s *NamedUsersControllerTestSuite) checkBanOK(t *testing.T) {
 	loadedUser = s.Graph.LoadUser(userToStayIntact.IdentityID())
 	assert.Equal(t, false, loadedUser.User().Banned)
 	testsupport.AssertIdentityEqual(t, userToStayIntact.Identity(), loadedUser.Identity())
+
+	// Check that the user is deleted on Che and Tenant services once the outbox events are delivered
+	_, err := s.Application.OutboxService().DeliverEvents(svc.Context)
+	require.NoError(t, err)
+	assert.True(t, gock.IsDone())
+}
+
+func (s *NamedUsersControllerTestSuite) TestBans() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		reasonCategory := repository.UserBanCategorySpam
+		reason := "phishing links"
+		endsAt := time.Now().Add(7 * 24 * time.Hour)
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+		test.BanNamedusersOK(t, svc.Context, svc, ctrl, user.Identity().Username, &app.BanNamedusersPayload{
+			Data: &app.BanUserData{
+				Attributes: &app.BanUserDataAttributes{
+					ReasonCategory: &reasonCategory,
+					Reason:         &reason,
+					EndsAt:         &endsAt,
+				},
+			},
+		})
+		// when
+		_, bans := test.BansNamedusersOK(t, svc.Context, svc, ctrl, user.Identity().Username)
+		// then
+		require.Len(t, bans.Data, 1)
+		assert.Equal(t, repository.UserBanCategorySpam, bans.Data[0].Attributes.ReasonCategory)
+		assert.Equal(t, &reason, bans.Data[0].Attributes.Reason)
+		assert.Equal(t, token.Admin, bans.Data[0].Attributes.Actor)
+		require.NotNil(t, bans.Data[0].Attributes.EndsAt)
+		assert.True(t, bans.Data[0].Attributes.Active)
+	})
+
+	s.T().Run("previous username", func(t *testing.T) {
+		// given a user whose username changed after she was banned
+		user := s.Graph.CreateUser()
+		identity := user.Identity()
+		previousUsername := identity.Username
+		reasonCategory := repository.UserBanCategorySpam
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+		test.BanNamedusersOK(t, svc.Context, svc, ctrl, previousUsername, &app.BanNamedusersPayload{
+			Data: &app.BanUserData{
+				Attributes: &app.BanUserDataAttributes{
+					ReasonCategory: &reasonCategory,
+				},
+			},
+		})
+		err := s.Application.UsernameHistory().Create(s.Ctx, &repository.UsernameHistory{
+			IdentityID:    identity.ID,
+			Username:      previousUsername,
+			ReservedUntil: time.Now().Add(time.Hour),
+		})
+		require.NoError(t, err)
+		identity.Username = "renamed-" + uuid.NewV4().String()
+		err = s.Application.Identities().Save(s.Ctx, identity)
+		require.NoError(t, err)
+		// when
+		_, bans := test.BansNamedusersOK(t, svc.Context, svc, ctrl, previousUsername)
+		// then the previous username resolves to the user
+		require.Len(t, bans.Data, 1)
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("not found", func(t *testing.T) {
+			svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+			test.BansNamedusersNotFound(t, svc.Context, svc, ctrl, uuid.NewV4().String())
+		})
+
+		t.Run("other service", func(t *testing.T) {
+			svc, ctrl := s.SecuredServiceAccountController(testsupport.TestOnlineRegistrationAppIdentity)
+			test.BansNamedusersForbidden(t, svc.Context, svc, ctrl, s.Graph.CreateUser().Identity().Username)
+		})
+
+		t.Run("regular user", func(t *testing.T) {
+			user := s.Graph.CreateUser()
+			svc, ctrl := s.SecuredController(*user.Identity())
+			test.BansNamedusersForbidden(t, svc.Context, svc, ctrl, user.Identity().Username)
+		})
+	})
+}
+
+func (s *NamedUsersControllerTestSuite) TestExport() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+		// when
+		_, export := test.ExportNamedusersOK(t, svc.Context, svc, ctrl, user.Identity().Username)
+		// then
+		assert.Equal(t, repository.UserDataExportStatusReady, export.Data.Attributes.Status)
+		assert.NotEmpty(t, export.Data.Attributes.Content)
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("not found", func(t *testing.T) {
+			svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+			test.ExportNamedusersNotFound(t, svc.Context, svc, ctrl, uuid.NewV4().String())
+		})
+
+		t.Run("other service", func(t *testing.T) {
+			svc, ctrl := s.SecuredServiceAccountController(testsupport.TestOnlineRegistrationAppIdentity)
+			test.ExportNamedusersForbidden(t, svc.Context, svc, ctrl, s.Graph.CreateUser().Identity().Username)
+		})
+
+		t.Run("regular user", func(t *testing.T) {
+			user := s.Graph.CreateUser()
+			svc, ctrl := s.SecuredController(*user.Identity())
+			test.ExportNamedusersForbidden(t, svc.Context, svc, ctrl, user.Identity().Username)
+		})
+	})
+}
+
+func (s *NamedUsersControllerTestSuite) TestUpdateProfile() {
+	// given
+	private, err := s.Application.UserProfileAttributeService().SaveAttribute(s.Ctx, repository.UserProfileAttribute{
+		Name:       newProfileAttributeName("locale"),
+		Type:       repository.UserProfileAttributeTypeString,
+		Visibility: repository.UserProfileAttributeVisibilityPrivate,
+	})
+	require.NoError(s.T(), err)
+	admin, err := s.Application.UserProfileAttributeService().SaveAttribute(s.Ctx, repository.UserProfileAttribute{
+		Name:       newProfileAttributeName("cost_center"),
+		Type:       repository.UserProfileAttributeTypeString,
+		Visibility: repository.UserProfileAttributeVisibilityAdmin,
+	})
+	require.NoError(s.T(), err)
+	payload := func(values map[string]interface{}) *app.UpdateProfileNamedusersPayload {
+		return &app.UpdateProfileNamedusersPayload{
+			Data: &app.UpdateUserProfileData{
+				Attributes: &app.UpdateUserProfileDataAttributes{
+					ProfileAttributes: values,
+				},
+			},
+		}
+	}
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		user := s.Graph.CreateUser()
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+		// when
+		_, result := test.UpdateProfileNamedusersOK(t, svc.Context, svc, ctrl, user.Identity().Username, payload(map[string]interface{}{
+			private.Name: "fr_FR",
+			admin.Name:   "R&D",
+		}))
+		// then
+		assert.Equal(t, map[string]interface{}{private.Name: "fr_FR", admin.Name: "R&D"}, result.Data.Attributes.ProfileAttributes)
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("invalid value", func(t *testing.T) {
+			svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+			test.UpdateProfileNamedusersBadRequest(t, svc.Context, svc, ctrl, s.Graph.CreateUser().Identity().Username, payload(map[string]interface{}{
+				private.Name: 42,
+			}))
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+			test.UpdateProfileNamedusersNotFound(t, svc.Context, svc, ctrl, uuid.NewV4().String(), payload(map[string]interface{}{
+				private.Name: "fr_FR",
+			}))
+		})
+
+		t.Run("regular user", func(t *testing.T) {
+			user := s.Graph.CreateUser()
+			svc, ctrl := s.SecuredController(*user.Identity())
+			test.UpdateProfileNamedusersForbidden(t, svc.Context, svc, ctrl, user.Identity().Username, payload(map[string]interface{}{
+				private.Name: "fr_FR",
+			}))
+		})
+	})
+}
+
+func (s *NamedUsersControllerTestSuite) TestMerge() {
+	payload := func(source string) *app.MergeNamedusersPayload {
+		return &app.MergeNamedusersPayload{
+			Data: &app.MergeUserData{
+				Attributes: &app.MergeUserDataAttributes{
+					Source: source,
+				},
+			},
+		}
+	}
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		source := s.Graph.CreateUser()
+		target := s.Graph.CreateUser()
+		space := s.Graph.CreateSpace().AddAdmin(source)
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+		// when
+		_, result := test.MergeNamedusersOK(t, svc.Context, svc, ctrl, target.Identity().Username, payload(source.Identity().Username))
+		// then
+		assert.Equal(t, target.IdentityID().String(), *result.Data.ID)
+		_, err := s.Application.Users().Load(s.Ctx, source.User().ID)
+		assert.IsType(t, errors.NotFoundError{}, err)
+		roles, err := s.Application.IdentityRoleRepository().FindIdentityRolesByIdentityAndResource(s.Ctx, space.SpaceID(), target.IdentityID())
+		require.NoError(t, err)
+		assert.Len(t, roles, 1)
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("same user", func(t *testing.T) {
+			user := s.Graph.CreateUser()
+			svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+			test.MergeNamedusersBadRequest(t, svc.Context, svc, ctrl, user.Identity().Username, payload(user.Identity().Username))
+		})
+
+		t.Run("source not found", func(t *testing.T) {
+			svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+			test.MergeNamedusersNotFound(t, svc.Context, svc, ctrl, s.Graph.CreateUser().Identity().Username, payload(uuid.NewV4().String()))
+		})
+
+		t.Run("regular user", func(t *testing.T) {
+			user := s.Graph.CreateUser()
+			svc, ctrl := s.SecuredController(*user.Identity())
+			test.MergeNamedusersForbidden(t, svc.Context, svc, ctrl, user.Identity().Username, payload(s.Graph.CreateUser().Identity().Username))
+		})
+	})
 }
 
 func (s *NamedUsersControllerTestSuite) TestDeactivateUser() {
@@ -296,3 +558,71 @@ func (s *NamedUsersControllerTestSuite) TestDeactivateUser() {
 		})
 	})
 }
+
+func (s *NamedUsersControllerTestSuite) TestBulk() {
+
+	newPayload := func(operation string, usernames ...string) *app.CreateUserBulkOperation {
+		return &app.CreateUserBulkOperation{
+			Data: &app.CreateUserBulkOperationData{
+				Attributes: &app.CreateUserBulkOperationDataAttributes{
+					Operation: operation,
+					Usernames: usernames,
+				},
+			},
+		}
+	}
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		user1 := s.Graph.CreateUser()
+		user2 := s.Graph.CreateUser()
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestOnlineRegistrationAppIdentity)
+		payload := newPayload(repository.UserBulkOperationBan, user1.Identity().Username, user2.Identity().Username)
+		reason := "spam campaign"
+		payload.Data.Attributes.Reason = &reason
+		// when
+		_, result := test.BulkNamedusersAccepted(t, svc.Context, svc, ctrl, payload)
+		// then
+		require.NotNil(t, result.Data)
+		assert.Equal(t, repository.UserBulkOperationBan, result.Data.Attributes.Operation)
+		assert.Equal(t, repository.UserBulkOperationStatusRunning, result.Data.Attributes.Status)
+		assert.NotEmpty(t, result.Data.Attributes.RequestedBy)
+		assert.Equal(t, 2, result.Data.Attributes.Total)
+		assert.Equal(t, 2, result.Data.Attributes.Pending)
+		assert.Equal(t, reason, result.Data.Attributes.Parameters["reason"])
+		// the users are banned asynchronously
+		assert.False(t, s.Graph.LoadUser(user1.IdentityID()).User().Banned)
+
+		t.Run("show", func(t *testing.T) {
+			// given
+			svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+			// when
+			_, result := test.ShowBulkNamedusersOK(t, svc.Context, svc, ctrl, result.Data.ID)
+			// then
+			require.Len(t, result.Data.Attributes.Items, 2)
+			for _, item := range result.Data.Attributes.Items {
+				assert.Equal(t, repository.UserBulkOperationItemStatusPending, item.Status)
+			}
+		})
+	})
+
+	s.T().Run("failures", func(t *testing.T) {
+
+		t.Run("bad request", func(t *testing.T) {
+			svc, ctrl := s.SecuredServiceAccountController(testsupport.TestOnlineRegistrationAppIdentity)
+			test.BulkNamedusersBadRequest(t, svc.Context, svc, ctrl, newPayload(repository.UserBulkOperationDeactivate))
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+			test.ShowBulkNamedusersNotFound(t, svc.Context, svc, ctrl, uuid.NewV4())
+		})
+
+		t.Run("forbidden", func(t *testing.T) {
+			user := s.Graph.CreateUser()
+			svc, ctrl := s.SecuredServiceAccountController(testsupport.TestTenantIdentity)
+			test.BulkNamedusersForbidden(t, svc.Context, svc, ctrl, newPayload(repository.UserBulkOperationBan, user.Identity().Username))
+			test.ShowBulkNamedusersForbidden(t, svc.Context, svc, ctrl, uuid.NewV4())
+		})
+	})
+}
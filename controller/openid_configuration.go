@@ -26,6 +26,10 @@ func (c *OpenidConfigurationController) Show(ctx *app.ShowOpenidConfigurationCon
 	userinfoEndpoint := rest.AbsoluteURL(ctx.RequestData, client.ShowUserinfoPath(), nil)
 	logoutEndpoint := rest.AbsoluteURL(ctx.RequestData, client.LogoutLogoutPath(), nil)
 	jwksURI := rest.AbsoluteURL(ctx.RequestData, client.KeysTokenPath(), nil)
+	backchannelLogoutSupported := true
+	// logout tokens have a `sid` claim matching the `session_state` claim of the user tokens when a single session
+	// was terminated, and identify the user with the `sub` claim only when all the sessions were terminated (logout)
+	backchannelLogoutSessionSupported := true
 
 	authOpenIDConfiguration := &app.OpenIDConfiguration{
 		// REQUIRED properties
@@ -56,6 +60,9 @@ func (c *OpenidConfigurationController) Show(ctx *app.ShowOpenidConfigurationCon
 		// client_secre_jwt for authorizatoin_code grant_type
 		TokenEndpointAuthMethodsSupported: []string{"client_secret_post", "client_secret_jwt"},
 		// response_modes_supported
+		// relying parties registered with a back-channel logout URI are notified when a user logs out
+		BackchannelLogoutSupported:        &backchannelLogoutSupported,
+		BackchannelLogoutSessionSupported: &backchannelLogoutSessionSupported,
 	}
 
 	return ctx.OK(authOpenIDConfiguration)
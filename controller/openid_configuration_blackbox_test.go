@@ -39,6 +39,8 @@ func (s *TestOpenIDConfigurationREST) TestShowOpenIDConfiguration() {
 	userInfoEndpoint := "http:///api/userinfo"
 	logoutEndpoint := "http:///api/logout"
 	jwksURI := "http:///api/token/keys"
+	backchannelLogoutSupported := true
+	backchannelLogoutSessionSupported := true
 
 	expectedOpenIDConfiguration := &app.OpenIDConfiguration{
 		Issuer:                            &issuer,
@@ -54,6 +56,8 @@ func (s *TestOpenIDConfigurationREST) TestShowOpenIDConfiguration() {
 		ScopesSupported:                   []string{"openid", "offline_access"},
 		ClaimsSupported:                   []string{"sub", "iss", "auth_time", "name", "given_name", "family_name", "preferred_username", "email"},
 		TokenEndpointAuthMethodsSupported: []string{"client_secret_post", "client_secret_jwt"},
+		BackchannelLogoutSupported:        &backchannelLogoutSupported,
+		BackchannelLogoutSessionSupported: &backchannelLogoutSessionSupported,
 	}
 
 	require.Equal(t, openIDConfiguration, expectedOpenIDConfiguration)
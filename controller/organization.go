@@ -5,6 +5,7 @@ import (
 
 	"github.com/fabric8-services/fabric8-auth/app"
 	"github.com/fabric8-services/fabric8-auth/application"
+	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
 	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
 	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/jsonapi"
@@ -86,6 +87,139 @@ func (c *OrganizationController) List(ctx *app.ListOrganizationContext) error {
 	return ctx.OK(&app.OrganizationArray{Data: convertToAppOrganization(orgs)})
 }
 
+// Update runs the update action.
+func (c *OrganizationController) Update(ctx *app.UpdateOrganizationContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	org, err := c.app.OrganizationService().UpdateOrganization(ctx, *currentUser, ctx.ID, ctx.Payload.Name)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":             err,
+			"organization_id": ctx.ID,
+			"org_name":        ctx.Payload.Name,
+		}, "failed to rename organization")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.OK(convertToAppOrganizationMedia(*org))
+}
+
+// Delete runs the delete action.
+func (c *OrganizationController) Delete(ctx *app.DeleteOrganizationContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	err = c.app.OrganizationService().DeleteOrganization(ctx, *currentUser, ctx.ID, ctx.DetachSpaces)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":             err,
+			"organization_id": ctx.ID,
+		}, "failed to delete organization")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent()
+}
+
+// ListMembers runs the listMembers action.
+func (c *OrganizationController) ListMembers(ctx *app.ListMembersOrganizationContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	members, err := c.app.OrganizationService().ListOrganizationMembers(ctx, *currentUser, ctx.ID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":             err,
+			"organization_id": ctx.ID,
+		}, "failed to list organization members")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	data := make([]*app.UserData, len(members))
+	for i := range members {
+		data[i] = ConvertToAppUser(ctx.RequestData, &members[i].User, &members[i], false).Data
+	}
+	return ctx.OK(&app.UserList{
+		Links: &app.PagingLinks{},
+		Meta:  &app.UserListMeta{TotalCount: len(data)},
+		Data:  data,
+	})
+}
+
+// RemoveMember runs the removeMember action.
+func (c *OrganizationController) RemoveMember(ctx *app.RemoveMemberOrganizationContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	err = c.app.OrganizationService().RemoveOrganizationMember(ctx, *currentUser, ctx.ID, ctx.MemberID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":             err,
+			"organization_id": ctx.ID,
+			"member_id":       ctx.MemberID,
+		}, "failed to remove organization member")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent()
+}
+
+// TransferOwnership runs the transferOwnership action.
+func (c *OrganizationController) TransferOwnership(ctx *app.TransferOwnershipOrganizationContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	err = c.app.OrganizationService().TransferOrganizationOwnership(ctx, *currentUser, ctx.ID, ctx.Payload.OwnerID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":             err,
+			"organization_id": ctx.ID,
+			"owner_id":        ctx.Payload.OwnerID,
+		}, "failed to transfer organization ownership")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent()
+}
+
+// AttachSpace runs the attachSpace action.
+func (c *OrganizationController) AttachSpace(ctx *app.AttachSpaceOrganizationContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	err = c.app.OrganizationService().AttachSpace(ctx, *currentUser, ctx.ID, ctx.Payload.SpaceID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":             err,
+			"organization_id": ctx.ID,
+			"space_id":        ctx.Payload.SpaceID,
+		}, "failed to attach space to organization")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent()
+}
+
+func convertToAppOrganizationMedia(org account.Identity) *app.Organization {
+	return &app.Organization{
+		ID:   org.ID.String(),
+		Name: org.IdentityResource.Name,
+	}
+}
+
 func convertToAppOrganization(orgs []authorization.IdentityAssociation) []*app.OrganizationData {
 	results := []*app.OrganizationData{}
 
@@ -127,3 +127,71 @@ func (rest *TestOrganizationREST) TestListOrganizationUnauthorized() {
 	service, controller := rest.UnsecuredController()
 	test.ListOrganizationUnauthorized(rest.T(), service.Context, service, controller)
 }
+
+func (rest *TestOrganizationREST) TestUpdateOrganization() {
+	g := rest.DBTestSuite.NewTestGraph(rest.T())
+	owner := g.CreateUser()
+	org := g.CreateOrganization(owner)
+
+	rest.T().Run("ok", func(t *testing.T) {
+		service, controller := rest.SecuredController(*owner.Identity())
+		orgName := "Organization-" + uuid.NewV4().String()
+		_, result := test.UpdateOrganizationOK(t, service.Context, service, controller, org.OrganizationID(), &app.UpdateOrganizationPayload{Name: orgName})
+		require.Equal(t, org.OrganizationID().String(), result.ID)
+		require.Equal(t, orgName, result.Name)
+	})
+
+	rest.T().Run("conflict", func(t *testing.T) {
+		service, controller := rest.SecuredController(*owner.Identity())
+		test.UpdateOrganizationConflict(t, service.Context, service, controller, org.OrganizationID(), &app.UpdateOrganizationPayload{Name: g.CreateOrganization().OrganizationName()})
+	})
+
+	rest.T().Run("forbidden", func(t *testing.T) {
+		service, controller := rest.SecuredController(*g.CreateUser().Identity())
+		test.UpdateOrganizationForbidden(t, service.Context, service, controller, org.OrganizationID(), &app.UpdateOrganizationPayload{Name: "Renamed"})
+	})
+}
+
+func (rest *TestOrganizationREST) TestDeleteOrganization() {
+	g := rest.DBTestSuite.NewTestGraph(rest.T())
+	owner := g.CreateUser()
+	service, controller := rest.SecuredController(*owner.Identity())
+
+	rest.T().Run("ok", func(t *testing.T) {
+		org := g.CreateOrganization(owner).AddMember(g.CreateUser())
+		test.DeleteOrganizationNoContent(t, service.Context, service, controller, org.OrganizationID(), false)
+		test.DeleteOrganizationNotFound(t, service.Context, service, controller, org.OrganizationID(), false)
+	})
+
+	rest.T().Run("attached spaces", func(t *testing.T) {
+		org := g.CreateOrganization(owner)
+		test.AttachSpaceOrganizationNoContent(t, service.Context, service, controller, org.OrganizationID(), &app.AttachSpaceOrganizationPayload{SpaceID: g.CreateSpace().AddAdmin(owner).SpaceID()})
+		test.DeleteOrganizationConflict(t, service.Context, service, controller, org.OrganizationID(), false)
+		test.DeleteOrganizationNoContent(t, service.Context, service, controller, org.OrganizationID(), true)
+	})
+}
+
+func (rest *TestOrganizationREST) TestOrganizationMembers() {
+	g := rest.DBTestSuite.NewTestGraph(rest.T())
+	owner := g.CreateUser()
+	member := g.CreateUser()
+	org := g.CreateOrganization(owner).AddMember(member)
+	service, controller := rest.SecuredController(*owner.Identity())
+
+	rest.T().Run("list", func(t *testing.T) {
+		_, result := test.ListMembersOrganizationOK(t, service.Context, service, controller, org.OrganizationID())
+		require.Len(t, result.Data, 1)
+		require.Equal(t, member.IdentityID().String(), *result.Data[0].ID)
+	})
+
+	rest.T().Run("transfer ownership", func(t *testing.T) {
+		other := g.CreateOrganization(owner).AddMember(member)
+		test.TransferOwnershipOrganizationNoContent(t, service.Context, service, controller, other.OrganizationID(), &app.TransferOwnershipOrganizationPayload{OwnerID: member.IdentityID()})
+		test.TransferOwnershipOrganizationForbidden(t, service.Context, service, controller, other.OrganizationID(), &app.TransferOwnershipOrganizationPayload{OwnerID: member.IdentityID()})
+	})
+
+	rest.T().Run("remove", func(t *testing.T) {
+		test.RemoveMemberOrganizationNoContent(t, service.Context, service, controller, org.OrganizationID(), member.IdentityID())
+		test.RemoveMemberOrganizationNotFound(t, service.Context, service, controller, org.OrganizationID(), member.IdentityID())
+	})
+}
@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization/token"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+)
+
+// ProfileattributesController implements the profileattributes resource.
+type ProfileattributesController struct {
+	*goa.Controller
+	app application.Application
+}
+
+// NewProfileattributesController creates a profileattributes controller.
+func NewProfileattributesController(service *goa.Service, app application.Application) *ProfileattributesController {
+	return &ProfileattributesController{
+		Controller: service.NewController("ProfileattributesController"),
+		app:        app,
+	}
+}
+
+// List runs the list action.
+func (c *ProfileattributesController) List(ctx *app.ListProfileattributesContext) error {
+	visibility := repository.UserProfileAttributeVisibilityAdmin
+	if !token.IsSpecificServiceAccount(ctx, token.Admin) {
+		_, err := c.app.UserService().LoadContextIdentityIfNotBanned(ctx)
+		if err != nil {
+			return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+		}
+		visibility = repository.UserProfileAttributeVisibilityPrivate
+	}
+	attributes, err := c.app.UserProfileAttributeService().ListAttributes(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	result := &app.UserProfileAttributeList{
+		Data: []*app.UserProfileAttributeData{},
+	}
+	for _, attribute := range attributes {
+		if attribute.IsVisibleTo(visibility) {
+			result.Data = append(result.Data, ConvertToAppUserProfileAttribute(attribute))
+		}
+	}
+	return ctx.OK(result)
+}
+
+// Save runs the save action.
+func (c *ProfileattributesController) Save(ctx *app.SaveProfileattributesContext) error {
+	if !token.IsSpecificServiceAccount(ctx, token.Admin) {
+		log.Error(ctx, nil, "the account is not an authorized service account allowed to manage the user profile attributes")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewForbiddenError("account not authorized to manage the user profile attributes"))
+	}
+	attributes := ctx.Payload.Data.Attributes
+	attribute := repository.UserProfileAttribute{
+		Name:          ctx.Name,
+		Description:   attributes.Description,
+		Type:          attributes.ValueType,
+		Visibility:    attributes.Visibility,
+		Pattern:       attributes.Pattern,
+		MinLength:     attributes.MinLength,
+		MaxLength:     attributes.MaxLength,
+		AllowedValues: attributes.AllowedValues,
+	}
+	if attributes.Indexable != nil {
+		attribute.Indexable = *attributes.Indexable
+	}
+	if attributes.Minimum != nil {
+		minimum := int64(*attributes.Minimum)
+		attribute.Minimum = &minimum
+	}
+	if attributes.Maximum != nil {
+		maximum := int64(*attributes.Maximum)
+		attribute.Maximum = &maximum
+	}
+	saved, err := c.app.UserProfileAttributeService().SaveAttribute(ctx, attribute)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":  err,
+			"name": ctx.Name,
+		}, "unable to save the user profile attribute")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.UserProfileAttributeSingle{
+		Data: ConvertToAppUserProfileAttribute(*saved),
+	})
+}
+
+// Delete runs the delete action.
+func (c *ProfileattributesController) Delete(ctx *app.DeleteProfileattributesContext) error {
+	if !token.IsSpecificServiceAccount(ctx, token.Admin) {
+		log.Error(ctx, nil, "the account is not an authorized service account allowed to manage the user profile attributes")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewForbiddenError("account not authorized to manage the user profile attributes"))
+	}
+	err := c.app.UserProfileAttributeService().DeleteAttribute(ctx, ctx.Name)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":  err,
+			"name": ctx.Name,
+		}, "unable to delete the user profile attribute")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.NoContent()
+}
+
+// ConvertToAppUserProfileAttribute converts the definition of a user profile attribute to its API representation
+func ConvertToAppUserProfileAttribute(attribute repository.UserProfileAttribute) *app.UserProfileAttributeData {
+	name := attribute.Name
+	indexable := attribute.Indexable
+	result := &app.UserProfileAttributeData{
+		ID:   &name,
+		Type: "user_profile_attributes",
+		Attributes: &app.UserProfileAttributeDataAttributes{
+			Description:   attribute.Description,
+			ValueType:     attribute.Type,
+			Visibility:    attribute.Visibility,
+			Indexable:     &indexable,
+			Pattern:       attribute.Pattern,
+			MinLength:     attribute.MinLength,
+			MaxLength:     attribute.MaxLength,
+			AllowedValues: attribute.AllowedValues,
+		},
+	}
+	if attribute.Minimum != nil {
+		minimum := int(*attribute.Minimum)
+		result.Attributes.Minimum = &minimum
+	}
+	if attribute.Maximum != nil {
+		maximum := int(*attribute.Maximum)
+		result.Attributes.Maximum = &maximum
+	}
+	return result
+}
@@ -0,0 +1,138 @@
+package controller_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/app/test"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/controller"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	testsupport "github.com/fabric8-services/fabric8-auth/test"
+
+	"github.com/goadesign/goa"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestProfileAttributesController(t *testing.T) {
+	suite.Run(t, &ProfileAttributesControllerTestSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+type ProfileAttributesControllerTestSuite struct {
+	gormtestsupport.DBTestSuite
+}
+
+func (s *ProfileAttributesControllerTestSuite) SecuredServiceAccountController(identity repository.Identity) (*goa.Service, *controller.ProfileattributesController) {
+	svc := testsupport.ServiceAsServiceAccountUser("ProfileAttributes-ServiceAccount-Service", identity)
+	return svc, controller.NewProfileattributesController(svc, s.Application)
+}
+
+func (s *ProfileAttributesControllerTestSuite) SecuredController(identity repository.Identity) (*goa.Service, *controller.ProfileattributesController) {
+	svc := testsupport.ServiceAsUser("ProfileAttributes-Service", identity)
+	return svc, controller.NewProfileattributesController(svc, s.Application)
+}
+
+func newProfileAttributeName(prefix string) string {
+	return prefix + "_" + strings.Replace(uuid.NewV4().String(), "-", "", -1)
+}
+
+func newSaveProfileAttributePayload(valueType, visibility string) *app.SaveProfileattributesPayload {
+	return &app.SaveProfileattributesPayload{
+		Data: &app.UserProfileAttributeData{
+			Type: "user_profile_attributes",
+			Attributes: &app.UserProfileAttributeDataAttributes{
+				ValueType:  valueType,
+				Visibility: visibility,
+			},
+		},
+	}
+}
+
+func (s *ProfileAttributesControllerTestSuite) TestSave() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+		name := newProfileAttributeName("timezone")
+		payload := newSaveProfileAttributePayload(repository.UserProfileAttributeTypeString, repository.UserProfileAttributeVisibilityPublic)
+		maxLength := 64
+		indexable := true
+		payload.Data.Attributes.MaxLength = &maxLength
+		payload.Data.Attributes.Indexable = &indexable
+		// when
+		_, result := test.SaveProfileattributesOK(t, svc.Context, svc, ctrl, name, payload)
+		// then
+		require.NotNil(t, result.Data.ID)
+		assert.Equal(t, name, *result.Data.ID)
+		assert.Equal(t, repository.UserProfileAttributeTypeString, result.Data.Attributes.ValueType)
+		require.NotNil(t, result.Data.Attributes.MaxLength)
+		assert.Equal(t, 64, *result.Data.Attributes.MaxLength)
+		assert.True(t, *result.Data.Attributes.Indexable)
+	})
+
+	s.T().Run("bad request", func(t *testing.T) {
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+		payload := newSaveProfileAttributePayload(repository.UserProfileAttributeTypeBoolean, repository.UserProfileAttributeVisibilityPublic)
+		payload.Data.Attributes.AllowedValues = []string{"yes", "no"}
+		test.SaveProfileattributesBadRequest(t, svc.Context, svc, ctrl, newProfileAttributeName("opt_in"), payload)
+	})
+
+	s.T().Run("forbidden", func(t *testing.T) {
+		svc, ctrl := s.SecuredController(*s.Graph.CreateUser().Identity())
+		payload := newSaveProfileAttributePayload(repository.UserProfileAttributeTypeString, repository.UserProfileAttributeVisibilityPublic)
+		test.SaveProfileattributesForbidden(t, svc.Context, svc, ctrl, newProfileAttributeName("locale"), payload)
+	})
+}
+
+func (s *ProfileAttributesControllerTestSuite) TestList() {
+	// given
+	svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+	public := newProfileAttributeName("locale")
+	test.SaveProfileattributesOK(s.T(), svc.Context, svc, ctrl, public,
+		newSaveProfileAttributePayload(repository.UserProfileAttributeTypeString, repository.UserProfileAttributeVisibilityPublic))
+	admin := newProfileAttributeName("cost_center")
+	test.SaveProfileattributesOK(s.T(), svc.Context, svc, ctrl, admin,
+		newSaveProfileAttributePayload(repository.UserProfileAttributeTypeString, repository.UserProfileAttributeVisibilityAdmin))
+
+	names := func(result *app.UserProfileAttributeList) []string {
+		names := []string{}
+		for _, data := range result.Data {
+			names = append(names, *data.ID)
+		}
+		return names
+	}
+
+	s.T().Run("admin", func(t *testing.T) {
+		_, result := test.ListProfileattributesOK(t, svc.Context, svc, ctrl)
+		assert.Contains(t, names(result), public)
+		assert.Contains(t, names(result), admin)
+	})
+
+	s.T().Run("user", func(t *testing.T) {
+		svc, ctrl := s.SecuredController(*s.Graph.CreateUser().Identity())
+		_, result := test.ListProfileattributesOK(t, svc.Context, svc, ctrl)
+		assert.Contains(t, names(result), public)
+		assert.NotContains(t, names(result), admin)
+	})
+}
+
+func (s *ProfileAttributesControllerTestSuite) TestDelete() {
+	svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+
+	s.T().Run("ok", func(t *testing.T) {
+		name := newProfileAttributeName("locale")
+		test.SaveProfileattributesOK(t, svc.Context, svc, ctrl, name,
+			newSaveProfileAttributePayload(repository.UserProfileAttributeTypeString, repository.UserProfileAttributeVisibilityPublic))
+		test.DeleteProfileattributesNoContent(t, svc.Context, svc, ctrl, name)
+		test.DeleteProfileattributesNotFound(t, svc.Context, svc, ctrl, name)
+	})
+
+	s.T().Run("forbidden", func(t *testing.T) {
+		svc, ctrl := s.SecuredController(*s.Graph.CreateUser().Identity())
+		test.DeleteProfileattributesForbidden(t, svc.Context, svc, ctrl, newProfileAttributeName("locale"))
+	})
+}
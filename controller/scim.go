@@ -0,0 +1,431 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/authentication/scim"
+	"github.com/fabric8-services/fabric8-auth/authorization/token"
+	"github.com/fabric8-services/fabric8-auth/client"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/rest"
+	"github.com/fabric8-services/fabric8-auth/sentry"
+
+	"github.com/goadesign/goa"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// scimMaxResults the maximum number of resources returned by the list actions
+const scimMaxResults = 200
+
+// SCIMController implements the scim resource.
+type SCIMController struct {
+	*goa.Controller
+	app application.Application
+}
+
+// NewSCIMController creates a scim controller.
+func NewSCIMController(service *goa.Service, app application.Application) *SCIMController {
+	return &SCIMController{
+		Controller: service.NewController("SCIMController"),
+		app:        app,
+	}
+}
+
+// ServiceProviderConfig runs the serviceProviderConfig action.
+func (c *SCIMController) ServiceProviderConfig(ctx *app.ServiceProviderConfigScimContext) error {
+	if err := checkSCIMProvisioningAccount(ctx); err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	primary := true
+	return ctx.OK(&app.SCIMServiceProviderConfig{
+		Schemas:        []string{scim.SchemaServiceProviderConfig},
+		Patch:          &app.SCIMSupport{Supported: true},
+		Bulk:           &app.SCIMBulkSupport{Supported: false},
+		Filter:         &app.SCIMFilterSupport{Supported: true, MaxResults: scimMaxResults},
+		ChangePassword: &app.SCIMSupport{Supported: false},
+		Sort:           &app.SCIMSupport{Supported: false},
+		Etag:           &app.SCIMSupport{Supported: false},
+		AuthenticationSchemes: []*app.SCIMAuthenticationScheme{
+			{
+				Type:        "oauthbearertoken",
+				Name:        "OAuth Bearer Token",
+				Description: "Authentication with the access token of the scim-provisioning service account",
+				Primary:     &primary,
+			},
+		},
+	})
+}
+
+// ListUsers runs the listUsers action.
+func (c *SCIMController) ListUsers(ctx *app.ListUsersScimContext) error {
+	if err := checkSCIMProvisioningAccount(ctx); err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	identities, total, err := c.app.SCIMService().ListUsers(ctx, scimFilter(ctx.Filter), ctx.StartIndex-1, ctx.Count)
+	if err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	result := &app.SCIMUserList{
+		Schemas:      []string{scim.SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   ctx.StartIndex,
+		ItemsPerPage: len(identities),
+		Resources:    make(app.SCIMUserCollection, len(identities)),
+	}
+	for i, identity := range identities {
+		result.Resources[i] = convertSCIMUser(ctx.RequestData, identity)
+	}
+	return ctx.OK(result)
+}
+
+// ShowUser runs the showUser action.
+func (c *SCIMController) ShowUser(ctx *app.ShowUserScimContext) error {
+	if err := checkSCIMProvisioningAccount(ctx); err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	identity, err := c.app.SCIMService().LoadUser(ctx, ctx.ID)
+	if err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	return ctx.OK(convertSCIMUser(ctx.RequestData, *identity))
+}
+
+// CreateUser runs the createUser action.
+func (c *SCIMController) CreateUser(ctx *app.CreateUserScimContext) error {
+	if err := checkSCIMProvisioningAccount(ctx); err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	p := ctx.Payload
+	identity, err := c.app.SCIMService().CreateUser(ctx, scimUserFromPayload(p.UserName, p.DisplayName, p.Name, p.Emails, p.Active))
+	if err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	result := convertSCIMUser(ctx.RequestData, *identity)
+	ctx.ResponseData.Header().Set("Location", result.Meta.Location)
+	return ctx.Created(result)
+}
+
+// ReplaceUser runs the replaceUser action.
+func (c *SCIMController) ReplaceUser(ctx *app.ReplaceUserScimContext) error {
+	if err := checkSCIMProvisioningAccount(ctx); err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	p := ctx.Payload
+	identity, err := c.app.SCIMService().ReplaceUser(ctx, ctx.ID, scimUserFromPayload(p.UserName, p.DisplayName, p.Name, p.Emails, p.Active))
+	if err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	return ctx.OK(convertSCIMUser(ctx.RequestData, *identity))
+}
+
+// PatchUser runs the patchUser action.
+func (c *SCIMController) PatchUser(ctx *app.PatchUserScimContext) error {
+	if err := checkSCIMProvisioningAccount(ctx); err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	identity, err := c.app.SCIMService().PatchUser(ctx, ctx.ID, scimPatchOperations(ctx.Payload.Operations))
+	if err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	return ctx.OK(convertSCIMUser(ctx.RequestData, *identity))
+}
+
+// DeleteUser runs the deleteUser action.
+func (c *SCIMController) DeleteUser(ctx *app.DeleteUserScimContext) error {
+	if err := checkSCIMProvisioningAccount(ctx); err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	err := c.app.SCIMService().DeleteUser(ctx, ctx.ID)
+	if err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	return ctx.NoContent()
+}
+
+// ListGroups runs the listGroups action.
+func (c *SCIMController) ListGroups(ctx *app.ListGroupsScimContext) error {
+	if err := checkSCIMProvisioningAccount(ctx); err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	groups, total, err := c.app.SCIMService().ListGroups(ctx, scimFilter(ctx.Filter), ctx.StartIndex-1, ctx.Count)
+	if err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	result := &app.SCIMGroupList{
+		Schemas:      []string{scim.SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   ctx.StartIndex,
+		ItemsPerPage: len(groups),
+		Resources:    make(app.SCIMGroupCollection, len(groups)),
+	}
+	for i, group := range groups {
+		result.Resources[i] = convertSCIMGroup(ctx.RequestData, group)
+	}
+	return ctx.OK(result)
+}
+
+// ShowGroup runs the showGroup action.
+func (c *SCIMController) ShowGroup(ctx *app.ShowGroupScimContext) error {
+	if err := checkSCIMProvisioningAccount(ctx); err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	group, err := c.app.SCIMService().LoadGroup(ctx, ctx.ID)
+	if err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	return ctx.OK(convertSCIMGroup(ctx.RequestData, *group))
+}
+
+// CreateGroup runs the createGroup action.
+func (c *SCIMController) CreateGroup(ctx *app.CreateGroupScimContext) error {
+	if err := checkSCIMProvisioningAccount(ctx); err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	group, err := scimGroupFromPayload(ctx.Payload.DisplayName, ctx.Payload.Members)
+	if err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	created, err := c.app.SCIMService().CreateGroup(ctx, *group)
+	if err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	result := convertSCIMGroup(ctx.RequestData, *created)
+	ctx.ResponseData.Header().Set("Location", result.Meta.Location)
+	return ctx.Created(result)
+}
+
+// ReplaceGroup runs the replaceGroup action.
+func (c *SCIMController) ReplaceGroup(ctx *app.ReplaceGroupScimContext) error {
+	if err := checkSCIMProvisioningAccount(ctx); err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	group, err := scimGroupFromPayload(ctx.Payload.DisplayName, ctx.Payload.Members)
+	if err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	replaced, err := c.app.SCIMService().ReplaceGroup(ctx, ctx.ID, *group)
+	if err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	return ctx.OK(convertSCIMGroup(ctx.RequestData, *replaced))
+}
+
+// PatchGroup runs the patchGroup action.
+func (c *SCIMController) PatchGroup(ctx *app.PatchGroupScimContext) error {
+	if err := checkSCIMProvisioningAccount(ctx); err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	group, err := c.app.SCIMService().PatchGroup(ctx, ctx.ID, scimPatchOperations(ctx.Payload.Operations))
+	if err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	return ctx.OK(convertSCIMGroup(ctx.RequestData, *group))
+}
+
+// DeleteGroup runs the deleteGroup action.
+func (c *SCIMController) DeleteGroup(ctx *app.DeleteGroupScimContext) error {
+	if err := checkSCIMProvisioningAccount(ctx); err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	err := c.app.SCIMService().DeleteGroup(ctx, ctx.ID)
+	if err != nil {
+		return scimErrorResponse(ctx, err)
+	}
+	return ctx.NoContent()
+}
+
+// checkSCIMProvisioningAccount returns a forbidden error if the request was not sent by the SCIM provisioning
+// service account
+func checkSCIMProvisioningAccount(ctx context.Context) error {
+	if !token.IsSpecificServiceAccount(ctx, token.SCIMProvisioning) {
+		log.Error(ctx, nil, "the account is not an authorized service account allowed to provision the users")
+		return errors.NewForbiddenError("account not authorized to provision the users")
+	}
+	return nil
+}
+
+func scimFilter(filter *string) string {
+	if filter == nil {
+		return ""
+	}
+	return *filter
+}
+
+// scimUserFromPayload returns the user given in the attributes of a create or replace payload. The users are active
+// unless specified otherwise.
+func scimUserFromPayload(userName string, displayName *string, name *app.SCIMName, emails []*app.SCIMEmail, active *bool) scim.User {
+	user := scim.User{
+		UserName: userName,
+		Active:   active == nil || *active,
+	}
+	var display, formatted, givenName, familyName string
+	if displayName != nil {
+		display = *displayName
+	}
+	if name != nil {
+		if name.Formatted != nil {
+			formatted = *name.Formatted
+		}
+		if name.GivenName != nil {
+			givenName = *name.GivenName
+		}
+		if name.FamilyName != nil {
+			familyName = *name.FamilyName
+		}
+	}
+	user.DisplayName = scim.FullName(display, formatted, givenName, familyName)
+	// keep the primary email address, or the first one if none is marked as primary
+	for _, email := range emails {
+		if email.Primary != nil && *email.Primary {
+			user.Email = email.Value
+			break
+		}
+		if user.Email == "" {
+			user.Email = email.Value
+		}
+	}
+	return user
+}
+
+func scimGroupFromPayload(displayName string, payloadMembers []*app.SCIMMember) (*scim.Group, error) {
+	members := make([]scim.Member, len(payloadMembers))
+	for i, member := range payloadMembers {
+		id, err := uuid.FromString(member.Value)
+		if err != nil {
+			return nil, errors.NewBadParameterErrorFromString("members", member.Value, "the value of the member is not a valid ID")
+		}
+		members[i] = scim.Member{ID: id}
+	}
+	return &scim.Group{DisplayName: displayName, Members: members}, nil
+}
+
+func scimPatchOperations(payloadOperations []*app.SCIMPatchOperation) []scim.PatchOperation {
+	operations := make([]scim.PatchOperation, len(payloadOperations))
+	for i, operation := range payloadOperations {
+		operations[i] = scim.PatchOperation{Op: operation.Op, Value: operation.Value}
+		if operation.Path != nil {
+			operations[i].Path = *operation.Path
+		}
+	}
+	return operations
+}
+
+func convertSCIMUser(request *goa.RequestData, identity account.Identity) *app.SCIMUser {
+	primary := true
+	return &app.SCIMUser{
+		Schemas:     []string{scim.SchemaUser},
+		ID:          identity.ID.String(),
+		UserName:    identity.Username,
+		DisplayName: &identity.User.FullName,
+		Name:        &app.SCIMName{Formatted: &identity.User.FullName},
+		Emails: []*app.SCIMEmail{
+			{Value: identity.User.Email, Primary: &primary},
+		},
+		Active: !identity.User.Banned,
+		Meta: &app.SCIMMeta{
+			ResourceType: "User",
+			Created:      &identity.CreatedAt,
+			LastModified: &identity.User.UpdatedAt,
+			Location:     rest.AbsoluteURL(request, client.ShowUserScimPath(identity.ID), nil),
+		},
+	}
+}
+
+func convertSCIMGroup(request *goa.RequestData, group scim.Group) *app.SCIMGroup {
+	members := make([]*app.SCIMMember, len(group.Members))
+	for i, member := range group.Members {
+		display := member.Display
+		members[i] = &app.SCIMMember{Value: member.ID.String(), Display: &display}
+	}
+	return &app.SCIMGroup{
+		Schemas:     []string{scim.SchemaGroup},
+		ID:          group.ID.String(),
+		DisplayName: group.DisplayName,
+		Members:     members,
+		Meta: &app.SCIMMeta{
+			ResourceType: "Group",
+			Created:      &group.Created,
+			LastModified: &group.LastModified,
+			Location:     rest.AbsoluteURL(request, client.ShowGroupScimPath(group.ID), nil),
+		},
+	}
+}
+
+type scimBadRequest interface {
+	BadRequest(*app.SCIMError) error
+}
+
+type scimNotFound interface {
+	NotFound(*app.SCIMError) error
+}
+
+type scimForbidden interface {
+	Forbidden(*app.SCIMError) error
+}
+
+type scimConflict interface {
+	Conflict(*app.SCIMError) error
+}
+
+type scimInternalServerError interface {
+	context.Context
+	InternalServerError(*app.SCIMError) error
+}
+
+// scimErrorResponse responds with a SCIM error whose status and SCIM type match the given error, the same way as
+// jsonapi.JSONErrorResponse does with JSON-API errors
+func scimErrorResponse(ctx context.Context, err error) error {
+	jsonErr, status := jsonapi.ErrorToJSONAPIError(ctx, err)
+	scimErr := &app.SCIMError{
+		Schemas: []string{scim.SchemaError},
+		Status:  strconv.Itoa(status),
+		Detail:  &jsonErr.Detail,
+	}
+	switch cause := errs.Cause(err).(type) {
+	case errors.BadParameterError:
+		scimType := "invalidValue"
+		switch cause.Parameter() {
+		case "filter":
+			scimType = "invalidFilter"
+		case "path":
+			scimType = "invalidPath"
+		}
+		scimErr.ScimType = &scimType
+	case errors.VersionConflictError, errors.DataConflictError:
+		scimType := "uniqueness"
+		scimErr.ScimType = &scimType
+	}
+
+	switch status {
+	case http.StatusBadRequest:
+		if ctx, ok := ctx.(scimBadRequest); ok {
+			return errs.WithStack(ctx.BadRequest(scimErr))
+		}
+	case http.StatusNotFound:
+		if ctx, ok := ctx.(scimNotFound); ok {
+			return errs.WithStack(ctx.NotFound(scimErr))
+		}
+	case http.StatusForbidden:
+		if ctx, ok := ctx.(scimForbidden); ok {
+			return errs.WithStack(ctx.Forbidden(scimErr))
+		}
+	case http.StatusConflict:
+		if ctx, ok := ctx.(scimConflict); ok {
+			return errs.WithStack(ctx.Conflict(scimErr))
+		}
+	}
+	sentry.Sentry().CaptureError(ctx, err)
+	if ctx, ok := ctx.(scimInternalServerError); ok {
+		scimErr.Status = strconv.Itoa(http.StatusInternalServerError)
+		return errs.WithStack(ctx.InternalServerError(scimErr))
+	}
+	return errs.WithStack(err)
+}
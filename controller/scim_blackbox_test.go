@@ -0,0 +1,219 @@
+package controller_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/app/test"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/authentication/scim"
+	"github.com/fabric8-services/fabric8-auth/controller"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	testsupport "github.com/fabric8-services/fabric8-auth/test"
+
+	"github.com/goadesign/goa"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestSCIMController(t *testing.T) {
+	suite.Run(t, &SCIMControllerTestSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+type SCIMControllerTestSuite struct {
+	gormtestsupport.DBTestSuite
+}
+
+func (s *SCIMControllerTestSuite) SecuredServiceAccountController(identity repository.Identity) (*goa.Service, *controller.SCIMController) {
+	svc := testsupport.ServiceAsServiceAccountUser("SCIM-ServiceAccount-Service", identity)
+	return svc, controller.NewSCIMController(svc, s.Application)
+}
+
+func newCreateUserScimPayload() *app.CreateUserScimPayload {
+	username := fmt.Sprintf("scim-%s", uuid.NewV4())
+	givenName := "John"
+	familyName := "Doe"
+	primary := true
+	return &app.CreateUserScimPayload{
+		Schemas:  []string{scim.SchemaUser},
+		UserName: username,
+		Name:     &app.SCIMName{GivenName: &givenName, FamilyName: &familyName},
+		Emails: []*app.SCIMEmail{
+			{Value: username + "@example.org"},
+			{Value: username + "@example.com", Primary: &primary},
+		},
+	}
+}
+
+func (s *SCIMControllerTestSuite) TestServiceProviderConfig() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestSCIMProvisioningIdentity)
+		_, result := test.ServiceProviderConfigScimOK(t, svc.Context, svc, ctrl)
+		assert.Equal(t, []string{scim.SchemaServiceProviderConfig}, result.Schemas)
+		assert.True(t, result.Patch.Supported)
+		assert.False(t, result.Bulk.Supported)
+		assert.True(t, result.Filter.Supported)
+	})
+
+	s.T().Run("forbidden", func(t *testing.T) {
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+		test.ServiceProviderConfigScimForbidden(t, svc.Context, svc, ctrl)
+	})
+}
+
+func (s *SCIMControllerTestSuite) TestUsers() {
+	svc, ctrl := s.SecuredServiceAccountController(testsupport.TestSCIMProvisioningIdentity)
+
+	s.T().Run("create and show", func(t *testing.T) {
+		// given
+		payload := newCreateUserScimPayload()
+		// when
+		_, created := test.CreateUserScimCreated(t, svc.Context, svc, ctrl, payload)
+		// then
+		assert.Equal(t, []string{scim.SchemaUser}, created.Schemas)
+		assert.Equal(t, payload.UserName, created.UserName)
+		require.NotNil(t, created.DisplayName)
+		assert.Equal(t, "John Doe", *created.DisplayName)
+		require.Len(t, created.Emails, 1)
+		assert.Equal(t, payload.UserName+"@example.com", created.Emails[0].Value)
+		assert.True(t, created.Active)
+		assert.Equal(t, "User", created.Meta.ResourceType)
+		assert.Contains(t, created.Meta.Location, "/scim/v2/Users/"+created.ID)
+
+		_, shown := test.ShowUserScimOK(t, svc.Context, svc, ctrl, uuid.FromStringOrNil(created.ID))
+		assert.Equal(t, created.UserName, shown.UserName)
+	})
+
+	s.T().Run("duplicate", func(t *testing.T) {
+		// given
+		payload := newCreateUserScimPayload()
+		test.CreateUserScimCreated(t, svc.Context, svc, ctrl, payload)
+		// when
+		_, scimErr := test.CreateUserScimConflict(t, svc.Context, svc, ctrl, payload)
+		// then
+		assert.Equal(t, "409", scimErr.Status)
+		require.NotNil(t, scimErr.ScimType)
+		assert.Equal(t, "uniqueness", *scimErr.ScimType)
+	})
+
+	s.T().Run("list with filter", func(t *testing.T) {
+		// given
+		_, created := test.CreateUserScimCreated(t, svc.Context, svc, ctrl, newCreateUserScimPayload())
+		filter := fmt.Sprintf(`userName eq "%s"`, created.UserName)
+		// when
+		_, result := test.ListUsersScimOK(t, svc.Context, svc, ctrl, 100, &filter, 1)
+		// then
+		assert.Equal(t, []string{scim.SchemaListResponse}, result.Schemas)
+		assert.Equal(t, 1, result.TotalResults)
+		assert.Equal(t, 1, result.StartIndex)
+		require.Len(t, result.Resources, 1)
+		assert.Equal(t, created.ID, result.Resources[0].ID)
+	})
+
+	s.T().Run("list with invalid filter", func(t *testing.T) {
+		// given
+		filter := `userName eq`
+		// when
+		_, scimErr := test.ListUsersScimBadRequest(t, svc.Context, svc, ctrl, 100, &filter, 1)
+		// then
+		require.NotNil(t, scimErr.ScimType)
+		assert.Equal(t, "invalidFilter", *scimErr.ScimType)
+	})
+
+	s.T().Run("patch", func(t *testing.T) {
+		// given
+		_, created := test.CreateUserScimCreated(t, svc.Context, svc, ctrl, newCreateUserScimPayload())
+		path := "active"
+		// when
+		_, patched := test.PatchUserScimOK(t, svc.Context, svc, ctrl, uuid.FromStringOrNil(created.ID), &app.PatchUserScimPayload{
+			Schemas:    []string{scim.SchemaPatchOp},
+			Operations: []*app.SCIMPatchOperation{{Op: "replace", Path: &path, Value: false}},
+		})
+		// then
+		assert.False(t, patched.Active)
+	})
+
+	s.T().Run("patch with invalid path", func(t *testing.T) {
+		// given
+		_, created := test.CreateUserScimCreated(t, svc.Context, svc, ctrl, newCreateUserScimPayload())
+		path := "password"
+		// when
+		_, scimErr := test.PatchUserScimBadRequest(t, svc.Context, svc, ctrl, uuid.FromStringOrNil(created.ID), &app.PatchUserScimPayload{
+			Operations: []*app.SCIMPatchOperation{{Op: "replace", Path: &path, Value: "secret"}},
+		})
+		// then
+		require.NotNil(t, scimErr.ScimType)
+		assert.Equal(t, "invalidPath", *scimErr.ScimType)
+	})
+
+	s.T().Run("delete", func(t *testing.T) {
+		// given
+		_, created := test.CreateUserScimCreated(t, svc.Context, svc, ctrl, newCreateUserScimPayload())
+		id := uuid.FromStringOrNil(created.ID)
+		// when
+		test.DeleteUserScimNoContent(t, svc.Context, svc, ctrl, id)
+		// then
+		test.ShowUserScimNotFound(t, svc.Context, svc, ctrl, id)
+	})
+
+	s.T().Run("forbidden", func(t *testing.T) {
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+		test.CreateUserScimForbidden(t, svc.Context, svc, ctrl, newCreateUserScimPayload())
+	})
+}
+
+func (s *SCIMControllerTestSuite) TestGroups() {
+	svc, ctrl := s.SecuredServiceAccountController(testsupport.TestSCIMProvisioningIdentity)
+	_, user := test.CreateUserScimCreated(s.T(), svc.Context, svc, ctrl, newCreateUserScimPayload())
+
+	s.T().Run("create and show", func(t *testing.T) {
+		// given
+		name := "developers-" + uuid.NewV4().String()
+		// when
+		_, created := test.CreateGroupScimCreated(t, svc.Context, svc, ctrl, &app.CreateGroupScimPayload{
+			DisplayName: name,
+			Members:     []*app.SCIMMember{{Value: user.ID}},
+		})
+		// then
+		assert.Equal(t, []string{scim.SchemaGroup}, created.Schemas)
+		assert.Equal(t, name, created.DisplayName)
+		require.Len(t, created.Members, 1)
+		assert.Equal(t, user.ID, created.Members[0].Value)
+		assert.Equal(t, "Group", created.Meta.ResourceType)
+
+		_, shown := test.ShowGroupScimOK(t, svc.Context, svc, ctrl, uuid.FromStringOrNil(created.ID))
+		assert.Equal(t, name, shown.DisplayName)
+	})
+
+	s.T().Run("create with invalid member", func(t *testing.T) {
+		test.CreateGroupScimBadRequest(t, svc.Context, svc, ctrl, &app.CreateGroupScimPayload{
+			DisplayName: "testers-" + uuid.NewV4().String(),
+			Members:     []*app.SCIMMember{{Value: "not-an-id"}},
+		})
+	})
+
+	s.T().Run("patch and delete", func(t *testing.T) {
+		// given
+		_, created := test.CreateGroupScimCreated(t, svc.Context, svc, ctrl, &app.CreateGroupScimPayload{
+			DisplayName: "writers-" + uuid.NewV4().String(),
+			Members:     []*app.SCIMMember{{Value: user.ID}},
+		})
+		id := uuid.FromStringOrNil(created.ID)
+		path := fmt.Sprintf(`members[value eq "%s"]`, user.ID)
+		// when
+		_, patched := test.PatchGroupScimOK(t, svc.Context, svc, ctrl, id, &app.PatchGroupScimPayload{
+			Operations: []*app.SCIMPatchOperation{{Op: "remove", Path: &path}},
+		})
+		// then
+		assert.Empty(t, patched.Members)
+
+		// when
+		test.DeleteGroupScimNoContent(t, svc.Context, svc, ctrl, id)
+		// then
+		test.ShowGroupScimNotFound(t, svc.Context, svc, ctrl, id)
+	})
+}
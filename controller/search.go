@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"net/url"
 	"regexp"
 
 	"github.com/fabric8-services/fabric8-auth/app"
@@ -44,6 +45,22 @@ func (c *SearchController) Users(ctx *app.UsersSearchContext) error {
 		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterError("", "search query should be longer"))
 	}
 
+	profileAttributes, err := c.app.UserProfileAttributeService().SearchFilter(ctx, ctx.FilterProfile)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	attributes, err := c.app.UserProfileAttributeService().ListAttributes(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	filter := account.IdentitySearchFilter{
+		Company:           ctx.FilterCompany,
+		OrganizationID:    ctx.FilterOrganization,
+		Cluster:           ctx.FilterCluster,
+		ProfileAttributes: profileAttributes,
+	}
+
 	var result []account.Identity
 	var count int
 
@@ -62,7 +79,7 @@ func (c *SearchController) Users(ctx *app.UsersSearchContext) error {
 
 	if r.MatchString(q) && len(q) > 1 { // 2 or more characters
 		err = transaction.Transactional(c.app, func(tr transaction.TransactionalResources) error {
-			result, count, err = tr.Identities().Search(ctx, q, offset, searchLimit)
+			result, count, err = tr.Identities().Search(ctx, q, filter, offset, searchLimit)
 			return err
 		})
 		if err != nil {
@@ -108,6 +125,8 @@ func (c *SearchController) Users(ctx *app.UsersSearchContext) error {
 				Email:        &email,
 				EmailPrivate: &ident.User.EmailPrivate,
 				Company:      &ident.User.Company,
+				ProfileAttributes: account.VisibleProfileAttributes(attributes, ident.User.ProfileAttributes,
+					account.UserProfileAttributeVisibilityPublic),
 			},
 		})
 	}
@@ -122,8 +141,27 @@ func (c *SearchController) Users(ctx *app.UsersSearchContext) error {
 		Links: &app.PagingLinks{},
 		Meta:  &app.UserListMeta{TotalCount: count},
 	}
-	setPagingLinks(response.Links, buildAbsoluteURL(ctx.RequestData), len(result), offset, limit, count, "q="+q)
+	setPagingLinks(response.Links, buildAbsoluteURL(ctx.RequestData), len(result), offset, limit, count, searchQuery(q, filter, ctx.FilterProfile))
 
 	return ctx.OK(&response)
 
 }
+
+// searchQuery returns the query string of the search and its filters, to keep them in the paging links
+func searchQuery(q string, filter account.IdentitySearchFilter, profileFilters []string) string {
+	query := url.Values{}
+	query.Set("q", q)
+	if filter.Company != nil {
+		query.Set("filter[company]", *filter.Company)
+	}
+	if filter.OrganizationID != nil {
+		query.Set("filter[organization]", filter.OrganizationID.String())
+	}
+	if filter.Cluster != nil {
+		query.Set("filter[cluster]", *filter.Cluster)
+	}
+	for _, profileFilter := range profileFilters {
+		query.Add("filter[profile]", profileFilter)
+	}
+	return query.Encode()
+}
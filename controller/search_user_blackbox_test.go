@@ -17,6 +17,7 @@ import (
 	testsupport "github.com/fabric8-services/fabric8-auth/test"
 	"github.com/goadesign/goa"
 	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
@@ -82,13 +83,53 @@ func (s *TestSearchUserSearch) TestUsersSearchOK() {
 	}
 
 	for _, tt := range tests {
-		_, result := test.UsersSearchOK(s.T(), s.controller.Context, s.svc, s.controller, tt.userSearchTestArgs.pageLimit, tt.userSearchTestArgs.pageOffset, tt.userSearchTestArgs.q)
+		_, result := test.UsersSearchOK(s.T(), s.controller.Context, s.svc, s.controller, nil, nil, nil, tt.userSearchTestArgs.pageLimit, tt.userSearchTestArgs.pageOffset, tt.userSearchTestArgs.q)
 		for _, userSearchTestExpect := range tt.userSearchTestExpects {
 			userSearchTestExpect(s.T(), tt, result)
 		}
 	}
 }
 
+func (s *TestSearchUserSearch) TestUsersSearchWithFilter() {
+	// given
+	suffix := uuid.NewV4().String()[:8]
+	john := s.Graph.CreateUser("John Doe" + suffix)
+	jane := s.Graph.CreateUser("Jane Doe" + suffix)
+	org := s.Graph.CreateOrganization().AddMember(john)
+	limit := 1
+
+	s.T().Run("by organization", func(t *testing.T) {
+		// when
+		orgID := org.OrganizationID()
+		_, result := test.UsersSearchOK(t, s.controller.Context, s.svc, s.controller, nil, nil, &orgID, &limit, nil, "doe"+suffix)
+		// then
+		require.Len(t, result.Data, 1)
+		assert.Equal(t, john.IdentityID().String(), *result.Data[0].ID)
+		assert.Equal(t, 1, result.Meta.TotalCount)
+	})
+
+	s.T().Run("by cluster", func(t *testing.T) {
+		// when
+		cluster := jane.User().Cluster
+		_, result := test.UsersSearchOK(t, s.controller.Context, s.svc, s.controller, &cluster, nil, nil, &limit, nil, "doe"+suffix)
+		// then
+		require.Len(t, result.Data, 1)
+		assert.Equal(t, jane.IdentityID().String(), *result.Data[0].ID)
+	})
+
+	s.T().Run("filters kept in the paging links", func(t *testing.T) {
+		// when
+		orgID := s.Graph.CreateOrganization().AddMember(john).AddMember(jane).OrganizationID()
+		_, result := test.UsersSearchOK(t, s.controller.Context, s.svc, s.controller, nil, nil, &orgID, &limit, nil, "doe"+suffix)
+		// then
+		require.Len(t, result.Data, 1)
+		assert.Equal(t, 2, result.Meta.TotalCount)
+		require.NotNil(t, result.Links.Next)
+		assert.Contains(t, *result.Links.Next, "page[offset]=1")
+		assert.Contains(t, *result.Links.Next, "filter%5Borganization%5D="+orgID.String())
+	})
+}
+
 func (s *TestSearchUserSearch) TestUsersSearchBadRequest() {
 
 	t := s.T()
@@ -100,7 +141,7 @@ func (s *TestSearchUserSearch) TestUsersSearchBadRequest() {
 	}
 
 	for _, tt := range tests {
-		test.UsersSearchBadRequest(t, s.controller.Context, s.svc, s.controller, tt.userSearchTestArgs.pageLimit, tt.userSearchTestArgs.pageOffset, tt.userSearchTestArgs.q)
+		test.UsersSearchBadRequest(t, s.controller.Context, s.svc, s.controller, nil, nil, nil, tt.userSearchTestArgs.pageLimit, tt.userSearchTestArgs.pageOffset, tt.userSearchTestArgs.q)
 	}
 }
 
@@ -173,14 +214,14 @@ func (s *TestSearchUserSearch) TestEmailPrivateSearchOK() {
 	offset := "0"
 	pageLimit := 1
 	// OK to search by username
-	_, results := test.UsersSearchOK(s.T(), s.controller.Context, s.svc, s.controller, &pageLimit, &offset, randomName)
+	_, results := test.UsersSearchOK(s.T(), s.controller.Context, s.svc, s.controller, nil, nil, nil, &pageLimit, &offset, randomName)
 
 	for _, result := range results.Data {
 		require.Equal(s.T(), "", *result.Attributes.Email)
 	}
 
 	// Empty result if searching by private email
-	_, results = test.UsersSearchOK(s.T(), s.controller.Context, s.svc, s.controller, &pageLimit, &offset, email)
+	_, results = test.UsersSearchOK(s.T(), s.controller.Context, s.svc, s.controller, nil, nil, nil, &pageLimit, &offset, email)
 	require.Empty(s.T(), results.Data)
 }
 
@@ -199,7 +240,7 @@ func (s *TestSearchUserSearch) TestEmailNotPrivateSearchOK() {
 
 	offset := "0"
 	pageLimit := 1
-	_, results := test.UsersSearchOK(s.T(), s.controller.Context, s.svc, s.controller, &pageLimit, &offset, randomName)
+	_, results := test.UsersSearchOK(s.T(), s.controller.Context, s.svc, s.controller, nil, nil, nil, &pageLimit, &offset, randomName)
 
 	for _, result := range results.Data {
 		require.NotEmpty(s.T(), *result.Attributes.Email)
@@ -308,10 +349,10 @@ func (s *TestSearchUserSearch) SecuredController() (*goa.Service, *SearchControl
 
 func (s *TestSearchUserSearch) TestSearchUnauthorized() {
 	_, ctrl := s.UnSecuredController()
-	test.UsersSearchUnauthorized(s.T(), ctrl.Context, ctrl.Service, ctrl, nil, nil, "a")
+	test.UsersSearchUnauthorized(s.T(), ctrl.Context, ctrl.Service, ctrl, nil, nil, nil, nil, nil, "a")
 }
 
 func (s *TestSearchUserSearch) TestSearchUnauthorizedForBannedUser() {
 	_, ctrl := s.UnsecuredControllerBannedUser()
-	test.UsersSearchUnauthorized(s.T(), ctrl.Context, ctrl.Service, ctrl, nil, nil, "a")
+	test.UsersSearchUnauthorized(s.T(), ctrl.Context, ctrl.Service, ctrl, nil, nil, nil, nil, nil, "a")
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/fabric8-services/fabric8-auth/app"
 	"github.com/fabric8-services/fabric8-auth/application"
+	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
 	"github.com/fabric8-services/fabric8-auth/authorization"
 	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
 	"github.com/fabric8-services/fabric8-auth/errors"
@@ -84,6 +85,123 @@ func (c *TeamController) List(ctx *app.ListTeamContext) error {
 	})
 }
 
+// Update runs the update action.
+func (c *TeamController) Update(ctx *app.UpdateTeamContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	team, err := c.app.TeamService().UpdateTeam(ctx, *currentUser, ctx.ID, ctx.Payload.Name)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":       err,
+			"team_id":   ctx.ID,
+			"team_name": ctx.Payload.Name,
+		}, "failed to rename team")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.OK(convertToAppTeam(*team))
+}
+
+// Delete runs the delete action.
+func (c *TeamController) Delete(ctx *app.DeleteTeamContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	err = c.app.TeamService().DeleteTeam(ctx, *currentUser, ctx.ID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":     err,
+			"team_id": ctx.ID,
+		}, "failed to delete team")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent()
+}
+
+// ListMembers runs the listMembers action.
+func (c *TeamController) ListMembers(ctx *app.ListMembersTeamContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	members, err := c.app.TeamService().ListTeamMembers(ctx, *currentUser, ctx.ID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":     err,
+			"team_id": ctx.ID,
+		}, "failed to list team members")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	data := make([]*app.UserData, len(members))
+	for i := range members {
+		data[i] = ConvertToAppUser(ctx.RequestData, &members[i].User, &members[i], false).Data
+	}
+	return ctx.OK(&app.UserList{
+		Links: &app.PagingLinks{},
+		Meta:  &app.UserListMeta{TotalCount: len(data)},
+		Data:  data,
+	})
+}
+
+// RemoveMember runs the removeMember action.
+func (c *TeamController) RemoveMember(ctx *app.RemoveMemberTeamContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	err = c.app.TeamService().RemoveTeamMember(ctx, *currentUser, ctx.ID, ctx.MemberID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":       err,
+			"team_id":   ctx.ID,
+			"member_id": ctx.MemberID,
+		}, "failed to remove team member")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.NoContent()
+}
+
+// Transfer runs the transfer action.
+func (c *TeamController) Transfer(ctx *app.TransferTeamContext) error {
+	currentUser, err := manager.ContextIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError(err.Error()))
+	}
+
+	team, err := c.app.TeamService().TransferTeam(ctx, *currentUser, ctx.ID, ctx.Payload.SpaceID)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err":      err,
+			"team_id":  ctx.ID,
+			"space_id": ctx.Payload.SpaceID,
+		}, "failed to transfer team")
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+
+	return ctx.OK(convertToAppTeam(*team))
+}
+
+func convertToAppTeam(team account.Identity) *app.Team {
+	result := &app.Team{
+		ID:   team.ID.String(),
+		Name: team.IdentityResource.Name,
+	}
+	if team.IdentityResource.ParentResourceID != nil {
+		result.SpaceID = *team.IdentityResource.ParentResourceID
+	}
+	return result
+}
+
 func convertToIdentityTeamData(teams []authorization.IdentityAssociation) []*app.IdentityTeamData {
 	results := []*app.IdentityTeamData{}
 
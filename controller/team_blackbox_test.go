@@ -121,3 +121,83 @@ func (rest *TestTeamREST) TestListTeamUnauthorized() {
 	service, controller := rest.UnsecuredController()
 	test.ListTeamUnauthorized(rest.T(), service.Context, service, controller)
 }
+
+func (rest *TestTeamREST) TestUpdateTeam() {
+	g := rest.DBTestSuite.NewTestGraph(rest.T())
+	admin := g.CreateUser()
+	team := g.CreateTeam(g.CreateSpace().AddAdmin(admin))
+
+	rest.T().Run("ok", func(t *testing.T) {
+		service, controller := rest.SecuredController(*admin.Identity())
+		teamName := "Team-" + uuid.NewV4().String()
+		_, result := test.UpdateTeamOK(t, service.Context, service, controller, team.TeamID(), &app.UpdateTeamPayload{Name: teamName})
+		require.Equal(t, team.TeamID().String(), result.ID)
+		require.Equal(t, teamName, result.Name)
+		require.Equal(t, team.Resource().ParentResourceID, &result.SpaceID)
+	})
+
+	rest.T().Run("forbidden", func(t *testing.T) {
+		service, controller := rest.SecuredController(*g.CreateUser().Identity())
+		test.UpdateTeamForbidden(t, service.Context, service, controller, team.TeamID(), &app.UpdateTeamPayload{Name: "Renamed"})
+	})
+
+	rest.T().Run("not found", func(t *testing.T) {
+		service, controller := rest.SecuredController(*admin.Identity())
+		test.UpdateTeamNotFound(t, service.Context, service, controller, uuid.NewV4(), &app.UpdateTeamPayload{Name: "Renamed"})
+	})
+}
+
+func (rest *TestTeamREST) TestDeleteTeam() {
+	g := rest.DBTestSuite.NewTestGraph(rest.T())
+	admin := g.CreateUser()
+	team := g.CreateTeam(g.CreateSpace().AddAdmin(admin)).AddMember(g.CreateUser())
+
+	rest.T().Run("forbidden", func(t *testing.T) {
+		service, controller := rest.SecuredController(*g.CreateUser().Identity())
+		test.DeleteTeamForbidden(t, service.Context, service, controller, team.TeamID())
+	})
+
+	rest.T().Run("ok", func(t *testing.T) {
+		service, controller := rest.SecuredController(*admin.Identity())
+		test.DeleteTeamNoContent(t, service.Context, service, controller, team.TeamID())
+		test.DeleteTeamNotFound(t, service.Context, service, controller, team.TeamID())
+	})
+}
+
+func (rest *TestTeamREST) TestListAndRemoveTeamMembers() {
+	g := rest.DBTestSuite.NewTestGraph(rest.T())
+	admin := g.CreateUser()
+	member := g.CreateUser()
+	team := g.CreateTeam(g.CreateSpace().AddAdmin(admin)).AddMember(member)
+	service, controller := rest.SecuredController(*admin.Identity())
+
+	rest.T().Run("list", func(t *testing.T) {
+		_, result := test.ListMembersTeamOK(t, service.Context, service, controller, team.TeamID())
+		require.Len(t, result.Data, 1)
+		require.Equal(t, member.IdentityID().String(), *result.Data[0].ID)
+	})
+
+	rest.T().Run("remove", func(t *testing.T) {
+		test.RemoveMemberTeamNoContent(t, service.Context, service, controller, team.TeamID(), member.IdentityID())
+		_, result := test.ListMembersTeamOK(t, service.Context, service, controller, team.TeamID())
+		require.Empty(t, result.Data)
+		test.RemoveMemberTeamNotFound(t, service.Context, service, controller, team.TeamID(), member.IdentityID())
+	})
+}
+
+func (rest *TestTeamREST) TestTransferTeam() {
+	g := rest.DBTestSuite.NewTestGraph(rest.T())
+	admin := g.CreateUser()
+	team := g.CreateTeam(g.CreateSpace().AddAdmin(admin))
+	target := g.CreateSpace().AddAdmin(admin)
+	service, controller := rest.SecuredController(*admin.Identity())
+
+	rest.T().Run("forbidden", func(t *testing.T) {
+		test.TransferTeamForbidden(t, service.Context, service, controller, team.TeamID(), &app.TransferTeamPayload{SpaceID: g.CreateSpace().SpaceID()})
+	})
+
+	rest.T().Run("ok", func(t *testing.T) {
+		_, result := test.TransferTeamOK(t, service.Context, service, controller, team.TeamID(), &app.TransferTeamPayload{SpaceID: target.SpaceID()})
+		require.Equal(t, target.SpaceID(), result.SpaceID)
+	})
+}
@@ -17,11 +17,13 @@ import (
 	"github.com/fabric8-services/fabric8-auth/application"
 	"github.com/fabric8-services/fabric8-auth/application/service"
 	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa"
 	"github.com/fabric8-services/fabric8-auth/authentication/provider"
 	providerrepo "github.com/fabric8-services/fabric8-auth/authentication/provider/repository"
 	"github.com/fabric8-services/fabric8-auth/authorization/token"
 	tokenPkg "github.com/fabric8-services/fabric8-auth/authorization/token"
 	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
+	"github.com/fabric8-services/fabric8-auth/client"
 	. "github.com/fabric8-services/fabric8-auth/controller"
 	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
@@ -685,6 +687,128 @@ func (s *TokenControllerTestSuite) TestExchangeWithCorrectCodeButNotApprovedUser
 	require.NotNil(s.T(), returnedToken.AccessToken)
 }
 
+func (s *TokenControllerTestSuite) TestExchangeWithCorrectCodeButMFAEnrolledUserUnauthorized() {
+	// given
+	svc := testsupport.ServiceAsUser("Token-Service", testsupport.TestIdentity)
+	tokenManager, err := manager.NewTokenManager(s.Configuration)
+	require.Nil(s.T(), err)
+	ctrl := NewTokenController(svc, s.Application, tokenManager, s.Configuration)
+	provider, identity := s.getDummyOAuthIDPProvider(true)
+	testsupport.ActivateDummyIdentityProviderFactory(s, provider)
+	secret, _, err := s.Application.MFAService().EnrollTOTP(s.Ctx, identity.ID)
+	require.NoError(s.T(), err)
+	code, err := mfa.GenerateTOTPCode(secret, mfa.TOTPStep(time.Now()))
+	require.NoError(s.T(), err)
+	_, err = s.Application.MFAService().ConfirmTOTP(s.Ctx, identity.ID, code)
+	require.NoError(s.T(), err)
+
+	// when the code did not go through the authorize callback, which verifies the second factor
+	authorizationCode := "XYZ"
+	test.ExchangeTokenUnauthorized(s.T(), svc.Context, svc, ctrl, &app.TokenExchange{GrantType: "authorization_code", ClientID: s.Configuration.GetPublicOAuthClientID(), Code: &authorizationCode})
+
+	// then no token is issued
+	tokens, err := s.Application.TokenRepository().ListForIdentity(s.Ctx, identity.ID)
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), tokens)
+}
+
+func (s *TokenControllerTestSuite) TestExchangeWithCodeOfMFAEnrolledUser() {
+	// given
+	svc := testsupport.ServiceAsUser("Token-Service", testsupport.TestIdentity)
+	tokenManager, err := manager.NewTokenManager(s.Configuration)
+	require.Nil(s.T(), err)
+	ctrl := NewTokenController(svc, s.Application, tokenManager, s.Configuration)
+	callbackURL := "https://auth.openshift.io" + client.CallbackAuthorizePath()
+
+	// authorizeCallback simulates the redirection from the oauth provider to the authorize callback of a new login of
+	// the given user, and returns the challenge to verify before the given code is released
+	authorizeCallback := func(identity account.Identity, code string) uuid.UUID {
+		state := uuid.NewV4().String()
+		err := s.Application.AuthenticationProviderService().SaveReferrer(s.Ctx, state, "https://openshift.io/somepath", nil, s.Configuration.GetValidRedirectURLs())
+		require.NoError(s.T(), err)
+		redirectTo, err := s.Application.AuthenticationProviderService().AuthorizeCallback(s.Ctx, state, code, callbackURL)
+		require.NoError(s.T(), err)
+		require.True(s.T(), strings.HasPrefix(*redirectTo, s.Configuration.GetMFAVerificationURL()+"?challenge="), *redirectTo)
+		redirectURL, err := url.Parse(*redirectTo)
+		require.NoError(s.T(), err)
+		challengeID, err := uuid.FromString(redirectURL.Query().Get("challenge"))
+		require.NoError(s.T(), err)
+		return challengeID
+	}
+
+	enroll := func() (account.Identity, []string) {
+		provider, identity := s.getDummyOAuthIDPProvider(true)
+		testsupport.ActivateDummyIdentityProviderFactory(s, provider)
+		secret, _, err := s.Application.MFAService().EnrollTOTP(s.Ctx, identity.ID)
+		require.NoError(s.T(), err)
+		code, err := mfa.GenerateTOTPCode(secret, mfa.TOTPStep(time.Now()))
+		require.NoError(s.T(), err)
+		recoveryCodes, err := s.Application.MFAService().ConfirmTOTP(s.Ctx, identity.ID, code)
+		require.NoError(s.T(), err)
+		return identity, recoveryCodes
+	}
+
+	s.Run("released once the second factor is verified", func() {
+		// given
+		identity, recoveryCodes := enroll()
+		authorizationCode := uuid.NewV4().String()
+		challengeID := authorizeCallback(identity, authorizationCode)
+
+		// when
+		redirectTo, err := s.Application.AuthenticationProviderService().CompleteMultiFactorLogin(
+			manager.ContextWithTokenManager(s.Ctx, testtoken.TokenManager), challengeID, recoveryCodes[0])
+
+		// then the client gets the code, and exchanges it for a multi-factor token
+		require.NoError(s.T(), err)
+		redirectURL, err := url.Parse(*redirectTo)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "openshift.io", redirectURL.Host)
+		assert.Equal(s.T(), authorizationCode, redirectURL.Query().Get("code"))
+		_, returnedToken := test.ExchangeTokenOK(s.T(), svc.Context, svc, ctrl, &app.TokenExchange{GrantType: "authorization_code", ClientID: s.Configuration.GetPublicOAuthClientID(), Code: &authorizationCode})
+		require.NotNil(s.T(), returnedToken.AccessToken)
+		claims, err := testtoken.TokenManager.ParseToken(s.Ctx, *returnedToken.AccessToken)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), identity.ID.String(), claims.Subject)
+		assert.ElementsMatch(s.T(), []string{tokenPkg.AuthMethodOTP, tokenPkg.AuthMethodMFA}, claims.AuthMethods)
+		// and the code can only be exchanged once
+		test.ExchangeTokenUnauthorized(s.T(), svc.Context, svc, ctrl, &app.TokenExchange{GrantType: "authorization_code", ClientID: s.Configuration.GetPublicOAuthClientID(), Code: &authorizationCode})
+	})
+
+	s.Run("not released before the second factor is verified", func() {
+		// given
+		identity, recoveryCodes := enroll()
+		authorizationCode := uuid.NewV4().String()
+		challengeID := authorizeCallback(identity, authorizationCode)
+
+		// when
+		test.ExchangeTokenUnauthorized(s.T(), svc.Context, svc, ctrl, &app.TokenExchange{GrantType: "authorization_code", ClientID: s.Configuration.GetPublicOAuthClientID(), Code: &authorizationCode})
+
+		// then no token is issued, and the code is revoked
+		tokens, err := s.Application.TokenRepository().ListForIdentity(s.Ctx, identity.ID)
+		require.NoError(s.T(), err)
+		assert.Empty(s.T(), tokens)
+		_, err = s.Application.AuthenticationProviderService().CompleteMultiFactorLogin(
+			manager.ContextWithTokenManager(s.Ctx, testtoken.TokenManager), challengeID, recoveryCodes[0])
+		require.Error(s.T(), err)
+		assert.IsType(s.T(), errors.UnauthorizedError{}, err)
+	})
+
+	s.Run("first factor only does not cancel the account deletion", func() {
+		// given
+		identity, _ := enroll()
+		_, err := s.Application.UserService().RequestDeletion(s.Ctx, identity.ID)
+		require.NoError(s.T(), err)
+
+		// when
+		authorizeCallback(identity, uuid.NewV4().String())
+
+		// then
+		loaded, err := s.Application.Identities().Load(s.Ctx, identity.ID)
+		require.NoError(s.T(), err)
+		assert.NotNil(s.T(), loaded.DeletionRequested)
+	})
+}
+
 func newOAuthMockService(t *testing.T, identity account.Identity) (service.AuthenticationProviderService, string, string) {
 	authProviderService := testservice.NewAuthenticationProviderServiceMock(t)
 	identity.User.FullName = "Test User" // origin 'fullname' will be updated by the token returned by the dummyIDPOAuthProvider.Profile function call
@@ -55,7 +55,7 @@ func (s *TokenStorageTestSuite) SetupSuite() {
 func (s *TokenStorageTestSuite) SetupTest() {
 	s.DBTestSuite.SetupTest()
 	s.identityRepository = account.NewIdentityRepository(s.DB)
-	s.externalTokenRepository = tokenrepo.NewExternalTokenRepository(s.DB)
+	s.externalTokenRepository = s.Application.ExternalTokens()
 	s.userRepository = account.NewUserRepository(s.DB)
 }
 
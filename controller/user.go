@@ -6,14 +6,19 @@ import (
 	"github.com/fabric8-services/fabric8-auth/app"
 	"github.com/fabric8-services/fabric8-auth/application"
 	appservice "github.com/fabric8-services/fabric8-auth/application/service"
+	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
 	"github.com/fabric8-services/fabric8-auth/authorization"
 	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
+	tokenrepo "github.com/fabric8-services/fabric8-auth/authorization/token/repository"
 	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/jsonapi"
 	"github.com/fabric8-services/fabric8-auth/log"
 	"github.com/fabric8-services/fabric8-auth/rest"
 
+	"github.com/dgrijalva/jwt-go"
 	"github.com/goadesign/goa"
+	goajwt "github.com/goadesign/goa/middleware/security/jwt"
+	uuid "github.com/satori/go.uuid"
 )
 
 // UserController implements the user resource.
@@ -60,7 +65,12 @@ func (c *UserController) Show(ctx *app.ShowUserContext) error {
 	if user.Banned {
 		ctx.ResponseData.Header().Add("Access-Control-Expose-Headers", "WWW-Authenticate")
 		ctx.ResponseData.Header().Set("WWW-Authenticate", "DEPROVISIONED description=\"Account has been banned\"")
-		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError("Account has been banned"))
+		return jsonapi.JSONErrorResponse(ctx, c.app.UserService().BanError(ctx, *user, "Account has been banned"))
+	}
+
+	attributes, err := c.app.UserProfileAttributeService().ListAttributes(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
 	}
 
 	return ctx.ConditionalRequest(*user, c.config.GetCacheControlUser, func() error {
@@ -70,7 +80,7 @@ func (c *UserController) Show(ctx *app.ShowUserContext) error {
 				c.tenantService.Init(ctx)
 			}(ctx)
 		}
-		return ctx.OK(ConvertToAppUser(ctx.RequestData, user, identity, true))
+		return ctx.OK(ConvertToAppUserWithProfile(ctx.RequestData, user, identity, true, attributes, account.UserProfileAttributeVisibilityPrivate))
 	})
 }
 
@@ -97,6 +107,133 @@ func (c *UserController) ListResources(ctx *app.ListResourcesUserContext) error
 	return ctx.OK(convertToUserResources(ctx.RequestData, resourceType, resourceIDs))
 }
 
+// ListSessions returns the active sessions of the current user
+func (c *UserController) ListSessions(ctx *app.ListSessionsUserContext) error {
+	identityID, err := c.tokenManager.Locate(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "Bad Token")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError("bad or missing token"))
+	}
+	sessions, err := c.app.TokenService().ListSessions(ctx, identityID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(convertToUserSessions(currentSessionID(ctx), sessions))
+}
+
+// TerminateSession terminates the given session of the current user
+func (c *UserController) TerminateSession(ctx *app.TerminateSessionUserContext) error {
+	identityID, err := c.tokenManager.Locate(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "Bad Token")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError("bad or missing token"))
+	}
+	sessionID, err := uuid.FromString(ctx.SessionID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterErrorFromString("sessionID", ctx.SessionID, "invalid session ID - not a UUID"))
+	}
+	err = c.app.TokenService().TerminateSession(ctx, identityID, sessionID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.NoContent()
+}
+
+// Export returns the export of the personal data of the current user
+func (c *UserController) Export(ctx *app.ExportUserContext) error {
+	identityID, err := c.tokenManager.Locate(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "Bad Token")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError("bad or missing token"))
+	}
+	export, err := c.app.UserDataExportService().ExportForIdentity(ctx, identityID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	if export.Status == account.UserDataExportStatusPending {
+		return ctx.Accepted(ConvertToAppUserDataExport(export))
+	}
+	return ctx.OK(ConvertToAppUserDataExport(export))
+}
+
+// Delete requests the deletion of the account of the current user
+func (c *UserController) Delete(ctx *app.DeleteUserContext) error {
+	identityID, err := c.tokenManager.Locate(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "Bad Token")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError("bad or missing token"))
+	}
+	_, err = c.app.UserService().RequestDeletion(ctx, identityID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.Accepted()
+}
+
+// ConvertToAppUserDataExport converts an export of the personal data of a user into its API representation
+func ConvertToAppUserDataExport(export *account.UserDataExport) *app.UserDataExport {
+	result := &app.UserDataExport{
+		Data: &app.UserDataExportData{
+			ID:   export.UserDataExportID.String(),
+			Type: "user-data-exports",
+			Attributes: &app.UserDataExportAttributes{
+				Status:      export.Status,
+				CreatedAt:   export.CreatedAt,
+				CompletedAt: export.CompletedAt,
+				ExpiresAt:   export.ExpiresAt,
+			},
+		},
+	}
+	if export.Status == account.UserDataExportStatusReady {
+		result.Data.Attributes.Content = export.Content
+	}
+	return result
+}
+
+// currentSessionID returns the session state of the token used in the current request, or an empty string if the
+// token has no session state
+func currentSessionID(ctx context.Context) string {
+	jwtToken := goajwt.ContextJWT(ctx)
+	if jwtToken == nil {
+		return ""
+	}
+	if claims, ok := jwtToken.Claims.(jwt.MapClaims); ok {
+		if sessionState, ok := claims["session_state"].(string); ok {
+			return sessionState
+		}
+	}
+	return ""
+}
+
+// convertToUserSessions converts the given sessions, flagging the one matching the current session ID
+func convertToUserSessions(currentSessionID string, sessions []tokenrepo.Session) *app.UserSessionArray {
+	data := make([]*app.UserSessionData, 0, len(sessions))
+	for _, s := range sessions {
+		userAgent := s.UserAgent
+		ipAddress := s.IPAddress
+		data = append(data, &app.UserSessionData{
+			SessionID: s.SessionID.String(),
+			ClientID:  s.ClientID,
+			UserAgent: &userAgent,
+			IPAddress: &ipAddress,
+			CreatedAt: s.CreatedAt,
+			LastUsed:  s.LastUsed,
+			Current:   s.SessionID.String() == currentSessionID,
+		})
+	}
+	return &app.UserSessionArray{
+		Data: data,
+	}
+}
+
 // convertToUserResources converts a list of resources to which the user has a role
 func convertToUserResources(request *goa.RequestData, resourceType string, resourceIDs []string) *app.UserResourcesList {
 	data := make([]*app.UserResourceData, 0)
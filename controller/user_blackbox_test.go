@@ -14,7 +14,10 @@ import (
 	"github.com/fabric8-services/fabric8-auth/app"
 	"github.com/fabric8-services/fabric8-auth/app/test"
 	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	authtoken "github.com/fabric8-services/fabric8-auth/authorization/token"
+	tokenrepo "github.com/fabric8-services/fabric8-auth/authorization/token/repository"
 	. "github.com/fabric8-services/fabric8-auth/controller"
+	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
 	"github.com/fabric8-services/fabric8-auth/resource"
 	testsupport "github.com/fabric8-services/fabric8-auth/test"
@@ -283,6 +286,187 @@ func (s *UserControllerTestSuite) TestListUserResources() {
 
 }
 
+func (s *UserControllerTestSuite) TestListSessions() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		g := s.NewTestGraph(t)
+		user := g.CreateUser()
+		current := s.createSession(t, user.IdentityID(), time.Now())
+		other := s.createSession(t, user.IdentityID(), time.Now().Add(-time.Hour))
+		s.createSession(t, g.CreateUser().IdentityID(), time.Now()) // session of another user
+		svc, userCtrl := s.SecuredController(*user.Identity())
+		jwt.ContextJWT(svc.Context).Claims.(token.MapClaims)["session_state"] = current.SessionID.String()
+		// when
+		_, sessions := test.ListSessionsUserOK(t, svc.Context, svc, userCtrl)
+		// then the least recently used session comes first
+		require.Len(t, sessions.Data, 2)
+		assert.Equal(t, other.SessionID.String(), sessions.Data[0].SessionID)
+		assert.False(t, sessions.Data[0].Current)
+		assert.Equal(t, current.SessionID.String(), sessions.Data[1].SessionID)
+		assert.True(t, sessions.Data[1].Current)
+		assert.Equal(t, current.ClientID, sessions.Data[1].ClientID)
+		require.NotNil(t, sessions.Data[1].UserAgent)
+		assert.Equal(t, current.UserAgent, *sessions.Data[1].UserAgent)
+		require.NotNil(t, sessions.Data[1].IPAddress)
+		assert.Equal(t, current.IPAddress, *sessions.Data[1].IPAddress)
+	})
+
+	s.T().Run("no session", func(t *testing.T) {
+		// given
+		g := s.NewTestGraph(t)
+		user := g.CreateUser()
+		svc, userCtrl := s.SecuredController(*user.Identity())
+		// when
+		_, sessions := test.ListSessionsUserOK(t, svc.Context, svc, userCtrl)
+		// then
+		assert.Empty(t, sessions.Data)
+	})
+
+	s.T().Run("unauthorized", func(t *testing.T) {
+		// given
+		svc, userCtrl := s.UnsecuredController()
+		// when/then
+		test.ListSessionsUserUnauthorized(t, svc.Context, svc, userCtrl)
+	})
+}
+
+func (s *UserControllerTestSuite) TestTerminateSession() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		g := s.NewTestGraph(t)
+		user := g.CreateUser()
+		session := s.createSession(t, user.IdentityID(), time.Now())
+		tkn := s.createSessionToken(t, user.IdentityID(), session.SessionID)
+		svc, userCtrl := s.SecuredController(*user.Identity())
+		// when
+		test.TerminateSessionUserNoContent(t, svc.Context, svc, userCtrl, session.SessionID.String())
+		// then
+		_, err := s.Application.SessionRepository().Load(svc.Context, session.SessionID)
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, err)
+		loaded, err := s.Application.TokenRepository().Load(svc.Context, tkn.TokenID)
+		require.NoError(t, err)
+		assert.True(t, loaded.HasStatus(authtoken.TOKEN_STATUS_LOGGED_OUT))
+	})
+
+	s.T().Run("not found", func(t *testing.T) {
+
+		t.Run("unknown session", func(t *testing.T) {
+			// given
+			g := s.NewTestGraph(t)
+			user := g.CreateUser()
+			svc, userCtrl := s.SecuredController(*user.Identity())
+			// when/then
+			test.TerminateSessionUserNotFound(t, svc.Context, svc, userCtrl, uuid.NewV4().String())
+		})
+
+		t.Run("session of another user", func(t *testing.T) {
+			// given
+			g := s.NewTestGraph(t)
+			user := g.CreateUser()
+			session := s.createSession(t, g.CreateUser().IdentityID(), time.Now())
+			svc, userCtrl := s.SecuredController(*user.Identity())
+			// when
+			test.TerminateSessionUserNotFound(t, svc.Context, svc, userCtrl, session.SessionID.String())
+			// then the session was not terminated
+			_, err := s.Application.SessionRepository().Load(svc.Context, session.SessionID)
+			require.NoError(t, err)
+		})
+	})
+
+	s.T().Run("bad request", func(t *testing.T) {
+		// given
+		g := s.NewTestGraph(t)
+		user := g.CreateUser()
+		svc, userCtrl := s.SecuredController(*user.Identity())
+		// when/then
+		test.TerminateSessionUserBadRequest(t, svc.Context, svc, userCtrl, "foo")
+	})
+
+	s.T().Run("unauthorized", func(t *testing.T) {
+		// given
+		svc, userCtrl := s.UnsecuredController()
+		// when/then
+		test.TerminateSessionUserUnauthorized(t, svc.Context, svc, userCtrl, uuid.NewV4().String())
+	})
+}
+
+func (s *UserControllerTestSuite) TestExport() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		g := s.NewTestGraph(t)
+		user := g.CreateUser()
+		svc, userCtrl := s.SecuredController(*user.Identity())
+		// when
+		_, export := test.ExportUserOK(t, svc.Context, svc, userCtrl)
+		// then
+		assert.Equal(t, "user-data-exports", export.Data.Type)
+		assert.Equal(t, account.UserDataExportStatusReady, export.Data.Attributes.Status)
+		assert.NotNil(t, export.Data.Attributes.ExpiresAt)
+		assert.NotEmpty(t, export.Data.Attributes.Content)
+	})
+
+	s.T().Run("unauthorized", func(t *testing.T) {
+		// given
+		svc, userCtrl := s.UnsecuredController()
+		// when/then
+		test.ExportUserUnauthorized(t, svc.Context, svc, userCtrl)
+	})
+}
+
+func (s *UserControllerTestSuite) TestDelete() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		g := s.NewTestGraph(t)
+		user := g.CreateUser()
+		svc, userCtrl := s.SecuredController(*user.Identity())
+		// when
+		test.DeleteUserAccepted(t, svc.Context, svc, userCtrl)
+		// then
+		identity, err := s.Application.Identities().Load(svc.Context, user.IdentityID())
+		require.NoError(t, err)
+		assert.NotNil(t, identity.DeletionRequested)
+		assert.NotNil(t, identity.DeactivationScheduled)
+	})
+
+	s.T().Run("unauthorized", func(t *testing.T) {
+		// given
+		svc, userCtrl := s.UnsecuredController()
+		// when/then
+		test.DeleteUserUnauthorized(t, svc.Context, svc, userCtrl)
+	})
+}
+
+func (s *UserControllerTestSuite) createSession(t *testing.T, identityID uuid.UUID, lastUsed time.Time) *tokenrepo.Session {
+	session := &tokenrepo.Session{
+		IdentityID: identityID,
+		ClientID:   "740650a2-9c44-4db5-b067-a3d1b2cd2d01",
+		UserAgent:  "Mozilla/5.0",
+		IPAddress:  "10.0.0.1",
+		LastUsed:   lastUsed,
+	}
+	err := s.Application.SessionRepository().Create(context.Background(), session)
+	require.NoError(t, err)
+	return session
+}
+
+func (s *UserControllerTestSuite) createSessionToken(t *testing.T, identityID uuid.UUID, sessionID uuid.UUID) *tokenrepo.Token {
+	tkn := &tokenrepo.Token{
+		TokenID:    uuid.NewV4(),
+		IdentityID: identityID,
+		SessionID:  &sessionID,
+		TokenType:  authtoken.TOKEN_TYPE_ACCESS,
+		ExpiryTime: time.Now().Add(time.Hour),
+	}
+	err := s.Application.TokenRepository().Create(context.Background(), tkn)
+	require.NoError(t, err)
+	return tkn
+}
+
 func (s *UserControllerTestSuite) checkPrivateEmailVisible(t *testing.T, emailPrivate bool) {
 	testUser := account.User{
 		ID:           uuid.NewV4(),
@@ -45,7 +45,7 @@ func (c *UserinfoController) Show(ctx *app.ShowUserinfoContext) error {
 	if user.Banned {
 		ctx.ResponseData.Header().Add("Access-Control-Expose-Headers", "WWW-Authenticate")
 		ctx.ResponseData.Header().Set("WWW-Authenticate", "DEPROVISIONED description=\"Account has been banned\"")
-		return jsonapi.JSONErrorResponse(ctx, errors.NewUnauthorizedError("Account has been banned"))
+		return jsonapi.JSONErrorResponse(ctx, c.app.UserService().BanError(ctx, *user, "Account has been banned"))
 	}
 
 	givenName, familyName := account.SplitFullName(user.FullName)
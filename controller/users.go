@@ -59,6 +59,10 @@ func NewUsersController(service *goa.Service, app application.Application, confi
 func (c *UsersController) Show(ctx *app.ShowUsersContext) error {
 	tenantSA := token.IsSpecificServiceAccount(ctx, token.Tenant)
 	isServiceAccount := tenantSA || token.IsSpecificServiceAccount(ctx, token.Notification)
+	visibility := accountrepo.UserProfileAttributeVisibilityPublic
+	if token.IsSpecificServiceAccount(ctx, token.Admin) {
+		visibility = accountrepo.UserProfileAttributeVisibilityAdmin
+	}
 
 	var identity *accountrepo.Identity
 	err := transaction.Transactional(c.app, func(tr transaction.TransactionalResources) error {
@@ -84,8 +88,12 @@ func (c *UsersController) Show(ctx *app.ShowUsersContext) error {
 	if err != nil {
 		return jsonapi.JSONErrorResponse(ctx, err)
 	}
+	attributes, err := c.app.UserProfileAttributeService().ListAttributes(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
 	return ctx.ConditionalRequest(identity.User, c.config.GetCacheControlUser, func() error {
-		return ctx.OK(ConvertToAppUser(ctx.RequestData, &identity.User, identity, isServiceAccount))
+		return ctx.OK(ConvertToAppUserWithProfile(ctx.RequestData, &identity.User, identity, isServiceAccount, attributes, visibility))
 	})
 }
 
@@ -286,7 +294,24 @@ func (c *UsersController) Update(ctx *app.UpdateUsersContext) error {
 		return jsonapi.JSONErrorResponse(ctx, err)
 	}
 
-	var isEmailVerificationNeeded bool
+	// the username is changed first, along with its history, so that the identity is loaded with its new username below
+	updatedUserName := ctx.Payload.Data.Attributes.Username
+	if updatedUserName != nil && *updatedUserName != loggedInIdentity.Username {
+		if !isUsernameValid(*updatedUserName) {
+			return jsonapi.JSONErrorResponse(ctx, errs.Wrap(errors.NewBadParameterError("username", "required"), fmt.Sprintf("invalid value assigned to username for identity with id %s and user with id %s", loggedInIdentity.ID, loggedInIdentity.UserID.UUID)))
+		}
+		err = c.app.UserService().ChangeUsername(ctx, loggedInIdentity, *updatedUserName, true)
+		if err != nil {
+			log.Error(ctx, map[string]interface{}{
+				"identity_id": loggedInIdentity.ID.String(),
+				"err":         err,
+			}, "failed to update the username")
+			return jsonapi.JSONErrorResponse(ctx, err)
+		}
+	}
+
+	// the new email address, which only replaces the current one once it is confirmed
+	var pendingEmail *string
 
 	var identity *accountrepo.Identity
 	var user *accountrepo.User
@@ -318,30 +343,7 @@ func (c *UsersController) Update(ctx *app.UpdateUsersContext) error {
 				// TODO: Add errors.NewConflictError(..)
 				return errs.Wrap(errors.NewBadParameterError("email", *updatedEmail).Expected("unique email"), fmt.Sprintf("email : %s is already in use", *updatedEmail))
 			}
-			user.Email = *updatedEmail
-
-			isEmailVerificationNeeded = true
-			user.EmailVerified = false
-		}
-
-		updatedUserName := ctx.Payload.Data.Attributes.Username
-		if updatedUserName != nil && *updatedUserName != identity.Username {
-			isValid := isUsernameValid(*updatedUserName)
-			if !isValid {
-				return errs.Wrap(errors.NewBadParameterError("username", "required"), fmt.Sprintf("invalid value assigned to username for identity with id %s and user with id %s", identity.ID, identity.UserID.UUID))
-			}
-			if identity.RegistrationCompleted {
-				return errors.NewForbiddenError(fmt.Sprintf("username cannot be updated more than once for identity id %s ", loggedInIdentity.ID))
-			}
-			isUnique, err := isUsernameUnique(ctx, tr, *updatedUserName, *identity)
-			if err != nil {
-				return errs.Wrap(err, fmt.Sprintf("error updating identitity with id %s and user with id %s", identity.ID, identity.UserID.UUID))
-			}
-			if !isUnique {
-				// TODO : Add errors.NewConflictError(..)
-				return errs.Wrap(errors.NewBadParameterError("username", *updatedUserName).Expected("unique username"), fmt.Sprintf("username : %s is already in use", *updatedUserName))
-			}
-			identity.Username = *updatedUserName
+			pendingEmail = updatedEmail
 		}
 
 		updatedRegistratedCompleted := ctx.Payload.Data.Attributes.RegistrationCompleted
@@ -387,6 +389,12 @@ func (c *UsersController) Update(ctx *app.UpdateUsersContext) error {
 			user.Company = *updatedCompany
 		}
 
+		err = c.app.UserProfileAttributeService().ApplyProfileAttributes(ctx, user, ctx.Payload.Data.Attributes.ProfileAttributes,
+			accountrepo.UserProfileAttributeVisibilityPrivate)
+		if err != nil {
+			return err
+		}
+
 		updatedContextInformation := ctx.Payload.Data.Attributes.ContextInformation
 		if updatedContextInformation != nil {
 			// if user.ContextInformation , we get to PATCH the ContextInformation field,
@@ -429,19 +437,24 @@ func (c *UsersController) Update(ctx *app.UpdateUsersContext) error {
 		return jsonapi.JSONErrorResponse(ctx, err)
 	}
 
-	if isEmailVerificationNeeded {
-		_, err = c.EmailVerificationService.SendVerificationCode(ctx, ctx.RequestData, *identity)
+	if pendingEmail != nil {
+		_, err = c.EmailVerificationService.RequestEmailChange(ctx, ctx.RequestData, *identity, *pendingEmail)
 		if err != nil {
 			log.Error(ctx, map[string]interface{}{
 				"identity_id": loggedInIdentity.ID.String(),
 				"err":         err,
 				"username":    identity.Username,
-				"email":       user.Email,
-			}, "failed to send verification email for update on email")
+				"email":       *pendingEmail,
+			}, "failed to request the change of email")
+			return jsonapi.JSONErrorResponse(ctx, err)
 		}
 	}
 
-	return ctx.OK(ConvertToAppUser(ctx.RequestData, user, identity, true))
+	attributes, err := c.app.UserProfileAttributeService().ListAttributes(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(ConvertToAppUserWithProfile(ctx.RequestData, user, identity, true, attributes, accountrepo.UserProfileAttributeVisibilityPrivate))
 }
 
 func (c *UsersController) updateFeatureLevel(ctx context.Context, user *accountrepo.User, updatedFeatureLevel *string) error {
@@ -486,23 +499,6 @@ func isUsernameValid(username string) bool {
 	return false
 }
 
-func isUsernameUnique(ctx context.Context, repos repository.Repositories, username string, identity accountrepo.Identity) (bool, error) {
-	usersWithSameUserName, err := repos.Identities().Query(accountrepo.IdentityFilterByUsername(username), accountrepo.IdentityFilterByProviderType(accountrepo.DefaultIDP))
-	if err != nil {
-		log.Error(ctx, map[string]interface{}{
-			"user_name": username,
-			"err":       err,
-		}, "error fetching users with username filter")
-		return false, err
-	}
-	for _, u := range usersWithSameUserName {
-		if u.UserID.UUID != identity.UserID.UUID {
-			return false, nil
-		}
-	}
-	return true, nil
-}
-
 func isEmailUnique(ctx context.Context, repos repository.Repositories, email string, user accountrepo.User) (bool, error) {
 	usersWithSameEmail, err := repos.Users().Query(accountrepo.UserFilterByEmail(email))
 	if err != nil {
@@ -532,7 +528,7 @@ func (c *UsersController) userExistsInDB(ctx context.Context, email string, user
 			exists = true
 			return nil
 		}
-		identities, err := tr.Identities().Query(accountrepo.IdentityFilterByUsername(username), accountrepo.IdentityFilterByProviderType(accountrepo.DefaultIDP))
+		identities, err := tr.Identities().Query(accountrepo.IdentityFilterByCurrentUsername(username), accountrepo.IdentityFilterByProviderType(accountrepo.DefaultIDP))
 		if err != nil {
 			return err
 		}
@@ -627,6 +623,24 @@ func (c *UsersController) VerifyEmail(ctx *app.VerifyEmailUsersContext) error {
 	return ctx.TemporaryRedirect()
 }
 
+// CancelEmailChange cancels a pending change of the user's email address, or reverts it if it was recently confirmed.
+func (c *UsersController) CancelEmailChange(ctx *app.CancelEmailChangeUsersContext) error {
+	_, err := c.EmailVerificationService.CancelEmailChange(ctx, ctx.Code)
+	redirectURL, redirectErr := rest.AddParam(c.config.GetEmailVerifiedRedirectURL(), "cancelled", fmt.Sprint(err == nil))
+	if redirectErr != nil {
+		return redirectErr
+	}
+	if err != nil {
+		redirectURL, redirectErr = rest.AddParam(redirectURL, "error", err.Error())
+		if redirectErr != nil {
+			return redirectErr
+		}
+	}
+
+	ctx.ResponseData.Header().Set("Location", redirectURL)
+	return ctx.TemporaryRedirect()
+}
+
 // ListTokens lists all of the tokens for the specified identity.  This endpoint may only be invoked via the admin console
 // service account
 func (c *UsersController) ListTokens(ctx *app.ListTokensUsersContext) error {
@@ -676,13 +690,18 @@ func (c *UsersController) ListTokens(ctx *app.ListTokensUsersContext) error {
 			}
 		}
 
-		response.Data = append(response.Data, &app.UserTokenData{
+		tokenData := &app.UserTokenData{
 			TokenID:     t.TokenID.String(),
 			TokenType:   t.TokenType,
 			Status:      t.Status,
 			ExpiryTime:  t.ExpiryTime,
 			Permissions: perms,
-		})
+		}
+		if t.SessionID != nil {
+			sessionID := t.SessionID.String()
+			tokenData.SessionID = &sessionID
+		}
+		response.Data = append(response.Data, tokenData)
 	}
 
 	return ctx.OK(response)
@@ -728,7 +747,7 @@ func filterUsers(repos repository.Repositories, ctx *app.ListUsersContext) ([]ac
 	userFilters := []func(*gorm.DB) *gorm.DB{}
 	/*** Start filtering on Identities table ****/
 	if ctx.FilterUsername != nil {
-		identityFilters = append(identityFilters, accountrepo.IdentityFilterByUsername(*ctx.FilterUsername))
+		identityFilters = append(identityFilters, accountrepo.IdentityFilterByCurrentUsername(*ctx.FilterUsername))
 	}
 	// Add more filters when needed , here. ..
 	if len(identityFilters) != 0 {
@@ -893,6 +912,15 @@ func ConvertToAppUser(request *goa.RequestData, user *accountrepo.User, identity
 	return &converted
 }
 
+// ConvertToAppUserWithProfile converts a complete Identity object into REST representation, along with the values of
+// the custom profile attributes of the user which are visible with the given visibility
+func ConvertToAppUserWithProfile(request *goa.RequestData, user *accountrepo.User, identity *accountrepo.Identity, isAuthenticated bool,
+	attributes []accountrepo.UserProfileAttribute, visibility string) *app.User {
+	converted := ConvertToAppUser(request, user, identity, isAuthenticated)
+	converted.Data.Attributes.ProfileAttributes = accountrepo.VisibleProfileAttributes(attributes, user.ProfileAttributes, visibility)
+	return converted
+}
+
 // ConvertUsersSimple converts a array of simple Identity IDs into a Generic Reletionship List
 func ConvertUsersSimple(request *goa.RequestData, identityIDs []interface{}) []*app.GenericData {
 	var ops []*app.GenericData
@@ -23,6 +23,7 @@ import (
 	"github.com/fabric8-services/fabric8-auth/gormsupport"
 	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
 	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/notification"
 	"github.com/fabric8-services/fabric8-auth/resource"
 	"github.com/fabric8-services/fabric8-auth/rest"
 	testsupport "github.com/fabric8-services/fabric8-auth/test"
@@ -64,7 +65,7 @@ func (s *UsersControllerTestSuite) SetupSuite() {
 func (s *UsersControllerTestSuite) UnsecuredController() (*goa.Service, *UsersController) {
 	svc := testsupport.UnsecuredService("Users-Service")
 	controller := NewUsersController(s.svc, s.Application, s.Configuration)
-	controller.EmailVerificationService = service.NewEmailVerificationClient(s.Application)
+	controller.EmailVerificationService = service.NewEmailVerificationClient(s.Application, s.Configuration)
 	return svc, controller
 }
 
@@ -74,14 +75,14 @@ func (s *UsersControllerTestSuite) UnsecuredControllerBannedUser() (*goa.Service
 
 	svc := testsupport.ServiceAsUser("Users-Service", identity)
 	controller := NewUsersController(s.svc, s.Application, s.Configuration)
-	controller.EmailVerificationService = service.NewEmailVerificationClient(s.Application)
+	controller.EmailVerificationService = service.NewEmailVerificationClient(s.Application, s.Configuration)
 	return svc, controller
 }
 
 func (s *UsersControllerTestSuite) SecuredController(identity accountrepo.Identity) (*goa.Service, *UsersController) {
 	svc := testsupport.ServiceAsUser("Users-Service", identity)
 	controller := NewUsersController(s.svc, s.Application, s.Configuration)
-	controller.EmailVerificationService = service.NewEmailVerificationClient(s.Application)
+	controller.EmailVerificationService = service.NewEmailVerificationClient(s.Application, s.Configuration)
 	return svc, controller
 }
 
@@ -154,7 +155,7 @@ func (s *UsersControllerTestSuite) TestUpdateUser() {
 
 		t.Run("ok", func(t *testing.T) {
 			// given
-			_, identity := s.createRandomUserIdentity(t, "TestUpdateUser")
+			user, identity := s.createRandomUserIdentity(t, "TestUpdateUser")
 			// when
 			newEmail := "TestUpdateUserOK-" + uuid.NewV4().String() + "@email.com"
 			newFullName := "TestUpdateUserOK"
@@ -187,6 +188,11 @@ func (s *UsersControllerTestSuite) TestUpdateUser() {
 			_, result = test.ShowUsersOK(t, nil, nil, s.controller, identity.ID.String(), nil, nil)
 			require.NotNil(t, result)
 			assert.Equal(t, identity.ID.String(), *result.Data.ID)
+			// the email address only changes once the new address is confirmed
+			assert.Equal(t, user.Email, *result.Data.Attributes.Email)
+			change, err := s.Application.PendingEmailChanges().LoadForUser(context.Background(), user.ID)
+			require.NoError(t, err)
+			assert.Equal(t, newEmail, change.NewEmail)
 			assert.Equal(t, newFullName, *result.Data.Attributes.FullName)
 			assert.Equal(t, newImageURL, *result.Data.Attributes.ImageURL)
 			assert.Equal(t, newBio, *result.Data.Attributes.Bio)
@@ -312,6 +318,48 @@ func (s *UsersControllerTestSuite) TestUpdateUser() {
 			require.False(t, *result.Data.Attributes.RegistrationCompleted)
 		})
 
+		t.Run("username after registration ok", func(t *testing.T) {
+			// given
+			_, identity := s.createRandomUserIdentity(t, "TestUpdateUser")
+			identity.RegistrationCompleted = true
+			require.NoError(t, s.Application.Identities().Save(s.Ctx, &identity))
+			previousUsername := identity.Username
+			newUsername := "renamed-" + uuid.NewV4().String()
+			secureService, secureController := s.SecuredController(identity)
+			// when
+			updateUsersPayload := newUpdateUsersPayload(WithUpdatedUsername(newUsername))
+			_, result := test.UpdateUsersOK(t, secureService.Context, secureService, secureController, updateUsersPayload)
+			// then
+			assert.Equal(t, newUsername, *result.Data.Attributes.Username)
+			history, err := s.Application.UsernameHistory().ListForIdentity(s.Ctx, identity.ID)
+			require.NoError(t, err)
+			require.Len(t, history, 1)
+			assert.Equal(t, previousUsername, history[0].Username)
+			assert.True(t, history[0].ReservedUntil.After(time.Now().Add(s.Configuration.GetUsernameChangeCooldown()-time.Minute)))
+			// the notification of the dependent services is recorded in the outbox
+			events, err := s.Application.OutboxEvents().ListForIdentity(s.Ctx, identity.ID)
+			require.NoError(t, err)
+			var messages []notification.Message
+			for _, e := range events {
+				if e.EventType != accountrepo.OutboxEventTypeNotification {
+					continue
+				}
+				msg, err := e.NotificationMessage()
+				require.NoError(t, err)
+				messages = append(messages, msg)
+			}
+			require.Len(t, messages, 1)
+			assert.Equal(t, "user.username.update", messages[0].MessageType)
+			assert.Equal(t, identity.ID.String(), messages[0].TargetID)
+			assert.Equal(t, previousUsername, messages[0].Custom["previousUsername"])
+			assert.Equal(t, newUsername, messages[0].Custom["username"])
+			// the previous username still resolves to the user
+			resolved, err := s.Application.UserService().IdentityByUsernameAndEmail(s.Ctx, previousUsername, identity.User.Email)
+			require.NoError(t, err)
+			require.NotNil(t, resolved)
+			assert.Equal(t, identity.ID, resolved.ID)
+		})
+
 		t.Run("registration completed ok", func(t *testing.T) {
 			// given
 			_, identity := s.createRandomUserIdentity(t, "TestUpdateUser")
@@ -664,10 +712,49 @@ func (s *UsersControllerTestSuite) TestUpdateUser() {
 			require.Equal(t, "", *showUserResponse.Data.Attributes.Email)
 			require.True(t, *showUserResponse.Data.Attributes.EmailPrivate)
 		})
+
+		t.Run("profile attributes", func(t *testing.T) {
+			// given
+			public := s.saveProfileAttribute(t, accountrepo.UserProfileAttributeVisibilityPublic)
+			private := s.saveProfileAttribute(t, accountrepo.UserProfileAttributeVisibilityPrivate)
+			_, identity := s.createRandomUserIdentity(t, "TestUpdateUser")
+			secureService, secureController := s.SecuredController(identity)
+			// when
+			updateUsersPayload := newUpdateUsersPayload(WithUpdatedProfileAttributes(map[string]interface{}{
+				public.Name:  "Europe/Paris",
+				private.Name: "fr_FR",
+			}))
+			_, result := test.UpdateUsersOK(t, secureService.Context, secureService, secureController, updateUsersPayload)
+			// then the user sees all the values
+			assert.Equal(t, map[string]interface{}{public.Name: "Europe/Paris", private.Name: "fr_FR"}, result.Data.Attributes.ProfileAttributes)
+			// but the private values are hidden to the others
+			_, result = test.ShowUsersOK(t, nil, nil, s.controller, identity.ID.String(), nil, nil)
+			assert.Equal(t, map[string]interface{}{public.Name: "Europe/Paris"}, result.Data.Attributes.ProfileAttributes)
+
+			// when a value is unset
+			updateUsersPayload = newUpdateUsersPayload(WithUpdatedProfileAttributes(map[string]interface{}{
+				private.Name: nil,
+			}))
+			_, result = test.UpdateUsersOK(t, secureService.Context, secureService, secureController, updateUsersPayload)
+			// then
+			assert.Equal(t, map[string]interface{}{public.Name: "Europe/Paris"}, result.Data.Attributes.ProfileAttributes)
+		})
 	})
 
 	s.T().Run("bad request", func(t *testing.T) {
 
+		t.Run("invalid profile attribute", func(t *testing.T) {
+			// given
+			_, identity := s.createRandomUserIdentity(t, "TestUpdateUser")
+			secureService, secureController := s.SecuredController(identity)
+			attribute := s.saveProfileAttribute(t, accountrepo.UserProfileAttributeVisibilityPublic)
+			// when/then
+			updateUsersPayload := newUpdateUsersPayload(WithUpdatedProfileAttributes(map[string]interface{}{
+				attribute.Name: 3,
+			}))
+			test.UpdateUsersBadRequest(t, secureService.Context, secureService, secureController, updateUsersPayload)
+		})
+
 		t.Run("invalid email address", func(t *testing.T) {
 			// given
 			_, identity := s.createRandomUserIdentity(t, "TestUpdateUser")
@@ -722,6 +809,25 @@ func (s *UsersControllerTestSuite) TestUpdateUser() {
 			test.UpdateUsersBadRequest(t, secureService.Context, secureService, secureController, updateUsersPayload)
 		})
 
+		t.Run("username reserved to a previous owner", func(t *testing.T) {
+			// given a username recently given up by another user
+			_, previousOwner := s.createRandomUserIdentity(t, "TestUpdateUser")
+			reservedUsername := previousOwner.Username
+			err := s.Application.UsernameHistory().Create(s.Ctx, &accountrepo.UsernameHistory{
+				IdentityID:    previousOwner.ID,
+				Username:      reservedUsername,
+				ReservedUntil: time.Now().Add(time.Hour),
+			})
+			require.NoError(t, err)
+			previousOwner.Username = "renamed-" + uuid.NewV4().String()
+			require.NoError(t, s.Application.Identities().Save(s.Ctx, &previousOwner))
+			_, identity := s.createRandomUserIdentity(t, "TestUpdateUser")
+			secureService, secureController := s.SecuredController(identity)
+			// when/then
+			updateUsersPayload := newUpdateUsersPayload(WithUpdatedUsername(reservedUsername))
+			test.UpdateUsersBadRequest(t, secureService.Context, secureService, secureController, updateUsersPayload)
+		})
+
 		t.Run("existing email", func(t *testing.T) {
 			// create 2 users.
 			// user1, identity1 := s.createRandomUserIdentity(t, "TestUpdateUser")
@@ -743,6 +849,18 @@ func (s *UsersControllerTestSuite) TestUpdateUser() {
 
 	s.T().Run("forbidden", func(t *testing.T) {
 
+		t.Run("admin profile attribute", func(t *testing.T) {
+			// given
+			_, identity := s.createRandomUserIdentity(t, "TestUpdateUser")
+			secureService, secureController := s.SecuredController(identity)
+			attribute := s.saveProfileAttribute(t, accountrepo.UserProfileAttributeVisibilityAdmin)
+			// when/then
+			updateUsersPayload := newUpdateUsersPayload(WithUpdatedProfileAttributes(map[string]interface{}{
+				attribute.Name: "R&D",
+			}))
+			test.UpdateUsersForbidden(t, secureService.Context, secureService, secureController, updateUsersPayload)
+		})
+
 		t.Run("username multiple times forbidden", func(t *testing.T) {
 			_, identity := s.createRandomUserIdentity(t, "TestUpdateUser")
 			newUsername := identity.Username + uuid.NewV4().String()
@@ -766,7 +884,14 @@ func (s *UsersControllerTestSuite) TestUpdateUser() {
 
 			test.UpdateUsersOK(t, secureService.Context, secureService, secureController, updateUsersPayload)
 
-			// next attempt should fail.
+			// the username can be changed once after the registration
+			newUsername = identity.Username + uuid.NewV4().String()
+			updateUsersPayload = newUpdateUsersPayload(
+				WithUpdatedUsername(newUsername),
+				WithUpdatedContextInformation(contextInformation))
+			test.UpdateUsersOK(t, secureService.Context, secureService, secureController, updateUsersPayload)
+
+			// next attempt during the cooldown period should fail.
 			newUsername = identity.Username + uuid.NewV4().String()
 			updateUsersPayload = newUpdateUsersPayload(
 				WithUpdatedUsername(newUsername),
@@ -890,6 +1015,17 @@ func (s *UsersControllerTestSuite) TestSendEmailVerificationCode() {
 		secureService, secureController := s.SecuredControllerWithDummyEmailService(identity, false)
 		test.SendEmailVerificationCodeUsersInternalServerError(t, secureService.Context, secureService, secureController)
 	})
+
+	s.T().Run("too many requests", func(t *testing.T) {
+		// given
+		_, identity := s.createRandomUserIdentity(t, "TestSendEmailVerificationCode-TooManyRequests")
+		secureService, secureController := s.SecuredController(identity)
+		for i := 0; i < s.Configuration.GetEmailVerificationRateLimit(); i++ {
+			test.SendEmailVerificationCodeUsersNoContent(t, secureService.Context, secureService, secureController)
+		}
+		// when/then
+		test.SendEmailVerificationCodeUsersTooManyRequests(t, secureService.Context, secureService, secureController)
+	})
 }
 
 func (s *UsersControllerTestSuite) TestVerifyEmail() {
@@ -901,8 +1037,9 @@ func (s *UsersControllerTestSuite) TestVerifyEmail() {
 
 		// when
 		secureService, secureController := s.SecuredController(identity)
+		newEmail := "TestUpdateUserOK-" + uuid.NewV4().String() + "@email.com"
 		updateUsersPayload := newUpdateUsersPayload(
-			WithUpdatedEmail("TestUpdateUserOK-"+uuid.NewV4().String()+"@email.com"),
+			WithUpdatedEmail(newEmail),
 			WithUpdatedFullName("TestUpdateUserOK"),
 			WithUpdatedBio("new bio"),
 			WithUpdatedImageURL("http://new.image.io/imageurl"),
@@ -928,6 +1065,10 @@ func (s *UsersControllerTestSuite) TestVerifyEmail() {
 		codes, err = s.Application.VerificationCodes().Query(accountrepo.VerificationCodeWithUser(), accountrepo.VerificationCodeFilterByUserID(user.ID))
 		require.NoError(t, err)
 		require.Len(t, codes, 0)
+		// the email address changed once confirmed
+		_, result := test.ShowUsersOK(t, nil, nil, s.controller, identity.ID.String(), nil, nil)
+		assert.Equal(t, newEmail, *result.Data.Attributes.Email)
+		require.True(t, *result.Data.Attributes.EmailVerified)
 	})
 
 	s.T().Run("fail", func(t *testing.T) {
@@ -941,6 +1082,68 @@ func (s *UsersControllerTestSuite) TestVerifyEmail() {
 	})
 }
 
+func (s *UsersControllerTestSuite) TestCancelEmailChange() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		user, identity := s.createRandomUserIdentity(t, "TestCancelEmailChangeOK")
+		secureService, secureController := s.SecuredController(identity)
+		updateUsersPayload := newUpdateUsersPayload(WithUpdatedEmail("TestCancelEmailChangeOK-" + uuid.NewV4().String() + "@email.com"))
+		test.UpdateUsersOK(t, secureService.Context, secureService, secureController, updateUsersPayload)
+		change, err := s.Application.PendingEmailChanges().LoadForUser(context.Background(), user.ID)
+		require.NoError(t, err)
+		codes, err := s.Application.VerificationCodes().Query(accountrepo.VerificationCodeFilterByUserID(user.ID))
+		require.NoError(t, err)
+		require.Len(t, codes, 1)
+
+		// when
+		rw := test.CancelEmailChangeUsersTemporaryRedirect(t, secureService.Context, secureService, secureController, change.CancelCode)
+
+		// then
+		assert.Equal(t, "https://prod-preview.openshift.io/_home?cancelled=true", rw.Header().Get("Location"))
+		_, err = s.Application.PendingEmailChanges().LoadForUser(context.Background(), user.ID)
+		require.IsType(t, errors.NotFoundError{}, err)
+		// the code sent to the new address cannot be used anymore
+		rw = test.VerifyEmailUsersTemporaryRedirect(t, secureService.Context, secureService, secureController, codes[0].Code)
+		assert.Contains(t, rw.Header().Get("Location"), "verified=false")
+		_, result := test.ShowUsersOK(t, nil, nil, s.controller, identity.ID.String(), nil, nil)
+		assert.Equal(t, user.Email, *result.Data.Attributes.Email)
+	})
+
+	s.T().Run("revert after confirmation", func(t *testing.T) {
+		// given
+		user, identity := s.createRandomUserIdentity(t, "TestCancelEmailChangeRevert")
+		secureService, secureController := s.SecuredController(identity)
+		updateUsersPayload := newUpdateUsersPayload(WithUpdatedEmail("TestCancelEmailChangeRevert-" + uuid.NewV4().String() + "@email.com"))
+		test.UpdateUsersOK(t, secureService.Context, secureService, secureController, updateUsersPayload)
+		change, err := s.Application.PendingEmailChanges().LoadForUser(context.Background(), user.ID)
+		require.NoError(t, err)
+		codes, err := s.Application.VerificationCodes().Query(accountrepo.VerificationCodeFilterByUserID(user.ID))
+		require.NoError(t, err)
+		require.Len(t, codes, 1)
+		rw := test.VerifyEmailUsersTemporaryRedirect(t, secureService.Context, secureService, secureController, codes[0].Code)
+		require.Contains(t, rw.Header().Get("Location"), "verified=true")
+
+		// when
+		rw = test.CancelEmailChangeUsersTemporaryRedirect(t, secureService.Context, secureService, secureController, change.CancelCode)
+
+		// then
+		assert.Equal(t, "https://prod-preview.openshift.io/_home?cancelled=true", rw.Header().Get("Location"))
+		_, result := test.ShowUsersOK(t, nil, nil, s.controller, identity.ID.String(), nil, nil)
+		assert.Equal(t, user.Email, *result.Data.Attributes.Email)
+	})
+
+	s.T().Run("fail", func(t *testing.T) {
+		// given
+		_, identity := s.createRandomUserIdentity(t, "TestCancelEmailChangeFail")
+		// when
+		secureService, secureController := s.SecuredController(identity)
+		rw := test.CancelEmailChangeUsersTemporaryRedirect(t, secureService.Context, secureService, secureController, "ABCD")
+		// then
+		assert.Contains(t, rw.Header().Get("Location"), "cancelled=false")
+	})
+}
+
 func (s *UsersControllerTestSuite) TestShowUserOK() {
 	// given user
 	user, identity := s.createRandomUserIdentity(s.T(), "TestShowUserOK")
@@ -1355,6 +1558,12 @@ func WithUpdatedContextInformation(contextInformation map[string]interface{}) Up
 	}
 }
 
+func WithUpdatedProfileAttributes(profileAttributes map[string]interface{}) UpdateUserOption {
+	return func(attrs *app.UpdateIdentityDataAttributes) {
+		attrs.ProfileAttributes = profileAttributes
+	}
+}
+
 func WithUpdatedEmailPrivate(emailPrivate bool) UpdateUserOption {
 	return func(attrs *app.UpdateIdentityDataAttributes) {
 		attrs.EmailPrivate = &emailPrivate
@@ -1367,6 +1576,17 @@ func WithRegistrationCompleted(registrationCompleted bool) UpdateUserOption {
 	}
 }
 
+// saveProfileAttribute saves a new string profile attribute with the given visibility
+func (s *UsersControllerTestSuite) saveProfileAttribute(t *testing.T, visibility string) *accountrepo.UserProfileAttribute {
+	attribute, err := s.Application.UserProfileAttributeService().SaveAttribute(s.Ctx, accountrepo.UserProfileAttribute{
+		Name:       newProfileAttributeName("attribute"),
+		Type:       accountrepo.UserProfileAttributeTypeString,
+		Visibility: visibility,
+	})
+	require.NoError(t, err)
+	return attribute
+}
+
 func newUpdateUsersPayload(updateOptions ...UpdateUserOption) *app.UpdateUsersPayload {
 	attributes := app.UpdateIdentityDataAttributes{}
 	for _, option := range updateOptions {
@@ -1826,6 +2046,17 @@ func (s *DummyEmailVerificationService) VerifyCode(ctx context.Context, code str
 	return nil, nil
 }
 
+func (s *DummyEmailVerificationService) RequestEmailChange(ctx context.Context, req *goa.RequestData, identity accountrepo.Identity, email string) (*accountrepo.PendingEmailChange, error) {
+	if s.success {
+		return nil, nil
+	}
+	return nil, errors.NewInternalErrorFromString("failed to send out email")
+}
+
+func (s *DummyEmailVerificationService) CancelEmailChange(ctx context.Context, code string) (*accountrepo.PendingEmailChange, error) {
+	return nil, nil
+}
+
 type dummyTenantService struct {
 	identityID uuid.UUID
 	error
@@ -0,0 +1,227 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	mfarepo "github.com/fabric8-services/fabric8-auth/authentication/mfa/repository"
+	"github.com/fabric8-services/fabric8-auth/authentication/mfa/webauthn"
+	"github.com/fabric8-services/fabric8-auth/authorization/token"
+	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	uuid "github.com/satori/go.uuid"
+)
+
+// WebauthnController implements the webauthn resource.
+type WebauthnController struct {
+	*goa.Controller
+	app          application.Application
+	tokenManager manager.TokenManager
+}
+
+// NewWebauthnController creates a webauthn controller.
+func NewWebauthnController(service *goa.Service, app application.Application, tokenManager manager.TokenManager) *WebauthnController {
+	return &WebauthnController{
+		Controller:   service.NewController("WebauthnController"),
+		app:          app,
+		tokenManager: tokenManager,
+	}
+}
+
+// BeginRegistration starts the registration of a new WebAuthn credential for the current user. Users who already
+// enabled multi-factor authentication must use a multi-factor authenticated token, so that a stolen single-factor
+// token can not be used to register another authenticator.
+func (c *WebauthnController) BeginRegistration(ctx *app.BeginRegistrationWebauthnContext) error {
+	identityID, err := c.locateIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	enrolled, err := c.app.MFAService().IsEnrolled(ctx, identityID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	if enrolled && !token.IsMultiFactorAuthenticated(ctx) {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewForbiddenError("multi-factor authentication is required to register another authenticator"))
+	}
+	options, err := c.app.WebAuthnService().BeginRegistration(ctx, identityID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	algorithms := make([]int, len(options.Algorithms))
+	for i, algorithm := range options.Algorithms {
+		algorithms[i] = int(algorithm)
+	}
+	ctx.ResponseData.Header().Set("Cache-Control", "no-store")
+	return ctx.OK(&app.WebAuthnCreationOptions{
+		Challenge:          webauthn.EncodeBase64URL(options.Challenge),
+		RpID:               options.RPID,
+		RpName:             options.RPName,
+		UserID:             webauthn.EncodeBase64URL(options.UserID),
+		UserName:           options.UserName,
+		UserDisplayName:    options.UserDisplayName,
+		Algorithms:         algorithms,
+		ExcludeCredentials: encodeCredentialIDs(options.ExcludeCredentials),
+		Timeout:            int(options.Timeout / time.Millisecond),
+	})
+}
+
+// FinishRegistration completes the registration of a new WebAuthn credential for the current user
+func (c *WebauthnController) FinishRegistration(ctx *app.FinishRegistrationWebauthnContext) error {
+	identityID, err := c.locateIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	clientDataJSON, err := webauthn.DecodeBase64URL(ctx.Payload.ClientDataJSON)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterErrorFromString("client_data_json", ctx.Payload.ClientDataJSON, "invalid base64url value"))
+	}
+	attestationObject, err := webauthn.DecodeBase64URL(ctx.Payload.AttestationObject)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterErrorFromString("attestation_object", ctx.Payload.AttestationObject, "invalid base64url value"))
+	}
+	name := "Security key"
+	if ctx.Payload.Name != nil && *ctx.Payload.Name != "" {
+		name = *ctx.Payload.Name
+	}
+	credential, err := c.app.WebAuthnService().FinishRegistration(ctx, identityID, name, webauthn.AttestationResponse{
+		ClientDataJSON:    clientDataJSON,
+		AttestationObject: attestationObject,
+	})
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.OK(&app.WebAuthnCredentialSingle{
+		Data: convertToWebAuthnCredentialData(*credential),
+	})
+}
+
+// ListCredentials lists the WebAuthn credentials of the current user
+func (c *WebauthnController) ListCredentials(ctx *app.ListCredentialsWebauthnContext) error {
+	identityID, err := c.locateIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	credentials, err := c.app.WebAuthnService().ListCredentials(ctx, identityID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	data := make([]*app.WebAuthnCredentialData, len(credentials))
+	for i, credential := range credentials {
+		data[i] = convertToWebAuthnCredentialData(credential)
+	}
+	return ctx.OK(&app.WebAuthnCredentialList{
+		Data: data,
+	})
+}
+
+// DeleteCredential deletes a WebAuthn credential of the current user. Only a token obtained with multi-factor
+// authentication is allowed to do so.
+func (c *WebauthnController) DeleteCredential(ctx *app.DeleteCredentialWebauthnContext) error {
+	identityID, err := c.locateIdentity(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	credentialID, err := uuid.FromString(ctx.CredentialID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterErrorFromString("credentialID", ctx.CredentialID, "invalid credential ID - not a UUID"))
+	}
+	if !token.IsMultiFactorAuthenticated(ctx) {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewForbiddenError("multi-factor authentication is required to delete an authenticator"))
+	}
+	err = c.app.WebAuthnService().DeleteCredential(ctx, identityID, credentialID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	return ctx.NoContent()
+}
+
+// BeginAssertion starts the WebAuthn assertion of a login challenge
+func (c *WebauthnController) BeginAssertion(ctx *app.BeginAssertionWebauthnContext) error {
+	challengeID, err := uuid.FromString(ctx.Payload.Challenge)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterErrorFromString("challenge", ctx.Payload.Challenge, "invalid challenge - not a UUID"))
+	}
+	options, err := c.app.WebAuthnService().BeginAssertion(ctx, challengeID)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	ctx.ResponseData.Header().Set("Cache-Control", "no-store")
+	return ctx.OK(&app.WebAuthnRequestOptions{
+		Challenge:        webauthn.EncodeBase64URL(options.Challenge),
+		RpID:             options.RPID,
+		AllowCredentials: encodeCredentialIDs(options.AllowCredentials),
+		Timeout:          int(options.Timeout / time.Millisecond),
+	})
+}
+
+// FinishAssertion verifies the WebAuthn assertion of a login challenge and returns the URL to redirect to in order
+// to complete the login
+func (c *WebauthnController) FinishAssertion(ctx *app.FinishAssertionWebauthnContext) error {
+	challengeID, err := uuid.FromString(ctx.Payload.Challenge)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterErrorFromString("challenge", ctx.Payload.Challenge, "invalid challenge - not a UUID"))
+	}
+	response := webauthn.AssertionResponse{}
+	for _, field := range []struct {
+		name   string
+		value  string
+		target *[]byte
+	}{
+		{"credential_id", ctx.Payload.CredentialID, &response.CredentialID},
+		{"client_data_json", ctx.Payload.ClientDataJSON, &response.ClientDataJSON},
+		{"authenticator_data", ctx.Payload.AuthenticatorData, &response.AuthenticatorData},
+		{"signature", ctx.Payload.Signature, &response.Signature},
+	} {
+		*field.target, err = webauthn.DecodeBase64URL(field.value)
+		if err != nil {
+			return jsonapi.JSONErrorResponse(ctx, errors.NewBadParameterErrorFromString(field.name, field.value, "invalid base64url value"))
+		}
+	}
+	redirectURL, err := c.app.AuthenticationProviderService().CompleteWebAuthnLogin(ctx, challengeID, response)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	ctx.ResponseData.Header().Set("Cache-Control", "no-cache")
+	return ctx.OK(&app.MFAVerificationResponse{
+		RedirectURL: *redirectURL,
+	})
+}
+
+func (c *WebauthnController) locateIdentity(ctx context.Context) (uuid.UUID, error) {
+	identityID, err := c.tokenManager.Locate(ctx)
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "Bad Token")
+		return uuid.Nil, errors.NewUnauthorizedError("bad or missing token")
+	}
+	return identityID, nil
+}
+
+func encodeCredentialIDs(ids [][]byte) []string {
+	encoded := make([]string, len(ids))
+	for i, id := range ids {
+		encoded[i] = webauthn.EncodeBase64URL(id)
+	}
+	return encoded
+}
+
+func convertToWebAuthnCredentialData(credential mfarepo.WebAuthnCredential) *app.WebAuthnCredentialData {
+	data := &app.WebAuthnCredentialData{
+		ID:         credential.WebAuthnCredentialID.String(),
+		Name:       credential.Name,
+		CreatedAt:  credential.CreatedAt,
+		LastUsedAt: credential.LastUsedAt,
+	}
+	if credential.AAGUID != "" {
+		aaguid := credential.AAGUID
+		data.Aaguid = &aaguid
+	}
+	return data
+}
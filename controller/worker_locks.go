@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/authorization/token"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/worker/repository"
+
+	"github.com/goadesign/goa"
+)
+
+// WorkerLocksController implements the worker_locks resource.
+type WorkerLocksController struct {
+	*goa.Controller
+	app application.Application
+}
+
+// NewWorkerLocksController creates a worker_locks controller.
+func NewWorkerLocksController(service *goa.Service, app application.Application) *WorkerLocksController {
+	return &WorkerLocksController{
+		Controller: service.NewController("WorkerLocksController"),
+		app:        app,
+	}
+}
+
+// List runs the list action.
+func (c *WorkerLocksController) List(ctx *app.ListWorkerLocksContext) error {
+	if !token.IsSpecificServiceAccount(ctx, token.Admin) {
+		log.Error(ctx, nil, "the account is not an authorized service account allowed to manage the worker locks")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewForbiddenError("account not authorized to manage the worker locks"))
+	}
+	locks, err := c.app.WorkerLockRepository().ListLockStatuses(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	result := &app.WorkerLockList{
+		Data: make([]*app.WorkerLockData, len(locks)),
+	}
+	for i, l := range locks {
+		result.Data[i] = &app.WorkerLockData{
+			ID:   l.Name,
+			Type: "worker-locks",
+			Attributes: &app.WorkerLockDataAttributes{
+				Owner:       l.Owner,
+				HeartbeatAt: l.HeartbeatAt,
+				Stale:       l.IsStale(repository.DefaultLockLeaseDuration),
+			},
+		}
+	}
+	return ctx.OK(result)
+}
+
+// Release runs the release action.
+func (c *WorkerLocksController) Release(ctx *app.ReleaseWorkerLocksContext) error {
+	if !token.IsSpecificServiceAccount(ctx, token.Admin) {
+		log.Error(ctx, nil, "the account is not an authorized service account allowed to manage the worker locks")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewForbiddenError("account not authorized to manage the worker locks"))
+	}
+	force := ctx.Force != nil && *ctx.Force
+	err := c.app.WorkerLockRepository().ForceReleaseLock(ctx, ctx.Name, repository.DefaultLockLeaseDuration, force)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	log.Warn(ctx, map[string]interface{}{
+		"lock":  ctx.Name,
+		"force": force,
+	}, "worker lock force-released by an admin")
+	return ctx.NoContent()
+}
@@ -0,0 +1,104 @@
+package controller_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/app/test"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/controller"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	testsupport "github.com/fabric8-services/fabric8-auth/test"
+
+	"github.com/goadesign/goa"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestWorkerLocksController(t *testing.T) {
+	suite.Run(t, &WorkerLocksControllerTestSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+type WorkerLocksControllerTestSuite struct {
+	gormtestsupport.DBTestSuite
+}
+
+func (s *WorkerLocksControllerTestSuite) SecuredServiceAccountController(identity repository.Identity) (*goa.Service, *controller.WorkerLocksController) {
+	svc := testsupport.ServiceAsServiceAccountUser("WorkerLocks-ServiceAccount-Service", identity)
+	return svc, controller.NewWorkerLocksController(svc, s.Application)
+}
+
+// createLock records a lock whose owner is alive, since the lock was just created
+func (s *WorkerLocksControllerTestSuite) createLock(t *testing.T) string {
+	name := fmt.Sprintf("test-lock-%s", uuid.NewV4())
+	err := s.DB.Exec("INSERT INTO worker_lock (name, record_version_number, owner) VALUES (?, nextval('worker_lock_rvn'), ?)", name, "pod-1").Error
+	require.NoError(t, err)
+	return name
+}
+
+func (s *WorkerLocksControllerTestSuite) TestList() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		name := s.createLock(t)
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+		// when
+		_, result := test.ListWorkerLocksOK(t, svc.Context, svc, ctrl)
+		// then
+		var found bool
+		for _, l := range result.Data {
+			if l.ID == name {
+				found = true
+				assert.Equal(t, "pod-1", l.Attributes.Owner)
+				assert.NotNil(t, l.Attributes.HeartbeatAt)
+				assert.False(t, l.Attributes.Stale)
+			}
+		}
+		assert.True(t, found)
+	})
+
+	s.T().Run("forbidden", func(t *testing.T) {
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestOnlineRegistrationAppIdentity)
+		test.ListWorkerLocksForbidden(t, svc.Context, svc, ctrl)
+	})
+}
+
+func (s *WorkerLocksControllerTestSuite) TestRelease() {
+
+	s.T().Run("stale lock", func(t *testing.T) {
+		// given
+		name := s.createLock(t)
+		err := s.DB.Exec("UPDATE worker_lock SET heartbeat_at = now() - interval '1 hour' WHERE name = ?", name).Error
+		require.NoError(t, err)
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+		// when
+		test.ReleaseWorkerLocksNoContent(t, svc.Context, svc, ctrl, name, nil)
+		// then
+		test.ReleaseWorkerLocksNotFound(t, svc.Context, svc, ctrl, name, nil)
+	})
+
+	s.T().Run("lock is not stale", func(t *testing.T) {
+		name := s.createLock(t)
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+		test.ReleaseWorkerLocksConflict(t, svc.Context, svc, ctrl, name, nil)
+	})
+
+	s.T().Run("lock is not stale but release is forced", func(t *testing.T) {
+		// given
+		name := s.createLock(t)
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity)
+		force := true
+		// when
+		test.ReleaseWorkerLocksNoContent(t, svc.Context, svc, ctrl, name, &force)
+		// then
+		test.ReleaseWorkerLocksNotFound(t, svc.Context, svc, ctrl, name, nil)
+	})
+
+	s.T().Run("forbidden", func(t *testing.T) {
+		name := s.createLock(t)
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestOnlineRegistrationAppIdentity)
+		test.ReleaseWorkerLocksForbidden(t, svc.Context, svc, ctrl, name, nil)
+	})
+}
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/authorization/token"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/jsonapi"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/worker"
+
+	"github.com/goadesign/goa"
+)
+
+// WorkerChecker is to be used to report the status of the background workers
+type WorkerChecker interface {
+	Status(ctx context.Context) ([]worker.Status, error)
+}
+
+// WorkersController implements the workers resource.
+type WorkersController struct {
+	*goa.Controller
+	workerChecker WorkerChecker
+}
+
+// NewWorkersController creates a workers controller.
+func NewWorkersController(service *goa.Service, workerChecker WorkerChecker) *WorkersController {
+	return &WorkersController{
+		Controller:    service.NewController("WorkersController"),
+		workerChecker: workerChecker,
+	}
+}
+
+// List runs the list action.
+func (c *WorkersController) List(ctx *app.ListWorkersContext) error {
+	if !token.IsSpecificServiceAccount(ctx, token.Admin) {
+		log.Error(ctx, nil, "the account is not an authorized service account allowed to view the workers")
+		return jsonapi.JSONErrorResponse(ctx, errors.NewForbiddenError("account not authorized to view the workers"))
+	}
+	statuses, err := c.workerChecker.Status(ctx)
+	if err != nil {
+		return jsonapi.JSONErrorResponse(ctx, err)
+	}
+	result := &app.WorkerList{
+		Data: make([]*app.WorkerData, len(statuses)),
+	}
+	for i, s := range statuses {
+		result.Data[i] = &app.WorkerData{
+			ID:   s.Name,
+			Type: "workers",
+			Attributes: &app.WorkerDataAttributes{
+				LockHeld:           s.LockHeld,
+				LockOwner:          s.LockOwner,
+				LastCycleStartedAt: s.LastCycleStartedAt,
+				LastCycleEndedAt:   s.LastCycleEndedAt,
+				LastCycleError:     s.LastCycleError,
+			},
+		}
+		if s.HeartbeatAge != nil {
+			age := s.HeartbeatAge.Seconds()
+			result.Data[i].Attributes.HeartbeatAge = &age
+		}
+	}
+	return ctx.OK(result)
+}
@@ -0,0 +1,68 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/app/test"
+	"github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	"github.com/fabric8-services/fabric8-auth/controller"
+	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
+	testsupport "github.com/fabric8-services/fabric8-auth/test"
+	"github.com/fabric8-services/fabric8-auth/worker"
+
+	"github.com/goadesign/goa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestWorkersController(t *testing.T) {
+	suite.Run(t, &WorkersControllerTestSuite{DBTestSuite: gormtestsupport.NewDBTestSuite()})
+}
+
+type WorkersControllerTestSuite struct {
+	gormtestsupport.DBTestSuite
+}
+
+func (s *WorkersControllerTestSuite) SecuredServiceAccountController(identity repository.Identity, registry *worker.Registry) (*goa.Service, *controller.WorkersController) {
+	svc := testsupport.ServiceAsServiceAccountUser("Workers-ServiceAccount-Service", identity)
+	return svc, controller.NewWorkersController(svc, registry)
+}
+
+func (s *WorkersControllerTestSuite) TestList() {
+
+	s.T().Run("ok", func(t *testing.T) {
+		// given
+		registry := worker.NewRegistry(s.Application)
+		w := &worker.BaseWorker{
+			Ctx:   context.Background(),
+			App:   s.Application,
+			Name:  "workers-test-worker",
+			Owner: "workers-test-owner",
+			Do:    func() {},
+		}
+		registry.Add(w)
+		w.Start(50 * time.Millisecond)
+		defer registry.Drain(5 * time.Second)
+		for i := 0; i < 100 && !w.Status().LockHeld; i++ {
+			time.Sleep(50 * time.Millisecond)
+		}
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestAdminConsoleIdentity, registry)
+		// when
+		_, result := test.ListWorkersOK(t, svc.Context, svc, ctrl)
+		// then
+		require.Len(t, result.Data, 1)
+		assert.Equal(t, "workers-test-worker", result.Data[0].ID)
+		assert.True(t, result.Data[0].Attributes.LockHeld)
+		require.NotNil(t, result.Data[0].Attributes.LockOwner)
+		assert.Equal(t, "workers-test-owner", *result.Data[0].Attributes.LockOwner)
+		assert.NotNil(t, result.Data[0].Attributes.HeartbeatAge)
+	})
+
+	s.T().Run("forbidden", func(t *testing.T) {
+		svc, ctrl := s.SecuredServiceAccountController(testsupport.TestOnlineRegistrationAppIdentity, worker.NewRegistry(s.Application))
+		test.ListWorkersForbidden(t, svc.Context, svc, ctrl)
+	})
+}
@@ -42,6 +42,10 @@ var _ = a.API("auth", func() {
 		a.ContentType("application/vnd.api+json")
 	})
 
+	a.Trait("scim-media-type", func() {
+		a.ContentType("application/scim+json")
+	})
+
 	a.Trait("conditional", func() {
 		a.Headers(func() {
 			a.Header("If-Modified-Since", d.String)
@@ -65,6 +69,11 @@ var _ = a.API("auth", func() {
 		})
 	})
 
+	a.ResponseTemplate("TooManyRequests", func() {
+		a.Description("Too many requests over a period")
+		a.Status(429)
+	})
+
 	a.ResponseTemplate(d.Created, func(pattern string) {
 		a.Description("Resource created")
 		a.Status(201)
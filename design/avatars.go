@@ -0,0 +1,56 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+var _ = a.Resource("avatars", func() {
+	a.BasePath("/avatars")
+
+	a.Action("upload", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PUT(""),
+		)
+		a.Description(`Upload the avatar of the current user. The body of the request is the PNG, JPEG or GIF image, which is
+resized in the standard sizes, and the image URL of the user is set to the URL of the new avatar.`)
+		a.Response(d.OK, showUser)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+	})
+
+	a.Action("delete", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE(""),
+		)
+		a.Description("Delete the avatar of the current user, along with her image URL")
+		a.Response(d.OK, showUser)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+	})
+
+	a.Action("show", func() {
+		a.Routing(
+			a.GET("/:id"),
+		)
+		a.Description(`Show the image of an avatar, in PNG. The image of an avatar never changes, since each upload has a
+new ID, so it can be cached, and is not returned again if it did not change since the client's last call.`)
+		a.Params(func() {
+			a.Param("id", d.UUID, "The ID of the avatar")
+			a.Param("size", d.Integer, "The width and height of the image, in pixels: 32, 64, 128 or 256", func() {
+				a.Enum(32, 64, 128, 256)
+				a.Default(128)
+			})
+		})
+		a.UseTrait("conditional")
+		a.Response(d.OK)
+		a.Response(d.NotModified)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
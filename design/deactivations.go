@@ -0,0 +1,62 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+var _ = a.Resource("deactivations", func() {
+	a.BasePath("/deactivations")
+
+	a.Action("list", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET(""),
+		)
+		a.Description(`List the users who would be notified or deactivated during the next cycle of the user deactivation
+workers. The list can be exported in CSV format with the 'format=csv' query parameter.`)
+		a.Params(func() {
+			a.Param("page[offset]", d.String, "Paging start position")
+			a.Param("page[limit]", d.Integer, "Paging size")
+			a.Param("format", d.String, "The format of the response", func() {
+				a.Enum("json", "csv")
+				a.Default("json")
+			})
+		})
+		a.Response(d.OK, func() {
+			a.Media(deactivationCandidateList)
+		})
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+})
+
+var deactivationCandidateListMeta = a.Type("DeactivationCandidateListMeta", func() {
+	a.Attribute("totalCount", d.Integer)
+	a.Required("totalCount")
+})
+
+var deactivationCandidateList = JSONList(
+	"DeactivationCandidate", "Holds the paginated response to a deactivation candidates list request",
+	deactivationCandidateData,
+	pagingLinks,
+	deactivationCandidateListMeta)
+
+// deactivationCandidateData represents a user who would be notified or deactivated by the user deactivation workers
+var deactivationCandidateData = a.Type("DeactivationCandidateData", func() {
+	a.Attribute("id", d.String, "ID of the identity")
+	a.Attribute("type", d.String, "type of the candidate")
+	a.Attribute("attributes", deactivationCandidateDataAttributes, "Attributes of the candidate")
+	a.Required("id", "type", "attributes")
+})
+
+// deactivationCandidateDataAttributes represents the attributes of a user who would be notified or deactivated
+var deactivationCandidateDataAttributes = a.Type("DeactivationCandidateDataAttributes", func() {
+	a.Attribute("username", d.String, "The username of the user")
+	a.Attribute("action", d.String, "The action which would be taken: 'notify' or 'deactivate'")
+	a.Attribute("reason", d.String, "Why the user would be notified or deactivated")
+	a.Attribute("last-active", d.DateTime, "The time of the last activity of the user")
+	a.Attribute("scheduled-deactivation", d.DateTime, "The time at which the account would be deactivated")
+	a.Required("username", "action", "reason", "scheduled-deactivation")
+})
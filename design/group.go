@@ -0,0 +1,136 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+var _ = a.Resource("group", func() {
+
+	a.BasePath("/groups")
+
+	a.Action("create", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST(""),
+		)
+		a.Description("Create a new security group")
+		a.Payload(CreateGroupRequestMedia)
+		a.Response(d.Created, CreateGroupResponseMedia)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("listMembers", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:id/members"),
+		)
+		a.Params(func() {
+			a.Param("id", d.UUID, "The identifier of the group")
+			a.Param("transitive", d.Boolean, "Include the members of the nested groups", func() {
+				a.Default(false)
+			})
+		})
+		a.Description("List the members of a security group")
+		a.Response(d.OK, userList)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("addMember", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PUT("/:id/members/:memberID"),
+		)
+		a.Params(func() {
+			a.Param("id", d.UUID, "The identifier of the group")
+			a.Param("memberID", d.UUID, "The identity ID of the user or group to add")
+		})
+		a.Description("Add a user or a group as a member of a security group")
+		a.Response(d.NoContent)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("removeMember", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:id/members/:memberID"),
+		)
+		a.Params(func() {
+			a.Param("id", d.UUID, "The identifier of the group")
+			a.Param("memberID", d.UUID, "The identity ID of the member to remove")
+		})
+		a.Description("Remove a member from a security group")
+		a.Response(d.NoContent)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("nest", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PUT("/:id/parents/:parentID"),
+		)
+		a.Params(func() {
+			a.Param("id", d.UUID, "The identifier of the group")
+			a.Param("parentID", d.UUID, "The identifier of the group, team or organization in which to nest the group")
+		})
+		a.Description("Nest a security group in another group, a team or an organization")
+		a.Response(d.NoContent)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("unnest", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:id/parents/:parentID"),
+		)
+		a.Params(func() {
+			a.Param("id", d.UUID, "The identifier of the group")
+			a.Param("parentID", d.UUID, "The identifier of the group, team or organization from which to remove the group")
+		})
+		a.Description("Remove a security group from its parent group, team or organization")
+		a.Response(d.NoContent)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+})
+
+var CreateGroupRequestMedia = a.MediaType("application/vnd.create_group_request+json", func() {
+	a.Description("Request payload required to create a new security group")
+	a.Attributes(func() {
+		a.Attribute("name", d.String, "The name of the new group")
+		a.Required("name")
+	})
+	a.View("default", func() {
+		a.Attribute("name")
+	})
+})
+
+var CreateGroupResponseMedia = a.MediaType("application/vnd.create_group_response+json", func() {
+	a.Description("Response returned when creating a new security group")
+	a.Attributes(func() {
+		a.Attribute("group_id", d.String, "The identifier of the new group")
+		a.Required("group_id")
+	})
+	a.View("default", func() {
+		a.Attribute("group_id")
+	})
+})
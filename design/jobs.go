@@ -0,0 +1,147 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+var _ = a.Resource("jobs", func() {
+	a.BasePath("/jobs")
+
+	a.Action("list", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET(""),
+		)
+		a.Description("List the background jobs, along with their latest run")
+		a.Response(d.OK, jobList)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+
+	a.Action("runs", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:name/runs"),
+		)
+		a.Description("List the most recent runs of the background job, the most recent ones first")
+		a.Params(func() {
+			a.Param("name", d.String, "Name of the job")
+			a.Param("limit", d.Integer, "Maximum number of runs to return", func() {
+				a.Minimum(1)
+				a.Maximum(100)
+				a.Default(20)
+			})
+		})
+		a.Response(d.OK, jobRunList)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+
+	a.Action("trigger", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/:name/trigger"),
+		)
+		a.Description(`Request a run of the background job out of its schedule, even if the job is paused. The job runs
+during the next cycle of its worker.`)
+		a.Params(func() {
+			a.Param("name", d.String, "Name of the job")
+		})
+		a.Response(d.Accepted)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+
+	a.Action("pause", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PATCH("/:name/pause"),
+		)
+		a.Description("Suspend the scheduled runs of the background job")
+		a.Params(func() {
+			a.Param("name", d.String, "Name of the job")
+		})
+		a.Response(d.OK, jobSingle)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+
+	a.Action("resume", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PATCH("/:name/resume"),
+		)
+		a.Description("Resume the scheduled runs of the background job")
+		a.Params(func() {
+			a.Param("name", d.String, "Name of the job")
+		})
+		a.Response(d.OK, jobSingle)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+})
+
+var jobSingle = JSONSingle(
+	"Job", "Holds a single background job",
+	jobData,
+	nil)
+
+var jobList = JSONList(
+	"Job", "Holds the list of background jobs",
+	jobData,
+	nil,
+	nil)
+
+// jobData represents a background job
+var jobData = a.Type("JobData", func() {
+	a.Attribute("id", d.String, "Name of the job")
+	a.Attribute("type", d.String, "type of the job")
+	a.Attribute("attributes", jobDataAttributes, "Attributes of the job")
+	a.Required("id", "type", "attributes")
+})
+
+// jobDataAttributes represents the attributes of a background job
+var jobDataAttributes = a.Type("JobDataAttributes", func() {
+	a.Attribute("schedule", d.String, "The schedule of the job, as a cron expression")
+	a.Attribute("paused", d.Boolean, "Whether the scheduled runs of the job are suspended")
+	a.Attribute("trigger-requested-at", d.DateTime, "The time at which a run out of the schedule was requested")
+	a.Attribute("trigger-requested-by", d.String, "The name of the account which requested a run out of the schedule")
+	a.Attribute("last-run", jobRunDataAttributes, "The latest run of the job")
+	a.Required("schedule", "paused")
+})
+
+var jobRunList = JSONList(
+	"JobRun", "Holds the list of runs of a background job",
+	jobRunData,
+	nil,
+	nil)
+
+// jobRunData represents a run of a background job
+var jobRunData = a.Type("JobRunData", func() {
+	a.Attribute("id", d.String, "ID of the run")
+	a.Attribute("type", d.String, "type of the run")
+	a.Attribute("attributes", jobRunDataAttributes, "Attributes of the run")
+	a.Required("id", "type", "attributes")
+})
+
+// jobRunDataAttributes represents the attributes of a run of a background job
+var jobRunDataAttributes = a.Type("JobRunDataAttributes", func() {
+	a.Attribute("owner", d.String, "The pod which performed the run")
+	a.Attribute("trigger", d.String, "What started the run: 'schedule' or 'manual'")
+	a.Attribute("started-at", d.DateTime, "The time at which the run started")
+	a.Attribute("ended-at", d.DateTime, "The time at which the run ended")
+	a.Attribute("outcome", d.String, "The outcome of the run: 'running', 'success' or 'failure'")
+	a.Attribute("items-processed", d.Integer, "The number of items processed during the run")
+	a.Attribute("error", d.String, "The error which made the run fail")
+	a.Required("owner", "trigger", "started-at", "outcome", "items-processed")
+})
@@ -0,0 +1,129 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+var _ = a.Resource("mfa", func() {
+
+	a.BasePath("/mfa")
+
+	a.Action("enrollTOTP", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/totp"),
+		)
+		a.Description("Start the TOTP enrollment of the current user. The enrollment must then be confirmed with a code generated by the authenticator application")
+		a.Response(d.OK, totpEnrollmentMedia)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+	})
+
+	a.Action("confirmTOTP", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/totp/confirm"),
+		)
+		a.Description("Confirm the TOTP enrollment of the current user, and return the recovery codes. The recovery codes are only returned once")
+		a.Payload(totpCodeMedia)
+		a.Response(d.OK, mfaRecoveryCodesMedia)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+	})
+
+	a.Action("disableTOTP", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/totp"),
+		)
+		a.Description("Disable the multi-factor authentication of the current user. Requires a multi-factor authenticated token")
+		a.Response(d.NoContent)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+	})
+
+	a.Action("verify", func() {
+		a.Routing(
+			a.POST("/verify"),
+		)
+		a.Description("Verify the TOTP or recovery code of a login challenge, and return the URL to which the client should redirect to complete the login")
+		a.Payload(mfaVerificationRequestMedia)
+		a.Response(d.OK, mfaVerificationResponseMedia)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response("TooManyRequests", JSONAPIErrors)
+	})
+})
+
+var totpEnrollmentMedia = a.MediaType("application/vnd.totp-enrollment+json", func() {
+	a.TypeName("TOTPEnrollment")
+	a.Description("TOTP secret to configure in the authenticator application")
+	a.Attributes(func() {
+		a.Attribute("secret", d.String, "The base32-encoded TOTP secret")
+		a.Attribute("key_uri", d.String, "The otpauth:// URI of the secret, to render as a QR code")
+		a.Required("secret", "key_uri")
+	})
+	a.View("default", func() {
+		a.Attribute("secret")
+		a.Attribute("key_uri")
+	})
+})
+
+var totpCodeMedia = a.MediaType("application/vnd.totp-code+json", func() {
+	a.TypeName("TOTPCode")
+	a.Description("A code generated by the authenticator application")
+	a.Attributes(func() {
+		a.Attribute("code", d.String, "The TOTP code")
+		a.Required("code")
+	})
+	a.View("default", func() {
+		a.Attribute("code")
+	})
+})
+
+var mfaRecoveryCodesMedia = a.MediaType("application/vnd.mfa-recovery-codes+json", func() {
+	a.TypeName("MFARecoveryCodes")
+	a.Description("Single-use codes which can be used instead of a TOTP code")
+	a.Attributes(func() {
+		a.Attribute("recovery_codes", a.ArrayOf(d.String), "The recovery codes")
+		a.Required("recovery_codes")
+	})
+	a.View("default", func() {
+		a.Attribute("recovery_codes")
+	})
+})
+
+var mfaVerificationRequestMedia = a.MediaType("application/vnd.mfa-verification-request+json", func() {
+	a.TypeName("MFAVerificationRequest")
+	a.Description("Request payload required to verify the second factor of a login")
+	a.Attributes(func() {
+		a.Attribute("challenge", d.String, "The ID of the login challenge, as passed to the MFA verification page")
+		a.Attribute("code", d.String, "The TOTP or recovery code")
+		a.Required("challenge", "code")
+	})
+	a.View("default", func() {
+		a.Attribute("challenge")
+		a.Attribute("code")
+	})
+})
+
+var mfaVerificationResponseMedia = a.MediaType("application/vnd.mfa-verification-response+json", func() {
+	a.TypeName("MFAVerificationResponse")
+	a.Description("Response returned when the second factor of a login was verified")
+	a.Attributes(func() {
+		a.Attribute("redirect_url", d.String, "The URL to which the client should redirect to complete the login")
+		a.Required("redirect_url")
+	})
+	a.View("default", func() {
+		a.Attribute("redirect_url")
+	})
+})
@@ -13,10 +13,12 @@ var _ = a.Resource("namedusers", func() {
 		a.Routing(
 			a.PATCH("/:username/ban"),
 		)
-		a.Description("ban the user")
+		a.Description(`ban the user. The optional payload specifies the reason and the period of the ban. Without payload,
+or without end time, the ban is permanent until it is lifted. A ban starting in the future takes effect at its start time.`)
 		a.Params(func() {
 			a.Param("username", d.String, "Username")
 		})
+		a.OptionalPayload(banUser)
 		a.Response(d.OK, func() {
 			a.Media(showUser)
 		})
@@ -44,6 +46,42 @@ var _ = a.Resource("namedusers", func() {
 		a.Response(d.Forbidden, JSONAPIErrors)
 	})
 
+	a.Action("export", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:username/export"),
+		)
+		a.Description(`Export the personal data of the user. The response is '202 Accepted' while the export is being
+generated, in which case the client should request it again later.`)
+		a.Params(func() {
+			a.Param("username", d.String, "Username")
+		})
+		a.Response(d.OK, userDataExport)
+		a.Response(d.Accepted, func() {
+			a.Media(userDataExport)
+		})
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+
+	a.Action("bans", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:username/bans"),
+		)
+		a.Description("List the current, scheduled and past bans of the user, the most recent ones first")
+		a.Params(func() {
+			a.Param("username", d.String, "Username")
+		})
+		a.Response(d.OK, userBanList)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+
 	a.Action("deactivate", func() {
 		a.Security("jwt")
 		a.Routing(
@@ -61,4 +99,251 @@ var _ = a.Resource("namedusers", func() {
 		a.Response(d.Unauthorized, JSONAPIErrors)
 		a.Response(d.Forbidden, JSONAPIErrors)
 	})
+
+	a.Action("updateProfile", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PATCH("/:username/profile"),
+		)
+		a.Description("Set the values of the custom profile attributes of the user, including the attributes only visible to the admins")
+		a.Params(func() {
+			a.Param("username", d.String, "Username")
+		})
+		a.Payload(updateUserProfile)
+		a.Response(d.OK, func() {
+			a.Media(showUser)
+		})
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+
+	a.Action("merge", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/:username/merge"),
+		)
+		a.Description(`Merge the user given in the payload into this user, when the same person ended up with two accounts
+after signing in with a different identity provider or email address. The identities, roles, memberships, invitations
+and tokens of the source user are moved to this user, and the source user is deleted.`)
+		a.Params(func() {
+			a.Param("username", d.String, "Username of the user into which the source user is merged")
+		})
+		a.Payload(mergeUser)
+		a.Response(d.OK, func() {
+			a.Media(showUser)
+		})
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+
+	a.Action("bulk", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/bulk"),
+		)
+		a.Description(`Apply an operation to a list of users, or to the users matching a filter. The operation is applied
+asynchronously and at a limited rate: the response is '202 Accepted', and the client should request the operation again
+later to get the result for each user. The request is rejected with '400 Bad Request' if some of the given usernames
+are unknown.`)
+		a.Payload(createUserBulkOperation)
+		a.Response(d.Accepted, func() {
+			a.Media(userBulkOperationSingle)
+		})
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+
+	a.Action("showBulk", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/bulk/:id"),
+		)
+		a.Description("Show a bulk operation, along with the result for each of its users")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the bulk operation")
+		})
+		a.Response(d.OK, userBulkOperationSingle)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+})
+
+// banUser represents the reason and the period of a ban
+var banUser = a.MediaType("application/vnd.banuser+json", func() {
+	a.UseTrait("jsonapi-media-type")
+	a.TypeName("BanUser")
+	a.Description("User Ban")
+	a.Attributes(func() {
+		a.Attribute("data", banUserData)
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+// banUserData represents the reason and the period of a ban
+var banUserData = a.Type("BanUserData", func() {
+	a.Attribute("type", d.String, "type of the ban")
+	a.Attribute("attributes", banUserDataAttributes, "Attributes of the ban")
+	a.Required("attributes")
+})
+
+// banUserDataAttributes represents the reason and the period of a ban
+var banUserDataAttributes = a.Type("BanUserDataAttributes", func() {
+	a.Attribute("reason-category", d.String, "The category of the reason of the ban", func() {
+		a.Enum("abuse", "spam", "terms_violation", "security", "other")
+	})
+	a.Attribute("reason", d.String, "The details about the reason of the ban")
+	a.Attribute("starts-at", d.DateTime, "The time at which the ban takes effect. Defaults to now")
+	a.Attribute("ends-at", d.DateTime, "The time at which the ban ends. The ban is permanent if not specified")
+})
+
+var userBanList = JSONList(
+	"UserBan", "Holds the list of bans of a user",
+	userBanData,
+	nil,
+	nil)
+
+// userBanData represents a current, scheduled or past ban of a user
+var userBanData = a.Type("UserBanData", func() {
+	a.Attribute("id", d.String, "ID of the ban")
+	a.Attribute("type", d.String, "type of the ban")
+	a.Attribute("attributes", userBanDataAttributes, "Attributes of the ban")
+	a.Required("id", "type", "attributes")
+})
+
+// userBanDataAttributes represents the attributes of a ban of a user
+var userBanDataAttributes = a.Type("UserBanDataAttributes", func() {
+	a.Attribute("reason-category", d.String, "The category of the reason of the ban")
+	a.Attribute("reason", d.String, "The details about the reason of the ban")
+	a.Attribute("actor", d.String, "The name of the user or service account who banned the user")
+	a.Attribute("starts-at", d.DateTime, "The time at which the ban takes effect")
+	a.Attribute("ends-at", d.DateTime, "The time at which the ban ends, if not permanent")
+	a.Attribute("lifted-at", d.DateTime, "The time at which the ban was lifted")
+	a.Attribute("lifted-by", d.String, "The name of the user or service account who lifted the ban")
+	a.Attribute("active", d.Boolean, "Whether the ban is currently in effect")
+	a.Required("reason-category", "actor", "starts-at", "active")
+})
+
+// updateUserProfile represents the values of the custom profile attributes of a user to set
+var updateUserProfile = a.Type("UpdateUserProfile", func() {
+	a.Attribute("data", updateUserProfileData)
+	a.Required("data")
+})
+
+// updateUserProfileData represents the values of the custom profile attributes of a user to set
+var updateUserProfileData = a.Type("UpdateUserProfileData", func() {
+	a.Attribute("type", d.String, "type of the profile")
+	a.Attribute("attributes", updateUserProfileDataAttributes, "Attributes of the profile")
+	a.Required("attributes")
+})
+
+// updateUserProfileDataAttributes represents the values of the custom profile attributes of a user to set
+var updateUserProfileDataAttributes = a.Type("UpdateUserProfileDataAttributes", func() {
+	a.Attribute("profileAttributes", a.HashOf(d.String, d.Any), "The values of the custom profile attributes to set. A null value unsets the attribute")
+	a.Required("profileAttributes")
+})
+
+// mergeUser represents the user to merge into another user
+var mergeUser = a.Type("MergeUser", func() {
+	a.Attribute("data", mergeUserData)
+	a.Required("data")
+})
+
+// mergeUserData represents the user to merge into another user
+var mergeUserData = a.Type("MergeUserData", func() {
+	a.Attribute("type", d.String, "type of the merge")
+	a.Attribute("attributes", mergeUserDataAttributes, "Attributes of the merge")
+	a.Required("attributes")
+})
+
+// mergeUserDataAttributes represents the user to merge into another user
+var mergeUserDataAttributes = a.Type("MergeUserDataAttributes", func() {
+	a.Attribute("source", d.String, "The username of the user to merge, which is deleted once merged")
+	a.Required("source")
+})
+
+// createUserBulkOperation represents a request to apply an operation to a list of users
+var createUserBulkOperation = a.Type("CreateUserBulkOperation", func() {
+	a.Attribute("data", createUserBulkOperationData)
+	a.Required("data")
+})
+
+// createUserBulkOperationData represents a request to apply an operation to a list of users
+var createUserBulkOperationData = a.Type("CreateUserBulkOperationData", func() {
+	a.Attribute("type", d.String, "type of the bulk operation")
+	a.Attribute("attributes", createUserBulkOperationDataAttributes, "Attributes of the bulk operation")
+	a.Required("attributes")
+})
+
+// createUserBulkOperationDataAttributes represents the operation, its users and its parameters
+var createUserBulkOperationDataAttributes = a.Type("CreateUserBulkOperationDataAttributes", func() {
+	a.Attribute("operation", d.String, "The operation to apply to the users", func() {
+		a.Enum("ban", "deactivate", "move")
+	})
+	a.Attribute("usernames", a.ArrayOf(d.String), "The usernames of the users. Mutually exclusive with the filter")
+	a.Attribute("filter", userBulkOperationFilter, "The filter matching the users. Mutually exclusive with the usernames")
+	a.Attribute("reason-category", d.String, "The category of the reason of the ban, for the 'ban' operation", func() {
+		a.Enum("abuse", "spam", "terms_violation", "security", "other")
+	})
+	a.Attribute("reason", d.String, "The details about the reason of the ban, for the 'ban' operation")
+	a.Attribute("ends-at", d.DateTime, "The time at which the ban ends, for the 'ban' operation. The ban is permanent if not specified")
+	a.Attribute("cluster", d.String, "The API URL of the cluster to which the users are moved, for the 'move' operation")
+	a.Required("operation")
+})
+
+// userBulkOperationFilter represents the attributes of the users of a bulk operation
+var userBulkOperationFilter = a.Type("UserBulkOperationFilter", func() {
+	a.Attribute("cluster", d.String, "The API URL of the cluster of the users")
+	a.Attribute("feature-level", d.String, "The feature level of the users")
+})
+
+var userBulkOperationSingle = JSONSingle(
+	"UserBulkOperation", "Holds a single bulk operation on users",
+	userBulkOperationData,
+	nil)
+
+// userBulkOperationData represents an operation applied to a list of users
+var userBulkOperationData = a.Type("UserBulkOperationData", func() {
+	a.Attribute("id", d.UUID, "ID of the bulk operation")
+	a.Attribute("type", d.String, "type of the bulk operation")
+	a.Attribute("attributes", userBulkOperationDataAttributes, "Attributes of the bulk operation")
+	a.Required("id", "type", "attributes")
+})
+
+// userBulkOperationDataAttributes represents the status of a bulk operation and the result for each of its users
+var userBulkOperationDataAttributes = a.Type("UserBulkOperationDataAttributes", func() {
+	a.Attribute("operation", d.String, "The operation applied to the users")
+	a.Attribute("parameters", a.HashOf(d.String, d.Any), "The parameters of the operation")
+	a.Attribute("requested-by", d.String, "The name of the service account which requested the operation")
+	a.Attribute("status", d.String, "The status of the operation: 'running' until all the users are processed, then 'completed'")
+	a.Attribute("created-at", d.DateTime, "The time at which the operation was requested")
+	a.Attribute("completed-at", d.DateTime, "The time at which the last user was processed")
+	a.Attribute("total", d.Integer, "The number of users of the operation")
+	a.Attribute("pending", d.Integer, "The number of users not processed yet")
+	a.Attribute("succeeded", d.Integer, "The number of users to which the operation was applied")
+	a.Attribute("failed", d.Integer, "The number of users to which the operation could not be applied")
+	a.Attribute("items", a.ArrayOf(userBulkOperationItem), "The result for each user")
+	a.Required("operation", "requested-by", "status", "created-at", "total", "pending", "succeeded", "failed")
+})
+
+// userBulkOperationItem represents the result of a bulk operation for a single user
+var userBulkOperationItem = a.Type("UserBulkOperationItem", func() {
+	a.Attribute("username", d.String, "The username of the user")
+	a.Attribute("status", d.String, "The result for the user: 'pending', 'succeeded' or 'failed'")
+	a.Attribute("error", d.String, "The reason why the operation could not be applied to the user")
+	a.Attribute("completed-at", d.DateTime, "The time at which the user was processed")
+	a.Required("username", "status")
 })
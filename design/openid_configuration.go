@@ -25,6 +25,8 @@ var openIDConfiguration = a.MediaType("application/vnd.openIDConfiguration+json"
 		a.Attribute("scopes_supported", a.ArrayOf(d.String), "RECOMMENDED. JSON array containing a list of the OAuth 2.0 scope values that this server supports. The server MUST support the `openid` scope value.")
 		a.Attribute("claims_supported", a.ArrayOf(d.String), "RECOMMENDED. JSON array containing a list of the Claim Names of the Claims that the OpenID Provider MAY be able to supply values for. Note that for privacy or other reasons, this might not be an exhaustive list.")
 		a.Attribute("token_endpoint_auth_methods_supported", a.ArrayOf(d.String), "OPTIONAL. JSON array containing a list of Client Authentication methods supported by this Token Endpoint. The options are client_secret_post, client_secret_basic, client_secret_jwt, and private_key_jwt etc.")
+		a.Attribute("backchannel_logout_supported", d.Boolean, "OPTIONAL. Boolean value specifying whether the OpenID Provider supports back-channel logout, with true indicating support.")
+		a.Attribute("backchannel_logout_session_supported", d.Boolean, "OPTIONAL. Boolean value specifying whether the OpenID Provider can pass a sid (session ID) Claim in the Logout Token to identify the RP session with the OpenID Provider.")
 	})
 	a.View("default", func() {
 		a.Attribute("issuer", d.String, "")
@@ -40,6 +42,8 @@ var openIDConfiguration = a.MediaType("application/vnd.openIDConfiguration+json"
 		a.Attribute("scopes_supported", a.ArrayOf(d.String), "")
 		a.Attribute("claims_supported", a.ArrayOf(d.String), "")
 		a.Attribute("token_endpoint_auth_methods_supported", a.ArrayOf(d.String), "")
+		a.Attribute("backchannel_logout_supported", d.Boolean, "")
+		a.Attribute("backchannel_logout_session_supported", d.Boolean, "")
 	})
 })
 
@@ -33,6 +33,116 @@ var _ = a.Resource("organization", func() {
 		a.Response(d.InternalServerError, JSONAPIErrors)
 		a.Response(d.BadRequest, JSONAPIErrors)
 	})
+
+	a.Action("update", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PATCH("/:id"),
+		)
+		a.Params(func() {
+			a.Param("id", d.UUID, "The identifier of the organization")
+		})
+		a.Description("Rename an organization")
+		a.Payload(UpdateOrganizationRequestMedia)
+		a.Response(d.OK, OrganizationMedia)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("delete", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:id"),
+		)
+		a.Params(func() {
+			a.Param("id", d.UUID, "The identifier of the organization")
+			a.Param("detachSpaces", d.Boolean, "Detach the spaces attached to the organization instead of refusing the deletion", func() {
+				a.Default(false)
+			})
+		})
+		a.Description("Delete an organization, along with its memberships, invitations and role assignments")
+		a.Response(d.NoContent)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("listMembers", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:id/members"),
+		)
+		a.Params(func() {
+			a.Param("id", d.UUID, "The identifier of the organization")
+		})
+		a.Description("List the members of an organization")
+		a.Response(d.OK, userList)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("removeMember", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:id/members/:memberID"),
+		)
+		a.Params(func() {
+			a.Param("id", d.UUID, "The identifier of the organization")
+			a.Param("memberID", d.UUID, "The identity ID of the member to remove")
+		})
+		a.Description("Remove a member from an organization")
+		a.Response(d.NoContent)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("transferOwnership", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PUT("/:id/owner"),
+		)
+		a.Params(func() {
+			a.Param("id", d.UUID, "The identifier of the organization")
+		})
+		a.Description("Transfer the ownership of an organization to one of its members")
+		a.Payload(TransferOrganizationOwnershipRequestMedia)
+		a.Response(d.NoContent)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("attachSpace", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/:id/spaces"),
+		)
+		a.Params(func() {
+			a.Param("id", d.UUID, "The identifier of the organization")
+		})
+		a.Description("Attach an existing space to an organization")
+		a.Payload(AttachSpaceRequestMedia)
+		a.Response(d.NoContent)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
 })
 
 var CreateOrganizationRequestMedia = a.MediaType("application/vnd.create_organization_request+json", func() {
@@ -55,6 +165,53 @@ var CreateOrganizationResponseMedia = a.MediaType("application/vnd.create_organi
 	})
 })
 
+var UpdateOrganizationRequestMedia = a.MediaType("application/vnd.update_organization_request+json", func() {
+	a.Description("Request payload required to rename an organization")
+	a.Attributes(func() {
+		a.Attribute("name", d.String, "The new name of the organization")
+		a.Required("name")
+	})
+	a.View("default", func() {
+		a.Attribute("name")
+	})
+})
+
+var TransferOrganizationOwnershipRequestMedia = a.MediaType("application/vnd.transfer_organization_ownership_request+json", func() {
+	a.Description("Request payload required to transfer the ownership of an organization")
+	a.Attributes(func() {
+		a.Attribute("owner_id", d.UUID, "The identity ID of the new owner of the organization")
+		a.Required("owner_id")
+	})
+	a.View("default", func() {
+		a.Attribute("owner_id")
+	})
+})
+
+var AttachSpaceRequestMedia = a.MediaType("application/vnd.attach_space_request+json", func() {
+	a.Description("Request payload required to attach a space to an organization")
+	a.Attributes(func() {
+		a.Attribute("space_id", d.String, "The identifier of the space to attach")
+		a.Required("space_id")
+	})
+	a.View("default", func() {
+		a.Attribute("space_id")
+	})
+})
+
+var OrganizationMedia = a.MediaType("application/vnd.organization+json", func() {
+	a.TypeName("Organization")
+	a.Description("An organization")
+	a.Attributes(func() {
+		a.Attribute("id", d.String, "The identifier of the organization")
+		a.Attribute("name", d.String, "The name of the organization")
+		a.Required("id", "name")
+	})
+	a.View("default", func() {
+		a.Attribute("id")
+		a.Attribute("name")
+	})
+})
+
 var organizationArray = a.MediaType("application/vnd.organization-array+json", func() {
 	a.UseTrait("jsonapi-media-type")
 	a.TypeName("OrganizationArray")
@@ -0,0 +1,96 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+var _ = a.Resource("profileattributes", func() {
+	a.BasePath("/profileattributes")
+
+	a.Action("list", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET(""),
+		)
+		a.Description(`List the custom attributes of the user profiles, with their validation rules. The attributes which are
+only visible to the admins are not listed for the users.`)
+		a.Response(d.OK, userProfileAttributeList)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+	})
+
+	a.Action("save", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PUT("/:name"),
+		)
+		a.Description(`Create or update a custom attribute of the user profiles. The values already set are not validated
+again against the new rules, and the type of an existing attribute cannot be changed.`)
+		a.Params(func() {
+			a.Param("name", d.String, "The name of the attribute")
+		})
+		a.Payload(userProfileAttributeSingle)
+		a.Response(d.OK, userProfileAttributeSingle)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+
+	a.Action("delete", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:name"),
+		)
+		a.Description("Delete a custom attribute of the user profiles, along with its values for all the users")
+		a.Params(func() {
+			a.Param("name", d.String, "The name of the attribute")
+		})
+		a.Response(d.NoContent)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+})
+
+var userProfileAttributeList = JSONList(
+	"UserProfileAttribute", "Holds the list of the custom attributes of the user profiles",
+	userProfileAttributeData,
+	nil,
+	nil)
+
+var userProfileAttributeSingle = JSONSingle(
+	"UserProfileAttribute", "Holds a single custom attribute of the user profiles",
+	userProfileAttributeData,
+	nil)
+
+// userProfileAttributeData represents the definition of a custom attribute of the user profiles
+var userProfileAttributeData = a.Type("UserProfileAttributeData", func() {
+	a.Attribute("id", d.String, "The name of the attribute")
+	a.Attribute("type", d.String, "type of the attribute")
+	a.Attribute("attributes", userProfileAttributeDataAttributes, "Attributes of the attribute")
+	a.Required("type", "attributes")
+})
+
+// userProfileAttributeDataAttributes represents the type, the visibility and the validation rules of an attribute
+var userProfileAttributeDataAttributes = a.Type("UserProfileAttributeDataAttributes", func() {
+	a.Attribute("description", d.String, "The description of the attribute")
+	a.Attribute("value-type", d.String, "The type of the values of the attribute", func() {
+		a.Enum("string", "integer", "boolean")
+	})
+	a.Attribute("visibility", d.String, `Who can see the values of the attribute: everyone, the user and the admins, or
+only the admins, who are then the only ones to set them`, func() {
+		a.Enum("public", "private", "admin")
+	})
+	a.Attribute("indexable", d.Boolean, "Whether the users can be searched by the values of the attribute, if public")
+	a.Attribute("pattern", d.String, "The regular expression which the whole string values must match")
+	a.Attribute("min-length", d.Integer, "The minimum length of the string values")
+	a.Attribute("max-length", d.Integer, "The maximum length of the string values")
+	a.Attribute("minimum", d.Integer, "The minimum integer value")
+	a.Attribute("maximum", d.Integer, "The maximum integer value")
+	a.Attribute("allowed-values", a.ArrayOf(d.String), "The only values allowed for a string attribute")
+	a.Required("value-type", "visibility")
+})
@@ -0,0 +1,456 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+// The SCIM 2.0 endpoints (RFC 7644) used by the corporate identity management systems to provision the users and
+// the groups. The resources are not JSON-API documents, but SCIM resources with their own content type.
+var _ = a.Resource("scim", func() {
+	a.BasePath("/scim/v2")
+
+	a.Action("serviceProviderConfig", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/ServiceProviderConfig"),
+		)
+		a.Description("Show the SCIM features supported by the service")
+		a.Response(d.OK, scimServiceProviderConfig)
+		a.Response(d.Unauthorized, scimError)
+		a.Response(d.Forbidden, scimError)
+	})
+
+	a.Action("listUsers", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/Users"),
+		)
+		a.Description("List the provisioned users matching the filter")
+		a.Params(scimListParams)
+		a.Response(d.OK, scimUserList)
+		a.Response(d.BadRequest, scimError)
+		a.Response(d.InternalServerError, scimError)
+		a.Response(d.Unauthorized, scimError)
+		a.Response(d.Forbidden, scimError)
+	})
+
+	a.Action("showUser", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/Users/:id"),
+		)
+		a.Description("Show a provisioned user")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the identity of the user")
+		})
+		a.Response(d.OK, scimUser)
+		a.Response(d.NotFound, scimError)
+		a.Response(d.InternalServerError, scimError)
+		a.Response(d.Unauthorized, scimError)
+		a.Response(d.Forbidden, scimError)
+	})
+
+	a.Action("createUser", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/Users"),
+		)
+		a.Description("Provision a new user")
+		a.Payload(scimUserPayload)
+		a.Response(d.Created, scimUser)
+		a.Response(d.BadRequest, scimError)
+		a.Response(d.Conflict, scimError)
+		a.Response(d.InternalServerError, scimError)
+		a.Response(d.Unauthorized, scimError)
+		a.Response(d.Forbidden, scimError)
+	})
+
+	a.Action("replaceUser", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PUT("/Users/:id"),
+		)
+		a.Description("Replace all the attributes of a provisioned user")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the identity of the user")
+		})
+		a.Payload(scimUserPayload)
+		a.Response(d.OK, scimUser)
+		a.Response(d.BadRequest, scimError)
+		a.Response(d.NotFound, scimError)
+		a.Response(d.Conflict, scimError)
+		a.Response(d.InternalServerError, scimError)
+		a.Response(d.Unauthorized, scimError)
+		a.Response(d.Forbidden, scimError)
+	})
+
+	a.Action("patchUser", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PATCH("/Users/:id"),
+		)
+		a.Description("Modify some attributes of a provisioned user")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the identity of the user")
+		})
+		a.Payload(scimPatchPayload)
+		a.Response(d.OK, scimUser)
+		a.Response(d.BadRequest, scimError)
+		a.Response(d.NotFound, scimError)
+		a.Response(d.Conflict, scimError)
+		a.Response(d.InternalServerError, scimError)
+		a.Response(d.Unauthorized, scimError)
+		a.Response(d.Forbidden, scimError)
+	})
+
+	a.Action("deleteUser", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/Users/:id"),
+		)
+		a.Description("Deactivate the account of a provisioned user")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the identity of the user")
+		})
+		a.Response(d.NoContent)
+		a.Response(d.NotFound, scimError)
+		a.Response(d.InternalServerError, scimError)
+		a.Response(d.Unauthorized, scimError)
+		a.Response(d.Forbidden, scimError)
+	})
+
+	a.Action("listGroups", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/Groups"),
+		)
+		a.Description("List the provisioned groups matching the filter")
+		a.Params(scimListParams)
+		a.Response(d.OK, scimGroupList)
+		a.Response(d.BadRequest, scimError)
+		a.Response(d.InternalServerError, scimError)
+		a.Response(d.Unauthorized, scimError)
+		a.Response(d.Forbidden, scimError)
+	})
+
+	a.Action("showGroup", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/Groups/:id"),
+		)
+		a.Description("Show a provisioned group, along with its members")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the identity of the group")
+		})
+		a.Response(d.OK, scimGroup)
+		a.Response(d.NotFound, scimError)
+		a.Response(d.InternalServerError, scimError)
+		a.Response(d.Unauthorized, scimError)
+		a.Response(d.Forbidden, scimError)
+	})
+
+	a.Action("createGroup", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/Groups"),
+		)
+		a.Description("Provision a new group of users")
+		a.Payload(scimGroupPayload)
+		a.Response(d.Created, scimGroup)
+		a.Response(d.BadRequest, scimError)
+		a.Response(d.Conflict, scimError)
+		a.Response(d.InternalServerError, scimError)
+		a.Response(d.Unauthorized, scimError)
+		a.Response(d.Forbidden, scimError)
+	})
+
+	a.Action("replaceGroup", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PUT("/Groups/:id"),
+		)
+		a.Description("Replace the name and the members of a provisioned group")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the identity of the group")
+		})
+		a.Payload(scimGroupPayload)
+		a.Response(d.OK, scimGroup)
+		a.Response(d.BadRequest, scimError)
+		a.Response(d.NotFound, scimError)
+		a.Response(d.Conflict, scimError)
+		a.Response(d.InternalServerError, scimError)
+		a.Response(d.Unauthorized, scimError)
+		a.Response(d.Forbidden, scimError)
+	})
+
+	a.Action("patchGroup", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PATCH("/Groups/:id"),
+		)
+		a.Description("Rename a provisioned group, or add and remove some of its members")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the identity of the group")
+		})
+		a.Payload(scimPatchPayload)
+		a.Response(d.OK, scimGroup)
+		a.Response(d.BadRequest, scimError)
+		a.Response(d.NotFound, scimError)
+		a.Response(d.Conflict, scimError)
+		a.Response(d.InternalServerError, scimError)
+		a.Response(d.Unauthorized, scimError)
+		a.Response(d.Forbidden, scimError)
+	})
+
+	a.Action("deleteGroup", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/Groups/:id"),
+		)
+		a.Description("Delete a provisioned group. Its members are not deleted.")
+		a.Params(func() {
+			a.Param("id", d.UUID, "ID of the identity of the group")
+		})
+		a.Response(d.NoContent)
+		a.Response(d.NotFound, scimError)
+		a.Response(d.InternalServerError, scimError)
+		a.Response(d.Unauthorized, scimError)
+		a.Response(d.Forbidden, scimError)
+	})
+})
+
+// scimListParams the paging and filtering parameters of the list actions
+var scimListParams = func() {
+	a.Param("filter", d.String, `SCIM filter of the resources, eg: 'userName eq "john"'`)
+	a.Param("startIndex", d.Integer, "1-based index of the first resource to return", func() {
+		a.Minimum(1)
+		a.Default(1)
+	})
+	a.Param("count", d.Integer, "Maximum number of resources to return", func() {
+		a.Minimum(0)
+		a.Maximum(200)
+		a.Default(100)
+	})
+}
+
+var scimName = a.Type("SCIMName", func() {
+	a.Attribute("formatted", d.String, "The full name of the user")
+	a.Attribute("givenName", d.String, "The given name of the user")
+	a.Attribute("familyName", d.String, "The family name of the user")
+})
+
+var scimEmail = a.Type("SCIMEmail", func() {
+	a.Attribute("value", d.String, "The email address")
+	a.Attribute("type", d.String, "The type of email address, eg: 'work'")
+	a.Attribute("primary", d.Boolean, "True if this is the primary email address of the user")
+	a.Required("value")
+})
+
+var scimMember = a.Type("SCIMMember", func() {
+	a.Attribute("value", d.String, "The ID of the identity of the member")
+	a.Attribute("display", d.String, "The username of the member")
+	a.Required("value")
+})
+
+var scimMeta = a.Type("SCIMMeta", func() {
+	a.Attribute("resourceType", d.String, "The type of the resource: 'User' or 'Group'")
+	a.Attribute("created", d.DateTime, "The time at which the resource was created")
+	a.Attribute("lastModified", d.DateTime, "The time at which the resource was last modified")
+	a.Attribute("location", d.String, "The URL of the resource")
+	a.Required("resourceType", "location")
+})
+
+var scimUserPayload = a.Type("SCIMUserPayload", func() {
+	a.Attribute("schemas", a.ArrayOf(d.String), "The URNs of the schemas of the resource")
+	a.Attribute("externalId", d.String, "The ID of the user in the identity management system")
+	a.Attribute("userName", d.String, "The username of the user")
+	a.Attribute("displayName", d.String, "The full name of the user")
+	a.Attribute("name", scimName, "The components of the name of the user")
+	a.Attribute("emails", a.ArrayOf(scimEmail), "The email addresses of the user. Only the primary one is kept.")
+	a.Attribute("active", d.Boolean, "False if the user is not allowed to log in. Defaults to true. A user banned for another reason than the deactivation by the provisioning client can not be reactivated.")
+	a.Required("userName", "emails")
+})
+
+var scimGroupPayload = a.Type("SCIMGroupPayload", func() {
+	a.Attribute("schemas", a.ArrayOf(d.String), "The URNs of the schemas of the resource")
+	a.Attribute("externalId", d.String, "The ID of the group in the identity management system")
+	a.Attribute("displayName", d.String, "The name of the group")
+	a.Attribute("members", a.ArrayOf(scimMember), "The users who are members of the group")
+	a.Required("displayName")
+})
+
+var scimPatchOperation = a.Type("SCIMPatchOperation", func() {
+	a.Attribute("op", d.String, "The operation: 'add', 'remove' or 'replace'")
+	a.Attribute("path", d.String, "The path of the modified attribute")
+	a.Attribute("value", d.Any, "The new value of the attribute, or the values to add or remove")
+	a.Required("op")
+})
+
+var scimPatchPayload = a.Type("SCIMPatchPayload", func() {
+	a.Attribute("schemas", a.ArrayOf(d.String), "The URN of the schema of the PATCH requests")
+	a.Attribute("Operations", a.ArrayOf(scimPatchOperation), "The operations to apply, in order")
+	a.Required("Operations")
+})
+
+var scimUser = a.MediaType("application/vnd.scim.user+json", func() {
+	a.UseTrait("scim-media-type")
+	a.TypeName("SCIMUser")
+	a.Description("A user provisioned with SCIM")
+	a.Attributes(func() {
+		a.Attribute("schemas", a.ArrayOf(d.String))
+		a.Attribute("id", d.String, "The ID of the identity of the user")
+		a.Attribute("userName", d.String)
+		a.Attribute("displayName", d.String)
+		a.Attribute("name", scimName)
+		a.Attribute("emails", a.ArrayOf(scimEmail))
+		a.Attribute("active", d.Boolean)
+		a.Attribute("meta", scimMeta)
+		a.Required("schemas", "id", "userName", "emails", "active", "meta")
+	})
+	a.View("default", func() {
+		a.Attribute("schemas")
+		a.Attribute("id")
+		a.Attribute("userName")
+		a.Attribute("displayName")
+		a.Attribute("name")
+		a.Attribute("emails")
+		a.Attribute("active")
+		a.Attribute("meta")
+	})
+})
+
+var scimUserList = a.MediaType("application/vnd.scim.user-list+json", func() {
+	a.UseTrait("scim-media-type")
+	a.TypeName("SCIMUserList")
+	a.Description("A page of the users provisioned with SCIM")
+	a.Attributes(func() {
+		a.Attribute("schemas", a.ArrayOf(d.String))
+		a.Attribute("totalResults", d.Integer, "The total number of users matching the filter")
+		a.Attribute("startIndex", d.Integer, "The 1-based index of the first user of the page")
+		a.Attribute("itemsPerPage", d.Integer, "The number of users in the page")
+		a.Attribute("Resources", a.CollectionOf(scimUser))
+		a.Required("schemas", "totalResults", "startIndex", "itemsPerPage", "Resources")
+	})
+	a.View("default", func() {
+		a.Attribute("schemas")
+		a.Attribute("totalResults")
+		a.Attribute("startIndex")
+		a.Attribute("itemsPerPage")
+		a.Attribute("Resources")
+	})
+})
+
+var scimGroup = a.MediaType("application/vnd.scim.group+json", func() {
+	a.UseTrait("scim-media-type")
+	a.TypeName("SCIMGroup")
+	a.Description("A group of users provisioned with SCIM")
+	a.Attributes(func() {
+		a.Attribute("schemas", a.ArrayOf(d.String))
+		a.Attribute("id", d.String, "The ID of the identity of the group")
+		a.Attribute("displayName", d.String)
+		a.Attribute("members", a.ArrayOf(scimMember))
+		a.Attribute("meta", scimMeta)
+		a.Required("schemas", "id", "displayName", "members", "meta")
+	})
+	a.View("default", func() {
+		a.Attribute("schemas")
+		a.Attribute("id")
+		a.Attribute("displayName")
+		a.Attribute("members")
+		a.Attribute("meta")
+	})
+})
+
+var scimGroupList = a.MediaType("application/vnd.scim.group-list+json", func() {
+	a.UseTrait("scim-media-type")
+	a.TypeName("SCIMGroupList")
+	a.Description("A page of the groups provisioned with SCIM")
+	a.Attributes(func() {
+		a.Attribute("schemas", a.ArrayOf(d.String))
+		a.Attribute("totalResults", d.Integer, "The total number of groups matching the filter")
+		a.Attribute("startIndex", d.Integer, "The 1-based index of the first group of the page")
+		a.Attribute("itemsPerPage", d.Integer, "The number of groups in the page")
+		a.Attribute("Resources", a.CollectionOf(scimGroup))
+		a.Required("schemas", "totalResults", "startIndex", "itemsPerPage", "Resources")
+	})
+	a.View("default", func() {
+		a.Attribute("schemas")
+		a.Attribute("totalResults")
+		a.Attribute("startIndex")
+		a.Attribute("itemsPerPage")
+		a.Attribute("Resources")
+	})
+})
+
+var scimSupport = a.Type("SCIMSupport", func() {
+	a.Attribute("supported", d.Boolean, "True if the feature is supported")
+	a.Required("supported")
+})
+
+var scimFilterSupport = a.Type("SCIMFilterSupport", func() {
+	a.Attribute("supported", d.Boolean, "True if the filters are supported")
+	a.Attribute("maxResults", d.Integer, "The maximum number of resources returned in a response")
+	a.Required("supported", "maxResults")
+})
+
+var scimBulkSupport = a.Type("SCIMBulkSupport", func() {
+	a.Attribute("supported", d.Boolean, "True if the bulk operations are supported")
+	a.Attribute("maxOperations", d.Integer, "The maximum number of operations in a bulk request")
+	a.Attribute("maxPayloadSize", d.Integer, "The maximum size of a bulk request, in bytes")
+	a.Required("supported", "maxOperations", "maxPayloadSize")
+})
+
+var scimAuthenticationScheme = a.Type("SCIMAuthenticationScheme", func() {
+	a.Attribute("type", d.String, "The type of authentication scheme, eg: 'oauthbearertoken'")
+	a.Attribute("name", d.String, "The name of the authentication scheme")
+	a.Attribute("description", d.String, "The description of the authentication scheme")
+	a.Attribute("primary", d.Boolean, "True if this is the preferred authentication scheme")
+	a.Required("type", "name", "description")
+})
+
+var scimServiceProviderConfig = a.MediaType("application/vnd.scim.service-provider-config+json", func() {
+	a.UseTrait("scim-media-type")
+	a.TypeName("SCIMServiceProviderConfig")
+	a.Description("The SCIM features supported by the service")
+	a.Attributes(func() {
+		a.Attribute("schemas", a.ArrayOf(d.String))
+		a.Attribute("patch", scimSupport)
+		a.Attribute("bulk", scimBulkSupport)
+		a.Attribute("filter", scimFilterSupport)
+		a.Attribute("changePassword", scimSupport)
+		a.Attribute("sort", scimSupport)
+		a.Attribute("etag", scimSupport)
+		a.Attribute("authenticationSchemes", a.ArrayOf(scimAuthenticationScheme))
+		a.Required("schemas", "patch", "bulk", "filter", "changePassword", "sort", "etag", "authenticationSchemes")
+	})
+	a.View("default", func() {
+		a.Attribute("schemas")
+		a.Attribute("patch")
+		a.Attribute("bulk")
+		a.Attribute("filter")
+		a.Attribute("changePassword")
+		a.Attribute("sort")
+		a.Attribute("etag")
+		a.Attribute("authenticationSchemes")
+	})
+})
+
+var scimError = a.MediaType("application/vnd.scim.error+json", func() {
+	a.UseTrait("scim-media-type")
+	a.TypeName("SCIMError")
+	a.Description("A SCIM error response")
+	a.Attributes(func() {
+		a.Attribute("schemas", a.ArrayOf(d.String))
+		a.Attribute("status", d.String, "The HTTP status code")
+		a.Attribute("scimType", d.String, "The SCIM type of the error, eg: 'invalidFilter' or 'uniqueness'")
+		a.Attribute("detail", d.String, "The description of the error")
+		a.Required("schemas", "status")
+	})
+	a.View("default", func() {
+		a.Attribute("schemas")
+		a.Attribute("status")
+		a.Attribute("scimType")
+		a.Attribute("detail")
+	})
+})
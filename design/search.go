@@ -13,11 +13,16 @@ var _ = a.Resource("search", func() {
 		a.Routing(
 			a.GET("users"),
 		)
-		a.Description("Search by fullname")
+		a.Description(`Search users by username, full name, company or public email. The query may match any part of these
+fields, approximately, and the results are ordered by relevance.`)
 		a.Params(func() {
 			a.Param("q", d.String)
 			a.Param("page[offset]", d.String, "Paging start position") // #428
 			a.Param("page[limit]", d.Integer, "Paging size")
+			a.Param("filter[company]", d.String, "Only return the users of the given company")
+			a.Param("filter[organization]", d.UUID, "Only return the members of the organization with the given ID")
+			a.Param("filter[cluster]", d.String, "Only return the users provisioned on the cluster with the given URL")
+			a.Param("filter[profile]", a.ArrayOf(d.String), "Only return the users with the given values of indexable public profile attributes, as 'name:value'")
 			a.Required("q")
 		})
 		a.Response(d.OK, func() {
@@ -34,6 +34,91 @@ var _ = a.Resource("team", func() {
 		a.Response(d.InternalServerError, JSONAPIErrors)
 		a.Response(d.BadRequest, JSONAPIErrors)
 	})
+
+	a.Action("update", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.PATCH("/:id"),
+		)
+		a.Params(func() {
+			a.Param("id", d.UUID, "The identifier of the team")
+		})
+		a.Description("Rename a team")
+		a.Payload(UpdateTeamRequestMedia)
+		a.Response(d.OK, TeamMedia)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("delete", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:id"),
+		)
+		a.Params(func() {
+			a.Param("id", d.UUID, "The identifier of the team")
+		})
+		a.Description("Delete a team, along with its memberships, invitations and role assignments")
+		a.Response(d.NoContent)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("listMembers", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/:id/members"),
+		)
+		a.Params(func() {
+			a.Param("id", d.UUID, "The identifier of the team")
+		})
+		a.Description("List the members of a team")
+		a.Response(d.OK, userList)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("removeMember", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:id/members/:memberID"),
+		)
+		a.Params(func() {
+			a.Param("id", d.UUID, "The identifier of the team")
+			a.Param("memberID", d.UUID, "The identity ID of the member to remove")
+		})
+		a.Description("Remove a member from a team")
+		a.Response(d.NoContent)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("transfer", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/:id/transfer"),
+		)
+		a.Params(func() {
+			a.Param("id", d.UUID, "The identifier of the team")
+		})
+		a.Description("Transfer a team to another space")
+		a.Payload(TransferTeamRequestMedia)
+		a.Response(d.OK, TeamMedia)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
 })
 
 var CreateTeamRequestMedia = a.MediaType("application/vnd.create_team_request+json", func() {
@@ -58,6 +143,44 @@ var CreateTeamResponseMedia = a.MediaType("application/vnd.create_team_response+
 	})
 })
 
+var UpdateTeamRequestMedia = a.MediaType("application/vnd.update_team_request+json", func() {
+	a.Description("Request payload required to rename a team")
+	a.Attributes(func() {
+		a.Attribute("name", d.String, "The new name of the team")
+		a.Required("name")
+	})
+	a.View("default", func() {
+		a.Attribute("name")
+	})
+})
+
+var TransferTeamRequestMedia = a.MediaType("application/vnd.transfer_team_request+json", func() {
+	a.Description("Request payload required to transfer a team to another space")
+	a.Attributes(func() {
+		a.Attribute("space_id", d.String, "The identifier of the space to which the team is transferred")
+		a.Required("space_id")
+	})
+	a.View("default", func() {
+		a.Attribute("space_id")
+	})
+})
+
+var TeamMedia = a.MediaType("application/vnd.team+json", func() {
+	a.TypeName("Team")
+	a.Description("A team")
+	a.Attributes(func() {
+		a.Attribute("id", d.String, "The identifier of the team")
+		a.Attribute("name", d.String, "The name of the team")
+		a.Attribute("space_id", d.String, "The identifier of the space the team belongs to")
+		a.Required("id", "name", "space_id")
+	})
+	a.View("default", func() {
+		a.Attribute("id")
+		a.Attribute("name")
+		a.Attribute("space_id")
+	})
+})
+
 var identityTeamArray = a.MediaType("application/vnd.identity-team-array+json", func() {
 	a.UseTrait("jsonapi-media-type")
 	a.TypeName("IdentityTeamArray")
@@ -41,6 +41,62 @@ var _ = a.Resource("user", func() {
 		a.Response(d.InternalServerError, JSONAPIErrors)
 		a.Response(d.Unauthorized, JSONAPIErrors)
 	})
+
+	a.Action("listSessions", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/sessions"),
+		)
+		a.Description("List the active sessions of the current user")
+		a.Response(d.OK, userSessionArray)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+	})
+
+	a.Action("terminateSession", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/sessions/:sessionID"),
+		)
+		a.Params(func() {
+			a.Param("sessionID", d.String, "the ID of the session to terminate")
+		})
+		a.Description("Terminate a session of the current user, all the tokens issued from the session are invalidated and the relying parties registered with a back-channel logout URI are notified")
+		a.Response(d.NoContent)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+	})
+
+	a.Action("export", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/export"),
+		)
+		a.Description(`Export the personal data of the current user. The response is '202 Accepted' while the export is
+being generated, in which case the client should request it again later.`)
+		a.Response(d.OK, userDataExport)
+		a.Response(d.Accepted, func() {
+			a.Media(userDataExport)
+		})
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+	})
+
+	a.Action("delete", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE(""),
+		)
+		a.Description(`Request the deletion of the account of the current user. All tokens of the user are revoked and the
+account is deleted at the end of a grace period, unless the user logs in again in the meantime.`)
+		a.Response(d.Accepted)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+	})
 })
 
 // showUser represents an identified user object to show
@@ -118,6 +174,9 @@ var userDataAttributes = a.Type("UserDataAttributes", func() {
 	a.Attribute("contextInformation", a.HashOf(d.String, d.Any), "User context information of any type as a json", func() {
 		a.Example(map[string]interface{}{"last_visited_url": "https://a.openshift.io", "space": "3d6dab8d-f204-42e8-ab29-cdb1c93130ad"})
 	})
+	a.Attribute("profileAttributes", a.HashOf(d.String, d.Any), "The values of the custom profile attributes which are visible to the client", func() {
+		a.Example(map[string]interface{}{"timezone": "Europe/Paris", "github_handle": "octocat"})
+	})
 })
 
 // showUserResources a list of resources in which the user has a role
@@ -134,3 +193,63 @@ var userResourceData = a.Type("UserResourceData", func() {
 	a.Attribute("links", genericLinks)
 	a.Required("id", "type")
 })
+
+// userSessionArray represents the list of the active sessions of a user
+var userSessionArray = a.MediaType("application/vnd.user-session-array+json", func() {
+	a.UseTrait("jsonapi-media-type")
+	a.TypeName("UserSessionArray")
+	a.Description("User Session Array")
+	a.Attributes(func() {
+		a.Attribute("data", a.ArrayOf(userSessionData))
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+// userSessionData represents an active session of a user
+var userSessionData = a.Type("UserSessionData", func() {
+	a.Attribute("session_id", d.String, "unique session identifier")
+	a.Attribute("client_id", d.String, "the ID of the client the session was started from")
+	a.Attribute("user_agent", d.String, "the user agent of the client the session was started from")
+	a.Attribute("ip_address", d.String, "the IP address of the client the session was started from")
+	a.Attribute("created_at", d.DateTime, "the date of creation of the session")
+	a.Attribute("last_used", d.DateTime, "the last time a token of the session was used")
+	a.Attribute("current", d.Boolean, "whether the request was made with a token of this session")
+	a.Required("session_id", "client_id", "created_at", "last_used", "current")
+})
+
+// userDataExport represents a copy of the personal data of a user
+var userDataExport = a.MediaType("application/vnd.user-data-export+json", func() {
+	a.UseTrait("jsonapi-media-type")
+	a.TypeName("UserDataExport")
+	a.Description("User Data Export")
+	a.Attributes(func() {
+		a.Attribute("data", userDataExportData)
+		a.Required("data")
+	})
+	a.View("default", func() {
+		a.Attribute("data")
+		a.Required("data")
+	})
+})
+
+// userDataExportData represents a copy of the personal data of a user
+var userDataExportData = a.Type("UserDataExportData", func() {
+	a.Attribute("id", d.String, "unique id of the export")
+	a.Attribute("type", d.String, "type of the export")
+	a.Attribute("attributes", userDataExportAttributes, "Attributes of the export")
+	a.Required("id", "type", "attributes")
+})
+
+// userDataExportAttributes represents the attributes of a copy of the personal data of a user
+var userDataExportAttributes = a.Type("UserDataExportAttributes", func() {
+	a.Attribute("status", d.String, "The status of the export: 'pending' or 'ready'")
+	a.Attribute("created-at", d.DateTime, "The date of the request of the export")
+	a.Attribute("completed-at", d.DateTime, "The date at which the export was generated")
+	a.Attribute("expires-at", d.DateTime, "The date after which the export is not available anymore")
+	a.Attribute("content", a.HashOf(d.String, d.Any), "The exported data, once the export is ready")
+	a.Required("status", "created-at")
+})
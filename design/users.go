@@ -16,7 +16,22 @@ var _ = a.Resource("users", func() {
 			a.Param("code", d.String, "code")
 			a.Required("code")
 		})
-		a.Description("Verify if the new email updated by the user is a valid email")
+		a.Description(`Verify if the new email updated by the user is a valid email. If the code confirms a pending change of
+the email address, the email address of the user is changed.`)
+		a.Response(d.TemporaryRedirect)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+	})
+
+	a.Action("cancelEmailChange", func() {
+		a.Routing(
+			a.GET("/cancelemailchange"),
+		)
+		a.Params(func() {
+			a.Param("code", d.String, "the cancellation code sent to the current email address of the user")
+			a.Required("code")
+		})
+		a.Description(`Cancel a pending change of the email address of the user. A change which was already confirmed is reverted
+if the code did not expire yet.`)
 		a.Response(d.TemporaryRedirect)
 		a.Response(d.InternalServerError, JSONAPIErrors)
 	})
@@ -26,10 +41,12 @@ var _ = a.Resource("users", func() {
 		a.Routing(
 			a.POST("/verificationcode"),
 		)
-		a.Description("Send a verification code to the user's email address")
+		a.Description(`Send a verification code to the user's email address, or to the new address if the user has a pending
+change of her email address. The number of codes sent to a user over a period is limited.`)
 		a.Response(d.NoContent)
 		a.Response(d.InternalServerError, JSONAPIErrors)
 		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response("TooManyRequests", JSONAPIErrors)
 	})
 
 	a.Action("show", func() {
@@ -70,7 +87,9 @@ var _ = a.Resource("users", func() {
 		a.Routing(
 			a.PATCH(""),
 		)
-		a.Description("update the authenticated user")
+		a.Description(`update the authenticated user. A change of the email address is pending until it is confirmed with
+the code sent to the new address, and can be cancelled from the current address, or reverted from it for a while
+after it is confirmed.`)
 		a.Payload(updateUser)
 		a.Response(d.OK, func() {
 			a.Media(showUser)
@@ -81,6 +100,7 @@ var _ = a.Resource("users", func() {
 		a.Response(d.Unauthorized, JSONAPIErrors)
 		a.Response(d.Forbidden, JSONAPIErrors)
 		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response("TooManyRequests", JSONAPIErrors)
 	})
 
 	a.Action("list", func() {
@@ -219,6 +239,9 @@ var updateUserDataAttributes = a.Type("UpdateIdentityDataAttributes", func() {
 	a.Attribute("contextInformation", a.HashOf(d.String, d.Any), "User context information of any type as a json", func() {
 		a.Example(map[string]interface{}{"last_visited_url": "https://a.openshift.io", "space": "3d6dab8d-f204-42e8-ab29-cdb1c93130ad"})
 	})
+	a.Attribute("profileAttributes", a.HashOf(d.String, d.Any), "The values of the custom profile attributes to set. A null value unsets the attribute", func() {
+		a.Example(map[string]interface{}{"timezone": "Europe/Paris", "github_handle": nil})
+	})
 	a.Attribute("deprovisioned", d.Boolean, "Whether the identity has been deprovisioned (DEPRECATED: use 'banned' instead)")
 	a.Attribute("banned", d.Boolean, "Whether the identity has been banned")
 })
@@ -244,6 +267,7 @@ var userTokenData = a.Type("UserTokenData", func() {
 	a.Attribute("token_type", d.String, "token type")
 	a.Attribute("expiry_time", d.DateTime, "token expiry time")
 	a.Attribute("permissions", a.ArrayOf(tokenPrivilegeData))
+	a.Attribute("session_id", d.String, "identifier of the session the token was issued from")
 	a.Required("token_id", "status", "token_type", "expiry_time")
 })
 
@@ -0,0 +1,204 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+var _ = a.Resource("webauthn", func() {
+
+	a.BasePath("/webauthn")
+
+	a.Action("beginRegistration", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/registration/options"),
+		)
+		a.Description(`Start the registration of a new WebAuthn credential for the current user, and return the options to pass to navigator.credentials.create().
+Users who already enabled multi-factor authentication need a multi-factor authenticated token`)
+		a.Response(d.OK, webAuthnCreationOptionsMedia)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+	})
+
+	a.Action("finishRegistration", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.POST("/registration"),
+		)
+		a.Description("Complete the registration of a new WebAuthn credential for the current user, with the response of the authenticator")
+		a.Payload(webAuthnRegistrationRequestMedia)
+		a.Response(d.OK, webAuthnCredentialSingle)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+	})
+
+	a.Action("listCredentials", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET("/credentials"),
+		)
+		a.Description("List the WebAuthn credentials of the current user")
+		a.Response(d.OK, webAuthnCredentialList)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+	})
+
+	a.Action("deleteCredential", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/credentials/:credentialID"),
+		)
+		a.Params(func() {
+			a.Param("credentialID", d.String, "ID of the credential to delete")
+		})
+		a.Description("Delete a WebAuthn credential of the current user. Requires a multi-factor authenticated token")
+		a.Response(d.NoContent)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+	})
+
+	a.Action("beginAssertion", func() {
+		a.Routing(
+			a.POST("/assertion/options"),
+		)
+		a.Description("Start the WebAuthn assertion of a login challenge, and return the options to pass to navigator.credentials.get()")
+		a.Payload(webAuthnAssertionOptionsRequestMedia)
+		a.Response(d.OK, webAuthnRequestOptionsMedia)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+	})
+
+	a.Action("finishAssertion", func() {
+		a.Routing(
+			a.POST("/assertion"),
+		)
+		a.Description("Verify the WebAuthn assertion of a login challenge, and return the URL to which the client should redirect to complete the login")
+		a.Payload(webAuthnAssertionRequestMedia)
+		a.Response(d.OK, mfaVerificationResponseMedia)
+		a.Response(d.BadRequest, JSONAPIErrors)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+	})
+})
+
+var webAuthnCreationOptionsMedia = a.MediaType("application/vnd.webauthn-creation-options+json", func() {
+	a.TypeName("WebAuthnCreationOptions")
+	a.Description("Options of a WebAuthn registration ceremony. Binary values are base64url-encoded")
+	a.Attributes(func() {
+		a.Attribute("challenge", d.String, "The challenge to sign")
+		a.Attribute("rp_id", d.String, "The relying party ID")
+		a.Attribute("rp_name", d.String, "The relying party name")
+		a.Attribute("user_id", d.String, "The user handle")
+		a.Attribute("user_name", d.String, "The username")
+		a.Attribute("user_display_name", d.String, "The full name of the user")
+		a.Attribute("algorithms", a.ArrayOf(d.Integer), "The COSE algorithms accepted for the credential public key, by order of preference")
+		a.Attribute("exclude_credentials", a.ArrayOf(d.String), "The IDs of the credentials already registered by the user")
+		a.Attribute("timeout", d.Integer, "The time given to the user to complete the ceremony, in milliseconds")
+		a.Required("challenge", "rp_id", "rp_name", "user_id", "user_name", "user_display_name", "algorithms", "exclude_credentials", "timeout")
+	})
+	a.View("default", func() {
+		a.Attribute("challenge")
+		a.Attribute("rp_id")
+		a.Attribute("rp_name")
+		a.Attribute("user_id")
+		a.Attribute("user_name")
+		a.Attribute("user_display_name")
+		a.Attribute("algorithms")
+		a.Attribute("exclude_credentials")
+		a.Attribute("timeout")
+	})
+})
+
+var webAuthnRegistrationRequestMedia = a.MediaType("application/vnd.webauthn-registration-request+json", func() {
+	a.TypeName("WebAuthnRegistrationRequest")
+	a.Description("Response of the authenticator to a WebAuthn registration ceremony. Binary values are base64url-encoded")
+	a.Attributes(func() {
+		a.Attribute("name", d.String, "The name given by the user to the credential")
+		a.Attribute("client_data_json", d.String, "The client data collected by the browser")
+		a.Attribute("attestation_object", d.String, "The attestation object returned by the authenticator")
+		a.Required("client_data_json", "attestation_object")
+	})
+	a.View("default", func() {
+		a.Attribute("name")
+		a.Attribute("client_data_json")
+		a.Attribute("attestation_object")
+	})
+})
+
+var webAuthnAssertionOptionsRequestMedia = a.MediaType("application/vnd.webauthn-assertion-options-request+json", func() {
+	a.TypeName("WebAuthnAssertionOptionsRequest")
+	a.Description("Request payload required to start the WebAuthn assertion of a login challenge")
+	a.Attributes(func() {
+		a.Attribute("challenge", d.String, "The ID of the login challenge, as passed to the MFA verification page")
+		a.Required("challenge")
+	})
+	a.View("default", func() {
+		a.Attribute("challenge")
+	})
+})
+
+var webAuthnRequestOptionsMedia = a.MediaType("application/vnd.webauthn-request-options+json", func() {
+	a.TypeName("WebAuthnRequestOptions")
+	a.Description("Options of a WebAuthn assertion ceremony. Binary values are base64url-encoded")
+	a.Attributes(func() {
+		a.Attribute("challenge", d.String, "The challenge to sign")
+		a.Attribute("rp_id", d.String, "The relying party ID")
+		a.Attribute("allow_credentials", a.ArrayOf(d.String), "The IDs of the credentials registered by the user")
+		a.Attribute("timeout", d.Integer, "The time given to the user to complete the ceremony, in milliseconds")
+		a.Required("challenge", "rp_id", "allow_credentials", "timeout")
+	})
+	a.View("default", func() {
+		a.Attribute("challenge")
+		a.Attribute("rp_id")
+		a.Attribute("allow_credentials")
+		a.Attribute("timeout")
+	})
+})
+
+var webAuthnAssertionRequestMedia = a.MediaType("application/vnd.webauthn-assertion-request+json", func() {
+	a.TypeName("WebAuthnAssertionRequest")
+	a.Description("Response of the authenticator to the WebAuthn assertion ceremony of a login challenge. Binary values are base64url-encoded")
+	a.Attributes(func() {
+		a.Attribute("challenge", d.String, "The ID of the login challenge, as passed to the MFA verification page")
+		a.Attribute("credential_id", d.String, "The ID of the credential used by the authenticator")
+		a.Attribute("client_data_json", d.String, "The client data collected by the browser")
+		a.Attribute("authenticator_data", d.String, "The authenticator data")
+		a.Attribute("signature", d.String, "The assertion signature")
+		a.Required("challenge", "credential_id", "client_data_json", "authenticator_data", "signature")
+	})
+	a.View("default", func() {
+		a.Attribute("challenge")
+		a.Attribute("credential_id")
+		a.Attribute("client_data_json")
+		a.Attribute("authenticator_data")
+		a.Attribute("signature")
+	})
+})
+
+var webAuthnCredentialSingle = JSONSingle(
+	"WebAuthnCredential", "Holds a single WebAuthn credential",
+	webAuthnCredentialData,
+	nil)
+
+var webAuthnCredentialList = JSONList(
+	"WebAuthnCredential", "Holds the list of WebAuthn credentials of a user",
+	webAuthnCredentialData,
+	nil,
+	nil)
+
+var webAuthnCredentialData = a.Type("WebAuthnCredentialData", func() {
+	a.Attribute("id", d.String, "The ID of the credential")
+	a.Attribute("name", d.String, "The name given by the user to the credential")
+	a.Attribute("aaguid", d.String, "The identifier of the authenticator model")
+	a.Attribute("created_at", d.DateTime, "The time at which the credential was registered")
+	a.Attribute("last_used_at", d.DateTime, "The time at which the credential was last used to login")
+	a.Required("id", "name", "created_at")
+})
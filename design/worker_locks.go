@@ -0,0 +1,64 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+var _ = a.Resource("worker_locks", func() {
+	a.BasePath("/workers/locks")
+
+	a.Action("list", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET(""),
+		)
+		a.Description("List the locks of the background workers which are currently held, along with their owner")
+		a.Response(d.OK, workerLockList)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+
+	a.Action("release", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.DELETE("/:name"),
+		)
+		a.Description(`Force the release of a stale lock, so that another pod can take over the work without waiting
+for the end of the lease. Unless 'force' is set, the lock is released only if its owner did not send any heartbeat
+during the lease.`)
+		a.Params(func() {
+			a.Param("name", d.String, "Name of the lock")
+			a.Param("force", d.Boolean, "Release the lock even if its owner is still alive")
+		})
+		a.Response(d.NoContent)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.NotFound, JSONAPIErrors)
+		a.Response(d.Conflict, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+})
+
+var workerLockList = JSONList(
+	"WorkerLock", "Holds the list of worker locks",
+	workerLockData,
+	nil,
+	nil)
+
+// workerLockData represents the lock of a background worker
+var workerLockData = a.Type("WorkerLockData", func() {
+	a.Attribute("id", d.String, "Name of the lock")
+	a.Attribute("type", d.String, "type of the lock")
+	a.Attribute("attributes", workerLockDataAttributes, "Attributes of the lock")
+	a.Required("id", "type", "attributes")
+})
+
+// workerLockDataAttributes represents the attributes of the lock of a background worker
+var workerLockDataAttributes = a.Type("WorkerLockDataAttributes", func() {
+	a.Attribute("owner", d.String, "The pod which holds the lock")
+	a.Attribute("heartbeat-at", d.DateTime, "The time of the last heartbeat of the owner")
+	a.Attribute("stale", d.Boolean, "Whether the owner did not send any heartbeat during the lease, in which case the lock can be released")
+	a.Required("owner", "stale")
+})
@@ -0,0 +1,48 @@
+package design
+
+import (
+	d "github.com/goadesign/goa/design"
+	a "github.com/goadesign/goa/design/apidsl"
+)
+
+var _ = a.Resource("workers", func() {
+	a.BasePath("/workers")
+
+	a.Action("list", func() {
+		a.Security("jwt")
+		a.Routing(
+			a.GET(""),
+		)
+		a.Description(`List the background workers started by the instance which serves the request, along with the
+owner of their lock in any instance and the outcome of their last cycle`)
+		a.Response(d.OK, workerList)
+		a.Response(d.InternalServerError, JSONAPIErrors)
+		a.Response(d.Unauthorized, JSONAPIErrors)
+		a.Response(d.Forbidden, JSONAPIErrors)
+	})
+})
+
+var workerList = JSONList(
+	"Worker", "Holds the list of background workers",
+	workerData,
+	nil,
+	nil)
+
+// workerData represents a background worker
+var workerData = a.Type("WorkerData", func() {
+	a.Attribute("id", d.String, "Name of the worker, which is also the name of its lock")
+	a.Attribute("type", d.String, "type of the worker")
+	a.Attribute("attributes", workerDataAttributes, "Attributes of the worker")
+	a.Required("id", "type", "attributes")
+})
+
+// workerDataAttributes represents the attributes of a background worker
+var workerDataAttributes = a.Type("WorkerDataAttributes", func() {
+	a.Attribute("lock-held", d.Boolean, "Whether the lock of the worker is held by the instance which serves the request")
+	a.Attribute("lock-owner", d.String, "The instance which currently holds the lock of the worker, if any")
+	a.Attribute("heartbeat-age", d.Number, "The number of seconds since the last heartbeat of the owner of the lock")
+	a.Attribute("last-cycle-started-at", d.DateTime, "The time at which the last cycle of the worker started")
+	a.Attribute("last-cycle-ended-at", d.DateTime, "The time at which the last cycle of the worker ended")
+	a.Attribute("last-cycle-error", d.String, "The error which interrupted the last cycle of the worker, if any")
+	a.Required("lock-held")
+})
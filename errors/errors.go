@@ -15,6 +15,12 @@ const (
 
 	UNAUTHORIZED_CODE_TOKEN_DEPROVISIONED = 1
 	UNAUTHORIZED_CODE_TOKEN_REVOKED       = 2
+	// the user is banned, for a reason of the given category
+	UNAUTHORIZED_CODE_USER_BANNED                 = 3
+	UNAUTHORIZED_CODE_USER_BANNED_ABUSE           = 4
+	UNAUTHORIZED_CODE_USER_BANNED_SPAM            = 5
+	UNAUTHORIZED_CODE_USER_BANNED_TERMS_VIOLATION = 6
+	UNAUTHORIZED_CODE_USER_BANNED_SECURITY        = 7
 )
 
 // Constants that can be used to identify internal server errors
@@ -36,6 +42,8 @@ func FromStatusCode(statusCode int, format string, args ...interface{}) error {
 		return NewUnauthorizedError(msg)
 	case http.StatusForbidden:
 		return NewForbiddenError(msg)
+	case http.StatusTooManyRequests:
+		return NewTooManyRequestsError(msg)
 	default:
 		return NewInternalErrorFromString(msg)
 	}
@@ -103,6 +111,21 @@ func IsForbiddenError(err error) (bool, error) {
 	return true, e
 }
 
+// NewTooManyRequestsError returns the custom defined error of type TooManyRequestsError.
+func NewTooManyRequestsError(msg string) TooManyRequestsError {
+	return TooManyRequestsError{simpleError{msg}}
+}
+
+// IsTooManyRequestsError returns true if the cause of the given error can be
+// converted to an TooManyRequestsError, which is returned as the second result.
+func IsTooManyRequestsError(err error) (bool, error) {
+	e, ok := errs.Cause(err).(TooManyRequestsError)
+	if !ok {
+		return false, nil
+	}
+	return true, e
+}
+
 // InternalError means that the operation failed for some internal, unexpected reason
 type InternalError struct {
 	Err error
@@ -123,6 +146,11 @@ type ForbiddenError struct {
 	simpleError
 }
 
+// TooManyRequestsError means that the operation was requested too many times in a given period
+type TooManyRequestsError struct {
+	simpleError
+}
+
 // VersionConflictError means that the version was not as expected in an update operation
 type VersionConflictError struct {
 	simpleError
@@ -180,6 +208,11 @@ func (err BadParameterError) Error() string {
 	return fmt.Sprintf(stBadParameterErrorMsg, err.parameter, err.value, err.errorMessage)
 }
 
+// Parameter returns the name of the invalid parameter
+func (err BadParameterError) Parameter() string {
+	return err.parameter
+}
+
 // Expected sets the optional expectedValue parameter on the BadParameterError
 func (err BadParameterError) Expected(expected interface{}) BadParameterError {
 	err.expectedValue = expected
@@ -18,6 +18,7 @@ func TestFromStatusCode(t *testing.T) {
 	require.IsType(t, errors.VersionConflictError{}, errors.FromStatusCode(http.StatusConflict, ""))
 	require.IsType(t, errors.UnauthorizedError{}, errors.FromStatusCode(http.StatusUnauthorized, ""))
 	require.IsType(t, errors.ForbiddenError{}, errors.FromStatusCode(http.StatusForbidden, ""))
+	require.IsType(t, errors.TooManyRequestsError{}, errors.FromStatusCode(http.StatusTooManyRequests, ""))
 	require.IsType(t, errors.InternalError{}, errors.FromStatusCode(http.StatusInternalServerError, ""))
 }
 
@@ -10,14 +10,18 @@ import (
 	"github.com/fabric8-services/fabric8-auth/application/service/factory"
 	"github.com/fabric8-services/fabric8-auth/application/transaction"
 	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	logout "github.com/fabric8-services/fabric8-auth/authentication/logout/repository"
+	mfa "github.com/fabric8-services/fabric8-auth/authentication/mfa/repository"
 	provider "github.com/fabric8-services/fabric8-auth/authentication/provider/repository"
 	invitation "github.com/fabric8-services/fabric8-auth/authorization/invitation/repository"
 	permission "github.com/fabric8-services/fabric8-auth/authorization/permission/repository"
 	resource "github.com/fabric8-services/fabric8-auth/authorization/resource/repository"
 	resourcetype "github.com/fabric8-services/fabric8-auth/authorization/resourcetype/repository"
 	role "github.com/fabric8-services/fabric8-auth/authorization/role/repository"
+	"github.com/fabric8-services/fabric8-auth/authorization/token/encryption"
 	token "github.com/fabric8-services/fabric8-auth/authorization/token/repository"
 	"github.com/fabric8-services/fabric8-auth/configuration"
+	"github.com/fabric8-services/fabric8-auth/log"
 	worker "github.com/fabric8-services/fabric8-auth/worker/repository"
 
 	"github.com/jinzhu/gorm"
@@ -55,6 +59,15 @@ const (
 func NewGormDB(db *gorm.DB, config *configuration.ConfigurationData, wrappers factorymanager.FactoryWrappers, options ...factory.Option) *GormDB {
 	g := new(GormDB)
 	g.db = db.Set("gorm:save_associations", false)
+	encryptor, err := encryption.NewEnvelopeEncryptorFromConfig(config)
+	if err != nil {
+		// the misconfiguration is also reported as a default configuration error
+		log.Error(nil, map[string]interface{}{
+			"err": err,
+		}, "failed to create the external token encryptor")
+		encryptor = encryption.NewUnavailableEncryptor(err)
+	}
+	g.tokenEncryptor = encryptor
 	g.txIsoLevel = ""
 	g.serviceFactory = factory.NewServiceFactory(func() servicecontext.ServiceContext {
 		return factory.NewServiceContext(g, g, config, wrappers, options...)
@@ -64,7 +77,8 @@ func NewGormDB(db *gorm.DB, config *configuration.ConfigurationData, wrappers fa
 
 // GormBase is a base struct for gorm implementations of db & transaction
 type GormBase struct {
-	db *gorm.DB
+	db             *gorm.DB
+	tokenEncryptor encryption.Encryptor
 }
 
 // GormTransaction implements the Transaction interface methods for committing or rolling back a transaction
@@ -100,9 +114,14 @@ func (g *GormBase) OauthStates() provider.OauthStateReferenceRepository {
 	return provider.NewOauthStateReferenceRepository(g.db)
 }
 
+// AuthorizationCodes returns an authorization code repository
+func (g *GormBase) AuthorizationCodes() provider.AuthorizationCodeRepository {
+	return provider.NewAuthorizationCodeRepository(g.db, g.tokenEncryptor)
+}
+
 // ExternalTokens returns an ExternalTokens repository
 func (g *GormBase) ExternalTokens() token.ExternalTokenRepository {
-	return token.NewExternalTokenRepository(g.db)
+	return token.NewExternalTokenRepository(g.db, g.tokenEncryptor)
 }
 
 // VerificationCodes returns an VerificationCodes repository
@@ -110,6 +129,16 @@ func (g *GormBase) VerificationCodes() account.VerificationCodeRepository {
 	return account.NewVerificationCodeRepository(g.db)
 }
 
+// PendingEmailChanges returns a pending email change repository
+func (g *GormBase) PendingEmailChanges() account.PendingEmailChangeRepository {
+	return account.NewPendingEmailChangeRepository(g.db)
+}
+
+// UsernameHistory returns a repository of the previous usernames of the identities
+func (g *GormBase) UsernameHistory() account.UsernameHistoryRepository {
+	return account.NewUsernameHistoryRepository(g.db)
+}
+
 func (g *GormBase) InvitationRepository() invitation.InvitationRepository {
 	return invitation.NewInvitationRepository(g.db)
 }
@@ -146,6 +175,10 @@ func (g *GormBase) TokenRepository() token.TokenRepository {
 	return token.NewTokenRepository(g.db)
 }
 
+func (g *GormBase) SessionRepository() token.SessionRepository {
+	return token.NewSessionRepository(g.db)
+}
+
 func (g *GormBase) PrivilegeCacheRepository() permission.PrivilegeCacheRepository {
 	return permission.NewPrivilegeCacheRepository(g.db)
 }
@@ -154,6 +187,66 @@ func (g *GormBase) WorkerLockRepository() worker.LockRepository {
 	return worker.NewLockRepository(g.db.DB())
 }
 
+func (g *GormBase) JobRepository() worker.JobRepository {
+	return worker.NewJobRepository(g.db)
+}
+
+func (g *GormBase) JobRunRepository() worker.JobRunRepository {
+	return worker.NewJobRunRepository(g.db)
+}
+
+func (g *GormBase) QueueTaskRepository() worker.QueueTaskRepository {
+	return worker.NewQueueTaskRepository(g.db)
+}
+
+func (g *GormBase) OutboxEvents() account.OutboxEventRepository {
+	return account.NewOutboxEventRepository(g.db)
+}
+
+func (g *GormBase) AuditEvents() account.AuditEventRepository {
+	return account.NewAuditEventRepository(g.db)
+}
+
+func (g *GormBase) UserDataExports() account.UserDataExportRepository {
+	return account.NewUserDataExportRepository(g.db)
+}
+
+func (g *GormBase) UserBans() account.UserBanRepository {
+	return account.NewUserBanRepository(g.db)
+}
+
+func (g *GormBase) UserBulkOperations() account.UserBulkOperationRepository {
+	return account.NewUserBulkOperationRepository(g.db)
+}
+
+func (g *GormBase) UserProfileAttributes() account.UserProfileAttributeRepository {
+	return account.NewUserProfileAttributeRepository(g.db)
+}
+
+func (g *GormBase) BackChannelLogoutNotifications() logout.BackChannelLogoutNotificationRepository {
+	return logout.NewBackChannelLogoutNotificationRepository(g.db)
+}
+
+func (g *GormBase) TOTPCredentials() mfa.TOTPCredentialRepository {
+	return mfa.NewTOTPCredentialRepository(g.db, g.tokenEncryptor)
+}
+
+func (g *GormBase) RecoveryCodes() mfa.RecoveryCodeRepository {
+	return mfa.NewRecoveryCodeRepository(g.db)
+}
+
+func (g *GormBase) MFAChallenges() mfa.MFAChallengeRepository {
+	return mfa.NewMFAChallengeRepository(g.db)
+}
+
+func (g *GormBase) WebAuthnCredentials() mfa.WebAuthnCredentialRepository {
+	return mfa.NewWebAuthnCredentialRepository(g.db)
+}
+
+func (g *GormBase) WebAuthnRegistrations() mfa.WebAuthnRegistrationRepository {
+	return mfa.NewWebAuthnRegistrationRepository(g.db)
+}
+
 //----------------------------------------------------------------------------------------------------------------------
 //
 // Services
@@ -168,6 +261,10 @@ func (g *GormDB) InvitationService() service.InvitationService {
 	return g.serviceFactory.InvitationService()
 }
 
+func (g *GormDB) GroupService() service.GroupService {
+	return g.serviceFactory.GroupService()
+}
+
 func (g *GormDB) LinkService() service.LinkService {
 	return g.serviceFactory.LinkService()
 }
@@ -176,6 +273,10 @@ func (g *GormDB) LogoutService() service.LogoutService {
 	return g.serviceFactory.LogoutService()
 }
 
+func (g *GormDB) MFAService() service.MFAService {
+	return g.serviceFactory.MFAService()
+}
+
 func (g *GormDB) OSOSubscriptionService() service.OSOSubscriptionService {
 	return g.serviceFactory.OSOSubscriptionService()
 }
@@ -184,6 +285,10 @@ func (g *GormDB) OrganizationService() service.OrganizationService {
 	return g.serviceFactory.OrganizationService()
 }
 
+func (g *GormDB) OutboxService() service.OutboxService {
+	return g.serviceFactory.OutboxService()
+}
+
 func (g *GormDB) PermissionService() service.PermissionService {
 	return g.serviceFactory.PermissionService()
 }
@@ -196,6 +301,10 @@ func (g *GormDB) RoleManagementService() service.RoleManagementService {
 	return g.serviceFactory.RoleManagementService()
 }
 
+func (g *GormDB) SCIMService() service.SCIMService {
+	return g.serviceFactory.SCIMService()
+}
+
 func (g *GormDB) TeamService() service.TeamService {
 	return g.serviceFactory.TeamService()
 }
@@ -216,6 +325,30 @@ func (g *GormDB) UserService() service.UserService {
 	return g.serviceFactory.UserService()
 }
 
+func (g *GormDB) UserDataExportService() service.UserDataExportService {
+	return g.serviceFactory.UserDataExportService()
+}
+
+func (g *GormDB) UserBulkOperationService() service.UserBulkOperationService {
+	return g.serviceFactory.UserBulkOperationService()
+}
+
+func (g *GormDB) UserProfileAttributeService() service.UserProfileAttributeService {
+	return g.serviceFactory.UserProfileAttributeService()
+}
+
+func (g *GormDB) UserMergeService() service.UserMergeService {
+	return g.serviceFactory.UserMergeService()
+}
+
+func (g *GormDB) AvatarService() service.AvatarService {
+	return g.serviceFactory.AvatarService()
+}
+
+func (g *GormDB) WebAuthnService() service.WebAuthnService {
+	return g.serviceFactory.WebAuthnService()
+}
+
 func (g *GormDB) UserProfileService() service.UserProfileService {
 	return g.serviceFactory.UserProfileService()
 }
@@ -283,9 +416,9 @@ func (g *GormDB) BeginTransaction() (transaction.Transaction, error) {
 		if tx.Error != nil {
 			return nil, tx.Error
 		}
-		return &GormTransaction{GormBase{tx}}, nil
+		return &GormTransaction{GormBase{tx, g.tokenEncryptor}}, nil
 	}
-	return &GormTransaction{GormBase{tx}}, nil
+	return &GormTransaction{GormBase{tx, g.tokenEncryptor}}, nil
 }
 
 // Commit commits the current transaction
@@ -1,6 +1,10 @@
 package gormsupport
 
-import "github.com/lib/pq"
+import (
+	"strings"
+
+	"github.com/lib/pq"
+)
 
 const (
 	errCheckViolation      = "23514"
@@ -43,3 +47,11 @@ func IsForeignKeyViolation(err error, indexName string) bool {
 	}
 	return pqError.Code == errForeignKeyViolation && pqError.Constraint == indexName
 }
+
+// likePatternEscaper escapes the wildcards and the escape character of a LIKE pattern
+var likePatternEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// EscapeLikePattern escapes the wildcards of a LIKE pattern, so that they match literally
+func EscapeLikePattern(s string) string {
+	return likePatternEscaper.Replace(s)
+}
@@ -0,0 +1,19 @@
+package gormsupport_test
+
+import (
+	"testing"
+
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/resource"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscapeLikePattern(t *testing.T) {
+	resource.Require(t, resource.UnitTest)
+
+	assert.Equal(t, "jdoe", gormsupport.EscapeLikePattern("jdoe"))
+	assert.Equal(t, `50\% off`, gormsupport.EscapeLikePattern("50% off"))
+	assert.Equal(t, `j\_doe`, gormsupport.EscapeLikePattern("j_doe"))
+	assert.Equal(t, `c:\\temp\\\%`, gormsupport.EscapeLikePattern(`c:\temp\%`))
+}
@@ -24,6 +24,7 @@ const (
 	ErrorCodeInternalError     = "internal_error"
 	ErrorCodeUnauthorizedError = "unauthorized_error"
 	ErrorCodeForbiddenError    = "forbidden_error"
+	ErrorCodeTooManyRequests   = "too_many_requests"
 	ErrorCodeJWTSecurityError  = "jwt_security_error"
 )
 
@@ -37,8 +38,9 @@ func ErrorToJSONAPIError(ctx context.Context, err error) (app.JSONAPIError, int)
 	var title, code string
 	var statusCode int
 	var id *string
+	var meta map[string]interface{}
 	log.Info(ctx, map[string]interface{}{"err": cause, "error_message": cause.Error()}, "an error occurred in our api")
-	switch cause.(type) {
+	switch e := cause.(type) {
 	case errors.NotFoundError:
 		code = ErrorCodeNotFound
 		title = "Not found error"
@@ -67,10 +69,18 @@ func ErrorToJSONAPIError(ctx context.Context, err error) (app.JSONAPIError, int)
 		code = ErrorCodeUnauthorizedError
 		title = "Unauthorized error"
 		statusCode = http.StatusUnauthorized
+		if e.UnauthorizedCode != 0 {
+			// let the clients know why the access is not authorized (eg: the category of the ban of the user)
+			meta = map[string]interface{}{"unauthorized_code": e.UnauthorizedCode}
+		}
 	case errors.ForbiddenError:
 		code = ErrorCodeForbiddenError
 		title = "Forbidden error"
 		statusCode = http.StatusForbidden
+	case errors.TooManyRequestsError:
+		code = ErrorCodeTooManyRequests
+		title = "Too many requests error"
+		statusCode = http.StatusTooManyRequests
 	default:
 		code = ErrorCodeUnknownError
 		title = "Unknown error"
@@ -95,6 +105,7 @@ func ErrorToJSONAPIError(ctx context.Context, err error) (app.JSONAPIError, int)
 		Status: &statusCodeStr,
 		Title:  &title,
 		Detail: detail,
+		Meta:   meta,
 	}
 	return jerr, statusCode
 }
@@ -140,6 +151,11 @@ type Conflict interface {
 	Conflict(*app.JSONAPIErrors) error
 }
 
+// TooManyRequests represent a Context that can return a TooManyRequests HTTP status
+type TooManyRequests interface {
+	TooManyRequests(*app.JSONAPIErrors) error
+}
+
 // JSONErrorResponse auto maps the provided error to the correct response type
 // If all else fails, InternalServerError is returned
 func JSONErrorResponse(ctx InternalServerError, err error) error {
@@ -165,6 +181,10 @@ func JSONErrorResponse(ctx InternalServerError, err error) error {
 		if ctx, ok := ctx.(Conflict); ok {
 			return errs.WithStack(ctx.Conflict(jsonErr))
 		}
+	case http.StatusTooManyRequests:
+		if ctx, ok := ctx.(TooManyRequests); ok {
+			return errs.WithStack(ctx.TooManyRequests(jsonErr))
+		}
 	}
 
 	sentry.Sentry().CaptureError(ctx, err)
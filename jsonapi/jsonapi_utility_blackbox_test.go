@@ -60,6 +60,14 @@ func TestErrorToJSONAPIError(t *testing.T) {
 	require.NotNil(t, jerr.Status)
 	require.Equal(t, jsonapi.ErrorCodeUnauthorizedError, *jerr.Code)
 	require.Equal(t, strconv.Itoa(httpStatus), *jerr.Status)
+	require.Nil(t, jerr.Meta)
+
+	// test unauthorized error with code
+	jerr, httpStatus = jsonapi.ErrorToJSONAPIError(nil, errors.NewUnauthorizedErrorWithCode("foo", errors.UNAUTHORIZED_CODE_USER_BANNED_ABUSE))
+	require.Equal(t, http.StatusUnauthorized, httpStatus)
+	require.NotNil(t, jerr.Code)
+	require.Equal(t, jsonapi.ErrorCodeUnauthorizedError, *jerr.Code)
+	require.Equal(t, errors.UNAUTHORIZED_CODE_USER_BANNED_ABUSE, jerr.Meta["unauthorized_code"])
 
 	// test dataConfict error
 	jerr, httpStatus = jsonapi.ErrorToJSONAPIError(nil, errors.NewDataConflictError("foo"))
@@ -77,6 +85,14 @@ func TestErrorToJSONAPIError(t *testing.T) {
 	require.Equal(t, jsonapi.ErrorCodeForbiddenError, *jerr.Code)
 	require.Equal(t, strconv.Itoa(httpStatus), *jerr.Status)
 
+	// test too many requests error
+	jerr, httpStatus = jsonapi.ErrorToJSONAPIError(nil, errors.NewTooManyRequestsError("foo"))
+	require.Equal(t, http.StatusTooManyRequests, httpStatus)
+	require.NotNil(t, jerr.Code)
+	require.NotNil(t, jerr.Status)
+	require.Equal(t, jsonapi.ErrorCodeTooManyRequests, *jerr.Code)
+	require.Equal(t, strconv.Itoa(httpStatus), *jerr.Status)
+
 	// test unspecified error
 	jerr, httpStatus = jsonapi.ErrorToJSONAPIError(nil, fmt.Errorf("foobar"))
 	require.Equal(t, http.StatusInternalServerError, httpStatus)
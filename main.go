@@ -12,11 +12,13 @@ import (
 	"time"
 
 	"github.com/fabric8-services/fabric8-auth/app"
+	"github.com/fabric8-services/fabric8-auth/application"
 	factorymanager "github.com/fabric8-services/fabric8-auth/application/factory/manager"
 	appservice "github.com/fabric8-services/fabric8-auth/application/service"
 	"github.com/fabric8-services/fabric8-auth/application/transaction"
 	accountservice "github.com/fabric8-services/fabric8-auth/authentication/account/service"
 	userworker "github.com/fabric8-services/fabric8-auth/authentication/account/worker"
+	logoutworker "github.com/fabric8-services/fabric8-auth/authentication/logout/worker"
 	"github.com/fabric8-services/fabric8-auth/authorization/token/manager"
 	tokenworker "github.com/fabric8-services/fabric8-auth/authorization/token/worker"
 	"github.com/fabric8-services/fabric8-auth/configuration"
@@ -224,12 +226,20 @@ func main() {
 	userCtrl := controller.NewUserController(service, appDB, config, tokenManager, tenantService)
 	app.MountUserController(service, userCtrl)
 
+	// Mount "mfa" controller
+	mfaCtrl := controller.NewMfaController(service, appDB, tokenManager)
+	app.MountMfaController(service, mfaCtrl)
+
+	// Mount "webauthn" controller
+	webauthnCtrl := controller.NewWebauthnController(service, appDB, tokenManager)
+	app.MountWebauthnController(service, webauthnCtrl)
+
 	// Mount "search" controller
 	searchCtrl := controller.NewSearchController(service, appDB, config)
 	app.MountSearchController(service, searchCtrl)
 
 	// Mount "users" controller
-	emailVerificationService := accountservice.NewEmailVerificationClient(appDB)
+	emailVerificationService := accountservice.NewEmailVerificationClient(appDB, config)
 	usersCtrl := controller.NewUsersController(service, appDB, config)
 	usersCtrl.EmailVerificationService = emailVerificationService
 	app.MountUsersController(service, usersCtrl)
@@ -238,6 +248,35 @@ func main() {
 	namedusersCtrl := controller.NewNamedusersController(service, appDB, config, tenantService)
 	app.MountNamedusersController(service, namedusersCtrl)
 
+	// Mount "profileattributes" controller
+	profileAttributesCtrl := controller.NewProfileattributesController(service, appDB)
+	app.MountProfileattributesController(service, profileAttributesCtrl)
+
+	// Mount "avatars" controller
+	avatarsCtrl := controller.NewAvatarsController(service, appDB, config)
+	app.MountAvatarsController(service, avatarsCtrl)
+
+	// Mount "deactivations" controller
+	deactivationsCtrl := controller.NewDeactivationsController(service, appDB)
+	app.MountDeactivationsController(service, deactivationsCtrl)
+
+	// Mount "jobs" controller
+	jobsCtrl := controller.NewJobsController(service, appDB)
+	app.MountJobsController(service, jobsCtrl)
+
+	// Mount "scim" controller
+	scimCtrl := controller.NewSCIMController(service, appDB)
+	app.MountSCIMController(service, scimCtrl)
+
+	// Mount "workers" controller, which reports the status of the background workers started below
+	workers := worker.NewRegistry(appDB)
+	workersCtrl := controller.NewWorkersController(service, workers)
+	app.MountWorkersController(service, workersCtrl)
+
+	// Mount "worker_locks" controller
+	workerLocksCtrl := controller.NewWorkerLocksController(service, appDB)
+	app.MountWorkerLocksController(service, workerLocksCtrl)
+
 	//Mount "userinfo" controller
 	userInfoCtrl := controller.NewUserinfoController(service, appDB, tokenManager)
 	app.MountUserinfoController(service, userInfoCtrl)
@@ -266,6 +305,10 @@ func main() {
 	teamCtrl := controller.NewTeamController(service, appDB)
 	app.MountTeamController(service, teamCtrl)
 
+	// Mount "groups" controller
+	groupCtrl := controller.NewGroupController(service, appDB)
+	app.MountGroupController(service, groupCtrl)
+
 	// Mount "invitations" controller
 	invitationCtrl := controller.NewInvitationController(service, appDB, config)
 	app.MountInvitationController(service, invitationCtrl)
@@ -300,36 +343,97 @@ func main() {
 	// register user deactivation prometheus metric
 	metric.RegisterMetrics()
 
-	// Start background workers
-	// token cleanup, running once every hour
-	tokenCleanupWorker := tokenworker.NewTokenCleanupWorker(context.Background(), appDB)
-	tokenCleanupWorker.Start(time.Hour)
-	workers := []Worker{tokenCleanupWorker}
-	// User deactivation and notification workers
+	// Start background workers and jobs
 	ctx := manager.ContextWithTokenManager(context.Background(), tokenManager)
 	ctx = context.WithValue(ctx, worker.LockOwner, config.GetPodName())
+	// token cleanup, running once every hour
+	startJob(ctx, appDB, workers, tokenworker.NewTokenCleanupJob(appDB, "@hourly"), config.GetJobPollInterval())
+	// User deactivation and notification jobs
 	if config.GetUserDeactivationNotificationEnabled() {
 		log.Info(nil, map[string]interface{}{
 			"user_fetch_limit":               config.GetUserDeactivationFetchLimit(),
 			"inactivity_notification_period": config.GetUserDeactivationInactivityNotificationPeriod(),
 			"notification_interval":          config.GetUserDeactivationNotificationWorkerInterval(),
-		}, "Deactivation notification worker enabled")
-		userDeactivationNotificationWorker := userworker.NewUserDeactivationNotificationWorker(ctx, appDB)
-		userDeactivationNotificationWorker.Start(config.GetUserDeactivationNotificationWorkerInterval())
-		workers = append(workers, userDeactivationNotificationWorker)
+			"dry_run":                        config.GetUserDeactivationDryRun(),
+		}, "Deactivation notification job enabled")
+		startJob(ctx, appDB, workers, userworker.NewUserDeactivationNotificationJob(appDB,
+			worker.Every(config.GetUserDeactivationNotificationWorkerInterval()), config.GetUserDeactivationDryRun()), config.GetJobPollInterval())
 	}
 	if config.GetUserDeactivationEnabled() {
 		log.Info(nil, map[string]interface{}{
 			"user_fetch_limit":      config.GetUserDeactivationFetchLimit(),
 			"inactivity_period":     config.GetUserDeactivationInactivityPeriod(),
 			"deactivation_interval": config.GetUserDeactivationWorkerInterval(),
-		}, "Deactivation worker enabled")
-		userDeactivationWorker := userworker.NewUserDeactivationWorker(ctx, appDB)
-		userDeactivationWorker.Start(config.GetUserDeactivationWorkerInterval())
-		workers = append(workers, userDeactivationWorker)
+			"dry_run":               config.GetUserDeactivationDryRun(),
+		}, "Deactivation job enabled")
+		startJob(ctx, appDB, workers, userworker.NewUserDeactivationJob(appDB,
+			worker.Every(config.GetUserDeactivationWorkerInterval()), config.GetUserDeactivationDryRun()), config.GetJobPollInterval())
+	}
+	if config.GetExternalTokenReencryptionEnabled() {
+		log.Info(nil, map[string]interface{}{
+			"primary_key_id":        config.GetExternalTokenEncryptionPrimaryKeyID(),
+			"batch_size":            config.GetExternalTokenReencryptionBatchSize(),
+			"reencryption_interval": config.GetExternalTokenReencryptionWorkerInterval(),
+		}, "External token re-encryption job enabled")
+		startJob(ctx, appDB, workers, tokenworker.NewExternalTokenReencryptionJob(appDB,
+			worker.Every(config.GetExternalTokenReencryptionWorkerInterval())), config.GetJobPollInterval())
+	}
+	if config.GetBackChannelLogoutEnabled() {
+		log.Info(nil, map[string]interface{}{
+			"relying_parties":       len(config.GetBackChannelLogoutURIs()),
+			"max_attempts":          config.GetBackChannelLogoutMaxAttempts(),
+			"notification_interval": config.GetBackChannelLogoutWorkerInterval(),
+		}, "Back-channel logout job enabled")
+		startJob(ctx, appDB, workers, logoutworker.NewBackChannelLogoutJob(appDB,
+			worker.Every(config.GetBackChannelLogoutWorkerInterval())), config.GetJobPollInterval())
+	}
+	if config.GetOutboxEnabled() {
+		log.Info(nil, map[string]interface{}{
+			"poll_interval":            config.GetOutboxWorkerInterval(),
+			"deprovision_min_interval": config.GetOutboxDeprovisionMinInterval(),
+		}, "Outbox consumers enabled")
+		for _, outboxConsumer := range userworker.NewOutboxConsumers(ctx, appDB, config) {
+			outboxConsumer.Start(config.GetOutboxWorkerInterval())
+			workers.Add(outboxConsumer)
+		}
+	}
+	if config.GetUserDataExportEnabled() {
+		log.Info(nil, map[string]interface{}{
+			"sync_token_limit":    config.GetUserDataExportSyncTokenLimit(),
+			"retention":           config.GetUserDataExportRetention(),
+			"generation_interval": config.GetUserDataExportWorkerInterval(),
+		}, "User data export job enabled")
+		startJob(ctx, appDB, workers, userworker.NewUserDataExportJob(appDB,
+			worker.Every(config.GetUserDataExportWorkerInterval())), config.GetJobPollInterval())
+	}
+	if config.GetUserDeletionEnabled() {
+		log.Info(nil, map[string]interface{}{
+			"grace_period":      config.GetUserDeletionGracePeriod(),
+			"retention":         config.GetUserDeletionRetentionPeriod(),
+			"deletion_interval": config.GetUserDeletionWorkerInterval(),
+		}, "User deletion job enabled")
+		startJob(ctx, appDB, workers, userworker.NewUserDeletionJob(appDB,
+			worker.Every(config.GetUserDeletionWorkerInterval())), config.GetJobPollInterval())
+	}
+	if config.GetUserBanEnabled() {
+		log.Info(nil, map[string]interface{}{
+			"fetch_limit": config.GetUserBanFetchLimit(),
+			"schedule":    config.GetUserBanSchedule(),
+		}, "User ban job enabled")
+		startJob(ctx, appDB, workers, userworker.NewUserBanJob(appDB, config.GetUserBanSchedule()), config.GetJobPollInterval())
+	}
+	if config.GetUserBulkOperationEnabled() {
+		log.Info(nil, map[string]interface{}{
+			"concurrency":  config.GetUserBulkOperationConcurrency(),
+			"min_interval": config.GetUserBulkOperationMinInterval(),
+			"max_users":    config.GetUserBulkOperationMaxUsers(),
+		}, "User bulk operation consumer enabled")
+		userBulkOperationConsumer := userworker.NewUserBulkOperationConsumer(ctx, appDB, config.GetUserBulkOperationConcurrency(), config.GetUserBulkOperationMinInterval())
+		userBulkOperationConsumer.Start(config.GetUserBulkOperationPollInterval())
+		workers.Add(userBulkOperationConsumer)
 	}
 	// graceful shutdown
-	go handleShutdown(db, workers...)
+	go handleShutdown(db, workers, config.GetWorkerShutdownTimeout())
 
 	// Start http
 	if err := http.ListenAndServe(config.GetHTTPAddress(), nil); err != nil {
@@ -341,17 +445,31 @@ func main() {
 	}
 }
 
-type Worker interface {
-	Start(freq time.Duration)
-	Stop()
+// startJob starts a worker which runs the given job according to its schedule, and adds it to the registry
+func startJob(ctx context.Context, app application.Application, workers *worker.Registry, job worker.Job, pollInterval time.Duration) {
+	w, err := worker.NewJobWorker(ctx, app, job)
+	if err != nil {
+		log.Panic(nil, map[string]interface{}{
+			"err": err,
+			"job": job.Name,
+		}, "failed to create the job worker")
+	}
+	w.Start(pollInterval)
+	workers.Add(w)
 }
 
-func handleShutdown(db *gorm.DB, workers ...Worker) {
+func handleShutdown(db *gorm.DB, workers *worker.Registry, timeout time.Duration) {
 	c := make(chan os.Signal)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	<-c
 	// handle ctrl+c event here
-	// close database
+	// first, stop the workers and wait until they completed their current cycle and released their lock, so that
+	// another pod can take over
+	log.Warn(nil, map[string]interface{}{
+		"timeout": timeout,
+	}, "Draining the workers before complete shutdown")
+	workers.Drain(timeout)
+	// then, close database
 	log.Warn(nil, nil, "Closing DB connection before complete shutdown")
 	err := db.Close()
 	if err != nil {
@@ -359,10 +477,6 @@ func handleShutdown(db *gorm.DB, workers ...Worker) {
 			"error": err,
 		}, "error while closing the connection to the database")
 	}
-	// also, stop the workers
-	for _, w := range workers {
-		w.Stop()
-	}
 	os.Exit(0)
 }
 
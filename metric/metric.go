@@ -5,6 +5,7 @@ import (
 	metricsupport "github.com/fabric8-services/fabric8-common/metric"
 
 	"strconv"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -16,6 +17,28 @@ const (
 	UserDeactivationTriggerCounterName string = "user_deactivation_trigger_total"
 	// UserDeactivationCounterName the name of the user deactivation counter
 	UserDeactivationCounterName string = "user_deactivation_total"
+	// BackChannelLogoutNotificationCounterName the name of the back-channel logout notification counter
+	BackChannelLogoutNotificationCounterName string = "backchannel_logout_notification_total"
+	// OutboxEventCounterName the name of the outbox event delivery counter
+	OutboxEventCounterName string = "outbox_event_delivery_total"
+	// JobRunCounterName the name of the job run counter
+	JobRunCounterName string = "job_run_total"
+	// JobItemsProcessedCounterName the name of the counter of the items processed by the jobs
+	JobItemsProcessedCounterName string = "job_items_processed_total"
+	// JobLastSuccessGaugeName the name of the gauge of the time of the last successful run of the jobs
+	JobLastSuccessGaugeName string = "job_last_success_timestamp_seconds"
+	// JobLastDurationGaugeName the name of the gauge of the duration of the last run of the jobs
+	JobLastDurationGaugeName string = "job_last_duration_seconds"
+	// QueueTaskCounterName the name of the counter of the tasks processed by the queue consumers
+	QueueTaskCounterName string = "queue_task_total"
+	// WorkerLockHeldGaugeName the name of the gauge of the worker locks held by the current instance
+	WorkerLockHeldGaugeName string = "worker_lock_held"
+	// WorkerLockHeartbeatAgeGaugeName the name of the gauge of the age of the last heartbeat of the worker lock owners
+	WorkerLockHeartbeatAgeGaugeName string = "worker_lock_heartbeat_age_seconds"
+	// WorkerCycleCounterName the name of the counter of the worker cycles
+	WorkerCycleCounterName string = "worker_cycle_total"
+	// WorkerLastCycleGaugeName the name of the gauge of the time of the last cycle of the workers
+	WorkerLastCycleGaugeName string = "worker_last_cycle_timestamp_seconds"
 )
 
 var (
@@ -25,6 +48,28 @@ var (
 	UserDeactivationTriggerCounter *prometheus.CounterVec
 	// UserDeactivationCounter counts the user deactivations
 	UserDeactivationCounter *prometheus.CounterVec
+	// BackChannelLogoutNotificationCounter counts the logout notifications sent to the relying parties
+	BackChannelLogoutNotificationCounter *prometheus.CounterVec
+	// OutboxEventCounter counts the attempts to deliver the outbox events
+	OutboxEventCounter *prometheus.CounterVec
+	// JobRunCounter counts the runs of the jobs
+	JobRunCounter *prometheus.CounterVec
+	// JobItemsProcessedCounter counts the items processed by the jobs
+	JobItemsProcessedCounter *prometheus.CounterVec
+	// JobLastSuccessGauge the time of the last successful run of the jobs
+	JobLastSuccessGauge *prometheus.GaugeVec
+	// JobLastDurationGauge the duration of the last run of the jobs
+	JobLastDurationGauge *prometheus.GaugeVec
+	// QueueTaskCounter counts the attempts to process the queue tasks, by outcome
+	QueueTaskCounter *prometheus.CounterVec
+	// WorkerLockHeldGauge 1 if the lock of a worker is held by the current instance, 0 otherwise
+	WorkerLockHeldGauge *prometheus.GaugeVec
+	// WorkerLockHeartbeatAgeGauge the age of the last heartbeat of the owner of the lock of a worker
+	WorkerLockHeartbeatAgeGauge *prometheus.GaugeVec
+	// WorkerCycleCounter counts the cycles of the workers
+	WorkerCycleCounter *prometheus.CounterVec
+	// WorkerLastCycleGauge the time of the last cycle of the workers
+	WorkerLastCycleGauge *prometheus.GaugeVec
 )
 
 // RegisterMetrics registers the service-specific metrics
@@ -41,6 +86,50 @@ func RegisterMetrics() {
 		Name: UserDeactivationCounterName,
 		Help: "Total number of deactivated users",
 	}, []string{"successful"}), UserDeactivationCounterName).(*prometheus.CounterVec)
+	BackChannelLogoutNotificationCounter = metricsupport.Register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: BackChannelLogoutNotificationCounterName,
+		Help: "Total number of logout notifications sent to the relying parties",
+	}, []string{"successful"}), BackChannelLogoutNotificationCounterName).(*prometheus.CounterVec)
+	OutboxEventCounter = metricsupport.Register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: OutboxEventCounterName,
+		Help: "Total number of attempts to deliver the outbox events",
+	}, []string{"event_type", "successful"}), OutboxEventCounterName).(*prometheus.CounterVec)
+	JobRunCounter = metricsupport.Register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: JobRunCounterName,
+		Help: "Total number of runs of the jobs",
+	}, []string{"job", "successful"}), JobRunCounterName).(*prometheus.CounterVec)
+	JobItemsProcessedCounter = metricsupport.Register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: JobItemsProcessedCounterName,
+		Help: "Total number of items processed by the jobs",
+	}, []string{"job"}), JobItemsProcessedCounterName).(*prometheus.CounterVec)
+	JobLastSuccessGauge = metricsupport.Register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: JobLastSuccessGaugeName,
+		Help: "Time of the last successful run of the jobs",
+	}, []string{"job"}), JobLastSuccessGaugeName).(*prometheus.GaugeVec)
+	JobLastDurationGauge = metricsupport.Register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: JobLastDurationGaugeName,
+		Help: "Duration of the last run of the jobs",
+	}, []string{"job"}), JobLastDurationGaugeName).(*prometheus.GaugeVec)
+	QueueTaskCounter = metricsupport.Register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: QueueTaskCounterName,
+		Help: "Total number of attempts to process the queue tasks",
+	}, []string{"queue", "outcome"}), QueueTaskCounterName).(*prometheus.CounterVec)
+	WorkerLockHeldGauge = metricsupport.Register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: WorkerLockHeldGaugeName,
+		Help: "Whether the lock of the workers is held by the current instance",
+	}, []string{"worker"}), WorkerLockHeldGaugeName).(*prometheus.GaugeVec)
+	WorkerLockHeartbeatAgeGauge = metricsupport.Register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: WorkerLockHeartbeatAgeGaugeName,
+		Help: "Age of the last heartbeat of the owner of the lock of the workers",
+	}, []string{"worker"}), WorkerLockHeartbeatAgeGaugeName).(*prometheus.GaugeVec)
+	WorkerCycleCounter = metricsupport.Register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: WorkerCycleCounterName,
+		Help: "Total number of cycles of the workers",
+	}, []string{"worker", "successful"}), WorkerCycleCounterName).(*prometheus.CounterVec)
+	WorkerLastCycleGauge = metricsupport.Register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: WorkerLastCycleGaugeName,
+		Help: "Time of the last cycle of the workers",
+	}, []string{"worker"}), WorkerLastCycleGaugeName).(*prometheus.GaugeVec)
 	log.Info(nil, nil, "user deactivation/notification metrics registered successfully")
 }
 
@@ -48,6 +137,17 @@ func RegisterMetrics() {
 func UnregisterMetrics() {
 	prometheus.Unregister(*UserDeactivationNotificationCounter)
 	prometheus.Unregister(*UserDeactivationCounter)
+	prometheus.Unregister(*BackChannelLogoutNotificationCounter)
+	prometheus.Unregister(*OutboxEventCounter)
+	prometheus.Unregister(*JobRunCounter)
+	prometheus.Unregister(*JobItemsProcessedCounter)
+	prometheus.Unregister(*JobLastSuccessGauge)
+	prometheus.Unregister(*JobLastDurationGauge)
+	prometheus.Unregister(*QueueTaskCounter)
+	prometheus.Unregister(*WorkerLockHeldGauge)
+	prometheus.Unregister(*WorkerLockHeartbeatAgeGauge)
+	prometheus.Unregister(*WorkerCycleCounter)
+	prometheus.Unregister(*WorkerLastCycleGauge)
 	log.Info(nil, nil, "user deactivation/notification metrics unregistered successfully")
 }
 
@@ -119,3 +219,149 @@ func RecordUserDeactivation(successful bool) {
 		counter.Inc()
 	}
 }
+
+// RecordBackChannelLogoutNotification records a new logout notification sent to a relying party in the prometheus metric
+func RecordBackChannelLogoutNotification(successful bool) {
+	if BackChannelLogoutNotificationCounter == nil {
+		log.Warn(nil, map[string]interface{}{
+			"metric_name": BackChannelLogoutNotificationCounterName,
+		}, "metric not initialized")
+		return
+	}
+	if counter, err := BackChannelLogoutNotificationCounter.GetMetricWithLabelValues(strconv.FormatBool(successful)); err != nil {
+		log.Error(nil, map[string]interface{}{
+			"metric_name": BackChannelLogoutNotificationCounterName,
+			"successful":  successful,
+			"err":         err,
+		}, "Failed to get metric")
+	} else {
+		log.Info(nil, map[string]interface{}{
+			"metric_name": BackChannelLogoutNotificationCounterName,
+			"successful":  successful,
+		}, "incremented metric")
+		counter.Inc()
+	}
+}
+
+// RecordOutboxEventDelivery records a new attempt to deliver an outbox event in the prometheus metric
+func RecordOutboxEventDelivery(eventType string, successful bool) {
+	if OutboxEventCounter == nil {
+		log.Warn(nil, map[string]interface{}{
+			"metric_name": OutboxEventCounterName,
+		}, "metric not initialized")
+		return
+	}
+	if counter, err := OutboxEventCounter.GetMetricWithLabelValues(eventType, strconv.FormatBool(successful)); err != nil {
+		log.Error(nil, map[string]interface{}{
+			"metric_name": OutboxEventCounterName,
+			"event_type":  eventType,
+			"successful":  successful,
+			"err":         err,
+		}, "Failed to get metric")
+	} else {
+		log.Info(nil, map[string]interface{}{
+			"metric_name": OutboxEventCounterName,
+			"event_type":  eventType,
+			"successful":  successful,
+		}, "incremented metric")
+		counter.Inc()
+	}
+}
+
+// RecordJobRun records a run of a job in the prometheus metrics
+func RecordJobRun(job string, successful bool, duration time.Duration, itemsProcessed int, end time.Time) {
+	if JobRunCounter == nil || JobItemsProcessedCounter == nil || JobLastSuccessGauge == nil || JobLastDurationGauge == nil {
+		log.Warn(nil, map[string]interface{}{
+			"metric_name": JobRunCounterName,
+		}, "metric not initialized")
+		return
+	}
+	counter, err := JobRunCounter.GetMetricWithLabelValues(job, strconv.FormatBool(successful))
+	if err != nil {
+		log.Error(nil, map[string]interface{}{
+			"metric_name": JobRunCounterName,
+			"job":         job,
+			"successful":  successful,
+			"err":         err,
+		}, "Failed to get metric")
+		return
+	}
+	counter.Inc()
+	JobItemsProcessedCounter.WithLabelValues(job).Add(float64(itemsProcessed))
+	JobLastDurationGauge.WithLabelValues(job).Set(duration.Seconds())
+	if successful {
+		JobLastSuccessGauge.WithLabelValues(job).Set(float64(end.Unix()))
+	}
+	log.Info(nil, map[string]interface{}{
+		"metric_name": JobRunCounterName,
+		"job":         job,
+		"successful":  successful,
+	}, "recorded job run metrics")
+}
+
+// RecordQueueTask records a new attempt to process a queue task in the prometheus metric. The outcome is
+// `completed`, `retried` or `dead_lettered`
+func RecordQueueTask(queue string, outcome string) {
+	if QueueTaskCounter == nil {
+		log.Warn(nil, map[string]interface{}{
+			"metric_name": QueueTaskCounterName,
+		}, "metric not initialized")
+		return
+	}
+	if counter, err := QueueTaskCounter.GetMetricWithLabelValues(queue, outcome); err != nil {
+		log.Error(nil, map[string]interface{}{
+			"metric_name": QueueTaskCounterName,
+			"queue":       queue,
+			"outcome":     outcome,
+			"err":         err,
+		}, "Failed to get metric")
+	} else {
+		log.Debug(nil, map[string]interface{}{
+			"metric_name": QueueTaskCounterName,
+			"queue":       queue,
+			"outcome":     outcome,
+		}, "incremented metric")
+		counter.Inc()
+	}
+}
+
+// RecordWorkerLock records whether the lock of the given worker is held by the current instance, and the age of the
+// last heartbeat of its owner (if known) in the prometheus metrics
+func RecordWorkerLock(worker string, held bool, heartbeatAge *time.Duration) {
+	if WorkerLockHeldGauge == nil || WorkerLockHeartbeatAgeGauge == nil {
+		log.Warn(nil, map[string]interface{}{
+			"metric_name": WorkerLockHeldGaugeName,
+		}, "metric not initialized")
+		return
+	}
+	if held {
+		WorkerLockHeldGauge.WithLabelValues(worker).Set(1)
+	} else {
+		WorkerLockHeldGauge.WithLabelValues(worker).Set(0)
+	}
+	if heartbeatAge != nil {
+		WorkerLockHeartbeatAgeGauge.WithLabelValues(worker).Set(heartbeatAge.Seconds())
+	}
+}
+
+// RecordWorkerCycle records a cycle of the given worker in the prometheus metrics
+func RecordWorkerCycle(worker string, successful bool, end time.Time) {
+	if WorkerCycleCounter == nil || WorkerLastCycleGauge == nil {
+		log.Warn(nil, map[string]interface{}{
+			"metric_name": WorkerCycleCounterName,
+		}, "metric not initialized")
+		return
+	}
+	counter, err := WorkerCycleCounter.GetMetricWithLabelValues(worker, strconv.FormatBool(successful))
+	if err != nil {
+		log.Error(nil, map[string]interface{}{
+			"metric_name": WorkerCycleCounterName,
+			"worker":      worker,
+			"successful":  successful,
+			"err":         err,
+		}, "Failed to get metric")
+		return
+	}
+	counter.Inc()
+	WorkerLastCycleGauge.WithLabelValues(worker).Set(float64(end.Unix()))
+}
@@ -9,6 +9,7 @@ import (
 
 	factorymanager "github.com/fabric8-services/fabric8-auth/application/factory/manager"
 	"github.com/fabric8-services/fabric8-auth/application/service/factory"
+	authaccount "github.com/fabric8-services/fabric8-auth/authentication/account"
 	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
 	userservice "github.com/fabric8-services/fabric8-auth/authentication/account/service"
 	"github.com/fabric8-services/fabric8-auth/gormtestsupport"
@@ -44,6 +45,9 @@ func (s *MetricTestSuite) TestUserDeactivationNotificationCounter() {
 
 	ctx := context.Background()
 	config := userservicemock.NewUserServiceConfigurationMock(s.T())
+	config.GetUserDeactivationPoliciesFunc = func() (empty []authaccount.DeactivationPolicy) {
+		return empty
+	}
 	config.GetUserDeactivationInactivityPeriodFunc = func() time.Duration {
 		return 97 * 24 * time.Hour
 	}
@@ -268,6 +268,96 @@ func GetMigrations(configuration MigrationConfiguration) Migrations {
 	// Version 53
 	m = append(m, steps{ExecuteSQLFile("053-deactivation-indexes.sql")})
 
+	// Version 54
+	m = append(m, steps{ExecuteSQLFile("054-external-token-encryption.sql")})
+
+	// Version 55
+	m = append(m, steps{ExecuteSQLFile("055-backchannel-logout-notifications.sql")})
+
+	// Version 56
+	m = append(m, steps{ExecuteSQLFile("056-user-sessions.sql")})
+
+	// Version 57
+	m = append(m, steps{ExecuteSQLFile("057-multi-factor-authentication.sql")})
+
+	// Version 58
+	m = append(m, steps{ExecuteSQLFile("058-webauthn-credentials.sql")})
+
+	// Version 59
+	m = append(m, steps{ExecuteSQLFile("059-outbox-events.sql")})
+
+	// Version 60
+	m = append(m, steps{ExecuteSQLFile("060-user-data-export.sql")})
+
+	// Version 61
+	m = append(m, steps{ExecuteSQLFile("061-user-deletion-request.sql")})
+
+	// Version 62
+	m = append(m, steps{ExecuteSQLFile("062-user-deactivation-reminders.sql")})
+
+	// Version 63
+	m = append(m, steps{ExecuteSQLFile("063-user-ban.sql")})
+
+	// Version 64
+	m = append(m, steps{ExecuteSQLFile("064-job.sql")})
+
+	// Version 65
+	m = append(m, steps{ExecuteSQLFile("065-queue-task.sql")})
+
+	// Version 66
+	m = append(m, steps{ExecuteSQLFile("066-worker-lock-heartbeat.sql")})
+
+	// Version 67
+	m = append(m, steps{ExecuteSQLFile("067-user-bulk-operation.sql")})
+
+	// Version 68
+	m = append(m, steps{ExecuteSQLFile("068-user-search-indexes.sql")})
+
+	// Version 69
+	m = append(m, steps{ExecuteSQLFile("069-organization-view-scope-contributor-role.sql")})
+
+	// Version 70
+	m = append(m, steps{ExecuteSQLFile("070-membership-closure.sql")})
+
+	// Version 71
+	m = append(m, steps{ExecuteSQLFile("071-user-profile-attributes.sql")})
+
+	// Version 72
+	m = append(m, steps{ExecuteSQLFile("072-pending-email-change.sql")})
+
+	// Version 73
+	m = append(m, steps{ExecuteSQLFile("073-user-avatar.sql")})
+
+	// Version 74
+	m = append(m, steps{ExecuteSQLFile("074-identity-username-history.sql")})
+
+	// Version 75
+	m = append(m, steps{ExecuteSQLFile("075-mfa-lockout.sql")})
+
+	// Version 76
+	m = append(m, steps{ExecuteSQLFile("076-invitation-role-cascade.sql")})
+
+	// Version 77
+	m = append(m, steps{ExecuteSQLFile("077-audit-event.sql")})
+
+	// Version 78
+	m = append(m, steps{ExecuteSQLFile("078-identity-inactive-keyset-index.sql")})
+
+	// Version 79
+	m = append(m, steps{ExecuteSQLFile("079-pending-email-change-revert.sql")})
+
+	// Version 80
+	m = append(m, steps{ExecuteSQLFile("080-authorization-code.sql")})
+
+	// Version 81
+	m = append(m, steps{ExecuteSQLFile("081-outbox-event-dead-letter-deduplication.sql")})
+
+	// Version 82
+	m = append(m, steps{ExecuteSQLFile("082-outbox-queue.sql")})
+
+	// Version 83
+	m = append(m, steps{ExecuteSQLFile("083-backchannel-logout-session.sql")})
+
 	// Version N
 	//
 	// In order to add an upgrade, simply append an array of MigrationFunc to the
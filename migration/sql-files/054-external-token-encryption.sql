@@ -0,0 +1,6 @@
+-- envelope encryption of the external tokens: the token is encrypted with a data key,
+-- which is itself encrypted with the master key identified by `master_key_id`.
+-- Existing tokens (with no master key) are still stored in plain text until they are re-encrypted.
+ALTER TABLE external_tokens ADD COLUMN encrypted_data_key TEXT;
+ALTER TABLE external_tokens ADD COLUMN master_key_id TEXT;
+CREATE INDEX external_tokens_master_key_id_idx ON external_tokens USING btree (master_key_id);
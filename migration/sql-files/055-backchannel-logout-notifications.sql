@@ -0,0 +1,15 @@
+-- notifications to send to the relying parties which registered a back-channel logout URI, when a user logs out
+CREATE TABLE backchannel_logout_notification (
+  notification_id uuid NOT NULL PRIMARY KEY,
+  identity_id uuid NOT NULL,
+  client_id text NOT NULL,
+  logout_uri text NOT NULL,
+  attempts integer NOT NULL DEFAULT 0,
+  next_attempt timestamp with time zone NOT NULL,
+  last_error text,
+  created_at timestamp with time zone NOT NULL,
+  updated_at timestamp with time zone,
+  deleted_at timestamp with time zone
+);
+
+CREATE INDEX backchannel_logout_notification_next_attempt_idx ON backchannel_logout_notification USING btree (next_attempt) WHERE deleted_at IS NULL;
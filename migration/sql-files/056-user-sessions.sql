@@ -0,0 +1,17 @@
+-- sessions group the tokens issued from a single login
+CREATE TABLE user_session (
+  session_id uuid NOT NULL PRIMARY KEY,
+  identity_id uuid NOT NULL REFERENCES identities (id) ON DELETE CASCADE,
+  client_id text,
+  user_agent text,
+  ip_address text,
+  last_used timestamp with time zone NOT NULL,
+  created_at timestamp with time zone NOT NULL,
+  updated_at timestamp with time zone,
+  deleted_at timestamp with time zone
+);
+
+CREATE INDEX user_session_identity_id_idx ON user_session USING btree (identity_id);
+
+ALTER TABLE token ADD COLUMN session_id uuid REFERENCES user_session (session_id) ON DELETE SET NULL;
+CREATE INDEX token_session_id_idx ON token USING btree (session_id);
@@ -0,0 +1,37 @@
+-- TOTP credentials of the users who enrolled for multi-factor authentication. The secret is encrypted with a data key,
+-- itself encrypted with the master key identified by master_key_id
+CREATE TABLE totp_credential (
+  identity_id uuid NOT NULL PRIMARY KEY REFERENCES identities (id) ON DELETE CASCADE,
+  secret text NOT NULL,
+  encrypted_data_key text NOT NULL,
+  master_key_id text NOT NULL,
+  confirmed boolean NOT NULL DEFAULT false,
+  last_used_step bigint NOT NULL DEFAULT 0,
+  created_at timestamp with time zone NOT NULL,
+  updated_at timestamp with time zone
+);
+
+-- single-use recovery codes, only their bcrypt hash is stored
+CREATE TABLE mfa_recovery_code (
+  recovery_code_id uuid NOT NULL PRIMARY KEY,
+  identity_id uuid NOT NULL REFERENCES identities (id) ON DELETE CASCADE,
+  code_hash text NOT NULL,
+  created_at timestamp with time zone NOT NULL,
+  updated_at timestamp with time zone
+);
+
+CREATE INDEX mfa_recovery_code_identity_id_idx ON mfa_recovery_code USING btree (identity_id);
+
+-- logins waiting for the second factor to be verified
+CREATE TABLE mfa_challenge (
+  challenge_id uuid NOT NULL PRIMARY KEY,
+  identity_id uuid NOT NULL REFERENCES identities (id) ON DELETE CASCADE,
+  referrer text NOT NULL,
+  api_client text,
+  attempts integer NOT NULL DEFAULT 0,
+  expires_at timestamp with time zone NOT NULL,
+  created_at timestamp with time zone NOT NULL,
+  updated_at timestamp with time zone
+);
+
+CREATE INDEX mfa_challenge_expires_at_idx ON mfa_challenge USING btree (expires_at);
@@ -0,0 +1,29 @@
+-- WebAuthn (FIDO2) credentials registered by the users as a second authentication factor. The credential ID is chosen
+-- by the authenticator and stored in its base64url encoding, the public key in its COSE_Key encoding
+CREATE TABLE webauthn_credential (
+  webauthn_credential_id uuid NOT NULL PRIMARY KEY,
+  user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  credential_id text NOT NULL,
+  name text,
+  public_key bytea NOT NULL,
+  sign_count bigint NOT NULL DEFAULT 0,
+  aaguid text,
+  last_used_at timestamp with time zone,
+  created_at timestamp with time zone NOT NULL,
+  updated_at timestamp with time zone
+);
+
+CREATE UNIQUE INDEX webauthn_credential_credential_id_idx ON webauthn_credential USING btree (credential_id);
+CREATE INDEX webauthn_credential_user_id_idx ON webauthn_credential USING btree (user_id);
+
+-- pending registration ceremonies, at most one per user
+CREATE TABLE webauthn_registration (
+  user_id uuid NOT NULL PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+  challenge text NOT NULL,
+  expires_at timestamp with time zone NOT NULL,
+  created_at timestamp with time zone NOT NULL,
+  updated_at timestamp with time zone
+);
+
+-- challenge of the WebAuthn assertion ceremony started for a login waiting for the second factor, if any
+ALTER TABLE mfa_challenge ADD COLUMN webauthn_challenge text;
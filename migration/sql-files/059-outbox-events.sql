@@ -0,0 +1,20 @@
+-- side effects on the other services (Che, Tenant, admin console, notification) which must happen after a change
+-- in the auth DB. The events are written in the same transaction as the change, and delivered by the outbox worker.
+-- There is no foreign key on the identity, so that the events survive a hard-delete of the user.
+CREATE TABLE outbox_event (
+  outbox_event_id uuid NOT NULL PRIMARY KEY,
+  identity_id uuid NOT NULL,
+  event_type text NOT NULL,
+  deduplication_key text NOT NULL,
+  payload jsonb,
+  attempts integer NOT NULL DEFAULT 0,
+  next_attempt timestamp with time zone NOT NULL,
+  last_error text,
+  dead_lettered_at timestamp with time zone,
+  created_at timestamp with time zone NOT NULL,
+  updated_at timestamp with time zone
+);
+
+CREATE UNIQUE INDEX outbox_event_deduplication_key_idx ON outbox_event USING btree (deduplication_key);
+CREATE INDEX outbox_event_next_attempt_idx ON outbox_event USING btree (next_attempt) WHERE dead_lettered_at IS NULL;
+CREATE INDEX outbox_event_identity_id_idx ON outbox_event USING btree (identity_id);
@@ -0,0 +1,14 @@
+-- copies of the personal data of the users, generated on demand and kept until they expire
+CREATE TABLE user_data_export (
+  user_data_export_id uuid NOT NULL PRIMARY KEY,
+  identity_id uuid NOT NULL REFERENCES identities(id) ON DELETE CASCADE,
+  status text NOT NULL,
+  content jsonb,
+  completed_at timestamp with time zone,
+  expires_at timestamp with time zone,
+  created_at timestamp with time zone NOT NULL,
+  updated_at timestamp with time zone
+);
+
+CREATE INDEX user_data_export_identity_id_idx ON user_data_export USING btree (identity_id);
+CREATE INDEX user_data_export_status_idx ON user_data_export USING btree (status);
@@ -0,0 +1,31 @@
+-- the time at which the user requested the deletion of her account. The deletion happens at the time of the
+-- `deactivation_scheduled` column, unless the user logs in again in the meantime.
+ALTER TABLE identities ADD COLUMN deletion_requested timestamp with time zone;
+
+CREATE INDEX identities_deletion_requested_idx ON identities USING btree (deletion_requested) WHERE deletion_requested IS NOT NULL;
+
+-- drop the constraints on the tables referencing the identities and the users, and recreate them so that the
+-- deleted accounts can be purged along with the records that belong to them
+ALTER table external_tokens drop constraint external_provider_tokens_identity_id_fkey;
+ALTER table external_tokens add constraint external_provider_tokens_identity_id_fkey FOREIGN KEY (identity_id) REFERENCES identities(id) ON DELETE CASCADE;
+
+ALTER table identity_role drop constraint identity_role_identity_id_fkey;
+ALTER table identity_role add constraint identity_role_identity_id_fkey FOREIGN KEY (identity_id) REFERENCES identities(id) ON DELETE CASCADE;
+
+ALTER table membership drop constraint membership_member_id_fkey;
+ALTER table membership add constraint membership_member_id_fkey FOREIGN KEY (member_id) REFERENCES identities(id) ON DELETE CASCADE;
+
+ALTER table invitation drop constraint invitation_identity_id_fkey;
+ALTER table invitation add constraint invitation_identity_id_fkey FOREIGN KEY (identity_id) REFERENCES identities(id) ON DELETE CASCADE;
+
+ALTER table token drop constraint token_identity_id_fkey;
+ALTER table token add constraint token_identity_id_fkey FOREIGN KEY (identity_id) REFERENCES identities(id) ON DELETE CASCADE;
+
+ALTER table privilege_cache drop constraint privilege_cache_identity_id_fkey;
+ALTER table privilege_cache add constraint privilege_cache_identity_id_fkey FOREIGN KEY (identity_id) REFERENCES identities(id) ON DELETE CASCADE;
+
+ALTER table resource drop constraint resource_creator_id_fkey;
+ALTER table resource add constraint resource_creator_id_fkey FOREIGN KEY (creator_id) REFERENCES identities(id) ON DELETE SET NULL;
+
+ALTER table verification_codes drop constraint verification_codes_user_id_fkey;
+ALTER table verification_codes add constraint verification_codes_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
@@ -0,0 +1,5 @@
+-- the number of reminders sent to the user since her last activity, before the deactivation of her account
+ALTER TABLE identities ADD COLUMN deactivation_reminders integer NOT NULL DEFAULT 0;
+
+-- the users who were already notified received their single reminder
+UPDATE identities SET deactivation_reminders = 1 WHERE deactivation_notification IS NOT NULL;
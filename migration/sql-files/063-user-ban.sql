@@ -0,0 +1,21 @@
+-- the bans of the users, with their reason and their period. A ban without an end time is permanent until it is lifted.
+CREATE TABLE user_ban (
+  user_ban_id uuid NOT NULL PRIMARY KEY,
+  user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  reason_category text NOT NULL,
+  reason text,
+  actor text NOT NULL,
+  starts_at timestamp with time zone NOT NULL,
+  ends_at timestamp with time zone,
+  lifted_at timestamp with time zone,
+  lifted_by text,
+  created_at timestamp with time zone NOT NULL,
+  updated_at timestamp with time zone
+);
+
+CREATE INDEX user_ban_user_id_idx ON user_ban USING btree (user_id);
+CREATE INDEX user_ban_pending_idx ON user_ban USING btree (starts_at, ends_at) WHERE lifted_at IS NULL;
+
+-- keep a record of the users who were banned before the bans were recorded
+INSERT INTO user_ban (user_ban_id, user_id, reason_category, actor, starts_at, created_at)
+  SELECT uuid_generate_v4(), id, 'other', 'unknown', coalesce(updated_at, created_at), now() FROM users WHERE banned = true AND deleted_at IS NULL;
@@ -0,0 +1,27 @@
+-- the state of the background jobs, shared by all the pods
+CREATE TABLE job (
+  name text NOT NULL PRIMARY KEY,
+  schedule text NOT NULL,
+  paused boolean NOT NULL DEFAULT false,
+  trigger_requested_at timestamp with time zone,
+  trigger_requested_by text,
+  created_at timestamp with time zone NOT NULL,
+  updated_at timestamp with time zone
+);
+
+-- the records of the runs of the background jobs
+CREATE TABLE job_run (
+  job_run_id uuid NOT NULL PRIMARY KEY,
+  job_name text NOT NULL REFERENCES job(name) ON DELETE CASCADE,
+  owner text NOT NULL,
+  trigger text NOT NULL,
+  started_at timestamp with time zone NOT NULL,
+  ended_at timestamp with time zone,
+  outcome text NOT NULL,
+  items_processed integer NOT NULL DEFAULT 0,
+  error text,
+  created_at timestamp with time zone NOT NULL,
+  updated_at timestamp with time zone
+);
+
+CREATE INDEX job_run_job_name_started_at_idx ON job_run USING btree (job_name, started_at);
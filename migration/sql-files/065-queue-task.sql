@@ -0,0 +1,22 @@
+-- the tasks of the work queues, consumed concurrently by all the pods
+CREATE TABLE queue_task (
+  queue_task_id uuid NOT NULL PRIMARY KEY,
+  queue text NOT NULL,
+  priority integer NOT NULL DEFAULT 0,
+  payload jsonb,
+  deduplication_key text,
+  attempts integer NOT NULL DEFAULT 0,
+  max_attempts integer NOT NULL,
+  visible_at timestamp with time zone NOT NULL,
+  locked_by text,
+  locked_at timestamp with time zone,
+  last_error text,
+  dead_lettered_at timestamp with time zone,
+  created_at timestamp with time zone NOT NULL,
+  updated_at timestamp with time zone
+);
+
+-- the pending tasks, in the order in which they are consumed
+CREATE INDEX queue_task_pending_idx ON queue_task USING btree (queue, priority DESC, visible_at) WHERE dead_lettered_at IS NULL;
+-- a task is not enqueued if another one with the same key is pending in the same queue
+CREATE UNIQUE INDEX queue_task_deduplication_key_idx ON queue_task (queue, deduplication_key) WHERE deduplication_key IS NOT NULL AND dead_lettered_at IS NULL;
@@ -0,0 +1,16 @@
+-- the time of the last heartbeat of the owner of a worker lock, to report the health of the workers and detect the
+-- stale locks. The locks are managed by the `pglock` library, which only bumps the record version number at each
+-- heartbeat, hence the trigger.
+ALTER TABLE worker_lock ADD COLUMN heartbeat_at timestamp with time zone;
+
+CREATE OR REPLACE FUNCTION worker_lock_heartbeat() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP = 'INSERT' OR NEW.record_version_number IS DISTINCT FROM OLD.record_version_number THEN
+    NEW.heartbeat_at = now();
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER worker_lock_heartbeat_trigger BEFORE INSERT OR UPDATE ON worker_lock
+  FOR EACH ROW EXECUTE PROCEDURE worker_lock_heartbeat();
@@ -0,0 +1,25 @@
+-- the bulk operations on users requested by the admins, processed asynchronously
+CREATE TABLE user_bulk_operation (
+  user_bulk_operation_id uuid NOT NULL PRIMARY KEY,
+  operation text NOT NULL,
+  parameters jsonb,
+  requested_by text NOT NULL,
+  status text NOT NULL,
+  completed_at timestamp with time zone,
+  created_at timestamp with time zone NOT NULL,
+  updated_at timestamp with time zone
+);
+
+-- the result of a bulk operation for each of its users
+CREATE TABLE user_bulk_operation_item (
+  user_bulk_operation_item_id uuid NOT NULL PRIMARY KEY,
+  user_bulk_operation_id uuid NOT NULL REFERENCES user_bulk_operation (user_bulk_operation_id) ON DELETE CASCADE,
+  username text NOT NULL,
+  status text NOT NULL,
+  error text,
+  completed_at timestamp with time zone,
+  created_at timestamp with time zone NOT NULL,
+  updated_at timestamp with time zone
+);
+
+CREATE UNIQUE INDEX user_bulk_operation_item_username_idx ON user_bulk_operation_item (user_bulk_operation_id, username);
@@ -0,0 +1,10 @@
+-- trigram indexes for the fuzzy search of users on their username, full name, company and public email
+-- (the 'pg_trgm' extension was created in 026-identities-users-indexes.sql)
+CREATE INDEX ix_identities_username_lower_gin ON identities USING gin (lower(username) gin_trgm_ops);
+CREATE INDEX ix_users_company_gin ON users USING gin (lower(company) gin_trgm_ops);
+
+-- full-text index to match the words of the full name and company in any order
+CREATE INDEX ix_users_full_text_gin ON users USING gin (to_tsvector('simple', coalesce(full_name, '') || ' ' || coalesce(company, '')));
+
+-- index for the filter by cluster
+CREATE INDEX ix_users_cluster ON users (cluster);
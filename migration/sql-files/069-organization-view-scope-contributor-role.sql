@@ -0,0 +1,9 @@
+-- 'view' scope for organizations, required to list the members of an organization
+INSERT INTO resource_type_scope (resource_type_scope_id, resource_type_id, name, created_at) SELECT '51cbce0d-38c3-4f19-9203-575db532eb4e', rt.resource_type_id, 'view', now() FROM resource_type rt WHERE rt.name = 'identity/organization';
+
+-- the 'admin' role of organizations is granted the 'view' scope
+INSERT INTO role_scope (scope_id, role_id, created_at) SELECT '51cbce0d-38c3-4f19-9203-575db532eb4e', r.role_id, now() FROM role r, resource_type rt WHERE r.resource_type_id = rt.resource_type_id AND r.name = 'admin' AND rt.name = 'identity/organization';
+
+-- 'contributor' role of organizations, given to the former owner when the ownership of an organization is transferred
+INSERT INTO role (role_id, resource_type_id, name, created_at) SELECT 'e939f31c-52ec-4a92-ba99-4c3b1ced0271', rt.resource_type_id, 'contributor', now() FROM resource_type rt WHERE rt.name = 'identity/organization';
+INSERT INTO role_scope (scope_id, role_id, created_at) VALUES ('51cbce0d-38c3-4f19-9203-575db532eb4e', 'e939f31c-52ec-4a92-ba99-4c3b1ced0271', now());
@@ -0,0 +1,61 @@
+-- transitive closure of the memberships: a row exists for each identity that is a member of another identity, either
+-- directly or through nested groups, teams and organizations
+CREATE TABLE membership_closure (
+  member_id uuid NOT NULL REFERENCES identities (id) ON DELETE CASCADE,
+  member_of uuid NOT NULL REFERENCES identities (id) ON DELETE CASCADE,
+  PRIMARY KEY (member_id, member_of)
+);
+
+CREATE INDEX ix_membership_closure_member_of ON membership_closure (member_of);
+
+INSERT INTO membership_closure (member_id, member_of)
+  WITH RECURSIVE c AS (
+    SELECT member_id, member_of FROM membership
+    UNION SELECT c.member_id, m.member_of FROM c INNER JOIN membership m ON m.member_id = c.member_of
+  )
+  SELECT member_id, member_of FROM c;
+
+-- recomputes the closure rows of the given identity and of its transitive members
+CREATE OR REPLACE FUNCTION refresh_membership_closure(identity_id uuid) RETURNS void AS $$
+BEGIN
+  DELETE FROM membership_closure WHERE member_id IN (
+    WITH RECURSIVE affected AS (
+      SELECT identity_id AS id
+      UNION SELECT m.member_id FROM membership m INNER JOIN affected a ON m.member_of = a.id
+    )
+    SELECT id FROM affected);
+
+  INSERT INTO membership_closure (member_id, member_of)
+    WITH RECURSIVE affected AS (
+      SELECT identity_id AS id
+      UNION SELECT m.member_id FROM membership m INNER JOIN affected a ON m.member_of = a.id
+    ),
+    c AS (
+      SELECT m.member_id, m.member_of FROM membership m WHERE m.member_id IN (SELECT id FROM affected)
+      UNION SELECT c.member_id, m.member_of FROM c INNER JOIN membership m ON m.member_id = c.member_of
+    )
+    SELECT member_id, member_of FROM c;
+END;
+$$ LANGUAGE plpgsql;
+
+-- the closure is kept in sync with the memberships, including those removed in cascade when an identity is deleted
+CREATE OR REPLACE FUNCTION membership_closure_sync() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP = 'INSERT' THEN
+    PERFORM refresh_membership_closure(NEW.member_id);
+  ELSE
+    PERFORM refresh_membership_closure(OLD.member_id);
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER membership_closure_sync_trigger AFTER INSERT OR DELETE ON membership
+  FOR EACH ROW EXECUTE PROCEDURE membership_closure_sync();
+
+-- 'manage' and 'view' scopes of security groups, granted to the 'admin' role of the group
+INSERT INTO resource_type_scope (resource_type_scope_id, resource_type_id, name, created_at) SELECT '48c6188d-7c68-4e69-aedd-e87ccd3b37c7', rt.resource_type_id, 'manage', now() FROM resource_type rt WHERE rt.name = 'identity/group';
+INSERT INTO resource_type_scope (resource_type_scope_id, resource_type_id, name, created_at) SELECT 'a325e0db-8019-43a3-8b4b-3cc6eba4e7c5', rt.resource_type_id, 'view', now() FROM resource_type rt WHERE rt.name = 'identity/group';
+INSERT INTO role (role_id, resource_type_id, name, created_at) SELECT '6affbf5f-6ec4-4541-82ac-5e54afe91dd1', rt.resource_type_id, 'admin', now() FROM resource_type rt WHERE rt.name = 'identity/group';
+INSERT INTO role_scope (scope_id, role_id, created_at) VALUES ('48c6188d-7c68-4e69-aedd-e87ccd3b37c7', '6affbf5f-6ec4-4541-82ac-5e54afe91dd1', now());
+INSERT INTO role_scope (scope_id, role_id, created_at) VALUES ('a325e0db-8019-43a3-8b4b-3cc6eba4e7c5', '6affbf5f-6ec4-4541-82ac-5e54afe91dd1', now());
@@ -0,0 +1,22 @@
+-- the admin-managed definitions of the custom attributes of the user profiles, with the rules which their values must follow
+CREATE TABLE user_profile_attribute (
+  name text NOT NULL PRIMARY KEY,
+  description text,
+  type text NOT NULL,
+  visibility text NOT NULL,
+  indexable boolean NOT NULL DEFAULT false,
+  pattern text,
+  min_length integer,
+  max_length integer,
+  minimum bigint,
+  maximum bigint,
+  allowed_values text[],
+  created_at timestamp with time zone NOT NULL,
+  updated_at timestamp with time zone
+);
+
+-- the values of the custom attributes of the users, by attribute name
+ALTER TABLE users ADD COLUMN profile_attributes jsonb DEFAULT '{}';
+
+-- index for the search of the users by the values of their indexable attributes
+CREATE INDEX ix_users_profile_attributes_gin ON users USING gin (profile_attributes jsonb_path_ops);
@@ -0,0 +1,22 @@
+-- the verification codes expire. The codes created before can be used until they are verified.
+ALTER TABLE verification_codes ADD COLUMN expires_at timestamp with time zone;
+
+-- the verification codes recently sent to a user are counted to limit their rate
+CREATE INDEX verification_codes_user_id_created_at_idx ON verification_codes USING btree (user_id, created_at);
+
+-- the changes of the email addresses of the users which are not confirmed yet. The new address receives a verification
+-- code to confirm the change, and the current address a code to cancel it. A user has at most one pending change.
+CREATE TABLE pending_email_change (
+  pending_email_change_id uuid NOT NULL PRIMARY KEY,
+  user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  new_email text NOT NULL,
+  verification_code_id uuid NOT NULL REFERENCES verification_codes(id) ON DELETE CASCADE,
+  cancel_code text NOT NULL,
+  expires_at timestamp with time zone NOT NULL,
+  created_at timestamp with time zone NOT NULL,
+  updated_at timestamp with time zone
+);
+
+CREATE UNIQUE INDEX pending_email_change_user_id_idx ON pending_email_change (user_id);
+CREATE UNIQUE INDEX pending_email_change_cancel_code_idx ON pending_email_change (cancel_code);
+CREATE INDEX pending_email_change_verification_code_id_idx ON pending_email_change USING btree (verification_code_id);
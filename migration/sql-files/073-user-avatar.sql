@@ -0,0 +1,2 @@
+-- the avatar uploaded by the user, whose images are stored in the blob storage. The image URL of the user points at it.
+ALTER TABLE users ADD COLUMN avatar_id uuid;
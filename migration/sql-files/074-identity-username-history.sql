@@ -0,0 +1,14 @@
+-- the previous usernames of the identities. The lookups by username resolve them to the identity which used them last,
+-- unless another identity has taken the username since. A previous username is reserved to its former owner until
+-- the end of the cooldown period which follows the change.
+CREATE TABLE identity_username_history (
+  username_history_id uuid NOT NULL PRIMARY KEY,
+  identity_id uuid NOT NULL REFERENCES identities(id) ON DELETE CASCADE,
+  username text NOT NULL,
+  reserved_until timestamp with time zone NOT NULL,
+  created_at timestamp with time zone NOT NULL,
+  updated_at timestamp with time zone
+);
+
+CREATE INDEX identity_username_history_username_idx ON identity_username_history USING btree (username, created_at);
+CREATE INDEX identity_username_history_identity_id_idx ON identity_username_history USING btree (identity_id, created_at);
@@ -0,0 +1,4 @@
+-- the consecutive invalid codes of a user, across all the login challenges, and the time until which the verification
+-- of their codes is locked after too many invalid codes
+ALTER TABLE totp_credential ADD COLUMN failed_attempts integer NOT NULL DEFAULT 0;
+ALTER TABLE totp_credential ADD COLUMN locked_until timestamp with time zone;
@@ -0,0 +1,4 @@
+-- recreate the constraint on the roles of the invitations so that the invitations of the purged accounts can be deleted
+-- along with their roles
+ALTER table invitation_role drop constraint invitation_role_invitation_id_fkey;
+ALTER table invitation_role add constraint invitation_role_invitation_id_fkey FOREIGN KEY (invitation_id) REFERENCES invitation(invitation_id) ON DELETE CASCADE;
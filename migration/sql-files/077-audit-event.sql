@@ -0,0 +1,17 @@
+-- the audit events of the identities, kept after they were forwarded to the admin console service so that they can be
+-- part of the exports of the users' data. The events are removed when the account is purged.
+CREATE TABLE audit_event (
+  audit_event_id uuid NOT NULL PRIMARY KEY,
+  identity_id uuid NOT NULL REFERENCES identities(id) ON DELETE CASCADE,
+  event_type text NOT NULL,
+  created_at timestamp with time zone NOT NULL,
+  updated_at timestamp with time zone
+);
+
+CREATE INDEX audit_event_identity_id_idx ON audit_event USING btree (identity_id, created_at);
+
+-- record the audit events which were not forwarded yet
+INSERT INTO audit_event (audit_event_id, identity_id, event_type, created_at, updated_at)
+  SELECT o.outbox_event_id, o.identity_id, o.payload->>'event_type', o.created_at, o.created_at
+  FROM outbox_event o
+  WHERE o.event_type = 'audit_log' AND o.payload->>'event_type' IS NOT NULL AND EXISTS (SELECT 1 FROM identities i WHERE i.id = o.identity_id);
@@ -0,0 +1,2 @@
+-- the inactive identities are listed by keyset on their last activity, creation date and ID
+CREATE INDEX identities_inactive_keyset_idx ON identities USING btree (last_active, created_at, id);
@@ -0,0 +1,8 @@
+-- a confirmed change of the email address is kept until it expires, along with the previous address of the user, so
+-- that it can still be reverted with the code sent to the previous address. Its verification code is deleted once used.
+ALTER TABLE pending_email_change ADD COLUMN previous_email text;
+ALTER TABLE pending_email_change ADD COLUMN confirmed_at timestamp with time zone;
+ALTER TABLE pending_email_change ALTER COLUMN verification_code_id DROP NOT NULL;
+ALTER TABLE pending_email_change DROP CONSTRAINT pending_email_change_verification_code_id_fkey;
+ALTER TABLE pending_email_change ADD CONSTRAINT pending_email_change_verification_code_id_fkey
+  FOREIGN KEY (verification_code_id) REFERENCES verification_codes(id) ON DELETE SET NULL;
@@ -0,0 +1,21 @@
+-- the authorization codes returned by the OAuth provider to the authorize callback, which exchanges them for the
+-- provider token so that the second factor of the user is verified before the code is returned to the client. Only the
+-- hash of the code is stored, and the provider token is encrypted like the external tokens.
+CREATE TABLE authorization_code (
+  authorization_code_id uuid NOT NULL PRIMARY KEY,
+  code_hash text NOT NULL,
+  provider_token text NOT NULL,
+  encrypted_data_key text NOT NULL,
+  master_key_id text NOT NULL,
+  second_factor_required boolean NOT NULL DEFAULT false,
+  auth_method text,
+  expires_at timestamp with time zone NOT NULL,
+  created_at timestamp with time zone NOT NULL,
+  updated_at timestamp with time zone
+);
+
+CREATE UNIQUE INDEX authorization_code_code_hash_idx ON authorization_code (code_hash);
+CREATE INDEX authorization_code_expires_at_idx ON authorization_code USING btree (expires_at);
+
+-- the login challenges of the authorization code flow release the authorization code once verified
+ALTER TABLE mfa_challenge ADD COLUMN authorization_code_id uuid REFERENCES authorization_code (authorization_code_id) ON DELETE CASCADE;
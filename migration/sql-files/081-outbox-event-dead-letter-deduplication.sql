@@ -0,0 +1,4 @@
+-- the dead-lettered events are kept for inspection: they must not prevent the same side effect from being recorded
+-- again, nor an event from being redriven, as with the queue tasks
+DROP INDEX outbox_event_deduplication_key_idx;
+CREATE UNIQUE INDEX outbox_event_deduplication_key_idx ON outbox_event USING btree (deduplication_key) WHERE dead_lettered_at IS NULL;
@@ -0,0 +1,16 @@
+-- the outbox events are delivered by the consumers of the outbox work queues, in all the pods: the pending and
+-- dead-lettered events are moved to the queues, with the default maximum number of attempts. The deprovisioning events
+-- have their own queue, so that they can be throttled without delaying the other events.
+INSERT INTO queue_task (queue_task_id, queue, priority, payload, deduplication_key, attempts, max_attempts, visible_at,
+    last_error, dead_lettered_at, created_at, updated_at)
+  SELECT outbox_event_id,
+    CASE WHEN event_type = 'deprovision_user' THEN 'outbox-deprovision' ELSE 'outbox' END,
+    0,
+    jsonb_build_object('identity_id', identity_id, 'event_type', event_type, 'payload', payload),
+    deduplication_key, attempts, GREATEST(12, attempts + 1), next_attempt, last_error, dead_lettered_at, created_at,
+    updated_at
+  FROM outbox_event;
+
+CREATE INDEX queue_task_outbox_identity_id_idx ON queue_task USING btree ((payload->>'identity_id')) WHERE queue IN ('outbox', 'outbox-deprovision');
+
+DROP TABLE outbox_event;
@@ -0,0 +1,3 @@
+-- the session which was terminated, when a single session of the user was terminated: the logout token then identifies
+-- the session with its `sid` claim
+ALTER TABLE backchannel_logout_notification ADD COLUMN session_id uuid;
@@ -36,6 +36,37 @@ func NewUserEmailUpdated(identityID string, custom map[string]interface{}) Messa
 	}
 }
 
+// NewUserUsernameUpdated is a helper constructor which returns a message to inform the dependent services that the
+// username of the user changed, so that they can migrate the data and the URLs based on it
+func NewUserUsernameUpdated(identityID, previousUsername, username string) Message {
+	return Message{
+		MessageID:   uuid.NewV4(),
+		MessageType: "user.username.update",
+		TargetID:    identityID,
+		UserID:      &identityID,
+		Custom: map[string]interface{}{
+			"previousUsername": previousUsername,
+			"username":         username,
+		},
+	}
+}
+
+// NewUserEmailChangeRequestedEmail is a helper constructor which returns a message sent to the current email address of
+// the user, to inform her that a change of her email address was requested and to let her cancel it
+func NewUserEmailChangeRequestedEmail(identityID, email, newEmail, cancelURL string) Message {
+	return Message{
+		MessageID:   uuid.NewV4(),
+		MessageType: "user.email.change.requested",
+		TargetID:    identityID,
+		UserID:      &identityID,
+		Custom: map[string]interface{}{
+			"userEmail": email,
+			"newEmail":  newEmail,
+			"cancelURL": cancelURL,
+		},
+	}
+}
+
 // NewTeamInvitationEmail creates a Message for the notification service in order to send an invitation e-mail to a user
 //
 // The following custom parameter values are required:
@@ -96,3 +127,18 @@ func NewUserDeactivationEmail(identityID, email, deactivationDate string) Messag
 		},
 	}
 }
+
+// NewUserDeletionRequestedEmail is a helper constructor which returns a message to inform the user that her
+// account will be deleted at the given date, unless she logs in again before
+func NewUserDeletionRequestedEmail(identityID, email, deletionDate string) Message {
+	return Message{
+		MessageID:   uuid.NewV4(),
+		MessageType: "user.deletion.requested",
+		TargetID:    identityID,
+		UserID:      &identityID,
+		Custom: map[string]interface{}{
+			"userEmail":    email,
+			"deletionDate": deletionDate,
+		},
+	}
+}
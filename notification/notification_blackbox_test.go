@@ -30,3 +30,26 @@ func (s *TestNotificationSuite) TestNewUserEmailUpdatedOK() {
 	assert.Equal(s.T(), &userID, msg.UserID)
 	assert.Equal(s.T(), custom, msg.Custom)
 }
+
+func (s *TestNotificationSuite) TestNewUserUsernameUpdatedOK() {
+	userID := uuid.NewV4().String()
+
+	msg := notification.NewUserUsernameUpdated(userID, "jdoe", "john.doe")
+	assert.Equal(s.T(), "user.username.update", msg.MessageType)
+	assert.Equal(s.T(), userID, msg.TargetID)
+	assert.Equal(s.T(), &userID, msg.UserID)
+	assert.Equal(s.T(), "jdoe", msg.Custom["previousUsername"])
+	assert.Equal(s.T(), "john.doe", msg.Custom["username"])
+}
+
+func (s *TestNotificationSuite) TestNewUserEmailChangeRequestedEmailOK() {
+	userID := uuid.NewV4().String()
+
+	msg := notification.NewUserEmailChangeRequestedEmail(userID, "old@example.com", "new@example.com", "http://example.com/cancel")
+	assert.Equal(s.T(), "user.email.change.requested", msg.MessageType)
+	assert.Equal(s.T(), userID, msg.TargetID)
+	assert.Equal(s.T(), &userID, msg.UserID)
+	assert.Equal(s.T(), "old@example.com", msg.Custom["userEmail"])
+	assert.Equal(s.T(), "new@example.com", msg.Custom["newEmail"])
+	assert.Equal(s.T(), "http://example.com/cancel", msg.Custom["cancelURL"])
+}
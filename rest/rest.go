@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"regexp"
@@ -178,3 +179,17 @@ func AddTrailingSlashToURL(url string) string {
 	}
 	return url
 }
+
+// ClientIP makes a best effort to compute the IP address of the client which sent the given request,
+// taking into account the `X-Forwarded-For` header set by the proxies
+func ClientIP(req *http.Request) string {
+	if f := req.Header.Get("X-Forwarded-For"); f != "" {
+		// the left-most address is the one of the client
+		return strings.TrimSpace(strings.Split(f, ",")[0])
+	}
+	ip, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return ip
+}
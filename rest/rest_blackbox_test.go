@@ -279,3 +279,19 @@ func equalURLs(t *testing.T, expected string, actual string) {
 		assert.Equal(t, value, actualURL.Query()[name])
 	}
 }
+
+func TestClientIP(t *testing.T) {
+	resource.Require(t, resource.UnitTest)
+	t.Parallel()
+
+	t.Run("from remote address", func(t *testing.T) {
+		req := &http.Request{RemoteAddr: "10.0.0.1:54321", Header: http.Header{}}
+		assert.Equal(t, "10.0.0.1", ClientIP(req))
+	})
+
+	t.Run("from forwarded header", func(t *testing.T) {
+		req := &http.Request{RemoteAddr: "10.0.0.1:54321", Header: http.Header{}}
+		req.Header.Set("X-Forwarded-For", "203.0.113.5, 10.0.0.2")
+		assert.Equal(t, "203.0.113.5", ClientIP(req))
+	})
+}
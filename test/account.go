@@ -101,6 +101,12 @@ var TestAdminConsoleIdentity = account.Identity{
 	User:     TestUser,
 }
 
+var TestSCIMProvisioningIdentity = account.Identity{
+	ID:       uuid.NewV4(),
+	Username: "scim-provisioning",
+	User:     TestUser,
+}
+
 // CreateLonelyTestIdentity creates an identity not associated with any user. For testing purpose only.
 func CreateLonelyTestIdentity(db *gorm.DB, username string) (account.Identity, error) {
 	testIdentity := account.Identity{
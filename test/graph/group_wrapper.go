@@ -0,0 +1,74 @@
+package graph
+
+import (
+	account "github.com/fabric8-services/fabric8-auth/authentication/account/repository"
+	resource "github.com/fabric8-services/fabric8-auth/authorization/resource/repository"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// groupWrapper represents a security group resource domain object
+type groupWrapper struct {
+	baseWrapper
+	identity *account.Identity
+	resource *resource.Resource
+	creator  *account.Identity
+}
+
+func newGroupWrapper(g *TestGraph, params []interface{}) interface{} {
+	w := groupWrapper{baseWrapper: baseWrapper{g}}
+
+	var groupName *string
+
+	for i := range params {
+		switch t := params[i].(type) {
+		case string:
+			groupName = &t
+		case *userWrapper:
+			w.creator = t.Identity()
+		case userWrapper:
+			w.creator = t.Identity()
+		}
+	}
+
+	if w.creator == nil {
+		w.creator = w.graph.CreateUser().Identity()
+	}
+
+	if groupName == nil {
+		nm := "Group-" + uuid.NewV4().String()
+		groupName = &nm
+	}
+
+	groupID, err := g.app.GroupService().CreateGroup(g.ctx, w.creator.ID, *groupName)
+	require.NoError(g.t, err)
+
+	w.identity = g.LoadIdentity(groupID).Identity()
+	w.resource = g.LoadResource(w.identity.IdentityResourceID.String).Resource()
+	w.identity.IdentityResource = *w.resource
+	return &w
+}
+
+func (w *groupWrapper) GroupID() uuid.UUID {
+	return w.identity.ID
+}
+
+func (w *groupWrapper) Identity() *account.Identity {
+	return w.identity
+}
+
+func (w *groupWrapper) Resource() *resource.Resource {
+	return w.resource
+}
+
+func (w *groupWrapper) ResourceID() string {
+	return w.resource.ResourceID
+}
+
+// AddMember adds the given user or identity as a member of the group
+func (w *groupWrapper) AddMember(wrapper interface{}) *groupWrapper {
+	identityID := identityIDFromWrapper(w.graph.t, wrapper)
+	err := w.graph.app.Identities().AddMember(w.graph.ctx, w.identity.ID, identityID)
+	require.NoError(w.graph.t, err)
+	return w
+}
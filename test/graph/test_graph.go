@@ -42,8 +42,10 @@ func identityIDFromWrapper(t *testing.T, wrapper interface{}) uuid.UUID {
 		return w.identity.ID
 	case *organizationWrapper:
 		return w.identity.ID
+	case *groupWrapper:
+		return w.identity.ID
 	}
-	assert.FailNowf(t, "invalid type of identity wrapper", "wrapper must be either 'user', 'identity' or 'team' wrapper but it was %T", wrapper)
+	assert.FailNowf(t, "invalid type of identity wrapper", "wrapper must be either 'user', 'identity', 'team', 'organization' or 'group' wrapper but it was %T", wrapper)
 	return uuid.UUID{}
 }
 
@@ -181,6 +183,14 @@ func (g *TestGraph) LoadTeam(params ...interface{}) *teamWrapper {
 	return &w
 }
 
+func (g *TestGraph) CreateGroup(params ...interface{}) *groupWrapper {
+	return g.createAndRegister(newGroupWrapper, params).(*groupWrapper)
+}
+
+func (g *TestGraph) GroupByID(id string) *groupWrapper {
+	return g.references[id].(*groupWrapper)
+}
+
 func (g *TestGraph) CreateOrganization(params ...interface{}) *organizationWrapper {
 	return g.createAndRegister(newOrganizationWrapper, params).(*organizationWrapper)
 }
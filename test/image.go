@@ -0,0 +1,38 @@
+package test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// NewImage returns an image of the given dimensions, whose left half is red and right half is blue
+func NewImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if x < width/2 {
+				img.Set(x, y, color.RGBA{R: 255, A: 255})
+			} else {
+				img.Set(x, y, color.RGBA{B: 255, A: 255})
+			}
+		}
+	}
+	return img
+}
+
+// EncodePNG returns the given image encoded in PNG
+func EncodePNG(t *testing.T, img image.Image) []byte {
+	buf := &bytes.Buffer{}
+	require.NoError(t, png.Encode(buf, img))
+	return buf.Bytes()
+}
+
+// NewPNGImage returns a new image of the given dimensions (see `NewImage`), encoded in PNG
+func NewPNGImage(t *testing.T, width, height int) []byte {
+	return EncodePNG(t, NewImage(width, height))
+}
@@ -0,0 +1,194 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+
+	errs "github.com/pkg/errors"
+)
+
+// Authenticator a software WebAuthn authenticator holding a single ES256 credential, to run the registration and
+// assertion ceremonies in tests
+type Authenticator struct {
+	CredentialID []byte
+	PrivateKey   *ecdsa.PrivateKey
+	AAGUID       []byte
+	// SignCount the signature counter, incremented on each assertion
+	SignCount uint32
+	// Packed true to return a `packed` self attestation instead of a `none` attestation
+	Packed bool
+}
+
+// NewAuthenticator returns a new software authenticator with a new random credential
+func NewAuthenticator() (*Authenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	credentialID := make([]byte, 16)
+	_, err = rand.Read(credentialID)
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	return &Authenticator{
+		CredentialID: credentialID,
+		PrivateKey:   key,
+		AAGUID:       make([]byte, 16),
+	}, nil
+}
+
+// ClientDataJSON returns the client data that a browser would collect for the given ceremony type
+// (`webauthn.create` or `webauthn.get`), origin and challenge
+func ClientDataJSON(ceremonyType string, origin string, challenge []byte) []byte {
+	clientData, _ := json.Marshal(map[string]interface{}{
+		"type":      ceremonyType,
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    origin,
+	})
+	return clientData
+}
+
+// Register runs the registration ceremony for the given RP ID, origin and challenge, and returns the client data and
+// the attestation object
+func (a *Authenticator) Register(rpID string, origin string, challenge []byte) ([]byte, []byte, error) {
+	clientDataJSON := ClientDataJSON("webauthn.create", origin, challenge)
+	authData := a.authenticatorData(rpID, true)
+	statement := cborMap{}
+	format := "none"
+	if a.Packed {
+		signature, err := a.sign(authData, clientDataJSON)
+		if err != nil {
+			return nil, nil, err
+		}
+		format = "packed"
+		statement = cborMap{{"alg", int64(-7)}, {"sig", signature}}
+	}
+	attestationObject := encodeCBOR(cborMap{
+		{"fmt", format},
+		{"attStmt", statement},
+		{"authData", authData},
+	})
+	return clientDataJSON, attestationObject, nil
+}
+
+// Assert runs the assertion ceremony for the given RP ID, origin and challenge, and returns the client data, the
+// authenticator data and the signature
+func (a *Authenticator) Assert(rpID string, origin string, challenge []byte) ([]byte, []byte, []byte, error) {
+	a.SignCount++
+	clientDataJSON := ClientDataJSON("webauthn.get", origin, challenge)
+	authData := a.authenticatorData(rpID, false)
+	signature, err := a.sign(authData, clientDataJSON)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return clientDataJSON, authData, signature, nil
+}
+
+// PublicKey returns the COSE_Key encoding of the credential public key
+func (a *Authenticator) PublicKey() []byte {
+	return encodeCBOR(cborMap{
+		{int64(1), int64(2)},  // kty: EC2
+		{int64(3), int64(-7)}, // alg: ES256
+		{int64(-1), int64(1)}, // crv: P-256
+		{int64(-2), padded(a.PrivateKey.X, 32)},
+		{int64(-3), padded(a.PrivateKey.Y, 32)},
+	})
+}
+
+func (a *Authenticator) authenticatorData(rpID string, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append([]byte{}, rpIDHash[:]...)
+	flags := byte(0x01 | 0x04) // user present and verified
+	if attested {
+		flags |= 0x40
+	}
+	data = append(data, flags)
+	counter := make([]byte, 4)
+	binary.BigEndian.PutUint32(counter, a.SignCount)
+	data = append(data, counter...)
+	if attested {
+		data = append(data, a.AAGUID...)
+		length := make([]byte, 2)
+		binary.BigEndian.PutUint16(length, uint16(len(a.CredentialID)))
+		data = append(data, length...)
+		data = append(data, a.CredentialID...)
+		data = append(data, a.PublicKey()...)
+	}
+	return data
+}
+
+func (a *Authenticator) sign(authData []byte, clientDataJSON []byte) ([]byte, error) {
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	r, s, err := ecdsa.Sign(rand.Reader, a.PrivateKey, digest[:])
+	if err != nil {
+		return nil, errs.WithStack(err)
+	}
+	return asn1.Marshal(struct {
+		R, S *big.Int
+	}{r, s})
+}
+
+func padded(value *big.Int, length int) []byte {
+	result := make([]byte, length)
+	bytes := value.Bytes()
+	copy(result[length-len(bytes):], bytes)
+	return result
+}
+
+// cborMap a CBOR map, as an ordered list of key/value pairs
+type cborMap [][2]interface{}
+
+// encodeCBOR encodes the given value in CBOR. Only the types used by the attestation objects and COSE keys are
+// supported: int64, []byte, string and cborMap.
+func encodeCBOR(value interface{}) []byte {
+	switch v := value.(type) {
+	case int64:
+		if v < 0 {
+			return cborHead(1, uint64(-1-v))
+		}
+		return cborHead(0, uint64(v))
+	case []byte:
+		return append(cborHead(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHead(3, uint64(len(v))), v...)
+	case cborMap:
+		result := cborHead(5, uint64(len(v)))
+		for _, entry := range v {
+			result = append(result, encodeCBOR(entry[0])...)
+			result = append(result, encodeCBOR(entry[1])...)
+		}
+		return result
+	default:
+		panic(errs.Errorf("unsupported CBOR type %T", value))
+	}
+}
+
+func cborHead(majorType byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return []byte{majorType<<5 | byte(arg)}
+	case arg <= 0xff:
+		return []byte{majorType<<5 | 24, byte(arg)}
+	case arg <= 0xffff:
+		head := []byte{majorType<<5 | 25, 0, 0}
+		binary.BigEndian.PutUint16(head[1:], uint16(arg))
+		return head
+	case arg <= 0xffffffff:
+		head := []byte{majorType<<5 | 26, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(head[1:], uint32(arg))
+		return head
+	default:
+		head := make([]byte, 9)
+		head[0] = majorType<<5 | 27
+		binary.BigEndian.PutUint64(head[1:], arg)
+		return head
+	}
+}
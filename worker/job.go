@@ -0,0 +1,213 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/metric"
+	"github.com/fabric8-services/fabric8-auth/worker/repository"
+
+	errs "github.com/pkg/errors"
+)
+
+// JobRunRetention the duration during which the records of the runs of a job are kept
+const JobRunRetention = 30 * 24 * time.Hour
+
+// Job a background task, run by a single pod at a time according to its schedule
+type Job struct {
+	// Name the name of the job. Also, the name of the lock used by its worker
+	Name string
+	// Schedule the schedule of the job (see `ParseSchedule`)
+	Schedule string
+	// Run performs the task and returns the number of processed items (users, events, etc.)
+	Run func(ctx context.Context) (int, error)
+}
+
+// NewJobWorker returns a worker which runs the given job according to its schedule, or when a run is requested
+// through the admin API. Each run is recorded in the `job_run` table and in the metrics. The worker checks if the job
+// is due at each cycle, so the frequency of the worker is the precision of the schedule.
+func NewJobWorker(ctx context.Context, app application.Application, job Job) (Worker, error) {
+	schedule, err := ParseSchedule(job.Schedule)
+	if err != nil {
+		return nil, errs.Wrapf(err, "invalid schedule for job '%s'", job.Name)
+	}
+	w := &jobWorker{
+		BaseWorker: BaseWorker{
+			Ctx:   ctx,
+			App:   app,
+			Owner: GetLockOwner(ctx),
+			Name:  job.Name,
+		},
+		job:      job,
+		schedule: schedule,
+	}
+	w.Do = w.poll
+	w.OnLockAcquired = w.failInterruptedRuns
+	return w, nil
+}
+
+type jobWorker struct {
+	BaseWorker
+	job        Job
+	schedule   Schedule
+	registered bool
+}
+
+// failInterruptedRuns marks the runs of the job which are still `running` as failed. Since the lock was just acquired
+// by this worker, no other pod can be running the job: such runs were interrupted before their outcome was recorded
+// (eg, because the pod running them crashed or was killed).
+func (w *jobWorker) failInterruptedRuns() {
+	count, err := w.App.JobRunRepository().FailRunning(w.Ctx, w.job.Name, "interrupted: the worker running the job stopped before the end of the run", time.Now())
+	if err != nil {
+		log.Error(w.Ctx, map[string]interface{}{
+			"err": err,
+			"job": w.job.Name,
+		}, "unable to fail the interrupted runs of the job")
+		return
+	}
+	if count > 0 {
+		log.Warn(w.Ctx, map[string]interface{}{
+			"job":  w.job.Name,
+			"runs": count,
+		}, "failed the interrupted runs of the job")
+	}
+}
+
+// poll runs the job if a run was requested, or if the job is due and not paused
+func (w *jobWorker) poll() {
+	if !w.registered {
+		_, err := w.App.JobRepository().Register(w.Ctx, w.job.Name, w.job.Schedule)
+		if err != nil {
+			log.Error(w.Ctx, map[string]interface{}{
+				"err": err,
+				"job": w.job.Name,
+			}, "unable to register the job")
+			return
+		}
+		w.registered = true
+	}
+	state, err := w.App.JobRepository().Load(w.Ctx, w.job.Name)
+	if err != nil {
+		log.Error(w.Ctx, map[string]interface{}{
+			"err": err,
+			"job": w.job.Name,
+		}, "unable to load the state of the job")
+		return
+	}
+	if state.TriggerRequestedAt != nil {
+		log.Info(w.Ctx, map[string]interface{}{
+			"job":          w.job.Name,
+			"requested_at": *state.TriggerRequestedAt,
+			"requested_by": state.TriggerRequestedBy,
+		}, "running job on demand")
+		w.run(repository.JobRunTriggerManual)
+		err := w.App.JobRepository().ClearTrigger(w.Ctx, w.job.Name, *state.TriggerRequestedAt)
+		if err != nil {
+			log.Error(w.Ctx, map[string]interface{}{
+				"err": err,
+				"job": w.job.Name,
+			}, "unable to clear the trigger of the job")
+		}
+		return
+	}
+	if state.Paused {
+		log.Debug(w.Ctx, map[string]interface{}{
+			"job": w.job.Name,
+		}, "job is paused")
+		return
+	}
+	due, err := w.due(state, time.Now())
+	if err != nil {
+		log.Error(w.Ctx, map[string]interface{}{
+			"err": err,
+			"job": w.job.Name,
+		}, "unable to check if the job is due")
+		return
+	}
+	if due {
+		w.run(repository.JobRunTriggerSchedule)
+	}
+}
+
+// due returns true if the next run of the job after its latest run (or after its registration) is due at the given time
+func (w *jobWorker) due(state *repository.Job, now time.Time) (bool, error) {
+	last := state.CreatedAt
+	latest, err := w.App.JobRunRepository().LoadLatest(w.Ctx, w.job.Name)
+	if err != nil {
+		if notFound, _ := errors.IsNotFoundError(err); !notFound {
+			return false, err
+		}
+	} else {
+		last = latest.StartedAt
+	}
+	next := w.schedule.Next(last)
+	return !next.IsZero() && !now.Before(next), nil
+}
+
+// run runs the job and records its outcome
+func (w *jobWorker) run(trigger string) {
+	run := &repository.JobRun{
+		JobName:   w.job.Name,
+		Owner:     w.Owner,
+		Trigger:   trigger,
+		StartedAt: time.Now(),
+		Outcome:   repository.JobRunOutcomeRunning,
+	}
+	err := w.App.JobRunRepository().Create(w.Ctx, run)
+	if err != nil {
+		log.Error(w.Ctx, map[string]interface{}{
+			"err": err,
+			"job": w.job.Name,
+		}, "unable to record the start of the job run")
+		return
+	}
+	items, err := w.runJob()
+	end := time.Now()
+	run.EndedAt = &end
+	run.ItemsProcessed = items
+	if err != nil {
+		msg := err.Error()
+		run.Outcome = repository.JobRunOutcomeFailure
+		run.Error = &msg
+		log.Error(w.Ctx, map[string]interface{}{
+			"err":             err,
+			"job":             w.job.Name,
+			"items_processed": items,
+		}, "job run failed")
+	} else {
+		run.Outcome = repository.JobRunOutcomeSuccess
+		log.Info(w.Ctx, map[string]interface{}{
+			"job":             w.job.Name,
+			"items_processed": items,
+			"duration":        run.Duration(),
+		}, "job run succeeded")
+	}
+	metric.RecordJobRun(w.job.Name, err == nil, run.Duration(), items, end)
+	err = w.App.JobRunRepository().Save(w.Ctx, run)
+	if err != nil {
+		log.Error(w.Ctx, map[string]interface{}{
+			"err": err,
+			"job": w.job.Name,
+		}, "unable to record the end of the job run")
+	}
+	_, err = w.App.JobRunRepository().DeleteBefore(w.Ctx, w.job.Name, end.Add(-JobRunRetention))
+	if err != nil {
+		log.Error(w.Ctx, map[string]interface{}{
+			"err": err,
+			"job": w.job.Name,
+		}, "unable to delete the old runs of the job")
+	}
+}
+
+// runJob runs the job, and turns a panic into an error so that it is recorded as a failed run
+func (w *jobWorker) runJob() (items int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errs.Errorf("job panicked: %v", r)
+		}
+	}()
+	return w.job.Run(w.Ctx)
+}
@@ -0,0 +1,199 @@
+package worker_test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/worker"
+	"github.com/fabric8-services/fabric8-auth/worker/repository"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *WorkerTestSuite) TestJobWorker() {
+
+	ctx := context.Background()
+	freq := time.Millisecond * 50
+
+	// eventually waits until the given condition is met, or fails after a few seconds
+	eventually := func(t *testing.T, condition func() bool) {
+		for i := 0; i < 100; i++ {
+			if condition() {
+				return
+			}
+			time.Sleep(freq)
+		}
+		require.FailNow(t, "condition not met in time")
+	}
+	runsOf := func(t *testing.T, name string) []repository.JobRun {
+		runs, err := s.Application.JobRunRepository().ListForJob(ctx, name, 10)
+		require.NoError(t, err)
+		return runs
+	}
+
+	s.T().Run("run on demand", func(t *testing.T) {
+		// given
+		name := fmt.Sprintf("test-job-%s", uuid.NewV4())
+		var count int32
+		w, err := worker.NewJobWorker(ctx, s.Application, worker.Job{
+			Name:     name,
+			Schedule: "@yearly",
+			Run: func(ctx context.Context) (int, error) {
+				atomic.AddInt32(&count, 1)
+				return 3, nil
+			},
+		})
+		require.NoError(t, err)
+		w.Start(freq)
+		defer stop(w)
+		eventually(t, func() bool {
+			_, err := s.Application.JobRepository().Load(ctx, name)
+			return err == nil
+		})
+		// when
+		err = s.Application.JobRepository().RequestTrigger(ctx, name, "tester")
+		require.NoError(t, err)
+		// then
+		eventually(t, func() bool {
+			runs := runsOf(t, name)
+			return len(runs) == 1 && runs[0].Outcome != repository.JobRunOutcomeRunning
+		})
+		runs := runsOf(t, name)
+		assert.Equal(t, repository.JobRunOutcomeSuccess, runs[0].Outcome)
+		assert.Equal(t, repository.JobRunTriggerManual, runs[0].Trigger)
+		assert.Equal(t, 3, runs[0].ItemsProcessed)
+		assert.NotNil(t, runs[0].EndedAt)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+		job, err := s.Application.JobRepository().Load(ctx, name)
+		require.NoError(t, err)
+		assert.Nil(t, job.TriggerRequestedAt)
+	})
+
+	s.T().Run("run on schedule", func(t *testing.T) {
+		// given
+		name := fmt.Sprintf("test-job-%s", uuid.NewV4())
+		w, err := worker.NewJobWorker(ctx, s.Application, worker.Job{
+			Name:     name,
+			Schedule: "@every 1s",
+			Run: func(ctx context.Context) (int, error) {
+				return 0, nil
+			},
+		})
+		require.NoError(t, err)
+		// when
+		w.Start(freq)
+		defer stop(w)
+		// then
+		eventually(t, func() bool {
+			return len(runsOf(t, name)) > 0
+		})
+		assert.Equal(t, repository.JobRunTriggerSchedule, runsOf(t, name)[0].Trigger)
+	})
+
+	s.T().Run("paused", func(t *testing.T) {
+		// given
+		name := fmt.Sprintf("test-job-%s", uuid.NewV4())
+		_, err := s.Application.JobRepository().Register(ctx, name, "@every 1s")
+		require.NoError(t, err)
+		err = s.Application.JobRepository().SetPaused(ctx, name, true)
+		require.NoError(t, err)
+		w, err := worker.NewJobWorker(ctx, s.Application, worker.Job{
+			Name:     name,
+			Schedule: "@every 1s",
+			Run: func(ctx context.Context) (int, error) {
+				return 0, nil
+			},
+		})
+		require.NoError(t, err)
+		// when
+		w.Start(freq)
+		defer stop(w)
+		time.Sleep(2 * time.Second)
+		// then
+		assert.Empty(t, runsOf(t, name))
+		job, err := s.Application.JobRepository().Load(ctx, name)
+		require.NoError(t, err)
+		assert.True(t, job.Paused)
+	})
+
+	s.T().Run("failure", func(t *testing.T) {
+		// given
+		name := fmt.Sprintf("test-job-%s", uuid.NewV4())
+		w, err := worker.NewJobWorker(ctx, s.Application, worker.Job{
+			Name:     name,
+			Schedule: "@yearly",
+			Run: func(ctx context.Context) (int, error) {
+				panic("boom")
+			},
+		})
+		require.NoError(t, err)
+		w.Start(freq)
+		defer stop(w)
+		eventually(t, func() bool {
+			_, err := s.Application.JobRepository().Load(ctx, name)
+			return err == nil
+		})
+		// when
+		err = s.Application.JobRepository().RequestTrigger(ctx, name, "tester")
+		require.NoError(t, err)
+		// then
+		eventually(t, func() bool {
+			runs := runsOf(t, name)
+			return len(runs) == 1 && runs[0].Outcome != repository.JobRunOutcomeRunning
+		})
+		runs := runsOf(t, name)
+		assert.Equal(t, repository.JobRunOutcomeFailure, runs[0].Outcome)
+		require.NotNil(t, runs[0].Error)
+		assert.Contains(t, *runs[0].Error, "boom")
+	})
+
+	s.T().Run("interrupted run is failed when the lock is acquired", func(t *testing.T) {
+		// given a run left `running` by a pod which crashed
+		name := fmt.Sprintf("test-job-%s", uuid.NewV4())
+		interrupted := &repository.JobRun{
+			JobName:   name,
+			Owner:     "crashed-pod",
+			Trigger:   repository.JobRunTriggerSchedule,
+			StartedAt: time.Now().Add(-time.Hour),
+			Outcome:   repository.JobRunOutcomeRunning,
+		}
+		err := s.Application.JobRunRepository().Create(ctx, interrupted)
+		require.NoError(t, err)
+		w, err := worker.NewJobWorker(ctx, s.Application, worker.Job{
+			Name:     name,
+			Schedule: "@yearly",
+			Run: func(ctx context.Context) (int, error) {
+				return 0, nil
+			},
+		})
+		require.NoError(t, err)
+		// when
+		w.Start(freq)
+		defer stop(w)
+		// then
+		eventually(t, func() bool {
+			run, err := s.Application.JobRunRepository().Load(ctx, interrupted.JobRunID)
+			require.NoError(t, err)
+			return run.Outcome != repository.JobRunOutcomeRunning
+		})
+		run, err := s.Application.JobRunRepository().Load(ctx, interrupted.JobRunID)
+		require.NoError(t, err)
+		assert.Equal(t, repository.JobRunOutcomeFailure, run.Outcome)
+		assert.NotNil(t, run.EndedAt)
+		require.NotNil(t, run.Error)
+		assert.Contains(t, *run.Error, "interrupted")
+	})
+
+	s.T().Run("invalid schedule", func(t *testing.T) {
+		_, err := worker.NewJobWorker(ctx, s.Application, worker.Job{
+			Name:     "test-job",
+			Schedule: "every minute",
+		})
+		assert.Error(t, err)
+	})
+}
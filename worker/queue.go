@@ -0,0 +1,314 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/metric"
+	"github.com/fabric8-services/fabric8-auth/worker/repository"
+
+	errs "github.com/pkg/errors"
+)
+
+const (
+	// QueueTaskCompleted the task was processed successfully
+	QueueTaskCompleted = "completed"
+	// QueueTaskRetried the task failed and will be retried
+	QueueTaskRetried = "retried"
+	// QueueTaskDeadLettered the task failed too many times and was dead-lettered
+	QueueTaskDeadLettered = "dead_lettered"
+)
+
+// QueueHandler processes a task of a work queue. A task which was dequeued may be processed more than once (for
+// example, if the pod crashed before it was completed), so the handler must be idempotent.
+type QueueHandler func(ctx context.Context, task repository.QueueTask) error
+
+// QueueConsumerConfig the configuration of a queue consumer
+type QueueConsumerConfig struct {
+	// Concurrency the number of tasks processed in parallel by the consumer
+	Concurrency int
+	// BatchSize the maximum number of tasks dequeued at once by each of the parallel consumers
+	BatchSize int
+	// VisibilityTimeout the duration during which a dequeued task is hidden from the other consumers. It must be
+	// longer than the time needed to process a batch of tasks
+	VisibilityTimeout time.Duration
+	// RetryDelay the delay before the first retry of a task which failed. The delay doubles after each failed attempt
+	RetryDelay time.Duration
+	// MinInterval the minimum delay between the start of 2 tasks in this pod, shared by all the parallel consumers,
+	// to limit the load on the services called by the handler. Tasks are not throttled by default
+	MinInterval time.Duration
+}
+
+// DefaultQueueConsumerConfig the default configuration of a queue consumer
+var DefaultQueueConsumerConfig = QueueConsumerConfig{
+	Concurrency:       4,
+	BatchSize:         10,
+	VisibilityTimeout: 5 * time.Minute,
+	RetryDelay:        30 * time.Second,
+}
+
+// NewQueueConsumer returns a worker which processes the tasks of the given queue with the given handler.
+// Unlike the `BaseWorker`, the consumer does not hold any lock: all the pods consume the queue concurrently, and each
+// of them runs multiple consumers in parallel. When the queue is empty, the consumers poll it at the frequency given
+// when the worker is started.
+func NewQueueConsumer(ctx context.Context, app application.Application, queue string, handler QueueHandler, config QueueConsumerConfig) Worker {
+	return newQueueConsumer(ctx, app.QueueTaskRepository(), queue, handler, config)
+}
+
+func newQueueConsumer(ctx context.Context, tasks repository.QueueTaskRepository, queue string, handler QueueHandler, config QueueConsumerConfig) *queueConsumer {
+	if config.Concurrency <= 0 {
+		config.Concurrency = DefaultQueueConsumerConfig.Concurrency
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultQueueConsumerConfig.BatchSize
+	}
+	if config.VisibilityTimeout <= 0 {
+		config.VisibilityTimeout = DefaultQueueConsumerConfig.VisibilityTimeout
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = DefaultQueueConsumerConfig.RetryDelay
+	}
+	return &queueConsumer{
+		ctx:     ctx,
+		tasks:   tasks,
+		queue:   queue,
+		owner:   GetLockOwner(ctx),
+		handler: handler,
+		config:  config,
+	}
+}
+
+// ProcessQueue processes the visible tasks of the given queue with the given handler in the current goroutine, until
+// none is left or the queue cannot be read, and returns the number of tasks that were dequeued. The tasks are
+// throttled, completed, retried and dead-lettered in the same way as by the consumers, which makes it possible to
+// drain a queue without waiting for them.
+func ProcessQueue(ctx context.Context, tasks repository.QueueTaskRepository, queue string, handler QueueHandler, config QueueConsumerConfig) (int, error) {
+	c := newQueueConsumer(ctx, tasks, queue, handler, config)
+	var throttle *time.Ticker
+	if c.config.MinInterval > 0 {
+		throttle = time.NewTicker(c.config.MinInterval)
+		defer throttle.Stop()
+	}
+	count := 0
+	for {
+		dequeued, err := c.processBatch(c.owner, nil, throttle)
+		if err != nil || dequeued == 0 {
+			return count, err
+		}
+		count += dequeued
+	}
+}
+
+type queueConsumer struct {
+	ctx     context.Context
+	tasks   repository.QueueTaskRepository
+	queue   string
+	owner   string
+	handler QueueHandler
+	config  QueueConsumerConfig
+
+	mux     sync.Mutex
+	running bool
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+	status  Status // status of the last batch of tasks
+}
+
+// verify that `queueConsumer` is an implementation of `Worker`
+var _ Worker = &queueConsumer{}
+
+// Start starts the parallel consumers, which poll the queue at the given frequency when it is empty
+func (c *queueConsumer) Start(freq time.Duration) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	log.Info(c.ctx, map[string]interface{}{
+		"owner":       c.owner,
+		"queue":       c.queue,
+		"frequency":   freq,
+		"concurrency": c.config.Concurrency,
+	}, "starting queue consumer")
+	c.stopCh = make(chan struct{})
+	c.running = true
+	var throttle *time.Ticker
+	if c.config.MinInterval > 0 {
+		throttle = time.NewTicker(c.config.MinInterval)
+	}
+	for i := 0; i < c.config.Concurrency; i++ {
+		c.wg.Add(1)
+		go c.consume(fmt.Sprintf("%s-%d", c.owner, i), freq, c.stopCh, throttle)
+	}
+	if throttle != nil {
+		go func(stopCh chan struct{}) {
+			<-stopCh
+			c.wg.Wait()
+			throttle.Stop()
+		}(c.stopCh)
+	}
+}
+
+// Stop stops the consumers once they processed their current batch of tasks
+func (c *queueConsumer) Stop() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.stopCh == nil {
+		return
+	}
+	log.Debug(c.ctx, map[string]interface{}{
+		"owner": c.owner,
+		"queue": c.queue,
+	}, "time to stop the queue consumer")
+	close(c.stopCh)
+	c.stopCh = nil
+	go func() {
+		c.wg.Wait()
+		c.mux.Lock()
+		defer c.mux.Unlock()
+		c.running = false
+		log.Info(c.ctx, map[string]interface{}{
+			"owner": c.owner,
+			"queue": c.queue,
+		}, "queue consumer stopped")
+	}()
+}
+
+// IsStopped return true if none of the consumers is running anymore, false otherwise.
+func (c *queueConsumer) IsStopped() bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return !c.running
+}
+
+// Status returns the status of the consumer in the current pod. The consumer does not hold any lock, and its last
+// cycle is the last batch of tasks that was dequeued by any of the parallel consumers.
+func (c *queueConsumer) Status() Status {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	status := c.status
+	status.Name = c.queue
+	status.Owner = c.owner
+	return status
+}
+
+// consume processes the tasks of the queue until it is empty, then waits for the next tick
+func (c *queueConsumer) consume(consumer string, freq time.Duration, stopCh chan struct{}, throttle *time.Ticker) {
+	defer c.wg.Done()
+	ticker := time.NewTicker(freq)
+	defer ticker.Stop()
+	for {
+		for {
+			if count, _ := c.processBatch(consumer, stopCh, throttle); count == 0 {
+				break
+			}
+			select {
+			case <-stopCh:
+				return
+			default:
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// processBatch dequeues and processes a batch of tasks, and returns the number of tasks that were dequeued
+func (c *queueConsumer) processBatch(consumer string, stopCh chan struct{}, throttle *time.Ticker) (int, error) {
+	start := time.Now()
+	tasks, err := c.tasks.Dequeue(c.ctx, c.queue, consumer, c.config.VisibilityTimeout, c.config.BatchSize)
+	if err != nil {
+		// We will just log the error and try again at the next tick
+		log.Error(c.ctx, map[string]interface{}{
+			"err":      err,
+			"queue":    c.queue,
+			"consumer": consumer,
+		}, "unable to dequeue tasks")
+		c.recordBatch(start, err)
+		return 0, err
+	}
+	for _, task := range tasks {
+		if throttle != nil {
+			select {
+			case <-throttle.C:
+			case <-stopCh:
+				// the remaining tasks of the batch will be dequeued again at the end of their visibility timeout
+				c.recordBatch(start, nil)
+				return len(tasks), nil
+			}
+		}
+		c.process(task)
+	}
+	if len(tasks) > 0 {
+		c.recordBatch(start, nil)
+	}
+	return len(tasks), nil
+}
+
+// recordBatch records the outcome of a batch in the status of the consumer
+func (c *queueConsumer) recordBatch(start time.Time, err error) {
+	end := time.Now()
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.status.LastCycleStartedAt = &start
+	c.status.LastCycleEndedAt = &end
+	c.status.LastCycleError = nil
+	if err != nil {
+		msg := err.Error()
+		c.status.LastCycleError = &msg
+	}
+}
+
+// process processes a single task, then completes, retries or dead-letters it
+func (c *queueConsumer) process(task repository.QueueTask) {
+	handleErr := c.handle(task)
+	var outcome string
+	var err error
+	if handleErr == nil {
+		outcome = QueueTaskCompleted
+		err = c.tasks.Complete(c.ctx, task)
+	} else if task.Attempts >= task.MaxAttempts {
+		log.Error(c.ctx, map[string]interface{}{
+			"queue_task_id": task.QueueTaskID,
+			"queue":         c.queue,
+			"attempts":      task.Attempts,
+			"err":           handleErr,
+		}, "giving up on processing the queue task")
+		outcome = QueueTaskDeadLettered
+		err = c.tasks.DeadLetter(c.ctx, task, handleErr)
+	} else {
+		log.Warn(c.ctx, map[string]interface{}{
+			"queue_task_id": task.QueueTaskID,
+			"queue":         c.queue,
+			"attempts":      task.Attempts,
+			"err":           handleErr,
+		}, "unable to process the queue task, will retry later")
+		outcome = QueueTaskRetried
+		err = c.tasks.Retry(c.ctx, task, handleErr, time.Now().Add(RetryDelay(c.config.RetryDelay, task.Attempts)))
+	}
+	metric.RecordQueueTask(c.queue, outcome)
+	if err != nil {
+		// the visibility timeout expired and another consumer dequeued the task in the meantime, or the database
+		// is not reachable: either way, the task will be processed again.
+		log.Error(c.ctx, map[string]interface{}{
+			"err":           err,
+			"queue_task_id": task.QueueTaskID,
+			"queue":         c.queue,
+			"outcome":       outcome,
+		}, "unable to record the outcome of the queue task")
+	}
+}
+
+// handle runs the handler, and turns a panic into an error so that the task is retried
+func (c *queueConsumer) handle(task repository.QueueTask) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errs.Errorf("queue task handler panicked: %v", r)
+		}
+	}()
+	return c.handler(c.ctx, task)
+}
@@ -0,0 +1,324 @@
+package worker_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/account"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/worker"
+	"github.com/fabric8-services/fabric8-auth/worker/repository"
+
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *WorkerTestSuite) TestQueueTaskRepository() {
+
+	ctx := context.Background()
+	repo := s.Application.QueueTaskRepository()
+
+	enqueue := func(t *testing.T, queue string, priority int) repository.QueueTask {
+		task := repository.QueueTask{
+			Queue:    queue,
+			Priority: priority,
+			Payload:  account.ContextInformation{"priority": priority},
+		}
+		err := repo.Enqueue(ctx, &task)
+		require.NoError(t, err)
+		return task
+	}
+
+	s.T().Run("dequeue by priority", func(t *testing.T) {
+		// given
+		queue := fmt.Sprintf("test-queue-%s", uuid.NewV4())
+		low := enqueue(t, queue, 0)
+		high := enqueue(t, queue, 10)
+		// when
+		tasks, err := repo.Dequeue(ctx, queue, "consumer-1", time.Minute, 1)
+		// then
+		require.NoError(t, err)
+		require.Len(t, tasks, 1)
+		assert.Equal(t, high.QueueTaskID, tasks[0].QueueTaskID)
+		assert.Equal(t, 1, tasks[0].Attempts)
+		assert.Equal(t, repository.DefaultQueueTaskMaxAttempts, tasks[0].MaxAttempts)
+		assert.Equal(t, float64(10), tasks[0].Payload["priority"])
+		require.NotNil(t, tasks[0].LockedBy)
+		assert.Equal(t, "consumer-1", *tasks[0].LockedBy)
+		// and the next task is the one with the lowest priority
+		tasks, err = repo.Dequeue(ctx, queue, "consumer-1", time.Minute, 10)
+		require.NoError(t, err)
+		require.Len(t, tasks, 1)
+		assert.Equal(t, low.QueueTaskID, tasks[0].QueueTaskID)
+	})
+
+	s.T().Run("concurrent consumers get different tasks", func(t *testing.T) {
+		// given
+		queue := fmt.Sprintf("test-queue-%s", uuid.NewV4())
+		for i := 0; i < 20; i++ {
+			enqueue(t, queue, 0)
+		}
+		// when
+		var mux sync.Mutex
+		dequeued := map[uuid.UUID]int{}
+		wg := sync.WaitGroup{}
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				tasks, err := repo.Dequeue(ctx, queue, fmt.Sprintf("consumer-%d", i), time.Minute, 4)
+				require.NoError(t, err)
+				mux.Lock()
+				defer mux.Unlock()
+				for _, task := range tasks {
+					dequeued[task.QueueTaskID]++
+				}
+			}(i)
+		}
+		wg.Wait()
+		// then
+		assert.Len(t, dequeued, 20)
+		for _, count := range dequeued {
+			assert.Equal(t, 1, count)
+		}
+	})
+
+	s.T().Run("visibility timeout", func(t *testing.T) {
+		// given
+		queue := fmt.Sprintf("test-queue-%s", uuid.NewV4())
+		enqueue(t, queue, 0)
+		tasks, err := repo.Dequeue(ctx, queue, "consumer-1", time.Second, 1)
+		require.NoError(t, err)
+		require.Len(t, tasks, 1)
+		first := tasks[0]
+		// when the task is hidden
+		tasks, err = repo.Dequeue(ctx, queue, "consumer-2", time.Minute, 1)
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, tasks)
+		// when the visibility timeout expired
+		time.Sleep(1100 * time.Millisecond)
+		tasks, err = repo.Dequeue(ctx, queue, "consumer-2", time.Minute, 1)
+		// then
+		require.NoError(t, err)
+		require.Len(t, tasks, 1)
+		assert.Equal(t, 2, tasks[0].Attempts)
+		// and the first consumer cannot complete the task anymore
+		err = repo.Complete(ctx, first)
+		require.Error(t, err)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+		err = repo.Complete(ctx, tasks[0])
+		require.NoError(t, err)
+		_, err = repo.Load(ctx, first.QueueTaskID)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+
+	s.T().Run("retry", func(t *testing.T) {
+		// given
+		queue := fmt.Sprintf("test-queue-%s", uuid.NewV4())
+		enqueue(t, queue, 0)
+		tasks, err := repo.Dequeue(ctx, queue, "consumer-1", time.Minute, 1)
+		require.NoError(t, err)
+		require.Len(t, tasks, 1)
+		// when
+		err = repo.Retry(ctx, tasks[0], errs.New("oops"), time.Now())
+		// then
+		require.NoError(t, err)
+		retried, err := repo.Dequeue(ctx, queue, "consumer-1", time.Minute, 1)
+		require.NoError(t, err)
+		require.Len(t, retried, 1)
+		assert.Equal(t, 2, retried[0].Attempts)
+		require.NotNil(t, retried[0].LastError)
+		assert.Equal(t, "oops", *retried[0].LastError)
+	})
+
+	s.T().Run("dead letter and redrive", func(t *testing.T) {
+		// given
+		queue := fmt.Sprintf("test-queue-%s", uuid.NewV4())
+		task := enqueue(t, queue, 0)
+		tasks, err := repo.Dequeue(ctx, queue, "consumer-1", time.Minute, 1)
+		require.NoError(t, err)
+		require.Len(t, tasks, 1)
+		// when
+		err = repo.DeadLetter(ctx, tasks[0], errs.New("oops"))
+		// then
+		require.NoError(t, err)
+		count, err := repo.CountPending(ctx, queue)
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+		deadLettered, err := repo.ListDeadLettered(ctx, queue, 10)
+		require.NoError(t, err)
+		require.Len(t, deadLettered, 1)
+		assert.Equal(t, task.QueueTaskID, deadLettered[0].QueueTaskID)
+		// when
+		err = repo.Redrive(ctx, task.QueueTaskID)
+		// then
+		require.NoError(t, err)
+		tasks, err = repo.Dequeue(ctx, queue, "consumer-1", time.Minute, 1)
+		require.NoError(t, err)
+		require.Len(t, tasks, 1)
+		assert.Equal(t, 1, tasks[0].Attempts)
+		// and a pending task cannot be redriven
+		err = repo.Redrive(ctx, task.QueueTaskID)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+
+	s.T().Run("exhausted task is dead-lettered instead of being dequeued", func(t *testing.T) {
+		// given a task whose consumer crashed during its last attempt
+		queue := fmt.Sprintf("test-queue-%s", uuid.NewV4())
+		task := repository.QueueTask{
+			Queue:       queue,
+			MaxAttempts: 1,
+		}
+		err := repo.Enqueue(ctx, &task)
+		require.NoError(t, err)
+		tasks, err := repo.Dequeue(ctx, queue, "consumer-1", time.Second, 1)
+		require.NoError(t, err)
+		require.Len(t, tasks, 1)
+		time.Sleep(1100 * time.Millisecond)
+		// when
+		tasks, err = repo.Dequeue(ctx, queue, "consumer-2", time.Minute, 1)
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, tasks)
+		deadLettered, err := repo.ListDeadLettered(ctx, queue, 10)
+		require.NoError(t, err)
+		require.Len(t, deadLettered, 1)
+		assert.Equal(t, task.QueueTaskID, deadLettered[0].QueueTaskID)
+		assert.Equal(t, 1, deadLettered[0].Attempts)
+		assert.Nil(t, deadLettered[0].LockedBy)
+		require.NotNil(t, deadLettered[0].LastError)
+	})
+
+	s.T().Run("deduplication", func(t *testing.T) {
+		// given
+		queue := fmt.Sprintf("test-queue-%s", uuid.NewV4())
+		key := "same-key"
+		for i := 0; i < 2; i++ {
+			err := repo.Enqueue(ctx, &repository.QueueTask{
+				Queue:            queue,
+				DeduplicationKey: &key,
+			})
+			require.NoError(t, err)
+		}
+		// when
+		count, err := repo.CountPending(ctx, queue)
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		// and the same key can be used in another queue
+		err = repo.Enqueue(ctx, &repository.QueueTask{
+			Queue:            fmt.Sprintf("test-queue-%s", uuid.NewV4()),
+			DeduplicationKey: &key,
+		})
+		require.NoError(t, err)
+	})
+}
+
+func (s *WorkerTestSuite) TestQueueConsumer() {
+
+	ctx := context.Background()
+	freq := time.Millisecond * 50
+	config := worker.QueueConsumerConfig{
+		Concurrency:       3,
+		BatchSize:         2,
+		VisibilityTimeout: time.Minute,
+		RetryDelay:        time.Millisecond,
+	}
+
+	// eventually waits until the given condition is met, or fails after a few seconds
+	eventually := func(t *testing.T, condition func() bool) {
+		for i := 0; i < 100; i++ {
+			if condition() {
+				return
+			}
+			time.Sleep(freq)
+		}
+		require.FailNow(t, "condition not met in time")
+	}
+
+	s.T().Run("process all tasks", func(t *testing.T) {
+		// given
+		queue := fmt.Sprintf("test-queue-%s", uuid.NewV4())
+		for i := 0; i < 10; i++ {
+			err := s.Application.QueueTaskRepository().Enqueue(ctx, &repository.QueueTask{Queue: queue})
+			require.NoError(t, err)
+		}
+		var count int32
+		c := worker.NewQueueConsumer(ctx, s.Application, queue, func(ctx context.Context, task repository.QueueTask) error {
+			atomic.AddInt32(&count, 1)
+			return nil
+		}, config)
+		// when
+		c.Start(freq)
+		defer stop(c)
+		// then
+		eventually(t, func() bool {
+			pending, err := s.Application.QueueTaskRepository().CountPending(ctx, queue)
+			require.NoError(t, err)
+			return pending == 0
+		})
+		assert.Equal(t, int32(10), atomic.LoadInt32(&count))
+	})
+
+	s.T().Run("retry then dead letter", func(t *testing.T) {
+		// given
+		queue := fmt.Sprintf("test-queue-%s", uuid.NewV4())
+		err := s.Application.QueueTaskRepository().Enqueue(ctx, &repository.QueueTask{Queue: queue, MaxAttempts: 3})
+		require.NoError(t, err)
+		var count int32
+		c := worker.NewQueueConsumer(ctx, s.Application, queue, func(ctx context.Context, task repository.QueueTask) error {
+			if atomic.AddInt32(&count, 1) == 1 {
+				panic("boom")
+			}
+			return errs.New("oops")
+		}, config)
+		// when
+		c.Start(freq)
+		defer stop(c)
+		// then
+		eventually(t, func() bool {
+			deadLettered, err := s.Application.QueueTaskRepository().ListDeadLettered(ctx, queue, 10)
+			require.NoError(t, err)
+			return len(deadLettered) == 1
+		})
+		assert.Equal(t, int32(3), atomic.LoadInt32(&count))
+		deadLettered, err := s.Application.QueueTaskRepository().ListDeadLettered(ctx, queue, 10)
+		require.NoError(t, err)
+		require.NotNil(t, deadLettered[0].LastError)
+		assert.Equal(t, "oops", *deadLettered[0].LastError)
+	})
+
+	s.T().Run("process queue synchronously", func(t *testing.T) {
+		// given
+		queue := fmt.Sprintf("test-queue-%s", uuid.NewV4())
+		for i := 0; i < 5; i++ {
+			err := s.Application.QueueTaskRepository().Enqueue(ctx, &repository.QueueTask{Queue: queue, Priority: i})
+			require.NoError(t, err)
+		}
+		var priorities []int
+		syncConfig := config
+		syncConfig.RetryDelay = time.Minute
+		// when
+		count, err := worker.ProcessQueue(ctx, s.Application.QueueTaskRepository(), queue, func(ctx context.Context, task repository.QueueTask) error {
+			priorities = append(priorities, task.Priority)
+			if task.Priority == 0 {
+				return errs.New("oops")
+			}
+			return nil
+		}, syncConfig)
+		// then all the tasks were dequeued once, and the failed one is retried later
+		require.NoError(t, err)
+		assert.Equal(t, 5, count)
+		assert.Equal(t, []int{4, 3, 2, 1, 0}, priorities)
+		pending, err := s.Application.QueueTaskRepository().CountPending(ctx, queue)
+		require.NoError(t, err)
+		assert.Equal(t, 1, pending)
+	})
+}
@@ -0,0 +1,92 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/log"
+)
+
+// Registry the workers started in the current pod, to report their status and to stop them during the shutdown
+type Registry struct {
+	app     application.Application
+	mux     sync.Mutex
+	workers []Worker
+}
+
+// NewRegistry returns a new, empty registry
+func NewRegistry(app application.Application) *Registry {
+	return &Registry{
+		app: app,
+	}
+}
+
+// Add adds the given worker to the registry
+func (r *Registry) Add(w Worker) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.workers = append(r.workers, w)
+}
+
+// Workers returns the workers in the registry
+func (r *Registry) Workers() []Worker {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return append([]Worker{}, r.workers...)
+}
+
+// Status returns the status of the workers in the registry, along with the current owner of their lock in any pod and
+// the age of the last heartbeat of this owner
+func (r *Registry) Status(ctx context.Context) ([]Status, error) {
+	locks, err := r.app.WorkerLockRepository().ListLockStatuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	workers := r.Workers()
+	statuses := make([]Status, len(workers))
+	for i, w := range workers {
+		statuses[i] = w.Status()
+		for _, l := range locks {
+			if l.Name == statuses[i].Name {
+				owner := l.Owner
+				statuses[i].LockOwner = &owner
+				statuses[i].HeartbeatAge = l.HeartbeatAge()
+			}
+		}
+	}
+	return statuses, nil
+}
+
+// Drain stops all the workers in the registry, and waits until they completed their current cycle and released their
+// lock, so that another pod can take over. Returns false if some workers were still running after the given timeout.
+func (r *Registry) Drain(timeout time.Duration) bool {
+	workers := r.Workers()
+	for _, w := range workers {
+		w.Stop()
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		running := []string{}
+		for _, w := range workers {
+			if !w.IsStopped() {
+				running = append(running, w.Status().Name)
+			}
+		}
+		if len(running) == 0 {
+			log.Info(nil, map[string]interface{}{
+				"workers": len(workers),
+			}, "all workers stopped")
+			return true
+		}
+		if time.Now().After(deadline) {
+			log.Warn(nil, map[string]interface{}{
+				"running": running,
+				"timeout": timeout,
+			}, "some workers did not stop in time")
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+)
+
+// Job the state of a background job, shared by all the pods
+type Job struct {
+	gormsupport.LifecycleHardDelete
+	// Name the name of the job. This is the primary key value.
+	Name string `gorm:"primary_key;column:name"`
+	// the schedule of the job, as a cron expression
+	Schedule string
+	// true if the scheduled runs of the job are suspended
+	Paused bool
+	// the time at which a run of the job was requested out of its schedule, or nil if no run was requested
+	TriggerRequestedAt *time.Time
+	// the name of the user or service account who requested the run
+	TriggerRequestedBy *string
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m Job) TableName() string {
+	return "job"
+}
+
+// GormJobRepository is the implementation of the storage interface for Job.
+type GormJobRepository struct {
+	db *gorm.DB
+}
+
+// NewJobRepository creates a new storage type.
+func NewJobRepository(db *gorm.DB) JobRepository {
+	return &GormJobRepository{db: db}
+}
+
+// JobRepository represents the storage interface.
+type JobRepository interface {
+	Load(ctx context.Context, name string) (*Job, error)
+	List(ctx context.Context) ([]Job, error)
+	Register(ctx context.Context, name, schedule string) (*Job, error)
+	SetPaused(ctx context.Context, name string, paused bool) error
+	RequestTrigger(ctx context.Context, name, requestedBy string) error
+	ClearTrigger(ctx context.Context, name string, requestedAt time.Time) error
+}
+
+// Load returns a single job as a Database Model
+func (m *GormJobRepository) Load(ctx context.Context, name string) (*Job, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "job", "load"}, time.Now())
+	var native Job
+	err := m.db.Where("name = ?", name).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundErrorWithKey("job", "name", name)
+	}
+	return &native, errs.WithStack(err)
+}
+
+// List returns all the jobs, ordered by name
+func (m *GormJobRepository) List(ctx context.Context) ([]Job, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "job", "list"}, time.Now())
+	var jobs []Job
+	err := m.db.Order("name").Find(&jobs).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Error(ctx, map[string]interface{}{
+			"err": err,
+		}, "unable to list the jobs")
+		return nil, errs.WithStack(err)
+	}
+	return jobs, nil
+}
+
+// Register creates the job with the given name if it does not exist yet, or updates its schedule otherwise,
+// and returns the job. The pause and trigger states of an existing job are preserved.
+func (m *GormJobRepository) Register(ctx context.Context, name, schedule string) (*Job, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "job", "register"}, time.Now())
+	now := time.Now()
+	err := m.db.Exec(`INSERT INTO job (name, schedule, paused, created_at, updated_at) VALUES (?, ?, false, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET schedule = EXCLUDED.schedule, updated_at = EXCLUDED.updated_at`,
+		name, schedule, now, now).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"job": name,
+			"err": err,
+		}, "unable to register the job")
+		return nil, errs.WithStack(err)
+	}
+	return m.Load(ctx, name)
+}
+
+// SetPaused suspends or resumes the scheduled runs of the job with the given name
+func (m *GormJobRepository) SetPaused(ctx context.Context, name string, paused bool) error {
+	defer goa.MeasureSince([]string{"goa", "db", "job", "set_paused"}, time.Now())
+	return m.update(ctx, name, map[string]interface{}{"paused": paused})
+}
+
+// RequestTrigger requests a run of the job with the given name, out of its schedule
+func (m *GormJobRepository) RequestTrigger(ctx context.Context, name, requestedBy string) error {
+	defer goa.MeasureSince([]string{"goa", "db", "job", "request_trigger"}, time.Now())
+	return m.update(ctx, name, map[string]interface{}{
+		"trigger_requested_at": time.Now(),
+		"trigger_requested_by": requestedBy,
+	})
+}
+
+// ClearTrigger clears the request of a run of the job with the given name, unless another run was requested after the
+// given time
+func (m *GormJobRepository) ClearTrigger(ctx context.Context, name string, requestedAt time.Time) error {
+	defer goa.MeasureSince([]string{"goa", "db", "job", "clear_trigger"}, time.Now())
+	err := m.db.Model(&Job{}).Where("name = ? AND trigger_requested_at <= ?", name, requestedAt).
+		Updates(map[string]interface{}{"trigger_requested_at": nil, "trigger_requested_by": nil}).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"job": name,
+			"err": err,
+		}, "unable to clear the trigger of the job")
+		return errs.WithStack(err)
+	}
+	return nil
+}
+
+func (m *GormJobRepository) update(ctx context.Context, name string, values map[string]interface{}) error {
+	result := m.db.Model(&Job{}).Where("name = ?", name).Updates(values)
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"job": name,
+			"err": result.Error,
+		}, "unable to update the job")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundErrorWithKey("job", "name", name)
+	}
+	return nil
+}
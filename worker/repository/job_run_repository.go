@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	// JobRunOutcomeRunning the run is in progress (or the pod running it died)
+	JobRunOutcomeRunning = "running"
+	// JobRunOutcomeSuccess the run completed successfully
+	JobRunOutcomeSuccess = "success"
+	// JobRunOutcomeFailure the run failed
+	JobRunOutcomeFailure = "failure"
+
+	// JobRunTriggerSchedule the run was started according to the schedule of the job
+	JobRunTriggerSchedule = "schedule"
+	// JobRunTriggerManual the run was requested through the admin API
+	JobRunTriggerManual = "manual"
+)
+
+// JobRun the record of a run of a background job
+type JobRun struct {
+	gormsupport.LifecycleHardDelete
+	// JobRunID the ID of the run. This is the primary key value.
+	JobRunID uuid.UUID `sql:"type:uuid" gorm:"primary_key;column:job_run_id"`
+	// the name of the job
+	JobName string
+	// the owner of the worker lock (eg, the name of the pod) which performed the run
+	Owner string
+	// what started the run: `schedule` or `manual`
+	Trigger string
+	// the time at which the run started
+	StartedAt time.Time
+	// the time at which the run ended, or nil if it is still in progress
+	EndedAt *time.Time
+	// the outcome of the run: `running`, `success` or `failure`
+	Outcome string
+	// the number of items (users, events, etc.) processed during the run
+	ItemsProcessed int
+	// the error which made the run fail
+	Error *string
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m JobRun) TableName() string {
+	return "job_run"
+}
+
+// Duration returns the duration of the run, or 0 if it is still in progress
+func (m JobRun) Duration() time.Duration {
+	if m.EndedAt == nil {
+		return 0
+	}
+	return m.EndedAt.Sub(m.StartedAt)
+}
+
+// GormJobRunRepository is the implementation of the storage interface for JobRun.
+type GormJobRunRepository struct {
+	db *gorm.DB
+}
+
+// NewJobRunRepository creates a new storage type.
+func NewJobRunRepository(db *gorm.DB) JobRunRepository {
+	return &GormJobRunRepository{db: db}
+}
+
+// JobRunRepository represents the storage interface.
+type JobRunRepository interface {
+	Load(ctx context.Context, id uuid.UUID) (*JobRun, error)
+	LoadLatest(ctx context.Context, jobName string) (*JobRun, error)
+	Create(ctx context.Context, run *JobRun) error
+	Save(ctx context.Context, run *JobRun) error
+	ListForJob(ctx context.Context, jobName string, limit int) ([]JobRun, error)
+	DeleteBefore(ctx context.Context, jobName string, before time.Time) (int, error)
+	FailRunning(ctx context.Context, jobName string, msg string, endedAt time.Time) (int, error)
+}
+
+// Load returns a single run as a Database Model
+func (m *GormJobRunRepository) Load(ctx context.Context, id uuid.UUID) (*JobRun, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "job_run", "load"}, time.Now())
+	var native JobRun
+	err := m.db.Where("job_run_id = ?", id).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("job_run", id.String())
+	}
+	return &native, errs.WithStack(err)
+}
+
+// LoadLatest returns the most recent run of the job with the given name
+func (m *GormJobRunRepository) LoadLatest(ctx context.Context, jobName string) (*JobRun, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "job_run", "load_latest"}, time.Now())
+	var native JobRun
+	err := m.db.Where("job_name = ?", jobName).Order("started_at DESC").Limit(1).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundErrorWithKey("job_run", "job_name", jobName)
+	}
+	return &native, errs.WithStack(err)
+}
+
+// Create creates a new record.
+func (m *GormJobRunRepository) Create(ctx context.Context, run *JobRun) error {
+	defer goa.MeasureSince([]string{"goa", "db", "job_run", "create"}, time.Now())
+	if run.JobRunID == uuid.Nil {
+		run.JobRunID = uuid.NewV4()
+	}
+	err := m.db.Create(run).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"job_run_id": run.JobRunID,
+			"job":        run.JobName,
+			"err":        err,
+		}, "unable to create the job run")
+		return errs.WithStack(err)
+	}
+	return nil
+}
+
+// Save modifies a single record.
+func (m *GormJobRunRepository) Save(ctx context.Context, run *JobRun) error {
+	defer goa.MeasureSince([]string{"goa", "db", "job_run", "save"}, time.Now())
+	err := m.db.Save(run).Error
+	if err != nil {
+		log.Error(ctx, map[string]interface{}{
+			"job_run_id": run.JobRunID,
+			"job":        run.JobName,
+			"err":        err,
+		}, "unable to update the job run")
+		return errs.WithStack(err)
+	}
+	return nil
+}
+
+// ListForJob returns the most recent runs of the job with the given name, the most recent ones first. The number of
+// results is capped by the given limit.
+func (m *GormJobRunRepository) ListForJob(ctx context.Context, jobName string, limit int) ([]JobRun, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "job_run", "list_for_job"}, time.Now())
+	var runs []JobRun
+	err := m.db.Where("job_name = ?", jobName).Order("started_at DESC").Limit(limit).Find(&runs).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Error(ctx, map[string]interface{}{
+			"job": jobName,
+			"err": err,
+		}, "unable to list the job runs")
+		return nil, errs.WithStack(err)
+	}
+	return runs, nil
+}
+
+// DeleteBefore removes the runs of the job with the given name which started before the given time, and returns the
+// number of deleted records. This is a hard delete!
+func (m *GormJobRunRepository) DeleteBefore(ctx context.Context, jobName string, before time.Time) (int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "job_run", "delete_before"}, time.Now())
+	result := m.db.Where("job_name = ? AND started_at < ?", jobName, before).Delete(&JobRun{})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"job": jobName,
+			"err": result.Error,
+		}, "unable to delete the old job runs")
+		return 0, errs.WithStack(result.Error)
+	}
+	return int(result.RowsAffected), nil
+}
+
+// FailRunning marks the runs of the job with the given name which are still `running` as failed with the given error
+// message, and returns the number of updated records. This is meant to be called by the owner of the worker lock,
+// when no other run can be in progress: such runs were interrupted (eg, because the pod running them crashed).
+func (m *GormJobRunRepository) FailRunning(ctx context.Context, jobName string, msg string, endedAt time.Time) (int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "job_run", "fail_running"}, time.Now())
+	result := m.db.Model(&JobRun{}).Where("job_name = ? AND outcome = ?", jobName, JobRunOutcomeRunning).Updates(map[string]interface{}{
+		"outcome":  JobRunOutcomeFailure,
+		"error":    msg,
+		"ended_at": endedAt,
+	})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"job": jobName,
+			"err": result.Error,
+		}, "unable to fail the interrupted job runs")
+		return 0, errs.WithStack(result.Error)
+	}
+	return int(result.RowsAffected), nil
+}
@@ -0,0 +1,305 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/authentication/account"
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/gormsupport"
+	"github.com/fabric8-services/fabric8-auth/log"
+
+	"github.com/goadesign/goa"
+	"github.com/jinzhu/gorm"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+)
+
+// DefaultQueueTaskMaxAttempts the maximum number of attempts to process a task, unless specified otherwise when the
+// task is enqueued
+const DefaultQueueTaskMaxAttempts = 10
+
+// QueueTask a task in a work queue. Unlike the jobs, which run in a single pod at a time, the tasks of a queue are
+// consumed concurrently by all the pods: a task is locked by its consumer when it is dequeued, and becomes visible
+// again to the other consumers if it was not completed before the end of its visibility timeout.
+// A task which failed is retried until the maximum number of attempts is reached, in which case it is dead-lettered:
+// it is kept for inspection but not consumed anymore.
+type QueueTask struct {
+	gormsupport.LifecycleHardDelete
+	// QueueTaskID the ID of the task. This is the primary key value.
+	QueueTaskID uuid.UUID `sql:"type:uuid" gorm:"primary_key;column:queue_task_id"`
+	// the name of the queue
+	Queue string
+	// the priority of the task. Tasks with a higher priority are consumed first
+	Priority int
+	// the data needed to process the task
+	Payload account.ContextInformation `sql:"type:jsonb"`
+	// the optional key which identifies the task. A task is not enqueued if another one with the same key is pending
+	// in the same queue
+	DeduplicationKey *string
+	// the number of times the task was dequeued
+	Attempts int
+	// the maximum number of attempts before the task is dead-lettered
+	MaxAttempts int
+	// the time after which the task can be dequeued
+	VisibleAt time.Time
+	// the consumer which dequeued the task last
+	LockedBy *string
+	// the time at which the task was dequeued last
+	LockedAt *time.Time
+	// the error which occurred during the last attempt, if any
+	LastError *string
+	// the time at which the processing of the task was abandoned, if any
+	DeadLetteredAt *time.Time
+}
+
+// TableName overrides the table name settings in Gorm to force a specific table name
+// in the database.
+func (m QueueTask) TableName() string {
+	return "queue_task"
+}
+
+// GormQueueTaskRepository is the implementation of the storage interface for QueueTask.
+type GormQueueTaskRepository struct {
+	db *gorm.DB
+}
+
+// NewQueueTaskRepository creates a new storage type.
+func NewQueueTaskRepository(db *gorm.DB) QueueTaskRepository {
+	return &GormQueueTaskRepository{db: db}
+}
+
+// QueueTaskRepository represents the storage interface.
+type QueueTaskRepository interface {
+	Load(ctx context.Context, id uuid.UUID) (*QueueTask, error)
+	Enqueue(ctx context.Context, task *QueueTask) error
+	Dequeue(ctx context.Context, queue, consumer string, visibilityTimeout time.Duration, limit int) ([]QueueTask, error)
+	Complete(ctx context.Context, task QueueTask) error
+	Retry(ctx context.Context, task QueueTask, cause error, visibleAt time.Time) error
+	DeadLetter(ctx context.Context, task QueueTask, cause error) error
+	Redrive(ctx context.Context, id uuid.UUID) error
+	ListDeadLettered(ctx context.Context, queue string, limit int) ([]QueueTask, error)
+	CountPending(ctx context.Context, queue string) (int, error)
+}
+
+// Load returns a single task as a Database Model
+func (m *GormQueueTaskRepository) Load(ctx context.Context, id uuid.UUID) (*QueueTask, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "queue_task", "load"}, time.Now())
+	var native QueueTask
+	err := m.db.Where("queue_task_id = ?", id).Find(&native).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, errors.NewNotFoundError("queue_task", id.String())
+	}
+	return &native, errs.WithStack(err)
+}
+
+// Enqueue records a new task, to be processed as soon as its visibility time is reached (immediately by default).
+// Since the task is recorded in the database, it can be enqueued in the same transaction as the change which caused
+// it. Nothing is recorded if a task with the same deduplication key is already pending in the same queue.
+func (m *GormQueueTaskRepository) Enqueue(ctx context.Context, task *QueueTask) error {
+	defer goa.MeasureSince([]string{"goa", "db", "queue_task", "enqueue"}, time.Now())
+	if task.QueueTaskID == uuid.Nil {
+		task.QueueTaskID = uuid.NewV4()
+	}
+	if task.MaxAttempts <= 0 {
+		task.MaxAttempts = DefaultQueueTaskMaxAttempts
+	}
+	now := time.Now()
+	if task.VisibleAt.IsZero() {
+		task.VisibleAt = now
+	}
+	payload, err := task.Payload.Value()
+	if err != nil {
+		return errs.Wrap(err, "unable to encode the payload of the queue task")
+	}
+	result := m.db.Exec(`INSERT INTO queue_task (queue_task_id, queue, priority, payload, deduplication_key, attempts,
+		max_attempts, visible_at, created_at, updated_at) VALUES (?, ?, ?, ?, ?, 0, ?, ?, ?, ?)
+		ON CONFLICT (queue, deduplication_key) WHERE deduplication_key IS NOT NULL AND dead_lettered_at IS NULL DO NOTHING`,
+		task.QueueTaskID, task.Queue, task.Priority, payload, task.DeduplicationKey, task.MaxAttempts, task.VisibleAt, now, now)
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"queue_task_id": task.QueueTaskID,
+			"queue":         task.Queue,
+			"err":           result.Error,
+		}, "unable to create the queue task")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		log.Info(ctx, map[string]interface{}{
+			"queue":             task.Queue,
+			"deduplication_key": *task.DeduplicationKey,
+		}, "queue task already pending")
+		return nil
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"queue_task_id": task.QueueTaskID,
+		"queue":         task.Queue,
+	}, "queue task created!")
+	return nil
+}
+
+// Dequeue locks and returns the pending tasks of the given queue which are visible, the tasks with the highest
+// priority first. The number of results is capped by the given limit.
+// The tasks which are locked by another transaction are skipped, so that multiple consumers can dequeue concurrently
+// without blocking each other nor getting the same tasks. The returned tasks are hidden from the other consumers
+// until the end of the given visibility timeout, and their number of attempts is incremented.
+// The visible tasks which already reached their maximum number of attempts (because their consumer crashed or did not
+// complete them before the end of their visibility timeout) are dead-lettered instead of being handed out again.
+func (m *GormQueueTaskRepository) Dequeue(ctx context.Context, queue, consumer string, visibilityTimeout time.Duration, limit int) ([]QueueTask, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "queue_task", "dequeue"}, time.Now())
+	now := time.Now()
+	result := m.db.Exec(`UPDATE queue_task SET dead_lettered_at = ?, last_error = ?, locked_by = NULL, locked_at = NULL, updated_at = ?
+		WHERE queue_task_id IN (
+			SELECT queue_task_id FROM queue_task
+			WHERE queue = ? AND dead_lettered_at IS NULL AND visible_at <= ? AND attempts >= max_attempts
+			FOR UPDATE SKIP LOCKED)`,
+		now, "the maximum number of attempts was reached without completing the task", now, queue, now)
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"queue":    queue,
+			"consumer": consumer,
+			"err":      result.Error,
+		}, "unable to dead-letter the exhausted queue tasks")
+		return nil, errs.WithStack(result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Warn(ctx, map[string]interface{}{
+			"queue": queue,
+			"count": result.RowsAffected,
+		}, "dead-lettered the queue tasks which reached their maximum number of attempts")
+	}
+	var tasks []QueueTask
+	err := m.db.Raw(`UPDATE queue_task SET attempts = attempts + 1, locked_by = ?, locked_at = ?, visible_at = ?, updated_at = ?
+		WHERE queue_task_id IN (
+			SELECT queue_task_id FROM queue_task
+			WHERE queue = ? AND dead_lettered_at IS NULL AND visible_at <= ? AND attempts < max_attempts
+			ORDER BY priority DESC, visible_at, created_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED)
+		RETURNING *`,
+		consumer, now, now.Add(visibilityTimeout), now, queue, now, limit).Scan(&tasks).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Error(ctx, map[string]interface{}{
+			"queue":    queue,
+			"consumer": consumer,
+			"err":      err,
+		}, "unable to dequeue the queue tasks")
+		return nil, errs.WithStack(err)
+	}
+	return tasks, nil
+}
+
+// Complete removes the given task once it was processed. This is a hard delete!
+// A `NotFoundError` is returned if the task was dequeued again in the meantime (because its visibility timeout
+// expired) or if it does not exist anymore.
+func (m *GormQueueTaskRepository) Complete(ctx context.Context, task QueueTask) error {
+	defer goa.MeasureSince([]string{"goa", "db", "queue_task", "complete"}, time.Now())
+	result := m.db.Where("queue_task_id = ? AND attempts = ?", task.QueueTaskID, task.Attempts).Delete(&QueueTask{})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"queue_task_id": task.QueueTaskID,
+			"err":           result.Error,
+		}, "unable to delete the queue task")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("queue_task", task.QueueTaskID.String())
+	}
+	log.Debug(ctx, map[string]interface{}{
+		"queue_task_id": task.QueueTaskID,
+		"queue":         task.Queue,
+	}, "queue task completed!")
+	return nil
+}
+
+// Retry releases the given task which failed with the given error, so that it can be dequeued again after the given
+// time. A `NotFoundError` is returned if the task was dequeued again in the meantime or if it does not exist anymore.
+func (m *GormQueueTaskRepository) Retry(ctx context.Context, task QueueTask, cause error, visibleAt time.Time) error {
+	defer goa.MeasureSince([]string{"goa", "db", "queue_task", "retry"}, time.Now())
+	return m.release(ctx, task, map[string]interface{}{
+		"visible_at": visibleAt,
+		"last_error": cause.Error(),
+		"locked_by":  nil,
+		"locked_at":  nil,
+	})
+}
+
+// DeadLetter abandons the processing of the given task which failed with the given error. The task is kept for
+// inspection until it is redriven.
+func (m *GormQueueTaskRepository) DeadLetter(ctx context.Context, task QueueTask, cause error) error {
+	defer goa.MeasureSince([]string{"goa", "db", "queue_task", "dead_letter"}, time.Now())
+	return m.release(ctx, task, map[string]interface{}{
+		"dead_lettered_at": time.Now(),
+		"last_error":       cause.Error(),
+		"locked_by":        nil,
+		"locked_at":        nil,
+	})
+}
+
+// release updates the given task, unless it was dequeued again in the meantime
+func (m *GormQueueTaskRepository) release(ctx context.Context, task QueueTask, values map[string]interface{}) error {
+	result := m.db.Model(&QueueTask{}).Where("queue_task_id = ? AND attempts = ?", task.QueueTaskID, task.Attempts).Updates(values)
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"queue_task_id": task.QueueTaskID,
+			"err":           result.Error,
+		}, "unable to update the queue task")
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("queue_task", task.QueueTaskID.String())
+	}
+	return nil
+}
+
+// Redrive puts the dead-lettered task with the given ID back in its queue, with a new set of attempts
+func (m *GormQueueTaskRepository) Redrive(ctx context.Context, id uuid.UUID) error {
+	defer goa.MeasureSince([]string{"goa", "db", "queue_task", "redrive"}, time.Now())
+	result := m.db.Model(&QueueTask{}).Where("queue_task_id = ? AND dead_lettered_at IS NOT NULL", id).Updates(map[string]interface{}{
+		"dead_lettered_at": nil,
+		"attempts":         0,
+		"visible_at":       time.Now(),
+	})
+	if result.Error != nil {
+		log.Error(ctx, map[string]interface{}{
+			"queue_task_id": id,
+			"err":           result.Error,
+		}, "unable to redrive the queue task")
+		if gormsupport.IsUniqueViolation(result.Error, "queue_task_deduplication_key_idx") {
+			return errors.NewDataConflictError("a task with the same deduplication key is already pending")
+		}
+		return errs.WithStack(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.NewNotFoundError("dead-lettered queue_task", id.String())
+	}
+	return nil
+}
+
+// ListDeadLettered returns the dead-lettered tasks of the given queue, the most recent ones first. The number of
+// results is capped by the given limit.
+func (m *GormQueueTaskRepository) ListDeadLettered(ctx context.Context, queue string, limit int) ([]QueueTask, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "queue_task", "list_dead_lettered"}, time.Now())
+	var tasks []QueueTask
+	err := m.db.Where("queue = ? AND dead_lettered_at IS NOT NULL", queue).Order("dead_lettered_at DESC").Limit(limit).Find(&tasks).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Error(ctx, map[string]interface{}{
+			"queue": queue,
+			"err":   err,
+		}, "unable to list the dead-lettered queue tasks")
+		return nil, errs.WithStack(err)
+	}
+	return tasks, nil
+}
+
+// CountPending returns the number of tasks of the given queue which were not dead-lettered, including the tasks which
+// are being processed
+func (m *GormQueueTaskRepository) CountPending(ctx context.Context, queue string) (int, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "queue_task", "count_pending"}, time.Now())
+	var count int
+	err := m.db.Model(&QueueTask{}).Where("queue = ? AND dead_lettered_at IS NULL", queue).Count(&count).Error
+	if err != nil {
+		return 0, errs.WithStack(err)
+	}
+	return count, nil
+}
@@ -3,18 +3,84 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"time"
 
+	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/log"
 
 	"cirello.io/pglock"
+	"github.com/goadesign/goa"
+	"github.com/lib/pq"
 	errs "github.com/pkg/errors"
 )
 
+const (
+	// DefaultLockLeaseDuration the duration after which a lock can be taken over by another owner if it was not
+	// renewed by a heartbeat. Also, the age of the last heartbeat after which a lock is considered stale.
+	DefaultLockLeaseDuration = 30 * time.Second
+	// DefaultLockHeartbeatFrequency the frequency at which the owner of a lock renews its lease
+	DefaultLockHeartbeatFrequency = 10 * time.Second
+)
+
+// LockStatus the status of a worker lock, as recorded in the database
+type LockStatus struct {
+	// the name of the lock
+	Name string
+	// the current owner of the lock (eg, the name of the Pod)
+	Owner string
+	// the time of the last heartbeat of the owner, or nil if no heartbeat was recorded yet
+	HeartbeatAt *time.Time
+	// the age of the last heartbeat, computed with the clock of the database which also records the heartbeats
+	heartbeatAge *time.Duration
+}
+
+// HeartbeatAge returns the time elapsed since the last heartbeat of the owner of the lock, according to the clock of
+// the database, or nil if no heartbeat was recorded yet
+func (s LockStatus) HeartbeatAge() *time.Duration {
+	return s.heartbeatAge
+}
+
+// IsStale returns true if no heartbeat of the owner of the lock was recorded since the given duration
+func (s LockStatus) IsStale(staleAfter time.Duration) bool {
+	age := s.HeartbeatAge()
+	return age == nil || *age > staleAfter
+}
+
+// lockStatusColumns the columns to scan with `scanLockStatus`
+const lockStatusColumns = "name, owner, heartbeat_at, EXTRACT(EPOCH FROM now() - heartbeat_at)"
+
+// scanLockStatus scans a row of the `lockStatusColumns` into a lock status
+func scanLockStatus(row interface {
+	Scan(dest ...interface{}) error
+}) (*LockStatus, error) {
+	var status LockStatus
+	var owner sql.NullString
+	var heartbeatAt pq.NullTime
+	var heartbeatAge sql.NullFloat64
+	err := row.Scan(&status.Name, &owner, &heartbeatAt, &heartbeatAge)
+	if err != nil {
+		return nil, err
+	}
+	status.Owner = owner.String
+	if heartbeatAt.Valid {
+		t := heartbeatAt.Time
+		status.HeartbeatAt = &t
+	}
+	if heartbeatAge.Valid {
+		age := time.Duration(heartbeatAge.Float64 * float64(time.Second))
+		status.heartbeatAge = &age
+	}
+	return &status, nil
+}
+
 // LockRepository the interface for the repository
 type LockRepository interface {
 	AcquireLock(ctx context.Context, owner string, name string, opts ...pglock.ClientOption) (*pglock.Lock, error)
 	GetLock(ctx context.Context, name string) (*pglock.Lock, error)
+	GetLockStatus(ctx context.Context, name string) (*LockStatus, error)
+	ListLockStatuses(ctx context.Context) ([]LockStatus, error)
+	ForceReleaseLock(ctx context.Context, name string, staleAfter time.Duration, force bool) error
 }
 
 type lockRepositoryImpl struct {
@@ -44,8 +110,8 @@ func (r *lockRepositoryImpl) AcquireLock(ctx context.Context, owner, name string
 		// Use default
 		clnOpts = []pglock.ClientOption{
 			pglock.WithCustomTable("worker_lock"),
-			pglock.WithLeaseDuration(30 * time.Second),
-			pglock.WithHeartbeatFrequency(10 * time.Second),
+			pglock.WithLeaseDuration(DefaultLockLeaseDuration),
+			pglock.WithHeartbeatFrequency(DefaultLockHeartbeatFrequency),
 			pglock.WithLogger(log.Logger()),
 		}
 	}
@@ -103,3 +169,73 @@ func (r *lockRepositoryImpl) GetLock(ctx context.Context, name string) (*pglock.
 	}, "obtained existing lock")
 	return l, nil
 }
+
+// GetLockStatus returns the status of the lock with the given name, or a `NotFoundError` if the lock is not held
+func (r *lockRepositoryImpl) GetLockStatus(ctx context.Context, name string) (*LockStatus, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "worker_lock", "get_status"}, time.Now())
+	status, err := scanLockStatus(r.db.QueryRowContext(ctx, "SELECT "+lockStatusColumns+" FROM worker_lock WHERE name = $1", name))
+	if err == sql.ErrNoRows {
+		return nil, errors.NewNotFoundErrorWithKey("worker_lock", "name", name)
+	}
+	if err != nil {
+		return nil, errs.Wrapf(err, "cannot obtain the status of the lock '%s'", name)
+	}
+	return status, nil
+}
+
+// ListLockStatuses returns the status of all the locks which are currently held, ordered by name
+func (r *lockRepositoryImpl) ListLockStatuses(ctx context.Context) ([]LockStatus, error) {
+	defer goa.MeasureSince([]string{"goa", "db", "worker_lock", "list_statuses"}, time.Now())
+	rows, err := r.db.QueryContext(ctx, "SELECT "+lockStatusColumns+" FROM worker_lock ORDER BY name")
+	if err != nil {
+		return nil, errs.Wrap(err, "cannot list the worker locks")
+	}
+	defer rows.Close()
+	statuses := []LockStatus{}
+	for rows.Next() {
+		status, err := scanLockStatus(rows)
+		if err != nil {
+			return nil, errs.Wrap(err, "cannot list the worker locks")
+		}
+		statuses = append(statuses, *status)
+	}
+	return statuses, errs.WithStack(rows.Err())
+}
+
+// ForceReleaseLock removes the lock with the given name, so that another owner can acquire it without waiting for
+// the end of its lease. Unless `force` is true, the lock is removed only if no heartbeat of its owner was recorded
+// since the given duration (according to the clock of the database): a `DataConflictError` is returned otherwise,
+// since the owner is probably still alive.
+func (r *lockRepositoryImpl) ForceReleaseLock(ctx context.Context, name string, staleAfter time.Duration, force bool) error {
+	defer goa.MeasureSince([]string{"goa", "db", "worker_lock", "force_release"}, time.Now())
+	status, err := r.GetLockStatus(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !force && !status.IsStale(staleAfter) {
+		return errors.NewDataConflictError(fmt.Sprintf("the lock '%s' is not stale: its owner '%s' is still alive", name, status.Owner))
+	}
+	result, err := r.db.ExecContext(ctx, `DELETE FROM worker_lock WHERE name = $1
+		AND ($2 OR heartbeat_at IS NULL OR heartbeat_at < now() - $3 * interval '1 millisecond')`,
+		name, force, staleAfter.Nanoseconds()/int64(time.Millisecond))
+	if err != nil {
+		return errs.Wrapf(err, "cannot release the lock '%s'", name)
+	}
+	if count, err := result.RowsAffected(); err != nil {
+		return errs.Wrapf(err, "cannot release the lock '%s'", name)
+	} else if count == 0 {
+		if force {
+			// the lock was released in the meantime
+			return errors.NewNotFoundErrorWithKey("worker_lock", "name", name)
+		}
+		// the lock was renewed or released in the meantime
+		return errors.NewDataConflictError(fmt.Sprintf("the lock '%s' is not stale anymore", name))
+	}
+	log.Warn(ctx, map[string]interface{}{
+		"lock":         name,
+		"owner":        status.Owner,
+		"heartbeat_at": status.HeartbeatAt,
+		"forced":       force,
+	}, "lock released")
+	return nil
+}
@@ -0,0 +1,19 @@
+package worker
+
+import "time"
+
+// maxRetryDelayExponent caps the exponential backoff between 2 attempts
+const maxRetryDelayExponent = 10
+
+// RetryDelay returns the delay before the next attempt of a task which failed the given number of times.
+// The delay starts with the given initial delay and doubles after each failed attempt.
+func RetryDelay(initialDelay time.Duration, attempts int) time.Duration {
+	exp := attempts - 1
+	if exp > maxRetryDelayExponent {
+		exp = maxRetryDelayExponent
+	}
+	if exp < 0 {
+		exp = 0
+	}
+	return initialDelay * time.Duration(1<<uint(exp))
+}
@@ -0,0 +1,189 @@
+package worker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	errs "github.com/pkg/errors"
+)
+
+// Schedule the schedule of a job
+type Schedule interface {
+	// Next returns the first time after the given time at which the job should run
+	Next(t time.Time) time.Time
+}
+
+// descriptors the predefined schedules
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseSchedule parses the given schedule specification, which is either:
+// - a standard cron expression with 5 fields (minute, hour, day of month, month, day of week), eg: `*/15 * * * *`.
+// Each field supports `*`, values, ranges (`1-5`), steps (`*/2`, `0-30/10`) and lists (`1,15`).
+// - a predefined schedule: `@yearly`, `@monthly`, `@weekly`, `@daily`, `@hourly`
+// - a fixed interval: `@every <duration>`, eg: `@every 5m`
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, errs.Wrapf(err, "invalid schedule '%s'", spec)
+		}
+		if d < time.Second {
+			return nil, errs.Errorf("invalid schedule '%s': the interval must be at least 1s", spec)
+		}
+		return everySchedule{interval: d}, nil
+	}
+	if expr, found := descriptors[spec]; found {
+		spec = expr
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, errs.Errorf("invalid schedule '%s': expected 5 fields but got %d", spec, len(fields))
+	}
+	var err error
+	s := cronSchedule{
+		domRestricted: fields[2] != "*",
+		dowRestricted: fields[4] != "*",
+	}
+	if s.minute, err = parseField(fields[0], 0, 59); err != nil {
+		return nil, errs.Wrapf(err, "invalid minute in schedule '%s'", spec)
+	}
+	if s.hour, err = parseField(fields[1], 0, 23); err != nil {
+		return nil, errs.Wrapf(err, "invalid hour in schedule '%s'", spec)
+	}
+	if s.dom, err = parseField(fields[2], 1, 31); err != nil {
+		return nil, errs.Wrapf(err, "invalid day of month in schedule '%s'", spec)
+	}
+	if s.month, err = parseField(fields[3], 1, 12); err != nil {
+		return nil, errs.Wrapf(err, "invalid month in schedule '%s'", spec)
+	}
+	if s.dow, err = parseField(fields[4], 0, 7); err != nil {
+		return nil, errs.Wrapf(err, "invalid day of week in schedule '%s'", spec)
+	}
+	// both 0 and 7 stand for sunday
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+// parseField parses a field of a cron expression into a bitset of the matching values
+func parseField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, errs.Errorf("invalid step in '%s'", part)
+			}
+			part = part[:i]
+		}
+		from, to := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, errs.Errorf("invalid range '%s'", part)
+			}
+			if to, err = strconv.Atoi(bounds[1]); err != nil {
+				return 0, errs.Errorf("invalid range '%s'", part)
+			}
+		default:
+			var err error
+			if from, err = strconv.Atoi(part); err != nil {
+				return 0, errs.Errorf("invalid value '%s'", part)
+			}
+			if step == 1 {
+				// a single value, unless followed by a step (eg: `5/10` means from 5 to max every 10)
+				to = from
+			}
+		}
+		if from < min || to > max || from > to {
+			return 0, errs.Errorf("'%s' is out of range [%d-%d]", part, min, max)
+		}
+		for v := from; v <= to; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// cronSchedule a schedule defined by a cron expression
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domRestricted, dowRestricted  bool
+}
+
+// maxScheduleLookahead the limit after which an expression which never matches (eg: `0 0 31 2 *`) is given up
+const maxScheduleLookahead = 5 * 366 * 24 * time.Hour
+
+// Next returns the first time after the given time matching the cron expression, or the zero time if none was found
+func (s cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	limit := t.Add(maxScheduleLookahead)
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches checks the day of month and the day of week. As with the standard cron, if both are restricted then
+// the day matches when either of them matches
+func (s cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domRestricted && s.dowRestricted {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// Every returns the specification of a schedule with the given fixed interval between 2 runs, eg: `@every 5m0s`
+func Every(interval time.Duration) string {
+	return everySchedule{interval: interval}.String()
+}
+
+// everySchedule a schedule with a fixed interval between 2 runs
+type everySchedule struct {
+	interval time.Duration
+}
+
+// Next returns the given time plus the interval
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(s.interval)
+}
+
+func (s everySchedule) String() string {
+	return fmt.Sprintf("@every %s", s.interval)
+}
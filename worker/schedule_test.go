@@ -0,0 +1,67 @@
+package worker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/resource"
+	"github.com/fabric8-services/fabric8-auth/worker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule(t *testing.T) {
+	resource.Require(t, resource.UnitTest)
+
+	// a wednesday
+	now := time.Date(2019, time.March, 13, 10, 42, 30, 0, time.UTC)
+
+	t.Run("ok", func(t *testing.T) {
+		testcases := []struct {
+			spec     string
+			expected time.Time
+		}{
+			{"* * * * *", time.Date(2019, time.March, 13, 10, 43, 0, 0, time.UTC)},
+			{"*/15 * * * *", time.Date(2019, time.March, 13, 10, 45, 0, 0, time.UTC)},
+			{"0,30 8-9 * * *", time.Date(2019, time.March, 14, 8, 0, 0, 0, time.UTC)},
+			{"5/20 * * * *", time.Date(2019, time.March, 13, 10, 45, 0, 0, time.UTC)},
+			{"0 0 * * 0", time.Date(2019, time.March, 17, 0, 0, 0, 0, time.UTC)},
+			{"0 0 * * 7", time.Date(2019, time.March, 17, 0, 0, 0, 0, time.UTC)},
+			{"0 0 1 * 5", time.Date(2019, time.March, 15, 0, 0, 0, 0, time.UTC)},
+			{"30 2 29 2 *", time.Date(2020, time.February, 29, 2, 30, 0, 0, time.UTC)},
+			{"@hourly", time.Date(2019, time.March, 13, 11, 0, 0, 0, time.UTC)},
+			{"@daily", time.Date(2019, time.March, 14, 0, 0, 0, 0, time.UTC)},
+			{"@monthly", time.Date(2019, time.April, 1, 0, 0, 0, 0, time.UTC)},
+			{"@every 90s", time.Date(2019, time.March, 13, 10, 44, 0, 0, time.UTC)},
+		}
+		for _, tc := range testcases {
+			t.Run(tc.spec, func(t *testing.T) {
+				s, err := worker.ParseSchedule(tc.spec)
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, s.Next(now))
+			})
+		}
+	})
+
+	t.Run("every", func(t *testing.T) {
+		s, err := worker.ParseSchedule(worker.Every(10 * time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, now.Add(10*time.Minute), s.Next(now))
+	})
+
+	t.Run("never", func(t *testing.T) {
+		s, err := worker.ParseSchedule("0 0 31 2 *")
+		require.NoError(t, err)
+		assert.True(t, s.Next(now).IsZero())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@every", "@every 1ms", "@sometimes"} {
+			t.Run(spec, func(t *testing.T) {
+				_, err := worker.ParseSchedule(spec)
+				assert.Error(t, err)
+			})
+		}
+	})
+}
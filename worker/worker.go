@@ -2,12 +2,17 @@ package worker
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/fabric8-services/fabric8-auth/application"
+	"github.com/fabric8-services/fabric8-auth/errors"
 	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/metric"
+	"github.com/fabric8-services/fabric8-auth/worker/repository"
 
 	"cirello.io/pglock"
+	errs "github.com/pkg/errors"
 )
 
 // Worker the interface for the workers
@@ -15,6 +20,27 @@ type Worker interface {
 	Start(freq time.Duration)
 	Stop()
 	IsStopped() bool
+	Status() Status
+}
+
+// Status the status of a worker in the current pod
+type Status struct {
+	// Name the name of the worker, which is also the name of its lock
+	Name string
+	// Owner the owner of the lock in the current pod
+	Owner string
+	// LockHeld true if the lock of the worker is held by the current pod
+	LockHeld bool
+	// LockOwner the owner which currently holds the lock of the worker, in any pod
+	LockOwner *string
+	// HeartbeatAge the time elapsed since the last heartbeat of the owner of the lock
+	HeartbeatAge *time.Duration
+	// LastCycleStartedAt the time at which the last cycle of the worker started in the current pod
+	LastCycleStartedAt *time.Time
+	// LastCycleEndedAt the time at which the last cycle of the worker ended in the current pod
+	LastCycleEndedAt *time.Time
+	// LastCycleError the error (or panic) which interrupted the last cycle of the worker, if any
+	LastCycleError *string
 }
 
 // BaseWorker the base worker
@@ -25,11 +51,18 @@ type BaseWorker struct {
 	Owner string // owner of the lock (eg, the name of the Pod), to use when claiming a lock
 	Do    func() // the function to run the business code at each cycle of the worker
 	Opts  []pglock.ClientOption
+	// StaleLockAge the age of the last heartbeat of the lock owner after which the lock is taken over by this worker
+	// (default: `repository.DefaultLockLeaseDuration`)
+	StaleLockAge time.Duration
+	// OnLockAcquired the function to run each time the lock is acquired by this worker, before its next cycle (optional)
+	OnLockAcquired func()
 
 	running bool // state of the worker
 	lock    *pglock.Lock
 	ticker  *time.Ticker
 	stopCh  chan bool
+	mux     sync.Mutex
+	status  Status // status of the last cycle of the worker
 }
 
 // verify that `BaseWorker` is an implementation of `Worker`
@@ -48,7 +81,7 @@ func (w *BaseWorker) Start(freq time.Duration) {
 	}, "starting worker")
 	w.ticker = time.NewTicker(freq)
 	go func() {
-		w.acquireLock() // will wait until succeed
+		w.acquireLock() // stands by if the lock is held by another pod
 		for {
 			select {
 			case <-w.ticker.C:
@@ -61,7 +94,28 @@ func (w *BaseWorker) Start(freq time.Duration) {
 	}()
 }
 
+// acquireLock acquires the lock of the worker, unless it is held by another owner which is still alive, in which
+// case the worker stands by and tries again at the next cycle. A stale lock is taken over once its lease expired.
 func (w *BaseWorker) acquireLock() {
+	status, err := w.App.WorkerLockRepository().GetLockStatus(w.Ctx, w.Name)
+	if err != nil {
+		if notFound, _ := errors.IsNotFoundError(err); !notFound {
+			log.Warn(w.Ctx, map[string]interface{}{
+				"error": err,
+				"owner": w.Owner,
+				"name":  w.Name,
+			}, "unable to check the existing lock's owner")
+			return
+		}
+	} else if status.Owner != w.Owner && !status.IsStale(w.staleLockAge()) {
+		log.Debug(w.Ctx, map[string]interface{}{
+			"owner":      w.Owner,
+			"name":       w.Name,
+			"lock_owner": status.Owner,
+		}, "lock is held by another owner, standing by")
+		metric.RecordWorkerLock(w.Name, false, status.HeartbeatAge())
+		return
+	}
 	l, err := w.App.WorkerLockRepository().AcquireLock(w.Ctx, w.Owner, w.Name, w.Opts...)
 	if err != nil {
 		log.Warn(w.Ctx, map[string]interface{}{
@@ -78,11 +132,46 @@ func (w *BaseWorker) acquireLock() {
 		"name":  w.Name,
 	}, "acquired lock")
 	w.lock = l
+	w.mux.Lock()
+	if w.Owner == "" {
+		// the lock client generated a random owner
+		w.Owner = l.Owner()
+	}
+	w.status.LockHeld = true
+	w.mux.Unlock()
+	metric.RecordWorkerLock(w.Name, true, nil)
+	if w.OnLockAcquired != nil {
+		w.OnLockAcquired()
+	}
+}
+
+// checkLock returns true if the lock is still held by the current owner. If the lock was lost (eg, because it was
+// force-released after a heartbeat failure), then it is closed so that it can be acquired again.
+func (w *BaseWorker) checkLock() (bool, error) {
+	if w.lock == nil {
+		return false, nil
+	}
+	status, err := w.App.WorkerLockRepository().GetLockStatus(w.Ctx, w.Name)
+	if err != nil {
+		if notFound, _ := errors.IsNotFoundError(err); !notFound {
+			return false, err
+		}
+	} else if status.Owner == w.Owner {
+		metric.RecordWorkerLock(w.Name, true, status.HeartbeatAge())
+		return true, nil
+	}
+	// Theoretically it can happen if the heartbeat failed for some reason and the lock has been acquired by another owner
+	log.Error(w.Ctx, map[string]interface{}{
+		"owner": w.Owner,
+		"name":  w.Name,
+	}, "the current owner lost the lock! will try to re-acquire it")
+	w.releaseLock()
+	return false, nil
 }
 
 func (w *BaseWorker) execute() {
 	// Check if the lock is still hold by the current owner
-	l, err := w.App.WorkerLockRepository().GetLock(w.Ctx, w.Name)
+	held, err := w.checkLock()
 	if err != nil {
 		log.Warn(w.Ctx, map[string]interface{}{
 			"error": err,
@@ -91,13 +180,12 @@ func (w *BaseWorker) execute() {
 		}, "unable to check the existing lock's owner")
 		return
 	}
-	if l.Owner() != w.Owner {
-		// Theoretically it can happen if the heartbeat failed for some reason and the lock has been acquired by another owner
-		log.Error(w.Ctx, map[string]interface{}{
-			"owner": w.Owner,
-			"name":  w.Name,
-		}, "the current owner lost the lock! will try to re-acquire it")
-		w.acquireLock() // will wait until succeed
+	if !held {
+		w.acquireLock()
+		if w.lock == nil {
+			// the lock is held by another pod
+			return
+		}
 	}
 	if w.Do == nil {
 		log.Warn(w.Ctx, map[string]interface{}{
@@ -105,7 +193,61 @@ func (w *BaseWorker) execute() {
 		}, "nothing to do in this worker?!?")
 		return
 	}
+	w.run()
+}
+
+// run runs the business code of the worker and records the outcome of the cycle. A panic is recorded as a failed
+// cycle, so that the worker keeps running.
+func (w *BaseWorker) run() {
+	start := time.Now()
+	w.mux.Lock()
+	w.status.LastCycleStartedAt = &start
+	w.mux.Unlock()
+	err := w.do()
+	end := time.Now()
+	w.mux.Lock()
+	w.status.LastCycleEndedAt = &end
+	w.status.LastCycleError = nil
+	if err != nil {
+		msg := err.Error()
+		w.status.LastCycleError = &msg
+	}
+	w.mux.Unlock()
+	if err != nil {
+		log.Error(w.Ctx, map[string]interface{}{
+			"err":   err,
+			"owner": w.Owner,
+			"name":  w.Name,
+		}, "worker cycle failed")
+	}
+	metric.RecordWorkerCycle(w.Name, err == nil, end)
+}
+
+func (w *BaseWorker) do() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errs.Errorf("worker panicked: %v", r)
+		}
+	}()
 	w.Do()
+	return nil
+}
+
+func (w *BaseWorker) staleLockAge() time.Duration {
+	if w.StaleLockAge > 0 {
+		return w.StaleLockAge
+	}
+	return repository.DefaultLockLeaseDuration
+}
+
+// Status returns the status of the worker in the current pod
+func (w *BaseWorker) Status() Status {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	status := w.status
+	status.Name = w.Name
+	status.Owner = w.Owner
+	return status
 }
 
 // Stop stops the worker
@@ -139,20 +281,31 @@ func (w *BaseWorker) cleanup() {
 		"owner": w.Owner,
 		"name":  w.Name,
 	}, "releasing the worker lock")
-	if w.lock != nil {
-		err := w.lock.Close()
-		if err != nil {
-			log.Error(w.Ctx, map[string]interface{}{
-				"err":   err,
-				"owner": w.Owner,
-				"name":  w.Name,
-			}, "error while releasing worker lock")
-		} else {
-			log.Info(w.Ctx, map[string]interface{}{
-				"owner": w.Owner,
-				"name":  w.Name,
-			}, "worker lock released")
-		}
-	}
+	w.releaseLock()
 	close(w.stopCh)
 }
+
+// releaseLock releases the lock of the worker, if it is held
+func (w *BaseWorker) releaseLock() {
+	if w.lock == nil {
+		return
+	}
+	err := w.lock.Close()
+	if err != nil {
+		log.Error(w.Ctx, map[string]interface{}{
+			"err":   err,
+			"owner": w.Owner,
+			"name":  w.Name,
+		}, "error while releasing worker lock")
+	} else {
+		log.Info(w.Ctx, map[string]interface{}{
+			"owner": w.Owner,
+			"name":  w.Name,
+		}, "worker lock released")
+	}
+	w.lock = nil
+	w.mux.Lock()
+	w.status.LockHeld = false
+	w.mux.Unlock()
+	metric.RecordWorkerLock(w.Name, false, nil)
+}
@@ -0,0 +1,187 @@
+package worker_test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fabric8-services/fabric8-auth/errors"
+	"github.com/fabric8-services/fabric8-auth/log"
+	"github.com/fabric8-services/fabric8-auth/worker"
+
+	"cirello.io/pglock"
+	errs "github.com/pkg/errors"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *WorkerTestSuite) TestLockHandover() {
+
+	ctx := context.Background()
+	freq := time.Millisecond * 50
+
+	// eventually waits until the given condition is met, or fails after a few seconds
+	eventually := func(t *testing.T, condition func() bool) {
+		for i := 0; i < 100; i++ {
+			if condition() {
+				return
+			}
+			time.Sleep(freq)
+		}
+		require.FailNow(t, "condition not met in time")
+	}
+	newWorker := func(name, owner string, count *int32) *worker.BaseWorker {
+		return &worker.BaseWorker{
+			Ctx:   ctx,
+			App:   s.Application,
+			Owner: owner,
+			Name:  name,
+			Opts: []pglock.ClientOption{
+				pglock.WithCustomTable("worker_lock"),
+				pglock.WithLeaseDuration(freq * 4),
+				pglock.WithHeartbeatFrequency(freq),
+				pglock.WithLogger(log.Logger()),
+			},
+			StaleLockAge: freq * 4,
+			Do: func() {
+				atomic.AddInt32(count, 1)
+			},
+		}
+	}
+
+	s.T().Run("standby worker takes over after drain", func(t *testing.T) {
+		// given
+		name := fmt.Sprintf("test-worker-%s", uuid.NewV4())
+		var count1, count2 int32
+		w1 := newWorker(name, "owner-1", &count1)
+		w2 := newWorker(name, "owner-2", &count2)
+		w1.Start(freq)
+		eventually(t, func() bool {
+			return w1.Status().LockHeld
+		})
+		w2.Start(freq)
+		defer stop(w2)
+		time.Sleep(freq * 4)
+		assert.False(t, w2.Status().LockHeld)
+		assert.Equal(t, int32(0), atomic.LoadInt32(&count2))
+		// when
+		registry := worker.NewRegistry(s.Application)
+		registry.Add(w1)
+		stopped := registry.Drain(5 * time.Second)
+		// then
+		assert.True(t, stopped)
+		eventually(t, func() bool {
+			return w2.Status().LockHeld && atomic.LoadInt32(&count2) > 0
+		})
+		status, err := s.Application.WorkerLockRepository().GetLockStatus(ctx, name)
+		require.NoError(t, err)
+		assert.Equal(t, "owner-2", status.Owner)
+	})
+
+	s.T().Run("status", func(t *testing.T) {
+		// given
+		name := fmt.Sprintf("test-worker-%s", uuid.NewV4())
+		var count int32
+		w := newWorker(name, "owner-1", &count)
+		w.Do = func() {
+			if atomic.AddInt32(&count, 1) == 1 {
+				panic("boom")
+			}
+		}
+		registry := worker.NewRegistry(s.Application)
+		registry.Add(w)
+		// when
+		w.Start(freq)
+		defer registry.Drain(5 * time.Second)
+		// then the worker keeps running after a panic
+		eventually(t, func() bool {
+			return atomic.LoadInt32(&count) > 1
+		})
+		statuses, err := registry.Status(ctx)
+		require.NoError(t, err)
+		require.Len(t, statuses, 1)
+		assert.Equal(t, name, statuses[0].Name)
+		assert.Equal(t, "owner-1", statuses[0].Owner)
+		assert.True(t, statuses[0].LockHeld)
+		require.NotNil(t, statuses[0].LockOwner)
+		assert.Equal(t, "owner-1", *statuses[0].LockOwner)
+		require.NotNil(t, statuses[0].HeartbeatAge)
+		assert.True(t, *statuses[0].HeartbeatAge < time.Second)
+		assert.NotNil(t, statuses[0].LastCycleStartedAt)
+		assert.NotNil(t, statuses[0].LastCycleEndedAt)
+	})
+}
+
+func (s *WorkerTestSuite) TestForceReleaseLock() {
+
+	ctx := context.Background()
+	repo := s.Application.WorkerLockRepository()
+
+	// insertLock records a lock whose owner does not send any heartbeat
+	insertLock := func(t *testing.T, name string) {
+		err := s.DB.Exec("INSERT INTO worker_lock (name, record_version_number, owner) VALUES (?, nextval('worker_lock_rvn'), ?)", name, "dead-owner").Error
+		require.NoError(t, err)
+	}
+
+	s.T().Run("stale lock", func(t *testing.T) {
+		// given
+		name := fmt.Sprintf("test-lock-%s", uuid.NewV4())
+		insertLock(t, name)
+		time.Sleep(200 * time.Millisecond)
+		// when
+		err := repo.ForceReleaseLock(ctx, name, 100*time.Millisecond, false)
+		// then
+		require.NoError(t, err)
+		_, err = repo.GetLockStatus(ctx, name)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+
+	s.T().Run("lock is not stale", func(t *testing.T) {
+		// given
+		name := fmt.Sprintf("test-lock-%s", uuid.NewV4())
+		insertLock(t, name)
+		// when
+		err := repo.ForceReleaseLock(ctx, name, time.Minute, false)
+		// then
+		assert.IsType(t, errors.DataConflictError{}, errs.Cause(err))
+		status, err := repo.GetLockStatus(ctx, name)
+		require.NoError(t, err)
+		assert.Equal(t, "dead-owner", status.Owner)
+		assert.NotNil(t, status.HeartbeatAt)
+	})
+
+	s.T().Run("lock is not stale but release is forced", func(t *testing.T) {
+		// given
+		name := fmt.Sprintf("test-lock-%s", uuid.NewV4())
+		insertLock(t, name)
+		// when
+		err := repo.ForceReleaseLock(ctx, name, time.Minute, true)
+		// then
+		require.NoError(t, err)
+		_, err = repo.GetLockStatus(ctx, name)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+
+	s.T().Run("heartbeat age from the database clock", func(t *testing.T) {
+		// given a heartbeat recorded by the database one hour ago
+		name := fmt.Sprintf("test-lock-%s", uuid.NewV4())
+		insertLock(t, name)
+		err := s.DB.Exec("UPDATE worker_lock SET heartbeat_at = now() - interval '1 hour' WHERE name = ?", name).Error
+		require.NoError(t, err)
+		// when
+		status, err := repo.GetLockStatus(ctx, name)
+		// then
+		require.NoError(t, err)
+		require.NotNil(t, status.HeartbeatAge())
+		assert.InDelta(t, time.Hour.Seconds(), status.HeartbeatAge().Seconds(), 5)
+		assert.True(t, status.IsStale(time.Minute))
+	})
+
+	s.T().Run("unknown lock", func(t *testing.T) {
+		err := repo.ForceReleaseLock(ctx, uuid.NewV4().String(), time.Minute, false)
+		assert.IsType(t, errors.NotFoundError{}, errs.Cause(err))
+	})
+}